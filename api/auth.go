@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rancher/go-rancher/api"
+	"github.com/rancher/go-rancher/client"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+var authExemptPaths = map[string]struct{}{
+	"/metrics": {},
+}
+
+type apiRoleContextKey struct{}
+
+// getRequestRole returns the role the request is authenticated with, or empty
+// if the API authentication is disabled.
+func getRequestRole(req *http.Request) string {
+	role, _ := req.Context().Value(apiRoleContextKey{}).(string)
+	return role
+}
+
+// authMiddleware authenticates the request by a verified client certificate
+// or a token, and only allows the admin role to make changes. It's a no-op
+// unless the API authentication setting is enabled.
+func (s *Server) authMiddleware(schemas *client.Schemas) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if _, ok := authExemptPaths[req.URL.Path]; ok {
+				next.ServeHTTP(rw, req)
+				return
+			}
+			role, err := s.authorize(req)
+			if err != nil {
+				api.ApiHandler(schemas, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+					logrus.Warnf("HTTP authorization error %v", err)
+					writeErr(api.GetApiContext(req), rw, err)
+				})).ServeHTTP(rw, req)
+				return
+			}
+			if role != "" {
+				req = req.WithContext(context.WithValue(req.Context(), apiRoleContextKey{}, role))
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+func (s *Server) authorize(req *http.Request) (string, error) {
+	enabled, err := s.m.IsAPIAuthenticationEnabled()
+	if err != nil {
+		return "", err
+	}
+	if !enabled {
+		return "", nil
+	}
+
+	role := s.getForwardedRole(req)
+	if role == "" && hasVerifiedCertificate(req) {
+		role = manager.GetAPICertificateRole(req.TLS.VerifiedChains[0][0])
+	}
+	if role == "" {
+		if role, err = s.m.GetAPITokenRole(getRequestToken(req)); err != nil {
+			return "", err
+		}
+	}
+	if role == "" {
+		return "", types.NewReasonError(types.ErrorReasonUnauthorized, nil, "authentication is required")
+	}
+	if role != types.APIRoleAdmin && !isReadOnlyMethod(req.Method) {
+		return "", types.NewReasonError(types.ErrorReasonForbidden,
+			map[string]string{"role": role},
+			"role %v is not allowed to %v %v", role, req.Method, req.URL.Path)
+	}
+	return role, nil
+}
+
+// getForwardedRole returns the role the request was authenticated with by the
+// manager forwarding it, since the client certificate doesn't reach this
+// manager. It's only trusted if the forwarding manager is verified by its own
+// certificate.
+func (s *Server) getForwardedRole(req *http.Request) string {
+	role := req.Header.Get(HeaderForwardedRole)
+	if role == "" {
+		return ""
+	}
+	if !hasVerifiedCertificate(req) || !s.fwd.isForwardedByManager(req) {
+		logrus.Warnf("Ignoring header %v of request %v %v not forwarded by a verified manager", HeaderForwardedRole, req.Method, req.URL.Path)
+		return ""
+	}
+	return role
+}
+
+func hasVerifiedCertificate(req *http.Request) bool {
+	return req.TLS != nil && len(req.TLS.VerifiedChains) != 0 && len(req.TLS.VerifiedChains[0]) != 0
+}
+
+// getRequestToken returns the bearer token, or the basic auth password as
+// sent by the Longhorn API client.
+func getRequestToken(req *http.Request) string {
+	if _, password, ok := req.BasicAuth(); ok {
+		return password
+	}
+	auth := req.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return ""
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
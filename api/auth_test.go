@@ -0,0 +1,228 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+const (
+	testNamespace   = "longhorn-system"
+	testNode        = "test-node-name-1"
+	testAdminToken  = "admin-token"
+	testReaderToken = "reader-token"
+)
+
+func newTestAuthServer(t *testing.T, stopCh chan struct{}, enabled bool) *Server {
+	setting := &longhorn.Setting{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      string(types.SettingNameAPIAuthentication),
+			Namespace: testNamespace,
+		},
+		Value: "false",
+	}
+	if enabled {
+		setting.Value = "true"
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.APIAuthSecretName,
+			Namespace: testNamespace,
+		},
+		Data: map[string][]byte{
+			types.APIRoleAdmin:    []byte(testAdminToken + "\n"),
+			types.APIRoleReadOnly: []byte("other-token\n" + testReaderToken),
+		},
+	}
+	c, err := fake.NewCluster(testNamespace, stopCh, setting, secret)
+	require.NoError(t, err)
+	return &Server{
+		m: c.NewVolumeManager(testNode),
+		fwd: NewFwd(&fakeNodeLocator{
+			currentNodeID: testNode,
+			nodeIPs:       map[string]string{testNode: testManagerIP1, "node-2": testManagerIP2},
+		}),
+	}
+}
+
+// newTestForwardedRequest returns the request forwarded with the role by the
+// manager at the IP, verified by the certificate if it's given.
+func newTestForwardedRequest(method, role, peerIP string, verified bool) *http.Request {
+	req := httptest.NewRequest(method, "/v1/volumes", nil)
+	if verified {
+		req = newTestCertificateRequest(method, "longhorn-backend")
+	}
+	req.Header.Set(HeaderForwardedFrom, "node-2")
+	req.Header.Set(HeaderForwardedRole, role)
+	return req.WithContext(context.WithValue(req.Context(), peerIPContextKey{}, peerIP))
+}
+
+func newTestCertificateRequest(method, organization string) *http.Request {
+	req := httptest.NewRequest(method, "/v1/volumes", nil)
+	req.TLS = &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{
+			{
+				{Subject: pkix.Name{Organization: []string{organization}}},
+			},
+		},
+	}
+	return req
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	tests := map[string]struct {
+		disabled     bool
+		req          func() *http.Request
+		expectStatus int
+	}{
+		"disabled": {
+			disabled: true,
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/v1/volumes", nil)
+			},
+			expectStatus: http.StatusOK,
+		},
+		"metrics exempted": {
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			},
+			expectStatus: http.StatusOK,
+		},
+		"no token": {
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/v1/volumes", nil)
+			},
+			expectStatus: http.StatusUnauthorized,
+		},
+		"unknown token": {
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/volumes", nil)
+				req.Header.Set("Authorization", "Bearer unknown")
+				return req
+			},
+			expectStatus: http.StatusUnauthorized,
+		},
+		"read-only token to read": {
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/v1/volumes", nil)
+				req.Header.Set("Authorization", "Bearer "+testReaderToken)
+				return req
+			},
+			expectStatus: http.StatusOK,
+		},
+		"read-only token to change": {
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodDelete, "/v1/volumes/vol", nil)
+				req.Header.Set("Authorization", "Bearer "+testReaderToken)
+				return req
+			},
+			expectStatus: http.StatusForbidden,
+		},
+		"admin token as basic auth password to change": {
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/v1/volumes", nil)
+				req.SetBasicAuth("longhorn", testAdminToken)
+				return req
+			},
+			expectStatus: http.StatusOK,
+		},
+		"read-only certificate to read": {
+			req: func() *http.Request {
+				return newTestCertificateRequest(http.MethodGet, types.APIRoleOrganizationPrefix+types.APIRoleReadOnly)
+			},
+			expectStatus: http.StatusOK,
+		},
+		"read-only certificate to change": {
+			req: func() *http.Request {
+				return newTestCertificateRequest(http.MethodPut, types.APIRoleOrganizationPrefix+types.APIRoleReadOnly)
+			},
+			expectStatus: http.StatusForbidden,
+		},
+		"admin certificate to change": {
+			req: func() *http.Request {
+				return newTestCertificateRequest(http.MethodPost, types.APIRoleOrganizationPrefix+types.APIRoleAdmin)
+			},
+			expectStatus: http.StatusOK,
+		},
+		"admin role forwarded by verified manager": {
+			req: func() *http.Request {
+				return newTestForwardedRequest(http.MethodPost, types.APIRoleAdmin, testManagerIP2, true)
+			},
+			expectStatus: http.StatusOK,
+		},
+		"read-only role forwarded by verified manager to change": {
+			req: func() *http.Request {
+				return newTestForwardedRequest(http.MethodPost, types.APIRoleReadOnly, testManagerIP2, true)
+			},
+			expectStatus: http.StatusForbidden,
+		},
+		"forwarded role without manager certificate": {
+			req: func() *http.Request {
+				return newTestForwardedRequest(http.MethodGet, types.APIRoleAdmin, testManagerIP2, false)
+			},
+			expectStatus: http.StatusUnauthorized,
+		},
+		"forwarded role from client": {
+			req: func() *http.Request {
+				return newTestForwardedRequest(http.MethodGet, types.APIRoleAdmin, testClientIP, true)
+			},
+			expectStatus: http.StatusUnauthorized,
+		},
+		"certificate without role falls back to token": {
+			req: func() *http.Request {
+				req := newTestCertificateRequest(http.MethodPost, "example")
+				req.Header.Set("Authorization", "Bearer "+testAdminToken)
+				return req
+			},
+			expectStatus: http.StatusOK,
+		},
+	}
+
+	enabledServer := newTestAuthServer(t, stopCh, true)
+	disabledServer := newTestAuthServer(t, stopCh, false)
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := enabledServer
+			if tc.disabled {
+				s = disabledServer
+			}
+			rw := httptest.NewRecorder()
+			s.authMiddleware(NewSchema())(next).ServeHTTP(rw, tc.req())
+			require.Equal(t, tc.expectStatus, rw.Code)
+		})
+	}
+}
+
+func TestGetRequestToken(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/volumes", nil)
+	require.Equal(t, "", getRequestToken(req))
+
+	req.Header.Set("Authorization", "Bearer  token ")
+	require.Equal(t, "token", getRequestToken(req))
+
+	req.Header.Set("Authorization", "Token token")
+	require.Equal(t, "", getRequestToken(req))
+
+	req.SetBasicAuth("user", "password")
+	require.Equal(t, "password", getRequestToken(req))
+}
@@ -1,13 +1,21 @@
 package api
 
 import (
+	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/rancher/go-rancher/api"
 	"github.com/rancher/go-rancher/client"
 	"github.com/sirupsen/logrus"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
 )
 
 func (s *Server) BackupTargetList(w http.ResponseWriter, req *http.Request) error {
@@ -21,6 +29,28 @@ func (s *Server) BackupTargetList(w http.ResponseWriter, req *http.Request) erro
 	return nil
 }
 
+func (s *Server) BackupTargetTest(w http.ResponseWriter, req *http.Request) error {
+	var input BackupTargetTestInput
+	apiContext := api.GetApiContext(req)
+
+	if err := apiContext.Read(&input); err != nil {
+		return err
+	}
+
+	result := &BackupTargetTestResult{
+		Resource: client.Resource{
+			Type: "backupTargetTestResult",
+		},
+		Available: true,
+	}
+	if err := s.m.TestBackupTarget(input.BackupTargetURL, input.CredentialSecret); err != nil {
+		result.Available = false
+		result.Message = err.Error()
+	}
+	apiContext.Write(result)
+	return nil
+}
+
 func (s *Server) BackupVolumeList(w http.ResponseWriter, req *http.Request) error {
 	apiContext := api.GetApiContext(req)
 
@@ -108,6 +138,96 @@ func (s *Server) BackupGet(w http.ResponseWriter, req *http.Request) error {
 	return nil
 }
 
+// BackupVerify restores the backup to a temporary volume in the background.
+// The result is in the verification fields of the backup.
+func (s *Server) BackupVerify(w http.ResponseWriter, req *http.Request) error {
+	var input BackupInput
+
+	apiContext := api.GetApiContext(req)
+
+	if err := apiContext.Read(&input); err != nil {
+		return err
+	}
+	if input.Name == "" {
+		return errors.New("empty backup name is not allowed")
+	}
+	volName := mux.Vars(req)["volName"]
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.VerifyBackup(input.Name)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to verify backup '%v' of volume '%v'", input.Name, volName)
+	}
+	backup, ok := obj.(*longhorn.Backup)
+	if !ok {
+		return fmt.Errorf("failed to convert %v to backup", obj)
+	}
+	apiContext.Write(toBackupResource(backup))
+	return nil
+}
+
+func (s *Server) BackupBrowse(w http.ResponseWriter, req *http.Request) error {
+	var input BackupInput
+
+	apiContext := api.GetApiContext(req)
+
+	if err := apiContext.Read(&input); err != nil {
+		return err
+	}
+	if input.Name == "" {
+		return errors.New("empty backup name is not allowed")
+	}
+	volName := mux.Vars(req)["volName"]
+
+	v, err := s.m.CreateBackupBrowser(input.Name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to browse backup '%v' of volume '%v'", input.Name, volName)
+	}
+	return s.responseWithVolume(w, req, v.Name, v)
+}
+
+func (s *Server) VolumeBrowseFileList(w http.ResponseWriter, req *http.Request) (err error) {
+	defer func() {
+		err = errors.Wrap(err, "failed to list backup browser files")
+	}()
+
+	var input BrowseInput
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return err
+	}
+	volName := mux.Vars(req)["name"]
+
+	entries, err := s.m.ListBackupBrowserFiles(req.Context(), volName, input.Path)
+	if err != nil {
+		return err
+	}
+	apiContext.Write(toBrowseFileCollection(entries))
+	return nil
+}
+
+func (s *Server) VolumeBrowseFileDownload(w http.ResponseWriter, req *http.Request) (err error) {
+	defer func() {
+		err = errors.Wrap(err, "failed to download backup browser file")
+	}()
+
+	path := req.URL.Query().Get("path")
+	if path == "" {
+		return types.NewReasonError(types.ErrorReasonInvalidParameter, map[string]string{types.ErrorParameterParameter: "path"},
+			"file path is required")
+	}
+	volName := mux.Vars(req)["name"]
+
+	return s.m.CopyBackupBrowserFile(req.Context(), volName, path, func(info os.FileInfo, r io.Reader) error {
+		w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(info.Name()))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+		_, err := io.Copy(w, r)
+		return err
+	})
+}
+
 func (s *Server) BackupDelete(w http.ResponseWriter, req *http.Request) error {
 	var input BackupInput
 
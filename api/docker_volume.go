@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+// The Docker volume plugin protocol, see
+// https://docs.docker.com/engine/extend/plugins_volume/. The plugin is served
+// on the unix socket given by the docker-plugin-socket flag of the manager.
+// The Docker daemon of the node discovers it once the socket is in
+// /run/docker/plugins/ of the host, e.g. /run/docker/plugins/longhorn.sock.
+
+const (
+	dockerPluginContentType = "application/vnd.docker.plugins.v1.2+json"
+)
+
+type DockerVolumeRequest struct {
+	Name string            `json:"Name"`
+	ID   string            `json:"ID"`
+	Opts map[string]string `json:"Opts"`
+}
+
+type DockerVolumeResponse struct {
+	Mountpoint string                  `json:"Mountpoint,omitempty"`
+	Volume     *manager.DockerVolume   `json:"Volume,omitempty"`
+	Volumes    []*manager.DockerVolume `json:"Volumes,omitempty"`
+	Err        string                  `json:"Err"`
+}
+
+type dockerVolumeHandler func(ctx context.Context, req *DockerVolumeRequest) (*DockerVolumeResponse, error)
+
+func writeDockerPluginResponse(rw http.ResponseWriter, resp interface{}) {
+	rw.Header().Set("Content-Type", dockerPluginContentType)
+	if err := json.NewEncoder(rw).Encode(resp); err != nil {
+		logrus.WithError(err).Warn("Failed to write docker plugin response")
+	}
+}
+
+func (s *Server) DockerPluginActivate(rw http.ResponseWriter, req *http.Request) {
+	writeDockerPluginResponse(rw, map[string][]string{
+		"Implements": {"VolumeDriver"},
+	})
+}
+
+func (s *Server) DockerVolumeCapabilities(rw http.ResponseWriter, req *http.Request) {
+	writeDockerPluginResponse(rw, map[string]interface{}{
+		"Capabilities": map[string]string{
+			"Scope": "global",
+		},
+	})
+}
+
+// dockerVolumeHandlerFunc decodes the request of the Docker daemon for the
+// handler. Each request gets a request ID, like the API requests, so the
+// volume operations it starts can be traced.
+func (s *Server) dockerVolumeHandlerFunc(handler dockerVolumeHandler) http.Handler {
+	return withRequestID(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		input := &DockerVolumeRequest{}
+		// Some requests, e.g. List, have no body
+		if err := json.NewDecoder(req.Body).Decode(input); err != nil && err != io.EOF {
+			writeDockerPluginResponse(rw, &DockerVolumeResponse{Err: err.Error()})
+			return
+		}
+		resp, err := handler(req.Context(), input)
+		if err != nil {
+			util.WithRequestIDField(req.Context(), logrus.StandardLogger()).WithError(err).Warnf("Failed to handle docker volume request %v", req.URL.Path)
+			resp = &DockerVolumeResponse{Err: err.Error()}
+		}
+		writeDockerPluginResponse(rw, resp)
+	}))
+}
+
+func (s *Server) DockerVolumeCreate(ctx context.Context, req *DockerVolumeRequest) (*DockerVolumeResponse, error) {
+	if err := s.dvd.Create(ctx, req.Name, req.Opts); err != nil {
+		return nil, err
+	}
+	return &DockerVolumeResponse{}, nil
+}
+
+func (s *Server) DockerVolumeRemove(ctx context.Context, req *DockerVolumeRequest) (*DockerVolumeResponse, error) {
+	if err := s.dvd.Remove(ctx, req.Name); err != nil {
+		return nil, err
+	}
+	return &DockerVolumeResponse{}, nil
+}
+
+func (s *Server) DockerVolumeMount(ctx context.Context, req *DockerVolumeRequest) (*DockerVolumeResponse, error) {
+	mountpoint, err := s.dvd.Mount(ctx, req.Name, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &DockerVolumeResponse{Mountpoint: mountpoint}, nil
+}
+
+func (s *Server) DockerVolumeUnmount(ctx context.Context, req *DockerVolumeRequest) (*DockerVolumeResponse, error) {
+	if err := s.dvd.Unmount(ctx, req.Name, req.ID); err != nil {
+		return nil, err
+	}
+	return &DockerVolumeResponse{}, nil
+}
+
+func (s *Server) DockerVolumePath(ctx context.Context, req *DockerVolumeRequest) (*DockerVolumeResponse, error) {
+	mountpoint, err := s.dvd.Path(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &DockerVolumeResponse{Mountpoint: mountpoint}, nil
+}
+
+func (s *Server) DockerVolumeGet(ctx context.Context, req *DockerVolumeRequest) (*DockerVolumeResponse, error) {
+	volume, err := s.dvd.Get(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &DockerVolumeResponse{Volume: volume}, nil
+}
+
+func (s *Server) DockerVolumeList(ctx context.Context, req *DockerVolumeRequest) (*DockerVolumeResponse, error) {
+	volumes, err := s.dvd.List()
+	if err != nil {
+		return nil, err
+	}
+	return &DockerVolumeResponse{Volumes: volumes}, nil
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+func TestDockerPluginRouter(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// The Docker daemon doesn't send credentials even if the API requires them
+	s := newTestAuthServer(t, stopCh, true)
+	s.dvd = manager.NewDockerVolumeDriver(s.m)
+	r := NewDockerPluginRouter(s)
+
+	rw := httptest.NewRecorder()
+	r.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/Plugin.Activate", nil))
+	assert.Equal(http.StatusOK, rw.Code)
+	assert.JSONEq(`{"Implements": ["VolumeDriver"]}`, rw.Body.String())
+
+	rw = httptest.NewRecorder()
+	r.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/VolumeDriver.List", nil))
+	assert.Equal(http.StatusOK, rw.Code)
+	resp := &DockerVolumeResponse{}
+	assert.NoError(json.NewDecoder(rw.Body).Decode(resp))
+	assert.Empty(resp.Err)
+	assert.Empty(resp.Volumes)
+	// The Docker daemon doesn't send a request ID, so one is generated
+	assert.NotEmpty(rw.Header().Get(util.RequestIDHeader))
+
+	// Only the plugin protocol is served
+	rw = httptest.NewRecorder()
+	r.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/v1/volumes", nil))
+	assert.Equal(http.StatusNotFound, rw.Code)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+	"github.com/rancher/go-rancher/api"
+	"github.com/rancher/go-rancher/client"
+
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+// Drain makes the server reject the new requests making changes, so that
+// they can be retried on the other managers during the shutdown. The reads
+// are still served until the server is shut down.
+func (s *Server) Drain() {
+	atomic.StoreInt32(&s.draining, 1)
+}
+
+func (s *Server) isDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+func (s *Server) drainMiddleware(schemas *client.Schemas) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if !s.isDraining() || isReadOnlyMethod(req.Method) {
+				next.ServeHTTP(rw, req)
+				return
+			}
+			api.ApiHandler(schemas, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				writeErr(api.GetApiContext(req), rw, types.NewReasonError(types.ErrorReasonUnavailable, nil,
+					"longhorn manager %v is shutting down", s.m.GetCurrentNodeID()))
+			})).ServeHTTP(rw, req)
+		})
+	}
+}
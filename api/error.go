@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/go-rancher/api"
+	"github.com/rancher/go-rancher/client"
+	"github.com/sirupsen/logrus"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+type Error struct {
+	client.Resource
+	Status     int               `json:"status"`
+	Code       string            `json:"code"`
+	Message    string            `json:"message"`
+	Detail     string            `json:"detail"`
+	Reason     string            `json:"reason"`
+	Parameters map[string]string `json:"parameters"`
+}
+
+var errorReasonToStatus = map[types.ErrorReason]int{
+	types.ErrorReasonNotFound:            http.StatusNotFound,
+	types.ErrorReasonAlreadyExists:       http.StatusConflict,
+	types.ErrorReasonConflict:            http.StatusConflict,
+	types.ErrorReasonInvalidParameter:    http.StatusBadRequest,
+	types.ErrorReasonInvalidState:        http.StatusBadRequest,
+	types.ErrorReasonInsufficientStorage: http.StatusInsufficientStorage,
+	types.ErrorReasonUnauthorized:        http.StatusUnauthorized,
+	types.ErrorReasonForbidden:           http.StatusForbidden,
+	types.ErrorReasonForwardFailed:       http.StatusBadGateway,
+	types.ErrorReasonUnavailable:         http.StatusServiceUnavailable,
+	types.ErrorReasonTimeout:             http.StatusGatewayTimeout,
+	types.ErrorReasonQuotaExceeded:       http.StatusForbidden,
+	types.ErrorReasonEngineUnavailable:   http.StatusServiceUnavailable,
+}
+
+// getReasonError returns the machine-readable reason of err. Kubernetes API
+// errors are translated so that a missing or conflicting object carries the
+// same reason regardless of which layer raised it.
+func getReasonError(err error) *types.ReasonError {
+	if reasonErr := types.GetReasonError(err); reasonErr != nil {
+		return reasonErr
+	}
+
+	var statusErr *apierrors.StatusError
+	if !errors.As(err, &statusErr) {
+		return nil
+	}
+	parameters := map[string]string{}
+	if details := statusErr.ErrStatus.Details; details != nil {
+		parameters[types.ErrorParameterName] = details.Name
+		parameters[types.ErrorParameterKind] = details.Kind
+	}
+	switch {
+	case apierrors.IsNotFound(statusErr):
+		return types.NewReasonError(types.ErrorReasonNotFound, parameters, "%v", statusErr)
+	case apierrors.IsAlreadyExists(statusErr):
+		return types.NewReasonError(types.ErrorReasonAlreadyExists, parameters, "%v", statusErr)
+	case apierrors.IsConflict(statusErr):
+		return types.NewReasonError(types.ErrorReasonConflict, parameters, "%v", statusErr)
+	case apierrors.IsInvalid(statusErr), apierrors.IsBadRequest(statusErr):
+		return types.NewReasonError(types.ErrorReasonInvalidParameter, parameters, "%v", statusErr)
+	case apierrors.IsTimeout(statusErr), apierrors.IsServerTimeout(statusErr):
+		return types.NewReasonError(types.ErrorReasonTimeout, parameters, "%v", statusErr)
+	case apierrors.IsServiceUnavailable(statusErr), apierrors.IsTooManyRequests(statusErr), apierrors.IsInternalError(statusErr):
+		// The Kubernetes API server backing the datastore is down or overloaded
+		return types.NewReasonError(types.ErrorReasonUnavailable, parameters, "%v", statusErr)
+	}
+	return nil
+}
+
+func writeErr(apiContext *api.ApiContext, rw http.ResponseWriter, err error) {
+	reasonErr := getReasonError(err)
+	if reasonErr == nil {
+		apiContext.WriteErr(err)
+		return
+	}
+
+	status, ok := errorReasonToStatus[reasonErr.Reason]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	rw.WriteHeader(status)
+	if writeErr := apiContext.WriteResource(&Error{
+		Resource: client.Resource{
+			Type: "error",
+		},
+		Status:     status,
+		Code:       http.StatusText(status),
+		Message:    err.Error(),
+		Reason:     string(reasonErr.Reason),
+		Parameters: reasonErr.Parameters,
+	}); writeErr != nil {
+		logrus.WithError(writeErr).Errorf("Failed to write err: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/go-rancher/api"
+	"github.com/stretchr/testify/require"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+func TestGetReasonError(t *testing.T) {
+	volumes := schema.GroupResource{Group: "longhorn.io", Resource: "volumes"}
+	tests := map[string]struct {
+		err          error
+		expectReason types.ErrorReason
+		expectName   string
+	}{
+		"reason error": {
+			err:          types.NewReasonError(types.ErrorReasonInvalidState, nil, "invalid"),
+			expectReason: types.ErrorReasonInvalidState,
+		},
+		"wrapped reason error": {
+			err:          errors.Wrap(types.NewReasonError(types.ErrorReasonQuotaExceeded, nil, "exceeded"), "unable to create volume"),
+			expectReason: types.ErrorReasonQuotaExceeded,
+		},
+		"not found": {
+			err:          errors.Wrap(apierrors.NewNotFound(volumes, "vol"), "unable to get volume"),
+			expectReason: types.ErrorReasonNotFound,
+			expectName:   "vol",
+		},
+		"already exists": {
+			err:          apierrors.NewAlreadyExists(volumes, "vol"),
+			expectReason: types.ErrorReasonAlreadyExists,
+			expectName:   "vol",
+		},
+		"conflict": {
+			err:          apierrors.NewConflict(volumes, "vol", fmt.Errorf("modified")),
+			expectReason: types.ErrorReasonConflict,
+			expectName:   "vol",
+		},
+		"invalid": {
+			err:          apierrors.NewBadRequest("bad request"),
+			expectReason: types.ErrorReasonInvalidParameter,
+		},
+		"timeout": {
+			err:          apierrors.NewTimeoutError("timed out", 1),
+			expectReason: types.ErrorReasonTimeout,
+		},
+		"unavailable": {
+			err:          apierrors.NewServiceUnavailable("down"),
+			expectReason: types.ErrorReasonUnavailable,
+		},
+		"forbidden by kubernetes": {
+			err: apierrors.NewForbidden(volumes, "vol", fmt.Errorf("denied")),
+		},
+		"plain error": {
+			err: fmt.Errorf("failed"),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := require.New(t)
+
+			reasonErr := getReasonError(tc.err)
+			if tc.expectReason == "" {
+				assert.Nil(reasonErr)
+				return
+			}
+			assert.NotNil(reasonErr)
+			assert.Equal(tc.expectReason, reasonErr.Reason)
+			assert.Equal(tc.expectName, reasonErr.Parameters[types.ErrorParameterName])
+		})
+	}
+}
+
+func TestWriteErr(t *testing.T) {
+	write := func(err error) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/volumes/vol", nil)
+		require.NoError(t, api.CreateApiContext(rw, req, NewSchema()))
+		writeErr(api.GetApiContext(req), rw, err)
+		return rw
+	}
+
+	rw := write(errors.Wrap(types.NewReasonError(types.ErrorReasonInsufficientStorage,
+		map[string]string{types.ErrorParameterNeeded: "100%"}, "need %v bytes", 100), "unable to expand volume"))
+	require.Equal(t, http.StatusInsufficientStorage, rw.Code)
+	output := &Error{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), output))
+	require.Equal(t, http.StatusInsufficientStorage, output.Status)
+	require.Equal(t, string(types.ErrorReasonInsufficientStorage), output.Reason)
+	require.Equal(t, "100%", output.Parameters[types.ErrorParameterNeeded])
+	require.Equal(t, "unable to expand volume: need 100 bytes", output.Message)
+
+	// The messages of the Kubernetes errors are kept as they are
+	rw = write(apierrors.NewBadRequest("invalid size 10%"))
+	require.Equal(t, http.StatusBadRequest, rw.Code)
+	output = &Error{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), output))
+	require.Equal(t, string(types.ErrorReasonInvalidParameter), output.Reason)
+	require.Equal(t, "invalid size 10%", output.Message)
+
+	// Errors without a reason are still internal errors
+	rw = write(fmt.Errorf("failed"))
+	require.Equal(t, http.StatusInternalServerError, rw.Code)
+}
@@ -1,13 +1,17 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
+	"github.com/rancher/go-rancher/api"
 	"github.com/sirupsen/logrus"
 
 	v1 "k8s.io/api/core/v1"
@@ -21,6 +25,14 @@ import (
 const (
 	ParameterKeyAddress  = "address"
 	ParameterKeyFilePath = "filePath"
+	ParameterKeyNodeID   = "nodeID"
+
+	// HeaderForwardedFrom is set to the node of the manager forwarding the
+	// request
+	HeaderForwardedFrom = "X-Longhorn-Forwarded-From"
+	// HeaderForwardedRole is set to the role the forwarded request was
+	// authenticated with
+	HeaderForwardedRole = "X-Longhorn-Forwarded-Role"
 )
 
 type OwnerIDFunc func(req *http.Request) (string, error)
@@ -41,6 +53,51 @@ func OwnerIDFromVolume(m *manager.VolumeManager) func(req *http.Request) (string
 	}
 }
 
+// OperationNodeIDFromVolume returns the owner of the volume, which queues the
+// attach, detach and delete requests of the volume in order. A volume not
+// owned yet, e.g. just created, is handled by the current manager.
+func OperationNodeIDFromVolume(m *manager.VolumeManager) func(req *http.Request) (string, error) {
+	return func(req *http.Request) (string, error) {
+		ownerID, err := OwnerIDFromVolume(m)(req)
+		if err != nil {
+			return "", err
+		}
+		if ownerID == "" {
+			return m.GetCurrentNodeID(), nil
+		}
+		return ownerID, nil
+	}
+}
+
+// AttachedNodeIDFromVolume returns the node the volume is attached to, for the
+// requests that can only be handled there, e.g. accessing the filesystem.
+func AttachedNodeIDFromVolume(m *manager.VolumeManager) func(req *http.Request) (string, error) {
+	return func(req *http.Request) (string, error) {
+		name := mux.Vars(req)["name"]
+		volume, err := m.Get(name)
+		if err != nil {
+			return "", errors.Wrapf(err, "error getting volume '%s'", name)
+		}
+		if volume == nil {
+			return "", nil
+		}
+		return volume.Status.CurrentNodeID, nil
+	}
+}
+
+// BrowsingNodeIDFromVolume returns the node the backup browser is attached
+// to. The browser not attached yet is handled by the current node, which
+// attaches it there.
+func BrowsingNodeIDFromVolume(m *manager.VolumeManager) func(req *http.Request) (string, error) {
+	return func(req *http.Request) (string, error) {
+		nodeID, err := AttachedNodeIDFromVolume(m)(req)
+		if err != nil || nodeID != "" {
+			return nodeID, err
+		}
+		return m.GetCurrentNodeID(), nil
+	}
+}
+
 // NodeHasDefaultEngineImage picks a node that is ready and has default engine image deployed.
 // To prevent the repeatedly forwarding the request around, prioritize the current node if it meets the requirement.
 func NodeHasDefaultEngineImage(m *manager.VolumeManager) func(req *http.Request) (string, error) {
@@ -74,20 +131,72 @@ func OwnerIDFromNode(m *manager.VolumeManager) func(req *http.Request) (string,
 type NodeLocator interface {
 	GetCurrentNodeID() string
 	Node2APIAddress(nodeID string) (string, error)
+	IsManagerIP(ip string) (bool, error)
+}
+
+type peerIPContextKey struct{}
+
+// PeerIPHandler records the IP of the connection in the request context, so
+// the forwarding managers are recognized regardless of the headers rewriting
+// the remote address, e.g. X-Forwarded-For. It must be the outermost handler.
+func PeerIPHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if ip, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			req = req.WithContext(context.WithValue(req.Context(), peerIPContextKey{}, ip))
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// isForwardedByManager returns if the request comes from another manager, so
+// its HeaderForwardedFrom can be trusted. Anyone else could set the header to
+// keep the request from being forwarded to the responsible manager.
+func (f *Fwd) isForwardedByManager(req *http.Request) bool {
+	ip, ok := req.Context().Value(peerIPContextKey{}).(string)
+	if !ok {
+		return false
+	}
+	isManager, err := f.locator.IsManagerIP(ip)
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to check if request from %v is forwarded by a manager", ip)
+		return false
+	}
+	return isManager
 }
 
 type Fwd struct {
 	locator NodeLocator
-	proxy   http.Handler
+	proxy   *httputil.ReverseProxy
+
+	// The scheme and the transport to reach the managers on the other nodes
+	scheme    string
+	transport http.RoundTripper
 }
 
 func NewFwd(locator NodeLocator) *Fwd {
 	return &Fwd{
 		locator: locator,
-		proxy:   &httputil.ReverseProxy{Director: func(r *http.Request) {}},
+		proxy: &httputil.ReverseProxy{
+			Director:     func(r *http.Request) {},
+			ErrorHandler: handleProxyError,
+		},
+
+		scheme:    "http",
+		transport: http.DefaultTransport,
 	}
 }
 
+// EnableTLS makes the requests to the other managers use TLS with the config,
+// which should verify the peer certificates. The requests to the other
+// components, e.g. backing image uploads, are unchanged.
+func (f *Fwd) EnableTLS(config *tls.Config) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	f.scheme = "https"
+	f.transport = transport
+	f.proxy.Transport = transport
+}
+
 func (f *Fwd) Handler(proxyHandler ProxyRequestHandler, parametersGetFunc ParametersGetFunc, h HandleFuncWithError) HandleFuncWithError {
 	return func(w http.ResponseWriter, req *http.Request) error {
 		var requireProxy bool
@@ -115,23 +224,57 @@ func (f *Fwd) Handler(proxyHandler ProxyRequestHandler, parametersGetFunc Parame
 	}
 }
 
+// handleProxyError replies the failure to reach the target as an API error,
+// instead of an empty response. The URL of the request is already rewritten
+// to the target.
+func handleProxyError(rw http.ResponseWriter, req *http.Request, err error) {
+	target := req.URL.Host
+	logrus.WithError(err).Warnf("Failed to forward request %v %v to %v", req.Method, req.URL.Path, target)
+	writeErr(api.GetApiContext(req), rw, types.NewReasonError(types.ErrorReasonForwardFailed,
+		map[string]string{ParameterKeyAddress: target},
+		"failed to forward request to %v: %v", target, err))
+}
+
+// HandleProxyRequestByNodeID forwards the request to the manager on the node
+// unless it's the current node. A request is forwarded at most once, so it
+// can't bounce between managers while the owner is changing.
 func (f *Fwd) HandleProxyRequestByNodeID(parameters map[string]string, req *http.Request) (proxyRequired bool, err error) {
 	targetAddress := parameters[ParameterKeyAddress]
-	if targetAddress == req.Host {
+	if targetAddress == req.Host || parameters[ParameterKeyNodeID] == f.locator.GetCurrentNodeID() {
 		return false, nil
 	}
+	if from := req.Header.Get(HeaderForwardedFrom); from != "" {
+		if f.isForwardedByManager(req) {
+			logrus.Debugf("Handling request forwarded from %v locally, though it should be handled by %v", from, targetAddress)
+			return false, nil
+		}
+		logrus.Warnf("Ignoring header %v of request %v %v not forwarded by a manager", HeaderForwardedFrom, req.Method, req.URL.Path)
+	}
 
-	req.Host = targetAddress
-	req.URL.Host = targetAddress
-	req.URL.Scheme = "http"
+	// Keep the host and the scheme of the client, so the links in the
+	// response work for the client regardless of which manager handles it
 	h := req.Header
-	if h.Get("X-Forwarded-Proto") == "https" {
-		h.Set("X-Forwarded-Proto", "http")
+	if h.Get("X-Forwarded-Host") == "" {
+		h.Set("X-Forwarded-Host", req.Host)
 	}
-	if h.Get("X-Forwarded-Host") != "" {
-		h.Del("X-Forwarded-Host")
+	if h.Get("X-Forwarded-Proto") == "" {
+		scheme := "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+		h.Set("X-Forwarded-Proto", scheme)
+	}
+	h.Set(HeaderForwardedFrom, f.locator.GetCurrentNodeID())
+	if role := getRequestRole(req); role != "" {
+		h.Set(HeaderForwardedRole, role)
+	} else {
+		h.Del(HeaderForwardedRole)
 	}
 	req.Header = h
+
+	req.Host = targetAddress
+	req.URL.Host = targetAddress
+	req.URL.Scheme = f.scheme
 	logrus.Debugf("Forwarding request to %v", targetAddress)
 
 	return true, nil
@@ -143,11 +286,18 @@ func (f *Fwd) GetHTTPAddressByNodeID(getNodeID OwnerIDFunc) ParametersGetFunc {
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to get target node ID")
 		}
+		if nodeID == "" {
+			return nil, types.NewReasonError(types.ErrorReasonInvalidState, nil,
+				"no node is responsible for the request yet")
+		}
 		address, err := f.locator.Node2APIAddress(nodeID)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to get the address from node ID")
 		}
-		return map[string]string{ParameterKeyAddress: address}, nil
+		return map[string]string{
+			ParameterKeyAddress: address,
+			ParameterKeyNodeID:  nodeID,
+		}, nil
 	}
 }
 
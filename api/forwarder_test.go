@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+const (
+	testManagerIP1 = "10.42.0.1"
+	testManagerIP2 = "10.42.0.2"
+	testClientIP   = "10.42.9.9"
+)
+
+type fakeNodeLocator struct {
+	currentNodeID string
+	nodeIPs       map[string]string
+}
+
+func (l *fakeNodeLocator) GetCurrentNodeID() string {
+	return l.currentNodeID
+}
+
+func (l *fakeNodeLocator) Node2APIAddress(nodeID string) (string, error) {
+	ip, ok := l.nodeIPs[nodeID]
+	if !ok {
+		return "", fmt.Errorf("cannot find longhorn manager on node %v", nodeID)
+	}
+	return ip + ":9500", nil
+}
+
+func (l *fakeNodeLocator) IsManagerIP(ip string) (bool, error) {
+	for _, managerIP := range l.nodeIPs {
+		if managerIP == ip {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestHandleProxyRequestByNodeID(t *testing.T) {
+	locator := &fakeNodeLocator{
+		currentNodeID: "node-1",
+		nodeIPs: map[string]string{
+			"node-1": testManagerIP1,
+			"node-2": testManagerIP2,
+		},
+	}
+	f := NewFwd(locator)
+
+	tests := map[string]struct {
+		nodeID        string
+		remoteIP      string
+		forwardedFrom string
+		forwardedFor  string
+		expectProxy   bool
+	}{
+		"current node": {
+			nodeID:      "node-1",
+			remoteIP:    testClientIP,
+			expectProxy: false,
+		},
+		"other node": {
+			nodeID:      "node-2",
+			remoteIP:    testClientIP,
+			expectProxy: true,
+		},
+		"forwarded by manager": {
+			nodeID:        "node-2",
+			remoteIP:      testManagerIP2,
+			forwardedFrom: "node-2",
+			expectProxy:   false,
+		},
+		"forwarded header from client": {
+			nodeID:        "node-2",
+			remoteIP:      testClientIP,
+			forwardedFrom: "node-2",
+			expectProxy:   true,
+		},
+		"forwarded header from client pretending to be manager": {
+			nodeID:        "node-2",
+			remoteIP:      testClientIP,
+			forwardedFrom: "node-2",
+			forwardedFor:  testManagerIP2,
+			expectProxy:   true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := require.New(t)
+
+			var proxyRequired bool
+			var handled *http.Request
+			handler := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				parameters, err := f.GetHTTPAddressByNodeID(func(req *http.Request) (string, error) {
+					return tc.nodeID, nil
+				})(req)
+				assert.NoError(err)
+				proxyRequired, err = f.HandleProxyRequestByNodeID(parameters, req)
+				assert.NoError(err)
+				handled = req
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/volumes/vol?action=attach", nil)
+			req.Host = "longhorn-backend:9500"
+			req.RemoteAddr = tc.remoteIP + ":40000"
+			if tc.forwardedFrom != "" {
+				req.Header.Set(HeaderForwardedFrom, tc.forwardedFrom)
+			}
+			if tc.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+			PeerIPHandler(handlers.ProxyHeaders(handler)).ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(tc.expectProxy, proxyRequired)
+			if !tc.expectProxy {
+				return
+			}
+			assert.Equal(testManagerIP2+":9500", handled.URL.Host)
+			assert.Equal("http", handled.URL.Scheme)
+			assert.Equal("node-1", handled.Header.Get(HeaderForwardedFrom))
+			assert.Equal("longhorn-backend:9500", handled.Header.Get("X-Forwarded-Host"))
+		})
+	}
+}
+
+func TestHandleProxyRequestByNodeIDRole(t *testing.T) {
+	assert := require.New(t)
+
+	f := NewFwd(&fakeNodeLocator{
+		currentNodeID: "node-1",
+		nodeIPs:       map[string]string{"node-2": testManagerIP2},
+	})
+	parameters := map[string]string{
+		ParameterKeyAddress: testManagerIP2 + ":9500",
+		ParameterKeyNodeID:  "node-2",
+	}
+
+	// The role authenticated by this manager is forwarded
+	req := httptest.NewRequest(http.MethodPost, "/v1/volumes/vol?action=attach", nil)
+	req = req.WithContext(context.WithValue(req.Context(), apiRoleContextKey{}, types.APIRoleReadOnly))
+	proxyRequired, err := f.HandleProxyRequestByNodeID(parameters, req)
+	assert.NoError(err)
+	assert.True(proxyRequired)
+	assert.Equal(types.APIRoleReadOnly, req.Header.Get(HeaderForwardedRole))
+
+	// The role set by the client is dropped
+	req = httptest.NewRequest(http.MethodPost, "/v1/volumes/vol?action=attach", nil)
+	req.Header.Set(HeaderForwardedRole, types.APIRoleAdmin)
+	proxyRequired, err = f.HandleProxyRequestByNodeID(parameters, req)
+	assert.NoError(err)
+	assert.True(proxyRequired)
+	assert.Empty(req.Header.Get(HeaderForwardedRole))
+}
+
+func TestHandleProxyRequestByNodeIDWithoutPeerIP(t *testing.T) {
+	f := NewFwd(&fakeNodeLocator{
+		currentNodeID: "node-1",
+		nodeIPs:       map[string]string{"node-2": testManagerIP2},
+	})
+
+	// The header isn't trusted without the connection IP
+	req := httptest.NewRequest(http.MethodPost, "/v1/volumes/vol?action=attach", nil)
+	req.RemoteAddr = testManagerIP2 + ":40000"
+	req.Header.Set(HeaderForwardedFrom, "node-2")
+	proxyRequired, err := f.HandleProxyRequestByNodeID(map[string]string{
+		ParameterKeyAddress: testManagerIP2 + ":9500",
+		ParameterKeyNodeID:  "node-2",
+	}, req)
+	require.NoError(t, err)
+	require.True(t, proxyRequired)
+}
+
+func TestOperationNodeIDFromVolume(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	owned := &longhorn.Volume{ObjectMeta: metav1.ObjectMeta{Name: "owned", Namespace: testNamespace}}
+	owned.Status.OwnerID = "node-2"
+	unowned := &longhorn.Volume{ObjectMeta: metav1.ObjectMeta{Name: "unowned", Namespace: testNamespace}}
+	c, err := fake.NewCluster(testNamespace, stopCh, owned, unowned)
+	assert.NoError(err)
+	getNodeID := OperationNodeIDFromVolume(c.NewVolumeManager(testNode))
+
+	tests := map[string]struct {
+		volume         string
+		expectedNodeID string
+		expectErr      bool
+	}{
+		"owned by another manager": {"owned", "node-2", false},
+		"not owned yet":            {"unowned", testNode, false},
+		"not found":                {"missing", "", true},
+	}
+	for name, test := range tests {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/v1/volumes/"+test.volume, nil), map[string]string{"name": test.volume})
+		nodeID, err := getNodeID(req)
+		if test.expectErr {
+			assert.Error(err, name)
+			continue
+		}
+		assert.NoError(err, name)
+		assert.Equal(test.expectedNodeID, nodeID, name)
+	}
+}
@@ -76,3 +76,37 @@ func (s *Server) InstanceManagerList(rw http.ResponseWriter, req *http.Request)
 	apiContext.Write(toInstanceManagerCollection(instanceManagers))
 	return nil
 }
+
+func (s *Server) UpgradeReportGet(rw http.ResponseWriter, req *http.Request) error {
+	apiContext := api.GetApiContext(req)
+
+	report, err := s.m.GetUpgradeReport(req.URL.Query().Get("engineImage"))
+	if err != nil {
+		return errors.Wrap(err, "failed to get upgrade report")
+	}
+	apiContext.Write(&UpgradeReport{
+		Resource: client.Resource{
+			Id:   report.EngineImage,
+			Type: "upgradeReport",
+		},
+		UpgradeReport: *report,
+	})
+	return nil
+}
+
+func (s *Server) ClusterCapacityGet(rw http.ResponseWriter, req *http.Request) error {
+	apiContext := api.GetApiContext(req)
+
+	capacity, err := s.m.ClusterCapacity()
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster capacity")
+	}
+	apiContext.Write(&ClusterCapacity{
+		Resource: client.Resource{
+			Id:   "clustercapacity",
+			Type: "clusterCapacity",
+		},
+		ClusterCapacity: *capacity,
+	})
+	return nil
+}
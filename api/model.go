@@ -1,8 +1,12 @@
 package api
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rancher/go-rancher/api"
 	"github.com/rancher/go-rancher/client"
@@ -10,10 +14,13 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 
+	etypes "github.com/longhorn/longhorn-engine/pkg/types"
+
 	"github.com/longhorn/longhorn-manager/controller"
 	"github.com/longhorn/longhorn-manager/datastore"
 	"github.com/longhorn/longhorn-manager/engineapi"
 	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/scheduler"
 	"github.com/longhorn/longhorn-manager/types"
 	"github.com/longhorn/longhorn-manager/util"
 
@@ -40,6 +47,10 @@ type Volume struct {
 	Created                   string                                 `json:"created"`
 	LastBackup                string                                 `json:"lastBackup"`
 	LastBackupAt              string                                 `json:"lastBackupAt"`
+	LastIntegrityCheckedAt    string                                 `json:"lastIntegrityCheckedAt"`
+	LastIntegrityVerifiedAt   string                                 `json:"lastIntegrityVerifiedAt"`
+	IntegrityCheckError       string                                 `json:"integrityCheckError"`
+	FrozenUntil               string                                 `json:"frozenUntil"`
 	LastAttachedBy            string                                 `json:"lastAttachedBy"`
 	Standby                   bool                                   `json:"standby"`
 	RestoreRequired           bool                                   `json:"restoreRequired"`
@@ -47,10 +58,23 @@ type Volume struct {
 	SnapshotDataIntegrity     longhorn.SnapshotDataIntegrity         `json:"snapshotDataIntegrity"`
 	UnmapMarkSnapChainRemoved longhorn.UnmapMarkSnapChainRemoved     `json:"unmapMarkSnapChainRemoved"`
 	BackupCompressionMethod   longhorn.BackupCompressionMethod       `json:"backupCompressionMethod"`
+	ExpireAt                  string                                 `json:"expireAt"`
+	RebuildBandwidthLimit     int64                                  `json:"rebuildBandwidthLimit"`
+	FrontendIOPSLimit         int64                                  `json:"frontendIOPSLimit"`
+	FrontendBandwidthLimit    int64                                  `json:"frontendBandwidthLimit"`
+	SnapshotMaxCount          int                                    `json:"snapshotMaxCount"`
+	SnapshotMaxAge            string                                 `json:"snapshotMaxAge"`
+	FilesystemType            string                                 `json:"filesystemType"`
+	Filesystem                string                                 `json:"filesystem"`
+	LastFilesystemCheckAt     string                                 `json:"lastFilesystemCheckAt"`
+	ReadOnly                  bool                                   `json:"readOnly"`
 
 	DiskSelector         []string                      `json:"diskSelector"`
 	NodeSelector         []string                      `json:"nodeSelector"`
+	MountOptions         []string                      `json:"mountOptions"`
 	RecurringJobSelector []longhorn.VolumeRecurringJob `json:"recurringJobSelector"`
+	Labels               map[string]string             `json:"labels"`
+	Tenant               string                        `json:"tenant"`
 
 	NumberOfReplicas   int                         `json:"numberOfReplicas"`
 	ReplicaAutoBalance longhorn.ReplicaAutoBalance `json:"replicaAutoBalance"`
@@ -64,7 +88,10 @@ type Volume struct {
 	ShareEndpoint string                     `json:"shareEndpoint"`
 	ShareState    longhorn.ShareManagerState `json:"shareState"`
 
-	Migratable bool `json:"migratable"`
+	Migratable         bool                          `json:"migratable"`
+	MigrationNodeID    string                        `json:"migrationNodeID"`
+	MigrationState     longhorn.VolumeMigrationState `json:"migrationState"`
+	MigrationStartedAt string                        `json:"migrationStartedAt"`
 
 	Encrypted bool `json:"encrypted"`
 
@@ -80,13 +107,30 @@ type Snapshot struct {
 	client.Resource
 	longhorn.SnapshotInfo
 	Checksum string `json:"checksum"`
+	System   bool   `json:"system"`
 }
 
+const (
+	SnapshotCreatedByUser   = "user"
+	SnapshotCreatedBySystem = "system"
+)
+
 type BackupTarget struct {
 	client.Resource
 	engineapi.BackupTarget
 }
 
+type BackupTargetTestInput struct {
+	BackupTargetURL  string `json:"backupTargetURL"`
+	CredentialSecret string `json:"credentialSecret"`
+}
+
+type BackupTargetTestResult struct {
+	client.Resource
+	Available bool   `json:"available"`
+	Message   string `json:"message"`
+}
+
 type BackupVolume struct {
 	client.Resource
 
@@ -121,6 +165,9 @@ type Backup struct {
 	VolumeCreated          string               `json:"volumeCreated"`
 	VolumeBackingImageName string               `json:"volumeBackingImageName"`
 	CompressionMethod      string               `json:"compressionMethod"`
+	VerificationState      string               `json:"verificationState"`
+	VerificationError      string               `json:"verificationError"`
+	LastVerifiedAt         string               `json:"lastVerifiedAt"`
 }
 
 type Setting struct {
@@ -138,6 +185,14 @@ type Instance struct {
 	EngineImage         string `json:"engineImage"`
 	CurrentImage        string `json:"currentImage"`
 	InstanceManagerName string `json:"instanceManagerName"`
+
+	// The process level status. The uptime is in seconds since the process
+	// is seen running.
+	StartedAt      string `json:"startedAt"`
+	Uptime         int64  `json:"uptime"`
+	RestartCount   int    `json:"restartCount"`
+	LastRestartAt  string `json:"lastRestartAt"`
+	LastExitReason string `json:"lastExitReason"`
 }
 
 type Controller struct {
@@ -151,6 +206,10 @@ type Controller struct {
 	LastExpansionError               string `json:"lastExpansionError"`
 	LastExpansionFailedAt            string `json:"lastExpansionFailedAt"`
 	UnmapMarkSnapChainRemovedEnabled bool   `json:"unmapMarkSnapChainRemovedEnabled"`
+	ISCSITargetIQN                   string `json:"iscsiTargetIQN"`
+	ISCSITargetIP                    string `json:"iscsiTargetIP"`
+	ISCSITargetPort                  string `json:"iscsiTargetPort"`
+	FrontendReadOnly                 bool   `json:"frontendReadOnly"`
 }
 
 type Replica struct {
@@ -161,6 +220,10 @@ type Replica struct {
 	DataPath string `json:"dataPath"`
 	Mode     string `json:"mode"`
 	FailedAt string `json:"failedAt"`
+
+	// The logical size, and the space allocated on the disk
+	Size       string `json:"size"`
+	ActualSize string `json:"actualSize"`
 }
 
 type EngineImage struct {
@@ -200,6 +263,11 @@ type AttachInput struct {
 
 type DetachInput struct {
 	HostID string `json:"hostId"`
+	Force  bool   `json:"force"`
+}
+
+type MigrationStartInput struct {
+	NodeID string `json:"nodeID"`
 }
 
 type SnapshotInput struct {
@@ -211,10 +279,28 @@ type BackupInput struct {
 	Name string `json:"name"`
 }
 
+type VolumeBackupComparison struct {
+	client.Resource
+	manager.VolumeBackupComparison
+}
+
 type ReplicaRemoveInput struct {
 	Name string `json:"name"`
 }
 
+type ReplicaEvictInput struct {
+	Name string `json:"name"`
+}
+
+type ReplicaScheduleExplainInput struct {
+	Name string `json:"name"`
+}
+
+type ScheduleTrace struct {
+	client.Resource
+	scheduler.ScheduleTrace
+}
+
 type SalvageInput struct {
 	Names []string `json:"names"`
 }
@@ -231,6 +317,10 @@ type UpdateReplicaAutoBalanceInput struct {
 	ReplicaAutoBalance string `json:"replicaAutoBalance"`
 }
 
+type UpdateStaleReplicaTimeoutInput struct {
+	StaleReplicaTimeout int `json:"staleReplicaTimeout"`
+}
+
 type UpdateDataLocalityInput struct {
 	DataLocality string `json:"dataLocality"`
 }
@@ -243,6 +333,51 @@ type UpdateSnapshotDataIntegrityInput struct {
 	SnapshotDataIntegrity string `json:"snapshotDataIntegrity"`
 }
 
+type UpdateExpiryInput struct {
+	ExpireAt string `json:"expireAt"`
+	TTL      string `json:"ttl"`
+}
+
+type UpdateQoSInput struct {
+	RebuildBandwidthLimit  int64 `json:"rebuildBandwidthLimit"`
+	FrontendIOPSLimit      int64 `json:"frontendIOPSLimit"`
+	FrontendBandwidthLimit int64 `json:"frontendBandwidthLimit"`
+}
+
+type UpdateSnapshotLimitsInput struct {
+	SnapshotMaxCount int    `json:"snapshotMaxCount"`
+	SnapshotMaxAge   string `json:"snapshotMaxAge"`
+}
+
+type SetReadOnlyInput struct {
+	ReadOnly bool `json:"readOnly"`
+}
+
+type UpdateLabelsInput struct {
+	Labels map[string]string `json:"labels"`
+}
+
+type VolumeBulkActionInput struct {
+	Action        string `json:"action"`
+	LabelSelector string `json:"labelSelector"`
+}
+
+type VolumeImportInput struct {
+	Name                string                      `json:"name"`
+	Orphans             []string                    `json:"orphans"`
+	NumberOfReplicas    int                         `json:"numberOfReplicas"`
+	Frontend            longhorn.VolumeFrontend     `json:"frontend"`
+	DataLocality        longhorn.DataLocality       `json:"dataLocality"`
+	AccessMode          longhorn.AccessMode         `json:"accessMode"`
+	StaleReplicaTimeout int                         `json:"staleReplicaTimeout"`
+	ReplicaAutoBalance  longhorn.ReplicaAutoBalance `json:"replicaAutoBalance"`
+}
+
+type VolumeBulkActionOutput struct {
+	Data []manager.VolumeBulkActionResult `json:"data"`
+	Type string                           `json:"type"`
+}
+
 type UpdateBackupCompressionMethodInput struct {
 	BackupCompressionMethod string `json:"backupCompressionMethod"`
 }
@@ -272,6 +407,11 @@ type ExpandInput struct {
 	Size string `json:"size"`
 }
 
+type FreezeInput struct {
+	// The seconds to thaw the filesystem automatically after. 0 means the default.
+	Timeout int64 `json:"timeout"`
+}
+
 type Node struct {
 	client.Resource
 	Name                     string                        `json:"name"`
@@ -402,6 +542,9 @@ type RebuildStatus struct {
 	Replica      string `json:"replica"`
 	State        string `json:"state"`
 	FromReplica  string `json:"fromReplica"`
+	Throughput   int64  `json:"throughput"`
+
+	SnapshotChainRelation string `json:"snapshotChainRelation"`
 }
 
 type InstanceManager struct {
@@ -421,10 +564,77 @@ type RecurringJob struct {
 
 type Orphan struct {
 	client.Resource
-	Name string `json:"name"`
+	Name     string `json:"name"`
+	Retained bool   `json:"retained"`
 	longhorn.OrphanSpec
 }
 
+type ReplicaDataScanInput struct {
+	Repair bool `json:"repair"`
+}
+
+type ReplicaDataScanReport struct {
+	client.Resource
+	Node     string                           `json:"node"`
+	Replicas []*manager.ReplicaDataScanResult `json:"replicas"`
+}
+
+type DecommissionExecuteInput struct {
+	AllowDetach bool `json:"allowDetach"`
+}
+
+type NodeDecommissionPlan struct {
+	client.Resource
+	Node  string                      `json:"node"`
+	Steps []*manager.DecommissionStep `json:"steps"`
+}
+
+type NodeVerificationReport struct {
+	client.Resource
+	Node           string                                `json:"node"`
+	State          string                                `json:"state"`
+	Error          string                                `json:"error"`
+	StartedAt      string                                `json:"startedAt"`
+	LastVerifiedAt string                                `json:"lastVerifiedAt"`
+	Steps          []longhorn.NodeVerificationStepStatus `json:"steps"`
+}
+
+type ClusterVerificationReport struct {
+	client.Resource
+	State string                    `json:"state"`
+	Nodes []*NodeVerificationReport `json:"nodes"`
+}
+
+type DuplicateVolumeReport struct {
+	client.Resource
+	manager.DuplicateVolumeReport
+}
+
+type TenantUsage struct {
+	client.Resource
+	manager.TenantUsage
+}
+
+type UpgradeReport struct {
+	client.Resource
+	manager.UpgradeReport
+}
+
+type ClusterCapacity struct {
+	client.Resource
+	manager.ClusterCapacity
+}
+
+type WorkQueueReport struct {
+	client.Resource
+	Node   string                        `json:"node"`
+	Queues []*controller.WorkQueueStatus `json:"queues"`
+}
+
+type OrphanRetainInput struct {
+	Retained bool `json:"retained"`
+}
+
 type VolumeRecurringJob struct {
 	client.Resource
 	longhorn.VolumeRecurringJob
@@ -434,6 +644,24 @@ type VolumeRecurringJobInput struct {
 	longhorn.VolumeRecurringJob
 }
 
+type BrowseInput struct {
+	Path string `json:"path"`
+}
+
+type BrowseFile struct {
+	client.Resource
+	util.DirectoryEntry
+}
+
+type VolumeRecurringJobPreviewInput struct {
+	Count int `json:"count"`
+}
+
+type VolumeRecurringJobPreview struct {
+	client.Resource
+	manager.RecurringJobPreview
+}
+
 type BackupListOutput struct {
 	Data []Backup `json:"data"`
 	Type string   `json:"type"`
@@ -444,43 +672,108 @@ type SnapshotListOutput struct {
 	Type string     `json:"type"`
 }
 
+type EngineReplica struct {
+	client.Resource
+	Address string `json:"address"`
+	Mode    string `json:"mode"`
+}
+
+type EngineReplicaListOutput struct {
+	Data []EngineReplica `json:"data"`
+	Type string          `json:"type"`
+}
+
+type EngineInfo struct {
+	client.Resource
+	engineapi.Volume
+}
+
+type VolumeListOutput struct {
+	Data []Volume `json:"data"`
+	Type string   `json:"type"`
+}
+
 func NewSchema() *client.Schemas {
 	schemas := &client.Schemas{}
 
 	schemas.AddType("apiVersion", client.Resource{})
 	schemas.AddType("schema", client.Schema{})
-	schemas.AddType("error", client.ServerApiError{})
+	schemas.AddType("error", Error{})
 	schemas.AddType("attachInput", AttachInput{})
 	schemas.AddType("detachInput", DetachInput{})
+	schemas.AddType("migrationStartInput", MigrationStartInput{})
 	schemas.AddType("snapshotInput", SnapshotInput{})
-	schemas.AddType("backupTarget", BackupTarget{})
+	schemas.AddType("backupTargetTestInput", BackupTargetTestInput{})
+	schemas.AddType("backupTargetTestResult", BackupTargetTestResult{})
 	schemas.AddType("backup", Backup{})
 	schemas.AddType("backupInput", BackupInput{})
+	schemas.AddType("volumeBackupComparison", VolumeBackupComparison{})
 	schemas.AddType("backupStatus", BackupStatus{})
-	schemas.AddType("orphan", Orphan{})
+	schemas.AddType("orphanRetainInput", OrphanRetainInput{})
+	schemas.AddType("replicaDataScanInput", ReplicaDataScanInput{})
+	schemas.AddType("replicaDataScanResult", manager.ReplicaDataScanResult{})
+	replicaDataScanReportSchema(schemas.AddType("replicaDataScanReport", ReplicaDataScanReport{}))
+	schemas.AddType("decommissionExecuteInput", DecommissionExecuteInput{})
+	schemas.AddType("decommissionStep", manager.DecommissionStep{})
+	nodeDecommissionPlanSchema(schemas.AddType("nodeDecommissionPlan", NodeDecommissionPlan{}))
+	schemas.AddType("verificationStepStatus", longhorn.NodeVerificationStepStatus{})
+	nodeVerificationReportSchema(schemas.AddType("nodeVerificationReport", NodeVerificationReport{}))
+	clusterVerificationReportSchema(schemas.AddType("clusterVerificationReport", ClusterVerificationReport{}))
+	schemas.AddType("duplicateVolumeGroup", manager.DuplicateVolumeGroup{})
+	duplicateVolumeReportSchema(schemas.AddType("duplicateVolumeReport", DuplicateVolumeReport{}))
+	schemas.AddType("tenantUsage", TenantUsage{})
+	schemas.AddType("volumeUpgradeReport", manager.VolumeUpgradeReport{})
+	schemas.AddType("settingUpgradeReport", manager.SettingUpgradeReport{})
+	upgradeReportSchema(schemas.AddType("upgradeReport", UpgradeReport{}))
+	schemas.AddType("diskCapacity", manager.DiskCapacity{})
+	nodeCapacitySchema(schemas.AddType("nodeCapacity", manager.NodeCapacity{}))
+	schemas.AddType("volumeCapacity", manager.VolumeCapacity{})
+	clusterCapacitySchema(schemas.AddType("clusterCapacity", ClusterCapacity{}))
+	schemas.AddType("workQueuePendingItem", controller.WorkQueuePendingItem{})
+	workQueueStatusSchema(schemas.AddType("workQueueStatus", controller.WorkQueueStatus{}))
+	workQueueReportSchema(schemas.AddType("workQueueReport", WorkQueueReport{}))
 	schemas.AddType("restoreStatus", RestoreStatus{})
 	schemas.AddType("purgeStatus", PurgeStatus{})
 	schemas.AddType("rebuildStatus", RebuildStatus{})
 	schemas.AddType("replicaRemoveInput", ReplicaRemoveInput{})
+	schemas.AddType("replicaEvictInput", ReplicaEvictInput{})
+	schemas.AddType("replicaScheduleExplainInput", ReplicaScheduleExplainInput{})
+	schemas.AddType("scheduleTrace", ScheduleTrace{})
 	schemas.AddType("salvageInput", SalvageInput{})
 	schemas.AddType("activateInput", ActivateInput{})
 	schemas.AddType("expandInput", ExpandInput{})
+	schemas.AddType("freezeInput", FreezeInput{})
 	schemas.AddType("engineUpgradeInput", EngineUpgradeInput{})
 	schemas.AddType("replica", Replica{})
 	schemas.AddType("controller", Controller{})
 	schemas.AddType("diskUpdate", longhorn.DiskSpec{})
 	schemas.AddType("UpdateReplicaCountInput", UpdateReplicaCountInput{})
 	schemas.AddType("UpdateReplicaAutoBalanceInput", UpdateReplicaAutoBalanceInput{})
+	schemas.AddType("UpdateStaleReplicaTimeoutInput", UpdateStaleReplicaTimeoutInput{})
 	schemas.AddType("UpdateDataLocalityInput", UpdateDataLocalityInput{})
 	schemas.AddType("UpdateAccessModeInput", UpdateAccessModeInput{})
 	schemas.AddType("UpdateSnapshotDataIntegrityInput", UpdateSnapshotDataIntegrityInput{})
 	schemas.AddType("UpdateBackupCompressionInput", UpdateBackupCompressionMethodInput{})
+	schemas.AddType("UpdateExpiryInput", UpdateExpiryInput{})
+	schemas.AddType("UpdateQoSInput", UpdateQoSInput{})
+	schemas.AddType("UpdateSnapshotLimitsInput", UpdateSnapshotLimitsInput{})
+	schemas.AddType("setReadOnlyInput", SetReadOnlyInput{})
+	schemas.AddType("UpdateLabelsInput", UpdateLabelsInput{})
+	schemas.AddType("volumeBulkActionInput", VolumeBulkActionInput{})
+	schemas.AddType("volumeImportInput", VolumeImportInput{})
+	schemas.AddType("volumeBulkActionResult", manager.VolumeBulkActionResult{})
+	volumeBulkActionOutputSchema(schemas.AddType("volumeBulkActionOutput", VolumeBulkActionOutput{}))
 	schemas.AddType("UpdateUnmapMarkSnapChainRemovedInput", UpdateUnmapMarkSnapChainRemovedInput{})
 	schemas.AddType("workloadStatus", longhorn.WorkloadStatus{})
 	schemas.AddType("cloneStatus", longhorn.VolumeCloneStatus{})
 
 	schemas.AddType("volumeRecurringJob", VolumeRecurringJob{})
 	schemas.AddType("volumeRecurringJobInput", VolumeRecurringJobInput{})
+	schemas.AddType("volumeRecurringJobPreviewInput", VolumeRecurringJobPreviewInput{})
+	schemas.AddType("browseInput", BrowseInput{})
+	schemas.AddType("browseFile", BrowseFile{})
+	schemas.AddType("recurringJobRunPreview", manager.RecurringJobRunPreview{})
+	volumeRecurringJobPreviewSchema(schemas.AddType("volumeRecurringJobPreview", VolumeRecurringJobPreview{}))
 
 	schemas.AddType("PVCreateInput", PVCreateInput{})
 	schemas.AddType("PVCCreateInput", PVCCreateInput{})
@@ -511,26 +804,149 @@ func NewSchema() *client.Schemas {
 	engineImageSchema(schemas.AddType("engineImage", EngineImage{}))
 	backingImageSchema(schemas.AddType("backingImage", BackingImage{}))
 	nodeSchema(schemas.AddType("node", Node{}))
+	orphanSchema(schemas.AddType("orphan", Orphan{}))
+	backupTargetSchema(schemas.AddType("backupTarget", BackupTarget{}))
 	diskSchema(schemas.AddType("diskUpdateInput", DiskUpdateInput{}))
 	diskInfoSchema(schemas.AddType("diskInfo", DiskInfo{}))
 	kubernetesStatusSchema(schemas.AddType("kubernetesStatus", longhorn.KubernetesStatus{}))
 	backupListOutputSchema(schemas.AddType("backupListOutput", BackupListOutput{}))
 	snapshotListOutputSchema(schemas.AddType("snapshotListOutput", SnapshotListOutput{}))
+	schemas.AddType("engineReplica", EngineReplica{})
+	engineReplicaListOutputSchema(schemas.AddType("engineReplicaListOutput", EngineReplicaListOutput{}))
+	schemas.AddType("engineInfo", EngineInfo{})
+	volumeListOutputSchema(schemas.AddType("volumeListOutput", VolumeListOutput{}))
 	systemBackupSchema(schemas.AddType("systemBackup", SystemBackup{}))
 	systemRestoreSchema(schemas.AddType("systemRestore", SystemRestore{}))
 
 	return schemas
 }
 
+func backupTargetSchema(backupTarget *client.Schema) {
+	backupTarget.CollectionMethods = []string{"GET"}
+
+	backupTarget.CollectionActions = map[string]client.Action{
+		"backupTargetTest": {
+			Input:  "backupTargetTestInput",
+			Output: "backupTargetTestResult",
+		},
+	}
+}
+
+func orphanSchema(orphan *client.Schema) {
+	orphan.CollectionMethods = []string{"GET"}
+	orphan.ResourceMethods = []string{"GET", "DELETE"}
+
+	orphan.ResourceActions = map[string]client.Action{
+		"orphanRetain": {
+			Input:  "orphanRetainInput",
+			Output: "orphan",
+		},
+	}
+}
+
+func replicaDataScanReportSchema(report *client.Schema) {
+	replicas := report.ResourceFields["replicas"]
+	replicas.Type = "array[replicaDataScanResult]"
+	report.ResourceFields["replicas"] = replicas
+}
+
+func nodeDecommissionPlanSchema(plan *client.Schema) {
+	steps := plan.ResourceFields["steps"]
+	steps.Type = "array[decommissionStep]"
+	plan.ResourceFields["steps"] = steps
+}
+
+func nodeVerificationReportSchema(report *client.Schema) {
+	steps := report.ResourceFields["steps"]
+	steps.Type = "array[verificationStepStatus]"
+	report.ResourceFields["steps"] = steps
+}
+
+func clusterVerificationReportSchema(report *client.Schema) {
+	nodes := report.ResourceFields["nodes"]
+	nodes.Type = "array[nodeVerificationReport]"
+	report.ResourceFields["nodes"] = nodes
+}
+
+func duplicateVolumeReportSchema(report *client.Schema) {
+	groups := report.ResourceFields["groups"]
+	groups.Type = "array[duplicateVolumeGroup]"
+	report.ResourceFields["groups"] = groups
+}
+
+func upgradeReportSchema(report *client.Schema) {
+	volumes := report.ResourceFields["volumes"]
+	volumes.Type = "array[volumeUpgradeReport]"
+	report.ResourceFields["volumes"] = volumes
+
+	defaultSettings := report.ResourceFields["defaultSettings"]
+	defaultSettings.Type = "array[settingUpgradeReport]"
+	report.ResourceFields["defaultSettings"] = defaultSettings
+}
+
+func nodeCapacitySchema(capacity *client.Schema) {
+	disks := capacity.ResourceFields["disks"]
+	disks.Type = "array[diskCapacity]"
+	capacity.ResourceFields["disks"] = disks
+}
+
+func clusterCapacitySchema(capacity *client.Schema) {
+	nodes := capacity.ResourceFields["nodes"]
+	nodes.Type = "array[nodeCapacity]"
+	capacity.ResourceFields["nodes"] = nodes
+
+	volumes := capacity.ResourceFields["volumes"]
+	volumes.Type = "array[volumeCapacity]"
+	capacity.ResourceFields["volumes"] = volumes
+}
+
+func workQueueStatusSchema(status *client.Schema) {
+	pendingItems := status.ResourceFields["pendingItems"]
+	pendingItems.Type = "array[workQueuePendingItem]"
+	status.ResourceFields["pendingItems"] = pendingItems
+}
+
+func workQueueReportSchema(report *client.Schema) {
+	queues := report.ResourceFields["queues"]
+	queues.Type = "array[workQueueStatus]"
+	report.ResourceFields["queues"] = queues
+}
+
 func nodeSchema(node *client.Schema) {
 	node.CollectionMethods = []string{"GET"}
 	node.ResourceMethods = []string{"GET", "PUT"}
 
+	node.CollectionActions = map[string]client.Action{
+		"clusterVerify": {
+			Output: "clusterVerificationReport",
+		},
+		"clusterVerificationStatus": {
+			Output: "clusterVerificationReport",
+		},
+	}
+
 	node.ResourceActions = map[string]client.Action{
+		"decommissionExecute": {
+			Input:  "decommissionExecuteInput",
+			Output: "nodeDecommissionPlan",
+		},
+		"decommissionPlan": {
+			Output: "nodeDecommissionPlan",
+		},
 		"diskUpdate": {
 			Input:  "diskUpdateInput",
 			Output: "node",
 		},
+		"replicaDataScan": {
+			Input:  "replicaDataScanInput",
+			Output: "replicaDataScanReport",
+		},
+		"verify": {
+			Output: "nodeVerificationReport",
+		},
+		"workQueueStatus": {
+			Output: "workQueueReport",
+		},
 	}
 
 	allowScheduling := node.ResourceFields["allowScheduling"]
@@ -691,6 +1107,14 @@ func backupVolumeSchema(backupVolume *client.Schema) {
 			Input:  "backupInput",
 			Output: "backupVolume",
 		},
+		"backupVerify": {
+			Input:  "backupInput",
+			Output: "backup",
+		},
+		"backupBrowse": {
+			Input:  "backupInput",
+			Output: "volume",
+		},
 	}
 }
 
@@ -717,6 +1141,19 @@ func settingSchema(setting *client.Schema) {
 func volumeSchema(volume *client.Schema) {
 	volume.CollectionMethods = []string{"GET", "POST"}
 	volume.ResourceMethods = []string{"GET", "DELETE"}
+	volume.CollectionActions = map[string]client.Action{
+		"bulkAction": {
+			Input:  "volumeBulkActionInput",
+			Output: "volumeBulkActionOutput",
+		},
+		"duplicateReport": {
+			Output: "duplicateVolumeReport",
+		},
+		"import": {
+			Input:  "volumeImportInput",
+			Output: "volume",
+		},
+	}
 	volume.ResourceActions = map[string]client.Action{
 		"attach": {
 			Input:  "attachInput",
@@ -744,6 +1181,26 @@ func volumeSchema(volume *client.Schema) {
 		"trimFilesystem": {
 			Output: "volume",
 		},
+		"freeze": {
+			Input:  "freezeInput",
+			Output: "volume",
+		},
+		"thaw": {
+			Output: "volume",
+		},
+		"filesystemCheck": {
+			Output: "volume",
+		},
+		"migrationStart": {
+			Input:  "migrationStartInput",
+			Output: "volume",
+		},
+		"migrationConfirm": {
+			Output: "volume",
+		},
+		"migrationRollback": {
+			Output: "volume",
+		},
 
 		"snapshotPurge": {
 			Output: "volume",
@@ -759,6 +1216,13 @@ func volumeSchema(volume *client.Schema) {
 		"snapshotList": {
 			Output: "snapshotListOutput",
 		},
+		"snapshotViewCreate": {
+			Input:  "snapshotInput",
+			Output: "volume",
+		},
+		"snapshotViewList": {
+			Output: "volumeListOutput",
+		},
 		"snapshotDelete": {
 			Input:  "snapshotInput",
 			Output: "volume",
@@ -771,6 +1235,10 @@ func volumeSchema(volume *client.Schema) {
 			Input:  "snapshotInput",
 			Output: "volume",
 		},
+		"backupCompare": {
+			Input:  "backupInput",
+			Output: "volumeBackupComparison",
+		},
 
 		"recurringJobAdd": {
 			Input:  "volumeRecurringJobInput",
@@ -786,6 +1254,16 @@ func volumeSchema(volume *client.Schema) {
 			Output: "volumeRecurringJob",
 		},
 
+		"recurringJobPreview": {
+			Input:  "volumeRecurringJobPreviewInput",
+			Output: "volumeRecurringJobPreview",
+		},
+
+		"browseFileList": {
+			Input:  "browseInput",
+			Output: "browseFile",
+		},
+
 		"updateReplicaCount": {
 			Input: "UpdateReplicaCountInput",
 		},
@@ -798,6 +1276,10 @@ func volumeSchema(volume *client.Schema) {
 			Input: "UpdateDataLocalityInput",
 		},
 
+		"updateStaleReplicaTimeout": {
+			Input: "UpdateStaleReplicaTimeoutInput",
+		},
+
 		"updateAccessMode": {
 			Input: "UpdateAccessModeInput",
 		},
@@ -810,6 +1292,24 @@ func volumeSchema(volume *client.Schema) {
 			Input: "UpdateBackupCompressionMethodInput",
 		},
 
+		"updateExpiry": {
+			Input: "UpdateExpiryInput",
+		},
+		"updateQoS": {
+			Input: "UpdateQoSInput",
+		},
+		"updateSnapshotLimits": {
+			Input: "UpdateSnapshotLimitsInput",
+		},
+		"setReadOnly": {
+			Input:  "setReadOnlyInput",
+			Output: "volume",
+		},
+		"updateLabels": {
+			Input:  "UpdateLabelsInput",
+			Output: "volume",
+		},
+
 		"updateUnmapMarkSnapChainRemoved": {
 			Input: "UpdateUnmapMarkSnapChainRemovedInput",
 		},
@@ -831,6 +1331,22 @@ func volumeSchema(volume *client.Schema) {
 			Output: "volume",
 		},
 
+		"replicaEvict": {
+			Input:  "replicaEvictInput",
+			Output: "volume",
+		},
+
+		"replicaScheduleExplain": {
+			Input:  "replicaScheduleExplainInput",
+			Output: "scheduleTrace",
+		},
+		"replicaList": {
+			Output: "engineReplicaListOutput",
+		},
+		"controllerInfo": {
+			Output: "engineInfo",
+		},
+
 		"engineUpgrade": {
 			Input: "engineUpgradeInput",
 		},
@@ -884,6 +1400,36 @@ func volumeSchema(volume *client.Schema) {
 	volumeBackupCompressionMethod.Default = longhorn.BackupCompressionMethodLz4
 	volume.ResourceFields["backupCompressionMethod"] = volumeBackupCompressionMethod
 
+	volumeExpireAt := volume.ResourceFields["expireAt"]
+	volumeExpireAt.Create = true
+	volume.ResourceFields["expireAt"] = volumeExpireAt
+
+	for _, field := range []string{"rebuildBandwidthLimit", "frontendIOPSLimit", "frontendBandwidthLimit"} {
+		volumeQoSLimit := volume.ResourceFields[field]
+		volumeQoSLimit.Create = true
+		volume.ResourceFields[field] = volumeQoSLimit
+	}
+
+	for _, field := range []string{"snapshotMaxCount", "snapshotMaxAge"} {
+		volumeSnapshotLimit := volume.ResourceFields[field]
+		volumeSnapshotLimit.Create = true
+		volume.ResourceFields[field] = volumeSnapshotLimit
+	}
+
+	for _, field := range []string{"filesystemType", "filesystem", "mountOptions"} {
+		volumeFilesystem := volume.ResourceFields[field]
+		volumeFilesystem.Create = true
+		volume.ResourceFields[field] = volumeFilesystem
+	}
+
+	volumeLabels := volume.ResourceFields["labels"]
+	volumeLabels.Create = true
+	volume.ResourceFields["labels"] = volumeLabels
+
+	volumeTenant := volume.ResourceFields["tenant"]
+	volumeTenant.Create = true
+	volume.ResourceFields["tenant"] = volumeTenant
+
 	volumeAccessMode := volume.ResourceFields["accessMode"]
 	volumeAccessMode.Create = true
 	volumeAccessMode.Default = longhorn.AccessModeReadWriteOnce
@@ -986,6 +1532,30 @@ func snapshotListOutputSchema(snapshotList *client.Schema) {
 	snapshotList.ResourceFields["data"] = data
 }
 
+func engineReplicaListOutputSchema(replicaList *client.Schema) {
+	data := replicaList.ResourceFields["data"]
+	data.Type = "array[engineReplica]"
+	replicaList.ResourceFields["data"] = data
+}
+
+func volumeBulkActionOutputSchema(output *client.Schema) {
+	data := output.ResourceFields["data"]
+	data.Type = "array[volumeBulkActionResult]"
+	output.ResourceFields["data"] = data
+}
+
+func volumeRecurringJobPreviewSchema(preview *client.Schema) {
+	runs := preview.ResourceFields["runs"]
+	runs.Type = "array[recurringJobRunPreview]"
+	preview.ResourceFields["runs"] = runs
+}
+
+func volumeListOutputSchema(volumeList *client.Schema) {
+	data := volumeList.ResourceFields["data"]
+	data.Type = "array[volume]"
+	volumeList.ResourceFields["data"] = data
+}
+
 func systemBackupSchema(systemBackup *client.Schema) {
 	systemBackup.CollectionMethods = []string{"GET", "POST"}
 	systemBackup.ResourceMethods = []string{"GET", "DELETE"}
@@ -1037,6 +1607,17 @@ func toSettingCollection(settings []*longhorn.Setting) *client.GenericCollection
 	return &client.GenericCollection{Data: data, Collection: client.Collection{ResourceType: "setting"}}
 }
 
+func getInstanceUptime(startedAt string) int64 {
+	if startedAt == "" {
+		return 0
+	}
+	started, err := util.ParseTime(startedAt)
+	if err != nil {
+		return 0
+	}
+	return int64(time.Since(started) / time.Second)
+}
+
 func toVolumeResource(v *longhorn.Volume, ves []*longhorn.Engine, vrs []*longhorn.Replica, backups []*longhorn.Backup, apiContext *api.ApiContext) *Volume {
 	var ve *longhorn.Engine
 	controllers := []Controller{}
@@ -1055,7 +1636,7 @@ func toVolumeResource(v *longhorn.Volume, ves []*longhorn.Engine, vrs []*longhor
 			}
 			actualSize += snapshotSize
 		}
-		controllers = append(controllers, Controller{
+		ctrl := Controller{
 			Instance: Instance{
 				Name:                e.Name,
 				Running:             e.Status.CurrentState == longhorn.InstanceStateRunning,
@@ -1064,6 +1645,11 @@ func toVolumeResource(v *longhorn.Volume, ves []*longhorn.Engine, vrs []*longhor
 				EngineImage:         e.Spec.EngineImage,
 				CurrentImage:        e.Status.CurrentImage,
 				InstanceManagerName: e.Status.InstanceManagerName,
+				StartedAt:           e.Status.StartedAt,
+				Uptime:              getInstanceUptime(e.Status.StartedAt),
+				RestartCount:        e.Status.RestartCount,
+				LastRestartAt:       e.Status.LastRestartAt,
+				LastExitReason:      e.Status.LastExitReason,
 			},
 			Size:                             strconv.FormatInt(e.Status.CurrentSize, 10),
 			ActualSize:                       strconv.FormatInt(actualSize, 10),
@@ -1074,7 +1660,18 @@ func toVolumeResource(v *longhorn.Volume, ves []*longhorn.Engine, vrs []*longhor
 			LastExpansionError:               e.Status.LastExpansionError,
 			LastExpansionFailedAt:            e.Status.LastExpansionFailedAt,
 			UnmapMarkSnapChainRemovedEnabled: e.Status.UnmapMarkSnapChainRemovedEnabled,
-		})
+			FrontendReadOnly:                 e.Spec.ReadOnly,
+		}
+		if e.Status.Endpoint != "" && !engineapi.IsEndpointTGTBlockDev(e.Status.Endpoint) {
+			if target, err := engineapi.ParseISCSIEndpoint(e.Status.Endpoint); err != nil {
+				logrus.WithError(err).Warnf("api: Cannot parse iSCSI endpoint of engine %v", e.Name)
+			} else {
+				ctrl.ISCSITargetIQN = target.IQN
+				ctrl.ISCSITargetIP = target.IP
+				ctrl.ISCSITargetPort = target.Port
+			}
+		}
+		controllers = append(controllers, ctrl)
 		if e.Spec.NodeID == v.Status.CurrentNodeID {
 			ve = e
 		}
@@ -1113,14 +1710,24 @@ func toVolumeResource(v *longhorn.Volume, ves []*longhorn.Engine, vrs []*longhor
 		if rebuildStatus != nil {
 			replicas := util.GetSortedKeysFromMap(rebuildStatus)
 			for _, replica := range replicas {
+				replicaName := datastore.ReplicaAddressToReplicaName(replica, vrs)
+				chainRelation := ""
+				for _, r := range vrs {
+					if r.Name == replicaName {
+						chainRelation = string(r.Status.SnapshotChainRelation)
+					}
+				}
 				rebuildStatuses = append(rebuildStatuses, RebuildStatus{
 					Resource:     client.Resource{},
-					Replica:      datastore.ReplicaAddressToReplicaName(replica, vrs),
+					Replica:      replicaName,
 					Error:        rebuildStatus[replica].Error,
 					IsRebuilding: rebuildStatus[replica].IsRebuilding,
 					Progress:     rebuildStatus[replica].Progress,
 					State:        rebuildStatus[replica].State,
 					FromReplica:  datastore.ReplicaAddressToReplicaName(rebuildStatus[replica].FromReplicaAddress, vrs),
+					Throughput:   rebuildStatus[replica].Throughput,
+
+					SnapshotChainRelation: chainRelation,
 				})
 			}
 		}
@@ -1141,12 +1748,19 @@ func toVolumeResource(v *longhorn.Volume, ves []*longhorn.Engine, vrs []*longhor
 				EngineImage:         r.Spec.EngineImage,
 				CurrentImage:        r.Status.CurrentImage,
 				InstanceManagerName: r.Status.InstanceManagerName,
+				StartedAt:           r.Status.StartedAt,
+				Uptime:              getInstanceUptime(r.Status.StartedAt),
+				RestartCount:        r.Status.RestartCount,
+				LastRestartAt:       r.Status.LastRestartAt,
+				LastExitReason:      r.Status.LastExitReason,
 			},
-			DiskID:   r.Spec.DiskID,
-			DiskPath: r.Spec.DiskPath,
-			DataPath: types.GetReplicaDataPath(r.Spec.DiskPath, r.Spec.DataDirectoryName),
-			Mode:     mode,
-			FailedAt: r.Spec.FailedAt,
+			DiskID:     r.Spec.DiskID,
+			DiskPath:   r.Spec.DiskPath,
+			DataPath:   types.GetReplicaDataPath(r.Spec.DiskPath, r.Spec.DataDirectoryName),
+			Mode:       mode,
+			FailedAt:   r.Spec.FailedAt,
+			Size:       strconv.FormatInt(r.Spec.VolumeSize, 10),
+			ActualSize: strconv.FormatInt(r.Status.ActualSize, 10),
 		})
 	}
 
@@ -1201,6 +1815,19 @@ func toVolumeResource(v *longhorn.Volume, ves []*longhorn.Engine, vrs []*longhor
 		DataLocality:              v.Spec.DataLocality,
 		SnapshotDataIntegrity:     v.Spec.SnapshotDataIntegrity,
 		BackupCompressionMethod:   v.Spec.BackupCompressionMethod,
+		ExpireAt:                  v.Spec.ExpireAt,
+		RebuildBandwidthLimit:     v.Spec.RebuildBandwidthLimit,
+		FrontendIOPSLimit:         v.Spec.FrontendIOPSLimit,
+		FrontendBandwidthLimit:    v.Spec.FrontendBandwidthLimit,
+		SnapshotMaxCount:          v.Spec.SnapshotMaxCount,
+		SnapshotMaxAge:            v.Spec.SnapshotMaxAge,
+		FilesystemType:            v.Spec.FilesystemType,
+		Filesystem:                v.Spec.Filesystem,
+		MountOptions:              v.Spec.MountOptions,
+		LastFilesystemCheckAt:     v.Status.LastFilesystemCheckAt,
+		ReadOnly:                  v.Spec.ReadOnly,
+		Labels:                    manager.GetVolumeUserLabels(v),
+		Tenant:                    manager.GetVolumeTenant(v),
 		StaleReplicaTimeout:       v.Spec.StaleReplicaTimeout,
 		Created:                   v.CreationTimestamp.String(),
 		EngineImage:               v.Spec.EngineImage,
@@ -1215,6 +1842,10 @@ func toVolumeResource(v *longhorn.Volume, ves []*longhorn.Engine, vrs []*longhor
 		CurrentImage:              v.Status.CurrentImage,
 		LastBackup:                v.Status.LastBackup,
 		LastBackupAt:              v.Status.LastBackupAt,
+		LastIntegrityCheckedAt:    v.Status.LastIntegrityCheckedAt,
+		LastIntegrityVerifiedAt:   v.Status.LastIntegrityVerifiedAt,
+		IntegrityCheckError:       v.Status.IntegrityCheckError,
+		FrozenUntil:               v.Status.FrozenUntil,
 		RestoreRequired:           v.Status.RestoreRequired,
 		RevisionCounterDisabled:   v.Spec.RevisionCounterDisabled,
 		UnmapMarkSnapChainRemoved: v.Spec.UnmapMarkSnapChainRemoved,
@@ -1224,7 +1855,10 @@ func toVolumeResource(v *longhorn.Volume, ves []*longhorn.Engine, vrs []*longhor
 		ShareEndpoint: v.Status.ShareEndpoint,
 		ShareState:    v.Status.ShareState,
 
-		Migratable: v.Spec.Migratable,
+		Migratable:         v.Spec.Migratable,
+		MigrationNodeID:    v.Spec.MigrationNodeID,
+		MigrationState:     v.Status.MigrationState,
+		MigrationStartedAt: v.Status.MigrationStartedAt,
 
 		Encrypted: v.Spec.Encrypted,
 
@@ -1243,8 +1877,9 @@ func toVolumeResource(v *longhorn.Volume, ves []*longhorn.Engine, vrs []*longhor
 	// api attach & detach calls are always allowed
 	// the volume manager is responsible for handling them appropriately
 	actions := map[string]struct{}{
-		"attach": {},
-		"detach": {},
+		"attach":                 {},
+		"detach":                 {},
+		"replicaScheduleExplain": {},
 	}
 
 	if v.Status.Robustness == longhorn.VolumeRobustnessFaulted {
@@ -1262,17 +1897,26 @@ func toVolumeResource(v *longhorn.Volume, ves []*longhorn.Engine, vrs []*longhor
 			actions["updateDataLocality"] = struct{}{}
 			actions["updateAccessMode"] = struct{}{}
 			actions["updateReplicaAutoBalance"] = struct{}{}
+			actions["updateStaleReplicaTimeout"] = struct{}{}
 			actions["updateUnmapMarkSnapChainRemoved"] = struct{}{}
 			actions["updateSnapshotDataIntegrity"] = struct{}{}
 			actions["updateBackupCompressionMethod"] = struct{}{}
+			actions["updateExpiry"] = struct{}{}
+			actions["updateQoS"] = struct{}{}
+			actions["updateSnapshotLimits"] = struct{}{}
+			actions["setReadOnly"] = struct{}{}
+			actions["updateLabels"] = struct{}{}
+			actions["filesystemCheck"] = struct{}{}
 			actions["recurringJobAdd"] = struct{}{}
 			actions["recurringJobDelete"] = struct{}{}
 			actions["recurringJobList"] = struct{}{}
+			actions["recurringJobPreview"] = struct{}{}
 		case longhorn.VolumeStateAttaching:
 			actions["cancelExpansion"] = struct{}{}
 			actions["recurringJobAdd"] = struct{}{}
 			actions["recurringJobDelete"] = struct{}{}
 			actions["recurringJobList"] = struct{}{}
+			actions["recurringJobPreview"] = struct{}{}
 		case longhorn.VolumeStateAttached:
 			actions["activate"] = struct{}{}
 			actions["expand"] = struct{}{}
@@ -1282,24 +1926,50 @@ func toVolumeResource(v *longhorn.Volume, ves []*longhorn.Engine, vrs []*longhor
 			actions["snapshotGet"] = struct{}{}
 			actions["snapshotDelete"] = struct{}{}
 			actions["snapshotRevert"] = struct{}{}
+			actions["snapshotViewCreate"] = struct{}{}
+			actions["snapshotViewList"] = struct{}{}
 			actions["snapshotBackup"] = struct{}{}
+			actions["backupCompare"] = struct{}{}
 			actions["replicaRemove"] = struct{}{}
+			actions["replicaEvict"] = struct{}{}
+			actions["replicaList"] = struct{}{}
+			actions["controllerInfo"] = struct{}{}
 			actions["engineUpgrade"] = struct{}{}
 			actions["updateReplicaCount"] = struct{}{}
 			actions["updateDataLocality"] = struct{}{}
 			actions["updateReplicaAutoBalance"] = struct{}{}
+			actions["updateStaleReplicaTimeout"] = struct{}{}
 			actions["updateUnmapMarkSnapChainRemoved"] = struct{}{}
 			actions["updateSnapshotDataIntegrity"] = struct{}{}
 			actions["updateBackupCompressionMethod"] = struct{}{}
+			actions["updateExpiry"] = struct{}{}
+			actions["updateQoS"] = struct{}{}
+			actions["updateSnapshotLimits"] = struct{}{}
+			actions["setReadOnly"] = struct{}{}
+			actions["updateLabels"] = struct{}{}
 			actions["pvCreate"] = struct{}{}
 			actions["pvcCreate"] = struct{}{}
 			actions["cancelExpansion"] = struct{}{}
 			actions["trimFilesystem"] = struct{}{}
+			actions["freeze"] = struct{}{}
+			actions["thaw"] = struct{}{}
 			actions["recurringJobAdd"] = struct{}{}
 			actions["recurringJobDelete"] = struct{}{}
 			actions["recurringJobList"] = struct{}{}
+			actions["recurringJobPreview"] = struct{}{}
+		}
+	}
+	if v.Spec.Migratable && v.Status.State == longhorn.VolumeStateAttached {
+		if v.Spec.MigrationNodeID == "" {
+			actions["migrationStart"] = struct{}{}
+		} else {
+			actions["migrationConfirm"] = struct{}{}
+			actions["migrationRollback"] = struct{}{}
 		}
 	}
+	if _, ok := v.Labels[types.GetLonghornLabelKey(types.LonghornLabelBackupBrowser)]; ok {
+		actions["browseFileList"] = struct{}{}
+	}
 
 	for action := range actions {
 		r.Actions[action] = apiContext.UrlBuilder.ActionLink(r.Resource, action)
@@ -1320,6 +1990,7 @@ func toSnapshotResource(s *longhorn.SnapshotInfo, checksum string) *Snapshot {
 		},
 		SnapshotInfo: *s,
 		Checksum:     checksum,
+		System:       s.Name != etypes.VolumeHeadName && types.IsSystemSnapshot(s.Name, s.UserCreated, s.Labels),
 	}
 }
 
@@ -1338,6 +2009,31 @@ func toSnapshotCollection(ssList map[string]*longhorn.SnapshotInfo, ssListRO map
 	return &client.GenericCollection{Data: data, Collection: client.Collection{ResourceType: "snapshot"}}
 }
 
+func toEngineReplicaCollection(replicas map[string]*engineapi.Replica) *client.GenericCollection {
+	data := []interface{}{}
+	for address, r := range replicas {
+		data = append(data, &EngineReplica{
+			Resource: client.Resource{
+				Id:   address,
+				Type: "engineReplica",
+			},
+			Address: address,
+			Mode:    string(r.Mode),
+		})
+	}
+	return &client.GenericCollection{Data: data, Collection: client.Collection{ResourceType: "engineReplica"}}
+}
+
+func toEngineInfoResource(volumeName string, info *engineapi.Volume) *EngineInfo {
+	return &EngineInfo{
+		Resource: client.Resource{
+			Id:   volumeName,
+			Type: "engineInfo",
+		},
+		Volume: *info,
+	}
+}
+
 func toVolumeRecurringJobResource(obj *longhorn.VolumeRecurringJob) *VolumeRecurringJob {
 	if obj == nil {
 		logrus.Warn("weird: nil volumeRecurringJob")
@@ -1360,6 +2056,34 @@ func toVolumeRecurringJobCollection(recurringJobs map[string]*longhorn.VolumeRec
 	return &client.GenericCollection{Data: data, Collection: client.Collection{ResourceType: "volumeRecurringJob"}}
 }
 
+func toVolumeRecurringJobPreviewCollection(previews []*manager.RecurringJobPreview) *client.GenericCollection {
+	data := []interface{}{}
+	for _, preview := range previews {
+		data = append(data, &VolumeRecurringJobPreview{
+			Resource: client.Resource{
+				Id:   preview.Name,
+				Type: "volumeRecurringJobPreview",
+			},
+			RecurringJobPreview: *preview,
+		})
+	}
+	return &client.GenericCollection{Data: data, Collection: client.Collection{ResourceType: "volumeRecurringJobPreview"}}
+}
+
+func toBrowseFileCollection(entries []*util.DirectoryEntry) *client.GenericCollection {
+	data := []interface{}{}
+	for _, entry := range entries {
+		data = append(data, &BrowseFile{
+			Resource: client.Resource{
+				Id:   entry.Path,
+				Type: "browseFile",
+			},
+			DirectoryEntry: *entry,
+		})
+	}
+	return &client.GenericCollection{Data: data, Collection: client.Collection{ResourceType: "browseFile"}}
+}
+
 func toBackupTargetResource(bt *longhorn.BackupTarget) *BackupTarget {
 	if bt == nil {
 		logrus.Warnf("weird: nil backupTarget")
@@ -1409,6 +2133,8 @@ func toBackupVolumeResource(bv *longhorn.BackupVolume, apiContext *api.ApiContex
 		"backupList":   apiContext.UrlBuilder.ActionLink(b.Resource, "backupList"),
 		"backupGet":    apiContext.UrlBuilder.ActionLink(b.Resource, "backupGet"),
 		"backupDelete": apiContext.UrlBuilder.ActionLink(b.Resource, "backupDelete"),
+		"backupVerify": apiContext.UrlBuilder.ActionLink(b.Resource, "backupVerify"),
+		"backupBrowse": apiContext.UrlBuilder.ActionLink(b.Resource, "backupBrowse"),
 	}
 	return b
 }
@@ -1464,6 +2190,9 @@ func toBackupResource(b *longhorn.Backup) *Backup {
 		VolumeCreated:          b.Status.VolumeCreated,
 		VolumeBackingImageName: b.Status.VolumeBackingImageName,
 		CompressionMethod:      string(b.Status.CompressionMethod),
+		VerificationState:      string(b.Status.VerificationState),
+		VerificationError:      b.Status.VerificationError,
+		LastVerifiedAt:         b.Status.LastVerifiedAt,
 	}
 	// Set the volume name from backup CR's label if it's empty.
 	// This field is empty probably because the backup state is not Ready
@@ -1566,6 +2295,9 @@ type Server struct {
 	m   *manager.VolumeManager
 	wsc *controller.WebsocketController
 	fwd *Fwd
+	dvd *manager.DockerVolumeDriver
+
+	draining int32
 }
 
 func NewServer(m *manager.VolumeManager, wsc *controller.WebsocketController) *Server {
@@ -1573,10 +2305,17 @@ func NewServer(m *manager.VolumeManager, wsc *controller.WebsocketController) *S
 		m:   m,
 		wsc: wsc,
 		fwd: NewFwd(m),
+		dvd: manager.NewDockerVolumeDriver(m),
 	}
 	return s
 }
 
+// EnableTLS makes the forwarded requests to the other managers use TLS with
+// the config.
+func (s *Server) EnableTLS(peerConfig *tls.Config) {
+	s.fwd.EnableTLS(peerConfig)
+}
+
 func toNodeResource(node *longhorn.Node, address string, apiContext *api.ApiContext) *Node {
 	n := &Node{
 		Resource: client.Resource{
@@ -1617,7 +2356,12 @@ func toNodeResource(node *longhorn.Node, address string, apiContext *api.ApiCont
 	n.Disks = disks
 
 	n.Actions = map[string]string{
-		"diskUpdate": apiContext.UrlBuilder.ActionLink(n.Resource, "diskUpdate"),
+		"decommissionExecute": apiContext.UrlBuilder.ActionLink(n.Resource, "decommissionExecute"),
+		"decommissionPlan":    apiContext.UrlBuilder.ActionLink(n.Resource, "decommissionPlan"),
+		"diskUpdate":          apiContext.UrlBuilder.ActionLink(n.Resource, "diskUpdate"),
+		"replicaDataScan":     apiContext.UrlBuilder.ActionLink(n.Resource, "replicaDataScan"),
+		"verify":              apiContext.UrlBuilder.ActionLink(n.Resource, "verify"),
+		"workQueueStatus":     apiContext.UrlBuilder.ActionLink(n.Resource, "workQueueStatus"),
 	}
 
 	return n
@@ -1812,25 +2556,30 @@ func toRecurringJobCollection(jobs []*longhorn.RecurringJob, apiContext *api.Api
 	return &client.GenericCollection{Data: data, Collection: client.Collection{ResourceType: "recurringJob"}}
 }
 
-func toOrphanResource(orphan *longhorn.Orphan) *Orphan {
-	return &Orphan{
+func toOrphanResource(orphan *longhorn.Orphan, apiContext *api.ApiContext) *Orphan {
+	res := &Orphan{
 		Resource: client.Resource{
 			Id:   orphan.Name,
 			Type: "orphan",
 		},
-		Name: orphan.Name,
+		Name:     orphan.Name,
+		Retained: types.IsOrphanRetained(orphan),
 		OrphanSpec: longhorn.OrphanSpec{
 			NodeID:     orphan.Spec.NodeID,
 			Type:       orphan.Spec.Type,
 			Parameters: orphan.Spec.Parameters,
 		},
 	}
+	res.Actions = map[string]string{
+		"orphanRetain": apiContext.UrlBuilder.ActionLink(res.Resource, "orphanRetain"),
+	}
+	return res
 }
 
-func toOrphanCollection(orphans map[string]*longhorn.Orphan) *client.GenericCollection {
+func toOrphanCollection(orphans map[string]*longhorn.Orphan, apiContext *api.ApiContext) *client.GenericCollection {
 	var data []interface{}
 	for _, orphan := range orphans {
-		data = append(data, toOrphanResource(orphan))
+		data = append(data, toOrphanResource(orphan, apiContext))
 	}
 	return &client.GenericCollection{Data: data, Collection: client.Collection{ResourceType: "orphan"}}
 }
@@ -1842,3 +2591,25 @@ func sliceToMap(conditions []longhorn.Condition) map[string]longhorn.Condition {
 	}
 	return converted
 }
+
+// selectResourceFields converts the resource to a map with only the given
+// fields, besides the fields identifying the resource and its links.
+func selectResourceFields(resource interface{}, fields []string) (map[string]interface{}, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	all := map[string]interface{}{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	selected := map[string]interface{}{}
+	for _, field := range append([]string{"id", "type", "name", "links", "actions"}, fields...) {
+		field = strings.TrimSpace(field)
+		if value, exists := all[field]; exists {
+			selected[field] = value
+		}
+	}
+	return selected, nil
+}
@@ -1,19 +1,31 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/rancher/go-rancher/api"
 	"github.com/rancher/go-rancher/client"
 
+	"github.com/longhorn/longhorn-manager/controller"
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/types"
 	"github.com/longhorn/longhorn-manager/util"
 
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 )
 
+const (
+	nodeVerificationRequestTimeout = 30 * time.Second
+)
+
 func (s *Server) NodeList(rw http.ResponseWriter, req *http.Request) error {
 	apiContext := api.GetApiContext(req)
 
@@ -120,6 +132,245 @@ func (s *Server) DiskUpdate(rw http.ResponseWriter, req *http.Request) error {
 	return nil
 }
 
+func (s *Server) ReplicaDataScan(rw http.ResponseWriter, req *http.Request) error {
+	var input ReplicaDataScanInput
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return err
+	}
+
+	id := mux.Vars(req)["name"]
+
+	results, err := s.m.ScanNodeReplicaData(id, input.Repair)
+	if err != nil {
+		return err
+	}
+	apiContext.Write(&ReplicaDataScanReport{
+		Resource: client.Resource{
+			Id:   id,
+			Type: "replicaDataScanReport",
+		},
+		Node:     id,
+		Replicas: results,
+	})
+	return nil
+}
+
+func (s *Server) NodeDecommissionPlan(rw http.ResponseWriter, req *http.Request) error {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["name"]
+
+	steps, err := s.m.GetNodeDecommissionPlan(id)
+	if err != nil {
+		return err
+	}
+	apiContext.Write(toNodeDecommissionPlan(id, steps))
+	return nil
+}
+
+func (s *Server) NodeDecommissionExecute(rw http.ResponseWriter, req *http.Request) error {
+	var input DecommissionExecuteInput
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return err
+	}
+
+	id := mux.Vars(req)["name"]
+
+	steps, err := s.m.ExecuteNodeDecommissionPlan(id, input.AllowDetach)
+	if err != nil {
+		return err
+	}
+	apiContext.Write(toNodeDecommissionPlan(id, steps))
+	return nil
+}
+
+// NodeVerify starts the verification of the node in the background. The
+// progress is in the verification status of the node.
+func (s *Server) NodeVerify(rw http.ResponseWriter, req *http.Request) error {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["name"]
+
+	node, err := s.m.VerifyNode(id)
+	if err != nil {
+		return err
+	}
+	apiContext.Write(toNodeVerificationReport(node))
+	return nil
+}
+
+func (s *Server) NodeWorkQueueStatus(rw http.ResponseWriter, req *http.Request) error {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["name"]
+
+	apiContext.Write(&WorkQueueReport{
+		Resource: client.Resource{
+			Id:   id,
+			Type: "workQueueReport",
+		},
+		Node:   id,
+		Queues: controller.GetWorkQueueStatuses(),
+	})
+	return nil
+}
+
+// ClusterVerify starts the verification on all nodes, and returns the
+// verification status of each node.
+func (s *Server) ClusterVerify(rw http.ResponseWriter, req *http.Request) error {
+	apiContext := api.GetApiContext(req)
+
+	nodeList, err := s.m.ListNodesSorted()
+	if err != nil {
+		return errors.Wrap(err, "failed to list nodes")
+	}
+	nodeIPMap, err := s.m.GetManagerNodeIPMap()
+	if err != nil {
+		return errors.Wrap(err, "failed to get node ip")
+	}
+
+	reports := make([]*NodeVerificationReport, len(nodeList))
+	wg := sync.WaitGroup{}
+	for i, node := range nodeList {
+		wg.Add(1)
+		go func(i int, node *longhorn.Node) {
+			defer wg.Done()
+			report, err := s.fwd.requestNodeVerification(req, node.Name, nodeIPMap[node.Name])
+			if err != nil {
+				report = toNodeVerificationReport(node)
+				report.Error = err.Error()
+			}
+			reports[i] = report
+		}(i, node)
+	}
+	wg.Wait()
+
+	apiContext.Write(toClusterVerificationReport(reports))
+	return nil
+}
+
+// ClusterVerificationStatus returns the verification status of all nodes
+// without starting a new verification.
+func (s *Server) ClusterVerificationStatus(rw http.ResponseWriter, req *http.Request) error {
+	apiContext := api.GetApiContext(req)
+
+	nodeList, err := s.m.ListNodesSorted()
+	if err != nil {
+		return errors.Wrap(err, "failed to list nodes")
+	}
+	reports := []*NodeVerificationReport{}
+	for _, node := range nodeList {
+		reports = append(reports, toNodeVerificationReport(node))
+	}
+	apiContext.Write(toClusterVerificationReport(reports))
+	return nil
+}
+
+// requestNodeVerification asks the manager on the node to start the
+// verification, since the test volume data is checked on the node itself.
+func (f *Fwd) requestNodeVerification(req *http.Request, nodeName, nodeIP string) (*NodeVerificationReport, error) {
+	if nodeIP == "" {
+		return nil, fmt.Errorf("cannot find longhorn manager on node %v", nodeName)
+	}
+
+	url := fmt.Sprintf("%s://%s/v1/nodes/%s?action=verify", f.scheme, types.GetAPIServerAddressFromIP(nodeIP), nodeName)
+	newReq, err := http.NewRequestWithContext(req.Context(), http.MethodPost, url, strings.NewReader("{}"))
+	if err != nil {
+		return nil, err
+	}
+	newReq.Header.Set("Content-Type", "application/json")
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		newReq.Header.Set("Authorization", auth)
+	}
+
+	httpClient := http.Client{
+		Transport: f.transport,
+		Timeout:   nodeVerificationRequestTimeout,
+	}
+	resp, err := httpClient.Do(newReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to verify node %v with status %v: %v", nodeName, resp.StatusCode, string(body))
+	}
+
+	report := &NodeVerificationReport{}
+	if err := json.Unmarshal(body, report); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse verification report of node %v", nodeName)
+	}
+	return report, nil
+}
+
+func toNodeVerificationReport(node *longhorn.Node) *NodeVerificationReport {
+	status := node.Status.VerificationStatus
+	steps := status.Steps
+	if steps == nil {
+		steps = []longhorn.NodeVerificationStepStatus{}
+	}
+	return &NodeVerificationReport{
+		Resource: client.Resource{
+			Id:   node.Name,
+			Type: "nodeVerificationReport",
+		},
+		Node:           node.Name,
+		State:          string(status.State),
+		StartedAt:      status.StartedAt,
+		LastVerifiedAt: status.LastVerifiedAt,
+		Steps:          steps,
+	}
+}
+
+// toClusterVerificationReport sums up the node reports. The cluster fails the
+// verification if any node fails or cannot be verified, and passes it only if
+// all nodes pass.
+func toClusterVerificationReport(reports []*NodeVerificationReport) *ClusterVerificationReport {
+	failed, inProgress, unverified := false, false, false
+	for _, report := range reports {
+		switch {
+		case report.Error != "" || report.State == string(longhorn.NodeVerificationStateFailed):
+			failed = true
+		case report.State == string(longhorn.NodeVerificationStateInProgress):
+			inProgress = true
+		case report.State == "":
+			unverified = true
+		}
+	}
+
+	state := longhorn.NodeVerificationStatePassed
+	switch {
+	case failed:
+		state = longhorn.NodeVerificationStateFailed
+	case inProgress:
+		state = longhorn.NodeVerificationStateInProgress
+	case unverified:
+		state = ""
+	}
+	return &ClusterVerificationReport{
+		Resource: client.Resource{
+			Type: "clusterVerificationReport",
+		},
+		State: string(state),
+		Nodes: reports,
+	}
+}
+
+func toNodeDecommissionPlan(node string, steps []*manager.DecommissionStep) *NodeDecommissionPlan {
+	return &NodeDecommissionPlan{
+		Resource: client.Resource{
+			Id:   node,
+			Type: "nodeDecommissionPlan",
+		},
+		Node:  node,
+		Steps: steps,
+	}
+}
+
 func (s *Server) NodeDelete(rw http.ResponseWriter, req *http.Request) error {
 	id := mux.Vars(req)["name"]
 	if err := s.m.DeleteNode(id); err != nil {
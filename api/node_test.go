@@ -0,0 +1,82 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func TestToClusterVerificationReport(t *testing.T) {
+	passed := string(longhorn.NodeVerificationStatePassed)
+	failed := string(longhorn.NodeVerificationStateFailed)
+	inProgress := string(longhorn.NodeVerificationStateInProgress)
+
+	tests := map[string]struct {
+		states      []string
+		errs        []string
+		expectState string
+	}{
+		"all passed": {
+			states:      []string{passed, passed},
+			expectState: passed,
+		},
+		"one failed": {
+			states:      []string{passed, failed, inProgress},
+			expectState: failed,
+		},
+		"one cannot be verified": {
+			states:      []string{passed, inProgress},
+			errs:        []string{"", "connection refused"},
+			expectState: failed,
+		},
+		"one in progress": {
+			states:      []string{passed, inProgress, ""},
+			expectState: inProgress,
+		},
+		"one never verified": {
+			states:      []string{passed, ""},
+			expectState: "",
+		},
+		"no nodes": {
+			expectState: passed,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			reports := []*NodeVerificationReport{}
+			for i, state := range tc.states {
+				report := &NodeVerificationReport{State: state}
+				if i < len(tc.errs) {
+					report.Error = tc.errs[i]
+				}
+				reports = append(reports, report)
+			}
+			require.Equal(t, tc.expectState, toClusterVerificationReport(reports).State)
+		})
+	}
+}
+
+func TestToNodeVerificationReport(t *testing.T) {
+	node := &longhorn.Node{}
+	node.Name = "node-1"
+	report := toNodeVerificationReport(node)
+	require.Equal(t, "node-1", report.Node)
+	require.Equal(t, "", report.State)
+	require.NotNil(t, report.Steps)
+
+	node.Status.VerificationStatus = longhorn.NodeVerificationStatus{
+		State:          longhorn.NodeVerificationStateFailed,
+		StartedAt:      "2026-10-16T10:00:00Z",
+		LastVerifiedAt: "2026-10-16T10:01:00Z",
+		Steps: []longhorn.NodeVerificationStepStatus{
+			{Step: "create", Status: "failed", Message: "no space"},
+		},
+	}
+	report = toNodeVerificationReport(node)
+	require.Equal(t, string(longhorn.NodeVerificationStateFailed), report.State)
+	require.Equal(t, "2026-10-16T10:00:00Z", report.StartedAt)
+	require.Equal(t, "2026-10-16T10:01:00Z", report.LastVerifiedAt)
+	require.Equal(t, node.Status.VerificationStatus.Steps, report.Steps)
+}
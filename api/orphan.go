@@ -1,12 +1,17 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/rancher/go-rancher/api"
 	"github.com/rancher/go-rancher/client"
+
+	"github.com/longhorn/longhorn-manager/util"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 )
 
 func (s *Server) OrphanList(rw http.ResponseWriter, req *http.Request) (err error) {
@@ -17,7 +22,7 @@ func (s *Server) OrphanList(rw http.ResponseWriter, req *http.Request) (err erro
 		return errors.Wrap(err, "failed to list instance managers")
 	}
 
-	apiContext.Write(toOrphanCollection(orphans))
+	apiContext.Write(toOrphanCollection(orphans, apiContext))
 	return nil
 }
 
@@ -26,7 +31,7 @@ func (s *Server) orphanList(apiContext *api.ApiContext) (*client.GenericCollecti
 	if err != nil {
 		return nil, errors.Wrap(err, "error listing orphan")
 	}
-	return toOrphanCollection(list), nil
+	return toOrphanCollection(list, apiContext), nil
 }
 
 func (s *Server) OrphanGet(rw http.ResponseWriter, req *http.Request) error {
@@ -38,7 +43,7 @@ func (s *Server) OrphanGet(rw http.ResponseWriter, req *http.Request) error {
 	if err != nil {
 		return errors.Wrapf(err, "error get orphan '%s'", id)
 	}
-	apiContext.Write(toOrphanResource(orphan))
+	apiContext.Write(toOrphanResource(orphan, apiContext))
 	return nil
 }
 
@@ -50,3 +55,27 @@ func (s *Server) OrphanDelete(rw http.ResponseWriter, req *http.Request) error {
 
 	return nil
 }
+
+func (s *Server) OrphanRetain(rw http.ResponseWriter, req *http.Request) error {
+	var input OrphanRetainInput
+	apiContext := api.GetApiContext(req)
+
+	if err := apiContext.Read(&input); err != nil {
+		return err
+	}
+	id := mux.Vars(req)["name"]
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.RetainOrphan(id, input.Retained)
+	})
+	if err != nil {
+		return err
+	}
+	orphan, ok := obj.(*longhorn.Orphan)
+	if !ok {
+		return fmt.Errorf("BUG: cannot convert to orphan %v object", id)
+	}
+
+	apiContext.Write(toOrphanResource(orphan, apiContext))
+	return nil
+}
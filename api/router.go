@@ -9,18 +9,35 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/longhorn/longhorn-manager/metrics_collector/registry"
+	"github.com/longhorn/longhorn-manager/util"
 )
 
 type HandleFuncWithError func(http.ResponseWriter, *http.Request) error
 
 func HandleError(s *client.Schemas, t HandleFuncWithError) http.Handler {
-	return api.ApiHandler(s, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+	return withRequestID(api.ApiHandler(s, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		if err := t(rw, req); err != nil {
-			logrus.Warnf("HTTP handling error %v", err)
+			util.WithRequestIDField(req.Context(), logrus.StandardLogger()).Warnf("HTTP handling error %v", err)
 			apiContext := api.GetApiContext(req)
-			apiContext.WriteErr(err)
+			writeErr(apiContext, rw, err)
 		}
-	}))
+	})))
+}
+
+// withRequestID attaches the request ID to the request context and the
+// response. The ID from the caller, or from the manager forwarding the
+// request, is kept, so it's the same across the managers. It wraps the API
+// handler, since the API context is bound to the request it's created for.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requestID := req.Header.Get(util.RequestIDHeader)
+		if requestID == "" {
+			requestID = util.NewRequestID()
+			req.Header.Set(util.RequestIDHeader, requestID)
+		}
+		rw.Header().Set(util.RequestIDHeader, requestID)
+		next.ServeHTTP(rw, req.WithContext(util.WithRequestID(req.Context(), requestID)))
+	})
 }
 
 func NewRouter(s *Server) *mux.Router {
@@ -44,11 +61,19 @@ func NewRouter(s *Server) *mux.Router {
 
 	r.Methods("GET").Path("/v1/volumes").Handler(f(schemas, s.VolumeList))
 	r.Methods("GET").Path("/v1/volumes/{name}").Handler(f(schemas, s.VolumeGet))
-	r.Methods("DELETE").Path("/v1/volumes/{name}").Handler(f(schemas, s.VolumeDelete))
+	r.Methods("DELETE").Path("/v1/volumes/{name}").Handler(f(schemas, s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OperationNodeIDFromVolume(s.m)), s.VolumeDelete)))
+	r.Methods("POST").Path("/v1/volumes").Queries("action", "bulkAction").Handler(f(schemas, s.VolumeBulkAction))
+	r.Methods("POST").Path("/v1/volumes").Queries("action", "duplicateReport").Handler(f(schemas, s.VolumeDuplicateReport))
+	r.Methods("POST").Path("/v1/volumes").Queries("action", "import").Handler(f(schemas, s.VolumeImport))
 	r.Methods("POST").Path("/v1/volumes").Handler(f(schemas, s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(NodeHasDefaultEngineImage(s.m)), s.VolumeCreate)))
+	r.Methods("GET").Path("/v1/tenants/{tenant}").Handler(f(schemas, s.TenantGet))
+	r.Methods("GET").Path("/v1/tenants/{tenant}/volumes").Handler(f(schemas, s.VolumeList))
+	r.Methods("GET").Path("/v1/tenants/{tenant}/volumes/{name}").Handler(f(schemas, s.VolumeGet))
+	r.Methods("DELETE").Path("/v1/tenants/{tenant}/volumes/{name}").Handler(f(schemas, s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OperationNodeIDFromVolume(s.m)), s.VolumeDelete)))
+	r.Methods("POST").Path("/v1/tenants/{tenant}/volumes").Handler(f(schemas, s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(NodeHasDefaultEngineImage(s.m)), s.VolumeCreate)))
 	volumeActions := map[string]func(http.ResponseWriter, *http.Request) error{
-		"attach":                          s.VolumeAttach,
-		"detach":                          s.VolumeDetach,
+		"attach":                          s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OperationNodeIDFromVolume(s.m)), s.VolumeAttach),
+		"detach":                          s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OperationNodeIDFromVolume(s.m)), s.VolumeDetach),
 		"salvage":                         s.VolumeSalvage,
 		"updateDataLocality":              s.VolumeUpdateDataLocality,
 		"updateAccessMode":                s.VolumeUpdateAccessMode,
@@ -59,13 +84,31 @@ func NewRouter(s *Server) *mux.Router {
 
 		"updateReplicaCount":            s.VolumeUpdateReplicaCount,
 		"updateReplicaAutoBalance":      s.VolumeUpdateReplicaAutoBalance,
+		"updateStaleReplicaTimeout":     s.VolumeUpdateStaleReplicaTimeout,
 		"updateSnapshotDataIntegrity":   s.VolumeUpdateSnapshotDataIntegrity,
 		"updateBackupCompressionMethod": s.VolumeUpdateBackupCompressionMethod,
+		"updateExpiry":                  s.VolumeUpdateExpiry,
+		"updateQoS":                     s.VolumeUpdateQoS,
+		"updateSnapshotLimits":          s.VolumeUpdateSnapshotLimits,
+		"setReadOnly":                   s.VolumeSetReadOnly,
+		"updateLabels":                  s.VolumeUpdateLabels,
 		"replicaRemove":                 s.ReplicaRemove,
+		"replicaEvict":                  s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromVolume(s.m)), s.ReplicaEvict),
+		"replicaScheduleExplain":        s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromVolume(s.m)), s.ReplicaScheduleExplain),
+		"replicaList":                   s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromVolume(s.m)), s.VolumeReplicaList),
+		"controllerInfo":                s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromVolume(s.m)), s.VolumeControllerInfo),
 
 		"engineUpgrade": s.EngineUpgrade,
 
 		"trimFilesystem": s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromVolume(s.m)), s.VolumeFilesystemTrim),
+		"freeze":         s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(AttachedNodeIDFromVolume(s.m)), s.VolumeFreeze),
+		"thaw":           s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(AttachedNodeIDFromVolume(s.m)), s.VolumeThaw),
+
+		"filesystemCheck": s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OperationNodeIDFromVolume(s.m)), s.VolumeFilesystemCheck),
+
+		"migrationStart":    s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OperationNodeIDFromVolume(s.m)), s.VolumeMigrationStart),
+		"migrationConfirm":  s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OperationNodeIDFromVolume(s.m)), s.VolumeMigrationConfirm),
+		"migrationRollback": s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OperationNodeIDFromVolume(s.m)), s.VolumeMigrationRollback),
 
 		"snapshotPurge":  s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromVolume(s.m)), s.SnapshotPurge),
 		"snapshotCreate": s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromVolume(s.m)), s.SnapshotCreate),
@@ -74,19 +117,32 @@ func NewRouter(s *Server) *mux.Router {
 		"snapshotDelete": s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromVolume(s.m)), s.SnapshotDelete),
 		"snapshotRevert": s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromVolume(s.m)), s.SnapshotRevert),
 		"snapshotBackup": s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromVolume(s.m)), s.SnapshotBackup),
+		"backupCompare":  s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromVolume(s.m)), s.BackupCompare),
+
+		"snapshotViewCreate": s.SnapshotViewCreate,
+		"snapshotViewList":   s.SnapshotViewList,
 
 		"pvCreate":  s.PVCreate,
 		"pvcCreate": s.PVCCreate,
 
-		"recurringJobAdd":    s.VolumeRecurringAdd,
-		"recurringJobList":   s.VolumeRecurringList,
-		"recurringJobDelete": s.VolumeRecurringDelete,
+		"recurringJobAdd":     s.VolumeRecurringAdd,
+		"recurringJobList":    s.VolumeRecurringList,
+		"recurringJobDelete":  s.VolumeRecurringDelete,
+		"recurringJobPreview": s.VolumeRecurringPreview,
+
+		"browseFileList": s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(BrowsingNodeIDFromVolume(s.m)), s.VolumeBrowseFileList),
 	}
 	for name, action := range volumeActions {
 		r.Methods("POST").Path("/v1/volumes/{name}").Queries("action", name).Handler(f(schemas, action))
+		r.Methods("POST").Path("/v1/tenants/{tenant}/volumes/{name}").Queries("action", name).Handler(f(schemas, s.withVolumeTenant(action)))
 	}
+	r.Methods("GET").Path("/v1/volumes/{name}/browse/download").Handler(f(schemas,
+		s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(BrowsingNodeIDFromVolume(s.m)), s.VolumeBrowseFileDownload)))
+	r.Methods("GET").Path("/v1/volumes/{name}/logs").Handler(f(schemas, s.VolumeLogs))
+	r.Methods("GET").Path("/v1/volumes/{name}/supportbundle").Handler(f(schemas, s.VolumeSupportBundleDownload))
 
 	r.Methods("GET").Path("/v1/backuptargets").Handler(f(schemas, s.BackupTargetList))
+	r.Methods("POST").Path("/v1/backuptargets").Queries("action", "backupTargetTest").Handler(f(schemas, s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(NodeHasDefaultEngineImage(s.m)), s.BackupTargetTest)))
 	r.Methods("GET").Path("/v1/backupvolumes").Handler(f(schemas, s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(NodeHasDefaultEngineImage(s.m)), s.BackupVolumeList)))
 	r.Methods("GET").Path("/v1/backupvolumes/{volName}").Handler(f(schemas, s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(NodeHasDefaultEngineImage(s.m)), s.BackupVolumeGet)))
 	r.Methods("DELETE").Path("/v1/backupvolumes/{volName}").Handler(f(schemas, s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(NodeHasDefaultEngineImage(s.m)), s.BackupVolumeDelete)))
@@ -94,17 +150,26 @@ func NewRouter(s *Server) *mux.Router {
 		"backupList":   s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(NodeHasDefaultEngineImage(s.m)), s.BackupList),
 		"backupGet":    s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(NodeHasDefaultEngineImage(s.m)), s.BackupGet),
 		"backupDelete": s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(NodeHasDefaultEngineImage(s.m)), s.BackupDelete),
+		"backupVerify": s.BackupVerify,
+		"backupBrowse": s.BackupBrowse,
 	}
 	for name, action := range backupActions {
 		r.Methods("POST").Path("/v1/backupvolumes/{volName}").Queries("action", name).Handler(f(schemas, action))
 	}
 
 	r.Methods("GET").Path("/v1/nodes").Handler(f(schemas, s.NodeList))
+	r.Methods("POST").Path("/v1/nodes").Queries("action", "clusterVerify").Handler(f(schemas, s.ClusterVerify))
+	r.Methods("POST").Path("/v1/nodes").Queries("action", "clusterVerificationStatus").Handler(f(schemas, s.ClusterVerificationStatus))
 	r.Methods("GET").Path("/v1/nodes/{name}").Handler(f(schemas, s.NodeGet))
 	r.Methods("PUT").Path("/v1/nodes/{name}").Handler(f(schemas, s.NodeUpdate))
 	r.Methods("DELETE").Path("/v1/nodes/{name}").Handler(f(schemas, s.NodeDelete))
 	nodeActions := map[string]func(http.ResponseWriter, *http.Request) error{
-		"diskUpdate": s.DiskUpdate,
+		"decommissionExecute": s.NodeDecommissionExecute,
+		"decommissionPlan":    s.NodeDecommissionPlan,
+		"diskUpdate":          s.DiskUpdate,
+		"replicaDataScan":     s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromNode(s.m)), s.ReplicaDataScan),
+		"verify":              s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromNode(s.m)), s.NodeVerify),
+		"workQueueStatus":     s.fwd.Handler(s.fwd.HandleProxyRequestByNodeID, s.fwd.GetHTTPAddressByNodeID(OwnerIDFromNode(s.m)), s.NodeWorkQueueStatus),
 	}
 	for name, action := range nodeActions {
 		r.Methods("POST").Path("/v1/nodes/{name}").Queries("action", name).Handler(f(schemas, action))
@@ -117,6 +182,9 @@ func NewRouter(s *Server) *mux.Router {
 
 	r.Methods("Get").Path("/v1/events").Handler(f(schemas, s.EventList))
 
+	r.Methods("GET").Path("/v1/upgradereport").Handler(f(schemas, s.UpgradeReportGet))
+	r.Methods("GET").Path("/v1/clustercapacity").Handler(f(schemas, s.ClusterCapacityGet))
+
 	r.Methods("GET").Path("/v1/disktags").Handler(f(schemas, s.DiskTagList))
 	r.Methods("GET").Path("/v1/nodetags").Handler(f(schemas, s.NodeTagList))
 
@@ -146,6 +214,12 @@ func NewRouter(s *Server) *mux.Router {
 	r.Methods("GET").Path("/v1/orphans").Handler(f(schemas, s.OrphanList))
 	r.Methods("GET").Path("/v1/orphans/{name}").Handler(f(schemas, s.OrphanGet))
 	r.Methods("DELETE").Path("/v1/orphans/{name}").Handler(f(schemas, s.OrphanDelete))
+	orphanActions := map[string]func(http.ResponseWriter, *http.Request) error{
+		"orphanRetain": s.OrphanRetain,
+	}
+	for name, action := range orphanActions {
+		r.Methods("POST").Path("/v1/orphans/{name}").Queries("action", name).Handler(f(schemas, action))
+	}
 
 	r.Methods("POST").Path("/v1/supportbundles").Handler(f(schemas, s.SupportBundleCreate))
 	r.Methods("GET").Path("/v1/supportbundles").Handler(f(schemas, s.SupportBundleList))
@@ -222,5 +296,34 @@ func NewRouter(s *Server) *mux.Router {
 	r.Path("/v1/ws/events").Handler(f(schemas, eventListStream))
 	r.Path("/v1/ws/{period}/events").Handler(f(schemas, eventListStream))
 
+	r.Path("/v1/ws/statechanges").Handler(f(schemas, NewStateChangeStreamHandlerFunc(s.wsc)))
+
+	r.Use(s.authMiddleware(schemas))
+	r.Use(s.drainMiddleware(schemas))
+
+	return r
+}
+
+// NewDockerPluginRouter returns the router of the Docker volume plugin. The
+// Docker daemon can't authenticate to the API, so it's served on a local unix
+// socket instead, and the access is limited by the permissions of the socket.
+func NewDockerPluginRouter(s *Server) *mux.Router {
+	r := mux.NewRouter().StrictSlash(true)
+
+	r.Methods("POST").Path("/Plugin.Activate").HandlerFunc(s.DockerPluginActivate)
+	r.Methods("POST").Path("/VolumeDriver.Capabilities").HandlerFunc(s.DockerVolumeCapabilities)
+	dockerVolumeActions := map[string]dockerVolumeHandler{
+		"Create":  s.DockerVolumeCreate,
+		"Remove":  s.DockerVolumeRemove,
+		"Mount":   s.DockerVolumeMount,
+		"Unmount": s.DockerVolumeUnmount,
+		"Path":    s.DockerVolumePath,
+		"Get":     s.DockerVolumeGet,
+		"List":    s.DockerVolumeList,
+	}
+	for name, action := range dockerVolumeActions {
+		r.Methods("POST").Path("/VolumeDriver." + name).Handler(s.dockerVolumeHandlerFunc(action))
+	}
+
 	return r
 }
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+func TestWithRequestID(t *testing.T) {
+	assert := require.New(t)
+
+	var requestID string
+	handler := withRequestID(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requestID = util.GetRequestID(req.Context())
+		// The forwarded request keeps the ID
+		assert.Equal(requestID, req.Header.Get(util.RequestIDHeader))
+	}))
+
+	// The request ID of the client is kept
+	req := httptest.NewRequest(http.MethodGet, "/v1/volumes", nil)
+	req.Header.Set(util.RequestIDHeader, "request-1")
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	assert.Equal("request-1", requestID)
+	assert.Equal("request-1", rw.Header().Get(util.RequestIDHeader))
+
+	// A request ID is generated if the client doesn't send one
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/v1/volumes", nil))
+	assert.NotEmpty(requestID)
+	assert.NotEqual("request-1", requestID)
+	assert.Equal(requestID, rw.Header().Get(util.RequestIDHeader))
+}
@@ -9,10 +9,13 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/rancher/go-rancher/api"
+	"github.com/rancher/go-rancher/client"
 
 	bsutil "github.com/longhorn/backupstore/util"
+	etypes "github.com/longhorn/longhorn-engine/pkg/types"
 
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/manager"
 	"github.com/longhorn/longhorn-manager/types"
 	"github.com/longhorn/longhorn-manager/util"
 )
@@ -39,7 +42,7 @@ func (s *Server) SnapshotCreate(w http.ResponseWriter, req *http.Request) (err e
 		return fmt.Errorf("cannot create snapshot for standby volume %v", vol.Name)
 	}
 
-	snapshot, err := s.m.CreateSnapshot(input.Name, input.Labels, volName)
+	snapshot, err := s.m.CreateSnapshot(req.Context(), input.Name, input.Labels, volName)
 	if err != nil {
 		return err
 	}
@@ -54,10 +57,29 @@ func (s *Server) SnapshotList(w http.ResponseWriter, req *http.Request) (err err
 
 	volName := mux.Vars(req)["name"]
 
-	snapList, err := s.m.ListSnapshotInfos(volName)
+	// The snapshots can be filtered by the creator, e.g.
+	// /v1/volumes/<name>?action=snapshotList&createdBy=user
+	createdBy := req.URL.Query().Get("createdBy")
+	if createdBy != "" && createdBy != SnapshotCreatedByUser && createdBy != SnapshotCreatedBySystem {
+		return types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "createdBy", types.ErrorParameterValue: createdBy},
+			"invalid snapshot creator %v, must be %v or %v", createdBy, SnapshotCreatedByUser, SnapshotCreatedBySystem)
+	}
+
+	snapList, err := s.m.ListSnapshotInfos(req.Context(), volName)
 	if err != nil {
 		return err
 	}
+	if createdBy != "" {
+		for name, snap := range snapList {
+			if name == etypes.VolumeHeadName {
+				continue
+			}
+			if isSystem := types.IsSystemSnapshot(snap.Name, snap.UserCreated, snap.Labels); isSystem != (createdBy == SnapshotCreatedBySystem) {
+				delete(snapList, name)
+			}
+		}
+	}
 
 	snapListRO, _ := s.m.ListSnapshots(volName)
 	api.GetApiContext(req).Write(toSnapshotCollection(snapList, snapListRO))
@@ -78,7 +100,7 @@ func (s *Server) SnapshotGet(w http.ResponseWriter, req *http.Request) (err erro
 	}
 	volName := mux.Vars(req)["name"]
 
-	snap, err := s.m.GetSnapshotInfo(input.Name, volName)
+	snap, err := s.m.GetSnapshotInfo(req.Context(), input.Name, volName)
 	if err != nil {
 		return err
 	}
@@ -115,7 +137,7 @@ func (s *Server) SnapshotDelete(w http.ResponseWriter, req *http.Request) (err e
 		return fmt.Errorf("cannot delete snapshot for standby volume %v", vol.Name)
 	}
 
-	if err := s.m.DeleteSnapshot(input.Name, volName); err != nil {
+	if err := s.m.DeleteSnapshot(req.Context(), input.Name, volName); err != nil {
 		return err
 	}
 	return s.responseWithVolume(w, req, volName, nil)
@@ -147,7 +169,7 @@ func (s *Server) SnapshotRevert(w http.ResponseWriter, req *http.Request) (err e
 		return fmt.Errorf("cannot revert snapshot for volume %v with frontend enabled", vol.Name)
 	}
 
-	if err := s.m.RevertSnapshot(input.Name, volName); err != nil {
+	if err := s.m.RevertSnapshot(req.Context(), input.Name, volName); err != nil {
 		return err
 	}
 
@@ -198,15 +220,84 @@ func (s *Server) SnapshotBackup(w http.ResponseWriter, req *http.Request) (err e
 	return s.responseWithVolume(w, req, volName, nil)
 }
 
+func (s *Server) BackupCompare(w http.ResponseWriter, req *http.Request) (err error) {
+	defer func() {
+		err = errors.Wrap(err, "failed to compare volume with backup")
+	}()
+
+	var input BackupInput
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return err
+	}
+	if input.Name == "" {
+		return types.NewReasonError(types.ErrorReasonInvalidParameter, map[string]string{types.ErrorParameterParameter: "name"},
+			"backup name is required")
+	}
+
+	volName := mux.Vars(req)["name"]
+
+	comparison, err := s.m.CompareVolumeWithBackup(req.Context(), volName, input.Name)
+	if err != nil {
+		return err
+	}
+	apiContext.Write(&VolumeBackupComparison{
+		Resource: client.Resource{
+			Id:   input.Name,
+			Type: "volumeBackupComparison",
+		},
+		VolumeBackupComparison: *comparison,
+	})
+	return nil
+}
+
 func (s *Server) SnapshotPurge(w http.ResponseWriter, req *http.Request) (err error) {
 	defer func() {
 		err = errors.Wrap(err, "failed to purge snapshot")
 	}()
 
 	volName := mux.Vars(req)["name"]
-	if err := s.m.PurgeSnapshot(volName); err != nil {
+	if err := s.m.PurgeSnapshot(req.Context(), volName); err != nil {
 		return err
 	}
 
 	return s.responseWithVolume(w, req, volName, nil)
 }
+
+func (s *Server) SnapshotViewCreate(rw http.ResponseWriter, req *http.Request) (err error) {
+	defer func() {
+		err = errors.Wrap(err, "failed to create snapshot view")
+	}()
+	var input SnapshotInput
+
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return err
+	}
+
+	volName := mux.Vars(req)["name"]
+
+	v, err := s.m.CreateSnapshotView(req.Context(), volName, input.Name)
+	if err != nil {
+		return err
+	}
+	return s.responseWithVolume(rw, req, v.Name, v)
+}
+
+func (s *Server) SnapshotViewList(rw http.ResponseWriter, req *http.Request) (err error) {
+	defer func() {
+		err = errors.Wrap(err, "failed to list snapshot views")
+	}()
+
+	apiContext := api.GetApiContext(req)
+	volName := mux.Vars(req)["name"]
+
+	resp, err := s.volumeListPage(apiContext, &manager.VolumeListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", types.GetLonghornLabelKey(types.LonghornLabelSnapshotViewOf), volName),
+	}, nil)
+	if err != nil {
+		return err
+	}
+	apiContext.Write(resp)
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -139,3 +140,65 @@ func getPeriod(r *http.Request) time.Duration {
 	}
 	return period
 }
+
+// NewStateChangeStreamHandlerFunc streams the state change events of the
+// volumes, replicas and nodes. The events can be filtered with the
+// comma-separated query parameters resources and names, e.g.
+// /v1/ws/statechanges?resources=volume,replica&names=vol-1
+func NewStateChangeStreamHandlerFunc(wsc *controller.WebsocketController) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var resources, names []string
+		if query := r.URL.Query().Get("resources"); query != "" {
+			resources = strings.Split(query, ",")
+		}
+		if query := r.URL.Query().Get("names"); query != "" {
+			names = strings.Split(query, ",")
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return err
+		}
+		fields := logrus.Fields{
+			"id":   strconv.Itoa(rand.Int()),
+			"type": "statechanges",
+		}
+		logrus.WithFields(fields).Debug("websocket: open")
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				_, _, err := conn.ReadMessage()
+				if err != nil {
+					logrus.WithFields(fields).Debug(err.Error())
+					return
+				}
+			}
+		}()
+
+		subscriber := wsc.SubscribeStateChanges(resources, names)
+		defer subscriber.Close()
+
+		keepAliveTicker := time.NewTicker(keepAlivePeriod)
+		defer keepAliveTicker.Stop()
+		for {
+			select {
+			case <-done:
+				return nil
+			case event, ok := <-subscriber.Events():
+				if !ok {
+					return nil
+				}
+				if err = conn.SetWriteDeadline(time.Now().Add(writeWait)); err == nil {
+					err = conn.WriteJSON(event)
+				}
+			case <-keepAliveTicker.C:
+				err = conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(writeWait))
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -3,7 +3,11 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -11,11 +15,17 @@ import (
 	"github.com/rancher/go-rancher/client"
 
 	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/types"
 	"github.com/longhorn/longhorn-manager/util"
 
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 )
 
+// HeaderIdempotencyKey identifies a volume create request, so that the
+// orchestrators can retry it without getting an AlreadyExists error
+const HeaderIdempotencyKey = "Idempotency-Key"
+
 func (s *Server) VolumeList(rw http.ResponseWriter, req *http.Request) (err error) {
 	defer func() {
 		err = errors.Wrap(err, "unable to list")
@@ -23,7 +33,11 @@ func (s *Server) VolumeList(rw http.ResponseWriter, req *http.Request) (err erro
 
 	apiContext := api.GetApiContext(req)
 
-	resp, err := s.volumeList(apiContext)
+	opts, fields, err := parseVolumeListQuery(req)
+	if err != nil {
+		return err
+	}
+	resp, err := s.volumeListPage(apiContext, opts, fields)
 	if err != nil {
 		return err
 	}
@@ -33,10 +47,46 @@ func (s *Server) VolumeList(rw http.ResponseWriter, req *http.Request) (err erro
 	return nil
 }
 
+// parseVolumeListQuery parses the paging, filtering and field selection
+// parameters of a volume list request, e.g.
+// /v1/volumes?limit=100&continue=<token>&state=attached&node=node-1&labelSelector=app=db&fields=state,size
+// The tenant is taken from the path of /v1/tenants/{tenant}/volumes, or the
+// tenant parameter otherwise.
+func parseVolumeListQuery(req *http.Request) (*manager.VolumeListOptions, []string, error) {
+	query := req.URL.Query()
+	opts := &manager.VolumeListOptions{
+		Continue:      query.Get("continue"),
+		State:         longhorn.VolumeState(query.Get("state")),
+		NodeID:        query.Get("node"),
+		LabelSelector: query.Get("labelSelector"),
+		Tenant:        query.Get("tenant"),
+	}
+	if tenant := mux.Vars(req)["tenant"]; tenant != "" {
+		opts.Tenant = tenant
+	}
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		if opts.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+				map[string]string{types.ErrorParameterParameter: "limit", types.ErrorParameterValue: limit},
+				"invalid limit %v", limit)
+		}
+	}
+	var fields []string
+	if query.Get("fields") != "" {
+		fields = strings.Split(query.Get("fields"), ",")
+	}
+	return opts, fields, nil
+}
+
 func (s *Server) volumeList(apiContext *api.ApiContext) (*client.GenericCollection, error) {
+	return s.volumeListPage(apiContext, &manager.VolumeListOptions{}, nil)
+}
+
+func (s *Server) volumeListPage(apiContext *api.ApiContext, opts *manager.VolumeListOptions, fields []string) (*client.GenericCollection, error) {
 	resp := &client.GenericCollection{}
 
-	volumes, err := s.m.ListSorted()
+	volumes, continueToken, err := s.m.ListPage(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -55,21 +105,79 @@ func (s *Server) volumeList(apiContext *api.ApiContext) (*client.GenericCollecti
 			return nil, err
 		}
 
-		resp.Data = append(resp.Data, toVolumeResource(v, controllers, replicas, backups, apiContext))
+		var resource interface{} = toVolumeResource(v, controllers, replicas, backups, apiContext)
+		if len(fields) != 0 {
+			if resource, err = selectResourceFields(resource, fields); err != nil {
+				return nil, err
+			}
+		}
+		resp.Data = append(resp.Data, resource)
 	}
 	resp.ResourceType = "volume"
 	resp.CreateTypes = map[string]string{
 		"volume": apiContext.UrlBuilder.Collection("volume"),
 	}
+	if opts.Limit > 0 {
+		limit := int64(opts.Limit)
+		resp.Pagination = &client.Pagination{
+			Marker:  opts.Continue,
+			Limit:   &limit,
+			Partial: continueToken != "",
+		}
+		if continueToken != "" {
+			next, err := url.Parse(apiContext.UrlBuilder.Current())
+			if err != nil {
+				return nil, err
+			}
+			query := next.Query()
+			query.Set("continue", continueToken)
+			next.RawQuery = query.Encode()
+			resp.Pagination.Next = next.String()
+		}
+	}
 
 	return resp, nil
 }
 
 func (s *Server) VolumeGet(rw http.ResponseWriter, req *http.Request) error {
 	id := mux.Vars(req)["name"]
+	if err := s.checkVolumeTenant(req, id); err != nil {
+		return err
+	}
 	return s.responseWithVolume(rw, req, id, nil)
 }
 
+// checkVolumeTenant checks if the volume belongs to the tenant in the path of
+// /v1/tenants/{tenant}/volumes/{name}. The volumes of the other tenants are
+// not found to the tenant.
+func (s *Server) checkVolumeTenant(req *http.Request, name string) error {
+	tenant := mux.Vars(req)["tenant"]
+	if tenant == "" {
+		return nil
+	}
+	v, err := s.m.Get(name)
+	if err != nil {
+		return err
+	}
+	if manager.GetVolumeTenant(v) != tenant {
+		return types.NewReasonError(types.ErrorReasonNotFound,
+			map[string]string{types.ErrorParameterKind: "volume", types.ErrorParameterName: name, types.ErrorParameterTenant: tenant},
+			"volume %v is not found in tenant %v", name, tenant)
+	}
+	return nil
+}
+
+// withVolumeTenant scopes the volume action to the tenant in the path of
+// /v1/tenants/{tenant}/volumes/{name}, before the request is forwarded.
+func (s *Server) withVolumeTenant(h HandleFuncWithError) HandleFuncWithError {
+	return func(rw http.ResponseWriter, req *http.Request) error {
+		if err := s.checkVolumeTenant(req, mux.Vars(req)["name"]); err != nil {
+			return err
+		}
+		return h(rw, req)
+	}
+}
+
 func (s *Server) responseWithVolume(rw http.ResponseWriter, req *http.Request, id string, v *longhorn.Volume) error {
 	var err error
 	apiContext := api.GetApiContext(req)
@@ -132,6 +240,15 @@ func (s *Server) VolumeCreate(rw http.ResponseWriter, req *http.Request) error {
 		return fmt.Errorf("failed to parse size %v", err)
 	}
 
+	if tenant := mux.Vars(req)["tenant"]; tenant != "" {
+		if volume.Tenant != "" && volume.Tenant != tenant {
+			return types.NewReasonError(types.ErrorReasonInvalidParameter,
+				map[string]string{types.ErrorParameterParameter: "tenant", types.ErrorParameterValue: volume.Tenant},
+				"cannot create volume %v of tenant %v in tenant %v", volume.Name, volume.Tenant, tenant)
+		}
+		volume.Tenant = tenant
+	}
+
 	// Check DiskSelector.
 	diskTags, err := s.m.GetDiskTags()
 	if err != nil {
@@ -156,7 +273,7 @@ func (s *Server) VolumeCreate(rw http.ResponseWriter, req *http.Request) error {
 		}
 	}
 
-	v, err := s.m.Create(volume.Name, &longhorn.VolumeSpec{
+	v, err := s.m.Create(req.Context(), volume.Name, &longhorn.VolumeSpec{
 		Size:                      size,
 		AccessMode:                volume.AccessMode,
 		Migratable:                volume.Migratable,
@@ -177,7 +294,16 @@ func (s *Server) VolumeCreate(rw http.ResponseWriter, req *http.Request) error {
 		SnapshotDataIntegrity:     volume.SnapshotDataIntegrity,
 		BackupCompressionMethod:   volume.BackupCompressionMethod,
 		UnmapMarkSnapChainRemoved: volume.UnmapMarkSnapChainRemoved,
-	}, volume.RecurringJobSelector)
+		ExpireAt:                  volume.ExpireAt,
+		RebuildBandwidthLimit:     volume.RebuildBandwidthLimit,
+		FrontendIOPSLimit:         volume.FrontendIOPSLimit,
+		FrontendBandwidthLimit:    volume.FrontendBandwidthLimit,
+		SnapshotMaxCount:          volume.SnapshotMaxCount,
+		SnapshotMaxAge:            volume.SnapshotMaxAge,
+		FilesystemType:            volume.FilesystemType,
+		Filesystem:                volume.Filesystem,
+		MountOptions:              volume.MountOptions,
+	}, volume.RecurringJobSelector, volume.Labels, volume.Tenant, req.Header.Get(HeaderIdempotencyKey))
 	if err != nil {
 		return errors.Wrap(err, "unable to create volume")
 	}
@@ -186,8 +312,11 @@ func (s *Server) VolumeCreate(rw http.ResponseWriter, req *http.Request) error {
 
 func (s *Server) VolumeDelete(rw http.ResponseWriter, req *http.Request) error {
 	id := mux.Vars(req)["name"]
+	if err := s.checkVolumeTenant(req, id); err != nil {
+		return err
+	}
 
-	if err := s.m.Delete(id); err != nil {
+	if err := s.m.Delete(req.Context(), id); err != nil {
 		return errors.Wrap(err, "unable to delete volume")
 	}
 
@@ -204,7 +333,7 @@ func (s *Server) VolumeAttach(rw http.ResponseWriter, req *http.Request) error {
 	id := mux.Vars(req)["name"]
 
 	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
-		return s.m.Attach(id, input.HostID, input.DisableFrontend, input.AttachedBy)
+		return s.m.Attach(req.Context(), id, input.HostID, input.DisableFrontend, input.AttachedBy)
 	})
 	if err != nil {
 		return err
@@ -229,7 +358,7 @@ func (s *Server) VolumeDetach(rw http.ResponseWriter, req *http.Request) error {
 	id := mux.Vars(req)["name"]
 
 	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
-		return s.m.Detach(id, input.HostID)
+		return s.m.Detach(req.Context(), id, input.HostID, input.Force)
 	})
 	if err != nil {
 		return err
@@ -253,7 +382,7 @@ func (s *Server) VolumeSalvage(rw http.ResponseWriter, req *http.Request) error
 	id := mux.Vars(req)["name"]
 
 	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
-		return s.m.Salvage(id, input.Names)
+		return s.m.Salvage(req.Context(), id, input.Names)
 	})
 	if err != nil {
 		return err
@@ -304,6 +433,35 @@ func (s *Server) VolumeRecurringList(w http.ResponseWriter, req *http.Request) (
 	return nil
 }
 
+func (s *Server) VolumeRecurringPreview(w http.ResponseWriter, req *http.Request) (err error) {
+	defer func() {
+		err = errors.Wrap(err, "failed to preview volume recurring jobs")
+	}()
+
+	var input VolumeRecurringJobPreviewInput
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return errors.Wrapf(err, "error reading volumeRecurringJobPreviewInput")
+	}
+	if input.Count == 0 {
+		input.Count = manager.DefaultRecurringJobPreviewCount
+	}
+	if input.Count < 0 || input.Count > util.MaxCronRunTimes {
+		return types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "count", types.ErrorParameterValue: strconv.Itoa(input.Count)},
+			"count should be between 0 and %v", util.MaxCronRunTimes)
+	}
+
+	volName := mux.Vars(req)["name"]
+
+	previews, err := s.m.PreviewVolumeRecurringJobs(volName, input.Count)
+	if err != nil {
+		return err
+	}
+	apiContext.Write(toVolumeRecurringJobPreviewCollection(previews))
+	return nil
+}
+
 func (s *Server) VolumeRecurringDelete(rw http.ResponseWriter, req *http.Request) error {
 	var input VolumeRecurringJobInput
 	volName := mux.Vars(req)["name"]
@@ -396,6 +554,241 @@ func (s *Server) VolumeUpdateBackupCompressionMethod(rw http.ResponseWriter, req
 	return s.responseWithVolume(rw, req, "", v)
 }
 
+func (s *Server) VolumeUpdateExpiry(rw http.ResponseWriter, req *http.Request) error {
+	var input UpdateExpiryInput
+	id := mux.Vars(req)["name"]
+
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return errors.Wrapf(err, "error reading expiry")
+	}
+
+	expireAt := input.ExpireAt
+	if input.TTL != "" {
+		if expireAt != "" {
+			return fmt.Errorf("cannot specify both expireAt and ttl")
+		}
+		ttl, err := time.ParseDuration(input.TTL)
+		if err != nil {
+			return errors.Wrapf(err, "invalid ttl %v", input.TTL)
+		}
+		expireAt = time.Now().Add(ttl).UTC().Format(time.RFC3339)
+	}
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.UpdateExpireAt(id, expireAt)
+	})
+	if err != nil {
+		return err
+	}
+	v, ok := obj.(*longhorn.Volume)
+	if !ok {
+		return fmt.Errorf("BUG: cannot convert to volume %v object", id)
+	}
+
+	return s.responseWithVolume(rw, req, "", v)
+}
+
+func (s *Server) VolumeUpdateQoS(rw http.ResponseWriter, req *http.Request) error {
+	var input UpdateQoSInput
+	id := mux.Vars(req)["name"]
+
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return errors.Wrapf(err, "error reading QoS")
+	}
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.UpdateQoS(id, input.RebuildBandwidthLimit, input.FrontendIOPSLimit, input.FrontendBandwidthLimit)
+	})
+	if err != nil {
+		return err
+	}
+	v, ok := obj.(*longhorn.Volume)
+	if !ok {
+		return fmt.Errorf("BUG: cannot convert to volume %v object", id)
+	}
+
+	return s.responseWithVolume(rw, req, "", v)
+}
+
+func (s *Server) VolumeUpdateSnapshotLimits(rw http.ResponseWriter, req *http.Request) error {
+	var input UpdateSnapshotLimitsInput
+	id := mux.Vars(req)["name"]
+
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return errors.Wrapf(err, "error reading snapshot limits")
+	}
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.UpdateSnapshotLimits(id, input.SnapshotMaxCount, input.SnapshotMaxAge)
+	})
+	if err != nil {
+		return err
+	}
+	v, ok := obj.(*longhorn.Volume)
+	if !ok {
+		return fmt.Errorf("BUG: cannot convert to volume %v object", id)
+	}
+
+	return s.responseWithVolume(rw, req, "", v)
+}
+
+// VolumeReplicaList lists the replicas as seen by the running engine of the
+// volume. The request is served by the volume owner, so the repeated requests
+// share the engine status cache of the owner.
+func (s *Server) VolumeReplicaList(rw http.ResponseWriter, req *http.Request) error {
+	id := mux.Vars(req)["name"]
+
+	replicas, err := s.m.GetEngineReplicaList(req.Context(), id)
+	if err != nil {
+		return err
+	}
+	api.GetApiContext(req).Write(toEngineReplicaCollection(replicas))
+	return nil
+}
+
+func (s *Server) VolumeControllerInfo(rw http.ResponseWriter, req *http.Request) error {
+	id := mux.Vars(req)["name"]
+
+	info, err := s.m.GetEngineInfo(req.Context(), id)
+	if err != nil {
+		return err
+	}
+	api.GetApiContext(req).Write(toEngineInfoResource(id, info))
+	return nil
+}
+
+func (s *Server) VolumeSetReadOnly(rw http.ResponseWriter, req *http.Request) error {
+	var input SetReadOnlyInput
+	id := mux.Vars(req)["name"]
+
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return errors.Wrapf(err, "error reading setReadOnlyInput")
+	}
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.SetReadOnly(id, input.ReadOnly)
+	})
+	if err != nil {
+		return err
+	}
+	v, ok := obj.(*longhorn.Volume)
+	if !ok {
+		return fmt.Errorf("BUG: cannot convert to volume %v object", id)
+	}
+
+	return s.responseWithVolume(rw, req, "", v)
+}
+
+func (s *Server) VolumeUpdateLabels(rw http.ResponseWriter, req *http.Request) error {
+	var input UpdateLabelsInput
+	id := mux.Vars(req)["name"]
+
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return errors.Wrapf(err, "error reading labels")
+	}
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.UpdateLabels(id, input.Labels)
+	})
+	if err != nil {
+		return err
+	}
+	v, ok := obj.(*longhorn.Volume)
+	if !ok {
+		return fmt.Errorf("BUG: cannot convert to volume %v object", id)
+	}
+
+	return s.responseWithVolume(rw, req, "", v)
+}
+
+func (s *Server) VolumeImport(rw http.ResponseWriter, req *http.Request) error {
+	var input VolumeImportInput
+
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return errors.Wrapf(err, "error reading volume import input")
+	}
+	if input.Frontend == "" {
+		input.Frontend = longhorn.VolumeFrontendBlockDev
+	}
+
+	v, err := s.m.Import(req.Context(), input.Name, input.Orphans, &longhorn.VolumeSpec{
+		AccessMode:          input.AccessMode,
+		Frontend:            input.Frontend,
+		NumberOfReplicas:    input.NumberOfReplicas,
+		ReplicaAutoBalance:  input.ReplicaAutoBalance,
+		DataLocality:        input.DataLocality,
+		StaleReplicaTimeout: input.StaleReplicaTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	return s.responseWithVolume(rw, req, "", v)
+}
+
+func (s *Server) VolumeBulkAction(rw http.ResponseWriter, req *http.Request) error {
+	var input VolumeBulkActionInput
+
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return errors.Wrapf(err, "error reading bulk action input")
+	}
+
+	results, err := s.m.BulkAction(req.Context(), input.Action, input.LabelSelector)
+	if err != nil {
+		return err
+	}
+
+	output := &VolumeBulkActionOutput{
+		Data: []manager.VolumeBulkActionResult{},
+		Type: "volumeBulkActionOutput",
+	}
+	for _, result := range results {
+		output.Data = append(output.Data, *result)
+	}
+	apiContext.Write(output)
+	return nil
+}
+
+func (s *Server) VolumeDuplicateReport(rw http.ResponseWriter, req *http.Request) error {
+	apiContext := api.GetApiContext(req)
+
+	report, err := s.m.GetDuplicateVolumeReport()
+	if err != nil {
+		return err
+	}
+	apiContext.Write(&DuplicateVolumeReport{
+		Resource: client.Resource{
+			Type: "duplicateVolumeReport",
+		},
+		DuplicateVolumeReport: *report,
+	})
+	return nil
+}
+
+func (s *Server) TenantGet(rw http.ResponseWriter, req *http.Request) error {
+	apiContext := api.GetApiContext(req)
+	tenant := mux.Vars(req)["tenant"]
+
+	usage, err := s.m.GetTenantUsage(tenant)
+	if err != nil {
+		return err
+	}
+	apiContext.Write(&TenantUsage{
+		Resource: client.Resource{
+			Id:   tenant,
+			Type: "tenantUsage",
+		},
+		TenantUsage: *usage,
+	})
+	return nil
+}
+
 func (s *Server) VolumeUpdateReplicaAutoBalance(rw http.ResponseWriter, req *http.Request) error {
 	var input UpdateReplicaAutoBalanceInput
 	id := mux.Vars(req)["name"]
@@ -419,6 +812,29 @@ func (s *Server) VolumeUpdateReplicaAutoBalance(rw http.ResponseWriter, req *htt
 	return s.responseWithVolume(rw, req, "", v)
 }
 
+func (s *Server) VolumeUpdateStaleReplicaTimeout(rw http.ResponseWriter, req *http.Request) error {
+	var input UpdateStaleReplicaTimeoutInput
+	id := mux.Vars(req)["name"]
+
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return errors.Wrapf(err, "error reading staleReplicaTimeout")
+	}
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.UpdateStaleReplicaTimeout(id, input.StaleReplicaTimeout)
+	})
+	if err != nil {
+		return err
+	}
+	v, ok := obj.(*longhorn.Volume)
+	if !ok {
+		return fmt.Errorf("BUG: cannot convert to volume %v object", id)
+	}
+
+	return s.responseWithVolume(rw, req, "", v)
+}
+
 func (s *Server) VolumeUpdateDataLocality(rw http.ResponseWriter, req *http.Request) error {
 	var input UpdateDataLocalityInput
 	id := mux.Vars(req)["name"]
@@ -496,7 +912,7 @@ func (s *Server) VolumeActivate(rw http.ResponseWriter, req *http.Request) error
 	id := mux.Vars(req)["name"]
 
 	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
-		return s.m.Activate(id, input.Frontend)
+		return s.m.Activate(req.Context(), id, input.Frontend)
 	})
 	if err != nil {
 		return err
@@ -533,7 +949,7 @@ func (s *Server) VolumeExpand(rw http.ResponseWriter, req *http.Request) error {
 	}
 
 	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
-		return s.m.Expand(id, size)
+		return s.m.Expand(req.Context(), id, size)
 	})
 	if err != nil {
 		return err
@@ -580,6 +996,121 @@ func (s *Server) VolumeFilesystemTrim(rw http.ResponseWriter, req *http.Request)
 	return s.responseWithVolume(rw, req, "", v)
 }
 
+func (s *Server) VolumeFreeze(rw http.ResponseWriter, req *http.Request) error {
+	var input FreezeInput
+
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return errors.Wrapf(err, "error reading freezeInput")
+	}
+
+	id := mux.Vars(req)["name"]
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.Freeze(id, time.Duration(input.Timeout)*time.Second)
+	})
+	if err != nil {
+		return err
+	}
+	v, ok := obj.(*longhorn.Volume)
+	if !ok {
+		return fmt.Errorf("BUG: cannot convert to volume %v object", id)
+	}
+
+	return s.responseWithVolume(rw, req, "", v)
+}
+
+func (s *Server) VolumeThaw(rw http.ResponseWriter, req *http.Request) error {
+	id := mux.Vars(req)["name"]
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.Thaw(id)
+	})
+	if err != nil {
+		return err
+	}
+	v, ok := obj.(*longhorn.Volume)
+	if !ok {
+		return fmt.Errorf("BUG: cannot convert to volume %v object", id)
+	}
+
+	return s.responseWithVolume(rw, req, "", v)
+}
+
+func (s *Server) VolumeFilesystemCheck(rw http.ResponseWriter, req *http.Request) error {
+	id := mux.Vars(req)["name"]
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.CheckFilesystem(req.Context(), id)
+	})
+	if err != nil {
+		return err
+	}
+	v, ok := obj.(*longhorn.Volume)
+	if !ok {
+		return fmt.Errorf("BUG: cannot convert to volume %v object", id)
+	}
+
+	return s.responseWithVolume(rw, req, "", v)
+}
+
+func (s *Server) VolumeMigrationStart(rw http.ResponseWriter, req *http.Request) error {
+	var input MigrationStartInput
+
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return errors.Wrapf(err, "error reading migrationStartInput")
+	}
+	id := mux.Vars(req)["name"]
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.MigrationStart(req.Context(), id, input.NodeID)
+	})
+	if err != nil {
+		return err
+	}
+	v, ok := obj.(*longhorn.Volume)
+	if !ok {
+		return fmt.Errorf("BUG: cannot convert to volume %v object", id)
+	}
+
+	return s.responseWithVolume(rw, req, "", v)
+}
+
+func (s *Server) VolumeMigrationConfirm(rw http.ResponseWriter, req *http.Request) error {
+	id := mux.Vars(req)["name"]
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.MigrationConfirm(req.Context(), id)
+	})
+	if err != nil {
+		return err
+	}
+	v, ok := obj.(*longhorn.Volume)
+	if !ok {
+		return fmt.Errorf("BUG: cannot convert to volume %v object", id)
+	}
+
+	return s.responseWithVolume(rw, req, "", v)
+}
+
+func (s *Server) VolumeMigrationRollback(rw http.ResponseWriter, req *http.Request) error {
+	id := mux.Vars(req)["name"]
+
+	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		return s.m.MigrationRollback(req.Context(), id)
+	})
+	if err != nil {
+		return err
+	}
+	v, ok := obj.(*longhorn.Volume)
+	if !ok {
+		return fmt.Errorf("BUG: cannot convert to volume %v object", id)
+	}
+
+	return s.responseWithVolume(rw, req, "", v)
+}
+
 func (s *Server) PVCreate(rw http.ResponseWriter, req *http.Request) error {
 	var input PVCreateInput
 	id := mux.Vars(req)["name"]
@@ -651,6 +1182,49 @@ func (s *Server) ReplicaRemove(rw http.ResponseWriter, req *http.Request) error
 	return s.responseWithVolume(rw, req, id, nil)
 }
 
+func (s *Server) ReplicaEvict(rw http.ResponseWriter, req *http.Request) error {
+	var input ReplicaEvictInput
+
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return errors.Wrapf(err, "error read replicaEvictInput")
+	}
+
+	id := mux.Vars(req)["name"]
+
+	if err := s.m.EvictReplica(id, input.Name); err != nil {
+		return errors.Wrap(err, "unable to evict replica")
+	}
+
+	return s.responseWithVolume(rw, req, id, nil)
+}
+
+// ReplicaScheduleExplain is forwarded to the volume owner, since the traces
+// are recorded by the volume controller there.
+func (s *Server) ReplicaScheduleExplain(rw http.ResponseWriter, req *http.Request) error {
+	var input ReplicaScheduleExplainInput
+
+	apiContext := api.GetApiContext(req)
+	if err := apiContext.Read(&input); err != nil {
+		return errors.Wrapf(err, "error read replicaScheduleExplainInput")
+	}
+
+	id := mux.Vars(req)["name"]
+
+	trace, err := s.m.ScheduleExplain(id, input.Name)
+	if err != nil {
+		return errors.Wrap(err, "unable to explain replica scheduling")
+	}
+	apiContext.Write(&ScheduleTrace{
+		Resource: client.Resource{
+			Id:   input.Name,
+			Type: "scheduleTrace",
+		},
+		ScheduleTrace: *trace,
+	})
+	return nil
+}
+
 func (s *Server) EngineUpgrade(rw http.ResponseWriter, req *http.Request) error {
 	var input EngineUpgradeInput
 
@@ -662,7 +1236,7 @@ func (s *Server) EngineUpgrade(rw http.ResponseWriter, req *http.Request) error
 	id := mux.Vars(req)["name"]
 
 	obj, err := util.RetryOnConflictCause(func() (interface{}, error) {
-		return s.m.EngineUpgrade(id, input.Image)
+		return s.m.EngineUpgrade(req.Context(), id, input.Image)
 	})
 	if err != nil {
 		return err
@@ -674,3 +1248,55 @@ func (s *Server) EngineUpgrade(rw http.ResponseWriter, req *http.Request) error
 
 	return s.responseWithVolume(rw, req, id, v)
 }
+
+func (s *Server) VolumeLogs(rw http.ResponseWriter, req *http.Request) (err error) {
+	defer func() {
+		err = errors.Wrap(err, "failed to get volume logs")
+	}()
+
+	query := req.URL.Query()
+	since, err := parseLogSince(query.Get("since"))
+	if err != nil {
+		return err
+	}
+	tail := 0
+	if value := query.Get("tail"); value != "" {
+		if tail, err = strconv.Atoi(value); err != nil || tail < 0 {
+			return types.NewReasonError(types.ErrorReasonInvalidParameter,
+				map[string]string{types.ErrorParameterParameter: "tail", types.ErrorParameterValue: value},
+				"invalid tail %v", value)
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	return s.m.VolumeLogs(req.Context(), rw, mux.Vars(req)["name"], query.Get("component"), since, tail)
+}
+
+// parseLogSince accepts either a duration before now, e.g. 1h, or a time in
+// RFC3339 format.
+func parseLogSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "since", types.ErrorParameterValue: value},
+			"invalid since %v, expecting a duration or a time in RFC3339 format", value)
+	}
+	return t, nil
+}
+
+func (s *Server) VolumeSupportBundleDownload(rw http.ResponseWriter, req *http.Request) error {
+	name := mux.Vars(req)["name"]
+
+	rw.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(name+"-supportbundle.tar.gz"))
+	rw.Header().Set("Content-Type", "application/gzip")
+	if err := s.m.VolumeSupportBundle(req.Context(), rw, name); err != nil {
+		return errors.Wrap(err, "failed to download volume support bundle")
+	}
+	return nil
+}
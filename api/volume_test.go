@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/controller"
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func TestTenantVolumeAction(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	v := &longhorn.Volume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vol",
+			Namespace: testNamespace,
+			Labels:    map[string]string{types.GetLonghornLabelKey(types.LonghornLabelTenant): "team-a"},
+		},
+	}
+	c, err := fake.NewCluster(testNamespace, stopCh, v)
+	assert.NoError(err)
+	s := &Server{
+		m:   c.NewVolumeManager(testNode),
+		wsc: controller.NewWebsocketController(logrus.StandardLogger(), c.DataStore),
+		fwd: NewFwd(&fakeNodeLocator{
+			currentNodeID: testNode,
+			nodeIPs:       map[string]string{testNode: testManagerIP1},
+		}),
+	}
+	r := NewRouter(s)
+
+	tests := map[string]struct {
+		path         string
+		expectedCode int
+	}{
+		"cluster wide":                 {"/v1/volumes/vol?action=recurringJobList", http.StatusOK},
+		"same tenant":                  {"/v1/tenants/team-a/volumes/vol?action=recurringJobList", http.StatusOK},
+		"another tenant":               {"/v1/tenants/team-b/volumes/vol?action=recurringJobList", http.StatusNotFound},
+		"another tenant, other action": {"/v1/tenants/team-b/volumes/vol?action=updateLabels", http.StatusNotFound},
+		"volume not found":             {"/v1/tenants/team-a/volumes/missing?action=recurringJobList", http.StatusNotFound},
+	}
+	for name, test := range tests {
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, test.path, nil))
+		assert.Equal(test.expectedCode, rw.Code, "%v: %v", name, rw.Body.String())
+	}
+}
@@ -1,13 +1,19 @@
 package app
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 
 	_ "net/http/pprof" // for runtime profiling
 
 	"github.com/gorilla/handlers"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 
@@ -34,6 +40,12 @@ const (
 	FlagSupportBundleManagerImage = "support-bundle-manager-image"
 	FlagServiceAccount            = "service-account"
 	FlagKubeConfig                = "kube-config"
+	FlagTLSCertFile               = "tls-cert-file"
+	FlagTLSKeyFile                = "tls-key-file"
+	FlagTLSCAFile                 = "tls-ca-file"
+	FlagTLSPeerServerName         = "tls-peer-server-name"
+	FlagShutdownTimeout           = "shutdown-timeout"
+	FlagDockerPluginSocket        = "docker-plugin-socket"
 )
 
 func DaemonCmd() cli.Command {
@@ -72,6 +84,32 @@ func DaemonCmd() cli.Command {
 				Name:  FlagKubeConfig,
 				Usage: "Specify path to kube config (optional)",
 			},
+			cli.StringFlag{
+				Name:  FlagTLSCertFile,
+				Usage: "Specify the certificate file to serve the API over TLS, reloaded once modified (optional)",
+			},
+			cli.StringFlag{
+				Name:  FlagTLSKeyFile,
+				Usage: "Specify the key file of the TLS certificate",
+			},
+			cli.StringFlag{
+				Name:  FlagTLSCAFile,
+				Usage: "Specify the CA file to verify the other managers and the client certificates, the system CAs are used to verify the managers if it's empty (optional)",
+			},
+			cli.StringFlag{
+				Name:  FlagTLSPeerServerName,
+				Usage: "Specify the name verified against the SANs of the other managers' certificates",
+				Value: "longhorn-backend",
+			},
+			cli.DurationFlag{
+				Name:  FlagShutdownTimeout,
+				Usage: "Specify the time to drain the in-flight requests and engine connections on shutdown, should be less than the termination grace period of the pod",
+				Value: 20 * time.Second,
+			},
+			cli.StringFlag{
+				Name:  FlagDockerPluginSocket,
+				Usage: "Specify the unix socket to serve the Docker volume plugin on, e.g. /run/docker/plugins/longhorn.sock (optional)",
+			},
 		},
 		Action: func(c *cli.Context) {
 			if err := startManager(c); err != nil {
@@ -140,16 +178,22 @@ func startManager(c *cli.Context) error {
 		return err
 	}
 
+	tlsConfig, peerTLSConfig, err := getAPITLSConfig(c)
+	if err != nil {
+		return err
+	}
+	managerURL := types.GetManagerURL(tlsConfig != nil)
+
 	proxyConnCounter := util.NewAtomicCounter()
 
-	ds, wsc, err := controller.StartControllers(logger, done, currentNodeID, serviceAccount, managerImage, kubeconfigPath, meta.Version, proxyConnCounter)
+	ds, wsc, err := controller.StartControllers(logger, done, currentNodeID, serviceAccount, managerImage, managerURL, kubeconfigPath, meta.Version, proxyConnCounter)
 	if err != nil {
 		return err
 	}
 
 	m := manager.NewVolumeManager(currentNodeID, ds, proxyConnCounter)
 
-	metricsCollector.InitMetricsCollectorSystem(logger, currentNodeID, ds, kubeconfigPath, proxyConnCounter)
+	metricsCollector.InitMetricsCollectorSystem(logger, currentNodeID, ds, m, kubeconfigPath, proxyConnCounter)
 
 	defaultImageSettings := map[types.SettingName]string{
 		types.SettingNameDefaultEngineImage:              engineImage,
@@ -186,11 +230,32 @@ func startManager(c *cli.Context) error {
 		"/v1/events":       {},
 	}, os.Stdout, router)
 	router = handlers.ProxyHeaders(router)
+	router = api.PeerIPHandler(router)
 
 	listen := types.GetAPIServerAddressFromIP(currentIP)
-	logger.Infof("Listening on %s", listen)
+	apiServer := &http.Server{
+		Addr:    listen,
+		Handler: router,
+	}
+	if tlsConfig != nil {
+		apiServer.TLSConfig = tlsConfig
+		server.EnableTLS(peerTLSConfig)
+		logger.Infof("Listening on %s with TLS", listen)
+		go apiServer.ListenAndServeTLS("", "")
+	} else {
+		logger.Infof("Listening on %s", listen)
+		go apiServer.ListenAndServe()
+	}
+	apiServers := []*http.Server{apiServer}
 
-	go http.ListenAndServe(listen, router)
+	if socketPath := c.String(FlagDockerPluginSocket); socketPath != "" {
+		pluginServer, err := serveDockerPlugin(server, socketPath)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Serving the Docker volume plugin on %s", socketPath)
+		apiServers = append(apiServers, pluginServer)
+	}
 
 	go func() {
 		debugAddress := "127.0.0.1:6060"
@@ -201,11 +266,108 @@ func startManager(c *cli.Context) error {
 		}
 	}()
 
-	util.RegisterShutdownChannel(done)
-	<-done
+	shutdownCh := make(chan struct{})
+	util.RegisterShutdownChannel(shutdownCh)
+	<-shutdownCh
+	shutdown(logger, server, apiServers, done, proxyConnCounter, c.Duration(FlagShutdownTimeout))
 	return nil
 }
 
+// serveDockerPlugin serves the Docker volume plugin on the unix socket, which
+// is only accessible by root since the requests aren't authenticated.
+func serveDockerPlugin(server *api.Server, socketPath string) (*http.Server, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0755); err != nil {
+		return nil, err
+	}
+	// The socket is left behind if the manager wasn't shut down cleanly
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to remove the stale socket %v", socketPath)
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	pluginServer := &http.Server{
+		Handler: api.NewDockerPluginRouter(server),
+	}
+	go pluginServer.Serve(listener)
+	return pluginServer, nil
+}
+
+// shutdown stops taking the changes from the API first, then stops the
+// controllers once the in-flight requests are done, and waits for the engine
+// connections to be closed, within the timeout. The rebuilds and backups are
+// run by the engines and tracked by the custom resources, so they continue
+// and are picked up by the manager after restarting.
+func shutdown(logger logrus.FieldLogger, server *api.Server, apiServers []*http.Server, done chan struct{}, proxyConnCounter util.Counter, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	logger.Infof("Shutting down with timeout %v", timeout)
+	server.Drain()
+	for _, apiServer := range apiServers {
+		if err := apiServer.Shutdown(ctx); err != nil {
+			logger.WithError(err).Warn("Failed to wait for the in-flight API requests on shutdown")
+		}
+	}
+
+	close(done)
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for proxyConnCounter.GetCount() > 0 {
+		select {
+		case <-ctx.Done():
+			logger.Warnf("Exiting with %v engine proxy connections open", proxyConnCounter.GetCount())
+			return
+		case <-ticker.C:
+		}
+	}
+	logger.Info("Shut down")
+}
+
+// getAPITLSConfig returns the TLS config to serve the API, and the one to
+// forward requests to the other managers, or nil if TLS is not enabled.
+func getAPITLSConfig(c *cli.Context) (*tls.Config, *tls.Config, error) {
+	certFile, keyFile := c.String(FlagTLSCertFile), c.String(FlagTLSKeyFile)
+	if certFile == "" && keyFile == "" {
+		return nil, nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, nil, fmt.Errorf("require both %v and %v to enable TLS", FlagTLSCertFile, FlagTLSKeyFile)
+	}
+	reloader, err := util.NewCertificateReloader(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	peerConfig := &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		ServerName:           c.String(FlagTLSPeerServerName),
+		GetClientCertificate: reloader.GetClientCertificate,
+	}
+	if caFile := c.String(FlagTLSCAFile); caFile != "" {
+		pool, err := util.LoadCertPool(caFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The clients can still authenticate with a token instead
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		config.ClientCAs = pool
+		peerConfig.RootCAs = pool
+	}
+	return config, peerConfig, nil
+}
+
 func environmentCheck() error {
 	initiatorNSPath := iscsiutil.GetHostNamespacePath(util.HostProcPath)
 	namespace, err := iscsiutil.NewNamespaceExecutor(initiatorNSPath)
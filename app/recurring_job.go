@@ -164,7 +164,7 @@ func recurringJob(c *cli.Context) error {
 			})
 			log.Info("Creating job")
 
-			snapshotName := sliceStringSafely(types.GetCronJobNameForRecurringJob(jobName), 0, 8) + "-" + util.UUID()
+			snapshotName := types.GetRecurringJobSnapshotNamePrefix(jobName) + util.UUID()
 			job, err := NewJob(
 				logger,
 				managerURL,
@@ -190,16 +190,6 @@ func recurringJob(c *cli.Context) error {
 	return nil
 }
 
-func sliceStringSafely(s string, begin, end int) string {
-	if begin < 0 {
-		begin = 0
-	}
-	if end > len(s) {
-		end = len(s)
-	}
-	return s[begin:end]
-}
-
 func NewJob(logger logrus.FieldLogger, managerURL, volumeName, snapshotName string, labels map[string]string, retain int, task longhorn.RecurringJobType) (*Job, error) {
 	namespace := os.Getenv(types.EnvPodNamespace)
 	if namespace == "" {
@@ -216,8 +206,10 @@ func NewJob(logger logrus.FieldLogger, managerURL, volumeName, snapshotName stri
 	}
 
 	clientOpts := &longhornclient.ClientOpts{
-		Url:     managerURL,
-		Timeout: HTTPClientTimout,
+		Url:       managerURL,
+		SecretKey: types.GetAPIToken(),
+		CACerts:   types.GetAPICACerts(),
+		Timeout:   HTTPClientTimout,
 	}
 	apiClient, err := longhornclient.NewRancherClient(clientOpts)
 	if err != nil {
@@ -326,6 +318,13 @@ func (job *Job) run() (err error) {
 		return fmt.Errorf("cannot run job for volume %v that is using %v engines", volume.Name, len(volume.Controllers))
 	}
 
+	// The filesystem is only mounted by the workload, so the volume is not
+	// attached automatically for the trim
+	if job.task == longhorn.RecurringJobTypeFilesystemTrim {
+		job.logger.Infof("Running recurring filesystem trim for volume %v", volumeName)
+		return job.doRecurringFilesystemTrim(volume)
+	}
+
 	defer job.handleVolumeDetachment()
 
 	if volume.State != string(longhorn.VolumeStateAttached) && volume.State != string(longhorn.VolumeStateDetached) {
@@ -396,6 +395,26 @@ func (job *Job) doRecurringSnapshot() (err error) {
 	}
 }
 
+// doRecurringFilesystemTrim discards the blocks of the deleted files. If the
+// volume enables UnmapMarkSnapChainRemoved, the engine also reclaims the
+// space of these blocks in the snapshots marked as removed.
+func (job *Job) doRecurringFilesystemTrim(volume *longhornclient.Volume) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed recurring filesystem trim")
+		if err == nil {
+			job.logger.Info("Finished recurring filesystem trim")
+		}
+	}()
+
+	if volume.State != string(longhorn.VolumeStateAttached) || volume.DisableFrontend {
+		job.logger.Infof("Skipped filesystem trim since volume %v is not attached to a workload", volume.Name)
+		return nil
+	}
+
+	_, err = job.api.Volume.ActionTrimFilesystem(volume)
+	return err
+}
+
 func (job *Job) doSnapshot() (err error) {
 	volumeAPI := job.api.Volume
 	volumeName := job.volumeName
@@ -528,8 +547,13 @@ func (job *Job) filterExpiredSnapshotsOfCurrentRecurringJob(snapshots []longhorn
 		return []string{}
 	}
 
-	// Only consider deleting the snapshots that were created by our current job
+	// Only consider deleting the snapshots that were created by our current job.
+	// A user created snapshot with the job label copied doesn't have the name
+	// prefix of the job, so it's never deleted.
 	snapshots = filterSnapshotsWithLabel(snapshots, types.RecurringJobLabel, jobLabel)
+	snapshots = filterSnapshots(snapshots, func(snapshot longhornclient.Snapshot) bool {
+		return strings.HasPrefix(snapshot.Name, types.GetRecurringJobSnapshotNamePrefix(jobLabel))
+	})
 
 	if job.task == longhorn.RecurringJobTypeSnapshot || job.task == longhorn.RecurringJobTypeSnapshotForceCreate {
 		return filterExpiredItems(snapshotsToNameWithTimestamps(snapshots), job.retain)
@@ -547,6 +571,10 @@ func (job *Job) filterExpiredSnapshotsOfCurrentRecurringJob(snapshots []longhorn
 }
 
 func (job *Job) filterExpiredSnapshots(snapshots []longhornclient.Snapshot) []string {
+	// The snapshots created by Longhorn belong to their owners, e.g. a cloning volume
+	snapshots = filterSnapshots(snapshots, func(snapshot longhornclient.Snapshot) bool {
+		return !types.IsLonghornCreatedSnapshot(snapshot.Name, snapshot.Labels)
+	})
 	return filterExpiredItems(snapshotsToNameWithTimestamps(snapshots), job.retain)
 }
 
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+const (
+	upgradeReportRequestTimeout = time.Minute
+)
+
+func UpgradeReportCmd() cli.Command {
+	return cli.Command{
+		Name:  "upgrade-report",
+		Usage: "Report how each volume would be upgraded to an engine image, without upgrading anything",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  FlagManagerURL,
+				Usage: "Longhorn manager API URL",
+				Value: types.GetDefaultManagerURL(),
+			},
+			cli.StringFlag{
+				Name:  FlagEngineImage,
+				Usage: "Specify the engine image to upgrade to, the default engine image if it's empty",
+			},
+		},
+		Action: func(c *cli.Context) {
+			if err := upgradeReport(c); err != nil {
+				logrus.WithError(err).Fatal("Failed to get upgrade report")
+			}
+		},
+	}
+}
+
+func upgradeReport(c *cli.Context) error {
+	managerURL := c.String(FlagManagerURL)
+	if managerURL == "" {
+		return fmt.Errorf("require %v", FlagManagerURL)
+	}
+
+	reportURL := fmt.Sprintf("%s/upgradereport?engineImage=%s", managerURL, url.QueryEscape(c.String(FlagEngineImage)))
+	req, err := http.NewRequest(http.MethodGet, reportURL, nil)
+	if err != nil {
+		return err
+	}
+	if token := types.GetAPIToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: upgradeReportRequestTimeout}
+	if caCerts := types.GetAPICACerts(); caCerts != "" {
+		pool, err := util.ParseCertPool([]byte(caCerts))
+		if err != nil {
+			return errors.Wrapf(err, "invalid %v", types.EnvAPICACerts)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    pool,
+		}
+		client.Transport = transport
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %v from %v: %s", resp.Status, reportURL, body)
+	}
+
+	out := &bytes.Buffer{}
+	if err := json.Indent(out, body, "", "  "); err != nil {
+		return errors.Wrap(err, "invalid upgrade report")
+	}
+	out.WriteString("\n")
+	_, err = out.WriteTo(os.Stdout)
+	return err
+}
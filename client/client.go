@@ -10,6 +10,11 @@ type RancherBaseClientImpl struct {
 	Opts    *ClientOpts
 	Schemas *Schemas
 	Types   map[string]Schema
+
+	transport http.RoundTripper
+	// requestID is sent with each request, so the server logs the requests
+	// of one operation with the same ID
+	requestID string
 }
 
 type RancherBaseClient interface {
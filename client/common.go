@@ -2,6 +2,8 @@ package client
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -32,6 +34,9 @@ type ClientOpts struct {
 	AccessKey string
 	SecretKey string
 	Timeout   time.Duration
+	// CACerts are the PEM encoded CA certificates to verify the server, the
+	// system CAs are used if it's empty.
+	CACerts string
 }
 
 type ApiError struct {
@@ -152,7 +157,11 @@ func setupRancherBaseClient(rancherClient *RancherBaseClientImpl, opts *ClientOp
 	if opts.Timeout == 0 {
 		opts.Timeout = time.Second * 10
 	}
-	client := &http.Client{Timeout: opts.Timeout}
+	rancherClient.transport, err = newTransport(opts.CACerts)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: opts.Timeout, Transport: rancherClient.transport}
 	req, err := http.NewRequest("GET", opts.Url, nil)
 	if err != nil {
 		return err
@@ -222,15 +231,50 @@ func NewListOpts() *ListOpts {
 	}
 }
 
+const requestIDHeader = "X-Request-ID"
+
 func (rancherClient *RancherBaseClientImpl) setupRequest(req *http.Request) {
 	req.SetBasicAuth(rancherClient.Opts.AccessKey, rancherClient.Opts.SecretKey)
+	if rancherClient.requestID != "" {
+		req.Header.Set(requestIDHeader, rancherClient.requestID)
+	}
+}
+
+// WithRequestID returns a copy of the client which sends the request ID with
+// its requests. The copy shares the schemas and the transport of the client.
+func (c *RancherClient) WithRequestID(requestID string) *RancherClient {
+	base, ok := c.RancherBaseClient.(*RancherBaseClientImpl)
+	if !ok {
+		return c
+	}
+	copied := *base
+	copied.requestID = requestID
+	return constructClient(&copied)
 }
 
 func (rancherClient *RancherBaseClientImpl) newHttpClient() *http.Client {
 	if rancherClient.Opts.Timeout == 0 {
 		rancherClient.Opts.Timeout = time.Minute
 	}
-	return &http.Client{Timeout: rancherClient.Opts.Timeout}
+	return &http.Client{Timeout: rancherClient.Opts.Timeout, Transport: rancherClient.transport}
+}
+
+// newTransport returns the transport verifying the server by the CA
+// certificates, or nil for the default transport if there are none.
+func newTransport(caCerts string) (http.RoundTripper, error) {
+	if caCerts == "" {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(caCerts)) {
+		return nil, errors.New("Failed to find any valid CA certificate")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    pool,
+	}
+	return transport, nil
 }
 
 func (rancherClient *RancherBaseClientImpl) doDelete(url string) error {
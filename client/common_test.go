@@ -0,0 +1,58 @@
+package client
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCACerts(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-API-Schemas", "https://"+req.Host+"/v1")
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"data": []}`))
+	}))
+	defer server.Close()
+	caCerts := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	// The server is verified by the CA
+	_, err := NewRancherClient(&ClientOpts{Url: server.URL + "/v1", CACerts: caCerts})
+	require.NoError(t, err)
+
+	// The server is unknown to the system CAs
+	_, err = NewRancherClient(&ClientOpts{Url: server.URL + "/v1"})
+	require.Error(t, err)
+
+	_, err = NewRancherClient(&ClientOpts{Url: server.URL + "/v1", CACerts: "invalid"})
+	require.Error(t, err)
+}
+
+func TestClientWithRequestID(t *testing.T) {
+	assert := require.New(t)
+
+	requestIDs := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/v1/volumes/test" {
+			requestIDs <- req.Header.Get(requestIDHeader)
+		}
+		rw.Header().Set("X-API-Schemas", "http://"+req.Host+"/v1/schemas")
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"data": [{"id": "volume", "links": {"collection": "http://` + req.Host + `/v1/volumes"}, "collectionMethods": ["GET"], "resourceMethods": ["GET"]}]}`))
+	}))
+	defer server.Close()
+
+	c, err := NewRancherClient(&ClientOpts{Url: server.URL + "/v1"})
+	assert.NoError(err)
+
+	_, err = c.WithRequestID("request-1").Volume.ById("test")
+	assert.NoError(err)
+	assert.Equal("request-1", <-requestIDs)
+
+	// The original client doesn't send the ID
+	_, err = c.Volume.ById("test")
+	assert.NoError(err)
+	assert.Empty(<-requestIDs)
+}
@@ -17,27 +17,39 @@ type Controller struct {
 
 	EngineImage string `json:"engineImage,omitempty" yaml:"engine_image,omitempty"`
 
+	FrontendReadOnly bool `json:"frontendReadOnly,omitempty" yaml:"frontend_read_only,omitempty"`
+
 	HostId string `json:"hostId,omitempty" yaml:"host_id,omitempty"`
 
 	InstanceManagerName string `json:"instanceManagerName,omitempty" yaml:"instance_manager_name,omitempty"`
 
 	IsExpanding bool `json:"isExpanding,omitempty" yaml:"is_expanding,omitempty"`
 
+	LastExitReason string `json:"lastExitReason,omitempty" yaml:"last_exit_reason,omitempty"`
+
 	LastExpansionError string `json:"lastExpansionError,omitempty" yaml:"last_expansion_error,omitempty"`
 
 	LastExpansionFailedAt string `json:"lastExpansionFailedAt,omitempty" yaml:"last_expansion_failed_at,omitempty"`
 
+	LastRestartAt string `json:"lastRestartAt,omitempty" yaml:"last_restart_at,omitempty"`
+
 	LastRestoredBackup string `json:"lastRestoredBackup,omitempty" yaml:"last_restored_backup,omitempty"`
 
 	Name string `json:"name,omitempty" yaml:"name,omitempty"`
 
 	RequestedBackupRestore string `json:"requestedBackupRestore,omitempty" yaml:"requested_backup_restore,omitempty"`
 
+	RestartCount int64 `json:"restartCount,omitempty" yaml:"restart_count,omitempty"`
+
 	Running bool `json:"running,omitempty" yaml:"running,omitempty"`
 
 	Size string `json:"size,omitempty" yaml:"size,omitempty"`
 
+	StartedAt string `json:"startedAt,omitempty" yaml:"started_at,omitempty"`
+
 	UnmapMarkSnapChainRemovedEnabled bool `json:"unmapMarkSnapChainRemovedEnabled,omitempty" yaml:"unmap_mark_snap_chain_removed_enabled,omitempty"`
+
+	Uptime int64 `json:"uptime,omitempty" yaml:"uptime,omitempty"`
 }
 
 type ControllerCollection struct {
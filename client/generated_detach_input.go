@@ -7,6 +7,8 @@ const (
 type DetachInput struct {
 	Resource `yaml:"-"`
 
+	Force bool `json:"force,omitempty" yaml:"force,omitempty"`
+
 	HostId string `json:"hostId,omitempty" yaml:"host_id,omitempty"`
 }
 
@@ -25,11 +25,21 @@ type Replica struct {
 
 	InstanceManagerName string `json:"instanceManagerName,omitempty" yaml:"instance_manager_name,omitempty"`
 
+	LastExitReason string `json:"lastExitReason,omitempty" yaml:"last_exit_reason,omitempty"`
+
+	LastRestartAt string `json:"lastRestartAt,omitempty" yaml:"last_restart_at,omitempty"`
+
 	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
 
 	Name string `json:"name,omitempty" yaml:"name,omitempty"`
 
+	RestartCount int64 `json:"restartCount,omitempty" yaml:"restart_count,omitempty"`
+
 	Running bool `json:"running,omitempty" yaml:"running,omitempty"`
+
+	StartedAt string `json:"startedAt,omitempty" yaml:"started_at,omitempty"`
+
+	Uptime int64 `json:"uptime,omitempty" yaml:"uptime,omitempty"`
 }
 
 type ReplicaCollection struct {
@@ -24,6 +24,8 @@ type Snapshot struct {
 	Usercreated bool `json:"usercreated,omitempty" yaml:"usercreated,omitempty"`
 
 	Checksum string `json:"checksum,omitempty" yaml:"checksum,omitempty"`
+
+	System bool `json:"system,omitempty" yaml:"system,omitempty"`
 }
 
 type SnapshotCollection struct {
@@ -35,6 +35,10 @@ type Volume struct {
 
 	EngineImage string `json:"engineImage,omitempty" yaml:"engine_image,omitempty"`
 
+	Filesystem string `json:"filesystem,omitempty" yaml:"filesystem,omitempty"`
+
+	FilesystemType string `json:"filesystemType,omitempty" yaml:"filesystem_type,omitempty"`
+
 	FromBackup string `json:"fromBackup,omitempty" yaml:"from_backup,omitempty"`
 
 	Frontend string `json:"frontend,omitempty" yaml:"frontend,omitempty"`
@@ -49,6 +53,14 @@ type Volume struct {
 
 	Migratable bool `json:"migratable,omitempty" yaml:"migratable,omitempty"`
 
+	MigrationNodeID string `json:"migrationNodeID,omitempty" yaml:"migration_node_id,omitempty"`
+
+	MigrationStartedAt string `json:"migrationStartedAt,omitempty" yaml:"migration_started_at,omitempty"`
+
+	MigrationState string `json:"migrationState,omitempty" yaml:"migration_state,omitempty"`
+
+	MountOptions []string `json:"mountOptions,omitempty" yaml:"mount_options,omitempty"`
+
 	Name string `json:"name,omitempty" yaml:"name,omitempty"`
 
 	NodeSelector []string `json:"nodeSelector,omitempty" yaml:"node_selector,omitempty"`
@@ -57,6 +69,8 @@ type Volume struct {
 
 	PurgeStatus []PurgeStatus `json:"purgeStatus,omitempty" yaml:"purge_status,omitempty"`
 
+	ReadOnly bool `json:"readOnly,omitempty" yaml:"read_only,omitempty"`
+
 	Ready bool `json:"ready,omitempty" yaml:"ready,omitempty"`
 
 	RebuildStatus []RebuildStatus `json:"rebuildStatus,omitempty" yaml:"rebuild_status,omitempty"`
@@ -11,11 +11,15 @@ const (
 	EventReasonFailedStarting    = "FailedStarting"
 	EventReasonStop              = "Stop"
 	EventReasonFailedStopping    = "FailedStopping"
+	EventReasonRestarted         = "Restarted"
+	EventReasonFailedRestarting  = "FailedRestarting"
+	EventReasonFailedUpgrading   = "FailedUpgrading"
 	EventReasonUpdate            = "Update"
 
-	EventReasonRebuilt          = "Rebuilt"
-	EventReasonRebuilding       = "Rebuilding"
-	EventReasonFailedRebuilding = "FailedRebuilding"
+	EventReasonRebuilt             = "Rebuilt"
+	EventReasonRebuilding          = "Rebuilding"
+	EventReasonFailedRebuilding    = "FailedRebuilding"
+	EventReasonStaleReplicaDeleted = "StaleReplicaDeleted"
 
 	EventReasonVolumeCloneCompleted = "VolumeCloneCompleted"
 	EventReasonVolumeCloneInitiated = "VolumeCloneInitiated"
@@ -45,6 +49,10 @@ const (
 	EventReasonDetachedUnexpectly = "DetachedUnexpectly"
 	EventReasonRemount            = "Remount"
 	EventReasonAutoSalvaged       = "AutoSalvaged"
+	EventReasonAutoReattached     = "AutoReattached"
+	EventReasonAutoThawed         = "AutoThawed"
+	EventReasonExpired            = "Expired"
+	EventReasonSnapshotCleanedUp  = "SnapshotCleanedUp"
 
 	EventReasonFetching = "Fetching"
 	EventReasonFetched  = "Fetched"
@@ -53,6 +61,12 @@ const (
 	EventReasonSynced  = "Synced"
 
 	EventReasonFailedSnapshotDataIntegrityCheck = "FailedSnapshotDataIntegrityCheck"
+	EventReasonFailedBackupVerification         = "FailedBackupVerification"
+	EventReasonFailedFilesystemCheck            = "FailedFilesystemCheck"
+	EventReasonFilesystemChecked                = "FilesystemChecked"
+	EventReasonFailedFilesystemFormat           = "FailedFilesystemFormat"
+	EventReasonFilesystemFormatted              = "FilesystemFormatted"
+	EventReasonFailedApplyingQoS                = "FailedApplyingQoS"
 
 	EventReasonFailed   = "Failed"
 	EventReasonReady    = "Ready"
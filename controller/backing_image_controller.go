@@ -143,6 +143,9 @@ func (bic *BackingImageController) handleErr(err error, key interface{}) {
 		bic.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(bic.queue, err, key) {
+		return
+	}
 
 	if bic.queue.NumRequeues(key) < maxRetries {
 		logrus.Warnf("Error syncing Longhorn backing image %v: %v", key, err)
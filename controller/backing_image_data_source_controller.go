@@ -192,6 +192,9 @@ func (c *BackingImageDataSourceController) handleErr(err error, key interface{})
 		c.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(c.queue, err, key) {
+		return
+	}
 
 	if c.queue.NumRequeues(key) < maxRetries {
 		logrus.Warnf("Error syncing Longhorn backing image data source %v: %v", key, err)
@@ -739,8 +742,9 @@ func (c *BackingImageDataSourceController) prepareRunningParameters(bids *longho
 		}
 		defer engineClientProxy.Close()
 
-		snapLabels := map[string]string{types.GetLonghornLabelKey(types.LonghornLabelSnapshotForExportingBackingImage): bids.Name}
-		snapshotName, err := engineClientProxy.SnapshotCreate(e, bids.Name+"-"+util.RandomID(), snapLabels)
+		snapLabels := types.GetSystemSnapshotLabels(types.SystemSnapshotPurposeExportBackingImage, bids.Name)
+		snapLabels[types.GetLonghornLabelKey(types.LonghornLabelSnapshotForExportingBackingImage)] = bids.Name
+		snapshotName, err := engineClientProxy.SnapshotCreate(e, types.GenerateSystemSnapshotName(types.SystemSnapshotPurposeExportBackingImage, bids.Name), snapLabels)
 		if err != nil {
 			return err
 		}
@@ -201,6 +201,9 @@ func (c *BackingImageManagerController) handleErr(err error, key interface{}) {
 		c.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(c.queue, err, key) {
+		return
+	}
 
 	if c.queue.NumRequeues(key) < maxRetries {
 		logrus.Warnf("Error syncing Longhorn backing image manager %v: %v", key, err)
@@ -159,6 +159,9 @@ func (bc *BackupController) handleErr(err error, key interface{}) {
 		bc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(bc.queue, err, key) {
+		return
+	}
 
 	// The resync period of the backup is one hour and the maxRetries is 3.
 	// Thus, the deletion failure of the backup in error state is caused by the shutdown of the replica during backing up,
@@ -485,7 +488,7 @@ func (bc *BackupController) getBackupVolumeName(backup *longhorn.Backup) (string
 	return backupVolumeName, nil
 }
 
-func (bc *BackupController) getEngineBinaryClient(volumeName string) (*engineapi.EngineBinary, error) {
+func (bc *BackupController) getEngineBinaryClient(volumeName string) (engineapi.EngineClientProxy, error) {
 	engine, err := bc.ds.GetVolumeCurrentEngine(volumeName)
 	if err != nil {
 		return nil, err
@@ -189,6 +189,9 @@ func (btc *BackupTargetController) handleErr(err error, key interface{}) {
 		btc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(btc.queue, err, key) {
+		return
+	}
 
 	if btc.queue.NumRequeues(key) < maxRetries {
 		btc.logger.WithError(err).Warnf("Error syncing Longhorn backup target %v", key)
@@ -251,6 +254,10 @@ func newBackupTargetClient(ds *datastore.DataStore, backupTarget *longhorn.Backu
 			return nil, err
 		}
 	}
+
+	if err := ds.CheckAirGappedBackupTarget(backupType, credential); err != nil {
+		return nil, err
+	}
 	return engineapi.NewBackupTargetClient(engineImage, backupTarget.Spec.BackupTargetURL, credential), nil
 }
 
@@ -151,6 +151,9 @@ func (bvc *BackupVolumeController) handleErr(err error, key interface{}) {
 		bvc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(bvc.queue, err, key) {
+		return
+	}
 
 	if bvc.queue.NumRequeues(key) < maxRetries {
 		bvc.logger.WithError(err).Warnf("Error syncing Longhorn backup volume %v", key)
@@ -3,7 +3,10 @@ package controller
 import (
 	"github.com/sirupsen/logrus"
 
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/workqueue"
+
+	"github.com/longhorn/longhorn-manager/datastore"
 )
 
 var (
@@ -28,11 +31,25 @@ func newBaseController(name string, logger logrus.FieldLogger) *baseController {
 
 func newBaseControllerWithQueue(name string, logger logrus.FieldLogger,
 	queue workqueue.RateLimitingInterface) *baseController {
+	logger = logger.WithField("controller", name)
 	c := &baseController{
 		name:   name,
-		logger: logger.WithField("controller", name),
-		queue:  queue,
+		logger: logger,
+		queue:  newInstrumentedQueue(name, logger, queue),
 	}
 
 	return c
 }
+
+// requeueIfDataStoreUnavailable requeues the key for when the datastore is
+// expected back, if the sync failed fast since the datastore is unavailable.
+// The retry isn't counted, so no key is dropped during an outage, and the
+// failure isn't logged for each key.
+func requeueIfDataStoreUnavailable(queue workqueue.RateLimitingInterface, err error, key interface{}) bool {
+	if !datastore.ErrorIsUnavailable(err) {
+		return false
+	}
+	queue.Forget(key)
+	queue.AddAfter(key, wait.Jitter(datastore.ClientUnavailableRetryInterval, 1.0))
+	return true
+}
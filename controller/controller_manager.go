@@ -35,7 +35,7 @@ var (
 	longhornFinalizerKey = longhorn.SchemeGroupVersion.Group
 )
 
-func StartControllers(logger logrus.FieldLogger, stopCh chan struct{}, controllerID, serviceAccount, managerImage, kubeconfigPath, version string, proxyConnCounter util.Counter) (*datastore.DataStore, *WebsocketController, error) {
+func StartControllers(logger logrus.FieldLogger, stopCh chan struct{}, controllerID, serviceAccount, managerImage, managerURL, kubeconfigPath, version string, proxyConnCounter util.Counter) (*datastore.DataStore, *WebsocketController, error) {
 	namespace := os.Getenv(types.EnvPodNamespace)
 	if namespace == "" {
 		logrus.Warnf("Cannot detect pod namespace, environment variable %v is missing, "+
@@ -50,6 +50,7 @@ func StartControllers(logger logrus.FieldLogger, stopCh chan struct{}, controlle
 
 	config.Burst = 100
 	config.QPS = 50
+	datastore.WrapClientConfig(config)
 
 	kubeClient, err := clientset.NewForConfig(config)
 	if err != nil {
@@ -88,7 +89,9 @@ func StartControllers(logger logrus.FieldLogger, stopCh chan struct{}, controlle
 	ic := NewEngineImageController(logger, ds, scheme, kubeClient, namespace, controllerID, serviceAccount)
 	nc := NewNodeController(logger, ds, scheme, kubeClient, namespace, controllerID)
 	ws := NewWebsocketController(logger, ds)
-	sc := NewSettingController(logger, ds, scheme, kubeClient, namespace, controllerID, version)
+	le := NewLeaderElector(logger, kubeClient, namespace, controllerID)
+	sc := NewSettingController(logger, ds, scheme, kubeClient, namespace, controllerID, version, le)
+	notc := NewNotificationController(logger, ds, namespace, le)
 	btc := NewBackupTargetController(logger, ds, scheme, kubeClient, controllerID, namespace, proxyConnCounter)
 	bvc := NewBackupVolumeController(logger, ds, scheme, kubeClient, controllerID, namespace, proxyConnCounter)
 	bc := NewBackupController(logger, ds, scheme, kubeClient, controllerID, namespace, proxyConnCounter)
@@ -97,9 +100,10 @@ func StartControllers(logger logrus.FieldLogger, stopCh chan struct{}, controlle
 	bic := NewBackingImageController(logger, ds, scheme, kubeClient, namespace, controllerID, serviceAccount)
 	bimc := NewBackingImageManagerController(logger, ds, scheme, kubeClient, namespace, controllerID, serviceAccount)
 	bidsc := NewBackingImageDataSourceController(logger, ds, scheme, kubeClient, namespace, controllerID, serviceAccount, proxyConnCounter)
-	rjc := NewRecurringJobController(logger, ds, scheme, kubeClient, namespace, controllerID, serviceAccount, managerImage)
+	rjc := NewRecurringJobController(logger, ds, scheme, kubeClient, namespace, controllerID, serviceAccount, managerImage, managerURL)
 	oc := NewOrphanController(logger, ds, scheme, kubeClient, controllerID, namespace)
 	snapc := NewSnapshotController(logger, ds, scheme, kubeClient, namespace, controllerID, &engineapi.EngineCollection{}, proxyConnCounter)
+	snapcc := NewSnapshotCleanupController(logger, ds, scheme, kubeClient, namespace, controllerID)
 	bundlec := NewSupportBundleController(logger, ds, scheme, kubeClient, controllerID, namespace, serviceAccount)
 	sbc := NewSystemBackupController(logger, ds, scheme, kubeClient, namespace, controllerID, managerImage)
 	src := NewSystemRestoreController(logger, ds, scheme, kubeClient, namespace, controllerID)
@@ -115,6 +119,7 @@ func StartControllers(logger logrus.FieldLogger, stopCh chan struct{}, controlle
 	if !ds.Sync(stopCh) {
 		return nil, nil, fmt.Errorf("datastore cache sync up failed")
 	}
+	go le.Run(stopCh)
 	go rc.Run(Workers, stopCh)
 	go ec.Run(Workers, stopCh)
 	go vc.Run(Workers, stopCh)
@@ -122,6 +127,7 @@ func StartControllers(logger logrus.FieldLogger, stopCh chan struct{}, controlle
 	go nc.Run(Workers, stopCh)
 	go ws.Run(stopCh)
 	go sc.Run(stopCh)
+	go notc.Run(Workers, stopCh)
 	go imc.Run(Workers, stopCh)
 	go smc.Run(Workers, stopCh)
 	go bic.Run(Workers, stopCh)
@@ -133,6 +139,7 @@ func StartControllers(logger logrus.FieldLogger, stopCh chan struct{}, controlle
 	go rjc.Run(Workers, stopCh)
 	go oc.Run(Workers, stopCh)
 	go snapc.Run(Workers, stopCh)
+	go snapcc.Run(Workers, stopCh)
 	go bundlec.Run(Workers, stopCh)
 	go sbc.Run(Workers, stopCh)
 	go src.Run(Workers, stopCh)
@@ -11,6 +11,9 @@ import (
 
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/kubernetes/pkg/controller"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -23,6 +26,7 @@ import (
 
 	"github.com/longhorn/longhorn-manager/engineapi"
 	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
 
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 
@@ -511,3 +515,200 @@ func (s *TestSuite) TestIsSameGuaranteedCPURequirement(c *C) {
 	a.Requests[corev1.ResourceCPU], err = resource.ParseQuantity("0.25")
 	c.Assert(IsSameGuaranteedCPURequirement(a, b), Equals, true)
 }
+
+func (s *TestSuite) TestInstrumentedQueue(c *C) {
+	q := newInstrumentedQueue("test-instrumented-queue", logrus.StandardLogger(), workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()))
+	defer q.ShutDown()
+
+	q.Add("default/a")
+	q.Add("default/b")
+	q.Add("default/a")
+
+	status := q.status(time.Now())
+	c.Assert(status.Depth, Equals, 2)
+	c.Assert(status.PendingItems, HasLen, 2)
+
+	item, shutdown := q.Get()
+	c.Assert(shutdown, Equals, false)
+	c.Assert(item, Equals, "default/a")
+	q.Done(item)
+
+	status = q.status(time.Now().Add(time.Minute))
+	c.Assert(status.Depth, Equals, 1)
+	c.Assert(status.PendingItems, HasLen, 1)
+	c.Assert(status.PendingItems[0].Key, Equals, "default/b")
+	c.Assert(status.OldestPendingAge >= 60, Equals, true)
+
+	// The delayed key isn't pending until it's due
+	q.AddAfter("default/c", time.Hour)
+	status = q.status(time.Now().Add(time.Minute))
+	c.Assert(status.PendingItems, HasLen, 1)
+	c.Assert(status.PendingItems[0].Key, Equals, "default/b")
+	status = q.status(time.Now().Add(2 * time.Hour))
+	c.Assert(status.PendingItems, HasLen, 2)
+	c.Assert(status.PendingItems[0].Key, Equals, "default/b")
+	c.Assert(status.PendingItems[1].Key, Equals, "default/c")
+	c.Assert(status.PendingItems[1].AgeSeconds < status.PendingItems[0].AgeSeconds, Equals, true)
+
+	// or until it's added without the delay
+	q.Add("default/c")
+	status = q.status(time.Now().Add(time.Minute))
+	c.Assert(status.PendingItems, HasLen, 2)
+
+	found := false
+	for _, s := range GetWorkQueueStatuses() {
+		if s.Name == "test-instrumented-queue" {
+			found = true
+		}
+	}
+	c.Assert(found, Equals, true)
+}
+
+func (s *TestSuite) TestUpdateRebuildThroughput(c *C) {
+	m := &EngineMonitor{rebuildSamples: map[string]rebuildSample{}}
+	e := &longhorn.Engine{Spec: longhorn.EngineSpec{InstanceSpec: longhorn.InstanceSpec{VolumeSize: 100 * 1024 * 1024}}}
+	now := time.Now()
+
+	status := map[string]*longhorn.RebuildStatus{
+		"tcp://10.0.0.1:10000": {IsRebuilding: true, Progress: 10},
+	}
+	m.updateRebuildThroughput(e, status, now)
+	c.Assert(status["tcp://10.0.0.1:10000"].Throughput, Equals, int64(0))
+
+	status = map[string]*longhorn.RebuildStatus{
+		"tcp://10.0.0.1:10000": {IsRebuilding: true, Progress: 30},
+	}
+	m.updateRebuildThroughput(e, status, now.Add(10*time.Second))
+	c.Assert(status["tcp://10.0.0.1:10000"].Throughput, Equals, int64(2*1024*1024))
+
+	m.updateRebuildThroughput(e, map[string]*longhorn.RebuildStatus{}, now.Add(20*time.Second))
+	c.Assert(m.rebuildSamples, HasLen, 0)
+}
+
+func (s *TestSuite) TestStateChangeSubscriber(c *C) {
+	wc := &WebsocketController{baseController: newBaseController("test-state-change", logrus.StandardLogger())}
+	defer wc.Close()
+	handler := wc.stateChangeHandler("volume", getVolumeStateChange)
+
+	subscriber := wc.SubscribeStateChanges([]string{"volume"}, []string{"vol-1"})
+	all := wc.SubscribeStateChanges(nil, nil)
+
+	detached := newVolume("vol-1", 2)
+	detached.Status.State = longhorn.VolumeStateDetached
+	attached := detached.DeepCopy()
+	attached.Status.State = longhorn.VolumeStateAttached
+	other := newVolume("vol-2", 2)
+
+	handler.OnAdd(detached)
+	// Only the state changes are sent
+	handler.OnUpdate(detached, detached.DeepCopy())
+	handler.OnUpdate(detached, attached)
+	handler.OnAdd(other)
+	handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "vol-1", Obj: attached})
+
+	c.Assert(subscriber.Events(), HasLen, 3)
+	c.Assert(all.Events(), HasLen, 4)
+	event := <-subscriber.Events()
+	c.Assert(event.Action, Equals, StateChangeActionCreated)
+	c.Assert(event.State, Equals, string(longhorn.VolumeStateDetached))
+	event = <-subscriber.Events()
+	c.Assert(event.Action, Equals, StateChangeActionUpdated)
+	c.Assert(event.State, Equals, string(longhorn.VolumeStateAttached))
+	event = <-subscriber.Events()
+	c.Assert(event.Action, Equals, StateChangeActionDeleted)
+	c.Assert(event.Resource, Equals, "volume")
+
+	subscriber.Close()
+	_, ok := <-subscriber.Events()
+	c.Assert(ok, Equals, false)
+	c.Assert(wc.subscribers, HasLen, 1)
+}
+
+func (s *TestSuite) TestGetExpiredEvents(c *C) {
+	now := time.Now()
+	newEvent := func(name string, lastSeen time.Duration) corev1.Event {
+		return corev1.Event{
+			ObjectMeta:    metav1.ObjectMeta{Name: name},
+			LastTimestamp: metav1.NewTime(now.Add(-lastSeen)),
+		}
+	}
+	events := []corev1.Event{
+		newEvent("old", 3*time.Hour),
+		newEvent("new", time.Minute),
+		newEvent("middle", time.Hour),
+	}
+
+	c.Assert(getExpiredEvents(events, 0, 0, now), HasLen, 0)
+	c.Assert(getExpiredEvents(events, 2, 0, now), DeepEquals, []string{"old"})
+	c.Assert(getExpiredEvents(events, 0, 30*time.Minute, now), DeepEquals, []string{"middle", "old"})
+	c.Assert(getExpiredEvents(events, 1, 2*time.Hour, now), DeepEquals, []string{"middle", "old"})
+}
+
+func (s *TestSuite) TestLeaderElector(c *C) {
+	kubeClient := fake.NewSimpleClientset()
+	le := NewLeaderElector(logrus.StandardLogger(), kubeClient, TestNamespace, TestNode1)
+	started := make(chan struct{})
+	le.AddCallbacks(LeaderCallbacks{
+		OnStartedLeading: func() { close(started) },
+	})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go le.Run(stopCh)
+
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		c.Fatal("manager was not elected as the leader")
+	}
+	c.Assert(le.IsLeader(), Equals, true)
+
+	// Another manager waits for the lease held by the leader
+	other := NewLeaderElector(logrus.StandardLogger(), kubeClient, TestNamespace, TestNode2)
+	otherStopCh := make(chan struct{})
+	defer close(otherStopCh)
+	go other.Run(otherStopCh)
+	time.Sleep(3 * leaderElectionRetryPeriod)
+	c.Assert(other.IsLeader(), Equals, false)
+}
+
+func (s *TestSuite) TestFilesystemChecks(c *C) {
+	fc := newFilesystemChecks()
+	release := make(chan struct{})
+	fc.check = func(device, fsType string, repair bool) (*util.FilesystemCheckResult, error) {
+		<-release
+		return &util.FilesystemCheckResult{Clean: repair}, nil
+	}
+
+	done := make(chan struct{})
+	fc.start(TestVolumeName, "/dev/longhorn/"+TestVolumeName, util.FilesystemTypeExt4, true, func() { close(done) })
+	// The check already running isn't started again
+	fc.start(TestVolumeName, "/dev/longhorn/"+TestVolumeName, util.FilesystemTypeExt4, false, func() { c.Fatal("check started twice") })
+	c.Assert(fc.isRunning(TestVolumeName), Equals, true)
+	c.Assert(fc.getResult(TestVolumeName), IsNil)
+
+	close(release)
+	<-done
+	c.Assert(fc.isRunning(TestVolumeName), Equals, false)
+	result := fc.getResult(TestVolumeName)
+	c.Assert(result, NotNil)
+	c.Assert(result.repair, Equals, true)
+	c.Assert(result.result.Clean, Equals, true)
+	c.Assert(result.checkedAt, Not(Equals), "")
+	// The result is kept until it's deleted
+	c.Assert(fc.getResult(TestVolumeName), Equals, result)
+	fc.deleteResult(TestVolumeName, &filesystemCheckResult{})
+	c.Assert(fc.getResult(TestVolumeName), Equals, result)
+	fc.deleteResult(TestVolumeName, result)
+	c.Assert(fc.getResult(TestVolumeName), IsNil)
+
+	checked, err := isCheckedSince("", "2026-01-01T00:00:00Z")
+	c.Assert(err, IsNil)
+	c.Assert(checked, Equals, false)
+	checked, err = isCheckedSince("2026-01-01T00:00:00Z", "2026-01-01T00:00:00Z")
+	c.Assert(err, IsNil)
+	c.Assert(checked, Equals, true)
+	checked, err = isCheckedSince("2025-12-31T23:59:59Z", "2026-01-01T00:00:00Z")
+	c.Assert(err, IsNil)
+	c.Assert(checked, Equals, false)
+}
@@ -77,17 +77,24 @@ type EngineController struct {
 	cacheSyncs []cache.InformerSynced
 
 	backoff *flowcontrol.Backoff
+	// upgradeBackoff limits the events of the live upgrade failing on every
+	// sync
+	upgradeBackoff *flowcontrol.Backoff
 
 	instanceHandler *InstanceHandler
 
 	engines            engineapi.EngineClientCollection
 	engineMonitorMutex *sync.RWMutex
 	engineMonitorMap   map[string]chan struct{}
+	statusCollector    *EngineStatusCollector
 
 	proxyConnCounter util.Counter
 
 	restoringCounter      util.Counter
 	restoringCounterMutex *sync.Mutex
+
+	// for unit test
+	getReplicaSnapshotChain func(address string) ([]string, error)
 }
 
 type EngineMonitor struct {
@@ -99,6 +106,7 @@ type EngineMonitor struct {
 
 	Name             string
 	engines          engineapi.EngineClientCollection
+	statusCollector  *EngineStatusCollector
 	stopCh           chan struct{}
 	expansionBackoff *flowcontrol.Backoff
 	restoreBackoff   *flowcontrol.Backoff
@@ -114,6 +122,14 @@ type EngineMonitor struct {
 	restoringCounter         util.Counter
 	restoringCounterAcquired bool
 	restoringCounterMutex    *sync.Mutex
+
+	// the first observed progress of the ongoing rebuildings, by the replica address
+	rebuildSamples map[string]rebuildSample
+}
+
+type rebuildSample struct {
+	progress int
+	time     time.Time
 }
 
 func NewEngineController(
@@ -140,15 +156,19 @@ func NewEngineController(
 		kubeClient:    kubeClient,
 		eventRecorder: eventBroadcaster.NewRecorder(scheme, v1.EventSource{Component: "longhorn-engine-controller"}),
 
-		backoff: flowcontrol.NewBackOff(time.Second*10, time.Minute*5),
+		backoff:        flowcontrol.NewBackOff(time.Second*10, time.Minute*5),
+		upgradeBackoff: flowcontrol.NewBackOff(time.Minute, time.Minute*30),
 
 		engines:            engines,
 		engineMonitorMutex: &sync.RWMutex{},
 		engineMonitorMap:   map[string]chan struct{}{},
+		statusCollector:    NewEngineStatusCollector(logger, ds),
 
 		proxyConnCounter:      proxyConnCounter,
 		restoringCounter:      util.NewAtomicCounter(),
 		restoringCounterMutex: &sync.Mutex{},
+
+		getReplicaSnapshotChain: engineapi.GetReplicaSnapshotChain,
 	}
 	ec.instanceHandler = NewInstanceHandler(ds, ec, ec.eventRecorder)
 
@@ -211,6 +231,9 @@ func (ec *EngineController) handleErr(err error, key interface{}) {
 		ec.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(ec.queue, err, key) {
+		return
+	}
 
 	log := ec.logger.WithField("engine", key)
 	if ec.queue.NumRequeues(key) < maxRetries {
@@ -319,8 +342,11 @@ func (ec *EngineController) syncEngine(key string) (err error) {
 		if err := ec.Upgrade(engine); err != nil {
 			// Engine live upgrade failure shouldn't block the following engine state update.
 			log.WithError(err).Error("failed to run engine live upgrade")
+			ec.recordUpgradeFailure(engine, err)
 			// Sync replica address map as usual when the upgrade fails.
 			syncReplicaAddressMap = true
+		} else {
+			ec.upgradeBackoff.DeleteEntry(engine.Name)
 		}
 	} else if len(engine.Spec.UpgradedReplicaAddressMap) == 0 {
 		syncReplicaAddressMap = true
@@ -461,7 +487,12 @@ func (ec *EngineController) CreateInstance(obj interface{}) (*longhorn.InstanceP
 		return nil, err
 	}
 
-	return c.EngineProcessCreate(e, frontend, engineReplicaTimeout, fileSyncHTTPClientTimeout, v.Spec.DataLocality, engineCLIAPIVersion)
+	qos, err := ec.getEngineQoS(v, engineCLIAPIVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.EngineProcessCreate(e, frontend, engineReplicaTimeout, fileSyncHTTPClientTimeout, v.Spec.DataLocality, qos, engineCLIAPIVersion)
 }
 
 func (ec *EngineController) DeleteInstance(obj interface{}) (err error) {
@@ -710,6 +741,7 @@ func (ec *EngineController) startMonitoring(e *longhorn.Engine) {
 		ds:                     ec.ds,
 		eventRecorder:          ec.eventRecorder,
 		engines:                ec.engines,
+		statusCollector:        ec.statusCollector,
 		stopCh:                 stopCh,
 		monitorVoluntaryStopCh: monitorVoluntaryStopCh,
 		expansionBackoff:       flowcontrol.NewBackOff(time.Second*10, time.Minute*5),
@@ -718,6 +750,7 @@ func (ec *EngineController) startMonitoring(e *longhorn.Engine) {
 		proxyConnCounter:       ec.proxyConnCounter,
 		restoringCounter:       ec.restoringCounter,
 		restoringCounterMutex:  ec.restoringCounterMutex,
+		rebuildSamples:         map[string]rebuildSample{},
 	}
 
 	ec.engineMonitorMutex.Lock()
@@ -784,7 +817,7 @@ func (m *EngineMonitor) Run() {
 	for {
 		select {
 		case <-ticker.C:
-			if needStop := m.sync(); needStop {
+			if needStop := m.poll(); needStop {
 				return
 			}
 		case <-m.stopCh:
@@ -793,6 +826,13 @@ func (m *EngineMonitor) Run() {
 	}
 }
 
+func (m *EngineMonitor) poll() bool {
+	if m.statusCollector == nil {
+		return m.sync()
+	}
+	return m.statusCollector.Poll(m.stopCh, m.sync)
+}
+
 func (m *EngineMonitor) sync() bool {
 	for count := 0; count < EngineMonitorConflictRetryCount; count++ {
 		engine, err := m.ds.GetEngine(m.Name)
@@ -957,10 +997,11 @@ func (m *EngineMonitor) refresh(engine *longhorn.Engine) error {
 		if err != nil {
 			return err
 		}
+		m.updateRebuildThroughput(engine, rebuildStatus, time.Now())
 		engine.Status.RebuildStatus = rebuildStatus
 
 		// It's meaningless to sync the trim related field for old engines or engines in old engine instance managers
-		if cliAPIVersion >= 7 && im.Status.APIVersion >= 3 {
+		if engineapi.IsCLIFeatureSupported(engineapi.EngineFeatureUnmapMarkSnapChainRemoved, cliAPIVersion) && im.Status.APIVersion >= 3 {
 			// Check and correct flag UnmapMarkSnapChainRemoved for the engine and replicas
 			engine.Status.UnmapMarkSnapChainRemovedEnabled = volumeInfo.UnmapMarkSnapChainRemoved
 			if engine.Spec.UnmapMarkSnapChainRemovedEnabled != volumeInfo.UnmapMarkSnapChainRemoved {
@@ -1088,7 +1129,7 @@ func (m *EngineMonitor) refresh(engine *longhorn.Engine) error {
 	}
 
 	var snapshotCloneStatusMap map[string]*longhorn.SnapshotCloneStatus
-	if cliAPIVersion >= engineapi.CLIVersionFive {
+	if engineapi.IsCLIFeatureSupported(engineapi.EngineFeatureSnapshotClone, cliAPIVersion) {
 		if snapshotCloneStatusMap, err = engineClientProxy.SnapshotCloneStatus(engine); err != nil {
 			return err
 		}
@@ -1576,7 +1617,7 @@ func (ec *EngineController) ReconcileEngineState(e *longhorn.Engine) error {
 	return nil
 }
 
-func GetBinaryClientForEngine(e *longhorn.Engine, engines engineapi.EngineClientCollection, image string) (client *engineapi.EngineBinary, err error) {
+func GetBinaryClientForEngine(e *longhorn.Engine, engines engineapi.EngineClientCollection, image string) (client engineapi.EngineClientProxy, err error) {
 	defer func() {
 		err = errors.Wrapf(err, "cannot get client for engine %v", e.Name)
 	}()
@@ -1674,6 +1715,32 @@ func doesAddressExistInEngine(e *longhorn.Engine, addr string, engineClientProxy
 	return false, nil
 }
 
+// updateRebuildThroughput fills in the average throughput of each ongoing
+// rebuilding, based on the progress made since the rebuilding was first observed.
+func (m *EngineMonitor) updateRebuildThroughput(engine *longhorn.Engine, rebuildStatus map[string]*longhorn.RebuildStatus, now time.Time) {
+	for addr := range m.rebuildSamples {
+		if status, ok := rebuildStatus[addr]; !ok || !status.IsRebuilding {
+			delete(m.rebuildSamples, addr)
+		}
+	}
+	for addr, status := range rebuildStatus {
+		if !status.IsRebuilding {
+			continue
+		}
+		sample, ok := m.rebuildSamples[addr]
+		if !ok {
+			m.rebuildSamples[addr] = rebuildSample{progress: status.Progress, time: now}
+			continue
+		}
+		elapsed := now.Sub(sample.time).Seconds()
+		if elapsed <= 0 || status.Progress <= sample.progress {
+			continue
+		}
+		rebuilt := engine.Spec.VolumeSize * int64(status.Progress-sample.progress) / 100
+		status.Throughput = int64(float64(rebuilt) / elapsed)
+	}
+}
+
 func (ec *EngineController) startRebuilding(e *longhorn.Engine, replicaName, addr string) (err error) {
 	defer func() {
 		err = errors.Wrapf(err, "failed to start rebuild for %v of %v", replicaName, e.Name)
@@ -1777,6 +1844,16 @@ func (ec *EngineController) startRebuilding(e *longhorn.Engine, replicaName, add
 			log.Debug("Finished snapshot purge, will start rebuilding then")
 		}
 
+		// What is transferred is decided by the engine, the fast sync skips
+		// the snapshot data the replica already has. The relation of the
+		// snapshot chains is only recorded for the rebuilding report.
+		chainRelation, missingSnapshots, err := ec.getReplicaSnapshotChainRelation(e, replicaName, addr)
+		if err != nil {
+			log.WithError(err).Warnf("Failed to compare snapshot chains of replica %v", replicaName)
+		} else if chainRelation != "" {
+			log.Infof("Snapshot chain of replica %v is %v, missing snapshots %v", replicaName, chainRelation, missingSnapshots)
+		}
+
 		replica, err := ec.ds.GetReplica(replicaName)
 		if err != nil {
 			log.WithError(err).Errorf("Failed to get replica %v unable to mark failed rebuild", replica)
@@ -1784,6 +1861,7 @@ func (ec *EngineController) startRebuilding(e *longhorn.Engine, replicaName, add
 		}
 
 		// check and reset replica rebuild failed condition
+		replica.Status.SnapshotChainRelation = chainRelation
 		replica, err = ec.updateReplicaRebuildFailedCondition(replica, "")
 		if err != nil {
 			log.WithError(err).Errorf("Failed to update rebuild status information on replica %v", replicaName)
@@ -1872,6 +1950,40 @@ func (ec *EngineController) startRebuilding(e *longhorn.Engine, replicaName, add
 	return nil
 }
 
+// getReplicaSnapshotChainRelation compares the snapshot chain of the
+// rebuilding replica with the one of a healthy replica of the engine, and
+// returns the relation with the snapshots missing in the rebuilding replica.
+// A new replica has no snapshot to compare, so the healthy replica is not
+// asked in that case and the relation is empty.
+func (ec *EngineController) getReplicaSnapshotChainRelation(e *longhorn.Engine, replicaName, addr string) (longhorn.ReplicaSnapshotChainRelation, []string, error) {
+	rebuildingChain, err := ec.getReplicaSnapshotChain(addr)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(rebuildingChain) == 0 {
+		return "", nil, nil
+	}
+
+	healthyAddr := ""
+	for _, name := range util.GetSortedKeysFromMap(e.Status.ReplicaModeMap) {
+		if name == replicaName || e.Status.ReplicaModeMap[name] != longhorn.ReplicaModeRW {
+			continue
+		}
+		if healthyAddr = e.Status.CurrentReplicaAddressMap[name]; healthyAddr != "" {
+			break
+		}
+	}
+	if healthyAddr == "" {
+		return "", nil, fmt.Errorf("no healthy replica to compare with")
+	}
+	healthyChain, err := ec.getReplicaSnapshotChain(healthyAddr)
+	if err != nil {
+		return "", nil, err
+	}
+	relation, missingSnapshots := engineapi.GetReplicaSnapshotChainRelation(healthyChain, rebuildingChain)
+	return relation, missingSnapshots, nil
+}
+
 // updateReplicaRebuildFailedCondition updates the rebuild failed condition if replica rebuilding failed
 func (ec *EngineController) updateReplicaRebuildFailedCondition(replica *longhorn.Replica, errMsg string) (*longhorn.Replica, error) {
 	replicaRebuildFailedReason, conditionStatus, err := ec.getReplicaRebuildFailedReason(replica.Spec.NodeID, errMsg)
@@ -1961,6 +2073,21 @@ func (ec *EngineController) Upgrade(e *longhorn.Engine) (err error) {
 	return nil
 }
 
+// recordUpgradeFailure records an event for the failed live upgrade, which is
+// retried on every sync. The events are limited by a backoff per engine, and
+// there is no automatic rollback: the volume keeps retrying until it's
+// upgraded back to the current image.
+func (ec *EngineController) recordUpgradeFailure(e *longhorn.Engine, err error) {
+	now := time.Now()
+	if ec.upgradeBackoff.IsInBackOffSinceUpdate(e.Name, now) {
+		return
+	}
+	ec.upgradeBackoff.Next(e.Name, now)
+	ec.eventRecorder.Eventf(e, v1.EventTypeWarning, constant.EventReasonFailedUpgrading,
+		"Failed to live upgrade engine from %v to %v, will retry until the volume is upgraded back to %v: %v",
+		e.Status.CurrentImage, e.Spec.EngineImage, e.Status.CurrentImage, err)
+}
+
 func (ec *EngineController) UpgradeEngineProcess(e *longhorn.Engine) error {
 	frontend := e.Spec.Frontend
 	if e.Spec.DisableFrontend {
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
+
+	"github.com/longhorn/longhorn-manager/constant"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *TestSuite) TestGetReplicaSnapshotChainRelation(c *C) {
+	const (
+		healthyAddr    = "tcp://10.0.0.1:10000"
+		rebuildingAddr = "tcp://10.0.0.2:10000"
+	)
+
+	testCases := map[string]struct {
+		chains           map[string][]string
+		noHealthyReplica bool
+
+		expectedRelation longhorn.ReplicaSnapshotChainRelation
+		expectedMissing  []string
+		expectedCalls    []string
+		expectedErr      bool
+	}{
+		"behind": {
+			chains: map[string][]string{
+				healthyAddr:    {"snap1", "snap2"},
+				rebuildingAddr: {"snap1"},
+			},
+			expectedRelation: longhorn.ReplicaSnapshotChainRelationBehind,
+			expectedMissing:  []string{"snap2"},
+			expectedCalls:    []string{rebuildingAddr, healthyAddr},
+		},
+		"diverged": {
+			chains: map[string][]string{
+				healthyAddr:    {"snap1", "snap3"},
+				rebuildingAddr: {"snap1", "snap2"},
+			},
+			expectedRelation: longhorn.ReplicaSnapshotChainRelationDiverged,
+			expectedCalls:    []string{rebuildingAddr, healthyAddr},
+		},
+		"new replica": {
+			chains: map[string][]string{
+				healthyAddr: {"snap1"},
+			},
+			expectedCalls: []string{rebuildingAddr},
+		},
+		"no healthy replica": {
+			chains: map[string][]string{
+				rebuildingAddr: {"snap1"},
+			},
+			noHealthyReplica: true,
+			expectedCalls:    []string{rebuildingAddr},
+			expectedErr:      true,
+		},
+	}
+
+	for name, tc := range testCases {
+		fmt.Printf("testing %v\n", name)
+
+		e := newEngine(TestEngineName, TestEngineImage, TestInstanceManagerName1, TestNode1, TestIP1, 0, true, longhorn.InstanceStateRunning, longhorn.InstanceStateRunning)
+		e.Status.ReplicaModeMap = map[string]longhorn.ReplicaMode{
+			"replica-healthy":    longhorn.ReplicaModeRW,
+			"replica-rebuilding": longhorn.ReplicaModeWO,
+		}
+		e.Status.CurrentReplicaAddressMap = map[string]string{
+			"replica-healthy":    healthyAddr,
+			"replica-rebuilding": rebuildingAddr,
+		}
+		if tc.noHealthyReplica {
+			e.Status.ReplicaModeMap["replica-healthy"] = longhorn.ReplicaModeERR
+		}
+
+		calls := []string{}
+		ec := &EngineController{
+			getReplicaSnapshotChain: func(address string) ([]string, error) {
+				calls = append(calls, address)
+				return tc.chains[address], nil
+			},
+		}
+
+		relation, missing, err := ec.getReplicaSnapshotChainRelation(e, "replica-rebuilding", rebuildingAddr)
+		if tc.expectedErr {
+			c.Assert(err, NotNil)
+		} else {
+			c.Assert(err, IsNil)
+		}
+		c.Assert(relation, Equals, tc.expectedRelation)
+		c.Assert(missing, DeepEquals, tc.expectedMissing)
+		c.Assert(calls, DeepEquals, tc.expectedCalls)
+	}
+}
+
+func (s *TestSuite) TestRecordUpgradeFailure(c *C) {
+	recorder := record.NewFakeRecorder(10)
+	ec := &EngineController{
+		eventRecorder:  recorder,
+		upgradeBackoff: flowcontrol.NewBackOff(time.Minute, time.Minute*30),
+	}
+	e := newEngine(TestEngineName, TestEngineImage, TestInstanceManagerName1, TestNode1, TestIP1, 0, true, longhorn.InstanceStateRunning, longhorn.InstanceStateRunning)
+	e.Spec.EngineImage = "longhorn-engine:new"
+
+	// The upgrade retried on every sync records the failure only once per
+	// backoff period
+	ec.recordUpgradeFailure(e, fmt.Errorf("failed to upgrade"))
+	ec.recordUpgradeFailure(e, fmt.Errorf("failed to upgrade"))
+	c.Assert(recorder.Events, HasLen, 1)
+	event := <-recorder.Events
+	c.Assert(strings.Contains(event, constant.EventReasonFailedUpgrading), Equals, true)
+	c.Assert(strings.Contains(event, "will retry until the volume is upgraded back to "+TestEngineImage), Equals, true)
+
+	// The failure after a successful upgrade is recorded again
+	ec.upgradeBackoff.DeleteEntry(e.Name)
+	ec.recordUpgradeFailure(e, fmt.Errorf("failed to upgrade"))
+	c.Assert(recorder.Events, HasLen, 1)
+}
@@ -156,6 +156,9 @@ func (ic *EngineImageController) handleErr(err error, key interface{}) {
 		ic.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(ic.queue, err, key) {
+		return
+	}
 
 	log := ic.logger.WithField("engineImage", key)
 	if ic.queue.NumRequeues(key) < maxRetries {
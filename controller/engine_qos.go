@@ -0,0 +1,60 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/longhorn/longhorn-manager/constant"
+	"github.com/longhorn/longhorn-manager/engineapi"
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+const qosLimitDisabled = -1
+
+// getEngineQoS resolves the limits of the engine of the volume. The limits
+// of the volume override the global settings. The engine image without the
+// QoS support runs without the limits, which is reported by an event on the
+// volume rather than failing the engine.
+func (ec *EngineController) getEngineQoS(v *longhorn.Volume, engineCLIAPIVersion int) (qos engineapi.EngineQoS, err error) {
+	rebuildBandwidthLimit, err := ec.ds.GetSettingAsInt(types.SettingNameReplicaRebuildBandwidthLimit)
+	if err != nil {
+		return qos, err
+	}
+	frontendIOPSLimit, err := ec.ds.GetSettingAsInt(types.SettingNameVolumeFrontendIOPSLimit)
+	if err != nil {
+		return qos, err
+	}
+	frontendBandwidthLimit, err := ec.ds.GetSettingAsInt(types.SettingNameVolumeFrontendBandwidthLimit)
+	if err != nil {
+		return qos, err
+	}
+
+	qos = engineapi.EngineQoS{
+		RebuildBandwidthLimit:  resolveQoSLimit(v.Spec.RebuildBandwidthLimit, rebuildBandwidthLimit),
+		FrontendIOPSLimit:      resolveQoSLimit(v.Spec.FrontendIOPSLimit, frontendIOPSLimit),
+		FrontendBandwidthLimit: resolveQoSLimit(v.Spec.FrontendBandwidthLimit, frontendBandwidthLimit),
+	}
+	if qos == (engineapi.EngineQoS{}) {
+		return qos, nil
+	}
+	if err := engineapi.CheckCLIFeatureSupport(engineapi.EngineFeatureQoS, engineCLIAPIVersion); err != nil {
+		ec.eventRecorder.Eventf(v, v1.EventTypeWarning, constant.EventReasonFailedApplyingQoS,
+			"QoS limits of volume %v are not applied: %v", v.Name, err)
+		return engineapi.EngineQoS{}, nil
+	}
+	return qos, nil
+}
+
+// resolveQoSLimit returns the limit to apply, 0 for no limit. The volume
+// limit 0 follows the setting, and -1 disables the limit for the volume.
+func resolveQoSLimit(volumeLimit, settingLimit int64) int64 {
+	switch {
+	case volumeLimit == qosLimitDisabled:
+		return 0
+	case volumeLimit > 0:
+		return volumeLimit
+	case settingLimit > 0:
+		return settingLimit
+	}
+	return 0
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"strings"
+
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/controller"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/constant"
+	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/engineapi"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	lhfake "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned/fake"
+	lhinformerfactory "github.com/longhorn/longhorn-manager/k8s/pkg/client/informers/externalversions"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *TestSuite) TestResolveQoSLimit(c *C) {
+	c.Assert(resolveQoSLimit(0, 0), Equals, int64(0))
+	c.Assert(resolveQoSLimit(0, 100), Equals, int64(100))
+	c.Assert(resolveQoSLimit(50, 100), Equals, int64(50))
+	c.Assert(resolveQoSLimit(-1, 100), Equals, int64(0))
+}
+
+func (s *TestSuite) TestGetEngineQoS(c *C) {
+	kubeClient := fake.NewSimpleClientset()
+	kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+	lhClient := lhfake.NewSimpleClientset()
+	lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+	extensionsClient := apiextensionsfake.NewSimpleClientset()
+	ds := datastore.NewDataStore(lhInformerFactory, lhClient, kubeInformerFactory, kubeClient, extensionsClient, TestNamespace)
+
+	setting := &longhorn.Setting{
+		ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameVolumeFrontendIOPSLimit), Namespace: TestNamespace},
+		Value:      "100",
+	}
+	err := lhInformerFactory.Longhorn().V1beta2().Settings().Informer().GetIndexer().Add(setting)
+	c.Assert(err, IsNil)
+
+	v := newVolume(TestVolumeName, 2)
+	v.Spec.RebuildBandwidthLimit = 50
+
+	recorder := record.NewFakeRecorder(10)
+	ec := &EngineController{ds: ds, eventRecorder: recorder}
+	qos, err := ec.getEngineQoS(v, engineapi.CLIVersionEight)
+	c.Assert(err, IsNil)
+	c.Assert(qos, DeepEquals, engineapi.EngineQoS{RebuildBandwidthLimit: 50, FrontendIOPSLimit: 100})
+	c.Assert(recorder.Events, HasLen, 0)
+
+	// The engine image without the support runs without the limits, and the
+	// volume gets an event about it
+	qos, err = ec.getEngineQoS(v, engineapi.CLIVersionSeven)
+	c.Assert(err, IsNil)
+	c.Assert(qos, DeepEquals, engineapi.EngineQoS{})
+	c.Assert(recorder.Events, HasLen, 1)
+	c.Assert(strings.HasPrefix(<-recorder.Events, "Warning "+constant.EventReasonFailedApplyingQoS), Equals, true)
+
+	// No event without any limit
+	v.Spec.RebuildBandwidthLimit = qosLimitDisabled
+	v.Spec.FrontendIOPSLimit = qosLimitDisabled
+	qos, err = ec.getEngineQoS(v, engineapi.CLIVersionSeven)
+	c.Assert(err, IsNil)
+	c.Assert(qos, DeepEquals, engineapi.EngineQoS{})
+	c.Assert(recorder.Events, HasLen, 0)
+}
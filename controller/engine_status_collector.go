@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+// EngineStatusCollector runs the status polls of the engine monitors on the
+// node through a bounded pool, so the instance managers aren't flooded by all
+// the engines polling at the same tick. The polls beyond the limit wait for
+// the ongoing ones to finish. The limit is read from the setting on every
+// poll, so a change takes effect without restarting the monitors.
+type EngineStatusCollector struct {
+	logger logrus.FieldLogger
+	ds     *datastore.DataStore
+
+	mutex   *sync.Mutex
+	cond    *sync.Cond
+	running int
+
+	// for unit test
+	limitHandler func() int
+}
+
+func NewEngineStatusCollector(logger logrus.FieldLogger, ds *datastore.DataStore) *EngineStatusCollector {
+	mutex := &sync.Mutex{}
+	c := &EngineStatusCollector{
+		logger: logger,
+		ds:     ds,
+		mutex:  mutex,
+		cond:   sync.NewCond(mutex),
+	}
+	c.limitHandler = c.getLimit
+	return c
+}
+
+func (c *EngineStatusCollector) getLimit() int {
+	limit, err := c.ds.GetSettingAsInt(types.SettingNameEngineStatusPollConcurrency)
+	if err != nil {
+		c.logger.WithError(err).Warnf("Failed to get setting %v, using the default value", types.SettingNameEngineStatusPollConcurrency)
+		limit, _ = strconv.ParseInt(types.SettingDefinitionEngineStatusPollConcurrency.Default, 10, 64)
+	}
+	return int(limit)
+}
+
+// Poll runs the poll once there is a free slot, and returns its result. If
+// stopCh is closed while waiting for a slot, the poll is skipped and true is
+// returned, so the monitor stops.
+func (c *EngineStatusCollector) Poll(stopCh <-chan struct{}, poll func() bool) bool {
+	c.mutex.Lock()
+	for {
+		select {
+		case <-stopCh:
+			c.mutex.Unlock()
+			return true
+		default:
+		}
+		limit := c.limitHandler()
+		if limit < 1 {
+			limit = 1
+		}
+		if c.running < limit {
+			break
+		}
+		c.cond.Wait()
+	}
+	c.running++
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		c.running--
+		c.mutex.Unlock()
+		c.cond.Broadcast()
+	}()
+	return poll()
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestEngineStatusCollectorPoll(t *testing.T) {
+	c := NewEngineStatusCollector(logrus.StandardLogger(), nil)
+	c.limitHandler = func() int { return 2 }
+
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+	poll := func() bool {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+		return false
+	}
+
+	stopCh := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if needStop := c.Poll(stopCh, poll); needStop {
+				t.Errorf("Unexpected stop of poll")
+			}
+		}()
+	}
+	wg.Wait()
+	if maxRunning != 2 {
+		t.Errorf("Expected 2 polls at most at the same time, got %v", maxRunning)
+	}
+
+	close(stopCh)
+	if needStop := c.Poll(stopCh, func() bool {
+		t.Errorf("Unexpected poll after stop")
+		return false
+	}); !needStop {
+		t.Errorf("Expected stop of poll after stop")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -20,6 +21,13 @@ import (
 	"github.com/longhorn/longhorn-manager/engineapi"
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+const (
+	// The restart count of an instance is reset once the process keeps
+	// running for the period, since it's not crash looping.
+	instanceRestartCountResetPeriod = 10 * time.Minute
 )
 
 // InstanceHandler can handle the state transition of correlated instance and
@@ -190,6 +198,10 @@ func (h *InstanceHandler) syncStatusWithInstanceManager(im *longhorn.InstanceMan
 		status.StorageIP = ""
 		status.Port = 0
 	default:
+		// The crashed process is being deleted for the restart
+		if status.CurrentState == longhorn.InstanceStateStopping && !status.Started && spec.DesireState == longhorn.InstanceStateRunning {
+			return
+		}
 		if status.CurrentState != longhorn.InstanceStateError {
 			logrus.Warnf("Instance %v is state %v, error message: %v", instanceName, instance.Status.State, instance.Status.ErrorMsg)
 		}
@@ -381,7 +393,84 @@ func (h *InstanceHandler) ReconcileInstanceState(obj interface{}, spec *longhorn
 					instanceName, im.Name, im.Spec.NodeID)
 			}
 		}
+		if err := h.superviseInstance(instanceName, runtimeObj, im, spec, status, oldState != longhorn.InstanceStateError); err != nil {
+			return err
+		}
+	}
+
+	switch status.CurrentState {
+	case longhorn.InstanceStateRunning:
+		if status.StartedAt == "" {
+			status.StartedAt = util.Now()
+		}
+	case longhorn.InstanceStateUnknown:
+	default:
+		status.StartedAt = ""
+	}
+	return nil
+}
+
+// superviseInstance restarts the process of a running replica in place once
+// it exits unexpectedly, up to the restart limit. The crashed process is
+// deleted here and the instance becomes stopping, then the process is created
+// again by the reconciliation once it's gone. Beyond the limit, the instance
+// stays in error state and the failure is handled as before by the replica
+// rebuilding.
+//
+// A crashed engine is never restarted in place, since its replica address map
+// may still contain a rebuilding replica, which the new process would take as
+// a RW one. The engine stays in error state, so the volume controller marks
+// the volume faulted and reattaches it with the healthy replicas only.
+func (h *InstanceHandler) superviseInstance(instanceName string, obj runtime.Object, im *longhorn.InstanceManager, spec *longhorn.InstanceSpec, status *longhorn.InstanceStatus, crashed bool) error {
+	// The instance is not restarted if it fails to start, or the instance
+	// manager itself is gone
+	if spec.DesireState != longhorn.InstanceStateRunning || !status.Started ||
+		im.Status.CurrentState != longhorn.InstanceManagerStateRunning || im.DeletionTimestamp != nil ||
+		spec.NodeID != im.Spec.NodeID {
+		return nil
+	}
+
+	instance, exists := im.Status.Instances[instanceName]
+	if crashed {
+		status.LastExitReason = "process is gone"
+		if exists {
+			status.LastExitReason = fmt.Sprintf("process is %v", instance.Status.State)
+			if instance.Status.ErrorMsg != "" {
+				status.LastExitReason = fmt.Sprintf("%v: %v", status.LastExitReason, instance.Status.ErrorMsg)
+			}
+		}
+		if status.StartedAt != "" && util.TimestampAfterTimeout(status.StartedAt, instanceRestartCountResetPeriod) {
+			status.RestartCount = 0
+		}
+	}
+	if _, ok := obj.(*longhorn.Replica); !ok {
+		return nil
+	}
+
+	limit, err := h.ds.GetSettingAsInt(types.SettingNameInstanceProcessRestartLimit)
+	if err != nil {
+		return err
+	}
+	if status.RestartCount >= int(limit) {
+		if crashed && limit > 0 {
+			h.eventRecorder.Eventf(obj, v1.EventTypeWarning, constant.EventReasonFailedRestarting,
+				"%v exited unexpectedly (%v) after %v restarts, stop restarting it", instanceName, status.LastExitReason, status.RestartCount)
+		}
+		return nil
+	}
+
+	logrus.Warnf("Restarting instance %v on Instance Manager %v since it exited unexpectedly: %v", instanceName, im.Name, status.LastExitReason)
+	if exists {
+		if err := h.deleteInstance(instanceName, obj); err != nil {
+			return err
+		}
 	}
+	status.Started = false
+	status.CurrentState = longhorn.InstanceStateStopping
+	status.RestartCount++
+	status.LastRestartAt = util.Now()
+	h.eventRecorder.Eventf(obj, v1.EventTypeNormal, constant.EventReasonRestarted,
+		"Restarts %v since it exited unexpectedly (%v), restart count %v", instanceName, status.LastExitReason, status.RestartCount)
 	return nil
 }
 
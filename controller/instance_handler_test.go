@@ -19,6 +19,7 @@ import (
 	"github.com/longhorn/longhorn-manager/datastore"
 	"github.com/longhorn/longhorn-manager/engineapi"
 	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
 
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 	lhfake "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned/fake"
@@ -453,11 +454,143 @@ func (s *TestSuite) TestReconcileInstanceState(c *C) {
 			c.Assert(err, NotNil)
 		} else {
 			c.Assert(err, IsNil)
+			// The start time of the running process is recorded
+			if status.CurrentState == longhorn.InstanceStateRunning {
+				c.Assert(status.StartedAt, Not(Equals), "")
+				if e, ok := tc.expectedObj.(*longhorn.Engine); ok && e.Status.StartedAt == "" {
+					e.Status.StartedAt = status.StartedAt
+				}
+			}
 			c.Assert(tc.obj, DeepEquals, tc.expectedObj)
 		}
 	}
 }
 
+func newCrashedReplica(name, imName, nodeName, ip string, port int) *longhorn.Replica {
+	return &longhorn.Replica{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: TestNamespace,
+			Labels:    types.GetVolumeLabels(TestVolumeName),
+		},
+		Spec: longhorn.ReplicaSpec{
+			InstanceSpec: longhorn.InstanceSpec{
+				VolumeName:  TestVolumeName,
+				VolumeSize:  TestVolumeSize,
+				DesireState: longhorn.InstanceStateRunning,
+				NodeID:      nodeName,
+				EngineImage: TestEngineImage,
+			},
+		},
+		Status: longhorn.ReplicaStatus{
+			InstanceStatus: longhorn.InstanceStatus{
+				OwnerID:             TestOwnerID1,
+				CurrentState:        longhorn.InstanceStateRunning,
+				CurrentImage:        TestEngineImage,
+				InstanceManagerName: imName,
+				IP:                  ip,
+				StorageIP:           ip,
+				Port:                port,
+				Started:             true,
+			},
+		},
+	}
+}
+
+func (s *TestSuite) TestReconcileInstanceStateRestartsCrashedInstance(c *C) {
+	testCases := map[string]struct {
+		imType       longhorn.InstanceManagerType
+		engine       bool
+		restartCount int
+
+		expectedState        longhorn.InstanceState
+		expectedStarted      bool
+		expectedRestartCount int
+	}{
+		"crashed replica is restarted": {
+			longhorn.InstanceManagerTypeReplica, false, 0,
+			longhorn.InstanceStateStopping, false, 1,
+		},
+		"crashed replica reaches the restart limit": {
+			longhorn.InstanceManagerTypeReplica, false, 3,
+			longhorn.InstanceStateError, true, 3,
+		},
+		// The replica address map still contains a rebuilding replica, so the
+		// engine is left to the volume reattachment
+		"crashed engine with rebuilding replica is not restarted": {
+			longhorn.InstanceManagerTypeEngine, true, 0,
+			longhorn.InstanceStateError, true, 0,
+		},
+	}
+	for name, tc := range testCases {
+		fmt.Printf("testing instance handler: %v\n", name)
+
+		kubeClient := fake.NewSimpleClientset()
+		kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+		lhClient := lhfake.NewSimpleClientset()
+		lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+		extensionsClient := apiextensionsfake.NewSimpleClientset()
+
+		h := newTestInstanceHandler(lhInformerFactory, kubeInformerFactory, lhClient, kubeClient, extensionsClient)
+
+		ei := newEngineImage(TestEngineImage, longhorn.EngineImageStateDeployed)
+		err := lhInformerFactory.Longhorn().V1beta2().EngineImages().Informer().GetIndexer().Add(ei)
+		c.Assert(err, IsNil)
+		err = lhInformerFactory.Longhorn().V1beta2().Settings().Informer().GetIndexer().Add(newDefaultInstanceManagerImageSetting())
+		c.Assert(err, IsNil)
+
+		im := newInstanceManager(TestInstanceManagerName1, tc.imType, longhorn.InstanceManagerStateRunning, TestOwnerID1, TestNode1, TestIP1,
+			map[string]longhorn.InstanceProcess{
+				ExistingInstance: {
+					Spec: longhorn.InstanceProcessSpec{
+						Name: ExistingInstance,
+					},
+					Status: longhorn.InstanceProcessStatus{
+						State:    longhorn.InstanceStateError,
+						ErrorMsg: "exit status 1",
+					},
+				},
+			}, false)
+		err = lhInformerFactory.Longhorn().V1beta2().InstanceManagers().Informer().GetIndexer().Add(im)
+		c.Assert(err, IsNil)
+		err = kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer().Add(
+			newPod(&corev1.PodStatus{PodIP: TestIP1, Phase: corev1.PodRunning}, im.Name, im.Namespace, im.Spec.NodeID))
+		c.Assert(err, IsNil)
+
+		var obj runtime.Object
+		var status *longhorn.InstanceStatus
+		var spec *longhorn.InstanceSpec
+		if tc.engine {
+			e := newEngine(ExistingInstance, TestEngineImage, TestInstanceManagerName1, TestNode1, TestIP1, TestPort1, true, longhorn.InstanceStateRunning, longhorn.InstanceStateRunning)
+			e.Spec.ReplicaAddressMap = map[string]string{
+				"replica-healthy":    "10.0.0.1:10000",
+				"replica-rebuilding": "10.0.0.2:10000",
+			}
+			e.Status.CurrentReplicaAddressMap = e.Spec.ReplicaAddressMap
+			e.Status.ReplicaModeMap = map[string]longhorn.ReplicaMode{
+				"replica-healthy":    longhorn.ReplicaModeRW,
+				"replica-rebuilding": longhorn.ReplicaModeWO,
+			}
+			obj, spec, status = e, &e.Spec.InstanceSpec, &e.Status.InstanceStatus
+		} else {
+			r := newCrashedReplica(ExistingInstance, TestInstanceManagerName1, TestNode1, TestIP1, TestPort1)
+			obj, spec, status = r, &r.Spec.InstanceSpec, &r.Status.InstanceStatus
+		}
+		status.StartedAt = util.Now()
+		status.RestartCount = tc.restartCount
+
+		err = h.ReconcileInstanceState(obj, spec, status)
+		c.Assert(err, IsNil)
+		c.Assert(status.CurrentState, Equals, tc.expectedState)
+		c.Assert(status.Started, Equals, tc.expectedStarted)
+		c.Assert(status.RestartCount, Equals, tc.expectedRestartCount)
+		c.Assert(status.LastExitReason, Equals, "process is error: exit status 1")
+		c.Assert(status.StartedAt, Equals, "")
+		c.Assert(status.IP, Equals, "")
+		c.Assert(status.LastRestartAt != "", Equals, tc.expectedRestartCount > tc.restartCount)
+	}
+}
+
 func newTestInstanceHandler(lhInformerFactory lhinformerfactory.SharedInformerFactory, kubeInformerFactory informers.SharedInformerFactory,
 	lhClient *lhfake.Clientset, kubeClient *fake.Clientset, extensionsClient *apiextensionsfake.Clientset) *InstanceHandler {
 	ds := datastore.NewDataStore(lhInformerFactory, lhClient, kubeInformerFactory, kubeClient, extensionsClient, TestNamespace)
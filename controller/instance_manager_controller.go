@@ -242,6 +242,9 @@ func (imc *InstanceManagerController) handleErr(err error, key interface{}) {
 		imc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(imc.queue, err, key) {
+		return
+	}
 
 	if imc.queue.NumRequeues(key) < maxRetries {
 		logrus.Warnf("Error syncing Longhorn instance manager %v: %v", key, err)
@@ -115,6 +115,9 @@ func (pc *KubernetesPDBController) handleErr(err error, key interface{}) {
 		pc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(pc.queue, err, key) {
+		return
+	}
 
 	pc.logger.WithError(err).Warnf("Error syncing PDB for %v", key)
 	pc.queue.AddRateLimited(key)
@@ -126,6 +126,9 @@ func (kc *KubernetesConfigMapController) handleErr(err error, key interface{}) {
 		kc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(kc.queue, err, key) {
+		return
+	}
 
 	if kc.queue.NumRequeues(key) < maxRetries {
 		kc.logger.WithError(err).Warnf("Error syncing ConfigMap %v", key)
@@ -148,6 +148,9 @@ func (knc *KubernetesNodeController) handleErr(err error, key interface{}) {
 		knc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(knc.queue, err, key) {
+		return
+	}
 
 	if knc.queue.NumRequeues(key) < maxRetries {
 		logrus.Warnf("Error syncing Longhorn node %v: %v", key, err)
@@ -292,7 +295,7 @@ func (knc *KubernetesNodeController) syncDefaultDisks(node *longhorn.Node) (err
 		if err != nil {
 			return err
 		}
-		storageReservedPercentageForDefaultDisk, err := knc.ds.GetSettingAsInt(types.SettingNameStorageReservedPercentageForDefaultDisk)
+		storageReservedPercentageForDefaultDisk, err := knc.ds.GetNodeSettingAsInt(types.SettingNameStorageReservedPercentageForDefaultDisk, kubeNode.Name)
 		if err != nil {
 			return err
 		}
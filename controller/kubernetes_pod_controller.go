@@ -116,6 +116,9 @@ func (kc *KubernetesPodController) handleErr(err error, key interface{}) {
 		kc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(kc.queue, err, key) {
+		return
+	}
 
 	if kc.queue.NumRequeues(key) < maxRetries {
 		kc.logger.WithError(err).Warnf("%v: Error syncing Longhorn kubernetes pod %v", controllerAgentName, key)
@@ -137,6 +137,9 @@ func (kc *KubernetesPVController) handleErr(err error, key interface{}) {
 		kc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(kc.queue, err, key) {
+		return
+	}
 
 	if kc.queue.NumRequeues(key) < maxRetries {
 		logrus.Warnf("Error syncing Longhorn volume kubernetes status %v: %v", key, err)
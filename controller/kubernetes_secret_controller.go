@@ -126,6 +126,9 @@ func (ks *KubernetesSecretController) handleErr(err error, key interface{}) {
 		ks.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(ks.queue, err, key) {
+		return
+	}
 
 	if ks.queue.NumRequeues(key) < maxRetries {
 		ks.logger.WithError(err).Warnf("Error syncing Secret %v", key)
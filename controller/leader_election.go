@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	LeaderElectionLeaseName = "longhorn-manager-leader"
+
+	leaderElectionLeaseDuration = 20 * time.Second
+	leaderElectionRenewDeadline = 10 * time.Second
+	leaderElectionRetryPeriod   = 2 * time.Second
+)
+
+// LeaderCallbacks are called when this manager becomes the leader or loses
+// the leadership. They shouldn't block.
+type LeaderCallbacks struct {
+	OnStartedLeading func()
+	OnStoppedLeading func()
+}
+
+// LeaderElector elects one manager in the cluster for the cluster-scoped
+// work, e.g. polling the backup target. The leadership is held by renewing a
+// lease, so it moves to another manager once the leader fails to renew it.
+type LeaderElector struct {
+	logger     logrus.FieldLogger
+	kubeClient clientset.Interface
+	namespace  string
+	identity   string
+
+	lock      sync.RWMutex
+	isLeader  bool
+	callbacks []LeaderCallbacks
+}
+
+func NewLeaderElector(logger logrus.FieldLogger, kubeClient clientset.Interface, namespace, identity string) *LeaderElector {
+	return &LeaderElector{
+		logger:     logger.WithField("component", "leader-elector"),
+		kubeClient: kubeClient,
+		namespace:  namespace,
+		identity:   identity,
+	}
+}
+
+// AddCallbacks registers the callbacks of a singleton task. It should be
+// called before Run.
+func (le *LeaderElector) AddCallbacks(callbacks LeaderCallbacks) {
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	le.callbacks = append(le.callbacks, callbacks)
+}
+
+func (le *LeaderElector) IsLeader() bool {
+	le.lock.RLock()
+	defer le.lock.RUnlock()
+	return le.isLeader
+}
+
+func (le *LeaderElector) Run(stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      LeaderElectionLeaseName,
+			Namespace: le.namespace,
+		},
+		Client: le.kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: le.identity,
+		},
+	}
+
+	// RunOrDie returns once the leadership is lost, then this manager runs
+	// for the next election
+	wait.Until(func() {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			ReleaseOnCancel: true,
+			LeaseDuration:   leaderElectionLeaseDuration,
+			RenewDeadline:   leaderElectionRenewDeadline,
+			RetryPeriod:     leaderElectionRetryPeriod,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(context.Context) { le.setLeader(true) },
+				OnStoppedLeading: func() { le.setLeader(false) },
+				OnNewLeader: func(identity string) {
+					le.logger.Infof("Manager %v is elected as the leader", identity)
+				},
+			},
+		})
+	}, time.Second, stopCh)
+}
+
+func (le *LeaderElector) setLeader(isLeader bool) {
+	le.lock.Lock()
+	// OnStoppedLeading is called even if this manager never leads
+	if le.isLeader == isLeader {
+		le.lock.Unlock()
+		return
+	}
+	le.isLeader = isLeader
+	callbacks := le.callbacks
+	le.lock.Unlock()
+
+	if isLeader {
+		le.logger.Info("Started leading")
+	} else {
+		le.logger.Info("Stopped leading")
+	}
+	for _, c := range callbacks {
+		if isLeader && c.OnStartedLeading != nil {
+			c.OnStartedLeading()
+		} else if !isLeader && c.OnStoppedLeading != nil {
+			c.OnStoppedLeading()
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -41,6 +42,7 @@ type NodeMonitor struct {
 	getDiskConfig                    GetDiskConfig
 	generateDiskConfig               GenerateDiskConfig
 	getPossibleReplicaDirectoryNames GetPossibleReplicaDirectoryNames
+	getReplicaDirectoryActualSizes   GetReplicaDirectoryActualSizes
 }
 
 type CollectedDiskInfo struct {
@@ -50,12 +52,19 @@ type CollectedDiskInfo struct {
 	DiskUUID                      string
 	Condition                     *longhorn.Condition
 	OrphanedReplicaDirectoryNames map[string]string
+	// The volume metadata of the orphaned replica directories, by the
+	// directory name. Used to validate the data before it's imported.
+	OrphanedReplicaVolumeMetas map[string]*util.VolumeMeta
+	// The allocated size of the replicas on the disk, by the data directory
+	// name
+	ReplicaActualSizes map[string]int64
 }
 
 type GetDiskStatHandler func(string) (*util.DiskStat, error)
 type GetDiskConfig func(string) (*util.DiskConfig, error)
 type GenerateDiskConfig func(string) (*util.DiskConfig, error)
 type GetPossibleReplicaDirectoryNames func(*longhorn.Node, string, string, string) map[string]string
+type GetReplicaDirectoryActualSizes func(string, []string) (map[string]int64, error)
 
 func NewDiskMonitor(logger logrus.FieldLogger, ds *datastore.DataStore, nodeName string, syncCallback func(key string)) (*NodeMonitor, error) {
 	ctx, quit := context.WithCancel(context.Background())
@@ -75,6 +84,7 @@ func NewDiskMonitor(logger logrus.FieldLogger, ds *datastore.DataStore, nodeName
 		getDiskConfig:                    util.GetDiskConfig,
 		generateDiskConfig:               util.GenerateDiskConfig,
 		getPossibleReplicaDirectoryNames: getPossibleReplicaDirectoryNames,
+		getReplicaDirectoryActualSizes:   util.GetReplicaDirectoryActualSizes,
 	}
 
 	go m.Start()
@@ -174,10 +184,12 @@ func (m *NodeMonitor) collectDiskData(node *longhorn.Node) map[string]*Collected
 		}
 
 		replicaDirectoryNames := m.getPossibleReplicaDirectoryNames(node, diskName, diskConfig.DiskUUID, disk.Path)
-		orphanedReplicaDirectoryNames := m.getOrphanedReplicaDirectoryNames(node, diskName, diskConfig.DiskUUID, disk.Path, replicaDirectoryNames)
+		orphanedReplicaDirectoryNames, orphanedReplicaVolumeMetas := m.getOrphanedReplicaDirectoryNames(node, diskName, diskConfig.DiskUUID, disk.Path, replicaDirectoryNames)
 
 		diskInfoMap[diskName] = NewDiskInfo(disk.Path, diskConfig.DiskUUID, nodeOrDiskEvicted, stat,
 			orphanedReplicaDirectoryNames, string(longhorn.DiskConditionReasonNoDiskInfo), "")
+		diskInfoMap[diskName].OrphanedReplicaVolumeMetas = orphanedReplicaVolumeMetas
+		diskInfoMap[diskName].ReplicaActualSizes = m.getReplicaActualSizes(node, diskName, diskConfig.DiskUUID, disk.Path)
 	}
 
 	return diskInfoMap
@@ -232,16 +244,17 @@ func NewDiskInfo(path, diskUUID string, nodeOrDiskEvicted bool, stat *util.DiskS
 	return diskInfo
 }
 
-func (m *NodeMonitor) getOrphanedReplicaDirectoryNames(node *longhorn.Node, diskName, diskUUID, diskPath string, replicaDirectoryNames map[string]string) map[string]string {
+func (m *NodeMonitor) getOrphanedReplicaDirectoryNames(node *longhorn.Node, diskName, diskUUID, diskPath string, replicaDirectoryNames map[string]string) (map[string]string, map[string]*util.VolumeMeta) {
+	volumeMetas := map[string]*util.VolumeMeta{}
 	if len(replicaDirectoryNames) == 0 {
-		return map[string]string{}
+		return map[string]string{}, volumeMetas
 	}
 
 	// Find out the orphaned directories by checking with replica CRs
 	replicas, err := m.ds.ListReplicasByDiskUUID(diskUUID)
 	if err != nil {
 		logrus.Errorf("unable to list replicas for disk UUID %v since %v", diskUUID, err.Error())
-		return map[string]string{}
+		return map[string]string{}, volumeMetas
 	}
 
 	for _, replica := range replicas {
@@ -252,19 +265,47 @@ func (m *NodeMonitor) getOrphanedReplicaDirectoryNames(node *longhorn.Node, disk
 
 	if m.checkVolumeMeta {
 		for name := range replicaDirectoryNames {
-			if err := isVolumeMetaFileExist(diskPath, name); err != nil {
+			meta, err := getVolumeMeta(diskPath, name)
+			if err != nil {
 				delete(replicaDirectoryNames, name)
+				continue
 			}
+			volumeMetas[name] = meta
+		}
+	}
+
+	return replicaDirectoryNames, volumeMetas
+}
+
+func (m *NodeMonitor) getReplicaActualSizes(node *longhorn.Node, diskName, diskUUID, diskPath string) map[string]int64 {
+	if !canCollectDiskData(node, diskName, diskUUID, diskPath) {
+		return map[string]int64{}
+	}
+
+	replicas, err := m.ds.ListReplicasByDiskUUID(diskUUID)
+	if err != nil {
+		logrus.Errorf("unable to list replicas for disk UUID %v since %v", diskUUID, err.Error())
+		return map[string]int64{}
+	}
+	replicaDirectoryNames := []string{}
+	for _, replica := range replicas {
+		if replica.Spec.DiskPath == diskPath && replica.Spec.DataDirectoryName != "" {
+			replicaDirectoryNames = append(replicaDirectoryNames, replica.Spec.DataDirectoryName)
 		}
 	}
+	sort.Strings(replicaDirectoryNames)
 
-	return replicaDirectoryNames
+	actualSizes, err := m.getReplicaDirectoryActualSizes(diskPath, replicaDirectoryNames)
+	if err != nil {
+		logrus.Errorf("unable to get replica actual sizes in disk %v on node %v since %v", diskPath, node.Name, err.Error())
+		return map[string]int64{}
+	}
+	return actualSizes
 }
 
-func isVolumeMetaFileExist(diskPath, replicaDirectoryName string) error {
+func getVolumeMeta(diskPath, replicaDirectoryName string) (*util.VolumeMeta, error) {
 	path := filepath.Join(diskPath, "replicas", replicaDirectoryName, volumeMetaData)
-	_, err := util.GetVolumeMeta(path)
-	return err
+	return util.GetVolumeMeta(path)
 }
 
 func GetDiskNamesFromDiskMap(diskInfoMap map[string]*CollectedDiskInfo) []string {
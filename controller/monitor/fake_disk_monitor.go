@@ -35,6 +35,7 @@ func NewFakeNodeMonitor(logger logrus.FieldLogger, ds *datastore.DataStore, node
 		getDiskConfig:                    fakeGetDiskConfig,
 		generateDiskConfig:               fakeGenerateDiskConfig,
 		getPossibleReplicaDirectoryNames: fakeGetPossibleReplicaDirectoryNames,
+		getReplicaDirectoryActualSizes:   fakeGetReplicaDirectoryActualSizes,
 	}
 
 	return m, nil
@@ -46,6 +47,10 @@ func fakeGetPossibleReplicaDirectoryNames(node *longhorn.Node, diskName, diskUUI
 	}
 }
 
+func fakeGetReplicaDirectoryActualSizes(diskPath string, replicaDirectoryNames []string) (map[string]int64, error) {
+	return map[string]int64{}, nil
+}
+
 func fakeGetDiskStat(directory string) (*util.DiskStat, error) {
 	return &util.DiskStat{
 		Fsid:       "fsid",
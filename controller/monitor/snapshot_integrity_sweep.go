@@ -0,0 +1,164 @@
+package monitor
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	etypes "github.com/longhorn/longhorn-engine/pkg/types"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+const (
+	integritySweepPeriod         = time.Hour
+	integritySweepPeriodsPerWeek = 7 * 24
+
+	bytesPerGiB = int64(1) << 30
+)
+
+type integritySweepCandidate struct {
+	volumeName    string
+	snapshotName  string
+	lastCheckedAt string
+	// The bytes read to hash the snapshot on all the replicas
+	cost int64
+}
+
+// sweepSnapshots verifies a sampled snapshot of the volumes on the node
+// within the I/O budget. The unused budget is accumulated up to a week, so a
+// snapshot larger than the hourly budget is verified once enough is saved.
+func (m *SnapshotMonitor) sweepSnapshots() {
+	log := m.logger.WithField("monitor", monitorName)
+
+	budgetGiB, err := m.ds.GetSettingAsInt(types.SettingNameSnapshotIntegritySweepWeeklyBudget)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get snapshot integrity sweep budget")
+		return
+	}
+	if budgetGiB <= 0 {
+		m.integritySweepAllowance = 0
+		return
+	}
+	weeklyBudget := budgetGiB * bytesPerGiB
+	m.integritySweepAllowance += weeklyBudget / integritySweepPeriodsPerWeek
+	if m.integritySweepAllowance > weeklyBudget {
+		m.integritySweepAllowance = weeklyBudget
+	}
+
+	engines, err := m.ds.ListEnginesByNodeRO(m.nodeName)
+	if err != nil {
+		log.WithError(err).Warnf("Failed to list engines on node %v for snapshot integrity sweep", m.nodeName)
+		return
+	}
+	candidates := []*integritySweepCandidate{}
+	for _, e := range engines {
+		c, err := m.getIntegritySweepCandidate(e)
+		if err != nil {
+			log.WithError(err).Warnf("Failed to get snapshot integrity sweep candidate of volume %v", e.Spec.VolumeName)
+			continue
+		}
+		if c != nil {
+			candidates = append(candidates, c)
+		}
+	}
+	sortIntegritySweepCandidates(candidates)
+
+	for _, c := range candidates {
+		// Don't skip to the next volumes, so the least recently checked one
+		// isn't starved by the smaller snapshots of the others
+		if c.cost > m.integritySweepAllowance && m.integritySweepAllowance < weeklyBudget {
+			break
+		}
+		m.integritySweepAllowance -= c.cost
+		log.Infof("Sweeping snapshot %v of volume %v for integrity", c.snapshotName, c.volumeName)
+		m.snapshotCheckTaskQueue.Add(snapshotCheckTask{
+			volumeName:   c.volumeName,
+			snapshotName: c.snapshotName,
+			sweep:        true,
+		})
+	}
+}
+
+func (m *SnapshotMonitor) getIntegritySweepCandidate(e *longhorn.Engine) (*integritySweepCandidate, error) {
+	if e.Status.CurrentState != longhorn.InstanceStateRunning {
+		return nil, nil
+	}
+	v, err := m.ds.GetVolumeRO(e.Spec.VolumeName)
+	if err != nil {
+		if datastore.ErrorIsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// The same snapshots as the ones checked by the periodic check
+	snapshots := []*longhorn.SnapshotInfo{}
+	for _, snapshot := range e.Status.Snapshots {
+		if snapshot.Name == etypes.VolumeHeadName || !snapshot.UserCreated {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if len(snapshots) == 0 {
+		return nil, nil
+	}
+	snapshot := snapshots[rand.Intn(len(snapshots))]
+	size, _ := strconv.ParseInt(snapshot.Size, 10, 64)
+
+	replicas := 0
+	for _, mode := range e.Status.ReplicaModeMap {
+		if mode == longhorn.ReplicaModeRW {
+			replicas++
+		}
+	}
+	return &integritySweepCandidate{
+		volumeName:    v.Name,
+		snapshotName:  snapshot.Name,
+		lastCheckedAt: v.Status.LastIntegrityCheckedAt,
+		cost:          size * int64(replicas),
+	}, nil
+}
+
+// sortIntegritySweepCandidates puts the volumes never checked first, then the
+// least recently checked ones.
+func sortIntegritySweepCandidates(candidates []*integritySweepCandidate) {
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].lastCheckedAt != candidates[j].lastCheckedAt {
+			return candidates[i].lastCheckedAt < candidates[j].lastCheckedAt
+		}
+		return candidates[i].volumeName < candidates[j].volumeName
+	})
+}
+
+// recordIntegritySweepResult records the result in the volume status. The
+// errors of the checks that couldn't start, e.g. during rebuilding, are not
+// results.
+func (m *SnapshotMonitor) recordIntegritySweepResult(volumeName string, checkErr error) {
+	if checkErr != nil && strings.Contains(checkErr.Error(), etypes.CannotRequestHashingSnapshotPrefix) {
+		return
+	}
+
+	now := util.Now()
+	if _, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		v, err := m.ds.GetVolume(volumeName)
+		if err != nil {
+			return nil, err
+		}
+		v.Status.LastIntegrityCheckedAt = now
+		if checkErr == nil {
+			v.Status.LastIntegrityVerifiedAt = now
+			v.Status.IntegrityCheckError = ""
+		} else {
+			v.Status.IntegrityCheckError = checkErr.Error()
+		}
+		return m.ds.UpdateVolumeStatus(v)
+	}); err != nil {
+		m.logger.WithField("monitor", monitorName).WithError(err).Warnf("Failed to record snapshot integrity sweep result of volume %v", volumeName)
+	}
+}
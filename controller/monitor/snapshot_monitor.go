@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +18,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
@@ -53,6 +55,9 @@ type snapshotCheckTask struct {
 	volumeName   string
 	snapshotName string
 	changeEvent  bool
+	// sweep is set for the checks of the integrity sweep, which are done
+	// regardless of the snapshot data integrity setting
+	sweep bool
 }
 
 type SnapshotMonitorStatus struct {
@@ -76,6 +81,9 @@ type SnapshotMonitor struct {
 
 	existingDataIntegrityCronJob string
 
+	// The bytes the integrity sweep can read, only accessed by the sweep
+	integritySweepAllowance int64
+
 	syncCallback func(key string)
 
 	proxyConnCounter util.Counter
@@ -123,6 +131,8 @@ func (m *SnapshotMonitor) Start() {
 	}
 
 	go m.processSnapshotChangeEvent()
+
+	go wait.Until(m.sweepSnapshots, integritySweepPeriod, m.ctx.Done())
 }
 
 func (m *SnapshotMonitor) processNextEvent() bool {
@@ -202,7 +212,7 @@ func (m *SnapshotMonitor) processNextWorkItem(id int) bool {
 		return true
 	}
 
-	if dataIntegrity == longhorn.SnapshotDataIntegrityDisabled {
+	if dataIntegrity == longhorn.SnapshotDataIntegrityDisabled && !task.sweep {
 		return true
 	}
 
@@ -314,7 +324,7 @@ func (m *SnapshotMonitor) deleteFromInProgressSnapshotCheckTasks(snapshotName st
 	delete(m.inProgressSnapshotCheckTasks, snapshotName)
 }
 
-func (m *SnapshotMonitor) run(arg interface{}) error {
+func (m *SnapshotMonitor) run(arg interface{}) (err error) {
 	task, ok := arg.(snapshotCheckTask)
 	if !ok {
 		return fmt.Errorf("failed to assert value: %v", arg)
@@ -325,6 +335,12 @@ func (m *SnapshotMonitor) run(arg interface{}) error {
 	}
 	defer m.deleteFromInProgressSnapshotCheckTasks(task.snapshotName)
 
+	if task.sweep {
+		defer func() {
+			m.recordIntegritySweepResult(task.volumeName, err)
+		}()
+	}
+
 	engine, err := m.ds.GetVolumeCurrentEngine(task.volumeName)
 	if err != nil {
 		return errors.Wrapf(err, "failed to get engine for volume %v", task.volumeName)
@@ -345,7 +361,7 @@ func (m *SnapshotMonitor) run(arg interface{}) error {
 	}
 	defer engineClientProxy.Close()
 
-	err = m.requestSnapshotHashing(engine, engineClientProxy, task.snapshotName, task.changeEvent)
+	err = m.requestSnapshotHashing(engine, engineClientProxy, task.snapshotName, task.changeEvent, task.sweep)
 	if err != nil {
 		return err
 	}
@@ -354,6 +370,14 @@ func (m *SnapshotMonitor) run(arg interface{}) error {
 }
 
 func (m *SnapshotMonitor) canRequestSnapshotHash(engine *longhorn.Engine) error {
+	cliAPIVersion, err := m.ds.GetEngineImageCLIAPIVersion(engine.Status.CurrentImage)
+	if err != nil {
+		return err
+	}
+	if err := engineapi.CheckCLIFeatureSupport(engineapi.EngineFeatureSnapshotHash, cliAPIVersion); err != nil {
+		return err
+	}
+
 	if err := m.checkVolumeIsNotPurging(engine); err != nil {
 		return err
 	}
@@ -374,14 +398,15 @@ func (m *SnapshotMonitor) canRequestSnapshotHash(engine *longhorn.Engine) error
 }
 
 func (m *SnapshotMonitor) requestSnapshotHashing(engine *longhorn.Engine, engineClientProxy engineapi.EngineClientProxy,
-	snapshotName string, changeEvent bool) error {
+	snapshotName string, changeEvent, sweep bool) error {
 	// One snapshot CR might be updated many times in a short period.
 	// The checksum calculation is expected to run once if it is triggered by snapshot update event.
 	// So, if refresh is false and the checksum is existing, don't need to calculate it again if the ctime is not changed.
 	// The periodc snapshot check mechanism will do the regular checks.
 	// In other words, the full hash will be issued by the cron job only, no matter if the field is enabled or fast-check.
-	rehash := false
-	if !changeEvent {
+	// The integrity sweep always does the full hash to verify the data.
+	rehash := sweep
+	if !changeEvent && !sweep {
 		dataIntegrity, err := m.getSnapshotDataIntegrity(engine.Spec.VolumeName)
 		if err != nil {
 			return err
@@ -475,6 +500,7 @@ func (m *SnapshotMonitor) syncHashStatusFromEngineReplicas(engine *longhorn.Engi
 	if err != nil {
 		m.eventRecorder.Eventf(engine, v1.EventTypeWarning, constant.EventReasonFailedSnapshotDataIntegrityCheck,
 			"Failed to check the data integrity of snapshot %v for volume %v", snapshotName, engine.Spec.VolumeName)
+		m.syncDataCorruptionCondition(engine.Spec.VolumeName, snapshotName, fmt.Sprintf("Failed to determine the checksum of snapshot %v: %v", snapshotName, err))
 		return errors.Wrapf(err, "failed to determine checksum for snapshot %v", snapshotName)
 	}
 
@@ -486,17 +512,100 @@ func (m *SnapshotMonitor) syncHashStatusFromEngineReplicas(engine *longhorn.Engi
 		}
 	}
 
-	m.kickOutCorruptedReplicas(engine, engineClientProxy, checksum, hashStatus)
+	corrupted := m.kickOutCorruptedReplicas(engine, engineClientProxy, checksum, hashStatus)
+	if len(corrupted) > 0 {
+		sort.Strings(corrupted)
+		m.syncDataCorruptionCondition(engine.Spec.VolumeName, snapshotName, fmt.Sprintf("The checksums of snapshot %v on replicas %v mismatched, the replicas are rebuilt",
+			snapshotName, strings.Join(corrupted, ", ")))
+	} else {
+		m.syncDataCorruptionCondition(engine.Spec.VolumeName, snapshotName, "")
+	}
 
 	return nil
 }
 
+// syncDataCorruptionCondition records the result of the check of the snapshot
+// on it, and sets the data corruption condition of the volume if there is a
+// message. Otherwise it only clears the condition set by a snapshot check,
+// rather than by a backup verification, once none of the checked snapshots of
+// the volume is corrupted.
+func (m *SnapshotMonitor) syncDataCorruptionCondition(volumeName, snapshotName, message string) {
+	log := m.logger.WithField("monitor", monitorName)
+	if err := m.markSnapshotCorrupted(snapshotName, message != ""); err != nil {
+		log.WithError(err).Warnf("Failed to record the check result of snapshot %v", snapshotName)
+		return
+	}
+	if _, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		v, err := m.ds.GetVolume(volumeName)
+		if err != nil {
+			return nil, err
+		}
+		condition := types.GetCondition(v.Status.Conditions, longhorn.VolumeConditionTypeDataCorruption)
+		if message != "" {
+			v.Status.Conditions = types.SetCondition(v.Status.Conditions,
+				longhorn.VolumeConditionTypeDataCorruption, longhorn.ConditionStatusTrue,
+				longhorn.VolumeConditionReasonSnapshotChecksumMismatch, message)
+		} else if condition.Status == longhorn.ConditionStatusTrue && condition.Reason == longhorn.VolumeConditionReasonSnapshotChecksumMismatch {
+			snapshots, err := m.ds.ListVolumeSnapshotsRO(volumeName)
+			if err != nil {
+				return nil, err
+			}
+			for _, snapshot := range snapshots {
+				// The label of the checked one may not be seen removed yet
+				if snapshot.Name == snapshotName {
+					continue
+				}
+				if _, corrupted := snapshot.Labels[types.GetLonghornLabelKey(types.LonghornLabelDataCorruption)]; corrupted {
+					return v, nil
+				}
+			}
+			v.Status.Conditions = types.SetCondition(v.Status.Conditions,
+				longhorn.VolumeConditionTypeDataCorruption, longhorn.ConditionStatusFalse, "", "")
+		} else {
+			return v, nil
+		}
+		return m.ds.UpdateVolumeStatus(v)
+	}); err != nil {
+		log.WithError(err).Warnf("Failed to sync data corruption condition of volume %v", volumeName)
+	}
+}
+
+// markSnapshotCorrupted labels the snapshot if its last check found it
+// corrupted. The label is removed once a check finds it clean again, e.g.
+// after the corrupted replicas are rebuilt.
+func (m *SnapshotMonitor) markSnapshotCorrupted(snapshotName string, corrupted bool) error {
+	_, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		snapshot, err := m.ds.GetSnapshot(snapshotName)
+		if err != nil {
+			if datastore.ErrorIsNotFound(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		key := types.GetLonghornLabelKey(types.LonghornLabelDataCorruption)
+		if _, marked := snapshot.Labels[key]; marked == corrupted {
+			return snapshot, nil
+		}
+		if corrupted {
+			if snapshot.Labels == nil {
+				snapshot.Labels = map[string]string{}
+			}
+			snapshot.Labels[key] = "true"
+		} else {
+			delete(snapshot.Labels, key)
+		}
+		return m.ds.UpdateSnapshot(snapshot)
+	})
+	return err
+}
+
 func (m *SnapshotMonitor) kickOutCorruptedReplicas(engine *longhorn.Engine, engineClientProxy engineapi.EngineClientProxy,
-	checksum string, hashStatus map[string]*longhorn.HashStatus) {
+	checksum string, hashStatus map[string]*longhorn.HashStatus) (corrupted []string) {
 	for address, status := range hashStatus {
 		if status.Checksum == checksum {
 			continue
 		}
+		corrupted = append(corrupted, address)
 
 		m.eventRecorder.Eventf(engine, v1.EventTypeWarning, constant.EventReasonFaulted, "Detected corrupted replica %v", address)
 
@@ -504,6 +613,7 @@ func (m *SnapshotMonitor) kickOutCorruptedReplicas(engine *longhorn.Engine, engi
 			m.logger.WithField("monitor", monitorName).Errorf("failed to update replica %v mode to ERR", address)
 		}
 	}
+	return corrupted
 }
 
 func determineChecksumFromHashStatus(log logrus.FieldLogger, snapshotName, existingChecksum string, hashStatus map[string]*longhorn.HashStatus) (string, error) {
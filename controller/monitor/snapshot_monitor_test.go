@@ -3,10 +3,16 @@ package monitor
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 )
 
@@ -546,3 +552,74 @@ func TestDetermineChecksumFromHashStatus(t *testing.T) {
 		}
 	}
 }
+
+func TestSortIntegritySweepCandidates(t *testing.T) {
+	assert := require.New(t)
+
+	candidates := []*integritySweepCandidate{
+		{volumeName: "vol-recent", lastCheckedAt: "2023-01-08T00:00:00Z"},
+		{volumeName: "vol-never-b"},
+		{volumeName: "vol-old", lastCheckedAt: "2023-01-01T00:00:00Z"},
+		{volumeName: "vol-never-a"},
+	}
+	sortIntegritySweepCandidates(candidates)
+
+	names := []string{}
+	for _, c := range candidates {
+		names = append(names, c.volumeName)
+	}
+	assert.Equal([]string{"vol-never-a", "vol-never-b", "vol-old", "vol-recent"}, names)
+}
+
+func TestSyncDataCorruptionCondition(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	namespace := "longhorn-system"
+	volumeName := "vol-1"
+	newSnapshot := func(name string) *longhorn.Snapshot {
+		return &longhorn.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    types.GetVolumeLabels(volumeName),
+			},
+			Spec: longhorn.SnapshotSpec{Volume: volumeName},
+		}
+	}
+	c, err := fake.NewCluster(namespace, stopCh,
+		&longhorn.Volume{ObjectMeta: metav1.ObjectMeta{Name: volumeName, Namespace: namespace}},
+		newSnapshot("snap-1"), newSnapshot("snap-2"))
+	assert.NoError(err)
+	m := &SnapshotMonitor{
+		baseMonitor: &baseMonitor{logger: logrus.StandardLogger(), ds: c.DataStore},
+	}
+
+	sync := func(snapshotName, message string, expectedCorrupted bool) {
+		m.syncDataCorruptionCondition(volumeName, snapshotName, message)
+		assert.Eventually(func() bool {
+			snapshot, err := c.DataStore.GetSnapshotRO(snapshotName)
+			if err != nil {
+				return false
+			}
+			_, marked := snapshot.Labels[types.GetLonghornLabelKey(types.LonghornLabelDataCorruption)]
+			return marked == (message != "")
+		}, 5*time.Second, 10*time.Millisecond, snapshotName)
+		assert.Eventually(func() bool {
+			v, err := c.DataStore.GetVolumeRO(volumeName)
+			if err != nil {
+				return false
+			}
+			condition := types.GetCondition(v.Status.Conditions, longhorn.VolumeConditionTypeDataCorruption)
+			return (condition.Status == longhorn.ConditionStatusTrue) == expectedCorrupted
+		}, 5*time.Second, 10*time.Millisecond, "%v: expected corrupted %v", snapshotName, expectedCorrupted)
+	}
+
+	sync("snap-1", "The checksums of snapshot snap-1 mismatched", true)
+	sync("snap-2", "The checksums of snapshot snap-2 mismatched", true)
+	// The condition stays until every corrupted snapshot is found clean
+	sync("snap-1", "", true)
+	sync("snap-2", "", false)
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util/cloudmetadata"
+)
+
+const (
+	cloudTagSyncInterval = 5 * time.Minute
+
+	labelNameMaxLength = 63
+)
+
+// syncCloudTags sets the tags of the cloud instance as the labels of the
+// current node. The node controller on each node syncs its own node, since
+// the instance metadata is only reachable from the instance.
+func (nc *NodeController) syncCloudTags() {
+	log := nc.logger.WithField("node", nc.controllerID)
+
+	provider, err := nc.ds.GetSettingValueExisted(types.SettingNameCloudTagSyncProvider)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get cloud tag sync provider setting")
+		return
+	}
+	tags := map[string]string{}
+	if provider != string(types.CloudTagSyncProviderDisabled) {
+		p, err := cloudmetadata.GetProvider(provider)
+		if err != nil {
+			log.WithError(err).Warn("Failed to get cloud metadata provider")
+			return
+		}
+		if tags, err = p.GetInstanceTags(); err != nil {
+			log.WithError(err).Warnf("Failed to get cloud instance tags from %v", provider)
+			return
+		}
+	}
+
+	node, err := nc.ds.GetNode(nc.controllerID)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get node to sync cloud tags")
+		return
+	}
+	existingLabels := map[string]string{}
+	newLabels := map[string]string{}
+	for key, value := range node.Labels {
+		if strings.HasPrefix(key, types.CloudTagLabelKeyPrefix) {
+			existingLabels[key] = value
+		} else {
+			newLabels[key] = value
+		}
+	}
+	cloudTagLabels := getCloudTagLabels(tags)
+	if reflect.DeepEqual(existingLabels, cloudTagLabels) {
+		return
+	}
+	for key, value := range cloudTagLabels {
+		newLabels[key] = value
+	}
+	node.Labels = newLabels
+	if _, err := nc.ds.UpdateNode(node); err != nil {
+		log.WithError(err).Warn("Failed to update node labels with cloud tags")
+		return
+	}
+	log.Infof("Synced %v cloud instance tags from %v to node labels", len(cloudTagLabels), provider)
+}
+
+// getCloudTagLabels converts the tags to labels. The characters not allowed
+// in labels are replaced, and the tags too long are truncated.
+func getCloudTagLabels(tags map[string]string) map[string]string {
+	labels := map[string]string{}
+	for key, value := range tags {
+		name := sanitizeLabelValue(key)
+		if name == "" {
+			continue
+		}
+		labels[types.CloudTagLabelKeyPrefix+name] = sanitizeLabelValue(value)
+	}
+	return labels
+}
+
+func sanitizeLabelValue(s string) string {
+	isAlphanumeric := func(r rune) bool {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+	}
+	s = strings.Map(func(r rune) rune {
+		if isAlphanumeric(r) || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '-'
+	}, s)
+	if len(s) > labelNameMaxLength {
+		s = s[:labelNameMaxLength]
+	}
+	return strings.TrimFunc(s, func(r rune) bool {
+		return !isAlphanumeric(r)
+	})
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -38,6 +40,13 @@ var (
 	unknownFsid = "UNKNOWN_FSID"
 
 	snapshotChangeEventQueueMax = 1048576
+
+	// The actual size of a replica in use changes by every write, so it's
+	// only updated once it changes by the larger of the minimum and the
+	// percentage of the volume size, rather than rewriting the replica on
+	// every node sync.
+	replicaActualSizeUpdateMinimum    = int64(16 * 1024 * 1024)
+	replicaActualSizeUpdatePercentage = int64(1)
 )
 
 type NodeController struct {
@@ -157,6 +166,13 @@ func NewNodeController(
 	}, 0)
 	nc.cacheSyncs = append(nc.cacheSyncs, ds.KubeNodeInformer.HasSynced)
 
+	ds.CSINodeInformer.AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
+		AddFunc:    nc.enqueueCSINode,
+		UpdateFunc: func(old, cur interface{}) { nc.enqueueCSINode(cur) },
+		DeleteFunc: nc.enqueueCSINode,
+	}, 0)
+	nc.cacheSyncs = append(nc.cacheSyncs, ds.CSINodeInformer.HasSynced)
+
 	return nc
 }
 
@@ -286,6 +302,7 @@ func (nc *NodeController) Run(workers int, stopCh <-chan struct{}) {
 	for i := 0; i < workers; i++ {
 		go wait.Until(nc.worker, time.Second, stopCh)
 	}
+	go wait.Until(nc.syncCloudTags, cloudTagSyncInterval, stopCh)
 
 	<-stopCh
 }
@@ -314,6 +331,9 @@ func (nc *NodeController) handleErr(err error, key interface{}) {
 		nc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(nc.queue, err, key) {
+		return
+	}
 
 	if nc.queue.NumRequeues(key) < maxRetries {
 		logrus.Warnf("Error syncing Longhorn node %v: %v", key, err)
@@ -548,6 +568,10 @@ func (nc *NodeController) syncNode(key string) (err error) {
 		return err
 	}
 
+	if err := nc.syncReplicaActualSizes(node, collectedDiskInfo); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -683,6 +707,33 @@ func (nc *NodeController) enqueueKubernetesNode(obj interface{}) {
 	nc.enqueueNode(nodeRO)
 }
 
+func (nc *NodeController) enqueueCSINode(obj interface{}) {
+	csiNode, ok := obj.(*storagev1.CSINode)
+	if !ok {
+		deletedState, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("received unexpected obj: %#v", obj))
+			return
+		}
+
+		// use the last known state, to enqueue, dependent objects
+		csiNode, ok = deletedState.Obj.(*storagev1.CSINode)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("DeletedFinalStateUnknown contained invalid object: %#v", deletedState.Obj))
+			return
+		}
+	}
+
+	nodeRO, err := nc.ds.GetNodeRO(csiNode.Name)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			utilruntime.HandleError(fmt.Errorf("couldn't get longhorn node %v: %v ", csiNode.Name, err))
+		}
+		return
+	}
+	nc.enqueueNode(nodeRO)
+}
+
 func (nc *NodeController) syncDiskStatus(node *longhorn.Node, collectedDataInfo map[string]*monitor.CollectedDiskInfo) error {
 	alignDiskSpecAndStatus(node)
 
@@ -923,9 +974,34 @@ func (nc *NodeController) syncNodeStatus(pod *v1.Pod, node *longhorn.Node) error
 		}
 	}
 
+	// sync CSI driver registration for node status to check whether volumes can be published on the node
+	csiNode, err := nc.ds.GetCSINode(node.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if isCSIDriverRegistered(csiNode) {
+		node.Status.Conditions = types.SetCondition(node.Status.Conditions, longhorn.NodeConditionTypeCSIDriverReady, longhorn.ConditionStatusTrue, "", "")
+	} else {
+		node.Status.Conditions = types.SetCondition(node.Status.Conditions, longhorn.NodeConditionTypeCSIDriverReady, longhorn.ConditionStatusFalse,
+			string(longhorn.NodeConditionReasonCSIDriverNotRegistered),
+			fmt.Sprintf("CSI driver %v is not registered with the kubelet on node %v", types.LonghornDriverName, node.Name))
+	}
+
 	return nil
 }
 
+func isCSIDriverRegistered(csiNode *storagev1.CSINode) bool {
+	if csiNode == nil {
+		return false
+	}
+	for _, driver := range csiNode.Spec.Drivers {
+		if driver.Name == types.LonghornDriverName {
+			return true
+		}
+	}
+	return false
+}
+
 func (nc *NodeController) syncInstanceManagers(node *longhorn.Node) error {
 	defaultInstanceManagerImage, err := nc.ds.GetSettingValueExisted(types.SettingNameDefaultInstanceManagerImage)
 	if err != nil {
@@ -1168,6 +1244,58 @@ func (nc *NodeController) syncOrphans(node *longhorn.Node, collectedDataInfo map
 	return nil
 }
 
+func (nc *NodeController) syncReplicaActualSizes(node *longhorn.Node, collectedDataInfo map[string]*monitor.CollectedDiskInfo) error {
+	replicas, err := nc.ds.ListReplicasByNodeRO(node.Name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list replicas on node %v", node.Name)
+	}
+
+	for _, r := range replicas {
+		var diskInfo *monitor.CollectedDiskInfo
+		for _, info := range collectedDataInfo {
+			if info.DiskUUID == r.Spec.DiskID && info.Path == r.Spec.DiskPath {
+				diskInfo = info
+				break
+			}
+		}
+		if diskInfo == nil {
+			continue
+		}
+		actualSize, ok := diskInfo.ReplicaActualSizes[r.Spec.DataDirectoryName]
+		if !ok || !shouldUpdateReplicaActualSize(r, actualSize) {
+			continue
+		}
+
+		replica, err := nc.ds.GetReplica(r.Name)
+		if err != nil {
+			if datastore.ErrorIsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		replica.Status.ActualSize = actualSize
+		if _, err := nc.ds.UpdateReplicaStatus(replica); err != nil && !datastore.ErrorIsNotFound(err) {
+			return errors.Wrapf(err, "failed to update actual size of replica %v", r.Name)
+		}
+	}
+	return nil
+}
+
+func shouldUpdateReplicaActualSize(r *longhorn.Replica, actualSize int64) bool {
+	if r.Status.ActualSize == 0 {
+		return actualSize != 0
+	}
+	threshold := r.Spec.VolumeSize * replicaActualSizeUpdatePercentage / 100
+	if threshold < replicaActualSizeUpdateMinimum {
+		threshold = replicaActualSizeUpdateMinimum
+	}
+	delta := actualSize - r.Status.ActualSize
+	if delta < 0 {
+		delta = -delta
+	}
+	return delta >= threshold
+}
+
 func (nc *NodeController) getNewAndMissingOrphanedReplicaDirectoryNames(diskName, diskUUID, diskPath string, replicaDirectoryNames map[string]string) (map[string]string, map[string]string) {
 	newOrphanedReplicaDirectoryNames := map[string]string{}
 	missingOrphanedReplicaDirectoryNames := map[string]string{}
@@ -1193,6 +1321,12 @@ func (nc *NodeController) getNewAndMissingOrphanedReplicaDirectoryNames(diskName
 			continue
 		}
 
+		// The data retained from a failed replica may not be collected by the disk monitor yet
+		if orphan.Spec.Parameters[longhorn.OrphanFailedReplicaName] != "" &&
+			time.Since(orphan.CreationTimestamp.Time) < 2*monitor.NodeMonitorSyncPeriod {
+			continue
+		}
+
 		dirName := orphan.Spec.Parameters[longhorn.OrphanDataName]
 		if _, ok := replicaDirectoryNames[dirName]; !ok {
 			missingOrphanedReplicaDirectoryNames[dirName] = ""
@@ -1230,7 +1364,22 @@ func (nc *NodeController) deleteOrphans(node *longhorn.Node, diskName string, di
 			continue
 		}
 
-		if autoDeletionEnabled || dataCleanableCondition.Status == longhorn.ConditionStatusFalse {
+		if types.IsOrphanRetained(orphan) {
+			continue
+		}
+
+		cleanupAfter, isPendingCleanup := getOrphanCleanupAfter(orphan)
+		if isPendingCleanup && time.Now().After(cleanupAfter) {
+			log := getLoggerForNode(nc.logger, node).WithField("orphan", orphan.Name)
+			log.Infof("Cleaning up the data retained from failed replica %v since the grace period expired",
+				orphan.Spec.Parameters[longhorn.OrphanFailedReplicaName])
+			if err := nc.ds.DeleteOrphan(orphan.Name); err != nil && !apierrors.IsNotFound(err) {
+				return errors.Wrapf(err, "failed to delete orphan %v", orphan.Name)
+			}
+			continue
+		}
+
+		if (autoDeletionEnabled && !isPendingCleanup) || dataCleanableCondition.Status == longhorn.ConditionStatusFalse {
 			if err := nc.ds.DeleteOrphan(orphan.Name); err != nil && !apierrors.IsNotFound(err) {
 				return errors.Wrapf(err, "failed to delete orphan %v", orphan.Name)
 			}
@@ -1239,6 +1388,21 @@ func (nc *NodeController) deleteOrphans(node *longhorn.Node, diskName string, di
 	return nil
 }
 
+// getOrphanCleanupAfter returns the time after which the data retained from a
+// failed replica should be cleaned up, and whether the orphan has one.
+func getOrphanCleanupAfter(orphan *longhorn.Orphan) (time.Time, bool) {
+	cleanupAfter, ok := orphan.Spec.Parameters[longhorn.OrphanCleanupAfter]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := util.ParseTime(cleanupAfter)
+	if err != nil {
+		logrus.Warnf("Invalid cleanup time %v of orphan %v", cleanupAfter, orphan.Name)
+		return time.Time{}, false
+	}
+	return t, true
+}
+
 func (nc *NodeController) createOrphans(node *longhorn.Node, diskName string, diskInfo *monitor.CollectedDiskInfo, newOrphanedReplicaDirectoryNames map[string]string) error {
 	for dirName := range newOrphanedReplicaDirectoryNames {
 		if err := nc.createOrphan(node, diskName, dirName, diskInfo); err != nil && !apierrors.IsAlreadyExists(err) {
@@ -1272,6 +1436,12 @@ func (nc *NodeController) createOrphan(node *longhorn.Node, diskName, replicaDir
 			},
 		},
 	}
+	if meta := diskInfo.OrphanedReplicaVolumeMetas[replicaDirectoryName]; meta != nil {
+		orphan.Spec.Parameters[longhorn.OrphanVolumeSize] = strconv.FormatInt(meta.Size, 10)
+		orphan.Spec.Parameters[longhorn.OrphanVolumeHead] = meta.Head
+		orphan.Spec.Parameters[longhorn.OrphanVolumeRebuilding] = strconv.FormatBool(meta.Rebuilding)
+		orphan.Spec.Parameters[longhorn.OrphanVolumeError] = meta.Error
+	}
 
 	_, err = nc.ds.CreateOrphan(orphan)
 
@@ -3,10 +3,12 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/informers"
 	clientset "k8s.io/client-go/kubernetes"
@@ -43,6 +45,7 @@ type NodeTestCase struct {
 	pods            map[string]*v1.Pod
 	replicas        []*longhorn.Replica
 	kubeNodes       map[string]*v1.Node
+	csiNodes        map[string]*storagev1.CSINode
 	engineManagers  map[string]*longhorn.InstanceManager
 	replicaManagers map[string]*longhorn.InstanceManager
 
@@ -116,6 +119,21 @@ func generateManagerPod(testType string) map[string]*v1.Pod {
 	}
 }
 
+func newCSINode(name string, drivers ...string) *storagev1.CSINode {
+	csiNode := &storagev1.CSINode{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}
+	for _, driver := range drivers {
+		csiNode.Spec.Drivers = append(csiNode.Spec.Drivers, storagev1.CSINodeDriver{
+			Name:   driver,
+			NodeID: name,
+		})
+	}
+	return csiNode
+}
+
 func kubeObjStatusSyncTest(testType string) *NodeTestCase {
 	tc := &NodeTestCase{}
 	tc.kubeNodes = generateKubeNodes(testType)
@@ -135,6 +153,7 @@ func kubeObjStatusSyncTest(testType string) *NodeTestCase {
 					newNodeCondition(longhorn.NodeConditionTypeSchedulable, longhorn.ConditionStatusTrue, ""),
 					newNodeCondition(longhorn.NodeConditionTypeReady, longhorn.ConditionStatusTrue, ""),
 					newNodeCondition(longhorn.NodeConditionTypeMountPropagation, longhorn.ConditionStatusTrue, ""),
+					newNodeCondition(longhorn.NodeConditionTypeCSIDriverReady, longhorn.ConditionStatusTrue, ""),
 				},
 			},
 			TestNode2: {
@@ -151,6 +170,7 @@ func kubeObjStatusSyncTest(testType string) *NodeTestCase {
 					newNodeCondition(longhorn.NodeConditionTypeSchedulable, longhorn.ConditionStatusTrue, ""),
 					newNodeCondition(longhorn.NodeConditionTypeReady, longhorn.ConditionStatusFalse, longhorn.NodeConditionReasonManagerPodDown),
 					newNodeCondition(longhorn.NodeConditionTypeMountPropagation, longhorn.ConditionStatusFalse, longhorn.NodeConditionReasonNoMountPropagationSupport),
+					newNodeCondition(longhorn.NodeConditionTypeCSIDriverReady, longhorn.ConditionStatusFalse, longhorn.NodeConditionReasonCSIDriverNotRegistered),
 				},
 			},
 			TestNode2: {
@@ -167,6 +187,7 @@ func kubeObjStatusSyncTest(testType string) *NodeTestCase {
 					newNodeCondition(longhorn.NodeConditionTypeSchedulable, longhorn.ConditionStatusTrue, ""),
 					newNodeCondition(longhorn.NodeConditionTypeReady, longhorn.ConditionStatusFalse, longhorn.NodeConditionReasonKubernetesNodeNotReady),
 					newNodeCondition(longhorn.NodeConditionTypeMountPropagation, longhorn.ConditionStatusTrue, ""),
+					newNodeCondition(longhorn.NodeConditionTypeCSIDriverReady, longhorn.ConditionStatusTrue, ""),
 				},
 			},
 			TestNode2: {
@@ -183,6 +204,7 @@ func kubeObjStatusSyncTest(testType string) *NodeTestCase {
 					newNodeCondition(longhorn.NodeConditionTypeSchedulable, longhorn.ConditionStatusTrue, ""),
 					newNodeCondition(longhorn.NodeConditionTypeReady, longhorn.ConditionStatusFalse, longhorn.NodeConditionReasonKubernetesNodePressure),
 					newNodeCondition(longhorn.NodeConditionTypeMountPropagation, longhorn.ConditionStatusTrue, ""),
+					newNodeCondition(longhorn.NodeConditionTypeCSIDriverReady, longhorn.ConditionStatusTrue, ""),
 				},
 			},
 			TestNode2: {
@@ -194,6 +216,11 @@ func kubeObjStatusSyncTest(testType string) *NodeTestCase {
 		}
 	}
 	tc.pods = generateManagerPod(testType)
+	if testType != ManagerPodDown {
+		tc.csiNodes = map[string]*storagev1.CSINode{
+			TestNode1: newCSINode(TestNode1, types.LonghornDriverName),
+		}
+	}
 
 	tc.expectNodeStatus = nodeStatus
 
@@ -262,6 +289,7 @@ func (s *TestSuite) TestSyncNode(c *C) {
 				newNodeCondition(longhorn.NodeConditionTypeSchedulable, longhorn.ConditionStatusTrue, ""),
 				newNodeCondition(longhorn.NodeConditionTypeReady, longhorn.ConditionStatusTrue, ""),
 				newNodeCondition(longhorn.NodeConditionTypeMountPropagation, longhorn.ConditionStatusTrue, ""),
+				newNodeCondition(longhorn.NodeConditionTypeCSIDriverReady, longhorn.ConditionStatusFalse, longhorn.NodeConditionReasonCSIDriverNotRegistered),
 			},
 			DiskStatus: map[string]*longhorn.DiskStatus{
 				TestDiskID1: {
@@ -350,6 +378,7 @@ func (s *TestSuite) TestSyncNode(c *C) {
 				newNodeCondition(longhorn.NodeConditionTypeSchedulable, longhorn.ConditionStatusTrue, ""),
 				newNodeCondition(longhorn.NodeConditionTypeReady, longhorn.ConditionStatusTrue, ""),
 				newNodeCondition(longhorn.NodeConditionTypeMountPropagation, longhorn.ConditionStatusTrue, ""),
+				newNodeCondition(longhorn.NodeConditionTypeCSIDriverReady, longhorn.ConditionStatusFalse, longhorn.NodeConditionReasonCSIDriverNotRegistered),
 			},
 			DiskStatus: map[string]*longhorn.DiskStatus{
 				TestDiskID1: {
@@ -437,6 +466,7 @@ func (s *TestSuite) TestSyncNode(c *C) {
 				newNodeCondition(longhorn.NodeConditionTypeSchedulable, longhorn.ConditionStatusTrue, ""),
 				newNodeCondition(longhorn.NodeConditionTypeReady, longhorn.ConditionStatusTrue, ""),
 				newNodeCondition(longhorn.NodeConditionTypeMountPropagation, longhorn.ConditionStatusTrue, ""),
+				newNodeCondition(longhorn.NodeConditionTypeCSIDriverReady, longhorn.ConditionStatusFalse, longhorn.NodeConditionReasonCSIDriverNotRegistered),
 			},
 			DiskStatus: map[string]*longhorn.DiskStatus{
 				TestDiskID1: {
@@ -505,6 +535,7 @@ func (s *TestSuite) TestSyncNode(c *C) {
 				newNodeCondition(longhorn.NodeConditionTypeSchedulable, longhorn.ConditionStatusTrue, ""),
 				newNodeCondition(longhorn.NodeConditionTypeReady, longhorn.ConditionStatusTrue, ""),
 				newNodeCondition(longhorn.NodeConditionTypeMountPropagation, longhorn.ConditionStatusTrue, ""),
+				newNodeCondition(longhorn.NodeConditionTypeCSIDriverReady, longhorn.ConditionStatusFalse, longhorn.NodeConditionReasonCSIDriverNotRegistered),
 			},
 			DiskStatus: map[string]*longhorn.DiskStatus{
 				TestDiskID1: {
@@ -567,6 +598,7 @@ func (s *TestSuite) TestSyncNode(c *C) {
 				newNodeCondition(longhorn.NodeConditionTypeSchedulable, longhorn.ConditionStatusTrue, ""),
 				newNodeCondition(longhorn.NodeConditionTypeReady, longhorn.ConditionStatusTrue, ""),
 				newNodeCondition(longhorn.NodeConditionTypeMountPropagation, longhorn.ConditionStatusTrue, ""),
+				newNodeCondition(longhorn.NodeConditionTypeCSIDriverReady, longhorn.ConditionStatusFalse, longhorn.NodeConditionReasonCSIDriverNotRegistered),
 			},
 			DiskStatus: map[string]*longhorn.DiskStatus{
 				TestDiskID1: {
@@ -646,6 +678,7 @@ func (s *TestSuite) TestSyncNode(c *C) {
 				newNodeCondition(longhorn.NodeConditionTypeSchedulable, longhorn.ConditionStatusTrue, ""),
 				newNodeCondition(longhorn.NodeConditionTypeReady, longhorn.ConditionStatusTrue, ""),
 				newNodeCondition(longhorn.NodeConditionTypeMountPropagation, longhorn.ConditionStatusTrue, ""),
+				newNodeCondition(longhorn.NodeConditionTypeCSIDriverReady, longhorn.ConditionStatusFalse, longhorn.NodeConditionReasonCSIDriverNotRegistered),
 			},
 			DiskStatus: map[string]*longhorn.DiskStatus{},
 		},
@@ -690,6 +723,7 @@ func (s *TestSuite) TestSyncNode(c *C) {
 
 		rIndexer := lhInformerFactory.Longhorn().V1beta2().Replicas().Informer().GetIndexer()
 		knIndexer := kubeInformerFactory.Core().V1().Nodes().Informer().GetIndexer()
+		cnIndexer := kubeInformerFactory.Storage().V1().CSINodes().Informer().GetIndexer()
 
 		sIndexer := lhInformerFactory.Longhorn().V1beta2().Settings().Informer().GetIndexer()
 		imIndexer := lhInformerFactory.Longhorn().V1beta2().InstanceManagers().Informer().GetIndexer()
@@ -709,6 +743,14 @@ func (s *TestSuite) TestSyncNode(c *C) {
 
 		}
 
+		// create CSI node
+		for _, csiNode := range tc.csiNodes {
+			n, err := kubeClient.StorageV1().CSINodes().Create(context.TODO(), csiNode, metav1.CreateOptions{})
+			c.Assert(err, IsNil)
+			err = cnIndexer.Add(n)
+			c.Assert(err, IsNil)
+		}
+
 		extensionsClient := apiextensionsfake.NewSimpleClientset()
 
 		nc := newTestNodeController(lhInformerFactory, kubeInformerFactory, lhClient, kubeClient, extensionsClient, TestNode1)
@@ -822,3 +864,44 @@ func (s *TestSuite) TestSyncNode(c *C) {
 		}
 	}
 }
+
+func (s *TestSuite) TestGetCloudTagLabels(c *C) {
+	labels := getCloudTagLabels(map[string]string{
+		"team":               "storage",
+		"Cost Center":        "R&D/42",
+		"aws:cloudformation": "stack",
+		"!!!":                "dropped",
+		"long":               strings.Repeat("a", 70) + "-",
+	})
+	c.Assert(labels, DeepEquals, map[string]string{
+		types.CloudTagLabelKeyPrefix + "team":               "storage",
+		types.CloudTagLabelKeyPrefix + "Cost-Center":        "R-D-42",
+		types.CloudTagLabelKeyPrefix + "aws-cloudformation": "stack",
+		types.CloudTagLabelKeyPrefix + "long":               strings.Repeat("a", 63),
+	})
+}
+
+func (s *TestSuite) TestShouldUpdateReplicaActualSize(c *C) {
+	const gi = int64(1024 * 1024 * 1024)
+
+	testCases := map[string]struct {
+		volumeSize        int64
+		currentActualSize int64
+		actualSize        int64
+		expectedUpdate    bool
+	}{
+		"first size":                  {10 * gi, 0, 4096, true},
+		"unchanged":                   {10 * gi, 4096, 4096, false},
+		"small change":                {10 * gi, gi, gi + 1024*1024, false},
+		"change over the percentage":  {10 * gi, gi, gi + 200*1024*1024, true},
+		"shrink over the percentage":  {10 * gi, gi, gi - 200*1024*1024, true},
+		"change under the minimum":    {gi, gi / 2, gi/2 + 15*1024*1024, false},
+		"change reaching the minimum": {gi, gi / 2, gi/2 + 16*1024*1024, true},
+	}
+	for name, tc := range testCases {
+		r := &longhorn.Replica{}
+		r.Spec.VolumeSize = tc.volumeSize
+		r.Status.ActualSize = tc.currentActualSize
+		c.Assert(shouldUpdateReplicaActualSize(r, tc.actualSize), Equals, tc.expectedUpdate, Commentf("%v", name))
+	}
+}
@@ -0,0 +1,427 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+const (
+	NotificationTypeVolumeFaulted  = "VolumeFaulted"
+	NotificationTypeVolumeDegraded = "VolumeDegraded"
+	NotificationTypeBackupFailed   = "BackupFailed"
+	NotificationTypeNodeDown       = "NodeDown"
+
+	notificationMaxRetries             = 5
+	notificationDedupPeriod            = time.Hour
+	notificationDegradedCheckInterval  = time.Minute
+	notificationWebhookTimeout         = 10 * time.Second
+	notificationRetryBaseDelay         = time.Second
+	notificationRetryMaxDelay          = time.Minute
+	notificationWebhookMaxResponseSize = 4096
+)
+
+// Notification is POSTed to the notification webhook in the generic format.
+type Notification struct {
+	Type     string `json:"type"`
+	Resource string `json:"resource"`
+	Name     string `json:"name"`
+	Message  string `json:"message"`
+	Time     string `json:"time"`
+}
+
+// NotificationController sends the notifications of the critical events to
+// the webhook in the secret of the settings. The events are detected by the leader only, so
+// each event is sent once for the cluster. The same notification is sent at
+// most once in the dedup period.
+type NotificationController struct {
+	*baseController
+
+	namespace     string
+	ds            *datastore.DataStore
+	leaderElector *LeaderElector
+	httpClient    *http.Client
+
+	cacheSyncs []cache.InformerSynced
+
+	lock sync.Mutex
+	// the notifications to send, by the dedup key
+	pending map[string]*Notification
+	// when the notifications were sent, by the dedup key
+	sent map[string]time.Time
+
+	// for unit test
+	nowHandler func() time.Time
+}
+
+func NewNotificationController(
+	logger logrus.FieldLogger,
+	ds *datastore.DataStore,
+	namespace string,
+	leaderElector *LeaderElector) *NotificationController {
+
+	nc := &NotificationController{
+		baseController: newBaseControllerWithQueue("longhorn-notification", logger,
+			workqueue.NewNamedRateLimitingQueue(
+				workqueue.NewItemExponentialFailureRateLimiter(notificationRetryBaseDelay, notificationRetryMaxDelay),
+				"longhorn-notification")),
+
+		namespace:     namespace,
+		ds:            ds,
+		leaderElector: leaderElector,
+		httpClient:    &http.Client{Timeout: notificationWebhookTimeout},
+
+		pending: map[string]*Notification{},
+		sent:    map[string]time.Time{},
+
+		nowHandler: time.Now,
+	}
+
+	ds.VolumeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, cur interface{}) {
+			if n := getVolumeFaultedNotification(old, cur); n != nil {
+				nc.notify(n)
+			}
+		},
+	})
+	nc.cacheSyncs = append(nc.cacheSyncs, ds.VolumeInformer.HasSynced)
+	ds.BackupInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, cur interface{}) {
+			if n := getBackupFailedNotification(old, cur); n != nil {
+				nc.notify(n)
+			}
+		},
+	})
+	nc.cacheSyncs = append(nc.cacheSyncs, ds.BackupInformer.HasSynced)
+	ds.NodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, cur interface{}) {
+			if n := getNodeDownNotification(old, cur); n != nil {
+				nc.notify(n)
+			}
+		},
+	})
+	nc.cacheSyncs = append(nc.cacheSyncs, ds.NodeInformer.HasSynced)
+
+	return nc
+}
+
+func (nc *NotificationController) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer nc.queue.ShutDown()
+
+	nc.logger.Info("Starting Longhorn notification controller")
+	defer nc.logger.Info("Shut down Longhorn notification controller")
+
+	if !cache.WaitForNamedCacheSync(nc.name, stopCh, nc.cacheSyncs...) {
+		return
+	}
+	for i := 0; i < workers; i++ {
+		go wait.Until(nc.worker, time.Second, stopCh)
+	}
+	go wait.Until(nc.checkDegradedVolumes, notificationDegradedCheckInterval, stopCh)
+	<-stopCh
+}
+
+func (nc *NotificationController) worker() {
+	for nc.processNextWorkItem() {
+	}
+}
+
+func (nc *NotificationController) processNextWorkItem() bool {
+	key, quit := nc.queue.Get()
+	if quit {
+		return false
+	}
+	defer nc.queue.Done(key)
+	err := nc.sendNotification(key.(string))
+	nc.handleErr(err, key)
+	return true
+}
+
+func (nc *NotificationController) handleErr(err error, key interface{}) {
+	if err == nil {
+		nc.queue.Forget(key)
+		return
+	}
+
+	if nc.queue.NumRequeues(key) < notificationMaxRetries {
+		nc.logger.WithError(err).Warnf("Error sending notification %v", key)
+		nc.queue.AddRateLimited(key)
+		return
+	}
+
+	utilruntime.HandleError(err)
+	nc.logger.WithError(err).Warnf("Dropping notification %v out of the queue", key)
+	nc.queue.Forget(key)
+
+	nc.lock.Lock()
+	defer nc.lock.Unlock()
+	delete(nc.pending, key.(string))
+}
+
+// notify queues the notification unless the same one was sent in the dedup
+// period, or is being sent.
+func (nc *NotificationController) notify(n *Notification) {
+	if !nc.leaderElector.IsLeader() {
+		return
+	}
+
+	key := n.Type + "/" + n.Name
+	now := nc.nowHandler()
+
+	nc.lock.Lock()
+	defer nc.lock.Unlock()
+	if _, ok := nc.pending[key]; ok {
+		return
+	}
+	if sentAt, ok := nc.sent[key]; ok && now.Sub(sentAt) < notificationDedupPeriod {
+		return
+	}
+	n.Time = now.UTC().Format(time.RFC3339)
+	nc.pending[key] = n
+	nc.queue.Add(key)
+}
+
+func (nc *NotificationController) sendNotification(key string) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to send notification %v", key)
+	}()
+
+	nc.lock.Lock()
+	n, ok := nc.pending[key]
+	nc.lock.Unlock()
+	if !ok {
+		return nil
+	}
+
+	webhook, err := nc.getWebhookURL()
+	if err != nil {
+		return err
+	}
+	if webhook != "" {
+		format, err := nc.ds.GetSettingValueExisted(types.SettingNameNotificationWebhookFormat)
+		if err != nil {
+			return err
+		}
+		if err := nc.postNotification(webhook, format, n); err != nil {
+			return err
+		}
+		nc.logger.Infof("Sent notification %v: %v", key, n.Message)
+	}
+
+	nc.lock.Lock()
+	defer nc.lock.Unlock()
+	delete(nc.pending, key)
+	nc.sent[key] = nc.nowHandler()
+	return nil
+}
+
+// getWebhookURL returns the webhook URL in the secret of the settings, or
+// empty if the notifications are disabled.
+func (nc *NotificationController) getWebhookURL() (string, error) {
+	secretSetting, err := nc.ds.GetSetting(types.SettingNameNotificationWebhookSecret)
+	if err != nil {
+		return "", err
+	}
+	secretName := secretSetting.Value
+	if secretName == "" {
+		return "", nil
+	}
+	secret, err := nc.ds.GetSecretRO(nc.namespace, secretName)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the notification webhook secret %v", secretName)
+	}
+	webhook := strings.TrimSpace(string(secret.Data[types.NotificationWebhookSecretURLKey]))
+	if err := types.ValidateNotificationWebhookURL(webhook); err != nil {
+		return "", errors.Wrapf(err, "invalid %v in the notification webhook secret %v", types.NotificationWebhookSecretURLKey, secretName)
+	}
+	return webhook, nil
+}
+
+// postNotification POSTs the notification to the webhook. The errors only
+// refer to the host of the webhook, since the URL usually embeds the token.
+func (nc *NotificationController) postNotification(webhook, format string, n *Notification) error {
+	body, err := getNotificationPayload(format, n)
+	if err != nil {
+		return err
+	}
+	host := redactWebhookURL(webhook)
+	resp, err := nc.httpClient.Post(webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return errors.Wrapf(err, "failed to post to webhook %v", host)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, notificationWebhookMaxResponseSize))
+		return fmt.Errorf("webhook %v responded %v: %v", host, resp.Status, string(message))
+	}
+	return nil
+}
+
+// redactWebhookURL returns the scheme and host of the webhook URL, which is
+// enough to tell which webhook failed without leaking the token in the path.
+func redactWebhookURL(webhook string) string {
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return "<redacted>"
+	}
+	return u.Scheme + "://" + u.Host + "/<redacted>"
+}
+
+func getNotificationPayload(format string, n *Notification) ([]byte, error) {
+	if format == types.NotificationWebhookFormatSlack {
+		return json.Marshal(map[string]string{
+			"text": fmt.Sprintf("[Longhorn] %v %v %v: %v", n.Type, n.Resource, n.Name, n.Message),
+		})
+	}
+	return json.Marshal(n)
+}
+
+// checkDegradedVolumes notifies the volumes degraded for longer than the
+// threshold. A volume that stays degraded is notified again after the dedup
+// period.
+func (nc *NotificationController) checkDegradedVolumes() {
+	nc.pruneSentNotifications()
+
+	if !nc.leaderElector.IsLeader() {
+		return
+	}
+	threshold, err := nc.ds.GetSettingAsInt(types.SettingNameNotificationDegradedVolumeThreshold)
+	if err != nil {
+		nc.logger.WithError(err).Warn("Failed to get the degraded volume threshold for the notifications")
+		return
+	}
+	if threshold <= 0 {
+		return
+	}
+	volumes, err := nc.ds.ListVolumesRO()
+	if err != nil {
+		nc.logger.WithError(err).Warn("Failed to list volumes for the notifications")
+		return
+	}
+	for _, v := range volumes {
+		if n := getVolumeDegradedNotification(v, time.Duration(threshold)*time.Minute, nc.nowHandler()); n != nil {
+			nc.notify(n)
+		}
+	}
+}
+
+func (nc *NotificationController) pruneSentNotifications() {
+	now := nc.nowHandler()
+
+	nc.lock.Lock()
+	defer nc.lock.Unlock()
+	for key, sentAt := range nc.sent {
+		if now.Sub(sentAt) >= notificationDedupPeriod {
+			delete(nc.sent, key)
+		}
+	}
+}
+
+func getVolumeFaultedNotification(old, cur interface{}) *Notification {
+	oldVolume, ok := old.(*longhorn.Volume)
+	if !ok {
+		return nil
+	}
+	v, ok := cur.(*longhorn.Volume)
+	if !ok {
+		return nil
+	}
+	if v.Status.Robustness != longhorn.VolumeRobustnessFaulted || oldVolume.Status.Robustness == longhorn.VolumeRobustnessFaulted {
+		return nil
+	}
+	return &Notification{
+		Type:     NotificationTypeVolumeFaulted,
+		Resource: "volume",
+		Name:     v.Name,
+		Message:  fmt.Sprintf("volume %v is faulted, all its replicas failed", v.Name),
+	}
+}
+
+func getVolumeDegradedNotification(v *longhorn.Volume, threshold time.Duration, now time.Time) *Notification {
+	if v.Status.Robustness != longhorn.VolumeRobustnessDegraded {
+		return nil
+	}
+	degradedAt, err := util.ParseTime(v.Status.LastDegradedAt)
+	if err != nil {
+		return nil
+	}
+	if now.Sub(degradedAt) < threshold {
+		return nil
+	}
+	return &Notification{
+		Type:     NotificationTypeVolumeDegraded,
+		Resource: "volume",
+		Name:     v.Name,
+		Message:  fmt.Sprintf("volume %v is degraded since %v", v.Name, v.Status.LastDegradedAt),
+	}
+}
+
+func getBackupFailedNotification(old, cur interface{}) *Notification {
+	oldBackup, ok := old.(*longhorn.Backup)
+	if !ok {
+		return nil
+	}
+	b, ok := cur.(*longhorn.Backup)
+	if !ok {
+		return nil
+	}
+	if b.Status.State != longhorn.BackupStateError || oldBackup.Status.State == longhorn.BackupStateError {
+		return nil
+	}
+	volumeName := b.Status.VolumeName
+	if volumeName == "" {
+		volumeName = b.Labels[types.LonghornLabelBackupVolume]
+	}
+	return &Notification{
+		Type:     NotificationTypeBackupFailed,
+		Resource: "backup",
+		Name:     b.Name,
+		Message:  fmt.Sprintf("backup %v of volume %v failed: %v", b.Name, volumeName, b.Status.Error),
+	}
+}
+
+func getNodeDownNotification(old, cur interface{}) *Notification {
+	oldNode, ok := old.(*longhorn.Node)
+	if !ok {
+		return nil
+	}
+	node, ok := cur.(*longhorn.Node)
+	if !ok {
+		return nil
+	}
+	condition := types.GetCondition(node.Status.Conditions, longhorn.NodeConditionTypeReady)
+	oldCondition := types.GetCondition(oldNode.Status.Conditions, longhorn.NodeConditionTypeReady)
+	if condition.Status != longhorn.ConditionStatusFalse || oldCondition.Status != longhorn.ConditionStatusTrue {
+		return nil
+	}
+	return &Notification{
+		Type:     NotificationTypeNodeDown,
+		Resource: "node",
+		Name:     node.Name,
+		Message:  fmt.Sprintf("node %v is down: %v", node.Name, condition.Message),
+	}
+}
@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/kubernetes/pkg/controller"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	lhfake "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned/fake"
+	lhinformerfactory "github.com/longhorn/longhorn-manager/k8s/pkg/client/informers/externalversions"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+
+	. "gopkg.in/check.v1"
+)
+
+const testNotificationWebhookSecret = "notification-webhook"
+
+// fakeWebhook replies the statuses in order, and records the payloads.
+type fakeWebhook struct {
+	lock     sync.Mutex
+	statuses []int
+	payloads []string
+}
+
+func (w *fakeWebhook) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.payloads = append(w.payloads, string(body))
+	status := http.StatusOK
+	if len(w.statuses) > 0 {
+		status, w.statuses = w.statuses[0], w.statuses[1:]
+	}
+	rw.WriteHeader(status)
+}
+
+func (w *fakeWebhook) getPayloads() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([]string{}, w.payloads...)
+}
+
+func newTestNotificationController(c *C, webhookURL, format string) *NotificationController {
+	kubeClient := fake.NewSimpleClientset()
+	kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+	lhClient := lhfake.NewSimpleClientset()
+	lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+	extensionsClient := apiextensionsfake.NewSimpleClientset()
+	ds := datastore.NewDataStore(lhInformerFactory, lhClient, kubeInformerFactory, kubeClient, extensionsClient, TestNamespace)
+
+	sIndexer := lhInformerFactory.Longhorn().V1beta2().Settings().Informer().GetIndexer()
+	for name, value := range map[types.SettingName]string{
+		types.SettingNameNotificationWebhookSecret: testNotificationWebhookSecret,
+		types.SettingNameNotificationWebhookFormat: format,
+	} {
+		setting := initSettingsNameValue(string(name), value)
+		setting.Namespace = TestNamespace
+		c.Assert(sIndexer.Add(setting), IsNil)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: testNotificationWebhookSecret, Namespace: TestNamespace},
+		Data:       map[string][]byte{types.NotificationWebhookSecretURLKey: []byte(webhookURL + "\n")},
+	}
+	c.Assert(kubeInformerFactory.Core().V1().Secrets().Informer().GetIndexer().Add(secret), IsNil)
+
+	nc := NewNotificationController(logrus.StandardLogger(), ds, TestNamespace, &LeaderElector{isLeader: true})
+	// Retry right away
+	nc.baseController = newBaseControllerWithQueue("longhorn-notification-test", logrus.StandardLogger(),
+		workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, time.Millisecond), "longhorn-notification-test"))
+	return nc
+}
+
+func (s *TestSuite) TestNotification(c *C) {
+	webhook := &fakeWebhook{statuses: []int{http.StatusInternalServerError, http.StatusServiceUnavailable}}
+	server := httptest.NewServer(webhook)
+	defer server.Close()
+	webhookURL := server.URL + "/services/T0000/B0000/secret-token"
+
+	now := time.Now()
+	nc := newTestNotificationController(c, webhookURL, types.NotificationWebhookFormatSlack)
+	defer nc.queue.ShutDown()
+	nc.nowHandler = func() time.Time { return now }
+
+	healthy := &longhorn.Volume{ObjectMeta: metav1.ObjectMeta{Name: TestVolumeName}}
+	healthy.Status.Robustness = longhorn.VolumeRobustnessHealthy
+	faulted := healthy.DeepCopy()
+	faulted.Status.Robustness = longhorn.VolumeRobustnessFaulted
+	c.Assert(getVolumeFaultedNotification(faulted, faulted), IsNil)
+	n := getVolumeFaultedNotification(healthy, faulted)
+	c.Assert(n, NotNil)
+	c.Assert(n.Type, Equals, NotificationTypeVolumeFaulted)
+
+	// The same notification is queued once
+	nc.notify(n)
+	nc.notify(getVolumeFaultedNotification(healthy, faulted))
+	c.Assert(nc.queue.Len(), Equals, 1)
+
+	// A failed notification is retried, and the error doesn't leak the token
+	// of the webhook
+	err := nc.sendNotification(NotificationTypeVolumeFaulted + "/" + TestVolumeName)
+	c.Assert(err, NotNil)
+	c.Assert(strings.Contains(err.Error(), "500"), Equals, true, Commentf("unexpected error %v", err))
+	c.Assert(strings.Contains(err.Error(), "secret-token"), Equals, false, Commentf("unexpected error %v", err))
+	c.Assert(nc.processNextWorkItem(), Equals, true)
+	c.Assert(nc.queue.NumRequeues(NotificationTypeVolumeFaulted+"/"+TestVolumeName), Equals, 1)
+	c.Assert(nc.processNextWorkItem(), Equals, true)
+	c.Assert(nc.queue.Len(), Equals, 0)
+
+	expectedPayload := `{"text":"[Longhorn] VolumeFaulted volume ` + TestVolumeName + `: volume ` + TestVolumeName + ` is faulted, all its replicas failed"}`
+	c.Assert(webhook.getPayloads(), DeepEquals, []string{expectedPayload, expectedPayload, expectedPayload})
+
+	// The sent notification isn't sent again in the dedup period
+	nc.notify(getVolumeFaultedNotification(healthy, faulted))
+	c.Assert(nc.queue.Len(), Equals, 0)
+	now = now.Add(notificationDedupPeriod)
+	nc.pruneSentNotifications()
+	nc.notify(getVolumeFaultedNotification(healthy, faulted))
+	c.Assert(nc.queue.Len(), Equals, 1)
+
+	degraded := healthy.DeepCopy()
+	degraded.Status.Robustness = longhorn.VolumeRobustnessDegraded
+	degraded.Status.LastDegradedAt = now.Add(-time.Hour).UTC().Format(time.RFC3339)
+	c.Assert(getVolumeDegradedNotification(degraded, 2*time.Hour, now), IsNil)
+	c.Assert(getVolumeDegradedNotification(degraded, 30*time.Minute, now), NotNil)
+}
+
+func (s *TestSuite) TestNotificationGenericPayload(c *C) {
+	webhook := &fakeWebhook{}
+	server := httptest.NewServer(webhook)
+	defer server.Close()
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	nc := newTestNotificationController(c, server.URL+"/hooks/secret-token", types.NotificationWebhookFormatGeneric)
+	defer nc.queue.ShutDown()
+	nc.nowHandler = func() time.Time { return now }
+
+	nc.notify(&Notification{Type: NotificationTypeNodeDown, Resource: "node", Name: TestNode1, Message: "node is down"})
+	c.Assert(nc.processNextWorkItem(), Equals, true)
+	c.Assert(webhook.getPayloads(), DeepEquals, []string{
+		`{"type":"NodeDown","resource":"node","name":"` + TestNode1 + `","message":"node is down","time":"2026-01-02T03:04:05Z"}`,
+	})
+
+	// The unreachable webhook is only referred to by its host
+	server.Close()
+	nc.notify(&Notification{Type: NotificationTypeNodeDown, Resource: "node", Name: TestNode2, Message: "node is down"})
+	err := nc.sendNotification(NotificationTypeNodeDown + "/" + TestNode2)
+	c.Assert(err, NotNil)
+	c.Assert(strings.Contains(err.Error(), "secret-token"), Equals, false, Commentf("unexpected error %v", err))
+	c.Assert(strings.Contains(err.Error(), server.Listener.Addr().String()), Equals, true, Commentf("unexpected error %v", err))
+}
@@ -160,6 +160,9 @@ func (oc *OrphanController) handleErr(err error, key interface{}) {
 		oc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(oc.queue, err, key) {
+		return
+	}
 
 	log := oc.logger.WithField("orphan", key)
 
@@ -302,6 +305,21 @@ func (oc *OrphanController) cleanupOrphanedData(orphan *longhorn.Orphan) (err er
 }
 
 func (oc *OrphanController) deleteOrphanedReplica(orphan *longhorn.Orphan) error {
+	// The data may be adopted by a replica after the orphan was created, e.g.
+	// by a volume import, then the orphan is deleted but the data is in use.
+	replicas, err := oc.ds.ListReplicasByDiskUUID(orphan.Spec.Parameters[longhorn.OrphanDiskUUID])
+	if err != nil {
+		return err
+	}
+	for _, r := range replicas {
+		if r.Spec.DiskPath == orphan.Spec.Parameters[longhorn.OrphanDiskPath] &&
+			r.Spec.DataDirectoryName == orphan.Spec.Parameters[longhorn.OrphanDataName] {
+			oc.logger.Infof("Skipped deleting orphan %v replica directory %v since it's used by replica %v",
+				orphan.Name, orphan.Spec.Parameters[longhorn.OrphanDataName], r.Name)
+			return nil
+		}
+	}
+
 	oc.logger.Infof("Deleting orphan %v replica directory %v in disk %v on node %v",
 		orphan.Name, orphan.Spec.Parameters[longhorn.OrphanDataName],
 		orphan.Spec.Parameters[longhorn.OrphanDiskPath], orphan.Status.OwnerID)
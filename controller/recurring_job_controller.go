@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -15,6 +16,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
 	typedv1core "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -22,6 +24,7 @@ import (
 	"k8s.io/client-go/tools/record"
 	"k8s.io/kubernetes/pkg/controller"
 
+	"github.com/longhorn/longhorn-manager/constant"
 	"github.com/longhorn/longhorn-manager/datastore"
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 	"github.com/longhorn/longhorn-manager/types"
@@ -35,6 +38,7 @@ type RecurringJobController struct {
 
 	controllerID   string
 	ManagerImage   string
+	managerURL     string
 	serviceAccount string
 
 	kubeClient    clientset.Interface
@@ -50,7 +54,7 @@ func NewRecurringJobController(
 	ds *datastore.DataStore,
 	scheme *runtime.Scheme,
 	kubeClient clientset.Interface,
-	namespace, controllerID, serviceAccount, managerImage string,
+	namespace, controllerID, serviceAccount, managerImage, managerURL string,
 ) *RecurringJobController {
 
 	eventBroadcaster := record.NewBroadcaster()
@@ -64,6 +68,7 @@ func NewRecurringJobController(
 		namespace:      namespace,
 		controllerID:   controllerID,
 		ManagerImage:   managerImage,
+		managerURL:     managerURL,
 		serviceAccount: serviceAccount,
 
 		kubeClient:    kubeClient,
@@ -79,6 +84,11 @@ func NewRecurringJobController(
 	})
 	rjc.cacheSyncs = append(rjc.cacheSyncs, ds.RecurringJobInformer.HasSynced)
 
+	ds.BackupTargetInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: rjc.enqueueBackupTargetChange,
+	})
+	rjc.cacheSyncs = append(rjc.cacheSyncs, ds.BackupTargetInformer.HasSynced)
+
 	return rjc
 }
 
@@ -92,6 +102,32 @@ func (control *RecurringJobController) enqueueRecurringJob(obj interface{}) {
 	control.queue.Add(key)
 }
 
+func (control *RecurringJobController) enqueueBackupTargetChange(oldObj, curObj interface{}) {
+	oldBackupTarget, ok := oldObj.(*longhorn.BackupTarget)
+	if !ok {
+		return
+	}
+	curBackupTarget, ok := curObj.(*longhorn.BackupTarget)
+	if !ok {
+		return
+	}
+	if curBackupTarget.Name != types.DefaultBackupTargetName ||
+		oldBackupTarget.Status.Available == curBackupTarget.Status.Available {
+		return
+	}
+
+	recurringJobs, err := control.ds.ListRecurringJobsRO()
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to list recurring jobs: %v", err))
+		return
+	}
+	for _, recurringJob := range recurringJobs {
+		if isBackupRecurringJob(recurringJob) {
+			control.enqueueRecurringJob(recurringJob)
+		}
+	}
+}
+
 func (control *RecurringJobController) Run(workers int, stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 	defer control.queue.ShutDown()
@@ -134,6 +170,9 @@ func (control *RecurringJobController) handleErr(err error, key interface{}) {
 		control.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(control.queue, err, key) {
+		return
+	}
 
 	if control.queue.NumRequeues(key) < maxRetries {
 		logrus.Warnf("Error syncing Longhorn recurring job %v: %v", key, err)
@@ -300,6 +339,7 @@ func (control *RecurringJobController) detachVolumeAutoAttachedByRecurringJob(na
 	if v.Status.State == longhorn.VolumeStateAttached {
 		control.logger.Infof("requesting auto-attached volume %v to detach from node %v", v.Name, v.Spec.NodeID)
 		v.Spec.NodeID = ""
+		v.Spec.AttachmentTicket = longhorn.AttachmentTicket{}
 		if _, err := control.ds.UpdateVolume(v); err != nil {
 			return err
 		}
@@ -327,6 +367,14 @@ func (control *RecurringJobController) reconcileRecurringJob(recurringJob *longh
 			return errors.Wrap(err, "failed to create cron job")
 		}
 	} else {
+		// The catch-up job is created before the cron job is resumed, so a
+		// failed creation is retried with the cron job still suspended.
+		if isCronJobSuspended(appliedCronJob) && !isCronJobSuspended(cronJob) {
+			if err := control.createCatchUpJob(appliedCronJob, recurringJob); err != nil {
+				return errors.Wrap(err, "failed to create catch-up job")
+			}
+		}
+
 		err = control.checkAndUpdateCronJob(cronJob, appliedCronJob)
 		if err != nil {
 			return errors.Wrap(err, "failed to update cron job")
@@ -335,6 +383,69 @@ func (control *RecurringJobController) reconcileRecurringJob(recurringJob *longh
 	return nil
 }
 
+func isBackupRecurringJob(recurringJob *longhorn.RecurringJob) bool {
+	return recurringJob.Spec.Task == longhorn.RecurringJobTypeBackup ||
+		recurringJob.Spec.Task == longhorn.RecurringJobTypeBackupForceCreate
+}
+
+func isCronJobSuspended(cronJob *batchv1.CronJob) bool {
+	return cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend
+}
+
+// isBackupTargetDown returns true if the default backup target is configured
+// but was found unreachable in the last sync with the remote backupstore.
+func (control *RecurringJobController) isBackupTargetDown() (bool, error) {
+	backupTarget, err := control.ds.GetDefaultBackupTargetRO()
+	if err != nil {
+		if datastore.ErrorIsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if backupTarget.Spec.BackupTargetURL == "" || backupTarget.Status.LastSyncedAt.IsZero() {
+		return false, nil
+	}
+	return !backupTarget.Status.Available, nil
+}
+
+// getCatchUpJobName returns the name of the catch-up job of the suspended
+// cron job. The name is the same until the cron job is updated, so the
+// retries of the resume don't create more catch-up jobs. The name is a label
+// value of the job pods, so it's kept within 63 characters.
+func getCatchUpJobName(cronJob *batchv1.CronJob) string {
+	suffix := "-catch-up-" + util.GetStringHash(cronJob.Name+"/"+cronJob.ResourceVersion)
+	name := cronJob.Name
+	if len(name)+len(suffix) > validation.DNS1123LabelMaxLength {
+		name = strings.TrimRight(name[:validation.DNS1123LabelMaxLength-len(suffix)], "-.")
+	}
+	return name + suffix
+}
+
+// createCatchUpJob runs the backup job immediately after the cron job is
+// resumed, to make up the runs skipped when the backup target was down.
+func (control *RecurringJobController) createCatchUpJob(cronJob *batchv1.CronJob, recurringJob *longhorn.RecurringJob) error {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            getCatchUpJobName(cronJob),
+			Namespace:       cronJob.Namespace,
+			Labels:          cronJob.Spec.JobTemplate.Labels,
+			Annotations:     cronJob.Spec.JobTemplate.Annotations,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(cronJob, batchv1.SchemeGroupVersion.WithKind("CronJob"))},
+		},
+		Spec: *cronJob.Spec.JobTemplate.Spec.DeepCopy(),
+	}
+	if _, err := control.ds.CreateJob(job); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+
+	control.eventRecorder.Eventf(recurringJob, corev1.EventTypeNormal, constant.EventReasonStart,
+		"Started catch-up job for recurring job %v since the backup target is available again", recurringJob.Name)
+	return nil
+}
+
 func (control *RecurringJobController) createCronJob(cronJob *batchv1.CronJob, recurringJob *longhorn.RecurringJob) error {
 	var err error
 
@@ -393,7 +504,7 @@ func (control *RecurringJobController) newCronJob(recurringJob *longhorn.Recurri
 	cmd := []string{
 		"longhorn-manager", "-d",
 		"recurring-job", recurringJob.Name,
-		"--manager-url", types.GetDefaultManagerURL(),
+		"--manager-url", control.managerURL,
 	}
 
 	tolerations, err := control.ds.GetSettingTaintToleration()
@@ -414,6 +525,23 @@ func (control *RecurringJobController) newCronJob(recurringJob *longhorn.Recurri
 	}
 	registrySecret := registrySecretSetting.Value
 
+	// Pause the backup job while the backup target is down, instead of failing silently
+	var suspend *bool
+	if isBackupRecurringJob(recurringJob) {
+		backupTargetDown, err := control.isBackupTargetDown()
+		if err != nil {
+			return nil, err
+		}
+		if backupTargetDown {
+			suspend = &backupTargetDown
+			control.logger.WithField("recurringJob", recurringJob.Name).Warn("Suspending recurring backup job since the backup target is unavailable")
+		}
+	}
+
+	// The API tokens and CA are only needed if the API authentication and TLS
+	// are enabled
+	optional := true
+
 	// for mounting inside container
 	cronJob := &batchv1.CronJob{
 		ObjectMeta: metav1.ObjectMeta{
@@ -427,6 +555,7 @@ func (control *RecurringJobController) newCronJob(recurringJob *longhorn.Recurri
 		},
 		Spec: batchv1.CronJobSpec{
 			Schedule:                   recurringJob.Spec.Cron,
+			Suspend:                    suspend,
 			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
 			SuccessfulJobsHistoryLimit: &successfulJobsHistoryLimit,
 			FailedJobsHistoryLimit:     &failedJobsHistoryLimit,
@@ -456,6 +585,30 @@ func (control *RecurringJobController) newCronJob(recurringJob *longhorn.Recurri
 												},
 											},
 										},
+										{
+											Name: types.EnvAPITokens,
+											ValueFrom: &corev1.EnvVarSource{
+												SecretKeyRef: &corev1.SecretKeySelector{
+													LocalObjectReference: corev1.LocalObjectReference{
+														Name: types.APIAuthSecretName,
+													},
+													Key:      types.APIRoleAdmin,
+													Optional: &optional,
+												},
+											},
+										},
+										{
+											Name: types.EnvAPICACerts,
+											ValueFrom: &corev1.EnvVarSource{
+												SecretKeyRef: &corev1.SecretKeySelector{
+													LocalObjectReference: corev1.LocalObjectReference{
+														Name: types.APITLSSecretName,
+													},
+													Key:      types.APITLSSecretCAKey,
+													Optional: &optional,
+												},
+											},
+										},
 									},
 									VolumeMounts: []corev1.VolumeMount{
 										{
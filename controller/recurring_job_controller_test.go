@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func TestGetCatchUpJobName(t *testing.T) {
+	assert := require.New(t)
+
+	cronJob := &batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Name: "backup-daily", ResourceVersion: "1"}}
+	name := getCatchUpJobName(cronJob)
+	assert.True(strings.HasPrefix(name, "backup-daily-catch-up-"))
+	assert.Equal(name, getCatchUpJobName(cronJob))
+
+	// A new catch-up job once the cron job is updated
+	cronJob.ResourceVersion = "2"
+	assert.NotEqual(name, getCatchUpJobName(cronJob))
+
+	cronJob.Name = strings.Repeat("a", 50) + "-" + strings.Repeat("b", 12)
+	name = getCatchUpJobName(cronJob)
+	assert.LessOrEqual(len(name), 63)
+	assert.True(strings.HasPrefix(name, strings.Repeat("a", 40)))
+	assert.False(strings.Contains(name, "--"))
+}
+
+func TestReconcileRecurringJobCatchUp(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	recurringJob := &longhorn.RecurringJob{
+		ObjectMeta: metav1.ObjectMeta{Name: TestRecurringJobName, Namespace: TestNamespace},
+		Spec: longhorn.RecurringJobSpec{
+			Name:   TestRecurringJobName,
+			Task:   longhorn.RecurringJobTypeBackup,
+			Cron:   "0 0 * * *",
+			Retain: 1,
+		},
+	}
+	suspend := true
+	suspendedCronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      TestRecurringJobName,
+			Namespace: TestNamespace,
+			Labels:    types.GetCronJobLabels(&recurringJob.Spec),
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule: recurringJob.Spec.Cron,
+			Suspend:  &suspend,
+		},
+	}
+	c, err := fake.NewCluster(TestNamespace, stopCh, recurringJob, suspendedCronJob)
+	assert.NoError(err)
+	rjc := NewRecurringJobController(logrus.StandardLogger(), c.DataStore, scheme.Scheme, c.KubeClient,
+		TestNamespace, TestNode1, TestServiceAccount, TestManagerImage, types.GetManagerURL(true))
+	rjc.eventRecorder = record.NewFakeRecorder(100)
+
+	// The backup target is available, so the cron job is resumed with a
+	// catch-up job. The cron job stays suspended if the catch-up job fails.
+	c.Faults.Add(fake.Fault{Operation: "create", Target: "jobs", Err: fmt.Errorf("injected"), Times: 1})
+	assert.Error(rjc.reconcileRecurringJob(recurringJob))
+	cronJob, err := c.KubeClient.BatchV1().CronJobs(TestNamespace).Get(context.TODO(), TestRecurringJobName, metav1.GetOptions{})
+	assert.NoError(err)
+	assert.True(isCronJobSuspended(cronJob))
+
+	// Retried
+	assert.NoError(rjc.reconcileRecurringJob(recurringJob))
+	cronJob, err = c.KubeClient.BatchV1().CronJobs(TestNamespace).Get(context.TODO(), TestRecurringJobName, metav1.GetOptions{})
+	assert.NoError(err)
+	assert.False(isCronJobSuspended(cronJob))
+	jobs, err := c.KubeClient.BatchV1().Jobs(TestNamespace).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(err)
+	assert.Len(jobs.Items, 1)
+	assert.Equal(getCatchUpJobName(suspendedCronJob), jobs.Items[0].Name)
+
+	// The catch-up job is created once for the same suspension
+	assert.NoError(rjc.createCatchUpJob(suspendedCronJob, recurringJob))
+	jobs, err = c.KubeClient.BatchV1().Jobs(TestNamespace).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(err)
+	assert.Len(jobs.Items, 1)
+}
+
+func TestNewCronJobManagerAPI(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	recurringJob := &longhorn.RecurringJob{
+		ObjectMeta: metav1.ObjectMeta{Name: TestRecurringJobName, Namespace: TestNamespace},
+		Spec: longhorn.RecurringJobSpec{
+			Name:   TestRecurringJobName,
+			Task:   longhorn.RecurringJobTypeSnapshot,
+			Cron:   "0 0 * * *",
+			Retain: 1,
+		},
+	}
+	c, err := fake.NewCluster(TestNamespace, stopCh, recurringJob)
+	assert.NoError(err)
+	managerURL := types.GetManagerURL(true)
+	rjc := NewRecurringJobController(logrus.StandardLogger(), c.DataStore, scheme.Scheme, c.KubeClient,
+		TestNamespace, TestNode1, TestServiceAccount, TestManagerImage, managerURL)
+	rjc.eventRecorder = record.NewFakeRecorder(100)
+
+	cronJob, err := rjc.newCronJob(recurringJob)
+	assert.NoError(err)
+	container := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+	assert.Contains(strings.Join(container.Command, " "), "--manager-url "+managerURL)
+	assert.True(strings.HasPrefix(managerURL, "https://"))
+
+	// The API token and the CA come from the Secrets if they exist
+	envSecrets := map[string]string{}
+	for _, env := range container.Env {
+		if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+			assert.True(*env.ValueFrom.SecretKeyRef.Optional)
+			envSecrets[env.Name] = env.ValueFrom.SecretKeyRef.Name + "/" + env.ValueFrom.SecretKeyRef.Key
+		}
+	}
+	assert.Equal(types.APIAuthSecretName+"/"+types.APIRoleAdmin, envSecrets[types.EnvAPITokens])
+	assert.Equal(types.APITLSSecretName+"/"+types.APITLSSecretCAKey, envSecrets[types.EnvAPICACerts])
+}
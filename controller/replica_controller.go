@@ -171,6 +171,9 @@ func (rc *ReplicaController) handleErr(err error, key interface{}) {
 		rc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(rc.queue, err, key) {
+		return
+	}
 
 	if rc.queue.NumRequeues(key) < maxRetries {
 		rc.logger.WithError(err).Warnf("Error syncing Longhorn replica %v", key)
@@ -194,14 +197,19 @@ func getLoggerForReplica(logger logrus.FieldLogger, r *longhorn.Replica) *logrus
 	)
 }
 
-// From replica to check Node.Spec.EvictionRequested of the node
-// this replica first, then check Node.Spec.Disks.EvictionRequested
+// From replica to check Replica.Spec.EvictionRequested first, then
+// Node.Spec.EvictionRequested of the node this replica is on, then
+// Node.Spec.Disks.EvictionRequested
 func (rc *ReplicaController) isEvictionRequested(replica *longhorn.Replica) bool {
 	// Return false if this replica has not been assigned to a node.
 	if replica.Spec.NodeID == "" {
 		return false
 	}
 
+	if replica.Spec.EvictionRequested {
+		return true
+	}
+
 	log := getLoggerForReplica(rc.logger, replica)
 
 	if isDownOrDeleted, err := rc.ds.IsNodeDownOrDeleted(replica.Spec.NodeID); err != nil {
@@ -314,10 +322,21 @@ func (rc *ReplicaController) syncReplica(key string) (err error) {
 				if !strings.Contains(filepath.Base(filepath.Clean(dataPath)), "-") {
 					return fmt.Errorf("%v doesn't look like a replica data path", dataPath)
 				}
-				if err := util.RemoveHostDirectoryContent(dataPath); err != nil {
-					return errors.Wrapf(err, "cannot cleanup after replica %v at %v", replica.Name, dataPath)
+				retained, err := rc.retainFailedReplicaData(replica)
+				if err != nil {
+					return errors.Wrapf(err, "failed to retain the data of failed replica %v", replica.Name)
+				}
+				if retained {
+					log.Infof("Retained the data of failed replica at %v for the cleanup grace period", dataPath)
+				} else {
+					if err := util.RemoveHostDirectoryContent(dataPath); err != nil {
+						return errors.Wrapf(err, "cannot cleanup after replica %v at %v", replica.Name, dataPath)
+					}
+					if err := util.RemoveEmptyReplicaParentDirectory(replica.Spec.DiskPath, replica.Spec.DataDirectoryName); err != nil {
+						log.WithError(err).Warn("Failed to remove the empty parent directory of the replica data")
+					}
+					log.Debug("Cleanup replica completed")
 				}
-				log.Debug("Cleanup replica completed")
 			} else {
 				log.Debug("Didn't cleanup replica since it's not the active one for the path or the path is empty")
 			}
@@ -346,6 +365,74 @@ func (rc *ReplicaController) syncReplica(key string) (err error) {
 	return rc.instanceHandler.ReconcileInstanceState(replica, &replica.Spec.InstanceSpec, &replica.Status.InstanceStatus)
 }
 
+// retainFailedReplicaData hands the data of a failed replica over to an orphan
+// when the failed replica data cleanup grace period is set, so that the data
+// is kept until the grace period expires or the orphan is deleted manually.
+func (rc *ReplicaController) retainFailedReplicaData(r *longhorn.Replica) (bool, error) {
+	if r.Spec.FailedAt == "" {
+		return false, nil
+	}
+
+	gracePeriod, err := rc.ds.GetSettingAsInt(types.SettingNameFailedReplicaDataCleanupGracePeriod)
+	if err != nil {
+		return false, err
+	}
+	if gracePeriod <= 0 {
+		return false, nil
+	}
+
+	// The data is no longer useful once the volume is gone
+	v, err := rc.ds.GetVolumeRO(r.Spec.VolumeName)
+	if err != nil {
+		if datastore.ErrorIsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if v.DeletionTimestamp != nil {
+		return false, nil
+	}
+
+	node, err := rc.ds.GetNodeRO(rc.controllerID)
+	if err != nil {
+		return false, err
+	}
+	diskName := ""
+	for name, disk := range node.Spec.Disks {
+		diskStatus, ok := node.Status.DiskStatus[name]
+		if ok && disk.Path == r.Spec.DiskPath && diskStatus.DiskUUID == r.Spec.DiskID {
+			diskName = name
+			break
+		}
+	}
+	if diskName == "" {
+		return false, nil
+	}
+
+	orphan := &longhorn.Orphan{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: types.GetOrphanChecksumNameForOrphanedDirectory(rc.controllerID, diskName, r.Spec.DiskPath, r.Spec.DiskID, r.Spec.DataDirectoryName),
+		},
+		Spec: longhorn.OrphanSpec{
+			NodeID: rc.controllerID,
+			Type:   longhorn.OrphanTypeReplica,
+			Parameters: map[string]string{
+				longhorn.OrphanDataName:          r.Spec.DataDirectoryName,
+				longhorn.OrphanDiskName:          diskName,
+				longhorn.OrphanDiskUUID:          r.Spec.DiskID,
+				longhorn.OrphanDiskPath:          r.Spec.DiskPath,
+				longhorn.OrphanFailedReplicaName: r.Name,
+				longhorn.OrphanCleanupAfter:      time.Now().Add(time.Duration(gracePeriod) * time.Minute).UTC().Format(time.RFC3339),
+			},
+		},
+	}
+	if _, err := rc.ds.CreateOrphan(orphan); err != nil && !apierrors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	return true, nil
+}
+
 func (rc *ReplicaController) enqueueReplica(obj interface{}) {
 	key, err := controller.KeyFunc(obj)
 	if err != nil {
@@ -455,7 +542,7 @@ func IsRebuildingReplica(r *longhorn.Replica) bool {
 func (rc *ReplicaController) CanStartRebuildingReplica(r *longhorn.Replica) (bool, error) {
 	log := getLoggerForReplica(rc.logger, r)
 
-	concurrentRebuildingLimit, err := rc.ds.GetSettingAsInt(types.SettingNameConcurrentReplicaRebuildPerNodeLimit)
+	concurrentRebuildingLimit, err := rc.ds.GetNodeSettingAsInt(types.SettingNameConcurrentReplicaRebuildPerNodeLimit, r.Spec.NodeID)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func TestRetainFailedReplicaData(t *testing.T) {
+	v := newVolume(TestVolumeName, 2)
+	v.Namespace = TestNamespace
+	e := newEngineForVolume(v)
+	newFailedReplica := func() *longhorn.Replica {
+		r := newReplicaForVolume(v, e, TestNode1, TestDiskID1)
+		r.Namespace = TestNamespace
+		r.Spec.FailedAt = TestTimeNow
+		return r
+	}
+
+	tests := map[string]struct {
+		gracePeriod  string
+		withVolume   bool
+		notFailed    bool
+		expectRetain bool
+	}{
+		"failed replica":      {gracePeriod: "60", withVolume: true, expectRetain: true},
+		"replica not failed":  {gracePeriod: "60", withVolume: true, notFailed: true},
+		"retention disabled":  {gracePeriod: "0", withVolume: true},
+		"volume already gone": {gracePeriod: "60"},
+		"disabled by default": {withVolume: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := require.New(t)
+
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+
+			objects := []runtime.Object{newNode(TestNode1, TestNamespace, true, longhorn.ConditionStatusTrue, "")}
+			if tc.gracePeriod != "" {
+				objects = append(objects, newSetting(string(types.SettingNameFailedReplicaDataCleanupGracePeriod), tc.gracePeriod))
+			}
+			if tc.withVolume {
+				objects = append(objects, v.DeepCopy())
+			}
+			c, err := fake.NewCluster(TestNamespace, stopCh, objects...)
+			assert.NoError(err)
+			rc := NewReplicaController(logrus.StandardLogger(), c.DataStore, scheme.Scheme, c.KubeClient, TestNamespace, TestNode1)
+
+			r := newFailedReplica()
+			if tc.notFailed {
+				r.Spec.FailedAt = ""
+			}
+			before := time.Now()
+			retained, err := rc.retainFailedReplicaData(r)
+			assert.NoError(err)
+			assert.Equal(tc.expectRetain, retained)
+
+			orphans, err := c.LonghornClient.LonghornV1beta2().Orphans(TestNamespace).List(context.TODO(), metav1.ListOptions{})
+			assert.NoError(err)
+			if !tc.expectRetain {
+				assert.Empty(orphans.Items)
+				return
+			}
+			assert.Len(orphans.Items, 1)
+			params := orphans.Items[0].Spec.Parameters
+			assert.Equal(r.Spec.DataDirectoryName, params[longhorn.OrphanDataName])
+			assert.Equal(r.Name, params[longhorn.OrphanFailedReplicaName])
+			cleanupAfter, err := time.Parse(time.RFC3339, params[longhorn.OrphanCleanupAfter])
+			assert.NoError(err)
+			assert.True(cleanupAfter.After(before.Add(59 * time.Minute)))
+
+			// Retaining again is fine, e.g. after a failed finalizer removal
+			retained, err = rc.retainFailedReplicaData(r)
+			assert.NoError(err)
+			assert.True(retained)
+		})
+	}
+}
@@ -66,6 +66,9 @@ type SettingController struct {
 
 	// backup store timer is responsible for updating the backupTarget.spec.syncRequestAt
 	bsTimer *BackupStoreTimer
+
+	// the cluster-scoped work is only done by the leader
+	leaderElector *LeaderElector
 }
 
 type BackupStoreTimer struct {
@@ -97,7 +100,8 @@ func NewSettingController(
 	ds *datastore.DataStore,
 	scheme *runtime.Scheme,
 	kubeClient clientset.Interface,
-	namespace, controllerID, version string) *SettingController {
+	namespace, controllerID, version string,
+	leaderElector *LeaderElector) *SettingController {
 
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartLogging(logrus.Infof)
@@ -116,8 +120,17 @@ func NewSettingController(
 		ds: ds,
 
 		version: version,
+
+		leaderElector: leaderElector,
 	}
 
+	// The settings are resynced on the leadership change, so that the timer
+	// runs only on the leader
+	leaderElector.AddCallbacks(LeaderCallbacks{
+		OnStartedLeading: sc.enqueueSettingsForLeader,
+		OnStoppedLeading: sc.enqueueSettingsForLeader,
+	})
+
 	ds.SettingInformer.AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
 		AddFunc:    sc.enqueueSetting,
 		UpdateFunc: func(old, cur interface{}) { sc.enqueueSetting(cur) },
@@ -181,6 +194,9 @@ func (sc *SettingController) handleErr(err error, key interface{}) {
 		sc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(sc.queue, err, key) {
+		return
+	}
 
 	if sc.queue.NumRequeues(key) < maxRetries {
 		sc.logger.WithError(err).Warnf("Error syncing Longhorn setting %v", key)
@@ -203,7 +219,7 @@ func (sc *SettingController) syncSetting(key string) (err error) {
 		return err
 	}
 	switch name {
-	case string(types.SettingNameUpgradeChecker):
+	case string(types.SettingNameUpgradeChecker), string(types.SettingNameAirGappedMode):
 		if err := sc.syncUpgradeChecker(); err != nil {
 			return err
 		}
@@ -240,33 +256,16 @@ func (sc *SettingController) syncSetting(key string) (err error) {
 		if err := sc.cleanupFailedSupportBundles(); err != nil {
 			return err
 		}
+	case string(types.SettingNameCompletedJobRetentionPeriod), string(types.SettingNameEventRetentionCount), string(types.SettingNameEventRetentionPeriod):
+		if err := sc.collectGarbage(); err != nil {
+			return err
+		}
 	default:
 	}
 
 	return nil
 }
 
-// getResponsibleNodeID returns which node need to run
-func getResponsibleNodeID(ds *datastore.DataStore) (string, error) {
-	readyNodes, err := ds.ListReadyNodes()
-	if err != nil {
-		return "", err
-	}
-	if len(readyNodes) == 0 {
-		return "", fmt.Errorf("no ready nodes available")
-	}
-
-	// We use the first node as the responsible node
-	// If we pick a random node, there is probability
-	// more than one node be responsible node at the same time
-	var responsibleNodes []string
-	for node := range readyNodes {
-		responsibleNodes = append(responsibleNodes, node)
-	}
-	sort.Strings(responsibleNodes)
-	return responsibleNodes[0], nil
-}
-
 func (sc *SettingController) syncBackupTarget() (err error) {
 	defer func() {
 		err = errors.Wrapf(err, "failed to sync backup target")
@@ -279,12 +278,7 @@ func (sc *SettingController) syncBackupTarget() (err error) {
 		}
 	}
 
-	responsibleNodeID, err := getResponsibleNodeID(sc.ds)
-	if err != nil {
-		sc.logger.WithError(err).Warn("Failed to select node for sync backup target")
-		return err
-	}
-	if responsibleNodeID != sc.controllerID {
+	if !sc.leaderElector.IsLeader() {
 		stopTimer()
 		return nil
 	}
@@ -812,10 +806,22 @@ func (bst *BackupStoreTimer) Stop() {
 }
 
 func (sc *SettingController) syncUpgradeChecker() error {
+	if !sc.leaderElector.IsLeader() {
+		return nil
+	}
+
 	upgradeCheckerEnabled, err := sc.ds.GetSettingAsBool(types.SettingNameUpgradeChecker)
 	if err != nil {
 		return err
 	}
+	// The external version service is unreachable in the air-gapped mode
+	airGapped, err := sc.ds.GetSettingAsBool(types.SettingNameAirGappedMode)
+	if err != nil {
+		return err
+	}
+	if airGapped {
+		upgradeCheckerEnabled = false
+	}
 
 	latestLonghornVersion, err := sc.ds.GetSetting(types.SettingNameLatestLonghornVersion)
 	if err != nil {
@@ -957,6 +963,12 @@ func (sc *SettingController) enqueueSettingForNode(obj interface{}) {
 	sc.queue.Add(sc.namespace + "/" + string(types.SettingNameBackupTarget))
 }
 
+func (sc *SettingController) enqueueSettingsForLeader() {
+	sc.queue.Add(sc.namespace + "/" + string(types.SettingNameBackupTarget))
+	sc.queue.Add(sc.namespace + "/" + string(types.SettingNameUpgradeChecker))
+	sc.queue.Add(sc.namespace + "/" + string(types.SettingNameCompletedJobRetentionPeriod))
+}
+
 func (sc *SettingController) enqueueSettingForBackupTarget(obj interface{}) {
 	if _, ok := obj.(*longhorn.BackupTarget); !ok {
 		return
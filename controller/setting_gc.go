@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+const (
+	GarbageCollectionKindJob   = "job"
+	GarbageCollectionKindEvent = "event"
+)
+
+var (
+	purgedCountsLock sync.RWMutex
+	purgedCounts     = map[string]int64{}
+)
+
+func addPurgedCount(kind string, count int) {
+	purgedCountsLock.Lock()
+	defer purgedCountsLock.Unlock()
+	purgedCounts[kind] += int64(count)
+}
+
+// GetPurgedCounts returns how many objects of each kind the garbage
+// collection on this node has deleted since the manager started.
+func GetPurgedCounts() map[string]int64 {
+	purgedCountsLock.RLock()
+	defer purgedCountsLock.RUnlock()
+	counts := map[string]int64{}
+	for kind, count := range purgedCounts {
+		counts[kind] = count
+	}
+	return counts
+}
+
+// collectGarbage deletes the completed jobs and the events beyond the
+// retention settings. It's done by the leader, and repeated on the setting
+// resync.
+func (sc *SettingController) collectGarbage() (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to collect garbage")
+	}()
+
+	if !sc.leaderElector.IsLeader() {
+		return nil
+	}
+
+	now := time.Now()
+	if err := sc.collectCompletedJobs(now); err != nil {
+		return err
+	}
+	return sc.collectEvents(now)
+}
+
+func (sc *SettingController) collectCompletedJobs(now time.Time) error {
+	retentionHours, err := sc.ds.GetSettingAsInt(types.SettingNameCompletedJobRetentionPeriod)
+	if err != nil {
+		return err
+	}
+	if retentionHours <= 0 {
+		return nil
+	}
+	jobs, err := sc.ds.ListJobs()
+	if err != nil {
+		return err
+	}
+
+	purged := 0
+	for _, name := range getExpiredJobs(jobs, time.Duration(retentionHours)*time.Hour, now) {
+		if err := sc.ds.DeleteJob(name); err != nil && !apierrors.IsNotFound(err) {
+			sc.logger.WithError(err).Warnf("Failed to delete expired completed job %v", name)
+			continue
+		}
+		purged++
+	}
+	if purged > 0 {
+		addPurgedCount(GarbageCollectionKindJob, purged)
+		sc.logger.Infof("Deleted %v completed jobs older than %v hours", purged, retentionHours)
+	}
+	return nil
+}
+
+func (sc *SettingController) collectEvents(now time.Time) error {
+	retentionCount, err := sc.ds.GetSettingAsInt(types.SettingNameEventRetentionCount)
+	if err != nil {
+		return err
+	}
+	retentionHours, err := sc.ds.GetSettingAsInt(types.SettingNameEventRetentionPeriod)
+	if err != nil {
+		return err
+	}
+	if retentionCount <= 0 && retentionHours <= 0 {
+		return nil
+	}
+	eventList, err := sc.ds.GetLonghornEventList()
+	if err != nil {
+		return err
+	}
+
+	purged := 0
+	for _, name := range getExpiredEvents(eventList.Items, int(retentionCount), time.Duration(retentionHours)*time.Hour, now) {
+		if err := sc.ds.DeleteEvent(name); err != nil && !apierrors.IsNotFound(err) {
+			sc.logger.WithError(err).Warnf("Failed to delete expired event %v", name)
+			continue
+		}
+		purged++
+	}
+	if purged > 0 {
+		addPurgedCount(GarbageCollectionKindEvent, purged)
+		sc.logger.Infof("Deleted %v events beyond the retention", purged)
+	}
+	return nil
+}
+
+// getExpiredJobs returns the jobs of the recurring jobs completed before the
+// retention period. The other jobs in the namespace, e.g. the uninstallation
+// job, are not touched.
+func getExpiredJobs(jobs []batchv1.Job, retention time.Duration, now time.Time) []string {
+	expired := []string{}
+	for _, job := range jobs {
+		ownedByCronJob := false
+		for _, ref := range job.OwnerReferences {
+			if ref.Kind == types.KubernetesKindCronJob {
+				ownedByCronJob = true
+				break
+			}
+		}
+		if !ownedByCronJob {
+			continue
+		}
+		completedAt := getJobCompletionTime(&job)
+		if completedAt.IsZero() || now.Sub(completedAt) < retention {
+			continue
+		}
+		expired = append(expired, job.Name)
+	}
+	return expired
+}
+
+func getJobCompletionTime(job *batchv1.Job) time.Time {
+	if job.Status.CompletionTime != nil {
+		return job.Status.CompletionTime.Time
+	}
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) &&
+			condition.Status == corev1.ConditionTrue {
+			return condition.LastTransitionTime.Time
+		}
+	}
+	return time.Time{}
+}
+
+// getExpiredEvents returns the events not seen within the retention period,
+// and the least recently seen ones beyond the retention count.
+func getExpiredEvents(events []corev1.Event, retentionCount int, retention time.Duration, now time.Time) []string {
+	sort.SliceStable(events, func(i, j int) bool {
+		return getEventLastSeenTime(&events[i]).After(getEventLastSeenTime(&events[j]))
+	})
+
+	expired := []string{}
+	for i := range events {
+		if (retentionCount > 0 && i >= retentionCount) ||
+			(retention > 0 && now.Sub(getEventLastSeenTime(&events[i])) >= retention) {
+			expired = append(expired, events[i].Name)
+		}
+	}
+	return expired
+}
+
+func getEventLastSeenTime(event *corev1.Event) time.Time {
+	if event.Series != nil {
+		return event.Series.LastObservedTime.Time
+	}
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
@@ -238,6 +238,9 @@ func (c *ShareManagerController) handleErr(err error, key interface{}) {
 		c.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(c.queue, err, key) {
+		return
+	}
 
 	if c.queue.NumRequeues(key) < maxRetries {
 		c.logger.WithError(err).Warnf("Error syncing Longhorn share manager %v", key)
@@ -421,10 +424,13 @@ func (c *ShareManagerController) detachShareManagerVolume(sm *longhorn.ShareMana
 
 	// we don't want to detach volumes that we don't control
 	isMaintenanceMode := volume.Spec.DisableFrontend || volume.Status.FrontendDisabled
-	shouldDetach := !isMaintenanceMode && volume.Spec.AccessMode == longhorn.AccessModeReadWriteMany && volume.Spec.NodeID != ""
+	attachedBy := volume.Spec.AttachmentTicket.AttachedBy
+	isAttachedByOthers := attachedBy != "" && attachedBy != types.ShareManagerAttachedBy
+	shouldDetach := !isMaintenanceMode && !isAttachedByOthers && volume.Spec.AccessMode == longhorn.AccessModeReadWriteMany && volume.Spec.NodeID != ""
 	if shouldDetach {
 		log.Infof("requesting Volume detach from node %v", volume.Spec.NodeID)
 		volume.Spec.NodeID = ""
+		volume.Spec.AttachmentTicket = longhorn.AttachmentTicket{}
 		volume, err = c.ds.UpdateVolume(volume)
 		return err
 	}
@@ -520,6 +526,11 @@ func (c *ShareManagerController) syncShareManagerVolume(sm *longhorn.ShareManage
 	if shouldAttach {
 		log.WithField("volume", volume.Name).Info("Requesting Volume attach to share manager node")
 		volume.Spec.NodeID = sm.Status.OwnerID
+		volume.Spec.AttachmentTicket = longhorn.AttachmentTicket{
+			NodeID:      sm.Status.OwnerID,
+			AttachedBy:  types.ShareManagerAttachedBy,
+			RequestedAt: util.Now(),
+		}
 		if volume, err = c.ds.UpdateVolume(volume); err != nil {
 			return err
 		}
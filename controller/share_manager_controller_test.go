@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/controller"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	lhfake "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned/fake"
+	lhinformerfactory "github.com/longhorn/longhorn-manager/k8s/pkg/client/informers/externalversions"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *TestSuite) TestDetachShareManagerVolume(c *C) {
+	datastore.SkipListerCheck = true
+
+	testCases := map[string]struct {
+		attachedBy     string
+		expectedDetach bool
+	}{
+		"attached by the share manager": {
+			attachedBy:     types.ShareManagerAttachedBy,
+			expectedDetach: true,
+		},
+		"attached before the tickets were recorded": {
+			expectedDetach: true,
+		},
+		"attached by others": {
+			attachedBy: types.FilesystemCheckAttachedBy,
+		},
+	}
+
+	for name, tc := range testCases {
+		fmt.Printf("testing %v\n", name)
+
+		kubeClient := fake.NewSimpleClientset()
+		kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+		lhClient := lhfake.NewSimpleClientset()
+		lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+		extensionsClient := apiextensionsfake.NewSimpleClientset()
+		ds := datastore.NewDataStore(lhInformerFactory, lhClient, kubeInformerFactory, kubeClient, extensionsClient, TestNamespace)
+		smc := &ShareManagerController{
+			baseController: newBaseController("longhorn-share-manager", logrus.StandardLogger()),
+			namespace:      TestNamespace,
+			controllerID:   TestNode1,
+			eventRecorder:  record.NewFakeRecorder(100),
+			ds:             ds,
+		}
+
+		v := newVolume(TestVolumeName, 2)
+		v.Spec.AccessMode = longhorn.AccessModeReadWriteMany
+		v.Spec.NodeID = TestNode1
+		v.Spec.AttachmentTicket = longhorn.AttachmentTicket{
+			NodeID:      TestNode1,
+			AttachedBy:  tc.attachedBy,
+			RequestedAt: "2023-01-02T15:00:00Z",
+		}
+		v, err := lhClient.LonghornV1beta2().Volumes(TestNamespace).Create(context.TODO(), v, metav1.CreateOptions{})
+		c.Assert(err, IsNil)
+		vIndexer := lhInformerFactory.Longhorn().V1beta2().Volumes().Informer().GetIndexer()
+		c.Assert(vIndexer.Add(v), IsNil)
+
+		sm := &longhorn.ShareManager{
+			ObjectMeta: metav1.ObjectMeta{Name: TestVolumeName, Namespace: TestNamespace},
+		}
+		c.Assert(smc.detachShareManagerVolume(sm), IsNil)
+
+		v, err = lhClient.LonghornV1beta2().Volumes(TestNamespace).Get(context.TODO(), TestVolumeName, metav1.GetOptions{})
+		c.Assert(err, IsNil)
+		if tc.expectedDetach {
+			c.Assert(v.Spec.NodeID, Equals, "")
+			c.Assert(v.Spec.AttachmentTicket, Equals, longhorn.AttachmentTicket{})
+		} else {
+			c.Assert(v.Spec.NodeID, Equals, TestNode1)
+			c.Assert(v.Spec.AttachmentTicket.AttachedBy, Equals, tc.attachedBy)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/controller"
+
+	"github.com/longhorn/longhorn-manager/constant"
+	"github.com/longhorn/longhorn-manager/datastore"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+const (
+	// snapshotCleanupInterval is how often the volumes are checked for the
+	// snapshots exceeding the max age
+	snapshotCleanupInterval = 5 * time.Minute
+)
+
+// SnapshotCleanupController cleans up the oldest system snapshots of the
+// volumes beyond the snapshot max count or the snapshot max age of the
+// volumes. The snapshots created by users are never cleaned up. The snapshot
+// CRs are deleted, so the snapshot controller deletes the snapshots and
+// coalesces the chains by the snapshot purge.
+type SnapshotCleanupController struct {
+	*baseController
+
+	// which namespace controller is running with
+	namespace string
+	// use as the OwnerID of the controller
+	controllerID string
+
+	kubeClient    clientset.Interface
+	eventRecorder record.EventRecorder
+
+	ds         *datastore.DataStore
+	cacheSyncs []cache.InformerSynced
+
+	// for unit test
+	nowHandler func() time.Time
+}
+
+func NewSnapshotCleanupController(
+	logger logrus.FieldLogger,
+	ds *datastore.DataStore,
+	scheme *runtime.Scheme,
+	kubeClient clientset.Interface,
+	namespace string,
+	controllerID string,
+) *SnapshotCleanupController {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(logrus.Infof)
+	// TODO: remove the wrapper when every clients have moved to use the clientset.
+	eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{
+		Interface: v1core.New(kubeClient.CoreV1().RESTClient()).Events(""),
+	})
+
+	scc := &SnapshotCleanupController{
+		baseController: newBaseController("longhorn-snapshot-cleanup", logger),
+
+		namespace:     namespace,
+		controllerID:  controllerID,
+		kubeClient:    kubeClient,
+		eventRecorder: eventBroadcaster.NewRecorder(scheme, v1.EventSource{Component: "longhorn-snapshot-cleanup-controller"}),
+		ds:            ds,
+
+		nowHandler: time.Now,
+	}
+
+	ds.VolumeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    scc.enqueueVolume,
+		UpdateFunc: func(old, cur interface{}) { scc.enqueueVolume(cur) },
+	})
+	scc.cacheSyncs = append(scc.cacheSyncs, ds.VolumeInformer.HasSynced)
+	ds.SnapshotInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    scc.enqueueSnapshot,
+		UpdateFunc: func(old, cur interface{}) { scc.enqueueSnapshot(cur) },
+	})
+	scc.cacheSyncs = append(scc.cacheSyncs, ds.SnapshotInformer.HasSynced)
+
+	return scc
+}
+
+func (scc *SnapshotCleanupController) enqueueVolume(obj interface{}) {
+	key, err := controller.KeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %#v: %v", obj, err))
+		return
+	}
+
+	scc.queue.Add(key)
+}
+
+func (scc *SnapshotCleanupController) enqueueSnapshot(obj interface{}) {
+	snapshot, ok := obj.(*longhorn.Snapshot)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("received unexpected obj: %#v", obj))
+		return
+	}
+
+	scc.queue.Add(scc.namespace + "/" + snapshot.Spec.Volume)
+}
+
+func (scc *SnapshotCleanupController) enqueueAllVolumes() {
+	volumes, err := scc.ds.ListVolumesRO()
+	if err != nil {
+		utilruntime.HandleError(errors.Wrap(err, "failed to list volumes for snapshot cleanup"))
+		return
+	}
+	for _, v := range volumes {
+		if v.Spec.SnapshotMaxAge != "" {
+			scc.enqueueVolume(v)
+		}
+	}
+}
+
+func (scc *SnapshotCleanupController) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer scc.queue.ShutDown()
+
+	scc.logger.Info("Starting Longhorn Snapshot Cleanup Controller")
+	defer scc.logger.Info("Shut down Longhorn Snapshot Cleanup Controller")
+
+	if !cache.WaitForNamedCacheSync(scc.name, stopCh, scc.cacheSyncs...) {
+		return
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(scc.worker, time.Second, stopCh)
+	}
+	go wait.Until(scc.enqueueAllVolumes, snapshotCleanupInterval, stopCh)
+	<-stopCh
+}
+
+func (scc *SnapshotCleanupController) worker() {
+	for scc.processNextWorkItem() {
+	}
+}
+
+func (scc *SnapshotCleanupController) processNextWorkItem() bool {
+	key, quit := scc.queue.Get()
+	if quit {
+		return false
+	}
+	defer scc.queue.Done(key)
+	err := scc.syncHandler(key.(string))
+	scc.handleErr(err, key)
+	return true
+}
+
+func (scc *SnapshotCleanupController) handleErr(err error, key interface{}) {
+	if err == nil {
+		scc.queue.Forget(key)
+		return
+	}
+
+	scc.logger.WithError(err).Warnf("Error cleaning up snapshots of Longhorn volume %v", key)
+	scc.queue.AddRateLimited(key)
+}
+
+func (scc *SnapshotCleanupController) syncHandler(key string) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "%v: failed to sync volume %v", scc.name, key)
+	}()
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	if namespace != scc.namespace {
+		return nil
+	}
+	return scc.cleanupSnapshots(name)
+}
+
+func (scc *SnapshotCleanupController) cleanupSnapshots(volumeName string) error {
+	v, err := scc.ds.GetVolumeRO(volumeName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if v.Status.OwnerID != scc.controllerID || !v.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	if v.Spec.SnapshotMaxCount == 0 && v.Spec.SnapshotMaxAge == "" {
+		return nil
+	}
+	// The snapshots can be deleted from the running engine only
+	if v.Status.State != longhorn.VolumeStateAttached {
+		return nil
+	}
+
+	snapshots, err := scc.ds.ListVolumeSnapshotsRO(volumeName)
+	if err != nil {
+		return err
+	}
+	cleanups, err := getSnapshotsToCleanUp(v, snapshots, scc.nowHandler())
+	if err != nil {
+		return err
+	}
+
+	log := getLoggerForVolume(scc.logger, v)
+	for _, snapshot := range cleanups {
+		if err := scc.ds.DeleteSnapshot(snapshot.Name); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		log.Infof("Cleaning up system snapshot %v created at %v beyond the snapshot limits", snapshot.Name, snapshot.Status.CreationTime)
+		scc.eventRecorder.Eventf(v, v1.EventTypeNormal, constant.EventReasonSnapshotCleanedUp,
+			"Cleaning up system snapshot %v created at %v beyond the snapshot max count %v or max age %v",
+			snapshot.Name, snapshot.Status.CreationTime, v.Spec.SnapshotMaxCount, v.Spec.SnapshotMaxAge)
+	}
+	return nil
+}
+
+// getSnapshotsToCleanUp returns the oldest system snapshots beyond the
+// snapshot max count, and the system snapshots older than the snapshot max
+// age of the volume. All the snapshots count for the max count, since they
+// all make the chain longer, but only the system ones are cleaned up.
+func getSnapshotsToCleanUp(v *longhorn.Volume, snapshots map[string]*longhorn.Snapshot, now time.Time) ([]*longhorn.Snapshot, error) {
+	var maxAge time.Duration
+	if v.Spec.SnapshotMaxAge != "" {
+		var err error
+		if maxAge, err = time.ParseDuration(v.Spec.SnapshotMaxAge); err != nil {
+			return nil, errors.Wrapf(err, "invalid snapshot max age %v", v.Spec.SnapshotMaxAge)
+		}
+	}
+
+	type snapshotWithTime struct {
+		snapshot  *longhorn.Snapshot
+		createdAt time.Time
+	}
+	existing := []snapshotWithTime{}
+	for _, snapshot := range snapshots {
+		// Being deleted, or not created yet
+		if !snapshot.DeletionTimestamp.IsZero() || snapshot.Status.MarkRemoved || snapshot.Status.CreationTime == "" {
+			continue
+		}
+		createdAt, err := time.Parse(time.RFC3339, snapshot.Status.CreationTime)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid creation time %v of snapshot %v", snapshot.Status.CreationTime, snapshot.Name)
+		}
+		existing = append(existing, snapshotWithTime{snapshot: snapshot, createdAt: createdAt})
+	}
+	sort.Slice(existing, func(i, j int) bool {
+		if existing[i].createdAt.Equal(existing[j].createdAt) {
+			return existing[i].snapshot.Name < existing[j].snapshot.Name
+		}
+		return existing[i].createdAt.Before(existing[j].createdAt)
+	})
+
+	cleanups := []*longhorn.Snapshot{}
+	count := len(existing)
+	for _, s := range existing {
+		if s.snapshot.Status.UserCreated {
+			continue
+		}
+		overCount := v.Spec.SnapshotMaxCount > 0 && count > v.Spec.SnapshotMaxCount
+		overAge := maxAge > 0 && now.Sub(s.createdAt) > maxAge
+		if !overCount && !overAge {
+			continue
+		}
+		cleanups = append(cleanups, s.snapshot)
+		count--
+	}
+	return cleanups, nil
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func TestGetSnapshotsToCleanUp(t *testing.T) {
+	now := time.Date(2023, 3, 20, 12, 0, 0, 0, time.UTC)
+	newSnapshot := func(name string, age time.Duration, userCreated bool) *longhorn.Snapshot {
+		return &longhorn.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: longhorn.SnapshotStatus{
+				UserCreated:  userCreated,
+				CreationTime: now.Add(-age).Format(time.RFC3339),
+			},
+		}
+	}
+	snapshots := map[string]*longhorn.Snapshot{
+		"system-1": newSnapshot("system-1", 5*time.Hour, false),
+		"user-1":   newSnapshot("user-1", 4*time.Hour, true),
+		"system-2": newSnapshot("system-2", 3*time.Hour, false),
+		"system-3": newSnapshot("system-3", 2*time.Hour, false),
+		"user-2":   newSnapshot("user-2", time.Hour, true),
+	}
+	removed := newSnapshot("system-0", 6*time.Hour, false)
+	removed.Status.MarkRemoved = true
+	snapshots[removed.Name] = removed
+
+	tests := []struct {
+		name     string
+		maxCount int
+		maxAge   string
+		expected []string
+	}{
+		{
+			name:     "no limit",
+			expected: []string{},
+		},
+		{
+			name:     "max count",
+			maxCount: 3,
+			expected: []string{"system-1", "system-2"},
+		},
+		{
+			name:     "max count beyond system snapshots",
+			maxCount: 1,
+			expected: []string{"system-1", "system-2", "system-3"},
+		},
+		{
+			name:     "max age",
+			maxAge:   "150m",
+			expected: []string{"system-1", "system-2"},
+		},
+		{
+			name:     "max count and max age",
+			maxCount: 4,
+			maxAge:   "4h",
+			expected: []string{"system-1"},
+		},
+	}
+	for _, tc := range tests {
+		v := &longhorn.Volume{
+			Spec: longhorn.VolumeSpec{
+				SnapshotMaxCount: tc.maxCount,
+				SnapshotMaxAge:   tc.maxAge,
+			},
+		}
+		cleanups, err := getSnapshotsToCleanUp(v, snapshots, now)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tc.name, err)
+		}
+		names := []string{}
+		for _, s := range cleanups {
+			names = append(names, s.Name)
+		}
+		if len(names) != len(tc.expected) {
+			t.Fatalf("%v: expected %v, got %v", tc.name, tc.expected, names)
+		}
+		for i := range names {
+			if names[i] != tc.expected[i] {
+				t.Fatalf("%v: expected %v, got %v", tc.name, tc.expected, names)
+			}
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -23,6 +24,7 @@ import (
 	"github.com/longhorn/longhorn-manager/datastore"
 	"github.com/longhorn/longhorn-manager/engineapi"
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
 	"github.com/longhorn/longhorn-manager/util"
 )
 
@@ -294,6 +296,12 @@ func (sc *SnapshotController) reconcile(snapshotName string) (err error) {
 
 	log := getLoggerForSnapshot(sc.logger, snapshot)
 
+	if snapshot.DeletionTimestamp.IsZero() {
+		if handled, err := sc.handleBulkBackup(snapshot); handled || err != nil {
+			return err
+		}
+	}
+
 	existingSnapshot := snapshot.DeepCopy()
 	defer func() {
 		if err != nil && !shouldUpdateObject(err) {
@@ -398,6 +406,43 @@ func (sc *SnapshotController) reconcile(snapshotName string) (err error) {
 	return nil
 }
 
+// handleBulkBackup backs up the snapshot taken for a bulk backup once it's
+// ready, then removes the label requesting it. It returns true if the snapshot
+// is updated.
+func (sc *SnapshotController) handleBulkBackup(snapshot *longhorn.Snapshot) (bool, error) {
+	labelKey := types.GetLonghornLabelKey(types.LonghornLabelBulkBackup)
+	backupName := snapshot.Labels[labelKey]
+	if backupName == "" {
+		return false, nil
+	}
+
+	if snapshot.Status.Error != "" {
+		sc.eventRecorder.Eventf(snapshot, v1.EventTypeWarning, "BulkBackupError", "cannot create backup %v: %v", backupName, snapshot.Status.Error)
+	} else {
+		if !snapshot.Status.ReadyToUse {
+			return false, nil
+		}
+		_, err := sc.ds.CreateBackup(&longhorn.Backup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: backupName,
+			},
+			Spec: longhorn.BackupSpec{
+				SnapshotName: snapshot.Name,
+			},
+		}, snapshot.Spec.Volume)
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return false, errors.Wrapf(err, "failed to create bulk backup %v", backupName)
+		}
+		sc.eventRecorder.Eventf(snapshot, v1.EventTypeNormal, "BulkBackupCreate", "created backup %v", backupName)
+	}
+
+	delete(snapshot.Labels, labelKey)
+	if _, err := sc.ds.UpdateSnapshot(snapshot); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (sc *SnapshotController) generatingEventsForSnapshot(existingSnapshot, snapshot *longhorn.Snapshot) {
 	if !existingSnapshot.Status.MarkRemoved && snapshot.Status.MarkRemoved {
 		sc.eventRecorder.Event(snapshot, v1.EventTypeWarning, "SnapshotDelete", "snapshot is marked as removed")
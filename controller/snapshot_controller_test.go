@@ -1,8 +1,27 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/controller"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	lhfake "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned/fake"
+	lhinformerfactory "github.com/longhorn/longhorn-manager/k8s/pkg/client/informers/externalversions"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+
+	. "gopkg.in/check.v1"
 )
 
 func TestShouldUpdateObject(t *testing.T) {
@@ -28,3 +47,106 @@ func TestShouldUpdateObject(t *testing.T) {
 		t.Fatal("reconcileErr1 must be non-updatable error")
 	}
 }
+
+func (s *TestSuite) TestHandleBulkBackup(c *C) {
+	datastore.SkipListerCheck = true
+
+	labelKey := types.GetLonghornLabelKey(types.LonghornLabelBulkBackup)
+
+	testCases := map[string]struct {
+		ready    bool
+		snapErr  string
+		noLabel  bool
+		existing bool
+
+		expectedHandled bool
+		expectedBackup  bool
+	}{
+		"not ready": {},
+		"ready": {
+			ready:           true,
+			expectedHandled: true,
+			expectedBackup:  true,
+		},
+		"backup created before restart": {
+			ready:           true,
+			existing:        true,
+			expectedHandled: true,
+			expectedBackup:  true,
+		},
+		"snapshot failed": {
+			snapErr:         "engine is not running",
+			expectedHandled: true,
+		},
+		"not a bulk backup": {
+			ready:   true,
+			noLabel: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		fmt.Printf("testing %v\n", name)
+
+		kubeClient := fake.NewSimpleClientset()
+		kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+		lhClient := lhfake.NewSimpleClientset()
+		lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+		extensionsClient := apiextensionsfake.NewSimpleClientset()
+		ds := datastore.NewDataStore(lhInformerFactory, lhClient, kubeInformerFactory, kubeClient, extensionsClient, TestNamespace)
+		sc := &SnapshotController{
+			baseController: newBaseController("longhorn-snapshot", logrus.StandardLogger()),
+			namespace:      TestNamespace,
+			controllerID:   TestNode1,
+			eventRecorder:  record.NewFakeRecorder(100),
+			ds:             ds,
+		}
+
+		snapshot := &longhorn.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "snapshot-1",
+				Namespace: TestNamespace,
+				Labels:    map[string]string{labelKey: "backup-1"},
+			},
+			Spec: longhorn.SnapshotSpec{
+				Volume:         TestVolumeName,
+				CreateSnapshot: true,
+			},
+			Status: longhorn.SnapshotStatus{
+				ReadyToUse: tc.ready,
+				Error:      tc.snapErr,
+			},
+		}
+		if tc.noLabel {
+			snapshot.Labels = nil
+		}
+		snapshot, err := lhClient.LonghornV1beta2().Snapshots(TestNamespace).Create(context.TODO(), snapshot, metav1.CreateOptions{})
+		c.Assert(err, IsNil)
+		if tc.existing {
+			_, err = lhClient.LonghornV1beta2().Backups(TestNamespace).Create(context.TODO(), &longhorn.Backup{
+				ObjectMeta: metav1.ObjectMeta{Name: "backup-1", Namespace: TestNamespace},
+			}, metav1.CreateOptions{})
+			c.Assert(err, IsNil)
+		}
+
+		handled, err := sc.handleBulkBackup(snapshot)
+		c.Assert(err, IsNil)
+		c.Assert(handled, Equals, tc.expectedHandled)
+
+		backup, err := lhClient.LonghornV1beta2().Backups(TestNamespace).Get(context.TODO(), "backup-1", metav1.GetOptions{})
+		if !tc.expectedBackup {
+			c.Assert(datastore.ErrorIsNotFound(err), Equals, true)
+		} else {
+			c.Assert(err, IsNil)
+			if !tc.existing {
+				c.Assert(backup.Spec.SnapshotName, Equals, snapshot.Name)
+				c.Assert(backup.Labels[types.LonghornLabelBackupVolume], Equals, TestVolumeName)
+			}
+		}
+
+		// The label is removed once handled, so the backup isn't created again
+		snapshot, err = lhClient.LonghornV1beta2().Snapshots(TestNamespace).Get(context.TODO(), snapshot.Name, metav1.GetOptions{})
+		c.Assert(err, IsNil)
+		_, labeled := snapshot.Labels[labelKey]
+		c.Assert(labeled, Equals, !tc.expectedHandled && !tc.noLabel)
+	}
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+const (
+	StateChangeActionCreated = "created"
+	StateChangeActionUpdated = "updated"
+	StateChangeActionDeleted = "deleted"
+
+	stateChangeSubscriberBufferSize = 100
+)
+
+// StateChangeEvent is sent to the subscribers when an object is created or
+// deleted, or its state changes. Changes of the other fields are not sent.
+type StateChangeEvent struct {
+	Resource   string `json:"resource"`
+	Name       string `json:"name"`
+	Action     string `json:"action"`
+	State      string `json:"state"`
+	Robustness string `json:"robustness,omitempty"`
+	Node       string `json:"node,omitempty"`
+	Time       string `json:"time"`
+}
+
+type StateChangeSubscriber struct {
+	eventChan  chan *StateChangeEvent
+	resources  map[string]bool
+	names      map[string]bool
+	controller *WebsocketController
+}
+
+func (s *StateChangeSubscriber) Events() <-chan *StateChangeEvent {
+	return s.eventChan
+}
+
+// Close unsubscribes and closes the event channel.
+func (s *StateChangeSubscriber) Close() {
+	wc := s.controller
+	wc.watcherLock.Lock()
+	defer wc.watcherLock.Unlock()
+
+	for i, subscriber := range wc.subscribers {
+		if subscriber == s {
+			wc.subscribers = append(wc.subscribers[:i], wc.subscribers[i+1:]...)
+			close(s.eventChan)
+			return
+		}
+	}
+}
+
+func (s *StateChangeSubscriber) matches(event *StateChangeEvent) bool {
+	if len(s.resources) != 0 && !s.resources[event.Resource] {
+		return false
+	}
+	if len(s.names) != 0 && !s.names[event.Name] {
+		return false
+	}
+	return true
+}
+
+// SubscribeStateChanges returns a subscriber receiving the state change
+// events of the given resources and object names, or of all of them if
+// empty. The events are dropped if the subscriber falls behind.
+func (wc *WebsocketController) SubscribeStateChanges(resources, names []string) *StateChangeSubscriber {
+	wc.watcherLock.Lock()
+	defer wc.watcherLock.Unlock()
+
+	s := &StateChangeSubscriber{
+		eventChan:  make(chan *StateChangeEvent, stateChangeSubscriberBufferSize),
+		resources:  map[string]bool{},
+		names:      map[string]bool{},
+		controller: wc,
+	}
+	for _, r := range resources {
+		s.resources[r] = true
+	}
+	for _, n := range names {
+		s.names[n] = true
+	}
+	wc.subscribers = append(wc.subscribers, s)
+	return s
+}
+
+func (wc *WebsocketController) publishStateChange(event *StateChangeEvent) {
+	wc.watcherLock.Lock()
+	defer wc.watcherLock.Unlock()
+
+	for _, s := range wc.subscribers {
+		if !s.matches(event) {
+			continue
+		}
+		select {
+		case s.eventChan <- event:
+		default:
+			wc.logger.Warnf("Dropped state change event of %v %v for a slow subscriber", event.Resource, event.Name)
+		}
+	}
+}
+
+// stateChangeHandler publishes the events of the resource. getState returns
+// nil for an object of an unexpected type.
+func (wc *WebsocketController) stateChangeHandler(resource string, getState func(obj interface{}) *StateChangeEvent) cache.ResourceEventHandler {
+	publish := func(obj interface{}, action string) {
+		if deleted, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = deleted.Obj
+		}
+		event := getState(obj)
+		if event == nil {
+			return
+		}
+		event.Resource = resource
+		event.Action = action
+		event.Time = time.Now().UTC().Format(time.RFC3339)
+		wc.publishStateChange(event)
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			publish(obj, StateChangeActionCreated)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldState, newState := getState(oldObj), getState(newObj)
+			if oldState == nil || newState == nil || *oldState == *newState {
+				return
+			}
+			publish(newObj, StateChangeActionUpdated)
+		},
+		DeleteFunc: func(obj interface{}) {
+			publish(obj, StateChangeActionDeleted)
+		},
+	}
+}
+
+func getVolumeStateChange(obj interface{}) *StateChangeEvent {
+	v, ok := obj.(*longhorn.Volume)
+	if !ok {
+		return nil
+	}
+	return &StateChangeEvent{
+		Name:       v.Name,
+		State:      string(v.Status.State),
+		Robustness: string(v.Status.Robustness),
+		Node:       v.Status.CurrentNodeID,
+	}
+}
+
+func getReplicaStateChange(obj interface{}) *StateChangeEvent {
+	r, ok := obj.(*longhorn.Replica)
+	if !ok {
+		return nil
+	}
+	return &StateChangeEvent{
+		Name:  r.Name,
+		State: string(r.Status.CurrentState),
+		Node:  r.Spec.NodeID,
+	}
+}
+
+func getNodeStateChange(obj interface{}) *StateChangeEvent {
+	n, ok := obj.(*longhorn.Node)
+	if !ok {
+		return nil
+	}
+	state := "NotReady"
+	if types.GetCondition(n.Status.Conditions, longhorn.NodeConditionTypeReady).Status == longhorn.ConditionStatusTrue {
+		state = longhorn.NodeConditionTypeReady
+	}
+	return &StateChangeEvent{
+		Name:  n.Name,
+		State: state,
+		Node:  n.Name,
+	}
+}
@@ -182,6 +182,9 @@ func (c *SupportBundleController) handleErr(err error, key interface{}) {
 		c.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(c.queue, err, key) {
+		return
+	}
 
 	log := c.logger.WithField("supportBundle", key)
 
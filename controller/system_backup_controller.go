@@ -195,6 +195,9 @@ func (c *SystemBackupController) handleErr(err error, key interface{}) {
 		c.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(c.queue, err, key) {
+		return
+	}
 
 	log := c.logger.WithField("systemBackup", key)
 
@@ -159,6 +159,9 @@ func (c *SystemRestoreController) handleErr(err error, key interface{}) {
 		c.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(c.queue, err, key) {
+		return
+	}
 
 	log := c.logger.WithField("systemRestore", key)
 
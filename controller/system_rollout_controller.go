@@ -280,6 +280,9 @@ func (c *SystemRolloutController) handleErr(err error, key interface{}) {
 		c.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(c.queue, err, key) {
+		return
+	}
 
 	c.logger.WithError(err).Warn("Worker error")
 	c.queue.AddRateLimited(key)
@@ -248,6 +248,9 @@ func (c *UninstallController) handleErr(err error, key interface{}) {
 		c.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(c.queue, err, key) {
+		return
+	}
 
 	c.logger.WithError(err).Warn("worker error")
 	c.queue.AddRateLimited(key)
@@ -375,9 +378,10 @@ func (c *UninstallController) deleteStorageClass() error {
 }
 
 func (c *UninstallController) deleteLease() error {
-	err := c.ds.DeleteLease(upgrade.LeaseLockName)
-	if err != nil && !datastore.ErrorIsNotFound(err) {
-		return err
+	for _, name := range []string{upgrade.LeaseLockName, LeaderElectionLeaseName} {
+		if err := c.ds.DeleteLease(name); err != nil && !datastore.ErrorIsNotFound(err) {
+			return err
+		}
 	}
 	return nil
 }
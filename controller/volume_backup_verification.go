@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/longhorn/longhorn-manager/constant"
+	"github.com/longhorn/longhorn-manager/datastore"
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+// backupVerificationTimeout is how long the restoration of a verification may
+// take before it's given up, e.g. when the volume can't be scheduled or the
+// backup target is unreachable.
+const backupVerificationTimeout = 24 * time.Hour
+
+// checkBackupVerification finishes the verification of the backup restored
+// to the volume. The engine verifies the checksum of each block during the
+// restoration, so a completed restoration passes the verification and a
+// faulted one fails it. A restoration that doesn't finish before the timeout
+// says nothing about the data, so it's only recorded as timed out. The result
+// is recorded in the backup status and the condition of the backed up volume,
+// then the volume is deleted.
+func (vc *VolumeController) checkBackupVerification(v *longhorn.Volume, backupName string) (finished bool, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to check the verification of backup %v", backupName)
+	}()
+
+	state := longhorn.BackupVerificationStatePassed
+	var verificationErr error
+	switch {
+	case v.Status.RestoreInitiated && !v.Status.RestoreRequired && v.Status.State == longhorn.VolumeStateDetached:
+	case v.Status.Robustness == longhorn.VolumeRobustnessFaulted:
+		state = longhorn.BackupVerificationStateFailed
+		verificationErr = fmt.Errorf("restoration failed: %v",
+			types.GetCondition(v.Status.Conditions, longhorn.VolumeConditionTypeRestore).Message)
+	default:
+		now, err := time.Parse(time.RFC3339, vc.nowHandler())
+		if err != nil {
+			return false, err
+		}
+		if remaining := v.CreationTimestamp.Add(backupVerificationTimeout).Sub(now); remaining > 0 {
+			vc.enqueueVolumeAfter(v, remaining)
+			return false, nil
+		}
+		state = longhorn.BackupVerificationStateTimedOut
+		verificationErr = fmt.Errorf("restoration didn't finish within %v", backupVerificationTimeout)
+	}
+
+	if err := vc.recordBackupVerificationResult(backupName, v.Name, state, verificationErr); err != nil {
+		return false, err
+	}
+
+	log := getLoggerForVolume(vc.logger, v)
+	if verificationErr != nil {
+		log.WithError(verificationErr).Warnf("Backup %v verification finished as %v", backupName, state)
+	} else {
+		log.Infof("Backup %v passed the verification", backupName)
+	}
+	if err := vc.ds.DeleteVolume(v.Name); err != nil && !datastore.ErrorIsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+func (vc *VolumeController) recordBackupVerificationResult(backupName, volumeName string, state longhorn.BackupVerificationState, verificationErr error) error {
+	backup, err := vc.ds.GetBackup(backupName)
+	if err != nil {
+		if datastore.ErrorIsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	// Superseded by another verification
+	if backup.Status.VerificationVolume != volumeName {
+		return nil
+	}
+
+	backup.Status.VerificationVolume = ""
+	backup.Status.LastVerifiedAt = vc.nowHandler()
+	backup.Status.VerificationState = state
+	backup.Status.VerificationError = ""
+	if verificationErr != nil {
+		backup.Status.VerificationError = verificationErr.Error()
+	}
+	if _, err := vc.ds.UpdateBackupStatus(backup); err != nil {
+		return err
+	}
+	if state == longhorn.BackupVerificationStateTimedOut {
+		return nil
+	}
+
+	source, err := vc.ds.GetVolume(backup.Status.VolumeName)
+	if err != nil {
+		if datastore.ErrorIsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	condition := types.GetCondition(source.Status.Conditions, longhorn.VolumeConditionTypeDataCorruption)
+	if state == longhorn.BackupVerificationStateFailed {
+		source.Status.Conditions = types.SetCondition(source.Status.Conditions,
+			longhorn.VolumeConditionTypeDataCorruption, longhorn.ConditionStatusTrue,
+			longhorn.VolumeConditionReasonBackupVerificationFailure,
+			fmt.Sprintf("Backup %v failed the verification: %v", backupName, verificationErr))
+		vc.eventRecorder.Eventf(source, v1.EventTypeWarning, constant.EventReasonFailedBackupVerification,
+			"Backup %v failed the verification: %v", backupName, verificationErr)
+	} else if condition.Status == longhorn.ConditionStatusTrue && condition.Reason == longhorn.VolumeConditionReasonBackupVerificationFailure {
+		// Only clear the condition set by a backup verification, once none
+		// of the verified backups of the volume failed
+		failed, err := vc.getFailedBackupVerification(source.Name, backupName)
+		if err != nil {
+			return err
+		}
+		if failed != "" {
+			return nil
+		}
+		source.Status.Conditions = types.SetCondition(source.Status.Conditions,
+			longhorn.VolumeConditionTypeDataCorruption, longhorn.ConditionStatusFalse, "", "")
+	} else {
+		return nil
+	}
+	_, err = vc.ds.UpdateVolumeStatus(source)
+	return err
+}
+
+// getFailedBackupVerification returns a backup of the volume other than the
+// given one whose last verification failed.
+func (vc *VolumeController) getFailedBackupVerification(volumeName, exceptBackupName string) (string, error) {
+	backups, err := vc.ds.ListBackupsRO()
+	if err != nil {
+		return "", err
+	}
+	for _, backup := range backups {
+		if backup.Name == exceptBackupName || backup.Status.VolumeName != volumeName {
+			continue
+		}
+		if backup.Status.VerificationState == longhorn.BackupVerificationStateFailed {
+			return backup.Name, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/kubernetes/pkg/controller"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	lhfake "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned/fake"
+	lhinformerfactory "github.com/longhorn/longhorn-manager/k8s/pkg/client/informers/externalversions"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *TestSuite) TestCheckBackupVerification(c *C) {
+	datastore.SkipListerCheck = true
+
+	testNow, err := time.Parse(time.RFC3339, TestTimeNow)
+	c.Assert(err, IsNil)
+	verificationVolumeName := "verify-" + TestBackupName
+
+	testCases := map[string]struct {
+		restored     bool
+		faulted      bool
+		startedAt    time.Time
+		corrupted    bool
+		otherFailure bool
+
+		expectedFinished  bool
+		expectedState     longhorn.BackupVerificationState
+		expectedCorrupted bool
+	}{
+		"in progress": {
+			startedAt:     testNow.Add(-time.Hour),
+			expectedState: longhorn.BackupVerificationStateInProgress,
+		},
+		"timed out": {
+			startedAt:        testNow.Add(-backupVerificationTimeout),
+			expectedFinished: true,
+			expectedState:    longhorn.BackupVerificationStateTimedOut,
+		},
+		"timed out on a corrupted volume": {
+			startedAt:         testNow.Add(-backupVerificationTimeout),
+			corrupted:         true,
+			expectedFinished:  true,
+			expectedState:     longhorn.BackupVerificationStateTimedOut,
+			expectedCorrupted: true,
+		},
+		"failed": {
+			faulted:           true,
+			startedAt:         testNow.Add(-time.Hour),
+			expectedFinished:  true,
+			expectedState:     longhorn.BackupVerificationStateFailed,
+			expectedCorrupted: true,
+		},
+		"passed": {
+			restored:         true,
+			startedAt:        testNow.Add(-time.Hour),
+			corrupted:        true,
+			expectedFinished: true,
+			expectedState:    longhorn.BackupVerificationStatePassed,
+		},
+		"passed while another backup failed": {
+			restored:          true,
+			startedAt:         testNow.Add(-time.Hour),
+			corrupted:         true,
+			otherFailure:      true,
+			expectedFinished:  true,
+			expectedState:     longhorn.BackupVerificationStatePassed,
+			expectedCorrupted: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		fmt.Printf("testing %v\n", name)
+
+		kubeClient := fake.NewSimpleClientset()
+		kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+		lhClient := lhfake.NewSimpleClientset()
+		lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+		extensionsClient := apiextensionsfake.NewSimpleClientset()
+		vc := newTestVolumeController(lhInformerFactory, kubeInformerFactory, lhClient, kubeClient, extensionsClient, TestNode1)
+
+		vIndexer := lhInformerFactory.Longhorn().V1beta2().Volumes().Informer().GetIndexer()
+		bIndexer := lhInformerFactory.Longhorn().V1beta2().Backups().Informer().GetIndexer()
+
+		source := newVolume(TestVolumeName, 2)
+		if tc.corrupted {
+			source.Status.Conditions = types.SetCondition(source.Status.Conditions,
+				longhorn.VolumeConditionTypeDataCorruption, longhorn.ConditionStatusTrue,
+				longhorn.VolumeConditionReasonBackupVerificationFailure, "")
+		}
+		v := newVolume(verificationVolumeName, 1)
+		v.CreationTimestamp = metav1.NewTime(tc.startedAt)
+		v.Labels = map[string]string{types.GetLonghornLabelKey(types.LonghornLabelBackupVerification): TestBackupName}
+		v.Status.State = longhorn.VolumeStateAttached
+		v.Status.Robustness = longhorn.VolumeRobustnessHealthy
+		v.Status.RestoreInitiated = true
+		v.Status.RestoreRequired = true
+		if tc.restored {
+			v.Status.State = longhorn.VolumeStateDetached
+			v.Status.RestoreRequired = false
+		}
+		if tc.faulted {
+			v.Status.Robustness = longhorn.VolumeRobustnessFaulted
+		}
+		for _, volume := range []*longhorn.Volume{source, v} {
+			volume, err := lhClient.LonghornV1beta2().Volumes(TestNamespace).Create(context.TODO(), volume, metav1.CreateOptions{})
+			c.Assert(err, IsNil)
+			c.Assert(vIndexer.Add(volume), IsNil)
+		}
+
+		backups := []*longhorn.Backup{{
+			ObjectMeta: metav1.ObjectMeta{Name: TestBackupName, Namespace: TestNamespace},
+			Status: longhorn.BackupStatus{
+				VolumeName:         TestVolumeName,
+				VerificationState:  longhorn.BackupVerificationStateInProgress,
+				VerificationVolume: verificationVolumeName,
+			},
+		}}
+		if tc.otherFailure {
+			backups = append(backups, &longhorn.Backup{
+				ObjectMeta: metav1.ObjectMeta{Name: TestBackupName + "-0", Namespace: TestNamespace},
+				Status: longhorn.BackupStatus{
+					VolumeName:        TestVolumeName,
+					VerificationState: longhorn.BackupVerificationStateFailed,
+				},
+			})
+		}
+		for _, backup := range backups {
+			backup, err := lhClient.LonghornV1beta2().Backups(TestNamespace).Create(context.TODO(), backup, metav1.CreateOptions{})
+			c.Assert(err, IsNil)
+			c.Assert(bIndexer.Add(backup), IsNil)
+		}
+
+		finished, err := vc.checkBackupVerification(v, TestBackupName)
+		c.Assert(err, IsNil)
+		c.Assert(finished, Equals, tc.expectedFinished)
+
+		backup, err := lhClient.LonghornV1beta2().Backups(TestNamespace).Get(context.TODO(), TestBackupName, metav1.GetOptions{})
+		c.Assert(err, IsNil)
+		c.Assert(backup.Status.VerificationState, Equals, tc.expectedState)
+		if tc.expectedFinished {
+			c.Assert(backup.Status.VerificationVolume, Equals, "")
+			c.Assert(backup.Status.LastVerifiedAt, Equals, TestTimeNow)
+		}
+
+		_, err = lhClient.LonghornV1beta2().Volumes(TestNamespace).Get(context.TODO(), verificationVolumeName, metav1.GetOptions{})
+		c.Assert(datastore.ErrorIsNotFound(err), Equals, tc.expectedFinished)
+
+		source, err = lhClient.LonghornV1beta2().Volumes(TestNamespace).Get(context.TODO(), TestVolumeName, metav1.GetOptions{})
+		c.Assert(err, IsNil)
+		condition := types.GetCondition(source.Status.Conditions, longhorn.VolumeConditionTypeDataCorruption)
+		c.Assert(condition.Status == longhorn.ConditionStatusTrue, Equals, tc.expectedCorrupted)
+	}
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+// The conditions below are only added to the volume once they become true, and
+// then set back to false when they're resolved.
+
+func (vc *VolumeController) syncRebuildingCondition(v *longhorn.Volume, es map[string]*longhorn.Engine) {
+	rebuilding := []string{}
+	for _, e := range es {
+		for name, mode := range e.Status.ReplicaModeMap {
+			if mode == longhorn.ReplicaModeWO {
+				rebuilding = append(rebuilding, name)
+			}
+		}
+	}
+	if len(rebuilding) > 0 {
+		sort.Strings(rebuilding)
+		v.Status.Conditions = types.SetCondition(v.Status.Conditions,
+			longhorn.VolumeConditionTypeRebuilding, longhorn.ConditionStatusTrue,
+			longhorn.VolumeConditionReasonReplicaRebuilding,
+			fmt.Sprintf("Rebuilding replicas %v", strings.Join(rebuilding, ", ")))
+		return
+	}
+	if types.GetCondition(v.Status.Conditions, longhorn.VolumeConditionTypeRebuilding).Status == longhorn.ConditionStatusTrue {
+		v.Status.Conditions = types.SetCondition(v.Status.Conditions,
+			longhorn.VolumeConditionTypeRebuilding, longhorn.ConditionStatusFalse, "", "")
+	}
+}
+
+// syncBackupTargetUnavailableCondition explains why a volume restoring from
+// the backup target, e.g. a DR volume, doesn't make progress.
+func (vc *VolumeController) syncBackupTargetUnavailableCondition(v *longhorn.Volume) error {
+	message := ""
+	if v.Status.RestoreRequired || v.Status.IsStandby {
+		backupTarget, err := vc.ds.GetDefaultBackupTargetRO()
+		if err != nil && !datastore.ErrorIsNotFound(err) {
+			return err
+		}
+		if backupTarget != nil {
+			if condition := types.GetCondition(backupTarget.Status.Conditions, longhorn.BackupTargetConditionTypeUnavailable); condition.Status == longhorn.ConditionStatusTrue {
+				message = fmt.Sprintf("The backup target %v is unavailable: %v", backupTarget.Spec.BackupTargetURL, condition.Message)
+			}
+		}
+	}
+	if message != "" {
+		v.Status.Conditions = types.SetCondition(v.Status.Conditions,
+			longhorn.VolumeConditionTypeBackupTargetUnavailable, longhorn.ConditionStatusTrue,
+			longhorn.VolumeConditionReasonBackupTargetUnavailable, message)
+		return nil
+	}
+	if types.GetCondition(v.Status.Conditions, longhorn.VolumeConditionTypeBackupTargetUnavailable).Status == longhorn.ConditionStatusTrue {
+		v.Status.Conditions = types.SetCondition(v.Status.Conditions,
+			longhorn.VolumeConditionTypeBackupTargetUnavailable, longhorn.ConditionStatusFalse, "", "")
+	}
+	return nil
+}
@@ -44,8 +44,6 @@ var (
 
 	RetryInterval = 100 * time.Millisecond
 	RetryCounts   = 20
-
-	AutoSalvageTimeLimit = 1 * time.Minute
 )
 
 const (
@@ -78,6 +76,9 @@ type VolumeController struct {
 	nowHandler func() string
 
 	proxyConnCounter util.Counter
+
+	filesystemChecks  *filesystemChecks
+	filesystemFormats *filesystemFormats
 }
 
 func NewVolumeController(
@@ -109,6 +110,9 @@ func NewVolumeController(
 
 		nowHandler: util.Now,
 
+		filesystemChecks:  newFilesystemChecks(),
+		filesystemFormats: newFilesystemFormats(),
+
 		proxyConnCounter: proxyConnCounter,
 	}
 
@@ -169,6 +173,12 @@ func NewVolumeController(
 			UpdateFunc: func(old, cur interface{}) { vc.enqueueSettingChange(cur) },
 		},
 	}, 0)
+	ds.SettingInformer.AddEventHandlerWithResyncPeriod(cache.FilteringResourceEventHandler{
+		FilterFunc: isSettingRelatedToReplicaScheduling,
+		Handler: cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(old, cur interface{}) { vc.enqueueVolumesPendingReplicaScheduling() },
+		},
+	}, 0)
 	vc.cacheSyncs = append(vc.cacheSyncs, ds.SettingInformer.HasSynced)
 
 	return vc
@@ -216,6 +226,9 @@ func (vc *VolumeController) handleErr(err error, key interface{}) {
 		vc.queue.Forget(key)
 		return
 	}
+	if requeueIfDataStoreUnavailable(vc.queue, err, key) {
+		return
+	}
 
 	if vc.queue.NumRequeues(key) < maxRetries {
 		vc.logger.WithError(err).Warnf("Error syncing Longhorn volume %v", key)
@@ -228,6 +241,15 @@ func (vc *VolumeController) handleErr(err error, key interface{}) {
 	vc.queue.Forget(key)
 }
 
+// withLastRequestID appends the ID of the request last changing the volume
+// to the event message, so the event can be traced back to the request.
+func withLastRequestID(v *longhorn.Volume, msg string) string {
+	if requestID := v.Annotations[types.VolumeAnnotationLastRequestID]; requestID != "" {
+		return fmt.Sprintf("%v (request %v)", msg, requestID)
+	}
+	return msg
+}
+
 func getLoggerForVolume(logger logrus.FieldLogger, v *longhorn.Volume) *logrus.Entry {
 	log := logger.WithFields(
 		logrus.Fields{
@@ -239,6 +261,9 @@ func getLoggerForVolume(logger logrus.FieldLogger, v *longhorn.Volume) *logrus.E
 			"migratable": v.Spec.Migratable,
 		},
 	)
+	if requestID := v.Annotations[types.VolumeAnnotationLastRequestID]; requestID != "" {
+		log = log.WithField("lastRequestID", requestID)
+	}
 
 	if v.Spec.AccessMode == longhorn.AccessModeReadWriteMany {
 		log = log.WithFields(
@@ -313,6 +338,26 @@ func (vc *VolumeController) syncVolume(key string) (err error) {
 		return err
 	}
 
+	if volume.DeletionTimestamp == nil && volume.Spec.ExpireAt != "" {
+		expired, err := vc.checkVolumeExpiration(volume)
+		if err != nil {
+			return err
+		}
+		if expired {
+			return nil
+		}
+	}
+
+	if backupName := volume.Labels[types.GetLonghornLabelKey(types.LonghornLabelBackupVerification)]; volume.DeletionTimestamp == nil && backupName != "" {
+		finished, err := vc.checkBackupVerification(volume, backupName)
+		if err != nil {
+			return err
+		}
+		if finished {
+			return nil
+		}
+	}
+
 	if volume.DeletionTimestamp != nil {
 		if volume.Status.State != longhorn.VolumeStateDeleting {
 			volume.Status.State = longhorn.VolumeStateDeleting
@@ -435,10 +480,24 @@ func (vc *VolumeController) syncVolume(key string) (err error) {
 		}
 	}()
 
+	vc.syncReducedRedundancyCondition(volume)
+	vc.syncFrozenFilesystem(volume)
+	if finished, err := vc.syncFilesystemFormat(volume); err != nil || finished {
+		return err
+	}
+	if finished, err := vc.syncFilesystemCheck(volume); err != nil || finished {
+		return err
+	}
+
 	if err := vc.ReconcileEngineReplicaState(volume, engines, replicas); err != nil {
 		return err
 	}
 
+	vc.syncRebuildingCondition(volume, engines)
+	if err := vc.syncBackupTargetUnavailableCondition(volume); err != nil {
+		return err
+	}
+
 	if err := vc.syncVolumeUnmapMarkSnapChainRemovedSetting(volume, engines, replicas); err != nil {
 		return err
 	}
@@ -506,7 +565,7 @@ func (vc *VolumeController) EvictReplicas(v *longhorn.Volume,
 		}
 	}
 
-	if healthyNonEvictingCount < v.Spec.NumberOfReplicas && !hasNewReplica {
+	if healthyNonEvictingCount < vc.getEffectiveReplicaCount(v) && !hasNewReplica {
 		if err := vc.replenishReplicas(v, e, rs, ""); err != nil {
 			log.WithError(err).Error("Failed to create new replica for replica eviction")
 			vc.eventRecorder.Eventf(v, v1.EventTypeWarning,
@@ -646,10 +705,11 @@ func (vc *VolumeController) ReconcileEngineReplicaState(v *longhorn.Volume, es m
 	isMigratingDone := !vc.isVolumeMigrating(v) && len(es) == 1
 
 	oldRobustness := v.Status.Robustness
+	replicaCount := vc.getEffectiveReplicaCount(v)
 	if healthyCount == 0 { // no healthy replica exists, going to faulted
 		// ReconcileVolumeState() will deal with the faulted case
 		return nil
-	} else if healthyCount >= v.Spec.NumberOfReplicas {
+	} else if healthyCount >= replicaCount {
 		v.Status.Robustness = longhorn.VolumeRobustnessHealthy
 		if oldRobustness == longhorn.VolumeRobustnessDegraded {
 			vc.eventRecorder.Eventf(v, v1.EventTypeNormal, constant.EventReasonHealthy, "volume %v became healthy", v.Name)
@@ -681,7 +741,7 @@ func (vc *VolumeController) ReconcileEngineReplicaState(v *longhorn.Volume, es m
 			}
 		}
 
-	} else { // healthyCount < v.Spec.NumberOfReplicas
+	} else { // healthyCount < replicaCount
 		v.Status.Robustness = longhorn.VolumeRobustnessDegraded
 		if oldRobustness != longhorn.VolumeRobustnessDegraded {
 			v.Status.LastDegradedAt = vc.nowHandler()
@@ -847,33 +907,66 @@ func (vc *VolumeController) cleanupCorruptedOrStaleReplicas(v *longhorn.Volume,
 		if r.DeletionTimestamp != nil {
 			continue
 		}
-		staled := false
-		if v.Spec.StaleReplicaTimeout > 0 && util.TimestampAfterTimeout(r.Spec.FailedAt,
-			time.Duration(int64(v.Spec.StaleReplicaTimeout*60))*time.Second) {
-
-			staled = true
-		}
 
 		// 1. failed for multiple times or failed at rebuilding (`Spec.RebuildRetryCount` of a newly created rebuilding replica
 		//    is `FailedReplicaMaxRetryCount`) before ever became healthy/ mode RW,
-		// 2. failed too long ago, became stale and unnecessary to keep around, unless we don't have any healthy replicas
-		// 3. failed for race condition at upgrading when waiting IM-r to start and it would never became healty
-		if (r.Spec.RebuildRetryCount >= scheduler.FailedReplicaMaxRetryCount) || (healthyCount != 0 && staled) || (r.Spec.EngineImage != v.Status.CurrentImage) {
-			log.WithField("replica", r.Name).Info("Cleaning up corrupted, staled replica")
+		// 2. failed for race condition at upgrading when waiting IM-r to start and it would never became healty
+		if (r.Spec.RebuildRetryCount >= scheduler.FailedReplicaMaxRetryCount) || (r.Spec.EngineImage != v.Status.CurrentImage) {
+			log.WithField("replica", r.Name).Info("Cleaning up corrupted replica")
 			if err := vc.deleteReplica(r, rs); err != nil {
-				return errors.Wrapf(err, "cannot cleanup staled replica %v", r.Name)
+				return errors.Wrapf(err, "cannot cleanup corrupted replica %v", r.Name)
 			}
+			continue
+		}
+
+		if err := vc.cleanupStaleReplica(v, r, rs, healthyCount); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// cleanupStaleReplica deletes the failed replica once it has been failed for
+// longer than the stale replica timeout of the volume, in minutes. The
+// cleanup is disabled if the timeout is 0. The failed replica is kept until
+// the volume has as many healthy replicas as requested, since it may still
+// be reused by a rebuild or needed for a salvage. Before the timeout, the
+// volume is requeued to clean the replica up on time. A replica with an
+// invalid failed time is skipped rather than blocking the other replicas.
+func (vc *VolumeController) cleanupStaleReplica(v *longhorn.Volume, r *longhorn.Replica, rs map[string]*longhorn.Replica, healthyCount int) error {
+	if v.Spec.StaleReplicaTimeout <= 0 {
+		return nil
+	}
+	log := getLoggerForVolume(vc.logger, v).WithField("replica", r.Name)
+	failedAt, err := util.ParseTime(r.Spec.FailedAt)
+	if err != nil {
+		log.WithError(err).Warnf("Failed to parse the failed time %v of the replica, skipping its stale replica cleanup", r.Spec.FailedAt)
+		return nil
+	}
+	timeout := time.Duration(v.Spec.StaleReplicaTimeout) * time.Minute
+	if remaining := time.Until(failedAt.Add(timeout)); remaining > 0 {
+		vc.enqueueVolumeAfter(v, remaining)
+		return nil
+	}
+	if healthyCount < v.Spec.NumberOfReplicas {
+		return nil
+	}
+
+	log.Infof("Cleaning up replica failed for longer than the stale replica timeout %v", timeout)
+	if err := vc.deleteReplica(r, rs); err != nil {
+		return errors.Wrapf(err, "cannot cleanup stale replica %v", r.Name)
+	}
+	vc.eventRecorder.Eventf(v, v1.EventTypeNormal, constant.EventReasonStaleReplicaDeleted,
+		"deleted stale replica %v failed at %v after the timeout %v", r.Name, r.Spec.FailedAt, timeout)
+	return nil
+}
+
 func (vc *VolumeController) cleanupFailedToScheduledReplicas(v *longhorn.Volume, rs map[string]*longhorn.Replica) (err error) {
 	healthyCount := getHealthyAndActiveReplicaCount(rs)
 	hasEvictionRequestedReplicas := vc.hasReplicaEvictionRequested(rs)
 
-	if healthyCount >= v.Spec.NumberOfReplicas {
+	if healthyCount >= vc.getEffectiveReplicaCount(v) {
 		for _, r := range rs {
 			if !hasEvictionRequestedReplicas {
 				if r.Spec.HealthyAt == "" && r.Spec.NodeID == "" &&
@@ -915,6 +1008,10 @@ func (vc *VolumeController) cleanupExtraHealthyReplicas(v *longhorn.Volume, e *l
 		return err
 	}
 
+	if _, err = vc.cleanupScaledDownReplicas(v, e, rs); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -1024,6 +1121,42 @@ func (vc *VolumeController) cleanupDataLocalityReplicas(v *longhorn.Volume, e *l
 	return false, nil
 }
 
+// cleanupScaledDownReplicas deletes one extra healthy replica after the
+// replica count is reduced, if the auto-balance doesn't. The least preferred
+// replicas are the ones sharing a disk, a node, then a zone, and the local
+// replica is kept if the data locality is enabled.
+func (vc *VolumeController) cleanupScaledDownReplicas(v *longhorn.Volume, e *longhorn.Engine, rs map[string]*longhorn.Replica) (bool, error) {
+	healthyReplicas := map[string]*longhorn.Replica{}
+	for _, r := range rs {
+		if r.Spec.Active && r.Spec.HealthyAt != "" && r.Spec.FailedAt == "" {
+			healthyReplicas[r.Name] = r
+		}
+	}
+	if len(healthyReplicas) <= v.Spec.NumberOfReplicas || v.Spec.NumberOfReplicas < 1 {
+		return false, nil
+	}
+
+	rNames, err := vc.getPreferredReplicaCandidatesForDeletion(healthyReplicas)
+	if err != nil {
+		return false, err
+	}
+	// Idempotent for the same input like the auto-balance, by deleting the
+	// replica with the smallest name
+	sort.Strings(rNames)
+	for _, rName := range rNames {
+		r := healthyReplicas[rName]
+		if !isDataLocalityDisabled(v) && r.Spec.NodeID == e.Spec.NodeID {
+			continue
+		}
+		if err := vc.deleteReplica(r, rs); err != nil {
+			return false, err
+		}
+		getLoggerForVolume(vc.logger, v).Infof("Deleted replica %v after the replica count is reduced to %v", r.Name, v.Spec.NumberOfReplicas)
+		return true, nil
+	}
+	return false, nil
+}
+
 func (vc *VolumeController) getAutoBalancedReplicasSetting(v *longhorn.Volume) (longhorn.ReplicaAutoBalance, error) {
 	var setting longhorn.ReplicaAutoBalance
 
@@ -1161,6 +1294,13 @@ func (vc *VolumeController) ReconcileVolumeState(v *longhorn.Volume, es map[stri
 		}
 		newVolume = true
 		es[e.Name] = e
+
+		// The replicas of an imported volume are created before the engine
+		for _, r := range rs {
+			if r.Spec.EngineName == "" {
+				r.Spec.EngineName = e.Name
+			}
+		}
 	}
 
 	if len(e.Status.Snapshots) != 0 {
@@ -1338,67 +1478,25 @@ func (vc *VolumeController) ReconcileVolumeState(v *longhorn.Volume, es map[stri
 				return err
 			}
 			if !isNodeDownOrDeleted || !shouldBeAttached {
-				lastFailedAt := time.Time{}
-				failedUsableReplicas := map[string]*longhorn.Replica{}
-				dataExists := false
-
-				for _, r := range rs {
-					if r.Spec.HealthyAt == "" {
-						continue
-					}
-					dataExists = true
-					if r.Spec.NodeID == "" || r.Spec.DiskID == "" {
-						continue
-					}
-					if isDownOrDeleted, err := vc.ds.IsNodeDownOrDeleted(r.Spec.NodeID); err != nil {
-						log.WithField("replica", r.Name).WithError(err).Errorf("Unable to check if node %v is still running for failed replica", r.Spec.NodeID)
-						continue
-					} else if isDownOrDeleted {
-						continue
-					}
-					node, err := vc.ds.GetNode(r.Spec.NodeID)
-					if err != nil {
-						log.WithField("replica", r.Name).WithError(err).Errorf("Unable to get node %v for failed replica", r.Spec.NodeID)
-					}
-					diskSchedulable := false
-					for _, diskStatus := range node.Status.DiskStatus {
-						if diskStatus.DiskUUID == r.Spec.DiskID {
-							if types.GetCondition(diskStatus.Conditions, longhorn.DiskConditionTypeSchedulable).Status == longhorn.ConditionStatusTrue {
-								diskSchedulable = true
-								break
-							}
-						}
-					}
-					if !diskSchedulable {
-						continue
-					}
-					failedAt, err := util.ParseTime(r.Spec.FailedAt)
-					if err != nil {
-						log.WithField("replica", r.Name).WithError(err).Error("Unable to parse FailedAt timestamp for replica")
-						continue
-					}
-					if failedAt.After(lastFailedAt) {
-						lastFailedAt = failedAt
-					}
-					// all failedUsableReplica contains data
-					failedUsableReplicas[r.Name] = r
-				}
+				salvageableReplicas, dataExists := vc.ds.GetSalvageableReplicas(rs, types.AutoSalvageTimeLimit)
 				if !dataExists {
 					log.Warn("Cannot auto salvage volume: no data exists")
 				} else {
 					// This salvage is for revision counter enabled case
-					salvaged := false
-					// Bring up the replicas for auto-salvage
-					for _, r := range failedUsableReplicas {
-						if util.TimestampWithinLimit(lastFailedAt, r.Spec.FailedAt, AutoSalvageTimeLimit) {
-							r.Spec.FailedAt = ""
-							log.WithField("replica", r.Name).Warn("Automatically salvaging volume replica")
-							msg := fmt.Sprintf("Replica %v of volume %v will be automatically salvaged", r.Name, v.Name)
-							vc.eventRecorder.Event(v, v1.EventTypeWarning, constant.EventReasonAutoSalvaged, msg)
-							salvaged = true
-						}
+					// Bring up the replicas for auto-salvage. They are the ones
+					// failed along with the last failed one, so they have the
+					// latest data.
+					for _, r := range salvageableReplicas {
+						r.Spec.FailedAt = ""
+						log.WithField("replica", r.Name).Warn("Automatically salvaging volume replica")
+						msg := fmt.Sprintf("Replica %v of volume %v will be automatically salvaged", r.Name, v.Name)
+						vc.eventRecorder.Event(v, v1.EventTypeWarning, constant.EventReasonAutoSalvaged, msg)
 					}
-					if salvaged {
+					if len(salvageableReplicas) > 0 {
+						if len(salvageableReplicas) < len(rs) {
+							log.Infof("Automatically salvaging %v of %v replicas, the others failed more than %v before the last failure or are on an unavailable node or disk",
+								len(salvageableReplicas), len(rs), types.AutoSalvageTimeLimit)
+						}
 						// remount the reattached volume later if possible
 						// For the auto-salvaged volume, `v.Status.CurrentNodeID` is empty but `v.Spec.NodeID` shouldn't be empty.
 						// There shouldn't be any problems if v.Spec.NodeID is empty, since the volume is desired to be detached
@@ -1522,7 +1620,7 @@ func (vc *VolumeController) ReconcileVolumeState(v *longhorn.Volume, es map[stri
 
 		v.Status.State = longhorn.VolumeStateDetached
 		if oldState != v.Status.State {
-			vc.eventRecorder.Eventf(v, v1.EventTypeNormal, constant.EventReasonDetached, "volume %v has been detached", v.Name)
+			vc.eventRecorder.Event(v, v1.EventTypeNormal, constant.EventReasonDetached, withLastRequestID(v, fmt.Sprintf("volume %v has been detached", v.Name)))
 		}
 		// Automatic reattach the volume if PendingNodeID was set
 		// TODO: need to revisit for CurrentNodeID and PendingNodeID update
@@ -1533,12 +1631,8 @@ func (vc *VolumeController) ReconcileVolumeState(v *longhorn.Volume, es map[stri
 		// state of this sync loop if this code block is reached.
 		// Hence updating v.status.CurrentNodeID here is weird.
 		if v.Status.PendingNodeID != "" {
-			if isReady, err := vc.ds.CheckEngineImageReadiness(v.Status.CurrentImage, v.Status.PendingNodeID); !isReady {
-				log.WithError(err).Warnf("skip auto attach because current image %v is not ready", v.Status.CurrentImage)
-			} else {
-				log.Infof("Prepare to reattach the volume to %v after the detachment success", v.Status.PendingNodeID)
-				v.Status.CurrentNodeID = v.Status.PendingNodeID
-				v.Status.PendingNodeID = ""
+			if err := vc.reattachVolume(v); err != nil {
+				return err
 			}
 		}
 
@@ -1651,6 +1745,10 @@ func (vc *VolumeController) ReconcileVolumeState(v *longhorn.Volume, es map[stri
 		// The volume may be activated
 		e.Spec.DisableFrontend = v.Status.FrontendDisabled
 		e.Spec.Frontend = v.Spec.Frontend
+		// The read-only mode of a running engine cannot change
+		if e.Status.CurrentState == longhorn.InstanceStateStopped {
+			e.Spec.ReadOnly = v.Spec.ReadOnly
+		}
 		// wait for engine to be up
 		if e.Status.CurrentState != longhorn.InstanceStateRunning {
 			return nil
@@ -1669,7 +1767,7 @@ func (vc *VolumeController) ReconcileVolumeState(v *longhorn.Volume, es map[stri
 
 		v.Status.State = longhorn.VolumeStateAttached
 		if oldState != v.Status.State {
-			vc.eventRecorder.Eventf(v, v1.EventTypeNormal, constant.EventReasonAttached, "volume %v has been attached to %v", v.Name, v.Status.CurrentNodeID)
+			vc.eventRecorder.Event(v, v1.EventTypeNormal, constant.EventReasonAttached, withLastRequestID(v, fmt.Sprintf("volume %v has been attached to %v", v.Name, v.Status.CurrentNodeID)))
 		}
 	}
 	return nil
@@ -1816,6 +1914,12 @@ func (vc *VolumeController) replenishReplicas(v *longhorn.Volume, e *longhorn.En
 		return nil
 	}
 
+	if len(rs) != 0 {
+		if deferred, err := vc.isRebuildDeferredToOffPeakHours(v, rs); err != nil || deferred {
+			return err
+		}
+	}
+
 	log := getLoggerForVolume(vc.logger, v)
 
 	replenishCount, updateNodeAffinity := vc.getReplenishReplicasCount(v, rs, e)
@@ -2233,12 +2337,13 @@ func (vc *VolumeController) getReplenishReplicasCount(v *longhorn.Volume, rs map
 		return 0, ""
 	}
 
+	replicaCount := vc.getEffectiveReplicaCount(v)
 	switch {
-	case v.Spec.NumberOfReplicas < usableCount:
+	case replicaCount < usableCount:
 		return 0, ""
-	case v.Spec.NumberOfReplicas > usableCount:
-		return v.Spec.NumberOfReplicas - usableCount, ""
-	case v.Spec.NumberOfReplicas == usableCount:
+	case replicaCount > usableCount:
+		return replicaCount - usableCount, ""
+	case replicaCount == usableCount:
 		if adjustCount := vc.getReplicaCountForAutoBalanceLeastEffort(v, e, rs, vc.getReplicaCountForAutoBalanceZone); adjustCount != 0 {
 			return adjustCount, ""
 		}
@@ -2733,8 +2838,9 @@ func (vc *VolumeController) checkAndInitVolumeClone(v *longhorn.Volume) (err err
 	}
 
 	if snapshotName == "" {
-		labels := map[string]string{types.GetLonghornLabelKey(types.LonghornLabelSnapshotForCloningVolume): v.Name}
-		snapshot, err := vc.createSnapshot("", labels, sourceVol, e)
+		labels := types.GetSystemSnapshotLabels(types.SystemSnapshotPurposeCloneVolume, v.Name)
+		labels[types.GetLonghornLabelKey(types.LonghornLabelSnapshotForCloningVolume)] = v.Name
+		snapshot, err := vc.createSnapshot(types.GenerateSystemSnapshotName(types.SystemSnapshotPurposeCloneVolume, v.Name), labels, sourceVol, e)
 		if err != nil {
 			return errors.Wrapf(err, "failed to create snapshot of source volume %v", sourceVol.Name)
 		}
@@ -2811,6 +2917,39 @@ func (vc *VolumeController) reconcileVolumeSize(v *longhorn.Volume, e *longhorn.
 	return nil
 }
 
+// reattachVolume attaches the volume detached unexpectedly, e.g. by a node
+// reboot, again. If the workload is rescheduled to another node meanwhile,
+// the volume follows it. It waits for the node to come back otherwise, and
+// the node change enqueues the volume.
+func (vc *VolumeController) reattachVolume(v *longhorn.Volume) error {
+	log := getLoggerForVolume(vc.logger, v)
+
+	if v.Spec.NodeID != "" && v.Spec.NodeID != v.Status.PendingNodeID {
+		log.Infof("Volume is requested to attach to node %v instead of %v, reattach it there", v.Spec.NodeID, v.Status.PendingNodeID)
+		v.Status.PendingNodeID = v.Spec.NodeID
+	}
+	nodeID := v.Status.PendingNodeID
+
+	isDown, err := vc.ds.IsNodeDownOrDeleted(nodeID)
+	if err != nil {
+		return err
+	}
+	if isDown {
+		log.Infof("Waiting for node %v to come back to reattach the volume", nodeID)
+		return nil
+	}
+	if isReady, err := vc.ds.CheckEngineImageReadiness(v.Status.CurrentImage, nodeID); !isReady {
+		log.WithError(err).Warnf("skip auto attach because current image %v is not ready", v.Status.CurrentImage)
+		return nil
+	}
+
+	log.Infof("Prepare to reattach the volume to %v after the detachment success", nodeID)
+	v.Status.CurrentNodeID = nodeID
+	v.Status.PendingNodeID = ""
+	vc.eventRecorder.Eventf(v, v1.EventTypeNormal, constant.EventReasonAutoReattached, "volume %v is automatically reattached to node %v", v.Name, nodeID)
+	return nil
+}
+
 func (vc *VolumeController) checkForAutoAttachment(v *longhorn.Volume, e *longhorn.Engine, rs map[string]*longhorn.Replica, scheduled bool) error {
 	if v.Spec.NodeID != "" || v.Status.CurrentNodeID != "" {
 		return nil
@@ -3006,6 +3145,7 @@ func (vc *VolumeController) createEngine(v *longhorn.Volume, isNewEngine bool) (
 			ReplicaAddressMap:         map[string]string{},
 			UpgradedReplicaAddressMap: map[string]string{},
 			RevisionCounterDisabled:   v.Spec.RevisionCounterDisabled,
+			ReadOnly:                  v.Spec.ReadOnly,
 		},
 	}
 
@@ -3094,6 +3234,29 @@ func (vc *VolumeController) enqueueVolume(obj interface{}) {
 	vc.queue.Add(key)
 }
 
+// checkVolumeExpiration deletes the volume once its expiration time is
+// reached, which also detaches it. Otherwise the volume is requeued for the
+// expiration time.
+func (vc *VolumeController) checkVolumeExpiration(v *longhorn.Volume) (expired bool, err error) {
+	expireAt, err := time.Parse(time.RFC3339, v.Spec.ExpireAt)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to parse expiration time %v", v.Spec.ExpireAt)
+	}
+
+	remaining := expireAt.Sub(time.Now())
+	if remaining > 0 {
+		vc.enqueueVolumeAfter(v, remaining)
+		return false, nil
+	}
+
+	getLoggerForVolume(vc.logger, v).Infof("Deleting volume since it expired at %v", v.Spec.ExpireAt)
+	vc.eventRecorder.Eventf(v, v1.EventTypeNormal, constant.EventReasonExpired, "Deleting volume %v since it expired at %v", v.Name, v.Spec.ExpireAt)
+	if err := vc.ds.DeleteVolume(v.Name); err != nil && !datastore.ErrorIsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
+
 func (vc *VolumeController) enqueueVolumeAfter(obj interface{}, duration time.Duration) {
 	key, err := controller.KeyFunc(obj)
 	if err != nil {
@@ -3417,6 +3580,14 @@ func (vc *VolumeController) processMigration(v *longhorn.Volume, es map[string]*
 
 	log := getLoggerForVolume(vc.logger, v).WithField("migrationNodeID", v.Spec.MigrationNodeID)
 
+	if !vc.isVolumeMigrating(v) {
+		v.Status.MigrationState = longhorn.VolumeMigrationStateEmpty
+		v.Status.MigrationStartedAt = ""
+	} else if v.Status.MigrationState == longhorn.VolumeMigrationStateEmpty {
+		v.Status.MigrationState = longhorn.VolumeMigrationStatePreparing
+		v.Status.MigrationStartedAt = vc.nowHandler()
+	}
+
 	// only process if volume is attached and running
 	if v.Spec.NodeID == "" || v.Status.CurrentNodeID == "" || len(es) == 0 {
 		return nil
@@ -3485,6 +3656,7 @@ func (vc *VolumeController) processMigration(v *longhorn.Volume, es map[string]*
 		}
 
 		log.Warnf("The migration engine or all migration replicas crashed, will clean them up now")
+		v.Status.MigrationState = longhorn.VolumeMigrationStatePreparing
 
 		currentEngine, err := vc.getCurrentEngineAndCleanupOthers(v, es)
 		if err != nil {
@@ -3557,10 +3729,12 @@ func (vc *VolumeController) processMigration(v *longhorn.Volume, es map[string]*
 		return err
 	}
 	if !ready || revertRequired {
+		v.Status.MigrationState = longhorn.VolumeMigrationStatePreparing
 		return nil
 	}
 
 	log.Info("volume migration engine is ready")
+	v.Status.MigrationState = longhorn.VolumeMigrationStateReady
 	return nil
 }
 
@@ -3732,6 +3906,14 @@ func (vc *VolumeController) isResponsibleFor(v *longhorn.Volume, defaultEngineIm
 		err = errors.Wrap(err, "error while checking isResponsibleFor")
 	}()
 
+	handedOver, takeOver, err := vc.checkOwnerEviction(v)
+	if err != nil {
+		return false, err
+	}
+	if handedOver {
+		return takeOver, nil
+	}
+
 	readyNodesWithDefaultEI, err := vc.ds.ListReadyNodesWithEngineImage(defaultEngineImage)
 	if err != nil {
 		return false, err
@@ -3765,6 +3947,43 @@ func (vc *VolumeController) isResponsibleFor(v *longhorn.Volume, defaultEngineIm
 	return isPreferredOwner || continueToBeOwner || requiresNewOwner, nil
 }
 
+// checkOwnerEviction returns if the detached volume is handed over from its
+// owner node, since the node is being evicted, e.g. for the decommission of
+// the node. Otherwise the volume would be attached to the owner for the
+// eviction of its replicas. It's handed over to any other ready node that has
+// the engine image of the volume and isn't being evicted, and the current
+// node takes it over if it's one of them.
+func (vc *VolumeController) checkOwnerEviction(v *longhorn.Volume) (handedOver, takeOver bool, err error) {
+	if v.Spec.NodeID != "" || v.Status.State != longhorn.VolumeStateDetached || v.Status.OwnerID == "" {
+		return false, false, nil
+	}
+	owner, err := vc.ds.GetNodeRO(v.Status.OwnerID)
+	if err != nil {
+		if datastore.ErrorIsNotFound(err) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+	if !owner.Spec.EvictionRequested {
+		return false, false, nil
+	}
+
+	nodes, err := vc.ds.ListReadyNodesWithEngineImage(v.Spec.EngineImage)
+	if err != nil {
+		return false, false, err
+	}
+	for name, node := range nodes {
+		if name == owner.Name || node.Spec.EvictionRequested {
+			continue
+		}
+		handedOver = true
+		if name == vc.controllerID {
+			takeOver = true
+		}
+	}
+	return handedOver, takeOver, nil
+}
+
 func (vc *VolumeController) deleteReplica(r *longhorn.Replica, rs map[string]*longhorn.Replica) error {
 	// Must call Update before removal to keep the fields up to date
 	if _, err := vc.ds.UpdateReplica(r); err != nil {
@@ -3971,6 +4190,18 @@ func (vc *VolumeController) enqueueNodeChange(obj interface{}) {
 		}
 	}
 
+	// The volumes waiting for the node to come back to reattach
+	volumes, err := vc.ds.ListVolumesRO()
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to list volumes when enqueuing node %v: %v", node.Name, err))
+		return
+	}
+	for _, v := range volumes {
+		if v.Status.PendingNodeID == node.Name {
+			vc.enqueueVolume(v)
+		}
+	}
+
 	replicas, err := vc.ds.ListReplicasRO()
 	if err != nil {
 		utilruntime.HandleError(fmt.Errorf("failed to list replicas when enqueuing node %v: %v", node.Name, err))
@@ -4037,6 +4268,43 @@ func (vc *VolumeController) enqueueSettingChange(obj interface{}) {
 	}
 }
 
+// settingsRelatedToReplicaScheduling are read when a replica is scheduled or
+// rebuilt. The volumes waiting for it are requeued on the change, rather than
+// on the next resync.
+var settingsRelatedToReplicaScheduling = map[types.SettingName]bool{
+	types.SettingNameReplicaSoftAntiAffinity:              true,
+	types.SettingNameReplicaZoneSoftAntiAffinity:          true,
+	types.SettingNameReplicaZoneNetworkCost:               true,
+	types.SettingNameStorageOverProvisioningPercentage:    true,
+	types.SettingNameStorageMinimalAvailablePercentage:    true,
+	types.SettingNameDisableSchedulingOnCordonedNode:      true,
+	types.SettingNameConcurrentReplicaRebuildPerNodeLimit: true,
+	types.SettingNameReplicaReplenishmentWaitInterval:     true,
+	types.SettingNameReplicaRebuildOffPeakHours:           true,
+}
+
+func isSettingRelatedToReplicaScheduling(obj interface{}) bool {
+	setting, ok := obj.(*longhorn.Setting)
+	if !ok {
+		return false
+	}
+	return settingsRelatedToReplicaScheduling[types.SettingName(setting.Name)]
+}
+
+func (vc *VolumeController) enqueueVolumesPendingReplicaScheduling() {
+	vs, err := vc.ds.ListVolumesRO()
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to list volumes pending replica scheduling: %v", err))
+		return
+	}
+	for _, v := range vs {
+		scheduledCondition := types.GetCondition(v.Status.Conditions, longhorn.VolumeConditionTypeScheduled)
+		if v.Status.Robustness == longhorn.VolumeRobustnessDegraded || scheduledCondition.Status == longhorn.ConditionStatusFalse {
+			vc.enqueueVolume(v)
+		}
+	}
+}
+
 // ReconcileBackupVolumeState is responsible for syncing the state of backup volumes to volume.status
 func (vc *VolumeController) ReconcileBackupVolumeState(volume *longhorn.Volume) error {
 	log := getLoggerForVolume(vc.logger, volume)
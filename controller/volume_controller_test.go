@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -85,6 +87,7 @@ type VolumeTestCase struct {
 	replicaNodeSoftAntiAffinity      string
 	volumeAutoSalvage                string
 	replicaReplenishmentWaitInterval string
+	replicaRebuildOffPeakHours       string
 }
 
 func (s *TestSuite) TestVolumeLifeCycle(c *C) {
@@ -547,13 +550,11 @@ func (s *TestSuite) TestVolumeLifeCycle(c *C) {
 		}
 	}
 	tc.copyCurrentToExpect()
-	newReplicaMap := map[string]*longhorn.Replica{}
-	for name, r := range tc.replicas {
-		if name != failedReplicaName {
-			newReplicaMap[name] = r
-		}
-	}
-	tc.expectReplicas = newReplicaMap
+	// The failed replica is kept until the volume has enough healthy replicas
+	expectFailedReplica := tc.expectReplicas[failedReplicaName]
+	expectFailedReplica.Spec.FailedAt = getTestNow()
+	expectFailedReplica.Spec.DesireState = longhorn.InstanceStateStopped
+	expectFailedReplica.Spec.LogRequested = true
 	for _, e := range tc.expectEngines {
 		delete(e.Spec.ReplicaAddressMap, failedReplicaName)
 		e.Spec.LogRequested = true
@@ -945,6 +946,28 @@ func (s *TestSuite) TestVolumeLifeCycle(c *C) {
 	}
 	testCases["restoring volume reattaching - stop replicas"] = tc
 
+	// volume reattaching, the workload is rescheduled to another node
+	tc = generateVolumeTestCaseTemplate()
+	tc.volume.Spec.NodeID = TestNode1
+	tc.volume.Status.CurrentNodeID = ""
+	tc.volume.Status.PendingNodeID = TestNode2
+	tc.volume.Status.State = longhorn.VolumeStateDetaching
+	for _, e := range tc.engines {
+		e.Spec.NodeID = ""
+		e.Status.CurrentState = longhorn.InstanceStateStopped
+	}
+	for _, r := range tc.replicas {
+		r.Spec.HealthyAt = getTestNow()
+		r.Status.CurrentState = longhorn.InstanceStateStopped
+	}
+	tc.copyCurrentToExpect()
+	tc.expectVolume.Status.State = longhorn.VolumeStateDetached
+	tc.expectVolume.Status.Robustness = longhorn.VolumeRobustnessUnknown
+	tc.expectVolume.Status.CurrentImage = tc.volume.Spec.EngineImage
+	tc.expectVolume.Status.CurrentNodeID = TestNode1
+	tc.expectVolume.Status.PendingNodeID = ""
+	testCases["volume reattaching - follow the rescheduled workload"] = tc
+
 	// replica rebuilding - reuse failed replica
 	tc = generateVolumeTestCaseTemplate()
 	tc.volume.Spec.NodeID = TestNode1
@@ -1041,6 +1064,165 @@ func (s *TestSuite) TestVolumeLifeCycle(c *C) {
 	tc.copyCurrentToExpect()
 	testCases["replica rebuilding - delay replica replenishment"] = tc
 
+	// volume scaled down - delete extra healthy replica
+	tc = generateVolumeTestCaseTemplate()
+	tc.volume.Spec.NodeID = TestNode1
+	tc.volume.Spec.NumberOfReplicas = 1
+	tc.volume.Status.CurrentImage = TestEngineImage
+	tc.volume.Status.CurrentNodeID = TestNode1
+	tc.volume.Status.State = longhorn.VolumeStateAttached
+	tc.volume.Status.Robustness = longhorn.VolumeRobustnessHealthy
+	for _, e := range tc.engines {
+		e.Spec.NodeID = TestNode1
+		e.Spec.DesireState = longhorn.InstanceStateRunning
+		e.Spec.EngineImage = TestEngineImage
+		e.Status.CurrentState = longhorn.InstanceStateRunning
+		e.Status.CurrentImage = TestEngineImage
+		e.Status.CurrentSize = TestVolumeSize
+		e.Status.ReplicaModeMap = map[string]longhorn.ReplicaMode{}
+	}
+	replicaNames := []string{}
+	for name, r := range tc.replicas {
+		r.Spec.DesireState = longhorn.InstanceStateRunning
+		r.Status.CurrentState = longhorn.InstanceStateRunning
+		r.Status.IP = randomIP()
+		r.Status.StorageIP = r.Status.IP
+		r.Status.Port = randomPort()
+		r.Spec.HealthyAt = getTestNow()
+		for _, e := range tc.engines {
+			e.Status.ReplicaModeMap[name] = longhorn.ReplicaModeRW
+			e.Spec.ReplicaAddressMap[name] = imutil.GetURL(r.Status.StorageIP, r.Status.Port)
+		}
+		replicaNames = append(replicaNames, name)
+	}
+	tc.copyCurrentToExpect()
+	sort.Strings(replicaNames)
+	delete(tc.expectReplicas, replicaNames[0])
+	testCases["volume scaled down - delete extra healthy replica"] = tc
+
+	// stale failed replica cleaned up after the timeout
+	tc = generateVolumeTestCaseTemplate()
+	tc.volume.Spec.NodeID = TestNode1
+	tc.volume.Spec.NumberOfReplicas = 1
+	tc.volume.Status.CurrentImage = TestEngineImage
+	tc.volume.Status.CurrentNodeID = TestNode1
+	tc.volume.Status.State = longhorn.VolumeStateAttached
+	tc.volume.Status.Robustness = longhorn.VolumeRobustnessHealthy
+	for _, e := range tc.engines {
+		e.Spec.NodeID = TestNode1
+		e.Spec.DesireState = longhorn.InstanceStateRunning
+		e.Spec.EngineImage = TestEngineImage
+		e.Status.CurrentState = longhorn.InstanceStateRunning
+		e.Status.CurrentImage = TestEngineImage
+		e.Status.CurrentSize = TestVolumeSize
+		e.Status.ReplicaModeMap = map[string]longhorn.ReplicaMode{}
+	}
+	replicaNames = []string{}
+	for name := range tc.replicas {
+		replicaNames = append(replicaNames, name)
+	}
+	sort.Strings(replicaNames)
+	failedReplica = tc.replicas[replicaNames[0]]
+	for name, r := range tc.replicas {
+		r.Spec.HealthyAt = getTestNow()
+		if r == failedReplica {
+			r.Spec.DesireState = longhorn.InstanceStateStopped
+			r.Status.CurrentState = longhorn.InstanceStateStopped
+			r.Spec.FailedAt = time.Now().Add(-time.Duration(TestVolumeStaleTimeout+1) * time.Minute).UTC().Format(time.RFC3339)
+			continue
+		}
+		r.Spec.DesireState = longhorn.InstanceStateRunning
+		r.Status.CurrentState = longhorn.InstanceStateRunning
+		r.Status.IP = randomIP()
+		r.Status.StorageIP = r.Status.IP
+		r.Status.Port = randomPort()
+		for _, e := range tc.engines {
+			e.Status.ReplicaModeMap[name] = longhorn.ReplicaModeRW
+			e.Spec.ReplicaAddressMap[name] = imutil.GetURL(r.Status.StorageIP, r.Status.Port)
+		}
+	}
+	tc.copyCurrentToExpect()
+	delete(tc.expectReplicas, failedReplica.Name)
+	testCases["stale replica cleanup - delete replica failed for longer than the timeout"] = tc
+
+	// replica rebuilding - defer non-urgent rebuild to off-peak hours
+	tc = generateVolumeTestCaseTemplate()
+	tc.volume.Spec.NodeID = TestNode1
+	tc.volume.Spec.NumberOfReplicas = 3
+	tc.volume.Status.CurrentImage = TestEngineImage
+	tc.volume.Status.CurrentNodeID = TestNode1
+	tc.volume.Status.State = longhorn.VolumeStateAttached
+	tc.volume.Status.Robustness = longhorn.VolumeRobustnessDegraded
+	tc.volume.Status.LastDegradedAt = getTestNow()
+	for _, e := range tc.engines {
+		e.Spec.NodeID = TestNode1
+		e.Spec.DesireState = longhorn.InstanceStateRunning
+		e.Spec.EngineImage = TestEngineImage
+		e.Status.CurrentState = longhorn.InstanceStateRunning
+		e.Status.CurrentImage = TestEngineImage
+		e.Status.CurrentSize = TestVolumeSize
+		e.Status.ReplicaModeMap = map[string]longhorn.ReplicaMode{}
+	}
+	for name, r := range tc.replicas {
+		r.Spec.DesireState = longhorn.InstanceStateRunning
+		r.Status.CurrentState = longhorn.InstanceStateRunning
+		r.Status.IP = randomIP()
+		r.Status.StorageIP = r.Status.IP
+		r.Status.Port = randomPort()
+		r.Spec.HealthyAt = getTestNow()
+		for _, e := range tc.engines {
+			e.Status.ReplicaModeMap[name] = longhorn.ReplicaModeRW
+			e.Spec.ReplicaAddressMap[name] = imutil.GetURL(r.Status.StorageIP, r.Status.Port)
+		}
+	}
+	// The off-peak hours are all day except the hour of TestTimeNow
+	tc.replicaRebuildOffPeakHours = "1-0"
+	tc.replicaNodeSoftAntiAffinity = "true"
+	tc.copyCurrentToExpect()
+	testCases["replica rebuilding - defer non-urgent rebuild to off-peak hours"] = tc
+
+	// replica rebuilding - rebuilding condition
+	tc = generateVolumeTestCaseTemplate()
+	tc.volume.Spec.NodeID = TestNode1
+	tc.volume.Status.CurrentImage = TestEngineImage
+	tc.volume.Status.CurrentNodeID = TestNode1
+	tc.volume.Status.State = longhorn.VolumeStateAttached
+	tc.volume.Status.Robustness = longhorn.VolumeRobustnessDegraded
+	tc.volume.Status.LastDegradedAt = getTestNow()
+	for _, e := range tc.engines {
+		e.Spec.NodeID = TestNode1
+		e.Spec.DesireState = longhorn.InstanceStateRunning
+		e.Spec.EngineImage = TestEngineImage
+		e.Status.CurrentState = longhorn.InstanceStateRunning
+		e.Status.CurrentImage = TestEngineImage
+		e.Status.CurrentSize = TestVolumeSize
+		e.Status.ReplicaModeMap = map[string]longhorn.ReplicaMode{}
+	}
+	rebuildingReplica := ""
+	for name, r := range tc.replicas {
+		r.Spec.DesireState = longhorn.InstanceStateRunning
+		r.Status.CurrentState = longhorn.InstanceStateRunning
+		r.Status.IP = randomIP()
+		r.Status.StorageIP = r.Status.IP
+		r.Status.Port = randomPort()
+		mode := longhorn.ReplicaModeRW
+		if r.Spec.NodeID == TestNode2 {
+			mode = longhorn.ReplicaModeWO
+			rebuildingReplica = name
+		} else {
+			r.Spec.HealthyAt = getTestNow()
+		}
+		for _, e := range tc.engines {
+			e.Status.ReplicaModeMap[name] = mode
+			e.Spec.ReplicaAddressMap[name] = imutil.GetURL(r.Status.StorageIP, r.Status.Port)
+		}
+	}
+	tc.copyCurrentToExpect()
+	tc.expectVolume.Status.Conditions = setVolumeConditionWithoutTimestamp(tc.expectVolume.Status.Conditions,
+		longhorn.VolumeConditionTypeRebuilding, longhorn.ConditionStatusTrue,
+		longhorn.VolumeConditionReasonReplicaRebuilding, fmt.Sprintf("Rebuilding replicas %v", rebuildingReplica))
+	testCases["replica rebuilding - rebuilding condition"] = tc
+
 	s.runTestCases(c, testCases)
 }
 
@@ -1406,6 +1588,15 @@ func (s *TestSuite) runTestCases(c *C, testCases map[string]*VolumeTestCase) {
 			c.Assert(err, IsNil)
 			sIndexer.Add(setting)
 		}
+		// Set Replica Rebuild Off-Peak Hours
+		if tc.replicaRebuildOffPeakHours != "" {
+			s := initSettingsNameValue(
+				string(types.SettingNameReplicaRebuildOffPeakHours), tc.replicaRebuildOffPeakHours)
+			setting, err :=
+				lhClient.LonghornV1beta2().Settings(TestNamespace).Create(context.TODO(), s, metav1.CreateOptions{})
+			c.Assert(err, IsNil)
+			sIndexer.Add(setting)
+		}
 		// Set Default Engine Image
 		s := initSettingsNameValue(
 			string(types.SettingNameDefaultEngineImage), TestEngineImage)
@@ -1498,6 +1689,14 @@ func (s *TestSuite) runTestCases(c *C, testCases map[string]*VolumeTestCase) {
 
 		retRs, err := lhClient.LonghornV1beta2().Replicas(TestNamespace).List(context.TODO(), metav1.ListOptions{LabelSelector: getVolumeLabelSelector(v.Name)})
 		c.Assert(err, IsNil)
+		if tc.replicas != nil {
+			c.Assert(retRs.Items, HasLen, len(tc.expectReplicas))
+			// The replicas created by the controller are not labeled with
+			// the volume yet
+			allRs, err := lhClient.LonghornV1beta2().Replicas(TestNamespace).List(context.TODO(), metav1.ListOptions{})
+			c.Assert(err, IsNil)
+			c.Assert(allRs.Items, HasLen, len(tc.expectReplicas))
+		}
 		for _, retR := range retRs.Items {
 			if tc.replicas == nil {
 				// test creation, name would be different
@@ -1527,3 +1726,445 @@ func (s *TestSuite) runTestCases(c *C, testCases map[string]*VolumeTestCase) {
 		}
 	}
 }
+
+func (s *TestSuite) TestEnqueueVolumesPendingReplicaScheduling(c *C) {
+	kubeClient := fake.NewSimpleClientset()
+	kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+	lhClient := lhfake.NewSimpleClientset()
+	lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+	extensionsClient := apiextensionsfake.NewSimpleClientset()
+	vIndexer := lhInformerFactory.Longhorn().V1beta2().Volumes().Informer().GetIndexer()
+
+	vc := newTestVolumeController(lhInformerFactory, kubeInformerFactory, lhClient, kubeClient, extensionsClient, TestOwnerID1)
+	defer vc.queue.ShutDown()
+
+	healthy := newVolume("healthy", 2)
+	healthy.Namespace = TestNamespace
+	healthy.Status.Robustness = longhorn.VolumeRobustnessHealthy
+	degraded := newVolume("degraded", 2)
+	degraded.Namespace = TestNamespace
+	degraded.Status.Robustness = longhorn.VolumeRobustnessDegraded
+	unscheduled := newVolume("unscheduled", 2)
+	unscheduled.Namespace = TestNamespace
+	unscheduled.Status.Robustness = longhorn.VolumeRobustnessUnknown
+	unscheduled.Status.Conditions = setVolumeConditionWithoutTimestamp(unscheduled.Status.Conditions,
+		longhorn.VolumeConditionTypeScheduled, longhorn.ConditionStatusFalse, longhorn.VolumeConditionReasonReplicaSchedulingFailure, "")
+	for _, v := range []*longhorn.Volume{healthy, degraded, unscheduled} {
+		c.Assert(vIndexer.Add(v), IsNil)
+	}
+
+	vc.enqueueVolumesPendingReplicaScheduling()
+	c.Assert(vc.queue.Len(), Equals, 2)
+	keys := []string{}
+	for vc.queue.Len() > 0 {
+		key, _ := vc.queue.Get()
+		keys = append(keys, key.(string))
+		vc.queue.Done(key)
+	}
+	sort.Strings(keys)
+	c.Assert(keys, DeepEquals, []string{TestNamespace + "/degraded", TestNamespace + "/unscheduled"})
+
+	// Only the changes of the settings read by the scheduling requeue them
+	c.Assert(isSettingRelatedToReplicaScheduling(initSettingsNameValue(string(types.SettingNameStorageOverProvisioningPercentage), "100")), Equals, true)
+	c.Assert(isSettingRelatedToReplicaScheduling(initSettingsNameValue(string(types.SettingNameConcurrentReplicaRebuildPerNodeLimit), "0")), Equals, true)
+	c.Assert(isSettingRelatedToReplicaScheduling(initSettingsNameValue(string(types.SettingNameBackupTarget), "")), Equals, false)
+	c.Assert(isSettingRelatedToReplicaScheduling(healthy), Equals, false)
+}
+
+func (s *TestSuite) TestProcessMigrationState(c *C) {
+	kubeClient := fake.NewSimpleClientset()
+	kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+	lhClient := lhfake.NewSimpleClientset()
+	lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+	extensionsClient := apiextensionsfake.NewSimpleClientset()
+
+	vc := newTestVolumeController(lhInformerFactory, kubeInformerFactory, lhClient, kubeClient, extensionsClient, TestOwnerID1)
+	defer vc.queue.ShutDown()
+
+	v := newVolume(TestVolumeName, 2)
+	v.Namespace = TestNamespace
+	v.Spec.Migratable = true
+	v.Spec.AccessMode = longhorn.AccessModeReadWriteMany
+	v.Spec.NodeID = TestNode1
+	v.Spec.MigrationNodeID = TestNode2
+
+	// The migration is preparing from the start
+	c.Assert(vc.processMigration(v, map[string]*longhorn.Engine{}, map[string]*longhorn.Replica{}), IsNil)
+	c.Assert(v.Status.MigrationState, Equals, longhorn.VolumeMigrationStatePreparing)
+	c.Assert(v.Status.MigrationStartedAt, Equals, getTestNow())
+
+	// The start time is kept until the migration ends
+	v.Status.MigrationStartedAt = "2006-01-02T15:04:05Z"
+	c.Assert(vc.processMigration(v, map[string]*longhorn.Engine{}, map[string]*longhorn.Replica{}), IsNil)
+	c.Assert(v.Status.MigrationState, Equals, longhorn.VolumeMigrationStatePreparing)
+	c.Assert(v.Status.MigrationStartedAt, Equals, "2006-01-02T15:04:05Z")
+
+	// The ready migration is prepared again once the current engine crashes
+	v.Status.MigrationState = longhorn.VolumeMigrationStateReady
+	v.Status.CurrentNodeID = TestNode1
+	v.Status.CurrentImage = v.Spec.EngineImage
+	v.Status.Robustness = longhorn.VolumeRobustnessHealthy
+	current := newEngineForVolume(v)
+	current.Spec.NodeID = TestNode1
+	current.Status.CurrentState = longhorn.InstanceStateError
+	migration := newEngineForVolume(v)
+	migration.Spec.NodeID = TestNode2
+	migration.Spec.Active = false
+	es := map[string]*longhorn.Engine{}
+	for _, e := range []*longhorn.Engine{current, migration} {
+		e, err := lhClient.LonghornV1beta2().Engines(TestNamespace).Create(context.TODO(), e, metav1.CreateOptions{})
+		c.Assert(err, IsNil)
+		es[e.Name] = e
+	}
+	c.Assert(vc.processMigration(v, es, map[string]*longhorn.Replica{}), IsNil)
+	c.Assert(v.Status.MigrationState, Equals, longhorn.VolumeMigrationStatePreparing)
+	c.Assert(es, HasLen, 1)
+	c.Assert(es[current.Name], NotNil)
+
+	// The state is cleared once the migration is rolled back
+	v.Spec.MigrationNodeID = ""
+	c.Assert(vc.processMigration(v, es, map[string]*longhorn.Replica{}), IsNil)
+	c.Assert(v.Status.MigrationState, Equals, longhorn.VolumeMigrationStateEmpty)
+	c.Assert(v.Status.MigrationStartedAt, Equals, "")
+}
+
+func (s *TestSuite) TestSyncFilesystemCheck(c *C) {
+	kubeClient := fake.NewSimpleClientset()
+	kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+	lhClient := lhfake.NewSimpleClientset()
+	lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+	extensionsClient := apiextensionsfake.NewSimpleClientset()
+	sIndexer := lhInformerFactory.Longhorn().V1beta2().Settings().Informer().GetIndexer()
+
+	vc := newTestVolumeController(lhInformerFactory, kubeInformerFactory, lhClient, kubeClient, extensionsClient, TestNode1)
+	defer vc.queue.ShutDown()
+
+	setting := initSettingsNameValue(string(types.SettingNameFilesystemCheckAfterAttach), "true")
+	setting.Namespace = TestNamespace
+	c.Assert(sIndexer.Add(setting), IsNil)
+
+	checked := make(chan bool, 1)
+	vc.filesystemChecks.check = func(device, fsType string, repair bool) (*util.FilesystemCheckResult, error) {
+		checked <- repair
+		return &util.FilesystemCheckResult{Clean: true}, nil
+	}
+
+	newAttachedVolume := func() *longhorn.Volume {
+		v := newVolume(TestVolumeName, 2)
+		v.Namespace = TestNamespace
+		v.Spec.FilesystemType = util.FilesystemTypeExt4
+		v.Spec.NodeID = TestNode1
+		v.Spec.AttachmentTicket = longhorn.AttachmentTicket{NodeID: TestNode1, RequestedAt: "2026-01-01T00:00:00Z"}
+		v.Status.State = longhorn.VolumeStateAttached
+		v.Status.CurrentNodeID = TestNode1
+		return v
+	}
+
+	// The volume used by a workload is staged meanwhile, so it's not checked
+	v := newAttachedVolume()
+	v.Status.KubernetesStatus.WorkloadsStatus = []longhorn.WorkloadStatus{{PodName: "pod-1"}}
+	finished, err := vc.syncFilesystemCheck(v)
+	c.Assert(err, IsNil)
+	c.Assert(finished, Equals, false)
+	c.Assert(vc.filesystemChecks.isRunning(TestVolumeName), Equals, false)
+	c.Assert(checked, HasLen, 0)
+
+	// Otherwise it's checked once attached
+	v = newAttachedVolume()
+	_, err = vc.syncFilesystemCheck(v)
+	c.Assert(err, IsNil)
+	c.Assert(<-checked, Equals, false)
+	c.Assert(wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return vc.filesystemChecks.getResult(TestVolumeName) != nil, nil
+	}), IsNil)
+
+	// The result is recorded, but kept until the status update went through
+	_, err = vc.syncFilesystemCheck(v)
+	c.Assert(err, IsNil)
+	result := vc.filesystemChecks.getResult(TestVolumeName)
+	c.Assert(result, NotNil)
+	c.Assert(v.Status.LastFilesystemCheckAt, Equals, result.checkedAt)
+
+	// A failed update leaves the stale volume, so the result is recorded again
+	v = newAttachedVolume()
+	_, err = vc.syncFilesystemCheck(v)
+	c.Assert(err, IsNil)
+	c.Assert(v.Status.LastFilesystemCheckAt, Equals, result.checkedAt)
+	c.Assert(vc.filesystemChecks.getResult(TestVolumeName), Equals, result)
+
+	// The result is dropped once recorded, and the volume isn't checked again
+	_, err = vc.syncFilesystemCheck(v)
+	c.Assert(err, IsNil)
+	c.Assert(vc.filesystemChecks.getResult(TestVolumeName), IsNil)
+	c.Assert(vc.filesystemChecks.isRunning(TestVolumeName), Equals, false)
+	c.Assert(checked, HasLen, 0)
+}
+
+func (s *TestSuite) TestSyncFilesystemFormat(c *C) {
+	datastore.SkipListerCheck = true
+
+	type formatResult struct {
+		format    string
+		formatted bool
+		err       error
+	}
+	testCases := map[string]struct {
+		results []formatResult
+
+		expectedFilesystem     string
+		expectedFilesystemType string
+		expectedFailed         bool
+	}{
+		"formatted": {
+			results:                []formatResult{{format: util.FilesystemTypeExt4, formatted: true}},
+			expectedFilesystem:     util.FilesystemTypeExt4,
+			expectedFilesystemType: util.FilesystemTypeExt4,
+		},
+		"already has a supported filesystem": {
+			results:                []formatResult{{format: util.FilesystemTypeXFS}},
+			expectedFilesystem:     util.FilesystemTypeExt4,
+			expectedFilesystemType: util.FilesystemTypeXFS,
+		},
+		"already has another format": {
+			results: []formatResult{{format: "gpt"}},
+		},
+		"formatted after a failure": {
+			results: []formatResult{
+				{err: fmt.Errorf("device busy")},
+				{format: util.FilesystemTypeExt4, formatted: true},
+			},
+			expectedFilesystem:     util.FilesystemTypeExt4,
+			expectedFilesystemType: util.FilesystemTypeExt4,
+		},
+		"failed for good": {
+			results: []formatResult{
+				{err: fmt.Errorf("mkfs.ext4 not found")},
+				{err: fmt.Errorf("mkfs.ext4 not found")},
+				{err: fmt.Errorf("mkfs.ext4 not found")},
+			},
+			expectedFilesystem: util.FilesystemTypeExt4,
+			expectedFailed:     true,
+		},
+	}
+
+	for name, tc := range testCases {
+		fmt.Printf("testing %v\n", name)
+
+		kubeClient := fake.NewSimpleClientset()
+		kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+		lhClient := lhfake.NewSimpleClientset()
+		lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+		extensionsClient := apiextensionsfake.NewSimpleClientset()
+		vc := newTestVolumeController(lhInformerFactory, kubeInformerFactory, lhClient, kubeClient, extensionsClient, TestNode1)
+
+		formats := 0
+		vc.filesystemFormats.format = func(device, fsType string) (string, bool, error) {
+			r := tc.results[formats]
+			formats++
+			return r.format, r.formatted, r.err
+		}
+
+		v := newVolume(TestVolumeName, 2)
+		v.Spec.Filesystem = util.FilesystemTypeExt4
+		v.Spec.NodeID = TestNode1
+		v.Status.State = longhorn.VolumeStateAttached
+		v.Status.CurrentNodeID = TestNode1
+		v, err := lhClient.LonghornV1beta2().Volumes(TestNamespace).Create(context.TODO(), v, metav1.CreateOptions{})
+		c.Assert(err, IsNil)
+		vIndexer := lhInformerFactory.Longhorn().V1beta2().Volumes().Informer().GetIndexer()
+		c.Assert(vIndexer.Add(v), IsNil)
+
+		// The format runs in the background, and the result is picked up by
+		// the next sync
+		finished := false
+		for i := range tc.results {
+			finished, err = vc.syncFilesystemFormat(v)
+			c.Assert(err, IsNil)
+			c.Assert(finished, Equals, false)
+			c.Assert(wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+				return !vc.filesystemFormats.isRunning(TestVolumeName), nil
+			}), IsNil)
+			c.Assert(formats, Equals, i+1)
+
+			finished, err = vc.syncFilesystemFormat(v)
+			c.Assert(err, IsNil)
+		}
+		c.Assert(finished, Equals, !tc.expectedFailed)
+		c.Assert(isFilesystemFormatFailed(v), Equals, tc.expectedFailed)
+
+		if tc.expectedFailed {
+			// The failure is kept, and the format isn't retried until the
+			// volume is detached
+			finished, err = vc.syncFilesystemFormat(v)
+			c.Assert(err, IsNil)
+			c.Assert(finished, Equals, false)
+			c.Assert(vc.filesystemFormats.isRunning(TestVolumeName), Equals, false)
+			c.Assert(formats, Equals, len(tc.results))
+
+			v.Status.State = longhorn.VolumeStateDetached
+			_, err = vc.syncFilesystemFormat(v)
+			c.Assert(err, IsNil)
+			c.Assert(isFilesystemFormatFailed(v), Equals, false)
+		}
+
+		v, err = lhClient.LonghornV1beta2().Volumes(TestNamespace).Get(context.TODO(), TestVolumeName, metav1.GetOptions{})
+		c.Assert(err, IsNil)
+		c.Assert(v.Spec.Filesystem, Equals, tc.expectedFilesystem)
+		c.Assert(v.Spec.FilesystemType, Equals, tc.expectedFilesystemType)
+	}
+}
+
+func (s *TestSuite) TestCheckOwnerEviction(c *C) {
+	datastore.SkipListerCheck = true
+
+	testCases := map[string]struct {
+		controllerID     string
+		attached         bool
+		ownerEvicting    bool
+		othersEvicting   bool
+		expectHandedOver bool
+		expectTakeOver   bool
+	}{
+		"owner not evicted": {
+			controllerID: TestNode2,
+		},
+		"owner evicted": {
+			controllerID:     TestNode2,
+			ownerEvicting:    true,
+			expectHandedOver: true,
+			expectTakeOver:   true,
+		},
+		"owner evicted on the owner": {
+			controllerID:     TestNode1,
+			ownerEvicting:    true,
+			expectHandedOver: true,
+		},
+		"attached volume": {
+			controllerID:  TestNode2,
+			attached:      true,
+			ownerEvicting: true,
+		},
+		"no other node": {
+			controllerID:   TestNode2,
+			ownerEvicting:  true,
+			othersEvicting: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		fmt.Printf("testing %v\n", name)
+
+		kubeClient := fake.NewSimpleClientset()
+		kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+		lhClient := lhfake.NewSimpleClientset()
+		lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+		extensionsClient := apiextensionsfake.NewSimpleClientset()
+		vc := newTestVolumeController(lhInformerFactory, kubeInformerFactory, lhClient, kubeClient, extensionsClient, tc.controllerID)
+
+		ei := newEngineImage(TestEngineImage, longhorn.EngineImageStateDeployed)
+		ei.Status.NodeDeploymentMap = map[string]bool{TestNode1: true, TestNode2: true}
+		c.Assert(lhInformerFactory.Longhorn().V1beta2().EngineImages().Informer().GetIndexer().Add(ei), IsNil)
+		nIndexer := lhInformerFactory.Longhorn().V1beta2().Nodes().Informer().GetIndexer()
+		node1 := newNode(TestNode1, TestNamespace, false, longhorn.ConditionStatusTrue, "")
+		node1.Spec.EvictionRequested = tc.ownerEvicting
+		node2 := newNode(TestNode2, TestNamespace, true, longhorn.ConditionStatusTrue, "")
+		node2.Spec.EvictionRequested = tc.othersEvicting
+		c.Assert(nIndexer.Add(node1), IsNil)
+		c.Assert(nIndexer.Add(node2), IsNil)
+
+		v := newVolume(TestVolumeName, 2)
+		v.Status.OwnerID = TestNode1
+		v.Status.State = longhorn.VolumeStateDetached
+		if tc.attached {
+			v.Spec.NodeID = TestNode1
+			v.Status.State = longhorn.VolumeStateAttached
+		}
+
+		handedOver, takeOver, err := vc.checkOwnerEviction(v)
+		c.Assert(err, IsNil)
+		c.Assert(handedOver, Equals, tc.expectHandedOver)
+		c.Assert(takeOver, Equals, tc.expectTakeOver)
+	}
+}
+
+func (s *TestSuite) TestCleanupStaleReplica(c *C) {
+	datastore.SkipListerCheck = true
+
+	staleFailedAt := time.Now().Add(-time.Duration(TestVolumeStaleTimeout+1) * time.Minute).UTC().Format(time.RFC3339)
+	testCases := map[string]struct {
+		numberOfReplicas int
+		healthyReplicas  int
+		failedAts        []string
+
+		expectedDeleted []bool
+	}{
+		"enough healthy replicas": {
+			numberOfReplicas: 2,
+			healthyReplicas:  2,
+			failedAts:        []string{staleFailedAt},
+			expectedDeleted:  []bool{true},
+		},
+		"not enough healthy replicas": {
+			numberOfReplicas: 3,
+			healthyReplicas:  2,
+			failedAts:        []string{staleFailedAt},
+			expectedDeleted:  []bool{false},
+		},
+		"not stale yet": {
+			numberOfReplicas: 2,
+			healthyReplicas:  2,
+			failedAts:        []string{time.Now().UTC().Format(time.RFC3339)},
+			expectedDeleted:  []bool{false},
+		},
+		"invalid failed time": {
+			numberOfReplicas: 2,
+			healthyReplicas:  2,
+			failedAts:        []string{"invalid", staleFailedAt},
+			expectedDeleted:  []bool{false, true},
+		},
+	}
+
+	for name, tc := range testCases {
+		fmt.Printf("testing %v\n", name)
+
+		kubeClient := fake.NewSimpleClientset()
+		kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+		lhClient := lhfake.NewSimpleClientset()
+		lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+		extensionsClient := apiextensionsfake.NewSimpleClientset()
+		vc := newTestVolumeController(lhInformerFactory, kubeInformerFactory, lhClient, kubeClient, extensionsClient, TestNode1)
+		rIndexer := lhInformerFactory.Longhorn().V1beta2().Replicas().Informer().GetIndexer()
+
+		v := newVolume(TestVolumeName, tc.numberOfReplicas)
+		v.Status.CurrentImage = TestEngineImage
+		e := newEngineForVolume(v)
+
+		rs := map[string]*longhorn.Replica{}
+		addReplica := func(failedAt string) *longhorn.Replica {
+			r := newReplicaForVolume(v, e, TestNode1, TestDiskID1)
+			r.Namespace = TestNamespace
+			r.Spec.HealthyAt = getTestNow()
+			r.Spec.FailedAt = failedAt
+			r, err := lhClient.LonghornV1beta2().Replicas(TestNamespace).Create(context.TODO(), r, metav1.CreateOptions{})
+			c.Assert(err, IsNil)
+			c.Assert(rIndexer.Add(r), IsNil)
+			rs[r.Name] = r
+			return r
+		}
+		for i := 0; i < tc.healthyReplicas; i++ {
+			addReplica("")
+		}
+		failedReplicas := []*longhorn.Replica{}
+		for _, failedAt := range tc.failedAts {
+			failedReplicas = append(failedReplicas, addReplica(failedAt))
+		}
+
+		c.Assert(vc.cleanupCorruptedOrStaleReplicas(v, rs), IsNil)
+		for i, r := range failedReplicas {
+			_, err := lhClient.LonghornV1beta2().Replicas(TestNamespace).Get(context.TODO(), r.Name, metav1.GetOptions{})
+			c.Assert(datastore.ErrorIsNotFound(err), Equals, tc.expectedDeleted[i], Commentf("replica failed at %v", r.Spec.FailedAt))
+			_, exists := rs[r.Name]
+			c.Assert(exists, Equals, !tc.expectedDeleted[i])
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/longhorn/longhorn-manager/constant"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+const (
+	maxFilesystemFormatAttempts   = 3
+	filesystemFormatRetryInterval = time.Minute
+)
+
+type filesystemCheckResult struct {
+	repair    bool
+	checkedAt string
+	result    *util.FilesystemCheckResult
+	err       error
+}
+
+// filesystemChecks runs the filesystem checks of the volumes in the
+// background, since a check reads the whole filesystem metadata. The result
+// is picked up by the next sync of the volume, and kept until the volume
+// status records it.
+type filesystemChecks struct {
+	lock    sync.Mutex
+	running map[string]bool
+	results map[string]*filesystemCheckResult
+
+	// for unit test
+	check func(device, fsType string, repair bool) (*util.FilesystemCheckResult, error)
+}
+
+func newFilesystemChecks() *filesystemChecks {
+	return &filesystemChecks{
+		running: map[string]bool{},
+		results: map[string]*filesystemCheckResult{},
+		check:   util.CheckFilesystem,
+	}
+}
+
+func (fc *filesystemChecks) start(volumeName, device, fsType string, repair bool, done func()) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	if fc.running[volumeName] {
+		return
+	}
+	fc.running[volumeName] = true
+
+	go func() {
+		result, err := fc.check(device, fsType, repair)
+
+		fc.lock.Lock()
+		delete(fc.running, volumeName)
+		fc.results[volumeName] = &filesystemCheckResult{repair: repair, checkedAt: util.Now(), result: result, err: err}
+		fc.lock.Unlock()
+
+		done()
+	}()
+}
+
+func (fc *filesystemChecks) isRunning(volumeName string) bool {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	return fc.running[volumeName]
+}
+
+func (fc *filesystemChecks) getResult(volumeName string) *filesystemCheckResult {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	return fc.results[volumeName]
+}
+
+func (fc *filesystemChecks) deleteResult(volumeName string, result *filesystemCheckResult) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	if fc.results[volumeName] == result {
+		delete(fc.results, volumeName)
+	}
+}
+
+type filesystemFormatResult struct {
+	format    string
+	formatted bool
+	err       error
+	// the number of failed attempts in a row, including this one
+	attempts int
+}
+
+// filesystemFormats formats the volumes in the background, since mkfs may
+// take minutes on a large volume and would block a worker of the volume
+// controller meanwhile. Like filesystemChecks, the result is picked up by the
+// next sync of the volume.
+type filesystemFormats struct {
+	lock     sync.Mutex
+	running  map[string]bool
+	results  map[string]*filesystemFormatResult
+	failures map[string]int
+
+	// for unit test
+	format func(device, fsType string) (string, bool, error)
+}
+
+func newFilesystemFormats() *filesystemFormats {
+	return &filesystemFormats{
+		running:  map[string]bool{},
+		results:  map[string]*filesystemFormatResult{},
+		failures: map[string]int{},
+		format:   util.FormatDeviceIfBlank,
+	}
+}
+
+func (ff *filesystemFormats) start(volumeName, device, fsType string, done func()) {
+	ff.lock.Lock()
+	defer ff.lock.Unlock()
+
+	if ff.running[volumeName] {
+		return
+	}
+	ff.running[volumeName] = true
+
+	go func() {
+		format, formatted, err := ff.format(device, fsType)
+
+		ff.lock.Lock()
+		delete(ff.running, volumeName)
+		result := &filesystemFormatResult{format: format, formatted: formatted, err: err}
+		if err != nil {
+			ff.failures[volumeName]++
+			result.attempts = ff.failures[volumeName]
+			if result.attempts >= maxFilesystemFormatAttempts {
+				delete(ff.failures, volumeName)
+			}
+		} else {
+			delete(ff.failures, volumeName)
+		}
+		ff.results[volumeName] = result
+		ff.lock.Unlock()
+
+		done()
+	}()
+}
+
+func (ff *filesystemFormats) isRunning(volumeName string) bool {
+	ff.lock.Lock()
+	defer ff.lock.Unlock()
+	return ff.running[volumeName]
+}
+
+func (ff *filesystemFormats) takeResult(volumeName string) *filesystemFormatResult {
+	ff.lock.Lock()
+	defer ff.lock.Unlock()
+
+	result := ff.results[volumeName]
+	delete(ff.results, volumeName)
+	return result
+}
+
+// syncFilesystemCheck checks the filesystem of the volume once per
+// attachment, on the node the volume is attached to. After an attachment
+// requested for a full filesystem check, the errors are repaired and the
+// volume is detached again. After any other attachment, the check is
+// read-only and only runs if the setting is enabled and no workload uses the
+// volume, since the workload stages and mounts the volume meanwhile.
+func (vc *VolumeController) syncFilesystemCheck(v *longhorn.Volume) (finished bool, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to sync the filesystem check")
+	}()
+
+	// The result is only dropped once the status update recording it went
+	// through, otherwise the check would run again
+	if result := vc.filesystemChecks.getResult(v.Name); result != nil {
+		if v.Status.LastFilesystemCheckAt != result.checkedAt {
+			vc.recordFilesystemCheckResult(v, result)
+			return false, nil
+		}
+		vc.filesystemChecks.deleteResult(v.Name, result)
+	}
+
+	if v.Status.CurrentNodeID != vc.controllerID {
+		return false, nil
+	}
+
+	ticket := v.Spec.AttachmentTicket
+	if v.Status.State != longhorn.VolumeStateAttached || ticket.NodeID != vc.controllerID || ticket.RequestedAt == "" {
+		return false, nil
+	}
+	repair := ticket.AttachedBy == types.FilesystemCheckAttachedBy
+
+	checked, err := isCheckedSince(v.Status.LastFilesystemCheckAt, ticket.RequestedAt)
+	if err != nil {
+		return false, err
+	}
+	if checked {
+		if !repair {
+			return false, nil
+		}
+		getLoggerForVolume(vc.logger, v).Info("Detaching volume after the full filesystem check")
+		v.Spec.NodeID = ""
+		v.Spec.AttachmentTicket = longhorn.AttachmentTicket{}
+		if _, err := vc.ds.UpdateVolume(v); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if vc.filesystemChecks.isRunning(v.Name) || v.Spec.FilesystemType == "" || v.Spec.Encrypted || v.Status.FrontendDisabled {
+		return false, nil
+	}
+	if !repair {
+		if isVolumeUsedByWorkload(v) {
+			return false, nil
+		}
+		enabled, err := vc.ds.GetSettingAsBool(types.SettingNameFilesystemCheckAfterAttach)
+		if err != nil || !enabled {
+			return false, err
+		}
+	}
+
+	getLoggerForVolume(vc.logger, v).Infof("Checking %v filesystem, repair %v", v.Spec.FilesystemType, repair)
+	vc.filesystemChecks.start(v.Name, util.RegularDeviceDirectory+v.Name, v.Spec.FilesystemType, repair, func() {
+		vc.enqueueVolume(v)
+	})
+	return false, nil
+}
+
+// syncFilesystemFormat formats the volume requested with a filesystem once
+// it's attached the first time, and records the filesystem type in the spec.
+// A volume with existing data, e.g. restored from a backup, is never
+// formatted. Its filesystem type is recorded instead if it's supported, or
+// the request is dropped. If the format keeps failing, the failure is
+// recorded in the filesystem error condition and not retried until the
+// volume is detached, so the CSI driver fails the staging instead of waiting.
+func (vc *VolumeController) syncFilesystemFormat(v *longhorn.Volume) (finished bool, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to sync the filesystem format")
+	}()
+
+	formatFailed := isFilesystemFormatFailed(v)
+	if formatFailed && v.Status.State == longhorn.VolumeStateDetached {
+		v.Status.Conditions = types.SetCondition(v.Status.Conditions,
+			longhorn.VolumeConditionTypeFilesystemError, longhorn.ConditionStatusFalse, "", "")
+		formatFailed = false
+	}
+
+	if v.Spec.Filesystem == "" || v.Spec.FilesystemType != "" || v.Spec.Encrypted || v.Spec.ReadOnly || formatFailed ||
+		v.Status.CurrentNodeID != vc.controllerID || v.Status.State != longhorn.VolumeStateAttached ||
+		v.Status.FrontendDisabled || v.Status.RestoreRequired || v.Status.IsStandby {
+		return false, nil
+	}
+
+	if result := vc.filesystemFormats.takeResult(v.Name); result != nil {
+		return vc.recordFilesystemFormatResult(v, result)
+	}
+	if vc.filesystemFormats.isRunning(v.Name) {
+		return false, nil
+	}
+
+	getLoggerForVolume(vc.logger, v).Infof("Formatting volume with %v filesystem if it's blank", v.Spec.Filesystem)
+	vc.filesystemFormats.start(v.Name, util.RegularDeviceDirectory+v.Name, v.Spec.Filesystem, func() {
+		vc.enqueueVolume(v)
+	})
+	return false, nil
+}
+
+func (vc *VolumeController) recordFilesystemFormatResult(v *longhorn.Volume, r *filesystemFormatResult) (finished bool, err error) {
+	log := getLoggerForVolume(vc.logger, v)
+
+	if r.err != nil {
+		log.WithError(r.err).Warnf("Failed to format the volume, attempt %v of %v", r.attempts, maxFilesystemFormatAttempts)
+		vc.eventRecorder.Eventf(v, v1.EventTypeWarning, constant.EventReasonFailedFilesystemFormat,
+			"failed to format volume %v with %v filesystem: %v", v.Name, v.Spec.Filesystem, r.err)
+		if r.attempts < maxFilesystemFormatAttempts {
+			vc.enqueueVolumeAfter(v, filesystemFormatRetryInterval)
+			return false, nil
+		}
+		v.Status.Conditions = types.SetCondition(v.Status.Conditions,
+			longhorn.VolumeConditionTypeFilesystemError, longhorn.ConditionStatusTrue,
+			longhorn.VolumeConditionReasonFilesystemFormatFailure,
+			fmt.Sprintf("Failed to format with %v filesystem, detach the volume to retry: %v", v.Spec.Filesystem, r.err))
+		return false, nil
+	}
+
+	switch {
+	case r.formatted:
+		vc.eventRecorder.Eventf(v, v1.EventTypeNormal, constant.EventReasonFilesystemFormatted,
+			"volume %v is formatted with %v filesystem", v.Name, r.format)
+		v.Spec.FilesystemType = r.format
+	case r.format == util.FilesystemTypeExt4 || r.format == util.FilesystemTypeXFS:
+		log.Infof("Volume already has %v filesystem", r.format)
+		v.Spec.FilesystemType = r.format
+	default:
+		vc.eventRecorder.Eventf(v, v1.EventTypeWarning, constant.EventReasonFailedFilesystemFormat,
+			"volume %v is not formatted with %v filesystem since it already has %v format", v.Name, v.Spec.Filesystem, r.format)
+		v.Spec.Filesystem = ""
+	}
+	// The format is idempotent, so it's fine to run it again if the update fails
+	if _, err := vc.ds.UpdateVolume(v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isFilesystemFormatFailed tells if the format of the volume failed for good
+// in the current attachment.
+func isFilesystemFormatFailed(v *longhorn.Volume) bool {
+	condition := types.GetCondition(v.Status.Conditions, longhorn.VolumeConditionTypeFilesystemError)
+	return condition.Status == longhorn.ConditionStatusTrue && condition.Reason == longhorn.VolumeConditionReasonFilesystemFormatFailure
+}
+
+// isVolumeUsedByWorkload tells if a pod refers to the volume, so the volume
+// is staged by the CSI driver once it's attached.
+func isVolumeUsedByWorkload(v *longhorn.Volume) bool {
+	ks := v.Status.KubernetesStatus
+	return len(ks.WorkloadsStatus) != 0 && ks.LastPodRefAt == ""
+}
+
+func isCheckedSince(checkedAt, since string) (bool, error) {
+	if checkedAt == "" {
+		return false, nil
+	}
+	checkedTime, err := util.ParseTime(checkedAt)
+	if err != nil {
+		return false, err
+	}
+	sinceTime, err := util.ParseTime(since)
+	if err != nil {
+		return false, err
+	}
+	return !checkedTime.Before(sinceTime), nil
+}
+
+func (vc *VolumeController) recordFilesystemCheckResult(v *longhorn.Volume, r *filesystemCheckResult) {
+	log := getLoggerForVolume(vc.logger, v)
+
+	v.Status.LastFilesystemCheckAt = r.checkedAt
+	if r.err != nil {
+		log.WithError(r.err).Warn("Failed to check the filesystem")
+		vc.eventRecorder.Eventf(v, v1.EventTypeWarning, constant.EventReasonFailedFilesystemCheck,
+			"failed to check the filesystem of volume %v: %v", v.Name, r.err)
+		return
+	}
+
+	if !r.result.Clean {
+		message := fmt.Sprintf("Filesystem check found errors: %v", r.result.Output)
+		if r.repair {
+			message = fmt.Sprintf("Filesystem repair left errors: %v", r.result.Output)
+		}
+		v.Status.Conditions = types.SetCondition(v.Status.Conditions,
+			longhorn.VolumeConditionTypeFilesystemError, longhorn.ConditionStatusTrue,
+			longhorn.VolumeConditionReasonFilesystemErrorsFound, message)
+		vc.eventRecorder.Eventf(v, v1.EventTypeWarning, constant.EventReasonFailedFilesystemCheck,
+			"filesystem of volume %v has errors", v.Name)
+		return
+	}
+
+	if types.GetCondition(v.Status.Conditions, longhorn.VolumeConditionTypeFilesystemError).Status == longhorn.ConditionStatusTrue {
+		v.Status.Conditions = types.SetCondition(v.Status.Conditions,
+			longhorn.VolumeConditionTypeFilesystemError, longhorn.ConditionStatusFalse, "", "")
+	}
+	message := fmt.Sprintf("filesystem of volume %v is clean", v.Name)
+	if r.repair {
+		message = fmt.Sprintf("filesystem of volume %v is clean after the full check", v.Name)
+	}
+	vc.eventRecorder.Event(v, v1.EventTypeNormal, constant.EventReasonFilesystemChecked, message)
+}
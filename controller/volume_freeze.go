@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/longhorn/longhorn-manager/constant"
+	"github.com/longhorn/longhorn-manager/util"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+// syncFrozenFilesystem thaws the filesystem frozen by request once the
+// freeze timeout is reached or the volume is requested to detach, so a caller
+// never thawing it doesn't block the workload forever. The filesystem can only
+// be reached from the node the volume is attached to.
+func (vc *VolumeController) syncFrozenFilesystem(v *longhorn.Volume) {
+	if v.Status.FrozenUntil == "" {
+		return
+	}
+	log := getLoggerForVolume(vc.logger, v)
+
+	if v.Status.CurrentNodeID == "" {
+		v.Status.FrozenUntil = ""
+		return
+	}
+	if v.Status.CurrentNodeID != vc.controllerID {
+		return
+	}
+
+	if v.Spec.NodeID != "" {
+		frozenUntil, err := util.ParseTime(v.Status.FrozenUntil)
+		if err != nil {
+			log.WithError(err).Warnf("Invalid freeze deadline %v, thaw the filesystem now", v.Status.FrozenUntil)
+		} else if remaining := time.Until(frozenUntil); remaining > 0 {
+			vc.enqueueVolumeAfter(v, remaining)
+			return
+		}
+	}
+
+	if err := util.UnfreezeFilesystem(v.Name, v.Spec.Encrypted); err != nil {
+		log.WithError(err).Warn("Failed to thaw the filesystem automatically")
+		vc.enqueueVolumeAfter(v, time.Minute)
+		return
+	}
+	v.Status.FrozenUntil = ""
+	vc.eventRecorder.Eventf(v, v1.EventTypeWarning, constant.EventReasonAutoThawed, "filesystem of volume %v is thawed automatically", v.Name)
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"time"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+// isRebuildDeferredToOffPeakHours checks if the rebuilding of the volume
+// should wait for the off-peak hours. It's urgent to rebuild a volume with
+// only one healthy replica left, and the eviction is requested by the user,
+// so they're never deferred. The deferred volume is checked again when the
+// off-peak hours start.
+func (vc *VolumeController) isRebuildDeferredToOffPeakHours(v *longhorn.Volume, rs map[string]*longhorn.Replica) (bool, error) {
+	setting, err := vc.ds.GetSetting(types.SettingNameReplicaRebuildOffPeakHours)
+	if err != nil {
+		return false, err
+	}
+	offPeakHours, err := types.UnmarshalRebuildOffPeakHours(setting.Value)
+	if err != nil {
+		return false, err
+	}
+	if offPeakHours == nil || getHealthyAndActiveReplicaCount(rs) <= 1 || vc.hasReplicaEvictionRequested(rs) {
+		return false, nil
+	}
+
+	now, err := time.Parse(time.RFC3339, vc.nowHandler())
+	if err != nil {
+		return false, err
+	}
+	if offPeakHours.Contains(now) {
+		return false, nil
+	}
+	getLoggerForVolume(vc.logger, v).Debugf("Deferring replica rebuilding to the off-peak hours %v-%v", offPeakHours.Start, offPeakHours.End)
+	vc.enqueueVolumeAfter(v, offPeakHours.Until(now))
+	return true, nil
+}
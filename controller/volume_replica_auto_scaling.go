@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+// getEffectiveReplicaCount returns the replica count to keep for the volume.
+// With the replica count auto scaling, it's temporarily reduced to the
+// replicas the available nodes can hold, so the volume isn't rebuilding or
+// scheduling forever. The extra replicas are not cleaned up with the reduced
+// count, and the count goes back once the nodes return.
+func (vc *VolumeController) getEffectiveReplicaCount(v *longhorn.Volume) int {
+	log := getLoggerForVolume(vc.logger, v)
+
+	autoScaling, err := vc.ds.GetSettingAsBool(types.SettingNameReplicaCountAutoScaling)
+	if err != nil {
+		log.WithError(err).Warnf("Failed to get %v setting", types.SettingNameReplicaCountAutoScaling)
+		return v.Spec.NumberOfReplicas
+	}
+	if !autoScaling || isTargetVolumeOfCloning(v) {
+		return v.Spec.NumberOfReplicas
+	}
+	maxCount, err := vc.scheduler.GetMaxReplicaCount(v)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get the max replica count for replica count auto scaling")
+		return v.Spec.NumberOfReplicas
+	}
+	// There is nothing to scale down to without any available node
+	if maxCount <= 0 || maxCount >= v.Spec.NumberOfReplicas {
+		return v.Spec.NumberOfReplicas
+	}
+	return maxCount
+}
+
+func (vc *VolumeController) syncReducedRedundancyCondition(v *longhorn.Volume) {
+	count := vc.getEffectiveReplicaCount(v)
+	if count < v.Spec.NumberOfReplicas {
+		v.Status.Conditions = types.SetCondition(v.Status.Conditions,
+			longhorn.VolumeConditionTypeReducedRedundancy, longhorn.ConditionStatusTrue,
+			longhorn.VolumeConditionReasonInsufficientNodes,
+			fmt.Sprintf("Replica count is reduced to %v from %v since there are not enough available nodes", count, v.Spec.NumberOfReplicas))
+		return
+	}
+	// Only clear the condition set before
+	if types.GetCondition(v.Status.Conditions, longhorn.VolumeConditionTypeReducedRedundancy).Status == longhorn.ConditionStatusTrue {
+		v.Status.Conditions = types.SetCondition(v.Status.Conditions,
+			longhorn.VolumeConditionTypeReducedRedundancy, longhorn.ConditionStatusFalse, "", "")
+	}
+}
@@ -35,6 +35,7 @@ type WebsocketController struct {
 	cacheSyncs []cache.InformerSynced
 
 	watchers    []*Watcher
+	subscribers []*StateChangeSubscriber
 	watcherLock sync.Mutex
 }
 
@@ -74,6 +75,10 @@ func NewWebsocketController(
 	ds.SystemRestoreInformer.AddEventHandler(wc.notifyWatchersHandler("systemRestore"))
 	wc.cacheSyncs = append(wc.cacheSyncs, ds.SystemRestoreInformer.HasSynced)
 
+	ds.VolumeInformer.AddEventHandler(wc.stateChangeHandler("volume", getVolumeStateChange))
+	ds.ReplicaInformer.AddEventHandler(wc.stateChangeHandler("replica", getReplicaStateChange))
+	ds.NodeInformer.AddEventHandler(wc.stateChangeHandler("node", getNodeStateChange))
+
 	return wc
 }
 
@@ -111,6 +116,10 @@ func (wc *WebsocketController) Close() {
 		w.Close()
 	}
 	wc.watchers = wc.watchers[:0]
+	for _, s := range wc.subscribers {
+		close(s.eventChan)
+	}
+	wc.subscribers = wc.subscribers[:0]
 }
 
 func (wc *WebsocketController) notifyWatchersHandler(resource string) cache.ResourceEventHandler {
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// workQueueDepthWarningThreshold is the queue depth above which a
+	// controller is considered to fall behind its events.
+	workQueueDepthWarningThreshold = 1000
+)
+
+var (
+	workQueuesLock sync.RWMutex
+	workQueues     = map[string]*instrumentedQueue{}
+)
+
+type WorkQueuePendingItem struct {
+	Key        string `json:"key"`
+	QueuedAt   string `json:"queuedAt"`
+	AgeSeconds int64  `json:"ageSeconds"`
+}
+
+type WorkQueueStatus struct {
+	Name               string                 `json:"name"`
+	Depth              int                    `json:"depth"`
+	OldestPendingAge   int64                  `json:"oldestPendingAge"`
+	PendingItems       []WorkQueuePendingItem `json:"pendingItems"`
+	DepthOverThreshold bool                   `json:"depthOverThreshold"`
+}
+
+// instrumentedQueue records when each key is due for a worker, so the keys
+// waiting for a worker and how long they have been waiting can be inspected.
+// A key added with a delay isn't pending until the delay passes. The depth,
+// latency and retry metrics come from the workqueue metrics provider.
+type instrumentedQueue struct {
+	workqueue.RateLimitingInterface
+
+	name   string
+	logger logrus.FieldLogger
+
+	lock          sync.Mutex
+	dueAt         map[interface{}]time.Time
+	overThreshold bool
+}
+
+func newInstrumentedQueue(name string, logger logrus.FieldLogger, queue workqueue.RateLimitingInterface) *instrumentedQueue {
+	q := &instrumentedQueue{
+		RateLimitingInterface: queue,
+		name:                  name,
+		logger:                logger,
+		dueAt:                 map[interface{}]time.Time{},
+	}
+
+	workQueuesLock.Lock()
+	defer workQueuesLock.Unlock()
+	workQueues[name] = q
+
+	return q
+}
+
+func (q *instrumentedQueue) record(item interface{}, delay time.Duration) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	// A key is handed out once, at the earliest time it's added for
+	dueAt := time.Now().Add(delay)
+	if existing, exists := q.dueAt[item]; !exists || dueAt.Before(existing) {
+		q.dueAt[item] = dueAt
+	}
+
+	depth := len(q.dueAt)
+	if depth > workQueueDepthWarningThreshold && !q.overThreshold {
+		q.overThreshold = true
+		q.logger.Warnf("Work queue has %v pending items, more than the threshold %v, the controller is falling behind", depth, workQueueDepthWarningThreshold)
+	} else if depth <= workQueueDepthWarningThreshold/2 && q.overThreshold {
+		q.overThreshold = false
+		q.logger.Infof("Work queue has caught up with %v pending items", depth)
+	}
+}
+
+func (q *instrumentedQueue) Add(item interface{}) {
+	q.record(item, 0)
+	q.RateLimitingInterface.Add(item)
+}
+
+func (q *instrumentedQueue) AddAfter(item interface{}, duration time.Duration) {
+	q.record(item, duration)
+	q.RateLimitingInterface.AddAfter(item, duration)
+}
+
+// AddRateLimited records the key as due right away, since the backoff of the
+// rate limiter isn't known without consuming it. The controllers drop a key
+// after a few retries, so the backoff is short.
+func (q *instrumentedQueue) AddRateLimited(item interface{}) {
+	q.record(item, 0)
+	q.RateLimitingInterface.AddRateLimited(item)
+}
+
+func (q *instrumentedQueue) Get() (interface{}, bool) {
+	item, shutdown := q.RateLimitingInterface.Get()
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.dueAt, item)
+
+	return item, shutdown
+}
+
+func (q *instrumentedQueue) status(now time.Time) *WorkQueueStatus {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	status := &WorkQueueStatus{
+		Name:               q.name,
+		Depth:              q.Len(),
+		PendingItems:       []WorkQueuePendingItem{},
+		DepthOverThreshold: q.overThreshold,
+	}
+	for item, dueAt := range q.dueAt {
+		if dueAt.After(now) {
+			continue
+		}
+		age := int64(now.Sub(dueAt).Seconds())
+		if age > status.OldestPendingAge {
+			status.OldestPendingAge = age
+		}
+		status.PendingItems = append(status.PendingItems, WorkQueuePendingItem{
+			Key:        fmt.Sprintf("%v", item),
+			QueuedAt:   dueAt.UTC().Format(time.RFC3339),
+			AgeSeconds: age,
+		})
+	}
+	sort.Slice(status.PendingItems, func(i, j int) bool {
+		return status.PendingItems[i].AgeSeconds > status.PendingItems[j].AgeSeconds
+	})
+	return status
+}
+
+// GetWorkQueueStatuses returns the pending items of the controller work
+// queues in this process, sorted by the queue name.
+func GetWorkQueueStatuses() []*WorkQueueStatus {
+	workQueuesLock.RLock()
+	defer workQueuesLock.RUnlock()
+
+	now := time.Now()
+	statuses := []*WorkQueueStatus{}
+	for _, q := range workQueues {
+		statuses = append(statuses, q.status(now))
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
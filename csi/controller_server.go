@@ -13,6 +13,7 @@ import (
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -55,6 +56,7 @@ func NewControllerServer(apiClient *longhornclient.RancherClient, nodeID string)
 				csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
 				csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT,
 				csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
+				csi.ControllerServiceCapability_RPC_GET_CAPACITY,
 			}),
 		accessModes: getVolumeCapabilityAccessModes(
 			[]csi.VolumeCapability_AccessMode_Mode{
@@ -64,7 +66,21 @@ func NewControllerServer(apiClient *longhornclient.RancherClient, nodeID string)
 	}
 }
 
+// withRequestID returns a copy of the server whose API client sends the
+// request ID of the call, so the manager logs the requests of the call with
+// the same ID.
+func (cs *ControllerServer) withRequestID(ctx context.Context) *ControllerServer {
+	requestID := util.GetRequestID(ctx)
+	if requestID == "" {
+		return cs
+	}
+	server := *cs
+	server.apiClient = cs.apiClient.WithRequestID(requestID)
+	return &server
+}
+
 func (cs *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+	cs = cs.withRequestID(ctx)
 
 	volumeID := util.AutoCorrectName(req.GetName(), datastore.NameMaximumLength)
 	if len(volumeID) == 0 {
@@ -235,6 +251,10 @@ func (cs *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 
 	vol.Name = volumeID
 	vol.Size = fmt.Sprintf("%d", reqVolSizeBytes)
+	// The filesystem of a shared volume is created by the share manager
+	if vol.AccessMode != string(longhorn.AccessModeReadWriteMany) {
+		vol.FilesystemType = getVolumeFilesystemType(volumeCaps)
+	}
 
 	logrus.Infof("CreateVolume: creating a volume by API client, name: %s, size: %s accessMode: %v", vol.Name, vol.Size, vol.AccessMode)
 	resVol, err := cs.apiClient.Volume.Create(vol)
@@ -321,6 +341,8 @@ func (cs *ControllerServer) checkAndPrepareBackingImage(volumeName, backingImage
 }
 
 func (cs *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+	cs = cs.withRequestID(ctx)
+
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "volume id missing in request")
@@ -358,6 +380,8 @@ func (cs *ControllerServer) ControllerGetCapabilities(ctx context.Context, req *
 }
 
 func (cs *ControllerServer) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
+	cs = cs.withRequestID(ctx)
+
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "volume id missing in request")
@@ -386,6 +410,8 @@ func (cs *ControllerServer) ValidateVolumeCapabilities(ctx context.Context, req
 
 // ControllerPublishVolume will attach the volume to the specified node
 func (cs *ControllerServer) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (*csi.ControllerPublishVolumeResponse, error) {
+	cs = cs.withRequestID(ctx)
+
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "volume id missing in request")
@@ -459,6 +485,7 @@ func (cs *ControllerServer) publishVolume(volume *longhornclient.Volume, nodeID
 	input := &longhornclient.AttachInput{
 		HostId:          nodeID,
 		DisableFrontend: false,
+		AttachedBy:      types.CSIAttachedBy,
 	}
 
 	logrus.Infof("ControllerPublishVolume: volume %s with accessMode %s requesting publishing to %s", volume.Name, volume.AccessMode, nodeID)
@@ -496,6 +523,8 @@ func (cs *ControllerServer) updateVolumeAccessMode(volume *longhornclient.Volume
 
 // ControllerUnpublishVolume will detach the volume
 func (cs *ControllerServer) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
+	cs = cs.withRequestID(ctx)
+
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "volume id missing in request")
@@ -549,11 +578,57 @@ func (cs *ControllerServer) ListVolumes(context.Context, *csi.ListVolumesRequest
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
-func (cs *ControllerServer) GetCapacity(context.Context, *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+// GetCapacity reports the storage that the replica scheduler can still place
+// on the disks of all schedulable and ready nodes. The maximum volume size is
+// the largest schedulable space of a single disk, since a replica cannot span
+// disks.
+func (cs *ControllerServer) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
+	cs = cs.withRequestID(ctx)
+
+	overProvisioningPercentage, err := cs.getSettingAsInt(types.SettingNameStorageOverProvisioningPercentage)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	minimalAvailablePercentage, err := cs.getSettingAsInt(types.SettingNameStorageMinimalAvailablePercentage)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	nodeCollection, err := cs.apiClient.Node.List(&longhornclient.ListOpts{})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	availableCapacity, maximumVolumeSize, err := getSchedulableCapacity(nodeCollection.Data, overProvisioningPercentage, minimalAvailablePercentage)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	logrus.Debugf("GetCapacity: available capacity %v, maximum volume size %v", availableCapacity, maximumVolumeSize)
+	return &csi.GetCapacityResponse{
+		AvailableCapacity: availableCapacity,
+		MaximumVolumeSize: &wrappers.Int64Value{Value: maximumVolumeSize},
+	}, nil
+}
+
+func (cs *ControllerServer) getSettingAsInt(name types.SettingName) (int64, error) {
+	setting, err := cs.apiClient.Setting.ById(string(name))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get setting %v: %v", name, err)
+	}
+	if setting == nil {
+		return 0, fmt.Errorf("setting %v not found", name)
+	}
+	value, err := strconv.ParseInt(setting.Value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse setting %v: %v", name, err)
+	}
+	return value, nil
 }
 
 func (cs *ControllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
+	cs = cs.withRequestID(ctx)
+
 	var rsp *csi.CreateSnapshotResponse
 	var err error
 	defer func() {
@@ -774,6 +849,8 @@ func normalizeCSISnapshotType(cSISnapshotType string) string {
 }
 
 func (cs *ControllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
+	cs = cs.withRequestID(ctx)
+
 	snapshotID := req.GetSnapshotId()
 	if len(snapshotID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "missing snapshot id in request")
@@ -818,6 +895,8 @@ func (cs *ControllerServer) ListSnapshots(context.Context, *csi.ListSnapshotsReq
 }
 
 func (cs *ControllerServer) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
+	cs = cs.withRequestID(ctx)
+
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "volume id missing in request")
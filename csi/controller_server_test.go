@@ -0,0 +1,45 @@
+package csi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	longhornclient "github.com/longhorn/longhorn-manager/client"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+func TestControllerServerWithRequestID(t *testing.T) {
+	assert := require.New(t)
+
+	requestIDs := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/v1/settings/test" {
+			requestIDs <- req.Header.Get(util.RequestIDHeader)
+		}
+		rw.Header().Set("X-API-Schemas", "http://"+req.Host+"/v1/schemas")
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"data": [{"id": "setting", "links": {"collection": "http://` + req.Host + `/v1/settings"}, "collectionMethods": ["GET"], "resourceMethods": ["GET"]}]}`))
+	}))
+	defer server.Close()
+
+	apiClient, err := longhornclient.NewRancherClient(&longhornclient.ClientOpts{Url: server.URL + "/v1"})
+	assert.NoError(err)
+	cs := NewControllerServer(apiClient, "node-1")
+
+	// The calls without a request ID share the server
+	assert.Same(cs, cs.withRequestID(context.Background()))
+
+	// The API requests of the call carry its request ID
+	withRequestID := cs.withRequestID(util.WithRequestID(context.Background(), "request-1"))
+	assert.NotSame(cs, withRequestID)
+	_, err = withRequestID.apiClient.Setting.ById("test")
+	assert.NoError(err)
+	assert.Equal("request-1", <-requestIDs)
+	_, err = cs.apiClient.Setting.ById("test")
+	assert.NoError(err)
+	assert.Empty(<-requestIDs)
+}
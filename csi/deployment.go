@@ -325,6 +325,10 @@ type PluginDeployment struct {
 func NewPluginDeployment(namespace, serviceAccount, nodeDriverRegistrarImage, livenessProbeImage, managerImage, managerURL, rootDir string,
 	tolerations []v1.Toleration, tolerationsString, priorityClass, registrySecret string, imagePullPolicy v1.PullPolicy, nodeSelector map[string]string) *PluginDeployment {
 
+	// The API tokens and CA are only needed if the API authentication and TLS
+	// are enabled
+	optional := true
+
 	daemonSet := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        types.CSIPluginName,
@@ -469,6 +473,30 @@ func NewPluginDeployment(namespace, serviceAccount, nodeDriverRegistrarImage, li
 									Name:  "CSI_ENDPOINT",
 									Value: GetCSIEndpoint(),
 								},
+								{
+									Name: types.EnvAPITokens,
+									ValueFrom: &v1.EnvVarSource{
+										SecretKeyRef: &v1.SecretKeySelector{
+											LocalObjectReference: v1.LocalObjectReference{
+												Name: types.APIAuthSecretName,
+											},
+											Key:      types.APIRoleAdmin,
+											Optional: &optional,
+										},
+									},
+								},
+								{
+									Name: types.EnvAPICACerts,
+									ValueFrom: &v1.EnvVarSource{
+										SecretKeyRef: &v1.SecretKeySelector{
+											LocalObjectReference: v1.LocalObjectReference{
+												Name: types.APITLSSecretName,
+											},
+											Key:      types.APITLSSecretCAKey,
+											Optional: &optional,
+										},
+									},
+								},
 							},
 							VolumeMounts: []v1.VolumeMount{
 								{
@@ -5,6 +5,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	longhornclient "github.com/longhorn/longhorn-manager/client"
+	"github.com/longhorn/longhorn-manager/types"
 )
 
 type Manager struct {
@@ -23,7 +24,11 @@ func (m *Manager) Run(driverName, nodeID, endpoint, identityVersion, managerURL
 	logrus.Infof("CSI Driver: %v version: %v, manager URL %v", driverName, identityVersion, managerURL)
 
 	// Longhorn API Client
-	clientOpts := &longhornclient.ClientOpts{Url: managerURL}
+	clientOpts := &longhornclient.ClientOpts{
+		Url:       managerURL,
+		SecretKey: types.GetAPIToken(),
+		CACerts:   types.GetAPICACerts(),
+	}
 	apiClient, err := longhornclient.NewRancherClient(clientOpts)
 	if err != nil {
 		return errors.Wrap(err, "Failed to initialize Longhorn API client")
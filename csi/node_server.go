@@ -25,6 +25,7 @@ import (
 	"github.com/longhorn/longhorn-manager/csi/crypto"
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
 )
 
 const (
@@ -72,8 +73,22 @@ func NewNodeServer(apiClient *longhornclient.RancherClient, nodeID string) *Node
 	}
 }
 
+// withRequestID returns a copy of the server whose API client sends the
+// request ID of the call.
+func (ns *NodeServer) withRequestID(ctx context.Context) *NodeServer {
+	requestID := util.GetRequestID(ctx)
+	if requestID == "" {
+		return ns
+	}
+	server := *ns
+	server.apiClient = ns.apiClient.WithRequestID(requestID)
+	return &server
+}
+
 // NodePublishVolume will mount the volume /dev/longhorn/<volume_name> to target_path
 func (ns *NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
+	ns = ns.withRequestID(ctx)
+
 	targetPath := req.GetTargetPath()
 	if targetPath == "" {
 		return nil, status.Error(codes.InvalidArgument, "target path missing in request")
@@ -282,6 +297,8 @@ func (ns *NodeServer) nodePublishBlockVolume(volumeID, devicePath, targetPath st
 }
 
 func (ns *NodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
+	ns = ns.withRequestID(ctx)
+
 	targetPath := req.GetTargetPath()
 	if targetPath == "" {
 		return nil, status.Error(codes.InvalidArgument, "target path missing in request")
@@ -301,6 +318,7 @@ func (ns *NodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 }
 
 func (ns *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
+	ns = ns.withRequestID(ctx)
 
 	targetPath := req.GetStagingTargetPath()
 	if targetPath == "" {
@@ -383,11 +401,19 @@ func (ns *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 		return &csi.NodeStageVolumeResponse{}, nil
 	}
 
-	options := volumeCapability.GetMount().GetMountFlags()
-	fsType := volumeCapability.GetMount().GetFsType()
-	if fsType == "" {
-		fsType = defaultFsType
+	// The manager formats the volume requested with a filesystem once it's attached
+	if volume.Filesystem != "" && volume.FilesystemType == "" && !volume.Encrypted && !volume.ReadOnly {
+		if formatErr := getVolumeFormatError(volume); formatErr != "" {
+			return nil, status.Errorf(codes.FailedPrecondition, "volume %s cannot be formatted with %v filesystem: %v", volumeID, volume.Filesystem, formatErr)
+		}
+		return nil, status.Errorf(codes.Aborted, "volume %s is being formatted with %v filesystem", volumeID, volume.Filesystem)
+	}
+
+	options := mergeMountOptions(volumeCapability.GetMount().GetMountFlags(), volume.MountOptions)
+	if volume.ReadOnly {
+		options = mergeMountOptions(options, []string{"ro"})
 	}
+	fsType := getStageFilesystemType(volumeID, volumeCapability.GetMount().GetFsType(), volume.FilesystemType)
 
 	formatMounter, ok := mounter.(*mount.SafeFormatAndMount)
 	if !ok {
@@ -468,6 +494,7 @@ func (ns *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 }
 
 func (ns *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
+	ns = ns.withRequestID(ctx)
 
 	targetPath := req.GetStagingTargetPath()
 	if targetPath == "" {
@@ -507,6 +534,8 @@ func (ns *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstag
 }
 
 func (ns *NodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	ns = ns.withRequestID(ctx)
+
 	volumePath := req.GetVolumePath()
 	if volumePath == "" {
 		return nil, status.Error(codes.InvalidArgument, "volume path missing in request")
@@ -580,6 +609,8 @@ func (ns *NodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVo
 
 // NodeExpandVolume is designed to expand the file system for ONLINE expansion,
 func (ns *NodeServer) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
+	ns = ns.withRequestID(ctx)
+
 	if req.CapacityRange == nil {
 		return nil, status.Error(codes.InvalidArgument, "capacity range missing in request")
 	}
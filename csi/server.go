@@ -31,6 +31,8 @@ import (
 	"github.com/kubernetes-csi/csi-lib-utils/protosanitizer"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+
+	"github.com/longhorn/longhorn-manager/util"
 )
 
 func NewNonBlockingGRPCServer() *NonBlockingGRPCServer {
@@ -116,12 +118,16 @@ func parseEndpoint(ep string) (string, string, error) {
 	return "", "", fmt.Errorf("invalid endpoint: %v", ep)
 }
 
+// logGRPC logs the calls. Each call gets a request ID, which is sent with the
+// API requests of the call, so the call can be traced in the manager logs.
 func logGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	log := logrus.StandardLogger()
 	logLevel := logrus.InfoLevel
 
 	cut := strings.LastIndex(info.FullMethod, "/") + 1
 	method := info.FullMethod[cut:]
+
+	ctx = util.WithRequestID(ctx, util.NewRequestID())
+	log := util.WithRequestIDField(ctx, logrus.StandardLogger()).WithField("method", method)
 	switch method {
 	case "NodeGetCapabilities", "NodeGetVolumeStats", "Probe":
 		logLevel = logrus.TraceLevel
@@ -11,7 +11,6 @@ import (
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/pkg/errors"
-	"github.com/robfig/cron"
 	"github.com/sirupsen/logrus"
 
 	"golang.org/x/sys/unix"
@@ -21,7 +20,9 @@ import (
 
 	longhornclient "github.com/longhorn/longhorn-manager/client"
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/scheduler"
 	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
 )
 
 const (
@@ -208,8 +209,8 @@ func parseJSONRecurringJobs(jsonRecurringJobs string) ([]longhornclient.Recurrin
 		return nil, fmt.Errorf("invalid json format of recurringJobs: %v  %v", jsonRecurringJobs, err)
 	}
 	for _, recurringJob := range recurringJobs {
-		if _, err := cron.ParseStandard(recurringJob.Cron); err != nil {
-			return nil, fmt.Errorf("invalid cron format(%v): %v", recurringJob.Cron, err)
+		if _, err := util.ParseCronSchedule(recurringJob.Cron); err != nil {
+			return nil, err
 		}
 	}
 	return recurringJobs, nil
@@ -339,6 +340,68 @@ func makeFile(pathname string) error {
 	return nil
 }
 
+// getVolumeFilesystemType returns the filesystem type the volume is formatted
+// with when it's staged, if Longhorn can check it.
+func getVolumeFilesystemType(volumeCaps []*csi.VolumeCapability) string {
+	for _, cap := range volumeCaps {
+		mount := cap.GetMount()
+		if mount == nil {
+			continue
+		}
+		fsType := mount.GetFsType()
+		if fsType == "" {
+			fsType = defaultFsType
+		}
+		if fsType == util.FilesystemTypeExt4 || fsType == util.FilesystemTypeXFS {
+			return fsType
+		}
+	}
+	return ""
+}
+
+// mergeMountOptions appends the mount options of the volume missing from the
+// mount flags of the request.
+func mergeMountOptions(flags, volumeOptions []string) []string {
+	options := append([]string{}, flags...)
+	for _, option := range volumeOptions {
+		if !util.Contains(options, option) {
+			options = append(options, option)
+		}
+	}
+	return options
+}
+
+// getStageFilesystemType returns the filesystem type the volume is mounted
+// with. The filesystem the volume is recorded with wins over the one of the
+// capability, since the device is already formatted with it and mounting it
+// as another type would fail.
+func getStageFilesystemType(volumeID, capFsType, recordedFsType string) string {
+	switch {
+	case recordedFsType == "" && capFsType == "":
+		return defaultFsType
+	case recordedFsType == "":
+		return capFsType
+	case capFsType != "" && capFsType != recordedFsType:
+		logrus.Warnf("volume %v is formatted with %v filesystem, ignoring the requested %v filesystem", volumeID, recordedFsType, capFsType)
+	}
+	return recordedFsType
+}
+
+// getVolumeFormatError returns the reason the manager failed to format the
+// volume with the requested filesystem, or empty if it didn't fail.
+func getVolumeFormatError(volume *longhornclient.Volume) string {
+	conditions := map[string]longhorn.Condition{}
+	if err := convertAPIObject(volume.Conditions, &conditions); err != nil {
+		logrus.WithError(err).Warnf("Failed to parse conditions of volume %v", volume.Name)
+		return ""
+	}
+	condition := conditions[longhorn.VolumeConditionTypeFilesystemError]
+	if condition.Status != longhorn.ConditionStatusTrue || condition.Reason != longhorn.VolumeConditionReasonFilesystemFormatFailure {
+		return ""
+	}
+	return condition.Message
+}
+
 // requiresSharedAccess checks if the volume is requested to be multi node capable
 // a volume that is already in shared access mode, must be used via shared access
 // even if single node access is requested.
@@ -358,3 +421,56 @@ func requiresSharedAccess(vol *longhornclient.Volume, cap *csi.VolumeCapability)
 		mode == csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER ||
 		mode == csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER
 }
+
+// convertAPIObject decodes a loosely typed field of an API client object,
+// e.g. the disks of a node, into the given typed value.
+func convertAPIObject(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, to)
+}
+
+// getSchedulableCapacity sums the storage that the replica scheduler can
+// still place on the disks of the schedulable and ready nodes, and returns it
+// along with the largest schedulable storage of a single disk.
+func getSchedulableCapacity(nodes []longhornclient.Node, overProvisioningPercentage, minimalAvailablePercentage int64) (int64, int64, error) {
+	var availableCapacity, maximumVolumeSize int64
+	for _, node := range nodes {
+		if !node.AllowScheduling || !isNodeReady(&node) {
+			continue
+		}
+		disks := map[string]longhornclient.DiskInfo{}
+		if err := convertAPIObject(node.Disks, &disks); err != nil {
+			return 0, 0, fmt.Errorf("failed to parse disks of node %v: %v", node.Name, err)
+		}
+		for _, disk := range disks {
+			if !disk.AllowScheduling || disk.EvictionRequested {
+				continue
+			}
+			schedulable := scheduler.GetSchedulableStorage(&scheduler.DiskSchedulingInfo{
+				StorageAvailable:           disk.StorageAvailable,
+				StorageMaximum:             disk.StorageMaximum,
+				StorageReserved:            disk.StorageReserved,
+				StorageScheduled:           disk.StorageScheduled,
+				OverProvisioningPercentage: overProvisioningPercentage,
+				MinimalAvailablePercentage: minimalAvailablePercentage,
+			})
+			availableCapacity += schedulable
+			if schedulable > maximumVolumeSize {
+				maximumVolumeSize = schedulable
+			}
+		}
+	}
+	return availableCapacity, maximumVolumeSize, nil
+}
+
+func isNodeReady(node *longhornclient.Node) bool {
+	conditions := map[string]longhorn.Condition{}
+	if err := convertAPIObject(node.Conditions, &conditions); err != nil {
+		logrus.WithError(err).Warnf("Failed to parse conditions of node %v", node.Name)
+		return false
+	}
+	return conditions[longhorn.NodeConditionTypeReady].Status == longhorn.ConditionStatusTrue
+}
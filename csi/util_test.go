@@ -0,0 +1,104 @@
+package csi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/longhorn/longhorn-manager/util"
+
+	longhornclient "github.com/longhorn/longhorn-manager/client"
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func TestGetStageFilesystemType(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(defaultFsType, getStageFilesystemType("vol", "", ""))
+	assert.Equal(util.FilesystemTypeXFS, getStageFilesystemType("vol", util.FilesystemTypeXFS, ""))
+	assert.Equal(util.FilesystemTypeXFS, getStageFilesystemType("vol", "", util.FilesystemTypeXFS))
+	// The device is already formatted with the recorded filesystem
+	assert.Equal(util.FilesystemTypeXFS, getStageFilesystemType("vol", util.FilesystemTypeExt4, util.FilesystemTypeXFS))
+}
+
+func TestGetVolumeFormatError(t *testing.T) {
+	assert := require.New(t)
+
+	newVolume := func(status longhorn.ConditionStatus, reason string) *longhornclient.Volume {
+		return &longhornclient.Volume{
+			Name: "vol",
+			Conditions: map[string]interface{}{
+				longhorn.VolumeConditionTypeFilesystemError: map[string]interface{}{
+					"type":    longhorn.VolumeConditionTypeFilesystemError,
+					"status":  string(status),
+					"reason":  reason,
+					"message": "mkfs.xfs not found",
+				},
+			},
+		}
+	}
+
+	assert.Equal("", getVolumeFormatError(&longhornclient.Volume{Name: "vol"}))
+	assert.Equal("", getVolumeFormatError(newVolume(longhorn.ConditionStatusFalse, "")))
+	assert.Equal("", getVolumeFormatError(newVolume(longhorn.ConditionStatusTrue, longhorn.VolumeConditionReasonFilesystemErrorsFound)))
+	assert.Equal("mkfs.xfs not found", getVolumeFormatError(newVolume(longhorn.ConditionStatusTrue, longhorn.VolumeConditionReasonFilesystemFormatFailure)))
+}
+
+func TestMergeMountOptions(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal([]string{"noatime", "discard"}, mergeMountOptions([]string{"noatime"}, []string{"discard", "noatime"}))
+	assert.Equal([]string{}, mergeMountOptions(nil, nil))
+}
+
+func TestGetSchedulableCapacity(t *testing.T) {
+	assert := require.New(t)
+
+	newNode := func(name string, allowScheduling bool, ready longhorn.ConditionStatus, disks map[string]interface{}) longhornclient.Node {
+		return longhornclient.Node{
+			Name:            name,
+			AllowScheduling: allowScheduling,
+			Conditions: map[string]interface{}{
+				longhorn.NodeConditionTypeReady: map[string]interface{}{
+					"type":   longhorn.NodeConditionTypeReady,
+					"status": string(ready),
+				},
+			},
+			Disks: disks,
+		}
+	}
+	newDisk := func(allowScheduling bool, maximum, available, reserved, scheduled int64) map[string]interface{} {
+		return map[string]interface{}{
+			"allowScheduling":  allowScheduling,
+			"storageMaximum":   maximum,
+			"storageAvailable": available,
+			"storageReserved":  reserved,
+			"storageScheduled": scheduled,
+		}
+	}
+
+	nodes := []longhornclient.Node{
+		newNode("node-1", true, longhorn.ConditionStatusTrue, map[string]interface{}{
+			// (100 - 20) * 200% - 100
+			"disk-1": newDisk(true, 100, 60, 20, 100),
+			// (100 - 20) * 200% - 40
+			"disk-2": newDisk(true, 100, 90, 20, 40),
+			// Over scheduled
+			"disk-3": newDisk(true, 100, 90, 20, 200),
+			// Below the minimal available percentage
+			"disk-4": newDisk(true, 100, 25, 0, 0),
+			"disk-5": newDisk(false, 100, 100, 0, 0),
+		}),
+		newNode("node-2", false, longhorn.ConditionStatusTrue, map[string]interface{}{
+			"disk-1": newDisk(true, 100, 100, 0, 0),
+		}),
+		newNode("node-3", true, longhorn.ConditionStatusFalse, map[string]interface{}{
+			"disk-1": newDisk(true, 100, 100, 0, 0),
+		}),
+	}
+
+	availableCapacity, maximumVolumeSize, err := getSchedulableCapacity(nodes, 200, 25)
+	assert.NoError(err)
+	assert.Equal(int64(60+120), availableCapacity)
+	assert.Equal(int64(120), maximumVolumeSize)
+}
@@ -0,0 +1,240 @@
+package datastore
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+
+	"github.com/longhorn/longhorn-manager/metrics_collector/registry"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+const (
+	clientMaxRetries     = 3
+	clientRetryBaseDelay = 200 * time.Millisecond
+
+	clientBreakerThreshold = 10
+	clientBreakerCoolDown  = 10 * time.Second
+
+	// ClientUnavailableRetryInterval is how long the callers should wait
+	// before retrying once the datastore client fails fast.
+	ClientUnavailableRetryInterval = clientBreakerCoolDown
+)
+
+var (
+	clientRequestLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "longhorn",
+			Subsystem: "datastore",
+			Name:      "request_latency_seconds",
+			Help:      "Latency of the datastore requests in seconds, retries included. Broken down by verb and resource.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+		},
+		[]string{"verb", "resource"},
+	)
+
+	clientRequestResult = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "longhorn",
+			Subsystem: "datastore",
+			Name:      "requests_total",
+			Help:      "Number of datastore requests. Broken down by verb, resource and result, which is success, error, retried or rejected by the open circuit breaker.",
+		},
+		[]string{"verb", "resource", "result"},
+	)
+)
+
+func init() {
+	registry.Register(clientRequestLatency)
+	registry.Register(clientRequestResult)
+}
+
+// WrapClientConfig makes the clients created from the config retry the reads
+// failed by the API server being unavailable, with a jittered exponential
+// backoff, and fail fast the requests of a resource while the API server
+// keeps failing them. The writes are never retried since they're not
+// idempotent, like client-go does. The rate limit is set by the QPS and the
+// burst of the config. The leases are left alone, so the leader election
+// keeps its own timing.
+func WrapClientConfig(config *rest.Config) {
+	breakers := newClientBreakers()
+	config.WrapTransport = transport.Wrappers(config.WrapTransport, func(rt http.RoundTripper) http.RoundTripper {
+		return newClientRoundTripper(rt, breakers)
+	})
+}
+
+// ErrorIsUnavailable checks if the datastore client failed fast since the API
+// server is considered down.
+func ErrorIsUnavailable(err error) bool {
+	return errors.Is(err, util.ErrCircuitBreakerOpen)
+}
+
+// clientBreakers holds a circuit breaker per resource, so the resources the
+// API server keeps failing, e.g. the ones of an overloaded webhook or
+// aggregated API, don't fail fast the requests of the others.
+type clientBreakers struct {
+	lock     sync.Mutex
+	breakers map[string]*util.CircuitBreaker
+}
+
+func newClientBreakers() *clientBreakers {
+	return &clientBreakers{
+		breakers: map[string]*util.CircuitBreaker{},
+	}
+}
+
+func (b *clientBreakers) get(group, resource string) *util.CircuitBreaker {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	key := group + "/" + resource
+	breaker, ok := b.breakers[key]
+	if !ok {
+		breaker = util.NewCircuitBreaker(clientBreakerThreshold, clientBreakerCoolDown)
+		b.breakers[key] = breaker
+	}
+	return breaker
+}
+
+type clientRoundTripper struct {
+	delegate http.RoundTripper
+	breakers *clientBreakers
+
+	// for unit test
+	retryBaseDelay time.Duration
+}
+
+func newClientRoundTripper(delegate http.RoundTripper, breakers *clientBreakers) *clientRoundTripper {
+	return &clientRoundTripper{
+		delegate:       delegate,
+		breakers:       breakers,
+		retryBaseDelay: clientRetryBaseDelay,
+	}
+}
+
+func (c *clientRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	verb, group, resource := getRequestVerbAndResource(req)
+	if group == "coordination.k8s.io" && resource == "leases" {
+		return c.delegate.RoundTrip(req)
+	}
+	breaker := c.breakers.get(group, resource)
+	start := time.Now()
+
+	retryable := req.Method == http.MethodGet && verb != "watch"
+	delay := c.retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		if err := breaker.Allow(); err != nil {
+			clientRequestResult.WithLabelValues(verb, resource, "rejected").Inc()
+			return nil, err
+		}
+
+		resp, err := c.delegate.RoundTrip(req)
+		failed := err != nil || isServerError(resp.StatusCode)
+		if err != nil && req.Context().Err() != nil {
+			// Canceled by the caller rather than failed by the API server
+			breaker.Ignore()
+		} else {
+			unavailable := err != nil || isServerUnavailable(resp.StatusCode)
+			if breaker.Record(!unavailable) {
+				if unavailable {
+					logrus.WithError(err).Warnf("Datastore client failing fast the requests of %v for %v since the API server keeps failing them", resource, clientBreakerCoolDown)
+				} else {
+					logrus.Infof("Datastore client recovered the requests of %v since the API server is available again", resource)
+				}
+			}
+		}
+		if !failed {
+			clientRequestResult.WithLabelValues(verb, resource, "success").Inc()
+			if verb != "watch" {
+				clientRequestLatency.WithLabelValues(verb, resource).Observe(time.Since(start).Seconds())
+			}
+			return resp, nil
+		}
+		if !retryable || attempt >= clientMaxRetries || req.Context().Err() != nil {
+			clientRequestResult.WithLabelValues(verb, resource, "error").Inc()
+			return resp, err
+		}
+
+		clientRequestResult.WithLabelValues(verb, resource, "retried").Inc()
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(wait.Jitter(delay, 1.0)):
+		case <-req.Context().Done():
+			clientRequestResult.WithLabelValues(verb, resource, "error").Inc()
+			return nil, req.Context().Err()
+		}
+		delay *= 2
+	}
+}
+
+// isServerError checks if a read failed with the status is worth a retry.
+func isServerError(code int) bool {
+	return code == http.StatusInternalServerError || code == http.StatusBadGateway ||
+		isServerUnavailable(code)
+}
+
+// isServerUnavailable checks if the status tells the API server is
+// unavailable, which counts toward opening the circuit breaker. The other
+// server errors are answers of an available API server.
+func isServerUnavailable(code int) bool {
+	return code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// getRequestVerbAndResource gets the Kubernetes verb, API group and resource
+// from the request path, e.g. /apis/longhorn.io/v1beta2/namespaces/longhorn-system/volumes/vol
+// is a get of volumes in the group longhorn.io. The core group is empty.
+func getRequestVerbAndResource(req *http.Request) (verb, group, resource string) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		group = parts[1]
+		parts = parts[3:]
+	default:
+		return strings.ToLower(req.Method), "", "unknown"
+	}
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+	if len(parts) == 0 {
+		return strings.ToLower(req.Method), group, "unknown"
+	}
+	resource, named := parts[0], len(parts) > 1
+
+	switch req.Method {
+	case http.MethodGet:
+		if req.URL.Query().Get("watch") == "true" {
+			return "watch", group, resource
+		}
+		if named {
+			return "get", group, resource
+		}
+		return "list", group, resource
+	case http.MethodPost:
+		return "create", group, resource
+	case http.MethodPut:
+		return "update", group, resource
+	case http.MethodPatch:
+		return "patch", group, resource
+	case http.MethodDelete:
+		if named {
+			return "delete", group, resource
+		}
+		return "deletecollection", group, resource
+	}
+	return strings.ToLower(req.Method), group, resource
+}
@@ -0,0 +1,171 @@
+package datastore
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+// fakeRoundTripper answers the requests with the statuses in order, a 0
+// status being a connection error, then with 200.
+type fakeRoundTripper struct {
+	statuses []int
+	requests int
+}
+
+func (f *fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.requests++
+	status := http.StatusOK
+	if len(f.statuses) > 0 {
+		status = f.statuses[0]
+		f.statuses = f.statuses[1:]
+	}
+	if status == 0 {
+		return nil, fmt.Errorf("connection refused")
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func newTestClientRoundTripper(delegate http.RoundTripper) *clientRoundTripper {
+	c := newClientRoundTripper(delegate, newClientBreakers())
+	c.retryBaseDelay = time.Millisecond
+	return c
+}
+
+func TestClientRoundTripperRetry(t *testing.T) {
+	assert := require.New(t)
+
+	const volumes = "/apis/longhorn.io/v1beta2/namespaces/longhorn-system/volumes"
+
+	testCases := map[string]struct {
+		method           string
+		url              string
+		statuses         []int
+		expectedStatus   int
+		expectedRequests int
+	}{
+		"read recovered": {
+			http.MethodGet, volumes, []int{http.StatusServiceUnavailable, 0, http.StatusInternalServerError},
+			http.StatusOK, 4,
+		},
+		"read exhausted": {
+			http.MethodGet, volumes + "/vol", []int{503, 503, 503, 503, 503},
+			http.StatusServiceUnavailable, clientMaxRetries + 1,
+		},
+		"read not found": {
+			http.MethodGet, volumes + "/vol", []int{http.StatusNotFound},
+			http.StatusNotFound, 1,
+		},
+		"write": {
+			http.MethodPost, volumes, []int{http.StatusServiceUnavailable},
+			http.StatusServiceUnavailable, 1,
+		},
+		"watch": {
+			http.MethodGet, volumes + "?watch=true", []int{http.StatusServiceUnavailable},
+			http.StatusServiceUnavailable, 1,
+		},
+	}
+	for name, tc := range testCases {
+		delegate := &fakeRoundTripper{statuses: tc.statuses}
+		resp, err := newTestClientRoundTripper(delegate).RoundTrip(httptest.NewRequest(tc.method, tc.url, nil))
+		assert.NoError(err, name)
+		assert.Equal(tc.expectedStatus, resp.StatusCode, name)
+		assert.Equal(tc.expectedRequests, delegate.requests, name)
+	}
+
+	// The connection error is returned once the retries are exhausted
+	delegate := &fakeRoundTripper{statuses: []int{0, 0, 0, 0}}
+	_, err := newTestClientRoundTripper(delegate).RoundTrip(httptest.NewRequest(http.MethodGet, volumes, nil))
+	assert.Error(err)
+	assert.Equal(clientMaxRetries+1, delegate.requests)
+}
+
+func TestClientRoundTripperBreaker(t *testing.T) {
+	assert := require.New(t)
+
+	const (
+		volumes = "/apis/longhorn.io/v1beta2/namespaces/longhorn-system/volumes"
+		nodes   = "/apis/longhorn.io/v1beta2/namespaces/longhorn-system/nodes"
+		leases  = "/apis/coordination.k8s.io/v1/namespaces/longhorn-system/leases"
+	)
+	create := func(c *clientRoundTripper, url string) error {
+		_, err := c.RoundTrip(httptest.NewRequest(http.MethodPost, url, nil))
+		return err
+	}
+
+	// The other server errors are answers of an available API server
+	delegate := &fakeRoundTripper{}
+	c := newTestClientRoundTripper(delegate)
+	for i := 0; i < clientBreakerThreshold; i++ {
+		delegate.statuses = append(delegate.statuses, http.StatusInternalServerError, http.StatusBadGateway)
+	}
+	for i := 0; i < 2*clientBreakerThreshold; i++ {
+		assert.NoError(create(c, volumes))
+	}
+	assert.NoError(create(c, volumes))
+
+	// The unavailable API server and the connection errors open the breaker
+	// of the resource only
+	delegate = &fakeRoundTripper{}
+	c = newTestClientRoundTripper(delegate)
+	for i := 0; i < clientBreakerThreshold; i++ {
+		delegate.statuses = append(delegate.statuses, []int{http.StatusServiceUnavailable, http.StatusGatewayTimeout, 0}[i%3])
+	}
+	for i := 0; i < clientBreakerThreshold; i++ {
+		_ = create(c, volumes)
+	}
+	requests := delegate.requests
+	assert.ErrorIs(create(c, volumes), util.ErrCircuitBreakerOpen)
+	assert.Equal(requests, delegate.requests)
+	assert.NoError(create(c, nodes))
+
+	// The leases never fail fast
+	delegate.statuses = nil
+	for i := 0; i < clientBreakerThreshold; i++ {
+		delegate.statuses = append(delegate.statuses, http.StatusServiceUnavailable)
+	}
+	for i := 0; i < clientBreakerThreshold+1; i++ {
+		_ = create(c, leases)
+	}
+	assert.NoError(create(c, leases))
+}
+
+func TestGetRequestVerbAndResource(t *testing.T) {
+	assert := require.New(t)
+
+	testCases := []struct {
+		method   string
+		url      string
+		verb     string
+		group    string
+		resource string
+	}{
+		{http.MethodGet, "/apis/longhorn.io/v1beta2/namespaces/longhorn-system/volumes/vol", "get", "longhorn.io", "volumes"},
+		{http.MethodGet, "/apis/longhorn.io/v1beta2/namespaces/longhorn-system/volumes", "list", "longhorn.io", "volumes"},
+		{http.MethodGet, "/apis/longhorn.io/v1beta2/volumes?watch=true", "watch", "longhorn.io", "volumes"},
+		{http.MethodGet, "/api/v1/nodes/node-1", "get", "", "nodes"},
+		{http.MethodGet, "/api/v1/namespaces/longhorn-system", "get", "", "namespaces"},
+		{http.MethodPost, "/api/v1/namespaces/longhorn-system/pods", "create", "", "pods"},
+		{http.MethodPut, "/apis/longhorn.io/v1beta2/namespaces/longhorn-system/volumes/vol/status", "update", "longhorn.io", "volumes"},
+		{http.MethodPatch, "/apis/apps/v1/namespaces/longhorn-system/daemonsets/ds", "patch", "apps", "daemonsets"},
+		{http.MethodDelete, "/api/v1/namespaces/longhorn-system/pods/pod", "delete", "", "pods"},
+		{http.MethodDelete, "/api/v1/namespaces/longhorn-system/pods", "deletecollection", "", "pods"},
+		{http.MethodPut, "/apis/coordination.k8s.io/v1/namespaces/longhorn-system/leases/lease", "update", "coordination.k8s.io", "leases"},
+		{http.MethodGet, "/version", "get", "", "unknown"},
+		{http.MethodGet, "/apis/longhorn.io/v1beta2", "get", "longhorn.io", "unknown"},
+	}
+	for _, tc := range testCases {
+		verb, group, resource := getRequestVerbAndResource(httptest.NewRequest(tc.method, tc.url, nil))
+		assert.Equal(tc.verb, verb, tc.url)
+		assert.Equal(tc.group, group, tc.url)
+		assert.Equal(tc.resource, resource, tc.url)
+	}
+}
@@ -102,6 +102,8 @@ type DataStore struct {
 	PriorityClassInformer         cache.SharedInformer
 	csiDriverLister               storagelisters_v1.CSIDriverLister
 	CSIDriverInformer             cache.SharedInformer
+	csiNodeLister                 storagelisters_v1.CSINodeLister
+	CSINodeInformer               cache.SharedInformer
 	storageclassLister            storagelisters_v1.StorageClassLister
 	StorageClassInformer          cache.SharedInformer
 	pdbLister                     policylisters.PodDisruptionBudgetLister
@@ -188,6 +190,8 @@ func NewDataStore(
 	cacheSyncs = append(cacheSyncs, priorityClassInformer.Informer().HasSynced)
 	csiDriverInformer := kubeInformerFactory.Storage().V1().CSIDrivers()
 	cacheSyncs = append(cacheSyncs, csiDriverInformer.Informer().HasSynced)
+	csiNodeInformer := kubeInformerFactory.Storage().V1().CSINodes()
+	cacheSyncs = append(cacheSyncs, csiNodeInformer.Informer().HasSynced)
 	storageclassInformer := kubeInformerFactory.Storage().V1().StorageClasses()
 	cacheSyncs = append(cacheSyncs, storageclassInformer.Informer().HasSynced)
 	pdbInformer := kubeInformerFactory.Policy().V1().PodDisruptionBudgets()
@@ -267,6 +271,8 @@ func NewDataStore(
 		PriorityClassInformer:         priorityClassInformer.Informer(),
 		csiDriverLister:               csiDriverInformer.Lister(),
 		CSIDriverInformer:             csiDriverInformer.Informer(),
+		csiNodeLister:                 csiNodeInformer.Lister(),
+		CSINodeInformer:               csiNodeInformer.Informer(),
 		storageclassLister:            storageclassInformer.Lister(),
 		StorageClassInformer:          storageclassInformer.Informer(),
 		pdbLister:                     pdbInformer.Lister(),
@@ -518,6 +518,12 @@ func (s *DataStore) GetKubernetesNode(name string) (*corev1.Node, error) {
 	return s.knLister.Get(name)
 }
 
+// GetCSINode gets the CSINode of the given Kubernetes node, which lists the
+// CSI drivers registered with the kubelet on the node
+func (s *DataStore) GetCSINode(name string) (*storagev1.CSINode, error) {
+	return s.csiNodeLister.Get(name)
+}
+
 // IsKubeNodeUnschedulable checks if the Kubernetes Node resource is
 // unschedulable
 func (s *DataStore) IsKubeNodeUnschedulable(nodeName string) (bool, error) {
@@ -667,6 +673,13 @@ func (s *DataStore) GetSecret(namespace, name string) (*corev1.Secret, error) {
 	return resultRO.DeepCopy(), nil
 }
 
+// GetAPIAuthSecretRO gets the Secret holding the API tokens in the Longhorn
+// namespace. This function returns direct reference to the internal cache
+// object and should not be mutated.
+func (s *DataStore) GetAPIAuthSecretRO() (*corev1.Secret, error) {
+	return s.secretLister.Secrets(s.namespace).Get(types.APIAuthSecretName)
+}
+
 // GetPriorityClass gets the PriorityClass from the index for the
 // given name
 func (s *DataStore) GetPriorityClass(pcName string) (*schedulingv1.PriorityClass, error) {
@@ -907,6 +920,22 @@ func (s *DataStore) GetJob(name string) (*batchv1.Job, error) {
 	return s.kubeClient.BatchV1().Jobs(s.namespace).Get(context.TODO(), name, metav1.GetOptions{})
 }
 
+// ListJobs returns an uncached list of the Job resources in the Longhorn
+// namespace, since there is no Job informer
+func (s *DataStore) ListJobs() ([]batchv1.Job, error) {
+	list, err := s.kubeClient.BatchV1().Jobs(s.namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// DeleteEvent deletes the Event resource for the given name in the Longhorn
+// namespace
+func (s *DataStore) DeleteEvent(name string) error {
+	return s.kubeClient.CoreV1().Events(s.namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+}
+
 // CreateServiceAccount create a ServiceAccount resource with the given ServiceAccount object in the Longhorn
 // namespace
 func (s *DataStore) CreateServiceAccount(serviceAccount *corev1.ServiceAccount) (*corev1.ServiceAccount, error) {
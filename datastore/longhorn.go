@@ -4,12 +4,12 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/robfig/cron"
 	"github.com/sirupsen/logrus"
 
 	corev1 "k8s.io/api/core/v1"
@@ -507,6 +507,36 @@ func (s *DataStore) GetCredentialFromSecret(secretName string) (map[string]strin
 	return credentialSecret, nil
 }
 
+// CheckAirGappedBackupTarget checks the backup target can be reached in the
+// air-gapped mode.
+func (s *DataStore) CheckAirGappedBackupTarget(backupType string, credential map[string]string) error {
+	airGapped, err := s.GetSettingAsBool(types.SettingNameAirGappedMode)
+	if err != nil {
+		return err
+	}
+	if !airGapped {
+		return nil
+	}
+	return types.ValidateAirGappedBackupTarget(backupType, credential)
+}
+
+// ResolveImage resolves the image from the air-gapped registry in the
+// air-gapped mode.
+func (s *DataStore) ResolveImage(image string) (string, error) {
+	airGapped, err := s.GetSettingAsBool(types.SettingNameAirGappedMode)
+	if err != nil {
+		return "", err
+	}
+	if !airGapped {
+		return image, nil
+	}
+	registry, err := s.GetSetting(types.SettingNameAirGappedRegistry)
+	if err != nil {
+		return "", err
+	}
+	return types.ResolveImageWithRegistry(image, registry.Value)
+}
+
 func CheckVolume(v *longhorn.Volume) error {
 	size, err := util.ConvertSize(v.Spec.Size)
 	if err != nil {
@@ -517,11 +547,12 @@ func CheckVolume(v *longhorn.Volume) error {
 	}
 	errs := validation.IsDNS1123Label(v.Name)
 	if len(errs) != 0 {
-		return fmt.Errorf("invalid volume name: %+v", errs)
+		return types.NewReasonError(types.ErrorReasonInvalidParameter, map[string]string{types.ErrorParameterParameter: "name"},
+			"invalid volume name: %+v", errs)
 	}
 	if len(v.Name) > NameMaximumLength {
-		return fmt.Errorf("volume name is too long %v, must be less than %v characters",
-			v.Name, NameMaximumLength)
+		return types.NewReasonError(types.ErrorReasonInvalidParameter, map[string]string{types.ErrorParameterParameter: "name"},
+			"volume name is too long %v, must be less than %v characters", v.Name, NameMaximumLength)
 	}
 	return nil
 }
@@ -683,6 +714,96 @@ func (s *DataStore) ListVolumesBySelectorRO(selector labels.Selector) ([]*longho
 	return s.vLister.Volumes(s.namespace).List(selector)
 }
 
+// ListVolumesSortedBySelectorRO returns the Volumes matching the selector and
+// the filter if it's not nil, sorted by name, starting after the volume named
+// startAfter if it's not empty. At most limit Volumes are returned if limit is
+// positive, and more reports whether there are Volumes left after them.
+func (s *DataStore) ListVolumesSortedBySelectorRO(selector labels.Selector, filter func(*longhorn.Volume) bool, startAfter string, limit int) (volumes []*longhorn.Volume, more bool, err error) {
+	list, err := s.ListVolumesBySelectorRO(selector)
+	if err != nil {
+		return nil, false, err
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	volumes = []*longhorn.Volume{}
+	for _, v := range list {
+		if v.Name <= startAfter {
+			continue
+		}
+		if filter != nil && !filter(v) {
+			continue
+		}
+		if limit > 0 && len(volumes) == limit {
+			return volumes, true, nil
+		}
+		volumes = append(volumes, v)
+	}
+	return volumes, false, nil
+}
+
+// ListTenantVolumesRO returns the Volumes of the tenant.
+func (s *DataStore) ListTenantVolumesRO(tenant string) ([]*longhorn.Volume, error) {
+	return s.ListVolumesBySelectorRO(labels.SelectorFromSet(labels.Set{
+		types.GetLonghornLabelKey(types.LonghornLabelTenant): tenant,
+	}))
+}
+
+// GetTenantQuota returns the quota of the tenant from the tenant quotas
+// setting. The tenant without a quota is unlimited.
+func (s *DataStore) GetTenantQuota(tenant string) (types.TenantQuota, error) {
+	setting, err := s.GetSetting(types.SettingNameTenantQuotas)
+	if err != nil {
+		return types.TenantQuota{}, err
+	}
+	quotas, err := types.UnmarshalTenantQuotas(setting.Value)
+	if err != nil {
+		return types.TenantQuota{}, err
+	}
+	return quotas[tenant], nil
+}
+
+// CheckTenantQuota checks if the tenant can have the volume of the size
+// besides its other volumes. The volume may be a new one, or an existing one
+// of the tenant being expanded. The check is done on the cached volumes, so
+// the requests racing on different managers may exceed the quota a bit.
+func (s *DataStore) CheckTenantQuota(tenant, volumeName string, size int64) error {
+	if tenant == "" {
+		return nil
+	}
+	quota, err := s.GetTenantQuota(tenant)
+	if err != nil {
+		return err
+	}
+	if quota.VolumeCount == 0 && quota.Size == 0 {
+		return nil
+	}
+	volumes, err := s.ListTenantVolumesRO(tenant)
+	if err != nil {
+		return err
+	}
+	otherCount, otherSize := int64(0), int64(0)
+	for _, v := range volumes {
+		if v.Name == volumeName {
+			continue
+		}
+		otherCount++
+		otherSize += v.Spec.Size
+	}
+	if quota.VolumeCount > 0 && otherCount+1 > quota.VolumeCount {
+		return types.NewReasonError(types.ErrorReasonQuotaExceeded,
+			map[string]string{types.ErrorParameterTenant: tenant, types.ErrorParameterLimit: strconv.FormatInt(quota.VolumeCount, 10)},
+			"tenant %v already has %v volumes, reaching the limit %v", tenant, otherCount, quota.VolumeCount)
+	}
+	if quota.Size > 0 && otherSize+size > quota.Size {
+		return types.NewReasonError(types.ErrorReasonQuotaExceeded,
+			map[string]string{types.ErrorParameterTenant: tenant, types.ErrorParameterLimit: strconv.FormatInt(quota.Size, 10)},
+			"tenant %v with other volumes of %v bytes cannot have volume %v of %v bytes, exceeding the limit %v", tenant, otherSize, volumeName, size, quota.Size)
+	}
+	return nil
+}
+
 // ListVolumes returns an object contains all Volume
 func (s *DataStore) ListVolumes() (map[string]*longhorn.Volume, error) {
 	itemMap := make(map[string]*longhorn.Volume)
@@ -1426,7 +1547,8 @@ func (s *DataStore) GetEngineImageByImage(image string) (*longhorn.EngineImage,
 		}
 	}
 
-	return nil, errors.Errorf("cannot find engine image by %v", image)
+	return nil, types.NewReasonError(types.ErrorReasonNotFound, map[string]string{types.ErrorParameterKind: "engineImage", types.ErrorParameterName: image},
+		"cannot find engine image by %v", image)
 }
 
 // ListEngineImages returns object includes all EngineImage in namespace
@@ -2055,7 +2177,8 @@ func (s *DataStore) CreateNode(node *longhorn.Node) (*longhorn.Node, error) {
 
 // CreateDefaultNode will create the default Disk at the value of the
 // DefaultDataPath Setting only if Create Default Disk on Labeled Nodes has
-// been disabled.
+// been disabled. The scheduling of the node can be disabled on first boot by
+// the Kubernetes node annotation node.longhorn.io/default-node-scheduling.
 func (s *DataStore) CreateDefaultNode(name string) (*longhorn.Node, error) {
 	requireLabel, err := s.GetSettingAsBool(types.SettingNameCreateDefaultDiskLabeledNodes)
 	if err != nil {
@@ -2075,6 +2198,19 @@ func (s *DataStore) CreateDefaultNode(name string) (*longhorn.Node, error) {
 		},
 	}
 
+	kubeNode, err := s.GetKubernetesNode(name)
+	if err != nil {
+		return nil, err
+	}
+	if val, exist := kubeNode.Annotations[types.KubeNodeDefaultSchedulingAnnotationKey]; exist {
+		allowScheduling, err := types.GetNodeSchedulingFromAnnotation(val)
+		if err != nil {
+			logrus.WithError(err).Warnf("Ignored the default scheduling of node %v", name)
+		} else {
+			node.Spec.AllowScheduling = allowScheduling
+		}
+	}
+
 	// For newly added node, the customized default disks will be applied only if the setting is enabled.
 	if !requireLabel {
 		// Note: this part wasn't moved to the controller is because
@@ -2085,7 +2221,7 @@ func (s *DataStore) CreateDefaultNode(name string) (*longhorn.Node, error) {
 		if err != nil {
 			return nil, err
 		}
-		storageReservedPercentageForDefaultDisk, err := s.GetSettingAsInt(types.SettingNameStorageReservedPercentageForDefaultDisk)
+		storageReservedPercentageForDefaultDisk, err := s.GetNodeSettingAsInt(types.SettingNameStorageReservedPercentageForDefaultDisk, name)
 		if err != nil {
 			return nil, err
 		}
@@ -2425,6 +2561,66 @@ func (s *DataStore) IsNodeSchedulable(name string) bool {
 	return nodeSchedulableCondition.Status == longhorn.ConditionStatusTrue
 }
 
+// GetSalvageableReplicas returns the failed replicas most likely to have the
+// latest data, i.e. the ones failed within the time limit of the last failure,
+// on an up node and a schedulable disk. The returned bool is false if none of
+// the replicas has ever had data.
+func (s *DataStore) GetSalvageableReplicas(rs map[string]*longhorn.Replica, timeLimit time.Duration) (map[string]*longhorn.Replica, bool) {
+	lastFailedAt := time.Time{}
+	failedUsableReplicas := map[string]*longhorn.Replica{}
+	dataExists := false
+
+	for _, r := range rs {
+		if r.Spec.HealthyAt == "" {
+			continue
+		}
+		dataExists = true
+		if r.Spec.NodeID == "" || r.Spec.DiskID == "" || r.Spec.FailedAt == "" {
+			continue
+		}
+		log := logrus.WithField("replica", r.Name)
+		if isDownOrDeleted, err := s.IsNodeDownOrDeleted(r.Spec.NodeID); err != nil {
+			log.WithError(err).Errorf("Unable to check if node %v is still running for failed replica", r.Spec.NodeID)
+			continue
+		} else if isDownOrDeleted {
+			continue
+		}
+		node, err := s.GetNodeRO(r.Spec.NodeID)
+		if err != nil {
+			log.WithError(err).Errorf("Unable to get node %v for failed replica", r.Spec.NodeID)
+			continue
+		}
+		diskSchedulable := false
+		for _, diskStatus := range node.Status.DiskStatus {
+			if diskStatus.DiskUUID == r.Spec.DiskID {
+				if types.GetCondition(diskStatus.Conditions, longhorn.DiskConditionTypeSchedulable).Status == longhorn.ConditionStatusTrue {
+					diskSchedulable = true
+					break
+				}
+			}
+		}
+		if !diskSchedulable {
+			continue
+		}
+		failedAt, err := util.ParseTime(r.Spec.FailedAt)
+		if err != nil {
+			log.WithError(err).Error("Unable to parse FailedAt timestamp for replica")
+			continue
+		}
+		if failedAt.After(lastFailedAt) {
+			lastFailedAt = failedAt
+		}
+		failedUsableReplicas[r.Name] = r
+	}
+
+	for name, r := range failedUsableReplicas {
+		if !util.TimestampWithinLimit(lastFailedAt, r.Spec.FailedAt, timeLimit) {
+			delete(failedUsableReplicas, name)
+		}
+	}
+	return failedUsableReplicas, dataExists
+}
+
 func getNodeSelector(nodeName string) (labels.Selector, error) {
 	return metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
 		MatchLabels: map[string]string{
@@ -2661,6 +2857,36 @@ func (s *DataStore) GetSettingAsInt(settingName types.SettingName) (int64, error
 	return -1, fmt.Errorf("the %v setting value couldn't change to integer, value is %v ", string(settingName), value)
 }
 
+// GetNodeSettingAsInt gets the setting for the given name and node, returns
+// as int64. The setting overridden for the node group of the Kubernetes node
+// takes precedence over the global one.
+func (s *DataStore) GetNodeSettingAsInt(settingName types.SettingName, nodeName string) (int64, error) {
+	overridesSetting, err := s.GetSetting(types.SettingNameNodeGroupSettingOverrides)
+	if err != nil {
+		return -1, err
+	}
+	overrides, err := types.UnmarshalNodeGroupSettingOverrides(overridesSetting.Value)
+	if err != nil {
+		return -1, err
+	}
+	if len(overrides) == 0 {
+		return s.GetSettingAsInt(settingName)
+	}
+
+	kubeNode, err := s.GetKubernetesNode(nodeName)
+	if err != nil {
+		if ErrorIsNotFound(err) {
+			return s.GetSettingAsInt(settingName)
+		}
+		return -1, err
+	}
+	value, ok := types.GetNodeGroupSettingOverride(overrides, kubeNode.Labels, settingName)
+	if !ok {
+		return s.GetSettingAsInt(settingName)
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
 // GetSettingAsBool gets the setting for the given name, returns as boolean
 // Returns error if the definition type is not boolean
 func (s *DataStore) GetSettingAsBool(settingName types.SettingName) (bool, error) {
@@ -3571,6 +3797,18 @@ func (s *DataStore) GetSnapshot(name string) (*longhorn.Snapshot, error) {
 	return resultRO.DeepCopy(), nil
 }
 
+// UpdateSnapshot updates the given Longhorn snapshot and verifies update
+func (s *DataStore) UpdateSnapshot(snap *longhorn.Snapshot) (*longhorn.Snapshot, error) {
+	obj, err := s.lhClient.LonghornV1beta2().Snapshots(s.namespace).Update(context.TODO(), snap, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	verifyUpdate(snap.Name, obj, func(name string) (runtime.Object, error) {
+		return s.GetSnapshotRO(name)
+	})
+	return obj, nil
+}
+
 // UpdateSnapshotStatus updates the given Longhorn snapshot status verifies update
 func (s *DataStore) UpdateSnapshotStatus(snap *longhorn.Snapshot) (*longhorn.Snapshot, error) {
 	obj, err := s.lhClient.LonghornV1beta2().Snapshots(s.namespace).UpdateStatus(context.TODO(), snap, metav1.UpdateOptions{})
@@ -3761,8 +3999,8 @@ func ValidateRecurringJob(job longhorn.RecurringJobSpec) error {
 	if job.Concurrency == 0 {
 		job.Concurrency = types.DefaultRecurringJobConcurrency
 	}
-	if _, err := cron.ParseStandard(job.Cron); err != nil {
-		return fmt.Errorf("invalid cron format(%v): %v", job.Cron, err)
+	if _, err := util.ParseCronSchedule(job.Cron); err != nil {
+		return err
 	}
 	if len(job.Name) > NameMaximumLength {
 		return fmt.Errorf("job name %v must be %v characters or less", job.Name, NameMaximumLength)
@@ -3786,7 +4024,8 @@ func isValidRecurringJobTask(task longhorn.RecurringJobType) bool {
 		task == longhorn.RecurringJobTypeSnapshot ||
 		task == longhorn.RecurringJobTypeSnapshotForceCreate ||
 		task == longhorn.RecurringJobTypeSnapshotCleanup ||
-		task == longhorn.RecurringJobTypeSnapshotDelete
+		task == longhorn.RecurringJobTypeSnapshotDelete ||
+		task == longhorn.RecurringJobTypeFilesystemTrim
 }
 
 func ValidateRecurringJobs(jobs []longhorn.RecurringJobSpec) error {
@@ -0,0 +1,237 @@
+package datastore_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+const (
+	testNamespace = "longhorn-system"
+
+	testNodeUp          = "node-up"
+	testNodeDown        = "node-down"
+	testDiskSchedulable = "disk-schedulable"
+	testDiskDisabled    = "disk-disabled"
+)
+
+func newTestNode(name string, ready bool) *longhorn.Node {
+	readyCondition := longhorn.Condition{
+		Type:   longhorn.NodeConditionTypeReady,
+		Status: longhorn.ConditionStatusTrue,
+	}
+	if !ready {
+		readyCondition.Status = longhorn.ConditionStatusFalse
+		readyCondition.Reason = string(longhorn.NodeConditionReasonKubernetesNodeNotReady)
+	}
+	return &longhorn.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+		},
+		Status: longhorn.NodeStatus{
+			Conditions: []longhorn.Condition{readyCondition},
+			DiskStatus: map[string]*longhorn.DiskStatus{
+				"disk-1": {
+					DiskUUID: testDiskSchedulable,
+					Conditions: []longhorn.Condition{
+						{Type: longhorn.DiskConditionTypeSchedulable, Status: longhorn.ConditionStatusTrue},
+					},
+				},
+				"disk-2": {
+					DiskUUID: testDiskDisabled,
+					Conditions: []longhorn.Condition{
+						{Type: longhorn.DiskConditionTypeSchedulable, Status: longhorn.ConditionStatusFalse},
+					},
+				},
+			},
+		},
+	}
+}
+
+type testReplica struct {
+	nodeID    string
+	diskID    string
+	healthyAt string
+	failedAt  string
+}
+
+func TestGetSalvageableReplicas(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	c, err := fake.NewCluster(testNamespace, stopCh, newTestNode(testNodeUp, true), newTestNode(testNodeDown, false))
+	require.NoError(t, err)
+
+	now := time.Now()
+	ago := func(d time.Duration) string {
+		return now.Add(-d).UTC().Format(time.RFC3339)
+	}
+	healthyAt := ago(time.Hour)
+
+	tests := map[string]struct {
+		replicas           map[string]testReplica
+		expectedReplicas   []string
+		expectedDataExists bool
+	}{
+		"no replica": {
+			replicas: map[string]testReplica{},
+		},
+		"never healthy": {
+			replicas: map[string]testReplica{
+				"r1": {testNodeUp, testDiskSchedulable, "", ago(time.Minute)},
+			},
+		},
+		"failed within the time limit": {
+			replicas: map[string]testReplica{
+				"r1": {testNodeUp, testDiskSchedulable, healthyAt, ago(time.Minute)},
+				"r2": {testNodeUp, testDiskSchedulable, healthyAt, ago(time.Minute + types.AutoSalvageTimeLimit/2)},
+			},
+			expectedReplicas:   []string{"r1", "r2"},
+			expectedDataExists: true,
+		},
+		"failed before the time limit of the last failure": {
+			replicas: map[string]testReplica{
+				"r1": {testNodeUp, testDiskSchedulable, healthyAt, ago(time.Minute)},
+				"r2": {testNodeUp, testDiskSchedulable, healthyAt, ago(time.Minute + 2*types.AutoSalvageTimeLimit)},
+			},
+			expectedReplicas:   []string{"r1"},
+			expectedDataExists: true,
+		},
+		"not failed": {
+			replicas: map[string]testReplica{
+				"r1": {testNodeUp, testDiskSchedulable, healthyAt, ""},
+			},
+			expectedDataExists: true,
+		},
+		"node down": {
+			replicas: map[string]testReplica{
+				"r1": {testNodeDown, testDiskSchedulable, healthyAt, ago(time.Minute)},
+				"r2": {testNodeUp, testDiskSchedulable, healthyAt, ago(time.Hour)},
+			},
+			// The replica on the down node doesn't count as the last failure
+			expectedReplicas:   []string{"r2"},
+			expectedDataExists: true,
+		},
+		"node deleted": {
+			replicas: map[string]testReplica{
+				"r1": {"node-deleted", testDiskSchedulable, healthyAt, ago(time.Minute)},
+			},
+			expectedDataExists: true,
+		},
+		"disk not schedulable": {
+			replicas: map[string]testReplica{
+				"r1": {testNodeUp, testDiskDisabled, healthyAt, ago(time.Minute)},
+			},
+			expectedDataExists: true,
+		},
+		"disk missing": {
+			replicas: map[string]testReplica{
+				"r1": {testNodeUp, "disk-missing", healthyAt, ago(time.Minute)},
+				"r2": {testNodeUp, "", healthyAt, ago(time.Minute)},
+			},
+			expectedDataExists: true,
+		},
+		"invalid failure time": {
+			replicas: map[string]testReplica{
+				"r1": {testNodeUp, testDiskSchedulable, healthyAt, "yesterday"},
+			},
+			expectedDataExists: true,
+		},
+	}
+	for name, test := range tests {
+		rs := map[string]*longhorn.Replica{}
+		for replicaName, r := range test.replicas {
+			replica := &longhorn.Replica{}
+			replica.Name = replicaName
+			replica.Spec.NodeID = r.nodeID
+			replica.Spec.DiskID = r.diskID
+			replica.Spec.HealthyAt = r.healthyAt
+			replica.Spec.FailedAt = r.failedAt
+			rs[replicaName] = replica
+		}
+
+		salvageable, dataExists := c.DataStore.GetSalvageableReplicas(rs, types.AutoSalvageTimeLimit)
+		require.Equal(t, test.expectedDataExists, dataExists, name)
+		names := []string{}
+		for replicaName := range salvageable {
+			names = append(names, replicaName)
+		}
+		require.ElementsMatch(t, test.expectedReplicas, names, name)
+	}
+}
+
+func newTenantVolume(name, tenant string, size int64) *longhorn.Volume {
+	v := &longhorn.Volume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+		},
+		Spec: longhorn.VolumeSpec{Size: size},
+	}
+	if tenant != "" {
+		v.Labels = map[string]string{types.GetLonghornLabelKey(types.LonghornLabelTenant): tenant}
+	}
+	return v
+}
+
+func TestCheckTenantQuota(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	c, err := fake.NewCluster(testNamespace, stopCh,
+		&longhorn.Setting{
+			ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameTenantQuotas), Namespace: testNamespace},
+			Value:      "team-a:volumes=2,size=3Gi;team-b:size=1Gi",
+		},
+		newTenantVolume("vol-a-1", "team-a", 1<<30),
+		newTenantVolume("vol-b-1", "team-b", 1<<29),
+		newTenantVolume("vol-untenanted", "", 10<<30),
+	)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		tenant      string
+		volumeName  string
+		size        int64
+		expectedErr bool
+	}{
+		"no tenant":               {"", "vol-new", 100 << 30, false},
+		"tenant without quota":    {"team-c", "vol-new", 100 << 30, false},
+		"within quota":            {"team-a", "vol-new", 2 << 30, false},
+		"size over quota":         {"team-a", "vol-new", 2<<30 + 1, true},
+		"expansion within quota":  {"team-b", "vol-b-1", 1 << 30, false},
+		"expansion over quota":    {"team-b", "vol-b-1", 1<<30 + 1, true},
+		"second volume of tenant": {"team-b", "vol-new", 1 << 29, false},
+	}
+	for name, test := range tests {
+		err := c.DataStore.CheckTenantQuota(test.tenant, test.volumeName, test.size)
+		if !test.expectedErr {
+			require.NoError(t, err, name)
+			continue
+		}
+		require.Error(t, err, name)
+		require.Equal(t, types.ErrorReasonQuotaExceeded, types.GetReasonError(err).Reason, name)
+	}
+
+	// The volume count is checked against the other volumes of the tenant
+	_, err = c.LonghornClient.LonghornV1beta2().Volumes(testNamespace).Create(context.TODO(), newTenantVolume("vol-a-2", "team-a", 1<<29), metav1.CreateOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		volumes, err := c.DataStore.ListTenantVolumesRO("team-a")
+		return err == nil && len(volumes) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	err = c.DataStore.CheckTenantQuota("team-a", "vol-new", 1)
+	require.Error(t, err)
+	require.Equal(t, types.ErrorReasonQuotaExceeded, types.GetReasonError(err).Reason)
+	require.NoError(t, c.DataStore.CheckTenantQuota("team-a", "vol-a-2", 1<<29))
+}
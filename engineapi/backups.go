@@ -63,6 +63,10 @@ func NewBackupTargetClientFromBackupTarget(backupTarget *longhorn.BackupTarget,
 		}
 	}
 
+	if err := ds.CheckAirGappedBackupTarget(backupType, credential); err != nil {
+		return nil, err
+	}
+
 	return NewBackupTargetClient(defaultEngineImage, backupTarget.Spec.BackupTargetURL, credential), nil
 }
 
@@ -320,7 +324,7 @@ func (e *EngineBinary) SnapshotBackup(engine *longhorn.Engine, snapName, backupN
 			args = append(args, "--backing-image-checksum", backingImageChecksum)
 		}
 	}
-	if backupName != "" && version.ClientVersion.CLIAPIVersion > CLIVersionFour {
+	if backupName != "" && IsCLIFeatureSupported(EngineFeatureBackupName, version.ClientVersion.CLIAPIVersion) {
 		args = append(args, "--backup-name", backupName)
 	}
 	for k, v := range labels {
@@ -1,6 +1,7 @@
 package engineapi
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"path/filepath"
@@ -34,9 +35,20 @@ type EngineBinary struct {
 	ip    string
 	port  int
 	cURL  string
+
+	// ctx stops the engine binary calls with the default timeout, if set
+	ctx context.Context
+}
+
+func (c *EngineCollection) NewEngineClient(request *EngineClientRequest) (EngineClientProxy, error) {
+	client, err := newEngineBinary(request)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
 }
 
-func (c *EngineCollection) NewEngineClient(request *EngineClientRequest) (*EngineBinary, error) {
+func newEngineBinary(request *EngineClientRequest) (*EngineBinary, error) {
 	if request.EngineImage == "" {
 		return nil, fmt.Errorf("invalid empty engine image from request")
 	}
@@ -62,9 +74,24 @@ func (e *EngineBinary) LonghornEngineBinary() string {
 	return filepath.Join(types.GetEngineBinaryDirectoryOnHostForImage(e.image), "longhorn")
 }
 
+// WithContext returns a copy of the client whose engine binary calls with
+// the default timeout are killed once the context is done.
+func (e *EngineBinary) WithContext(ctx context.Context) *EngineBinary {
+	client := *e
+	client.ctx = ctx
+	return &client
+}
+
+func (e *EngineBinary) context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
 func (e *EngineBinary) ExecuteEngineBinary(args ...string) (string, error) {
 	args = append([]string{"--url", e.cURL}, args...)
-	return util.Execute([]string{}, e.LonghornEngineBinary(), args...)
+	return util.ExecuteWithContext(e.context(), []string{}, e.LonghornEngineBinary(), args...)
 }
 
 func (e *EngineBinary) ExecuteEngineBinaryWithTimeout(timeout time.Duration, args ...string) (string, error) {
@@ -134,13 +161,13 @@ func (e *EngineBinary) ReplicaAdd(engine *longhorn.Engine, url string, isRestore
 		return err
 	}
 
-	if version.ClientVersion.CLIAPIVersion >= 6 {
+	if IsCLIFeatureSupported(EngineFeatureReplicaExpansion, version.ClientVersion.CLIAPIVersion) {
 		cmd = append(cmd,
 			"--size", strconv.FormatInt(engine.Spec.VolumeSize, 10),
 			"--current-size", strconv.FormatInt(engine.Status.CurrentSize, 10))
 	}
 
-	if version.ClientVersion.CLIAPIVersion >= 7 {
+	if IsCLIFeatureSupported(EngineFeatureReplicaFastSync, version.ClientVersion.CLIAPIVersion) {
 		cmd = append(cmd, "--file-sync-http-client-timeout", strconv.FormatInt(replicaFileSyncHTTPClientTimeout, 10))
 
 		if fastSync {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/longhorn/longhorn-manager/datastore"
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
 )
 
 func GetEngineBinaryClient(ds *datastore.DataStore, volumeName, nodeID string) (client *EngineBinary, err error) {
@@ -20,26 +21,28 @@ func GetEngineBinaryClient(ds *datastore.DataStore, volumeName, nodeID string) (
 		return nil, err
 	}
 	if len(es) == 0 {
-		return nil, fmt.Errorf("cannot find engine")
+		return nil, types.NewReasonError(types.ErrorReasonNotFound, map[string]string{types.ErrorParameterKind: "engine"}, "cannot find engine")
 	}
 	if len(es) != 1 {
-		return nil, fmt.Errorf("more than one engine exists")
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, nil, "more than one engine exists")
 	}
 	for _, e = range es {
 		break
 	}
 	if e.Status.CurrentState != longhorn.InstanceStateRunning {
-		return nil, fmt.Errorf("engine is not running")
+		return nil, types.NewReasonError(types.ErrorReasonEngineUnavailable,
+			map[string]string{types.ErrorParameterEngine: e.Name, types.ErrorParameterState: string(e.Status.CurrentState)},
+			"engine is not running")
 	}
 	if isReady, err := ds.CheckEngineImageReadiness(e.Status.CurrentImage, nodeID); !isReady {
 		if err != nil {
 			return nil, fmt.Errorf("cannot get engine client with image %v: %v", e.Status.CurrentImage, err)
 		}
-		return nil, fmt.Errorf("cannot get engine client with image %v because it isn't deployed on this node", e.Status.CurrentImage)
+		return nil, types.NewReasonError(types.ErrorReasonEngineUnavailable, map[string]string{types.ErrorParameterEngine: e.Name},
+			"cannot get engine client with image %v because it isn't deployed on this node", e.Status.CurrentImage)
 	}
 
-	engineCollection := &EngineCollection{}
-	return engineCollection.NewEngineClient(&EngineClientRequest{
+	return newEngineBinary(&EngineClientRequest{
 		VolumeName:  e.Spec.VolumeName,
 		EngineImage: e.Status.CurrentImage,
 		IP:          e.Status.IP,
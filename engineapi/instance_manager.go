@@ -150,7 +150,7 @@ func (c *InstanceManagerClient) parseProcess(p *imapi.Process) *longhorn.Instanc
 }
 
 func (c *InstanceManagerClient) EngineProcessCreate(e *longhorn.Engine, volumeFrontend longhorn.VolumeFrontend,
-	engineReplicaTimeout, replicaFileSyncHTTPClientTimeout int64, dataLocality longhorn.DataLocality, engineCLIAPIVersion int) (*longhorn.InstanceProcess, error) {
+	engineReplicaTimeout, replicaFileSyncHTTPClientTimeout int64, dataLocality longhorn.DataLocality, qos EngineQoS, engineCLIAPIVersion int) (*longhorn.InstanceProcess, error) {
 	if err := CheckInstanceManagerCompatibility(c.apiMinVersion, c.apiVersion); err != nil {
 		return nil, err
 	}
@@ -190,6 +190,28 @@ func (c *InstanceManagerClient) EngineProcessCreate(e *longhorn.Engine, volumeFr
 		}
 	}
 
+	// The engine without QoS runs without limits, rather than failing to start
+	if IsCLIFeatureSupported(EngineFeatureQoS, engineCLIAPIVersion) {
+		if qos.RebuildBandwidthLimit > 0 {
+			args = append(args, "--rebuild-bandwidth-limit", strconv.FormatInt(qos.RebuildBandwidthLimit, 10))
+		}
+		if qos.FrontendIOPSLimit > 0 {
+			args = append(args, "--frontend-iops-limit", strconv.FormatInt(qos.FrontendIOPSLimit, 10))
+		}
+		if qos.FrontendBandwidthLimit > 0 {
+			args = append(args, "--frontend-bandwidth-limit", strconv.FormatInt(qos.FrontendBandwidthLimit, 10))
+		}
+	}
+
+	// Unlike QoS, the engine must not start writable if it's requested to
+	// be read-only
+	if e.Spec.ReadOnly {
+		if err := CheckCLIFeatureSupport(EngineFeatureFrontendReadOnly, engineCLIAPIVersion); err != nil {
+			return nil, err
+		}
+		args = append(args, "--frontend-read-only")
+	}
+
 	for _, addr := range e.Status.CurrentReplicaAddressMap {
 		args = append(args, "--replica", GetBackendReplicaURL(addr))
 	}
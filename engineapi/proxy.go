@@ -1,15 +1,22 @@
 package engineapi
 
 import (
+	"sync"
+	"time"
+
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	imclient "github.com/longhorn/longhorn-instance-manager/pkg/client"
 	imutil "github.com/longhorn/longhorn-instance-manager/pkg/util"
 
 	"github.com/longhorn/longhorn-manager/datastore"
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
 	"github.com/longhorn/longhorn-manager/util"
 )
 
@@ -24,6 +31,13 @@ func getLoggerForEngineProxyClient(logger logrus.FieldLogger, im *longhorn.Insta
 }
 
 func GetCompatibleClient(e *longhorn.Engine, fallBack interface{}, ds *datastore.DataStore, logger logrus.FieldLogger, proxyConnCounter util.Counter) (c EngineClientProxy, err error) {
+	return GetCompatibleClientWithContext(context.Background(), e, fallBack, ds, logger, proxyConnCounter)
+}
+
+// GetCompatibleClientWithContext is GetCompatibleClient for the calls of a
+// request. The calls stop once the context is done, and carry the request ID
+// in the context to the instance manager, if any.
+func GetCompatibleClientWithContext(ctx context.Context, e *longhorn.Engine, fallBack interface{}, ds *datastore.DataStore, logger logrus.FieldLogger, proxyConnCounter util.Counter) (c EngineClientProxy, err error) {
 	if e == nil {
 		return nil, errors.Errorf("BUG: failed to get engine client proxy due to missing engine")
 	}
@@ -52,53 +66,266 @@ func GetCompatibleClient(e *longhorn.Engine, fallBack interface{}, ds *datastore
 			return nil, errors.Errorf("missing engine client proxy fallback client")
 		}
 
-		if obj, ok := fallBack.(*EngineBinary); ok {
-			return obj, nil
+		if obj, ok := fallBack.(EngineClientProxy); ok {
+			return WithContext(ctx, obj), nil
 		}
 
 		return nil, errors.Errorf("BUG: invalid engine client proxy fallback client: %v", fallBack)
 	}
 
-	return NewEngineClientProxy(im, log, proxyConnCounter)
+	return NewEngineClientProxyWithContext(ctx, im, log, proxyConnCounter)
 }
 
 func NewEngineClientProxy(im *longhorn.InstanceManager, logger logrus.FieldLogger, proxyConnCounter util.Counter) (c EngineClientProxy, err error) {
+	return NewEngineClientProxyWithContext(context.Background(), im, logger, proxyConnCounter)
+}
+
+// NewEngineClientProxyWithContext returns the client whose calls stop once
+// the context is done. If the context carries a request ID, the client dials
+// its own connection to send the ID with each call, instead of sharing one
+// from the pool, since the metadata of the calls is fixed per connection by
+// the instance manager client.
+func NewEngineClientProxyWithContext(ctx context.Context, im *longhorn.InstanceManager, logger logrus.FieldLogger, proxyConnCounter util.Counter) (c EngineClientProxy, err error) {
 	defer func() {
 		err = errors.Wrap(err, "failed to get engine client proxy")
 	}()
 
 	isInstanceManagerRunning := im.Status.CurrentState == longhorn.InstanceManagerStateRunning
 	if !isInstanceManagerRunning {
-		err = errors.Errorf("%v instance manager is in %v, not running state", im.Name, im.Status.CurrentState)
+		err = types.NewReasonError(types.ErrorReasonEngineUnavailable, map[string]string{types.ErrorParameterName: im.Name, types.ErrorParameterState: string(im.Status.CurrentState)},
+			"%v instance manager is in %v, not running state", im.Name, im.Status.CurrentState)
 		return nil, err
 	}
 
 	hasIP := im.Status.IP != ""
 	if !hasIP {
-		err = errors.Errorf("%v instance manager status IP is missing", im.Name)
+		err = types.NewReasonError(types.ErrorReasonEngineUnavailable, map[string]string{types.ErrorParameterName: im.Name},
+			"%v instance manager status IP is missing", im.Name)
 		return nil, err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	client, err := imclient.NewProxyClient(ctx, cancel, im.Status.IP, InstanceManagerProxyDefaultPort)
+	if requestID := util.GetRequestID(ctx); requestID != "" {
+		client, err := newProxyClientWithRequestID(im.Status.IP, InstanceManagerProxyDefaultPort, requestID)
+		if err != nil {
+			return nil, err
+		}
+		if proxyConnCounter != nil {
+			proxyConnCounter.IncreaseCount()
+		}
+		return &Proxy{
+			logger:           util.WithRequestIDField(ctx, logger),
+			grpcClient:       client,
+			dedicated:        true,
+			proxyConnCounter: proxyConnCounter,
+			ctx:              ctx,
+		}, nil
+	}
+
+	client, err := proxyConnections.acquire(im, proxyConnCounter)
 	if err != nil {
 		return nil, err
 	}
 
-	proxyConnCounter.IncreaseCount()
-
 	return &Proxy{
 		logger:           logger,
 		grpcClient:       client,
 		proxyConnCounter: proxyConnCounter,
+		ctx:              ctx,
 	}, nil
 }
 
+// newProxyClientWithRequestID dials the proxy client whose calls carry the
+// request ID in the gRPC metadata.
+func newProxyClientWithRequestID(address string, port int, requestID string) (*imclient.ProxyClient, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = metadata.AppendToOutgoingContext(ctx, util.RequestIDMetadataKey, requestID)
+	client, err := imclient.NewProxyClient(ctx, cancel, address, port)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return client, nil
+}
+
 type Proxy struct {
 	logger     logrus.FieldLogger
 	grpcClient *imclient.ProxyClient
+	// dedicated is set if the gRPC client isn't shared from the pool, so it's
+	// closed with the proxy
+	dedicated bool
 
 	proxyConnCounter util.Counter
+
+	// ctx stops the retries of the calls, if set
+	ctx context.Context
+}
+
+// WithContext returns a copy of the client which stops retrying the calls
+// once the context is done. The gRPC calls already sent are bounded by the
+// deadline of the proxy client instead.
+func (p *Proxy) WithContext(ctx context.Context) *Proxy {
+	client := *p
+	client.ctx = ctx
+	return &client
+}
+
+func (p *Proxy) context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// WithContext returns a copy of the engine client whose calls stop once the
+// context is done, as far as the client can stop them.
+func WithContext(ctx context.Context, c EngineClientProxy) EngineClientProxy {
+	switch client := c.(type) {
+	case *EngineBinary:
+		return client.WithContext(ctx)
+	case *Proxy:
+		return client.WithContext(ctx)
+	}
+	return c
+}
+
+const (
+	// proxyConnectionIdleTimeout is how long an unused connection to an
+	// instance manager proxy is kept for reuse.
+	proxyConnectionIdleTimeout = 1 * time.Minute
+	// proxyConnectionReapInterval is how often the idle connections are
+	// closed.
+	proxyConnectionReapInterval = 30 * time.Second
+
+	proxyRetryCount    = 3
+	proxyRetryInterval = 500 * time.Millisecond
+)
+
+type proxyConnection struct {
+	client   *imclient.ProxyClient
+	refCount int
+	lastUsed time.Time
+}
+
+// proxyConnectionPool shares the gRPC connections to the instance manager
+// proxies, so that the frequent engine operations do not dial a new
+// connection for every call. The connections unused for the idle timeout are
+// closed by a reaper in the background.
+type proxyConnectionPool struct {
+	lock        sync.Mutex
+	connections map[string]*proxyConnection
+	reaperOnce  sync.Once
+
+	// for unit test
+	now          func() time.Time
+	reapInterval time.Duration
+}
+
+var proxyConnections = newProxyConnectionPool()
+
+func newProxyConnectionPool() *proxyConnectionPool {
+	return &proxyConnectionPool{
+		connections:  map[string]*proxyConnection{},
+		now:          time.Now,
+		reapInterval: proxyConnectionReapInterval,
+	}
+}
+
+// acquire returns the connection to the proxy of the instance manager, and
+// counts it as in use by the caller in proxyConnCounter until it's released.
+func (pool *proxyConnectionPool) acquire(im *longhorn.InstanceManager, proxyConnCounter util.Counter) (*imclient.ProxyClient, error) {
+	pool.reaperOnce.Do(func() {
+		go pool.reapIdleConnections()
+	})
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	key := im.Name + "/" + im.Status.IP
+	conn, ok := pool.connections[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		client, err := imclient.NewProxyClient(ctx, cancel, im.Status.IP, InstanceManagerProxyDefaultPort)
+		if err != nil {
+			return nil, err
+		}
+		conn = &proxyConnection{
+			client: client,
+		}
+		pool.connections[key] = conn
+	}
+	conn.refCount++
+	conn.lastUsed = pool.now()
+	if proxyConnCounter != nil {
+		proxyConnCounter.IncreaseCount()
+	}
+	return conn.client, nil
+}
+
+// release returns the connection to the pool, and stops counting it as in
+// use by the caller in proxyConnCounter.
+func (pool *proxyConnectionPool) release(client *imclient.ProxyClient, proxyConnCounter util.Counter) error {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	for _, conn := range pool.connections {
+		if conn.client == client {
+			conn.refCount--
+			conn.lastUsed = pool.now()
+			if proxyConnCounter != nil {
+				proxyConnCounter.DecreaseCount()
+			}
+			return nil
+		}
+	}
+	return errors.Errorf("BUG: cannot find engine client proxy connection %v in the pool", client.ServiceURL)
+}
+
+func (pool *proxyConnectionPool) reapIdleConnections() {
+	ticker := time.NewTicker(pool.reapInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		pool.lock.Lock()
+		pool.closeIdleConnections()
+		pool.lock.Unlock()
+	}
+}
+
+// closeIdleConnections must be called with the lock held.
+func (pool *proxyConnectionPool) closeIdleConnections() {
+	for key, conn := range pool.connections {
+		if conn.refCount > 0 || pool.now().Sub(conn.lastUsed) < proxyConnectionIdleTimeout {
+			continue
+		}
+		// The only potential returning error from Close() is
+		// "grpc: the client connection is closing", so the connection is
+		// dropped from the pool anyway.
+		if err := conn.client.Close(); err != nil {
+			logrus.WithError(err).Warnf("Failed to close idle engine client proxy connection %v", conn.client.ServiceURL)
+		}
+		delete(pool.connections, key)
+	}
+}
+
+// retry calls f again when the proxy is transiently unavailable. It should
+// only be used for idempotent calls.
+func (p *Proxy) retry(f func() error) (err error) {
+	ctx := p.context()
+	for i := 0; i < proxyRetryCount; i++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return errors.Wrap(ctxErr, "engine client proxy call is canceled")
+		}
+		if err = f(); err == nil || status.Code(errors.Cause(err)) != codes.Unavailable {
+			return err
+		}
+		if i < proxyRetryCount-1 {
+			p.logger.WithError(err).Debugf("Retrying engine client proxy call")
+			select {
+			case <-time.After(proxyRetryInterval):
+			case <-ctx.Done():
+			}
+		}
+	}
+	return types.NewReasonError(types.ErrorReasonEngineUnavailable, nil, "engine client proxy is unavailable: %v", err)
 }
 
 type EngineClientProxy interface {
@@ -113,14 +340,19 @@ func (p *Proxy) Close() {
 		return
 	}
 
-	if err := p.grpcClient.Close(); err != nil {
-		p.logger.WithError(err).Warn("failed to close engine client proxy")
+	if p.dedicated {
+		if err := p.grpcClient.Close(); err != nil {
+			p.logger.WithError(err).Warn("failed to close engine client proxy")
+		}
+		if p.proxyConnCounter != nil {
+			p.proxyConnCounter.DecreaseCount()
+		}
+		return
 	}
 
-	// The only potential returning error from Close() is
-	// "grpc: the client connection is closing". This means we should still
-	// decrease the connection count.
-	p.proxyConnCounter.DecreaseCount()
+	if err := proxyConnections.release(p.grpcClient, p.proxyConnCounter); err != nil {
+		p.logger.WithError(err).Warn("failed to close engine client proxy")
+	}
 }
 
 func (p *Proxy) DirectToURL(e *longhorn.Engine) string {
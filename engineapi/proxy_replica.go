@@ -1,6 +1,9 @@
 package engineapi
 
 import (
+	etypes "github.com/longhorn/longhorn-engine/pkg/types"
+	imclient "github.com/longhorn/longhorn-instance-manager/pkg/client"
+
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 )
 
@@ -13,7 +16,11 @@ func (p *Proxy) ReplicaRemove(e *longhorn.Engine, address string) (err error) {
 }
 
 func (p *Proxy) ReplicaList(e *longhorn.Engine) (replicas map[string]*Replica, err error) {
-	resp, err := p.grpcClient.ReplicaList(p.DirectToURL(e))
+	var resp []*etypes.ControllerReplicaInfo
+	err = p.retry(func() (err error) {
+		resp, err = p.grpcClient.ReplicaList(p.DirectToURL(e))
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -33,14 +40,24 @@ func (p *Proxy) ReplicaList(e *longhorn.Engine) (replicas map[string]*Replica, e
 }
 
 func (p *Proxy) ReplicaRebuildStatus(e *longhorn.Engine) (status map[string]*longhorn.RebuildStatus, err error) {
-	recv, err := p.grpcClient.ReplicaRebuildingStatus(p.DirectToURL(e))
+	var recv map[string]*imclient.ReplicaRebuildStatus
+	err = p.retry(func() (err error) {
+		recv, err = p.grpcClient.ReplicaRebuildingStatus(p.DirectToURL(e))
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	status = make(map[string]*longhorn.RebuildStatus)
 	for k, v := range recv {
-		status[k] = (*longhorn.RebuildStatus)(v)
+		status[k] = &longhorn.RebuildStatus{
+			Error:              v.Error,
+			IsRebuilding:       v.IsRebuilding,
+			Progress:           v.Progress,
+			State:              v.State,
+			FromReplicaAddress: v.FromReplicaAddress,
+		}
 	}
 	return status, nil
 }
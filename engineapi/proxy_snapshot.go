@@ -1,6 +1,8 @@
 package engineapi
 
 import (
+	etypes "github.com/longhorn/longhorn-engine/pkg/types"
+
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 )
 
@@ -9,7 +11,11 @@ func (p *Proxy) SnapshotCreate(e *longhorn.Engine, name string, labels map[strin
 }
 
 func (p *Proxy) SnapshotList(e *longhorn.Engine) (snapshots map[string]*longhorn.SnapshotInfo, err error) {
-	recv, err := p.grpcClient.SnapshotList(p.DirectToURL(e))
+	var recv map[string]*etypes.DiskInfo
+	err = p.retry(func() (err error) {
+		recv, err = p.grpcClient.SnapshotList(p.DirectToURL(e))
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
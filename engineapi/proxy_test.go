@@ -0,0 +1,241 @@
+package engineapi
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	imclient "github.com/longhorn/longhorn-instance-manager/pkg/client"
+	imrpc "github.com/longhorn/longhorn-instance-manager/pkg/imrpc"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+func newTestInstanceManager(name, ip string) *longhorn.InstanceManager {
+	im := &longhorn.InstanceManager{}
+	im.Name = name
+	im.Status.CurrentState = longhorn.InstanceManagerStateRunning
+	im.Status.IP = ip
+	return im
+}
+
+func TestProxyRetry(t *testing.T) {
+	assert := require.New(t)
+
+	p := &Proxy{logger: logrus.StandardLogger()}
+
+	calls := 0
+	err := p.retry(func() error {
+		calls++
+		if calls < 2 {
+			return status.Error(codes.Unavailable, "connection refused")
+		}
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(2, calls)
+
+	calls = 0
+	err = p.retry(func() error {
+		calls++
+		return errors.Wrap(status.Error(codes.Unavailable, "connection refused"), "failed to list snapshots")
+	})
+	assert.Equal(proxyRetryCount, calls)
+	reasonErr := types.GetReasonError(err)
+	assert.NotNil(reasonErr)
+	assert.Equal(types.ErrorReasonEngineUnavailable, reasonErr.Reason)
+
+	// The other errors are not retried
+	calls = 0
+	err = p.retry(func() error {
+		calls++
+		return status.Error(codes.NotFound, "snapshot not found")
+	})
+	assert.Equal(1, calls)
+	assert.Nil(types.GetReasonError(err))
+}
+
+func TestNewEngineClientProxyUnavailable(t *testing.T) {
+	assert := require.New(t)
+
+	im := &longhorn.InstanceManager{}
+	im.Name = "instance-manager-e"
+	im.Status.CurrentState = longhorn.InstanceManagerStateStarting
+	_, err := NewEngineClientProxy(im, logrus.StandardLogger(), nil)
+	reasonErr := types.GetReasonError(err)
+	assert.NotNil(reasonErr)
+	assert.Equal(types.ErrorReasonEngineUnavailable, reasonErr.Reason)
+	assert.Equal(string(longhorn.InstanceManagerStateStarting), reasonErr.Parameters[types.ErrorParameterState])
+}
+
+func TestProxyConnectionPool(t *testing.T) {
+	assert := require.New(t)
+
+	now := time.Now()
+	pool := newProxyConnectionPool()
+	pool.now = func() time.Time { return now }
+
+	// The connection is shared, and counted in the counter of each caller
+	im := newTestInstanceManager("instance-manager-e-1", "127.0.0.1")
+	counter1, counter2 := util.NewAtomicCounter(), util.NewAtomicCounter()
+	client1, err := pool.acquire(im, counter1)
+	assert.Nil(err)
+	client2, err := pool.acquire(im, counter2)
+	assert.Nil(err)
+	assert.Equal(client1, client2)
+	assert.Len(pool.connections, 1)
+	assert.Equal(int32(1), counter1.GetCount())
+	assert.Equal(int32(1), counter2.GetCount())
+
+	// The instance manager with a new IP gets a new connection
+	client3, err := pool.acquire(newTestInstanceManager("instance-manager-e-1", "127.0.0.2"), counter1)
+	assert.Nil(err)
+	assert.NotEqual(client1, client3)
+	assert.Len(pool.connections, 2)
+	assert.Equal(int32(2), counter1.GetCount())
+
+	assert.Nil(pool.release(client1, counter1))
+	assert.Nil(pool.release(client3, counter1))
+	assert.Equal(int32(0), counter1.GetCount())
+	assert.Equal(int32(1), counter2.GetCount())
+
+	// The connections in use are kept, and the unused ones are kept until
+	// the idle timeout
+	now = now.Add(proxyConnectionIdleTimeout)
+	pool.closeIdleConnections()
+	assert.Len(pool.connections, 1)
+	assert.Nil(pool.release(client2, counter2))
+	assert.Equal(int32(0), counter2.GetCount())
+	pool.closeIdleConnections()
+	assert.Len(pool.connections, 1)
+	now = now.Add(proxyConnectionIdleTimeout)
+	pool.closeIdleConnections()
+	assert.Len(pool.connections, 0)
+
+	assert.NotNil(pool.release(client1, counter1))
+}
+
+func TestProxyConnectionPoolReaper(t *testing.T) {
+	assert := require.New(t)
+
+	now := time.Now()
+	nowLock := sync.Mutex{}
+	pool := newProxyConnectionPool()
+	pool.now = func() time.Time {
+		nowLock.Lock()
+		defer nowLock.Unlock()
+		return now
+	}
+	pool.reapInterval = 10 * time.Millisecond
+
+	client, err := pool.acquire(newTestInstanceManager("instance-manager-e-1", "127.0.0.1"), nil)
+	assert.Nil(err)
+	assert.Nil(pool.release(client, nil))
+
+	// The idle connection is closed without any new caller
+	nowLock.Lock()
+	now = now.Add(proxyConnectionIdleTimeout)
+	nowLock.Unlock()
+	assert.Eventually(func() bool {
+		pool.lock.Lock()
+		defer pool.lock.Unlock()
+		return len(pool.connections) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestProxyRetryUnavailable(t *testing.T) {
+	assert := require.New(t)
+
+	// Nothing listens on the port, so the calls fail as unavailable
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := imclient.NewProxyClient(ctx, cancel, "127.0.0.1", 1)
+	assert.Nil(err)
+	defer client.Close()
+
+	p := &Proxy{logger: logrus.StandardLogger(), grpcClient: client}
+	e := &longhorn.Engine{}
+	e.Status.StorageIP = "127.0.0.1"
+	e.Status.Port = 10000
+
+	_, err = p.SnapshotList(e)
+	reasonErr := types.GetReasonError(err)
+	assert.NotNil(reasonErr, "unexpected error %v", err)
+	assert.Equal(types.ErrorReasonEngineUnavailable, reasonErr.Reason)
+}
+
+func TestProxyRetryCanceled(t *testing.T) {
+	assert := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := imclient.NewProxyClient(ctx, cancel, "127.0.0.1", 1)
+	assert.Nil(err)
+	defer client.Close()
+
+	p := &Proxy{logger: logrus.StandardLogger(), grpcClient: client}
+	callCtx, callCancel := context.WithCancel(context.Background())
+	p = WithContext(callCtx, p).(*Proxy)
+
+	calls := 0
+	err = p.retry(func() error {
+		calls++
+		callCancel()
+		return status.Error(codes.Unavailable, "unavailable")
+	})
+	assert.ErrorIs(err, context.Canceled)
+	assert.Equal(1, calls)
+}
+
+func TestProxyRequestID(t *testing.T) {
+	assert := require.New(t)
+
+	// The proxy server records the request IDs of the calls
+	requestIDs := make(chan []string, 1)
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		requestIDs <- md.Get(util.RequestIDMetadataKey)
+		return handler(ctx, req)
+	}))
+	imrpc.RegisterProxyEngineServiceServer(server, &imrpc.UnimplementedProxyEngineServiceServer{})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	client, err := newProxyClientWithRequestID("127.0.0.1", listener.Addr().(*net.TCPAddr).Port, "request-1")
+	assert.Nil(err)
+	counter := util.NewAtomicCounter()
+	counter.IncreaseCount()
+	p := &Proxy{
+		logger:           logrus.StandardLogger(),
+		grpcClient:       client,
+		dedicated:        true,
+		proxyConnCounter: counter,
+	}
+
+	e := &longhorn.Engine{}
+	e.Status.StorageIP = "127.0.0.1"
+	e.Status.Port = 10000
+	_, err = p.VersionGet(e, false)
+	assert.Equal(codes.Unimplemented, status.Code(errors.Cause(err)))
+	assert.Equal([]string{"request-1"}, <-requestIDs)
+
+	// The dedicated connection is closed rather than released to the pool
+	p.Close()
+	assert.Equal(int32(0), counter.GetCount())
+	_, err = p.VersionGet(e, false)
+	assert.NotNil(err)
+}
@@ -3,11 +3,17 @@ package engineapi
 import (
 	"fmt"
 
+	etypes "github.com/longhorn/longhorn-engine/pkg/types"
+
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 )
 
 func (p *Proxy) VolumeGet(e *longhorn.Engine) (volume *Volume, err error) {
-	recv, err := p.grpcClient.VolumeGet(p.DirectToURL(e))
+	var recv *etypes.VolumeInfo
+	err = p.retry(func() (err error) {
+		recv, err = p.grpcClient.VolumeGet(p.DirectToURL(e))
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
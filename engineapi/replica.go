@@ -0,0 +1,62 @@
+package engineapi
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	replicaclient "github.com/longhorn/longhorn-engine/pkg/replica/client"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+const (
+	snapshotDiskPrefix = "volume-snap-"
+	diskSuffix         = ".img"
+)
+
+// GetReplicaSnapshotChain gets the snapshot chain of the replica from the
+// replica directly, ordered from the oldest snapshot to the newest one. The
+// volume head isn't included.
+func GetReplicaSnapshotChain(address string) ([]string, error) {
+	client, err := replicaclient.NewReplicaClient(address)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	info, err := client.GetReplica()
+	if err != nil {
+		return nil, err
+	}
+	if info.Rebuilding {
+		return nil, errors.Errorf("replica %v is in the middle of a rebuilding", address)
+	}
+	if len(info.Chain) == 0 {
+		return nil, errors.Errorf("replica %v has no volume head", address)
+	}
+
+	chain := []string{}
+	for i := len(info.Chain) - 1; i >= 1; i-- {
+		name := strings.TrimSuffix(strings.TrimPrefix(info.Chain[i], snapshotDiskPrefix), diskSuffix)
+		chain = append(chain, name)
+	}
+	return chain, nil
+}
+
+// GetReplicaSnapshotChainRelation compares the snapshot chain of a rebuilding
+// replica with the one of a healthy replica, both ordered from the oldest
+// snapshot. If the rebuilding chain is a prefix of the healthy chain, the
+// replica is only behind, and the snapshots missing in it are returned.
+// Otherwise the chains diverge, e.g. by a revert or a snapshot purge.
+func GetReplicaSnapshotChainRelation(healthyChain, rebuildingChain []string) (longhorn.ReplicaSnapshotChainRelation, []string) {
+	if len(rebuildingChain) > len(healthyChain) {
+		return longhorn.ReplicaSnapshotChainRelationDiverged, nil
+	}
+	for i, snapshot := range rebuildingChain {
+		if healthyChain[i] != snapshot {
+			return longhorn.ReplicaSnapshotChainRelationDiverged, nil
+		}
+	}
+	return longhorn.ReplicaSnapshotChainRelationBehind, healthyChain[len(rebuildingChain):]
+}
@@ -0,0 +1,60 @@
+package engineapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func TestGetReplicaSnapshotChainRelation(t *testing.T) {
+	tests := []struct {
+		name             string
+		healthyChain     []string
+		rebuildingChain  []string
+		expectedRelation longhorn.ReplicaSnapshotChainRelation
+		expectedMissing  []string
+	}{
+		{
+			name:             "behind",
+			healthyChain:     []string{"snap1", "snap2", "snap3"},
+			rebuildingChain:  []string{"snap1"},
+			expectedRelation: longhorn.ReplicaSnapshotChainRelationBehind,
+			expectedMissing:  []string{"snap2", "snap3"},
+		},
+		{
+			name:             "up to date",
+			healthyChain:     []string{"snap1", "snap2"},
+			rebuildingChain:  []string{"snap1", "snap2"},
+			expectedRelation: longhorn.ReplicaSnapshotChainRelationBehind,
+			expectedMissing:  []string{},
+		},
+		{
+			name:             "empty",
+			healthyChain:     []string{"snap1"},
+			rebuildingChain:  []string{},
+			expectedRelation: longhorn.ReplicaSnapshotChainRelationBehind,
+			expectedMissing:  []string{"snap1"},
+		},
+		{
+			name:             "diverged",
+			healthyChain:     []string{"snap1", "snap3"},
+			rebuildingChain:  []string{"snap1", "snap2"},
+			expectedRelation: longhorn.ReplicaSnapshotChainRelationDiverged,
+		},
+		{
+			name:             "purged",
+			healthyChain:     []string{"snap2"},
+			rebuildingChain:  []string{"snap1", "snap2"},
+			expectedRelation: longhorn.ReplicaSnapshotChainRelationDiverged,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			relation, missing := GetReplicaSnapshotChainRelation(tc.healthyChain, tc.rebuildingChain)
+			require.Equal(t, tc.expectedRelation, relation)
+			require.Equal(t, tc.expectedMissing, missing)
+		})
+	}
+}
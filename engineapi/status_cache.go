@@ -0,0 +1,113 @@
+package engineapi
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/longhorn/longhorn-manager/metrics_collector/registry"
+)
+
+const (
+	StatusCacheCallReplicaList = "replica-list"
+	StatusCacheCallVolumeGet   = "volume-get"
+
+	statusCacheResultHit  = "hit"
+	statusCacheResultMiss = "miss"
+)
+
+var (
+	statusCacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "longhorn",
+			Subsystem: "engine_client",
+			Name:      "cache_requests_total",
+			Help:      "Number of the engine status requests served by the status cache. Broken down by call and result, which is hit or miss.",
+		},
+		[]string{"call", "result"},
+	)
+)
+
+func init() {
+	registry.Register(statusCacheRequests)
+}
+
+type statusCacheEntry struct {
+	value    interface{}
+	expireAt time.Time
+}
+
+// StatusCache caches the results of the read-only engine calls per volume, so
+// the repeated requests within the TTL don't reach the engine. The cached
+// values are shared by the callers, which must not modify them.
+type StatusCache struct {
+	mutex sync.Mutex
+	// volume name -> call -> entry
+	entries map[string]map[string]*statusCacheEntry
+
+	// for unit test
+	now func() time.Time
+}
+
+func NewStatusCache() *StatusCache {
+	return &StatusCache{
+		entries: map[string]map[string]*statusCacheEntry{},
+		now:     time.Now,
+	}
+}
+
+// Get returns the cached result of the call for the volume if it isn't
+// expired, otherwise calls fetch and caches the result for ttl. The errors
+// are not cached. A ttl of 0 disables the cache.
+func (c *StatusCache) Get(volumeName, call string, ttl time.Duration, fetch func() (interface{}, error)) (interface{}, error) {
+	if ttl <= 0 {
+		return fetch()
+	}
+
+	c.mutex.Lock()
+	if entry, ok := c.entries[volumeName][call]; ok && c.now().Before(entry.expireAt) {
+		c.mutex.Unlock()
+		statusCacheRequests.WithLabelValues(call, statusCacheResultHit).Inc()
+		return entry.value, nil
+	}
+	c.mutex.Unlock()
+	statusCacheRequests.WithLabelValues(call, statusCacheResultMiss).Inc()
+
+	value, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.entries[volumeName] == nil {
+		c.entries[volumeName] = map[string]*statusCacheEntry{}
+	}
+	c.entries[volumeName][call] = &statusCacheEntry{
+		value:    value,
+		expireAt: c.now().Add(ttl),
+	}
+	return value, nil
+}
+
+// Invalidate drops the cached results of the volume, e.g. after an operation
+// changing the engine. The expired results of the other volumes are dropped
+// as well, so the cache doesn't keep the deleted volumes.
+func (c *StatusCache) Invalidate(volumeName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, volumeName)
+	now := c.now()
+	for name, calls := range c.entries {
+		for call, entry := range calls {
+			if !now.Before(entry.expireAt) {
+				delete(calls, call)
+			}
+		}
+		if len(calls) == 0 {
+			delete(c.entries, name)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package engineapi
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusCache(t *testing.T) {
+	now := time.Now()
+	c := NewStatusCache()
+	c.now = func() time.Time { return now }
+
+	fetches := 0
+	fetch := func() (interface{}, error) {
+		fetches++
+		return fetches, nil
+	}
+
+	value, err := c.Get("vol1", StatusCacheCallVolumeGet, 5*time.Second, fetch)
+	require.NoError(t, err)
+	require.Equal(t, 1, value)
+
+	// Cached within the TTL, and separately per volume and call
+	value, err = c.Get("vol1", StatusCacheCallVolumeGet, 5*time.Second, fetch)
+	require.NoError(t, err)
+	require.Equal(t, 1, value)
+	value, err = c.Get("vol2", StatusCacheCallVolumeGet, 5*time.Second, fetch)
+	require.NoError(t, err)
+	require.Equal(t, 2, value)
+	value, err = c.Get("vol1", StatusCacheCallReplicaList, 5*time.Second, fetch)
+	require.NoError(t, err)
+	require.Equal(t, 3, value)
+
+	// Expired
+	now = now.Add(5 * time.Second)
+	value, err = c.Get("vol1", StatusCacheCallVolumeGet, 5*time.Second, fetch)
+	require.NoError(t, err)
+	require.Equal(t, 4, value)
+
+	// Invalidated, with the expired entries of the other volumes dropped
+	c.Invalidate("vol1")
+	require.Empty(t, c.entries)
+	value, err = c.Get("vol1", StatusCacheCallVolumeGet, 5*time.Second, fetch)
+	require.NoError(t, err)
+	require.Equal(t, 5, value)
+
+	// Disabled
+	value, err = c.Get("vol1", StatusCacheCallVolumeGet, 0, fetch)
+	require.NoError(t, err)
+	require.Equal(t, 6, value)
+
+	// Errors aren't cached
+	_, err = c.Get("vol3", StatusCacheCallVolumeGet, 5*time.Second, func() (interface{}, error) {
+		return nil, fmt.Errorf("failed")
+	})
+	require.Error(t, err)
+	value, err = c.Get("vol3", StatusCacheCallVolumeGet, 5*time.Second, fetch)
+	require.NoError(t, err)
+	require.Equal(t, 7, value)
+}
@@ -2,9 +2,12 @@ package engineapi
 
 import (
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
+
 	devtypes "github.com/longhorn/go-iscsi-helper/types"
 
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
@@ -18,8 +21,11 @@ const (
 	// engine.
 	MinCLIVersion = 3
 
-	CLIVersionFour = 4
-	CLIVersionFive = 5
+	CLIVersionFour  = 4
+	CLIVersionFive  = 5
+	CLIVersionSix   = 6
+	CLIVersionSeven = 7
+	CLIVersionEight = 8
 
 	InstanceManagerDefaultPort      = 8500
 	InstanceManagerProxyDefaultPort = InstanceManagerDefaultPort + 1
@@ -107,8 +113,10 @@ type EngineClientRequest struct {
 	Port        int
 }
 
+// EngineClientCollection creates the clients reaching the engine binaries.
+// The caller closes the client.
 type EngineClientCollection interface {
-	NewEngineClient(request *EngineClientRequest) (*EngineBinary, error)
+	NewEngineClient(request *EngineClientRequest) (EngineClientProxy, error)
 }
 
 type Volume struct {
@@ -235,6 +243,62 @@ func CheckCLICompatibility(cliVersion, cliMinVersion int) error {
 	return nil
 }
 
+// EngineQoS is the limits of an engine. 0 means no limit.
+type EngineQoS struct {
+	// In MiB/s
+	RebuildBandwidthLimit int64
+	FrontendIOPSLimit     int64
+	// In MiB/s
+	FrontendBandwidthLimit int64
+}
+
+// EngineFeature is an engine operation that is only available since a
+// specific CLI API version. The manager may drive engines of different
+// versions during a rolling upgrade, so such operations are checked against
+// the version of the engine image actually in use.
+type EngineFeature string
+
+const (
+	EngineFeatureBackupName                = EngineFeature("backup name")
+	EngineFeatureSnapshotClone             = EngineFeature("snapshot clone")
+	EngineFeatureBackingImageExport        = EngineFeature("backing image export")
+	EngineFeatureReplicaExpansion          = EngineFeature("replica expansion during rebuilding")
+	EngineFeatureReplicaFastSync           = EngineFeature("replica fast sync")
+	EngineFeatureUnmapMarkSnapChainRemoved = EngineFeature("unmap mark snapshot chain removed")
+	EngineFeatureSnapshotHash              = EngineFeature("snapshot hash")
+	EngineFeatureQoS                       = EngineFeature("rebuild and frontend QoS")
+	EngineFeatureFrontendReadOnly          = EngineFeature("read-only frontend")
+)
+
+var engineFeatureMinCLIVersion = map[EngineFeature]int{
+	EngineFeatureBackupName:                CLIVersionFive,
+	EngineFeatureSnapshotClone:             CLIVersionFive,
+	EngineFeatureBackingImageExport:        CLIVersionFive,
+	EngineFeatureReplicaExpansion:          CLIVersionSix,
+	EngineFeatureReplicaFastSync:           CLIVersionSeven,
+	EngineFeatureUnmapMarkSnapChainRemoved: CLIVersionSeven,
+	EngineFeatureSnapshotHash:              CLIVersionSeven,
+	EngineFeatureQoS:                       CLIVersionEight,
+	EngineFeatureFrontendReadOnly:          CLIVersionEight,
+}
+
+// CheckCLIFeatureSupport returns an error if an engine with the given CLI API
+// version cannot handle the feature.
+func CheckCLIFeatureSupport(feature EngineFeature, cliVersion int) error {
+	minVersion, ok := engineFeatureMinCLIVersion[feature]
+	if !ok {
+		return fmt.Errorf("BUG: unknown engine feature %v", feature)
+	}
+	if cliVersion < minVersion {
+		return fmt.Errorf("engine CLI API version %v does not support %v, which requires at least version %v, please upgrade the engine image", cliVersion, feature, minVersion)
+	}
+	return nil
+}
+
+func IsCLIFeatureSupported(feature EngineFeature, cliVersion int) bool {
+	return CheckCLIFeatureSupport(feature, cliVersion) == nil
+}
+
 func GetEngineProcessFrontend(volumeFrontend longhorn.VolumeFrontend) (string, error) {
 	frontend := ""
 	if volumeFrontend == longhorn.VolumeFrontendBlockDev {
@@ -271,6 +335,35 @@ func GetEngineEndpoint(volume *Volume, ip string) (string, error) {
 	return "", fmt.Errorf("unknown frontend %v", volume.Frontend)
 }
 
+type ISCSITarget struct {
+	IQN  string
+	IP   string
+	Port string
+	LUN  string
+}
+
+// ParseISCSIEndpoint parses an endpoint generated by GetEngineEndpoint, e.g.
+// iscsi://10.42.0.12:3260/iqn.2014-09.com.rancher:vol-name/1
+func ParseISCSIEndpoint(endpoint string) (*ISCSITarget, error) {
+	if !strings.HasPrefix(endpoint, EndpointISCSIPrefix) {
+		return nil, fmt.Errorf("invalid iscsi endpoint %v", endpoint)
+	}
+	parts := strings.Split(strings.TrimPrefix(endpoint, EndpointISCSIPrefix), "/")
+	if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("invalid iscsi endpoint %v", endpoint)
+	}
+	ip, port, err := net.SplitHostPort(parts[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid iscsi endpoint %v", endpoint)
+	}
+	return &ISCSITarget{
+		IQN:  parts[1],
+		IP:   ip,
+		Port: port,
+		LUN:  parts[2],
+	}, nil
+}
+
 func IsEndpointTGTBlockDev(endpoint string) bool {
 	if endpoint == "" {
 		return false
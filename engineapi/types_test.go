@@ -0,0 +1,56 @@
+package engineapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseISCSIEndpoint(t *testing.T) {
+	tests := []struct {
+		name         string
+		endpoint     string
+		expectTarget *ISCSITarget
+		expectError  bool
+	}{
+		{
+			name:     "valid endpoint",
+			endpoint: "iscsi://10.42.0.12:3260/iqn.2014-09.com.rancher:vol-name/1",
+			expectTarget: &ISCSITarget{
+				IQN:  "iqn.2014-09.com.rancher:vol-name",
+				IP:   "10.42.0.12",
+				Port: "3260",
+				LUN:  "1",
+			},
+		},
+		{
+			name:        "block device endpoint",
+			endpoint:    "/dev/longhorn/vol-name",
+			expectError: true,
+		},
+		{
+			name:        "missing lun",
+			endpoint:    "iscsi://10.42.0.12:3260/iqn.2014-09.com.rancher:vol-name",
+			expectError: true,
+		},
+		{
+			name:        "missing port",
+			endpoint:    "iscsi://10.42.0.12/iqn.2014-09.com.rancher:vol-name/1",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := require.New(t)
+
+			target, err := ParseISCSIEndpoint(tt.endpoint)
+			if tt.expectError {
+				assert.NotNil(err)
+				return
+			}
+			assert.Nil(err)
+			assert.Equal(tt.expectTarget, target)
+		})
+	}
+}
@@ -13,6 +13,15 @@ const (
 	BackupStateUnknown    = BackupState("Unknown")
 )
 
+type BackupVerificationState string
+
+const (
+	BackupVerificationStateInProgress = BackupVerificationState("InProgress")
+	BackupVerificationStatePassed     = BackupVerificationState("Passed")
+	BackupVerificationStateFailed     = BackupVerificationState("Failed")
+	BackupVerificationStateTimedOut   = BackupVerificationState("TimedOut")
+)
+
 type BackupCompressionMethod string
 
 const (
@@ -95,6 +104,20 @@ type BackupStatus struct {
 	// Compression method
 	// +optional
 	CompressionMethod BackupCompressionMethod `json:"compressionMethod"`
+	// The state of the last verification that restores the backup and checks
+	// the checksums of the blocks.
+	// Can be "", "InProgress", "Passed", "Failed", "TimedOut".
+	// +optional
+	VerificationState BackupVerificationState `json:"verificationState"`
+	// The volume restoring the backup for the verification in progress.
+	// +optional
+	VerificationVolume string `json:"verificationVolume"`
+	// The error of the last failed verification.
+	// +optional
+	VerificationError string `json:"verificationError"`
+	// The last time that the backup verification finished.
+	// +optional
+	LastVerifiedAt string `json:"lastVerifiedAt"`
 }
 
 // +genclient
@@ -66,6 +66,20 @@ type HashStatus struct {
 	SilentlyCorrupted bool `json:"silentlyCorrupted"`
 }
 
+// ReplicaSnapshotChainRelation is how the snapshot chain of a reused replica
+// relates to the one of a healthy replica when its rebuilding starts.
+type ReplicaSnapshotChainRelation string
+
+const (
+	// ReplicaSnapshotChainRelationBehind means the chain of the replica is a
+	// prefix of the healthy chain, so the fast sync only transfers the
+	// missing snapshots
+	ReplicaSnapshotChainRelationBehind = ReplicaSnapshotChainRelation("behind")
+	// ReplicaSnapshotChainRelationDiverged means the chains diverge, e.g. by a
+	// snapshot revert or purge
+	ReplicaSnapshotChainRelationDiverged = ReplicaSnapshotChainRelation("diverged")
+)
+
 type RebuildStatus struct {
 	// +optional
 	Error string `json:"error"`
@@ -77,6 +91,9 @@ type RebuildStatus struct {
 	State string `json:"state"`
 	// +optional
 	FromReplicaAddress string `json:"fromReplicaAddress"`
+	// The average rebuild throughput in bytes per second since the rebuilding was observed. The rebuild traffic is not compressed, so it is also the rate on the wire.
+	// +optional
+	Throughput int64 `json:"throughput"`
 }
 
 type SnapshotCloneStatus struct {
@@ -137,6 +154,8 @@ type EngineSpec struct {
 	// +optional
 	UnmapMarkSnapChainRemovedEnabled bool `json:"unmapMarkSnapChainRemovedEnabled"`
 	// +optional
+	ReadOnly bool `json:"readOnly"`
+	// +optional
 	Active bool `json:"active"`
 }
 
@@ -93,6 +93,16 @@ type InstanceStatus struct {
 	Port int `json:"port"`
 	// +optional
 	Started bool `json:"started"`
+	// The time the process is seen running since its last start.
+	// +optional
+	StartedAt string `json:"startedAt"`
+	// The number of times the process is restarted after exiting unexpectedly.
+	// +optional
+	RestartCount int `json:"restartCount"`
+	// +optional
+	LastRestartAt string `json:"lastRestartAt"`
+	// +optional
+	LastExitReason string `json:"lastExitReason"`
 	// +optional
 	LogFetched bool `json:"logFetched"`
 	// +optional
@@ -6,6 +6,7 @@ const (
 	NodeConditionTypeReady            = "Ready"
 	NodeConditionTypeMountPropagation = "MountPropagation"
 	NodeConditionTypeSchedulable      = "Schedulable"
+	NodeConditionTypeCSIDriverReady   = "CSIDriverReady"
 )
 
 const (
@@ -17,6 +18,7 @@ const (
 	NodeConditionReasonUnknownNodeConditionTrue  = "UnknownNodeConditionTrue"
 	NodeConditionReasonNoMountPropagationSupport = "NoMountPropagationSupport"
 	NodeConditionReasonKubernetesNodeCordoned    = "KubernetesNodeCordoned"
+	NodeConditionReasonCSIDriverNotRegistered    = "CSIDriverNotRegistered"
 )
 
 const (
@@ -50,6 +52,42 @@ type SnapshotCheckStatus struct {
 	LastPeriodicCheckedAt metav1.Time `json:"lastPeriodicCheckedAt"`
 }
 
+type NodeVerificationState string
+
+const (
+	NodeVerificationStateInProgress = NodeVerificationState("InProgress")
+	NodeVerificationStatePassed     = NodeVerificationState("Passed")
+	NodeVerificationStateFailed     = NodeVerificationState("Failed")
+)
+
+type NodeVerificationStepStatus struct {
+	// +optional
+	Step string `json:"step"`
+	// Can be "passed", "failed", "skipped".
+	// +optional
+	Status string `json:"status"`
+	// +optional
+	Message string `json:"message"`
+}
+
+type NodeVerificationStatus struct {
+	// The state of the last verification that runs a test volume through its
+	// life cycle on the node.
+	// Can be "", "InProgress", "Passed", "Failed".
+	// +optional
+	State NodeVerificationState `json:"state"`
+	// The results of the steps finished by the last verification.
+	// +optional
+	// +nullable
+	Steps []NodeVerificationStepStatus `json:"steps"`
+	// The last time that a node verification started.
+	// +optional
+	StartedAt string `json:"startedAt"`
+	// The last time that a node verification finished.
+	// +optional
+	LastVerifiedAt string `json:"lastVerifiedAt"`
+}
+
 type DiskSpec struct {
 	// +optional
 	Path string `json:"path"`
@@ -112,6 +150,8 @@ type NodeStatus struct {
 	Zone string `json:"zone"`
 	// +optional
 	SnapshotCheckStatus SnapshotCheckStatus `json:"snapshotCheckStatus"`
+	// +optional
+	VerificationStatus NodeVerificationStatus `json:"verificationStatus"`
 }
 
 // +genclient
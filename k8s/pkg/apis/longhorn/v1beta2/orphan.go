@@ -24,6 +24,17 @@ const (
 	OrphanDiskName = "DiskName"
 	OrphanDiskUUID = "DiskUUID"
 	OrphanDiskPath = "DiskPath"
+
+	// Set from the volume metadata of the orphaned replica data, if it's
+	// readable when the orphan is created
+	OrphanVolumeSize       = "VolumeSize"
+	OrphanVolumeHead       = "VolumeHead"
+	OrphanVolumeRebuilding = "VolumeRebuilding"
+	OrphanVolumeError      = "VolumeError"
+
+	// Set when the orphaned data is retained from a failed replica
+	OrphanFailedReplicaName = "FailedReplicaName"
+	OrphanCleanupAfter      = "CleanupAfter"
 )
 
 // OrphanSpec defines the desired state of the Longhorn orphaned data
@@ -2,7 +2,7 @@ package v1beta2
 
 import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-// +kubebuilder:validation:Enum=snapshot;snapshot-force-create;snapshot-cleanup;snapshot-delete;backup;backup-force-create
+// +kubebuilder:validation:Enum=snapshot;snapshot-force-create;snapshot-cleanup;snapshot-delete;backup;backup-force-create;filesystem-trim
 type RecurringJobType string
 
 const (
@@ -12,6 +12,7 @@ const (
 	RecurringJobTypeSnapshotDelete      = RecurringJobType("snapshot-delete")       // periodically remove and purge all kinds of snapshots that exceed the retention count
 	RecurringJobTypeBackup              = RecurringJobType("backup")                // periodically create snapshots then do backups
 	RecurringJobTypeBackupForceCreate   = RecurringJobType("backup-force-create")   // periodically create snapshots then do backups even if old snapshots cleanup failed
+	RecurringJobTypeFilesystemTrim      = RecurringJobType("filesystem-trim")       // periodically trim the filesystem to reclaim the space of the deleted files
 
 	RecurringJobGroupDefault = "default"
 )
@@ -37,7 +38,7 @@ type RecurringJobSpec struct {
 	// +optional
 	Groups []string `json:"groups,omitempty"`
 	// The recurring job task.
-	// Can be "snapshot", "snapshot-force-create", "snapshot-cleanup", "snapshot-delete", "backup" or "backup-force-create".
+	// Can be "snapshot", "snapshot-force-create", "snapshot-cleanup", "snapshot-delete", "backup", "backup-force-create" or "filesystem-trim".
 	// +optional
 	Task RecurringJobType `json:"task"`
 	// The cron setting.
@@ -43,6 +43,10 @@ type ReplicaSpec struct {
 	UnmapMarkDiskChainRemovedEnabled bool `json:"unmapMarkDiskChainRemovedEnabled"`
 	// +optional
 	RebuildRetryCount int `json:"rebuildRetryCount"`
+	// EvictionRequested is set to move the replica away, the replica is
+	// removed once it's rebuilt elsewhere.
+	// +optional
+	EvictionRequested bool `json:"evictionRequested"`
 	// Deprecated
 	// +optional
 	DataPath string `json:"dataPath"`
@@ -56,6 +60,15 @@ type ReplicaStatus struct {
 	InstanceStatus `json:""`
 	// +optional
 	EvictionRequested bool `json:"evictionRequested"`
+	// The space allocated on the disk by the replica. It's less than the
+	// volume size for a sparse replica.
+	// +optional
+	ActualSize int64 `json:"actualSize"`
+	// The relation of the snapshot chain of the replica to the one of a
+	// healthy replica when the last rebuilding started, behind or diverged.
+	// Empty if the replica had no snapshot, or the chains couldn't be read.
+	// +optional
+	SnapshotChainRelation ReplicaSnapshotChainRelation `json:"snapshotChainRelation"`
 }
 
 // +genclient
@@ -88,6 +88,14 @@ const (
 	VolumeCloneStateFailed    = VolumeCloneState("failed")
 )
 
+type VolumeMigrationState string
+
+const (
+	VolumeMigrationStateEmpty     = VolumeMigrationState("")
+	VolumeMigrationStatePreparing = VolumeMigrationState("preparing")
+	VolumeMigrationStateReady     = VolumeMigrationState("ready")
+)
+
 type VolumeCloneStatus struct {
 	// +optional
 	SourceVolume string `json:"sourceVolume"`
@@ -98,9 +106,14 @@ type VolumeCloneStatus struct {
 }
 
 const (
-	VolumeConditionTypeScheduled        = "scheduled"
-	VolumeConditionTypeRestore          = "restore"
-	VolumeConditionTypeTooManySnapshots = "toomanysnapshots"
+	VolumeConditionTypeScheduled               = "scheduled"
+	VolumeConditionTypeRestore                 = "restore"
+	VolumeConditionTypeTooManySnapshots        = "toomanysnapshots"
+	VolumeConditionTypeReducedRedundancy       = "reducedredundancy"
+	VolumeConditionTypeDataCorruption          = "datacorruption"
+	VolumeConditionTypeRebuilding              = "rebuilding"
+	VolumeConditionTypeBackupTargetUnavailable = "backuptargetunavailable"
+	VolumeConditionTypeFilesystemError         = "filesystemerror"
 )
 
 const (
@@ -109,6 +122,13 @@ const (
 	VolumeConditionReasonRestoreInProgress             = "RestoreInProgress"
 	VolumeConditionReasonRestoreFailure                = "RestoreFailure"
 	VolumeConditionReasonTooManySnapshots              = "TooManySnapshots"
+	VolumeConditionReasonInsufficientNodes             = "InsufficientNodes"
+	VolumeConditionReasonSnapshotChecksumMismatch      = "SnapshotChecksumMismatch"
+	VolumeConditionReasonBackupVerificationFailure     = "BackupVerificationFailure"
+	VolumeConditionReasonReplicaRebuilding             = "ReplicaRebuilding"
+	VolumeConditionReasonBackupTargetUnavailable       = "BackupTargetUnavailable"
+	VolumeConditionReasonFilesystemErrorsFound         = "FilesystemErrorsFound"
+	VolumeConditionReasonFilesystemFormatFailure       = "FilesystemFormatFailure"
 )
 
 type SnapshotDataIntegrity string
@@ -178,6 +198,17 @@ type WorkloadStatus struct {
 	WorkloadType string `json:"workloadType"`
 }
 
+// AttachmentTicket records who requested the attachment of the volume. It's
+// active while the volume is attached to the node of the ticket.
+type AttachmentTicket struct {
+	// +optional
+	NodeID string `json:"nodeID"`
+	// +optional
+	AttachedBy string `json:"attachedBy"`
+	// +optional
+	RequestedAt string `json:"requestedAt"`
+}
+
 // VolumeSpec defines the desired state of the Longhorn volume
 type VolumeSpec struct {
 	// +kubebuilder:validation:Type=string
@@ -218,6 +249,8 @@ type VolumeSpec struct {
 	// +optional
 	LastAttachedBy string `json:"lastAttachedBy"`
 	// +optional
+	AttachmentTicket AttachmentTicket `json:"attachmentTicket"`
+	// +optional
 	AccessMode AccessMode `json:"accessMode"`
 	// +optional
 	Migratable bool `json:"migratable"`
@@ -239,6 +272,38 @@ type VolumeSpec struct {
 	// +kubebuilder:validation:Enum=none;lz4;gzip
 	// +optional
 	BackupCompressionMethod BackupCompressionMethod `json:"backupCompressionMethod"`
+	// The time in RFC3339 format after which the volume is deleted automatically. Empty means never.
+	// +optional
+	ExpireAt string `json:"expireAt"`
+	// In MiB/s. 0 follows the global setting, and -1 means no limit.
+	// +optional
+	RebuildBandwidthLimit int64 `json:"rebuildBandwidthLimit"`
+	// 0 follows the global setting, and -1 means no limit.
+	// +optional
+	FrontendIOPSLimit int64 `json:"frontendIOPSLimit"`
+	// In MiB/s. 0 follows the global setting, and -1 means no limit.
+	// +optional
+	FrontendBandwidthLimit int64 `json:"frontendBandwidthLimit"`
+	// The filesystem type on the volume, e.g. ext4 or xfs. Empty skips the filesystem check.
+	// +kubebuilder:validation:Enum=ext4;xfs;""
+	// +optional
+	FilesystemType string `json:"filesystemType"`
+	// The filesystem the volume is formatted with once it's attached the first time, if it's blank.
+	// +kubebuilder:validation:Enum=ext4;xfs;""
+	// +optional
+	Filesystem string `json:"filesystem"`
+	// The options to mount the filesystem of the volume with.
+	// +optional
+	MountOptions []string `json:"mountOptions"`
+	// The volume frontend is read-only. It takes effect when the engine starts, e.g. the next time the volume is attached.
+	// +optional
+	ReadOnly bool `json:"readOnly"`
+	// The max number of the snapshots of the volume. The oldest system snapshots are cleaned up automatically beyond it. 0 means no limit.
+	// +optional
+	SnapshotMaxCount int `json:"snapshotMaxCount"`
+	// The max age of the system snapshots of the volume, e.g. 168h. The older ones are cleaned up automatically. Empty means no limit.
+	// +optional
+	SnapshotMaxAge string `json:"snapshotMaxAge"`
 }
 
 // VolumeStatus defines the observed state of the Longhorn volume
@@ -286,6 +351,27 @@ type VolumeStatus struct {
 	ShareEndpoint string `json:"shareEndpoint"`
 	// +optional
 	ShareState ShareManagerState `json:"shareState"`
+	// The time in RFC3339 format the integrity sweep last checked a snapshot of the volume, whatever the result.
+	// +optional
+	LastIntegrityCheckedAt string `json:"lastIntegrityCheckedAt"`
+	// The time in RFC3339 format the integrity sweep last verified a snapshot of the volume successfully.
+	// +optional
+	LastIntegrityVerifiedAt string `json:"lastIntegrityVerifiedAt"`
+	// The error of the last integrity sweep check. Empty if it passed.
+	// +optional
+	IntegrityCheckError string `json:"integrityCheckError"`
+	// The time in RFC3339 format the filesystem of the volume frozen by request is thawed automatically. Empty if it's not frozen.
+	// +optional
+	FrozenUntil string `json:"frozenUntil"`
+	// The time in RFC3339 format the filesystem check of the volume last ran, whatever the result.
+	// +optional
+	LastFilesystemCheckAt string `json:"lastFilesystemCheckAt"`
+	// The migration to spec.migrationNodeID is ready to be confirmed once the engine on the node is running. Empty if the volume is not migrating.
+	// +optional
+	MigrationState VolumeMigrationState `json:"migrationState"`
+	// The time in RFC3339 format the current migration started.
+	// +optional
+	MigrationStartedAt string `json:"migrationStartedAt"`
 }
 
 // +genclient
@@ -25,6 +25,22 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AttachmentTicket) DeepCopyInto(out *AttachmentTicket) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AttachmentTicket.
+func (in *AttachmentTicket) DeepCopy() *AttachmentTicket {
+	if in == nil {
+		return nil
+	}
+	out := new(AttachmentTicket)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BackingImage) DeepCopyInto(out *BackingImage) {
 	*out = *in
@@ -1494,6 +1510,7 @@ func (in *NodeStatus) DeepCopyInto(out *NodeStatus) {
 		}
 	}
 	in.SnapshotCheckStatus.DeepCopyInto(&out.SnapshotCheckStatus)
+	in.VerificationStatus.DeepCopyInto(&out.VerificationStatus)
 	return
 }
 
@@ -1507,6 +1524,43 @@ func (in *NodeStatus) DeepCopy() *NodeStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeVerificationStatus) DeepCopyInto(out *NodeVerificationStatus) {
+	*out = *in
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]NodeVerificationStepStatus, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeVerificationStatus.
+func (in *NodeVerificationStatus) DeepCopy() *NodeVerificationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeVerificationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeVerificationStepStatus) DeepCopyInto(out *NodeVerificationStepStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeVerificationStepStatus.
+func (in *NodeVerificationStepStatus) DeepCopy() *NodeVerificationStepStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeVerificationStepStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Orphan) DeepCopyInto(out *Orphan) {
 	*out = *in
@@ -2631,6 +2685,7 @@ func (in *VolumeRecurringJobSpec) DeepCopy() *VolumeRecurringJobSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VolumeSpec) DeepCopyInto(out *VolumeSpec) {
 	*out = *in
+	out.AttachmentTicket = in.AttachmentTicket
 	if in.DiskSelector != nil {
 		in, out := &in.DiskSelector, &out.DiskSelector
 		*out = make([]string, len(*in))
@@ -2648,6 +2703,11 @@ func (in *VolumeSpec) DeepCopyInto(out *VolumeSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.MountOptions != nil {
+		in, out := &in.MountOptions, &out.MountOptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+func (m *VolumeManager) IsAPIAuthenticationEnabled() (bool, error) {
+	return m.ds.GetSettingAsBool(types.SettingNameAPIAuthentication)
+}
+
+// GetAPITokenRole returns the role of the token in the API auth Secret, or
+// empty if the token is unknown. The admin tokens are checked first.
+func (m *VolumeManager) GetAPITokenRole(token string) (role string, err error) {
+	defer func() {
+		err = errors.Wrap(err, "unable to get API token role")
+	}()
+
+	if token == "" {
+		return "", nil
+	}
+	secret, err := m.ds.GetAPIAuthSecretRO()
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	for _, r := range []string{types.APIRoleAdmin, types.APIRoleReadOnly} {
+		for _, t := range types.ParseAPITokens(string(secret.Data[r])) {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return r, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// GetAPICertificateRole returns the role in the organization of a verified
+// client certificate, or empty if there is none.
+func GetAPICertificateRole(cert *x509.Certificate) string {
+	role := ""
+	for _, org := range cert.Subject.Organization {
+		if !strings.HasPrefix(org, types.APIRoleOrganizationPrefix) {
+			continue
+		}
+		switch r := strings.TrimPrefix(org, types.APIRoleOrganizationPrefix); r {
+		case types.APIRoleAdmin:
+			return r
+		case types.APIRoleReadOnly:
+			role = r
+		}
+	}
+	return role
+}
@@ -0,0 +1,34 @@
+package manager
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+func TestGetAPICertificateRole(t *testing.T) {
+	admin := types.APIRoleOrganizationPrefix + types.APIRoleAdmin
+	readOnly := types.APIRoleOrganizationPrefix + types.APIRoleReadOnly
+
+	tests := map[string]struct {
+		organizations []string
+		expectRole    string
+	}{
+		"admin":                {[]string{admin}, types.APIRoleAdmin},
+		"read-only":            {[]string{"example", readOnly}, types.APIRoleReadOnly},
+		"admin over read-only": {[]string{readOnly, admin}, types.APIRoleAdmin},
+		"unknown role":         {[]string{types.APIRoleOrganizationPrefix + "owner"}, ""},
+		"role without prefix":  {[]string{types.APIRoleAdmin}, ""},
+		"no organization":      {nil, ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cert := &x509.Certificate{Subject: pkix.Name{Organization: tc.organizations}}
+			require.Equal(t, tc.expectRole, GetAPICertificateRole(cert))
+		})
+	}
+}
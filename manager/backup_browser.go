@@ -0,0 +1,179 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+const (
+	BackupBrowserAttachedBy = "backup-browser"
+
+	// DefaultBackupBrowserLifetime is how long a backup browser is kept
+	// before it's deleted automatically.
+	DefaultBackupBrowserLifetime = 24 * time.Hour
+)
+
+// CreateBackupBrowser restores the backup to a temporary single replica
+// volume, so the files in it can be listed and downloaded without restoring
+// the whole volume for a workload. The browser is attached to the node
+// serving the first browsing request once the restoration completes, and
+// it's deleted automatically after DefaultBackupBrowserLifetime.
+func (m *VolumeManager) CreateBackupBrowser(backupName string) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to create browser of backup %v", backupName)
+	}()
+
+	backup, err := m.ds.GetBackupRO(backupName)
+	if err != nil {
+		return nil, err
+	}
+	if backup.Status.State != longhorn.BackupStateCompleted {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState,
+			map[string]string{types.ErrorParameterState: string(backup.Status.State)},
+			"backup %v is in state %v", backupName, backup.Status.State)
+	}
+	size, err := strconv.ParseInt(backup.Status.VolumeSize, 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid volume size %v of backup", backup.Status.VolumeSize)
+	}
+
+	v = &longhorn.Volume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: getBackupBrowserVolumeName(backupName),
+			Labels: map[string]string{
+				types.GetLonghornLabelKey(types.LonghornLabelBackupBrowser): backupName,
+			},
+		},
+		Spec: longhorn.VolumeSpec{
+			Size:             size,
+			Frontend:         longhorn.VolumeFrontendBlockDev,
+			FromBackup:       backup.Status.URL,
+			BackingImage:     backup.Status.VolumeBackingImageName,
+			NumberOfReplicas: 1,
+			DataLocality:     longhorn.DataLocalityDisabled,
+			ExpireAt:         m.clock.Now().Add(DefaultBackupBrowserLifetime).UTC().Format(time.RFC3339),
+		},
+	}
+	if v, err = m.ds.CreateVolume(v); err != nil {
+		return nil, err
+	}
+	logrus.Infof("Created browser %v of backup %v", v.Name, backupName)
+	return v, nil
+}
+
+func getBackupBrowserVolumeName(backupName string) string {
+	suffix := "-" + util.RandomID()
+	name := fmt.Sprintf("browse-%s", backupName)
+	if len(name)+len(suffix) > datastore.NameMaximumLength {
+		name = strings.TrimRight(name[:datastore.NameMaximumLength-len(suffix)], "-")
+	}
+	return name + suffix
+}
+
+// ListBackupBrowserFiles lists the directory of the filesystem restored to
+// the backup browser. It's called on the node the browser is attached to.
+func (m *VolumeManager) ListBackupBrowserFiles(ctx context.Context, name, dir string) (entries []*util.DirectoryEntry, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to list files of backup browser %v", name)
+	}()
+
+	err = m.browseBackup(ctx, name, func(root string) error {
+		entries, err = util.ListDirectoryEntries(root, dir)
+		return err
+	})
+	return entries, err
+}
+
+// CopyBackupBrowserFile writes the regular file of the filesystem restored
+// to the backup browser by the write function. It's called on the node the
+// browser is attached to.
+func (m *VolumeManager) CopyBackupBrowserFile(ctx context.Context, name, path string, write func(info os.FileInfo, r io.Reader) error) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to copy file %v of backup browser %v", path, name)
+	}()
+
+	return m.browseBackup(ctx, name, func(root string) error {
+		f, info, err := util.OpenFileInDirectory(root, path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return write(info, f)
+	})
+}
+
+// browseBackup mounts the filesystem of the backup browser read-only for the
+// duration of the browse function, so nothing is left mounted when the
+// browser is detached or deleted.
+func (m *VolumeManager) browseBackup(ctx context.Context, name string, browse func(root string) error) error {
+	v, err := m.getBackupBrowser(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	root, unmount, err := util.MountDeviceReadOnly(util.RegularDeviceDirectory + v.Name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := unmount(); err != nil {
+			logrus.WithError(err).Warnf("Failed to unmount backup browser %v", name)
+		}
+	}()
+	return browse(root)
+}
+
+// getBackupBrowser returns the backup browser once it's attached to the
+// current node. The browser restored and detached is attached to the current
+// node, and the caller retries after a while.
+func (m *VolumeManager) getBackupBrowser(ctx context.Context, name string) (*longhorn.Volume, error) {
+	v, err := m.ds.GetVolumeRO(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := v.Labels[types.GetLonghornLabelKey(types.LonghornLabelBackupBrowser)]; !ok {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterName: name},
+			"volume %v is not a backup browser", name)
+	}
+	if v.Spec.Encrypted {
+		return nil, fmt.Errorf("browsing an encrypted volume is not supported")
+	}
+
+	restoreCondition := types.GetCondition(v.Status.Conditions, longhorn.VolumeConditionTypeRestore)
+	if !v.Status.RestoreInitiated || v.Status.RestoreRequired || restoreCondition.Status == longhorn.ConditionStatusTrue {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState,
+			map[string]string{types.ErrorParameterState: string(v.Status.State)},
+			"backup browser %v is restoring the backup", name)
+	}
+
+	if v.Spec.NodeID == "" && v.Status.State == longhorn.VolumeStateDetached {
+		if _, err := m.Attach(ctx, name, m.currentNodeID, false, BackupBrowserAttachedBy); err != nil {
+			return nil, err
+		}
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState,
+			map[string]string{types.ErrorParameterState: string(longhorn.VolumeStateAttaching)},
+			"backup browser %v is being attached to node %v", name, m.currentNodeID)
+	}
+	if v.Status.CurrentNodeID != m.currentNodeID || v.Status.State != longhorn.VolumeStateAttached {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState,
+			map[string]string{types.ErrorParameterState: string(v.Status.State)},
+			"backup browser %v is not attached to node %v yet", name, m.currentNodeID)
+	}
+	return v, nil
+}
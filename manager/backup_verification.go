@@ -0,0 +1,91 @@
+package manager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+// VerifyBackup restores the backup to a temporary single replica volume. The
+// restoration reads back every block of the backup from the backup target
+// and checks it against the checksum recorded in the block name. The volume
+// controller records the result in the backup status and deletes the volume
+// once the restoration finishes.
+func (m *VolumeManager) VerifyBackup(backupName string) (backup *longhorn.Backup, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to verify backup %v", backupName)
+	}()
+
+	backup, err = m.ds.GetBackup(backupName)
+	if err != nil {
+		return nil, err
+	}
+	if backup.Status.State != longhorn.BackupStateCompleted {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState,
+			map[string]string{types.ErrorParameterState: string(backup.Status.State)},
+			"backup %v is in state %v", backupName, backup.Status.State)
+	}
+	if backup.Status.VerificationState == longhorn.BackupVerificationStateInProgress {
+		if _, err := m.ds.GetVolumeRO(backup.Status.VerificationVolume); err == nil {
+			return nil, types.NewReasonError(types.ErrorReasonConflict,
+				map[string]string{types.ErrorParameterName: backup.Status.VerificationVolume},
+				"backup %v is being verified by volume %v", backupName, backup.Status.VerificationVolume)
+		} else if !datastore.ErrorIsNotFound(err) {
+			return nil, err
+		}
+	}
+	size, err := strconv.ParseInt(backup.Status.VolumeSize, 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid volume size %v of backup", backup.Status.VolumeSize)
+	}
+
+	v := &longhorn.Volume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: getBackupVerificationVolumeName(backupName),
+			Labels: map[string]string{
+				types.GetLonghornLabelKey(types.LonghornLabelBackupVerification): backupName,
+			},
+		},
+		Spec: longhorn.VolumeSpec{
+			Size:             size,
+			Frontend:         longhorn.VolumeFrontendBlockDev,
+			FromBackup:       backup.Status.URL,
+			BackingImage:     backup.Status.VolumeBackingImageName,
+			NumberOfReplicas: 1,
+			DataLocality:     longhorn.DataLocalityDisabled,
+		},
+	}
+	if v, err = m.ds.CreateVolume(v); err != nil {
+		return nil, err
+	}
+
+	backup.Status.VerificationState = longhorn.BackupVerificationStateInProgress
+	backup.Status.VerificationVolume = v.Name
+	if backup, err = m.ds.UpdateBackupStatus(backup); err != nil {
+		if deleteErr := m.ds.DeleteVolume(v.Name); deleteErr != nil && !datastore.ErrorIsNotFound(deleteErr) {
+			logrus.WithError(deleteErr).Warnf("Failed to clean up backup verification volume %v", v.Name)
+		}
+		return nil, err
+	}
+	logrus.Infof("Verifying backup %v by restoring it to volume %v", backupName, v.Name)
+	return backup, nil
+}
+
+func getBackupVerificationVolumeName(backupName string) string {
+	suffix := "-" + util.RandomID()
+	name := fmt.Sprintf("verify-%s", backupName)
+	if len(name)+len(suffix) > datastore.NameMaximumLength {
+		name = strings.TrimRight(name[:datastore.NameMaximumLength-len(suffix)], "-")
+	}
+	return name + suffix
+}
@@ -0,0 +1,192 @@
+package manager
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+type DiskCapacity struct {
+	Name             string `json:"name"`
+	Path             string `json:"path"`
+	Schedulable      bool   `json:"schedulable"`
+	StorageMaximum   int64  `json:"storageMaximum"`
+	StorageReserved  int64  `json:"storageReserved"`
+	StorageAvailable int64  `json:"storageAvailable"`
+	StorageScheduled int64  `json:"storageScheduled"`
+	// The space used by the replicas on the disk, collected periodically by
+	// the node monitor.
+	StorageActual int64 `json:"storageActual"`
+	// The size left for new replicas under the over provisioning, or 0 if
+	// the disk is not schedulable.
+	StorageSchedulable int64 `json:"storageSchedulable"`
+	// Full means the available space is below the minimal available
+	// percentage, so no replica can be scheduled to the disk.
+	Full bool `json:"full"`
+}
+
+type NodeCapacity struct {
+	Name               string          `json:"name"`
+	Disks              []*DiskCapacity `json:"disks"`
+	StorageMaximum     int64           `json:"storageMaximum"`
+	StorageReserved    int64           `json:"storageReserved"`
+	StorageAvailable   int64           `json:"storageAvailable"`
+	StorageScheduled   int64           `json:"storageScheduled"`
+	StorageActual      int64           `json:"storageActual"`
+	StorageSchedulable int64           `json:"storageSchedulable"`
+}
+
+type VolumeCapacity struct {
+	Name             string `json:"name"`
+	Size             int64  `json:"size"`
+	NumberOfReplicas int    `json:"numberOfReplicas"`
+	// The size of the volume data, snapshots included, on a single replica.
+	ActualSize int64 `json:"actualSize"`
+	// The part of the actual size held by the snapshots.
+	SnapshotSize int64 `json:"snapshotSize"`
+	// The space used by all the replicas of the volume.
+	ReplicaActualSize int64 `json:"replicaActualSize"`
+	// The space the replicas of the volume may grow to, which is counted
+	// as scheduled on the disks.
+	ScheduledSize int64 `json:"scheduledSize"`
+}
+
+type ClusterCapacity struct {
+	Nodes              []*NodeCapacity   `json:"nodes"`
+	Volumes            []*VolumeCapacity `json:"volumes"`
+	StorageMaximum     int64             `json:"storageMaximum"`
+	StorageReserved    int64             `json:"storageReserved"`
+	StorageAvailable   int64             `json:"storageAvailable"`
+	StorageScheduled   int64             `json:"storageScheduled"`
+	StorageActual      int64             `json:"storageActual"`
+	StorageSchedulable int64             `json:"storageSchedulable"`
+	SnapshotSize       int64             `json:"snapshotSize"`
+	FullDisks          int               `json:"fullDisks"`
+}
+
+// ClusterCapacity summarizes the storage of the cluster per node and disk,
+// and the space used by each volume. The schedulable size follows the same
+// rules as the replica scheduler, so it tells how much more can be
+// provisioned before the volumes fail to schedule.
+func (m *VolumeManager) ClusterCapacity() (capacity *ClusterCapacity, err error) {
+	defer func() {
+		err = errors.Wrap(err, "unable to get cluster capacity")
+	}()
+
+	overProvisioningPercentage, err := m.ds.GetSettingAsInt(types.SettingNameStorageOverProvisioningPercentage)
+	if err != nil {
+		return nil, err
+	}
+	minimalAvailablePercentage, err := m.ds.GetSettingAsInt(types.SettingNameStorageMinimalAvailablePercentage)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas, err := m.ds.ListReplicasRO()
+	if err != nil {
+		return nil, err
+	}
+	diskActualSizes := map[string]int64{}
+	volumeReplicaActualSizes := map[string]int64{}
+	for _, r := range replicas {
+		diskActualSizes[r.Spec.DiskID] += r.Status.ActualSize
+		volumeReplicaActualSizes[r.Spec.VolumeName] += r.Status.ActualSize
+	}
+
+	capacity = &ClusterCapacity{
+		Nodes:   []*NodeCapacity{},
+		Volumes: []*VolumeCapacity{},
+	}
+
+	nodes, err := m.ds.ListNodesRO()
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		nc := &NodeCapacity{
+			Name:  node.Name,
+			Disks: []*DiskCapacity{},
+		}
+		for name, diskSpec := range node.Spec.Disks {
+			diskStatus, ok := node.Status.DiskStatus[name]
+			if !ok || diskStatus == nil {
+				continue
+			}
+			dc := &DiskCapacity{
+				Name:             name,
+				Path:             diskSpec.Path,
+				Schedulable:      node.Spec.AllowScheduling && diskSpec.AllowScheduling && !node.Spec.EvictionRequested && !diskSpec.EvictionRequested,
+				StorageMaximum:   diskStatus.StorageMaximum,
+				StorageReserved:  diskSpec.StorageReserved,
+				StorageAvailable: diskStatus.StorageAvailable,
+				StorageScheduled: diskStatus.StorageScheduled,
+				StorageActual:    diskActualSizes[diskStatus.DiskUUID],
+			}
+			dc.Full = dc.StorageAvailable <= int64(float64(dc.StorageMaximum)*float64(minimalAvailablePercentage)/100)
+			if dc.Schedulable && !dc.Full {
+				limit := int64(float64(dc.StorageMaximum-dc.StorageReserved) * float64(overProvisioningPercentage) / 100)
+				if limit > dc.StorageScheduled {
+					dc.StorageSchedulable = limit - dc.StorageScheduled
+				}
+			}
+			if dc.Full {
+				capacity.FullDisks++
+			}
+
+			nc.Disks = append(nc.Disks, dc)
+			nc.StorageMaximum += dc.StorageMaximum
+			nc.StorageReserved += dc.StorageReserved
+			nc.StorageAvailable += dc.StorageAvailable
+			nc.StorageScheduled += dc.StorageScheduled
+			nc.StorageActual += dc.StorageActual
+			nc.StorageSchedulable += dc.StorageSchedulable
+		}
+		sort.Slice(nc.Disks, func(i, j int) bool {
+			return nc.Disks[i].Name < nc.Disks[j].Name
+		})
+
+		capacity.Nodes = append(capacity.Nodes, nc)
+		capacity.StorageMaximum += nc.StorageMaximum
+		capacity.StorageReserved += nc.StorageReserved
+		capacity.StorageAvailable += nc.StorageAvailable
+		capacity.StorageScheduled += nc.StorageScheduled
+		capacity.StorageActual += nc.StorageActual
+		capacity.StorageSchedulable += nc.StorageSchedulable
+	}
+	sort.Slice(capacity.Nodes, func(i, j int) bool {
+		return capacity.Nodes[i].Name < capacity.Nodes[j].Name
+	})
+
+	snapshots, err := m.ds.ListSnapshotsRO(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	volumeSnapshotSizes := map[string]int64{}
+	for _, snapshot := range snapshots {
+		volumeSnapshotSizes[snapshot.Spec.Volume] += snapshot.Status.Size
+	}
+
+	volumes, err := m.ListSorted()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range volumes {
+		vc := &VolumeCapacity{
+			Name:              v.Name,
+			Size:              v.Spec.Size,
+			NumberOfReplicas:  v.Spec.NumberOfReplicas,
+			ActualSize:        v.Status.ActualSize,
+			SnapshotSize:      volumeSnapshotSizes[v.Name],
+			ReplicaActualSize: volumeReplicaActualSizes[v.Name],
+			ScheduledSize:     v.Spec.Size * int64(v.Spec.NumberOfReplicas),
+		}
+		capacity.Volumes = append(capacity.Volumes, vc)
+		capacity.SnapshotSize += vc.SnapshotSize
+	}
+
+	return capacity, nil
+}
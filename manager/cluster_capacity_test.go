@@ -0,0 +1,150 @@
+package manager_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func newCapacityNode(name string, allowScheduling bool, disks map[string]longhorn.DiskSpec, diskStatus map[string]*longhorn.DiskStatus) *longhorn.Node {
+	node := newReadyNode(name)
+	node.Spec.AllowScheduling = allowScheduling
+	node.Spec.Disks = disks
+	node.Status.DiskStatus = diskStatus
+	return node
+}
+
+func newCapacityReplica(name, volumeName, diskID string, actualSize int64) *longhorn.Replica {
+	r := &longhorn.Replica{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+	}
+	r.Spec.VolumeName = volumeName
+	r.Spec.DiskID = diskID
+	r.Status.ActualSize = actualSize
+	return r
+}
+
+func newCapacitySnapshot(name, volumeName string, size int64) *longhorn.Snapshot {
+	return &longhorn.Snapshot{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec:       longhorn.SnapshotSpec{Volume: volumeName},
+		Status:     longhorn.SnapshotStatus{Size: size},
+	}
+}
+
+func newCapacityVolume(name string, size int64, numberOfReplicas int, actualSize int64) *longhorn.Volume {
+	return &longhorn.Volume{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec:       longhorn.VolumeSpec{Size: size, NumberOfReplicas: numberOfReplicas},
+		Status:     longhorn.VolumeStatus{ActualSize: actualSize},
+	}
+}
+
+func TestClusterCapacity(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	objects := []runtime.Object{
+		&longhorn.Setting{
+			ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameStorageOverProvisioningPercentage), Namespace: testNamespace},
+			Value:      "200",
+		},
+		&longhorn.Setting{
+			ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameStorageMinimalAvailablePercentage), Namespace: testNamespace},
+			Value:      "25",
+		},
+		newCapacityNode(testNode1, true,
+			map[string]longhorn.DiskSpec{
+				"disk-a": {Path: "/a", AllowScheduling: true, StorageReserved: 100},
+				// Full since the available space is below 25%
+				"disk-b": {Path: "/b", AllowScheduling: true},
+				// Not reported by the node monitor yet
+				"disk-new": {Path: "/new", AllowScheduling: true},
+			},
+			map[string]*longhorn.DiskStatus{
+				"disk-a": {DiskUUID: "uuid-a", StorageMaximum: 1000, StorageAvailable: 600, StorageScheduled: 500},
+				"disk-b": {DiskUUID: "uuid-b", StorageMaximum: 1000, StorageAvailable: 200},
+			}),
+		// Nothing is schedulable on the node that disallows the scheduling
+		newCapacityNode(testNode2, false,
+			map[string]longhorn.DiskSpec{
+				"disk-c": {Path: "/c", AllowScheduling: true},
+			},
+			map[string]*longhorn.DiskStatus{
+				"disk-c": {DiskUUID: "uuid-c", StorageMaximum: 1000, StorageAvailable: 900, StorageScheduled: 100},
+			}),
+		newCapacityReplica("vol-1-r-1", "vol-1", "uuid-a", 300),
+		newCapacityReplica("vol-1-r-2", "vol-1", "uuid-c", 200),
+		newCapacityReplica("vol-2-r-1", "vol-2", "uuid-a", 50),
+		newCapacitySnapshot("snap-1", "vol-1", 100),
+		newCapacitySnapshot("snap-2", "vol-1", 40),
+		newCapacitySnapshot("snap-3", "vol-2", 10),
+		newCapacityVolume("vol-1", 1000, 2, 300),
+		newCapacityVolume("vol-2", 500, 1, 0),
+	}
+	c, err := fake.NewCluster(testNamespace, stopCh, objects...)
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+
+	capacity, err := m.ClusterCapacity()
+	assert.NoError(err)
+
+	assert.Equal([]*manager.NodeCapacity{
+		{
+			Name: testNode1,
+			Disks: []*manager.DiskCapacity{
+				{
+					Name: "disk-a", Path: "/a", Schedulable: true,
+					StorageMaximum: 1000, StorageReserved: 100, StorageAvailable: 600, StorageScheduled: 500,
+					StorageActual: 350,
+					// (1000 - 100) * 200% - 500
+					StorageSchedulable: 1300,
+				},
+				{
+					Name: "disk-b", Path: "/b", Schedulable: true,
+					StorageMaximum: 1000, StorageAvailable: 200,
+					Full: true,
+				},
+			},
+			StorageMaximum: 2000, StorageReserved: 100, StorageAvailable: 800, StorageScheduled: 500,
+			StorageActual: 350, StorageSchedulable: 1300,
+		},
+		{
+			Name: testNode2,
+			Disks: []*manager.DiskCapacity{
+				{
+					Name: "disk-c", Path: "/c",
+					StorageMaximum: 1000, StorageAvailable: 900, StorageScheduled: 100,
+					StorageActual: 200,
+				},
+			},
+			StorageMaximum: 1000, StorageAvailable: 900, StorageScheduled: 100,
+			StorageActual: 200,
+		},
+	}, capacity.Nodes)
+
+	assert.Equal([]*manager.VolumeCapacity{
+		{Name: "vol-1", Size: 1000, NumberOfReplicas: 2, ActualSize: 300, SnapshotSize: 140, ReplicaActualSize: 500, ScheduledSize: 2000},
+		{Name: "vol-2", Size: 500, NumberOfReplicas: 1, SnapshotSize: 10, ReplicaActualSize: 50, ScheduledSize: 500},
+	}, capacity.Volumes)
+
+	assert.Equal(int64(3000), capacity.StorageMaximum)
+	assert.Equal(int64(100), capacity.StorageReserved)
+	assert.Equal(int64(1700), capacity.StorageAvailable)
+	assert.Equal(int64(600), capacity.StorageScheduled)
+	assert.Equal(int64(550), capacity.StorageActual)
+	assert.Equal(int64(1300), capacity.StorageSchedulable)
+	assert.Equal(int64(150), capacity.SnapshotSize)
+	assert.Equal(1, capacity.FullDisks)
+}
@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/longhorn-manager/engineapi"
+	"github.com/longhorn/longhorn-manager/util"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+// Clock provides the time recorded by the manager in the resources, e.g. the
+// attachment request time. It doesn't affect how long the manager waits.
+type Clock interface {
+	Now() time.Time
+}
+
+// EngineClientFactory creates the client to call the running engine of a
+// volume. The calls of the client should stop once the context is done. The
+// caller closes the client.
+type EngineClientFactory interface {
+	NewEngineClient(ctx context.Context, e *longhorn.Engine, log logrus.FieldLogger) (engineapi.EngineClientProxy, error)
+}
+
+// FilesystemFreezer freezes and thaws the filesystem of a volume attached to
+// the current node. Freezing a frozen filesystem and thawing a filesystem not
+// frozen succeed.
+type FilesystemFreezer interface {
+	Freeze(volumeName string, isEncryptedDevice bool) error
+	Thaw(volumeName string, isEncryptedDevice bool) error
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+type defaultFilesystemFreezer struct{}
+
+func (defaultFilesystemFreezer) Freeze(volumeName string, isEncryptedDevice bool) error {
+	return util.FreezeFilesystem(volumeName, isEncryptedDevice)
+}
+
+func (defaultFilesystemFreezer) Thaw(volumeName string, isEncryptedDevice bool) error {
+	return util.UnfreezeFilesystem(volumeName, isEncryptedDevice)
+}
+
+type defaultEngineClientFactory struct {
+	m *VolumeManager
+}
+
+func (f *defaultEngineClientFactory) NewEngineClient(ctx context.Context, e *longhorn.Engine, log logrus.FieldLogger) (engineapi.EngineClientProxy, error) {
+	engineCliClient, err := engineapi.GetEngineBinaryClient(f.m.ds, e.Spec.VolumeName, f.m.currentNodeID)
+	if err != nil {
+		return nil, err
+	}
+	return engineapi.GetCompatibleClientWithContext(ctx, e, engineCliClient, f.m.ds, log, f.m.proxyConnCounter)
+}
+
+// SetClock replaces the clock of the manager, e.g. with a fake one in a test
+// harness. It's not safe to call after the manager starts serving.
+func (m *VolumeManager) SetClock(clock Clock) {
+	m.clock = clock
+}
+
+// SetEngineClientFactory replaces how the manager reaches the engines, so a
+// custom control plane or a test harness can run without the engine binaries
+// and the instance managers. It's not safe to call after the manager starts
+// serving.
+func (m *VolumeManager) SetEngineClientFactory(factory EngineClientFactory) {
+	m.engineClientFactory = factory
+}
+
+// SetFilesystemFreezer replaces how the manager freezes the filesystems, e.g.
+// with a fake one in a test harness. It's not safe to call after the manager
+// starts serving.
+func (m *VolumeManager) SetFilesystemFreezer(freezer FilesystemFreezer) {
+	m.freezer = freezer
+}
+
+func (m *VolumeManager) now() string {
+	return m.clock.Now().UTC().Format(time.RFC3339)
+}
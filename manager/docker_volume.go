@@ -0,0 +1,303 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/mount-utils"
+	utilexec "k8s.io/utils/exec"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+const (
+	DockerVolumeMountDirectory = "/var/lib/longhorn/docker-volumes/"
+
+	DockerVolumeOptionSize             = "size"
+	DockerVolumeOptionNumberOfReplicas = "numberOfReplicas"
+
+	dockerVolumeDefaultSize   = "10Gi"
+	dockerVolumeFilesystem    = "ext4"
+	dockerVolumeWaitTimeout   = 2 * time.Minute
+	dockerVolumeCheckInterval = time.Second
+)
+
+type DockerVolume struct {
+	Name       string                 `json:"Name"`
+	Mountpoint string                 `json:"Mountpoint,omitempty"`
+	CreatedAt  string                 `json:"CreatedAt,omitempty"`
+	Status     map[string]interface{} `json:"Status,omitempty"`
+}
+
+// DockerVolumeDriver implements the operations of the Docker volume plugin
+// protocol on top of the volume manager. Volumes are attached to the current
+// node and mounted with a filesystem below DockerVolumeMountDirectory, which
+// is shared with the host by the bidirectional mount propagation of the
+// manager pod.
+type DockerVolumeDriver struct {
+	m              *VolumeManager
+	mounter        *mount.SafeFormatAndMount
+	mountDirectory string
+
+	lock sync.Mutex
+	// the IDs of the mount requests of each mounted volume, a volume is
+	// unmounted and detached once all of them are released
+	mounts map[string]map[string]struct{}
+	// the volumes being mounted, closed once the mount is done. The lock is
+	// not held while waiting for the attachment.
+	mounting map[string]chan struct{}
+}
+
+func NewDockerVolumeDriver(m *VolumeManager) *DockerVolumeDriver {
+	return &DockerVolumeDriver{
+		m:              m,
+		mounter:        &mount.SafeFormatAndMount{Interface: mount.New(""), Exec: utilexec.New()},
+		mountDirectory: DockerVolumeMountDirectory,
+		mounts:         map[string]map[string]struct{}{},
+		mounting:       map[string]chan struct{}{},
+	}
+}
+
+// SetMounter replaces how the volumes are mounted and the directory of the
+// mountpoints, e.g. with a fake one in a test harness. It's not safe to call
+// after the driver starts serving.
+func (d *DockerVolumeDriver) SetMounter(mounter *mount.SafeFormatAndMount, mountDirectory string) {
+	d.mounter = mounter
+	d.mountDirectory = mountDirectory
+}
+
+func (d *DockerVolumeDriver) getMountpoint(name string) string {
+	return filepath.Join(d.mountDirectory, name)
+}
+
+// waitForMountingLocked waits until the volume is not being mounted by
+// another request. The lock is released while waiting.
+func (d *DockerVolumeDriver) waitForMountingLocked(name string) {
+	for {
+		mounting, ok := d.mounting[name]
+		if !ok {
+			return
+		}
+		d.lock.Unlock()
+		<-mounting
+		d.lock.Lock()
+	}
+}
+
+func (d *DockerVolumeDriver) Create(ctx context.Context, name string, opts map[string]string) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to create docker volume %v", name)
+	}()
+
+	sizeStr := dockerVolumeDefaultSize
+	if opts[DockerVolumeOptionSize] != "" {
+		sizeStr = opts[DockerVolumeOptionSize]
+	}
+	size, err := util.ConvertSize(sizeStr)
+	if err != nil {
+		return errors.Wrapf(err, "invalid option %v", DockerVolumeOptionSize)
+	}
+
+	// The default replica count setting is applied when it's not specified
+	numberOfReplicas := 0
+	if opts[DockerVolumeOptionNumberOfReplicas] != "" {
+		numberOfReplicas, err = strconv.Atoi(opts[DockerVolumeOptionNumberOfReplicas])
+		if err != nil {
+			return errors.Wrapf(err, "invalid option %v", DockerVolumeOptionNumberOfReplicas)
+		}
+	}
+
+	for key := range opts {
+		if key != DockerVolumeOptionSize && key != DockerVolumeOptionNumberOfReplicas {
+			return fmt.Errorf("unknown option %v", key)
+		}
+	}
+
+	_, err = d.m.Create(ctx, name, &longhorn.VolumeSpec{
+		Size:             size,
+		NumberOfReplicas: numberOfReplicas,
+		Frontend:         longhorn.VolumeFrontendBlockDev,
+	}, nil, nil, "", "")
+	return err
+}
+
+func (d *DockerVolumeDriver) Remove(ctx context.Context, name string) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to remove docker volume %v", name)
+	}()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if len(d.mounts[name]) != 0 || d.mounting[name] != nil {
+		return fmt.Errorf("volume is still mounted")
+	}
+	return d.m.Delete(ctx, name)
+}
+
+// Mount attaches the volume to the current node and mounts it, formatting it
+// first if there is no filesystem on it yet. The other requests aren't blocked
+// while waiting for the attachment, except the ones for the same volume.
+func (d *DockerVolumeDriver) Mount(ctx context.Context, name, id string) (mountpoint string, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to mount docker volume %v", name)
+	}()
+
+	mountpoint = d.getMountpoint(name)
+
+	d.lock.Lock()
+	d.waitForMountingLocked(name)
+	if len(d.mounts[name]) != 0 {
+		d.mounts[name][id] = struct{}{}
+		d.lock.Unlock()
+		return mountpoint, nil
+	}
+	mounting := make(chan struct{})
+	d.mounting[name] = mounting
+	d.lock.Unlock()
+
+	defer func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		if err == nil {
+			d.mounts[name] = map[string]struct{}{id: {}}
+		}
+		delete(d.mounting, name)
+		close(mounting)
+	}()
+
+	if _, err := d.m.Attach(ctx, name, d.m.currentNodeID, false, ""); err != nil {
+		return "", err
+	}
+	if err := d.waitForVolume(name, "attached", func(v *longhorn.Volume) bool {
+		return v.Status.State == longhorn.VolumeStateAttached && v.Status.CurrentNodeID == d.m.currentNodeID
+	}); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(mountpoint, 0750); err != nil {
+		return "", err
+	}
+	notMnt, err := mount.IsNotMountPoint(d.mounter, mountpoint)
+	if err != nil {
+		return "", err
+	}
+	if notMnt {
+		if err := d.mounter.FormatAndMount(util.RegularDeviceDirectory+name, mountpoint, dockerVolumeFilesystem, nil); err != nil {
+			return "", err
+		}
+	}
+
+	util.WithRequestIDField(ctx, logrus.StandardLogger()).Infof("Mounted docker volume %v at %v", name, mountpoint)
+	return mountpoint, nil
+}
+
+// Unmount releases the mount request, then unmounts and detaches the volume
+// if it was the last one.
+func (d *DockerVolumeDriver) Unmount(ctx context.Context, name, id string) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to unmount docker volume %v", name)
+	}()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.waitForMountingLocked(name)
+	delete(d.mounts[name], id)
+	if len(d.mounts[name]) != 0 {
+		return nil
+	}
+	delete(d.mounts, name)
+
+	mountpoint := d.getMountpoint(name)
+	if err := mount.CleanupMountPoint(mountpoint, d.mounter, false); err != nil {
+		return err
+	}
+	if _, err := d.m.Detach(ctx, name, d.m.currentNodeID, false); err != nil {
+		return err
+	}
+	util.WithRequestIDField(ctx, logrus.StandardLogger()).Infof("Unmounted docker volume %v from %v", name, mountpoint)
+	return nil
+}
+
+func (d *DockerVolumeDriver) Get(name string) (*DockerVolume, error) {
+	v, err := d.m.Get(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get docker volume %v", name)
+	}
+	return d.toDockerVolume(v), nil
+}
+
+func (d *DockerVolumeDriver) List() ([]*DockerVolume, error) {
+	volumes, err := d.m.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list docker volumes")
+	}
+	dockerVolumes := []*DockerVolume{}
+	for _, v := range volumes {
+		dockerVolumes = append(dockerVolumes, d.toDockerVolume(v))
+	}
+	sort.Slice(dockerVolumes, func(i, j int) bool {
+		return dockerVolumes[i].Name < dockerVolumes[j].Name
+	})
+	return dockerVolumes, nil
+}
+
+// Path returns the mountpoint of the volume, or an empty string if the volume
+// is not mounted.
+func (d *DockerVolumeDriver) Path(name string) (string, error) {
+	v, err := d.Get(name)
+	if err != nil {
+		return "", err
+	}
+	return v.Mountpoint, nil
+}
+
+func (d *DockerVolumeDriver) toDockerVolume(v *longhorn.Volume) *DockerVolume {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	dv := &DockerVolume{
+		Name:      v.Name,
+		CreatedAt: v.CreationTimestamp.UTC().Format(time.RFC3339),
+		Status: map[string]interface{}{
+			"state":            v.Status.State,
+			"robustness":       v.Status.Robustness,
+			"size":             v.Spec.Size,
+			"numberOfReplicas": v.Spec.NumberOfReplicas,
+			"node":             v.Status.CurrentNodeID,
+		},
+	}
+	if len(d.mounts[v.Name]) != 0 {
+		dv.Mountpoint = d.getMountpoint(v.Name)
+	}
+	return dv
+}
+
+func (d *DockerVolumeDriver) waitForVolume(name, description string, check func(v *longhorn.Volume) bool) error {
+	deadline := time.Now().Add(dockerVolumeWaitTimeout)
+	for time.Now().Before(deadline) {
+		v, err := d.m.ds.GetVolumeRO(name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return err
+			}
+			logrus.WithError(err).Warnf("Failed to get volume %v while waiting for it to be %v", name, description)
+		} else if check(v) {
+			return nil
+		}
+		time.Sleep(dockerVolumeCheckInterval)
+	}
+	return fmt.Errorf("timeout waiting for volume %v to be %v", name, description)
+}
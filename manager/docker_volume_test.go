@@ -0,0 +1,112 @@
+package manager_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/mount-utils"
+
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func TestDockerVolumeMount(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// The volume is requested to be attached to the node but not attached yet
+	v, e, ei := newRunningVolumeObjects(testNode1)
+	v.Status.State = longhorn.VolumeStateAttaching
+	r := &longhorn.Replica{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testVolumeName + "-r-0",
+			Namespace: testNamespace,
+			Labels:    types.GetVolumeLabels(testVolumeName),
+		},
+		Spec: longhorn.ReplicaSpec{
+			InstanceSpec: longhorn.InstanceSpec{
+				VolumeName: testVolumeName,
+				NodeID:     testNode1,
+			},
+		},
+	}
+	c, err := fake.NewCluster(testNamespace, stopCh, v, e, r, ei, newReadyNode(testNode1))
+	assert.NoError(err)
+	d := manager.NewDockerVolumeDriver(c.NewVolumeManager(testNode1))
+
+	// The mountpoint is already mounted so the fake mounter doesn't format it
+	mountDirectory := t.TempDir()
+	mountpoint := filepath.Join(mountDirectory, testVolumeName)
+	mounter := mount.NewFakeMounter([]mount.MountPoint{{Device: "/dev/longhorn/" + testVolumeName, Path: mountpoint}})
+	d.SetMounter(&mount.SafeFormatAndMount{Interface: mounter}, mountDirectory)
+
+	type result struct {
+		mountpoint string
+		err        error
+	}
+	mountInBackground := func(id string) <-chan result {
+		ch := make(chan result, 1)
+		go func() {
+			mountpoint, err := d.Mount(context.Background(), testVolumeName, id)
+			ch <- result{mountpoint, err}
+		}()
+		return ch
+	}
+	mount1 := mountInBackground("id-1")
+	mount2 := mountInBackground("id-2")
+	// The mounts keep waiting for at least one check interval
+	time.Sleep(200 * time.Millisecond)
+
+	// The other requests are served while waiting for the attachment
+	listed := make(chan error, 1)
+	go func() {
+		_, err := d.List()
+		listed <- err
+	}()
+	select {
+	case err := <-listed:
+		assert.NoError(err)
+	case <-time.After(5 * time.Second):
+		assert.FailNow("list is blocked by the pending mount")
+	}
+	assert.Error(d.Remove(context.Background(), testVolumeName))
+	path, err := d.Path(testVolumeName)
+	assert.NoError(err)
+	assert.Empty(path)
+
+	v, err = c.DataStore.GetVolume(testVolumeName)
+	assert.NoError(err)
+	v.Status.State = longhorn.VolumeStateAttached
+	v.Status.CurrentNodeID = testNode1
+	_, err = c.DataStore.UpdateVolumeStatus(v)
+	assert.NoError(err)
+
+	for _, ch := range []<-chan result{mount1, mount2} {
+		select {
+		case r := <-ch:
+			assert.NoError(r.err)
+			assert.Equal(mountpoint, r.mountpoint)
+		case <-time.After(10 * time.Second):
+			assert.FailNow("timeout waiting for the mount")
+		}
+	}
+	path, err = d.Path(testVolumeName)
+	assert.NoError(err)
+	assert.Equal(mountpoint, path)
+
+	// The volume is unmounted once all the mount requests are released
+	assert.NoError(d.Unmount(context.Background(), testVolumeName, "id-1"))
+	assert.Len(mounter.GetLog(), 0)
+	assert.NoError(d.Unmount(context.Background(), testVolumeName, "id-2"))
+	assert.Equal([]mount.FakeAction{{Action: mount.FakeActionUnmount, Target: mountpoint}}, mounter.GetLog())
+}
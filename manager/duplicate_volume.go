@@ -0,0 +1,173 @@
+package manager
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+const (
+	DuplicateVolumeReasonSameBackup       = "restored from the same backup"
+	DuplicateVolumeReasonCloned           = "cloned from another volume"
+	DuplicateVolumeReasonSnapshotChecksum = "snapshots with identical checksum"
+)
+
+type DuplicateVolumeGroup struct {
+	Volumes []string `json:"volumes"`
+	Reasons []string `json:"reasons"`
+	// The estimated space saved by sharing the common content through a
+	// backing image or clones, i.e. the smallest actual size of the volumes
+	// for each replica of the volumes but the ones of the volume with the
+	// most replicas.
+	PotentialSavings int64 `json:"potentialSavings"`
+}
+
+type DuplicateVolumeReport struct {
+	Groups                []*DuplicateVolumeGroup `json:"groups"`
+	TotalPotentialSavings int64                   `json:"totalPotentialSavings"`
+}
+
+// duplicateVolumeSets is a union-find of the volume names, with the reasons
+// volumes were found to share content.
+type duplicateVolumeSets struct {
+	parent  map[string]string
+	reasons map[string]map[string]struct{}
+}
+
+func (d *duplicateVolumeSets) find(name string) string {
+	for d.parent[name] != name {
+		d.parent[name] = d.parent[d.parent[name]]
+		name = d.parent[name]
+	}
+	return name
+}
+
+func (d *duplicateVolumeSets) union(a, b, reason string) {
+	if _, ok := d.parent[a]; !ok {
+		return
+	}
+	if _, ok := d.parent[b]; !ok {
+		return
+	}
+	rootA, rootB := d.find(a), d.find(b)
+	if rootA != rootB {
+		d.parent[rootB] = rootA
+		for r := range d.reasons[rootB] {
+			d.reasons[rootA][r] = struct{}{}
+		}
+		delete(d.reasons, rootB)
+	}
+	d.reasons[rootA][reason] = struct{}{}
+}
+
+// GetDuplicateVolumeReport finds the volumes likely to have the same content
+// lineage: restored from the same backup, cloned from each other, or having
+// snapshots with the same checksum. It's advisory only, the volumes may have
+// diverged since.
+func (m *VolumeManager) GetDuplicateVolumeReport() (report *DuplicateVolumeReport, err error) {
+	defer func() {
+		err = errors.Wrap(err, "unable to get duplicate volume report")
+	}()
+
+	volumes, err := m.ds.ListVolumesRO()
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := m.ds.ListSnapshotsRO(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	sets := &duplicateVolumeSets{
+		parent:  map[string]string{},
+		reasons: map[string]map[string]struct{}{},
+	}
+	sizes := map[string]int64{}
+	replicaCounts := map[string]int{}
+	for _, v := range volumes {
+		sets.parent[v.Name] = v.Name
+		sets.reasons[v.Name] = map[string]struct{}{}
+		sizes[v.Name] = v.Status.ActualSize
+		replicaCounts[v.Name] = v.Spec.NumberOfReplicas
+	}
+
+	backupVolumes := map[string]string{}
+	for _, v := range volumes {
+		if v.Spec.FromBackup != "" {
+			if other, ok := backupVolumes[v.Spec.FromBackup]; ok {
+				sets.union(other, v.Name, DuplicateVolumeReasonSameBackup)
+			} else {
+				backupVolumes[v.Spec.FromBackup] = v.Name
+			}
+		}
+		if types.IsValidVolumeDataSource(v.Spec.DataSource) {
+			sets.union(types.GetVolumeName(v.Spec.DataSource), v.Name, DuplicateVolumeReasonCloned)
+		}
+	}
+
+	checksumVolumes := map[string]string{}
+	for _, snapshot := range snapshots {
+		// The empty snapshots have the same checksum regardless of the
+		// content of the volumes
+		if snapshot.Status.Checksum == "" || snapshot.Status.Size == 0 {
+			continue
+		}
+		if other, ok := checksumVolumes[snapshot.Status.Checksum]; ok {
+			if other != snapshot.Spec.Volume {
+				sets.union(other, snapshot.Spec.Volume, DuplicateVolumeReasonSnapshotChecksum)
+			}
+		} else {
+			checksumVolumes[snapshot.Status.Checksum] = snapshot.Spec.Volume
+		}
+	}
+
+	members := map[string][]string{}
+	for name := range sets.parent {
+		root := sets.find(name)
+		members[root] = append(members[root], name)
+	}
+
+	report = &DuplicateVolumeReport{
+		Groups: []*DuplicateVolumeGroup{},
+	}
+	for root, names := range members {
+		if len(names) < 2 {
+			continue
+		}
+		sort.Strings(names)
+		group := &DuplicateVolumeGroup{
+			Volumes: names,
+			Reasons: []string{},
+		}
+		for reason := range sets.reasons[root] {
+			group.Reasons = append(group.Reasons, reason)
+		}
+		sort.Strings(group.Reasons)
+
+		minSize := sizes[names[0]]
+		replicaCount, maxReplicaCount := 0, 0
+		for _, name := range names {
+			if sizes[name] < minSize {
+				minSize = sizes[name]
+			}
+			replicaCount += replicaCounts[name]
+			if replicaCounts[name] > maxReplicaCount {
+				maxReplicaCount = replicaCounts[name]
+			}
+		}
+		group.PotentialSavings = minSize * int64(replicaCount-maxReplicaCount)
+		report.TotalPotentialSavings += group.PotentialSavings
+		report.Groups = append(report.Groups, group)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.Groups[i].PotentialSavings != report.Groups[j].PotentialSavings {
+			return report.Groups[i].PotentialSavings > report.Groups[j].PotentialSavings
+		}
+		return report.Groups[i].Volumes[0] < report.Groups[j].Volumes[0]
+	})
+	return report, nil
+}
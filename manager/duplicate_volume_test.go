@@ -0,0 +1,69 @@
+package manager_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/test/fake"
+)
+
+func newChecksumSnapshot(name, volumeName string, size int64, checksum string) runtime.Object {
+	snapshot := newCapacitySnapshot(name, volumeName, size)
+	snapshot.Status.Checksum = checksum
+	return snapshot
+}
+
+func TestGetDuplicateVolumeReport(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	restored1 := newCapacityVolume("restored-1", testVolumeSize, 3, 1000)
+	restored1.Spec.FromBackup = "s3://backupbucket@us-east-1/?backup=backup-1&volume=vol"
+	restored2 := newCapacityVolume("restored-2", testVolumeSize, 2, 800)
+	restored2.Spec.FromBackup = restored1.Spec.FromBackup
+
+	objects := []runtime.Object{
+		restored1,
+		restored2,
+		newCapacityVolume("vol-a", testVolumeSize, 2, 500),
+		newCapacityVolume("vol-b", testVolumeSize, 2, 600),
+		newCapacityVolume("vol-c", testVolumeSize, 2, 700),
+		newCapacityVolume("vol-d", testVolumeSize, 2, 700),
+		newChecksumSnapshot("snap-a", "vol-a", 100, "checksum-1"),
+		newChecksumSnapshot("snap-b", "vol-b", 100, "checksum-1"),
+		// The empty snapshots or the ones not checked yet don't tell anything
+		// about the content
+		newChecksumSnapshot("snap-c", "vol-c", 0, "checksum-empty"),
+		newChecksumSnapshot("snap-d", "vol-d", 0, "checksum-empty"),
+		newChecksumSnapshot("snap-c-2", "vol-c", 100, ""),
+		newChecksumSnapshot("snap-d-2", "vol-d", 100, ""),
+	}
+	c, err := fake.NewCluster(testNamespace, stopCh, objects...)
+	assert.NoError(err)
+
+	report, err := c.NewVolumeManager(testNode1).GetDuplicateVolumeReport()
+	assert.NoError(err)
+	assert.Equal(&manager.DuplicateVolumeReport{
+		Groups: []*manager.DuplicateVolumeGroup{
+			{
+				Volumes: []string{"restored-1", "restored-2"},
+				Reasons: []string{manager.DuplicateVolumeReasonSameBackup},
+				// The 2 replicas of restored-2 share the content of the
+				// 3 replicas of restored-1
+				PotentialSavings: 800 * 2,
+			},
+			{
+				Volumes:          []string{"vol-a", "vol-b"},
+				Reasons:          []string{manager.DuplicateVolumeReasonSnapshotChecksum},
+				PotentialSavings: 500 * 2,
+			},
+		},
+		TotalPotentialSavings: 800*2 + 500*2,
+	}, report)
+}
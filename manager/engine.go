@@ -1,7 +1,9 @@
 package manager
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -11,7 +13,10 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	etypes "github.com/longhorn/longhorn-engine/pkg/types"
+
 	"github.com/longhorn/longhorn-manager/engineapi"
+	"github.com/longhorn/longhorn-manager/types"
 	"github.com/longhorn/longhorn-manager/util"
 
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
@@ -21,64 +26,95 @@ const (
 	BackupStatusQueryInterval = 2 * time.Second
 )
 
-func (m *VolumeManager) ListSnapshotInfos(volumeName string) (map[string]*longhorn.SnapshotInfo, error) {
+func (m *VolumeManager) ListSnapshotInfos(ctx context.Context, volumeName string) (snapshots map[string]*longhorn.SnapshotInfo, err error) {
 	if volumeName == "" {
 		return nil, fmt.Errorf("volume name required")
 	}
 
-	engineCliClient, err := engineapi.GetEngineBinaryClient(m.ds, volumeName, m.currentNodeID)
+	err = m.runEngineOperation(ctx, OperationClassEngineQuery, volumeName, func(e *longhorn.Engine, engineClientProxy engineapi.EngineClientProxy) (err error) {
+		snapshots, err = engineClientProxy.SnapshotList(e)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	return snapshots, nil
+}
 
-	engine, err := m.GetRunningEngineByVolume(volumeName)
-	if err != nil {
-		return nil, err
+func (m *VolumeManager) GetSnapshotInfo(ctx context.Context, snapshotName, volumeName string) (snapshot *longhorn.SnapshotInfo, err error) {
+	if volumeName == "" || snapshotName == "" {
+		return nil, fmt.Errorf("volume and snapshot name required")
 	}
 
-	engineClientProxy, err := engineapi.GetCompatibleClient(engine, engineCliClient, m.ds, nil, m.proxyConnCounter)
+	err = m.runEngineOperation(ctx, OperationClassEngineQuery, volumeName, func(e *longhorn.Engine, engineClientProxy engineapi.EngineClientProxy) (err error) {
+		snapshot, err = engineClientProxy.SnapshotGet(e, snapshotName)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer engineClientProxy.Close()
 
-	return engineClientProxy.SnapshotList(engine)
+	if snapshot == nil {
+		return nil, fmt.Errorf("cannot find snapshot '%s' for volume '%s'", snapshotName, volumeName)
+	}
+
+	return snapshot, nil
 }
 
-func (m *VolumeManager) GetSnapshotInfo(snapshotName, volumeName string) (*longhorn.SnapshotInfo, error) {
-	if volumeName == "" || snapshotName == "" {
-		return nil, fmt.Errorf("volume and snapshot name required")
-	}
+// GetEngineReplicaList returns the replicas of the running engine of the
+// volume, cached for the engine status cache TTL.
+func (m *VolumeManager) GetEngineReplicaList(ctx context.Context, volumeName string) (replicas map[string]*engineapi.Replica, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to get replica list of volume %v", volumeName)
+	}()
 
-	engineCliClient, err := engineapi.GetEngineBinaryClient(m.ds, volumeName, m.currentNodeID)
+	value, err := m.getEngineStatus(ctx, volumeName, engineapi.StatusCacheCallReplicaList, func(e *longhorn.Engine, engineClientProxy engineapi.EngineClientProxy) (interface{}, error) {
+		return engineClientProxy.ReplicaList(e)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return value.(map[string]*engineapi.Replica), nil
+}
 
-	engine, err := m.GetRunningEngineByVolume(volumeName)
+// GetEngineInfo returns the controller info of the running engine of the
+// volume, cached for the engine status cache TTL.
+func (m *VolumeManager) GetEngineInfo(ctx context.Context, volumeName string) (info *engineapi.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to get controller info of volume %v", volumeName)
+	}()
+
+	value, err := m.getEngineStatus(ctx, volumeName, engineapi.StatusCacheCallVolumeGet, func(e *longhorn.Engine, engineClientProxy engineapi.EngineClientProxy) (interface{}, error) {
+		return engineClientProxy.VolumeGet(e)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return value.(*engineapi.Volume), nil
+}
 
-	engineClientProxy, err := engineapi.GetCompatibleClient(engine, engineCliClient, m.ds, nil, m.proxyConnCounter)
-	if err != nil {
-		return nil, err
+func (m *VolumeManager) getEngineStatus(ctx context.Context, volumeName, call string, fn func(e *longhorn.Engine, engineClientProxy engineapi.EngineClientProxy) (interface{}, error)) (interface{}, error) {
+	if volumeName == "" {
+		return nil, fmt.Errorf("volume name required")
 	}
-	defer engineClientProxy.Close()
 
-	snapshot, err := engineClientProxy.SnapshotGet(engine, snapshotName)
+	ttl, err := m.ds.GetSettingAsInt(types.SettingNameEngineStatusCacheTTL)
 	if err != nil {
 		return nil, err
 	}
-
-	if snapshot == nil {
-		return nil, fmt.Errorf("cannot find snapshot '%s' for volume '%s'", snapshotName, volumeName)
-	}
-
-	return snapshot, nil
+	return m.engineStatusCache.Get(volumeName, call, time.Duration(ttl)*time.Second, func() (value interface{}, err error) {
+		err = m.runEngineOperation(ctx, OperationClassEngineQuery, volumeName, func(e *longhorn.Engine, engineClientProxy engineapi.EngineClientProxy) (err error) {
+			value, err = fn(e, engineClientProxy)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return value, nil
+	})
 }
 
-func (m *VolumeManager) CreateSnapshot(snapshotName string, labels map[string]string, volumeName string) (*longhorn.SnapshotInfo, error) {
+func (m *VolumeManager) CreateSnapshot(ctx context.Context, snapshotName string, labels map[string]string, volumeName string) (snap *longhorn.SnapshotInfo, err error) {
 	if volumeName == "" {
 		return nil, fmt.Errorf("volume name required")
 	}
@@ -88,33 +124,40 @@ func (m *VolumeManager) CreateSnapshot(snapshotName string, labels map[string]st
 			return nil, fmt.Errorf("labels cannot contain '='")
 		}
 	}
-
-	if err := m.checkVolumeNotInMigration(volumeName); err != nil {
-		return nil, err
+	if err := types.ValidateUserSnapshot(snapshotName, labels); err != nil {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter, map[string]string{types.ErrorParameterName: snapshotName}, "%v", err)
 	}
 
-	engineCliClient, err := engineapi.GetEngineBinaryClient(m.ds, volumeName, m.currentNodeID)
-	if err != nil {
-		return nil, err
-	}
-
-	e, err := m.GetRunningEngineByVolume(volumeName)
-	if err != nil {
-		return nil, err
-	}
-
-	engineClientProxy, err := engineapi.GetCompatibleClient(e, engineCliClient, m.ds, nil, m.proxyConnCounter)
-	if err != nil {
+	if err := m.checkVolumeNotInMigration(volumeName); err != nil {
 		return nil, err
 	}
-	defer engineClientProxy.Close()
 
-	snapshotName, err = engineClientProxy.SnapshotCreate(e, snapshotName, labels)
-	if err != nil {
-		return nil, err
-	}
+	err = m.runEngineOperation(ctx, OperationClassEngineOperation, volumeName, func(e *longhorn.Engine, engineClientProxy engineapi.EngineClientProxy) (err error) {
+		if snapshotName != "" {
+			// The snapshot may be created by a previous request timed out,
+			// so the retry of the request returns it rather than failing.
+			existing, err := engineClientProxy.SnapshotGet(e, snapshotName)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				if existing.Removed || !equalSnapshotLabels(existing.Labels, labels) {
+					return types.NewReasonError(types.ErrorReasonConflict,
+						map[string]string{types.ErrorParameterName: snapshotName},
+						"snapshot %v already exists in volume %v", snapshotName, volumeName)
+				}
+				snap = existing
+				return nil
+			}
+		}
 
-	snap, err := engineClientProxy.SnapshotGet(e, snapshotName)
+		snapshotName, err = engineClientProxy.SnapshotCreate(e, snapshotName, labels)
+		if err != nil {
+			return err
+		}
+		snap, err = engineClientProxy.SnapshotGet(e, snapshotName)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -127,32 +170,30 @@ func (m *VolumeManager) CreateSnapshot(snapshotName string, labels map[string]st
 	return snap, nil
 }
 
-func (m *VolumeManager) DeleteSnapshot(snapshotName, volumeName string) error {
-	if volumeName == "" || snapshotName == "" {
-		return fmt.Errorf("volume and snapshot name required")
-	}
-
-	if err := m.checkVolumeNotInMigration(volumeName); err != nil {
-		return err
+func equalSnapshotLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
 	}
-
-	engineCliClient, err := engineapi.GetEngineBinaryClient(m.ds, volumeName, m.currentNodeID)
-	if err != nil {
-		return err
+	for k, v := range a {
+		if value, ok := b[k]; !ok || value != v {
+			return false
+		}
 	}
+	return true
+}
 
-	engine, err := m.GetRunningEngineByVolume(volumeName)
-	if err != nil {
-		return err
+func (m *VolumeManager) DeleteSnapshot(ctx context.Context, snapshotName, volumeName string) error {
+	if volumeName == "" || snapshotName == "" {
+		return fmt.Errorf("volume and snapshot name required")
 	}
 
-	engineClientProxy, err := engineapi.GetCompatibleClient(engine, engineCliClient, m.ds, nil, m.proxyConnCounter)
-	if err != nil {
+	if err := m.checkVolumeNotInMigration(volumeName); err != nil {
 		return err
 	}
-	defer engineClientProxy.Close()
 
-	if err := engineClientProxy.SnapshotDelete(engine, snapshotName); err != nil {
+	if err := m.runEngineOperation(ctx, OperationClassEngineOperation, volumeName, func(e *longhorn.Engine, engineClientProxy engineapi.EngineClientProxy) error {
+		return engineClientProxy.SnapshotDelete(e, snapshotName)
+	}); err != nil {
 		return err
 	}
 
@@ -160,7 +201,7 @@ func (m *VolumeManager) DeleteSnapshot(snapshotName, volumeName string) error {
 	return nil
 }
 
-func (m *VolumeManager) RevertSnapshot(snapshotName, volumeName string) error {
+func (m *VolumeManager) RevertSnapshot(ctx context.Context, snapshotName, volumeName string) error {
 	if volumeName == "" || snapshotName == "" {
 		return fmt.Errorf("volume and snapshot name required")
 	}
@@ -169,36 +210,22 @@ func (m *VolumeManager) RevertSnapshot(snapshotName, volumeName string) error {
 		return err
 	}
 
-	engineCliClient, err := engineapi.GetEngineBinaryClient(m.ds, volumeName, m.currentNodeID)
-	if err != nil {
-		return err
-	}
-
-	engine, err := m.GetRunningEngineByVolume(volumeName)
-	if err != nil {
-		return err
-	}
-
-	engineClientProxy, err := engineapi.GetCompatibleClient(engine, engineCliClient, m.ds, nil, m.proxyConnCounter)
-	if err != nil {
-		return err
-	}
-	defer engineClientProxy.Close()
-
-	snapshot, err := engineClientProxy.SnapshotGet(engine, snapshotName)
-	if err != nil {
-		return err
-	}
+	if err := m.runEngineOperation(ctx, OperationClassEngineOperation, volumeName, func(e *longhorn.Engine, engineClientProxy engineapi.EngineClientProxy) error {
+		snapshot, err := engineClientProxy.SnapshotGet(e, snapshotName)
+		if err != nil {
+			return err
+		}
 
-	if snapshot == nil {
-		return fmt.Errorf("not found snapshot '%s', for volume '%s'", snapshotName, volumeName)
-	}
+		if snapshot == nil {
+			return fmt.Errorf("not found snapshot '%s', for volume '%s'", snapshotName, volumeName)
+		}
 
-	if snapshot.Removed {
-		return fmt.Errorf("not revert to snapshot '%s' for volume '%s' since it's marked as Removed", snapshotName, volumeName)
-	}
+		if snapshot.Removed {
+			return fmt.Errorf("not revert to snapshot '%s' for volume '%s' since it's marked as Removed", snapshotName, volumeName)
+		}
 
-	if err := engineClientProxy.SnapshotRevert(engine, snapshotName); err != nil {
+		return engineClientProxy.SnapshotRevert(e, snapshotName)
+	}); err != nil {
 		return err
 	}
 
@@ -206,7 +233,7 @@ func (m *VolumeManager) RevertSnapshot(snapshotName, volumeName string) error {
 	return nil
 }
 
-func (m *VolumeManager) PurgeSnapshot(volumeName string) error {
+func (m *VolumeManager) PurgeSnapshot(ctx context.Context, volumeName string) error {
 	if volumeName == "" {
 		return fmt.Errorf("volume name required")
 	}
@@ -215,23 +242,9 @@ func (m *VolumeManager) PurgeSnapshot(volumeName string) error {
 		return err
 	}
 
-	engineCliClient, err := engineapi.GetEngineBinaryClient(m.ds, volumeName, m.currentNodeID)
-	if err != nil {
-		return err
-	}
-
-	engine, err := m.GetRunningEngineByVolume(volumeName)
-	if err != nil {
-		return err
-	}
-
-	engineClientProxy, err := engineapi.GetCompatibleClient(engine, engineCliClient, m.ds, nil, m.proxyConnCounter)
-	if err != nil {
-		return err
-	}
-	defer engineClientProxy.Close()
-
-	if err := engineClientProxy.SnapshotPurge(engine); err != nil {
+	if err := m.runEngineOperation(ctx, OperationClassEngineOperation, volumeName, func(e *longhorn.Engine, engineClientProxy engineapi.EngineClientProxy) error {
+		return engineClientProxy.SnapshotPurge(e)
+	}); err != nil {
 		return err
 	}
 
@@ -283,25 +296,28 @@ func (m *VolumeManager) GetRunningEngineByVolume(name string) (e *longhorn.Engin
 	}
 
 	if len(es) == 0 {
-		return nil, errors.Errorf("cannot find engine")
+		return nil, types.NewReasonError(types.ErrorReasonNotFound, map[string]string{types.ErrorParameterKind: "engine"}, "cannot find engine")
 	}
 
 	if len(es) != 1 {
-		return nil, errors.Errorf("more than one engine exists")
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, nil, "more than one engine exists")
 	}
 
 	for _, e = range es {
 		break
 	}
 	if e.Status.CurrentState != longhorn.InstanceStateRunning {
-		return nil, errors.Errorf("engine is not running")
+		return nil, types.NewReasonError(types.ErrorReasonEngineUnavailable,
+			map[string]string{types.ErrorParameterEngine: e.Name, types.ErrorParameterState: string(e.Status.CurrentState)},
+			"engine is not running")
 	}
 
 	if isReady, err := m.ds.CheckEngineImageReadiness(e.Status.CurrentImage, m.currentNodeID); !isReady {
 		if err != nil {
 			return nil, errors.Errorf("cannot get engine with image %v: %v", e.Status.CurrentImage, err)
 		}
-		return nil, errors.Errorf("cannot get engine with image %v because it isn't deployed on this node", e.Status.CurrentImage)
+		return nil, types.NewReasonError(types.ErrorReasonEngineUnavailable, map[string]string{types.ErrorParameterEngine: e.Name},
+			"cannot get engine with image %v because it isn't deployed on this node", e.Status.CurrentImage)
 	}
 
 	return e, nil
@@ -323,6 +339,40 @@ func (m *VolumeManager) ListBackupTargetsSorted() ([]*longhorn.BackupTarget, err
 	return backupTargets, nil
 }
 
+// TestBackupTarget verifies the backup target URL and the credential secret can
+// be used to access the backupstore, before applying them to the settings.
+func (m *VolumeManager) TestBackupTarget(backupTargetURL, credentialSecret string) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to access backup target %v", backupTargetURL)
+	}()
+
+	if backupTargetURL == "" {
+		return fmt.Errorf("backup target URL is empty")
+	}
+	if err := types.ValidateBackupTargetURL(backupTargetURL); err != nil {
+		return err
+	}
+
+	backupTarget := &longhorn.BackupTarget{
+		Spec: longhorn.BackupTargetSpec{
+			BackupTargetURL:  backupTargetURL,
+			CredentialSecret: credentialSecret,
+		},
+	}
+	backupTargetClient, err := engineapi.NewBackupTargetClientFromBackupTarget(backupTarget, m.ds)
+	if err != nil {
+		return err
+	}
+
+	// Listing the backup volumes requires both the connectivity and the read permission of the backupstore
+	if _, err := backupTargetClient.BackupVolumeNameList(backupTargetClient.URL, backupTargetClient.Credential); err != nil {
+		return err
+	}
+
+	logrus.Infof("Tested backup target %v", backupTargetURL)
+	return nil
+}
+
 func (m *VolumeManager) ListBackupVolumes() (map[string]*longhorn.BackupVolume, error) {
 	return m.ds.ListBackupVolumes()
 }
@@ -405,3 +455,122 @@ func (m *VolumeManager) GetBackup(backupName, volumeName string) (*longhorn.Back
 func (m *VolumeManager) DeleteBackup(backupName, volumeName string) error {
 	return m.ds.DeleteBackup(backupName)
 }
+
+type VolumeBackupComparison struct {
+	VolumeName            string   `json:"volumeName"`
+	BackupName            string   `json:"backupName"`
+	SnapshotName          string   `json:"snapshotName"`
+	SnapshotChecksum      string   `json:"snapshotChecksum"`
+	SnapshotExists        bool     `json:"snapshotExists"`
+	SnapshotInVolumeChain bool     `json:"snapshotInVolumeChain"`
+	Diverged              bool     `json:"diverged"`
+	DivergedSize          int64    `json:"divergedSize"`
+	Estimated             bool     `json:"estimated"`
+	ChangedSnapshots      []string `json:"changedSnapshots"`
+}
+
+// CompareVolumeWithBackup reports how much data differs between the current
+// volume content, which is the snapshot chain of the volume head, and the
+// snapshot of the backup. If the snapshot still exists, the chains are
+// compared exactly: the snapshots on only one of the chains differ, e.g. the
+// snapshots taken after the backup, or the ones dropped by a revert to an
+// earlier snapshot. Otherwise the size of the snapshots of the volume chain
+// created after the backup snapshot is used as an estimation.
+func (m *VolumeManager) CompareVolumeWithBackup(ctx context.Context, volumeName, backupName string) (comparison *VolumeBackupComparison, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to compare volume %v with backup %v", volumeName, backupName)
+	}()
+
+	backup, err := m.ds.GetBackupRO(backupName)
+	if err != nil {
+		return nil, err
+	}
+	if backup.Status.VolumeName != volumeName {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "backup", types.ErrorParameterValue: backupName},
+			"backup %v belongs to volume %v", backupName, backup.Status.VolumeName)
+	}
+	if backup.Status.State != longhorn.BackupStateCompleted {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState,
+			map[string]string{types.ErrorParameterState: string(backup.Status.State)},
+			"backup %v is in state %v", backupName, backup.Status.State)
+	}
+
+	snapshots, err := m.ListSnapshotInfos(ctx, volumeName)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison = &VolumeBackupComparison{
+		VolumeName:       volumeName,
+		BackupName:       backupName,
+		SnapshotName:     backup.Status.SnapshotName,
+		ChangedSnapshots: []string{},
+	}
+
+	volumeChain := getSnapshotChain(snapshots, etypes.VolumeHeadName)
+	changed := map[string]*longhorn.SnapshotInfo{}
+	if _, ok := snapshots[backup.Status.SnapshotName]; ok {
+		comparison.SnapshotExists = true
+		comparison.SnapshotInVolumeChain = volumeChain[backup.Status.SnapshotName] != nil
+		if snapshot, err := m.ds.GetSnapshotRO(backup.Status.SnapshotName); err == nil {
+			comparison.SnapshotChecksum = snapshot.Status.Checksum
+		} else if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		backupChain := getSnapshotChain(snapshots, backup.Status.SnapshotName)
+		for name, snapshot := range volumeChain {
+			if backupChain[name] == nil {
+				changed[name] = snapshot
+			}
+		}
+		for name, snapshot := range backupChain {
+			if volumeChain[name] == nil {
+				changed[name] = snapshot
+			}
+		}
+	} else {
+		comparison.Estimated = true
+		backupSnapshotCreatedAt, err := time.Parse(time.RFC3339, backup.Status.SnapshotCreatedAt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse snapshot creation time of backup %v", backupName)
+		}
+		for name, snapshot := range volumeChain {
+			created, err := time.Parse(time.RFC3339, snapshot.Created)
+			if err != nil || created.After(backupSnapshotCreatedAt) || name == etypes.VolumeHeadName {
+				changed[name] = snapshot
+			}
+		}
+	}
+
+	for name, snapshot := range changed {
+		size, err := util.ConvertSize(snapshot.Size)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse size of snapshot %v", name)
+		}
+		if size == 0 {
+			continue
+		}
+		comparison.DivergedSize += size
+		comparison.ChangedSnapshots = append(comparison.ChangedSnapshots, name)
+	}
+	sort.Strings(comparison.ChangedSnapshots)
+	comparison.Diverged = comparison.DivergedSize > 0
+
+	return comparison, nil
+}
+
+// getSnapshotChain returns the snapshot and all its ancestors, which hold
+// the content of the snapshot together.
+func getSnapshotChain(snapshots map[string]*longhorn.SnapshotInfo, name string) map[string]*longhorn.SnapshotInfo {
+	chain := map[string]*longhorn.SnapshotInfo{}
+	for name != "" {
+		snapshot, ok := snapshots[name]
+		if !ok || chain[name] != nil {
+			break
+		}
+		chain[name] = snapshot
+		name = snapshot.Parent
+	}
+	return chain
+}
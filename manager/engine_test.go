@@ -0,0 +1,198 @@
+package manager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+const (
+	testVolumeName  = "test-volume"
+	testVolumeSize  = 2 * 1024 * 1024 * 1024
+	testEngineImage = "longhornio/longhorn-engine:latest"
+)
+
+// newRunningVolumeObjects returns the volume attached to the node, its
+// running engine, and the engine image deployed on the node.
+func newRunningVolumeObjects(nodeID string) (*longhorn.Volume, *longhorn.Engine, *longhorn.EngineImage) {
+	v := &longhorn.Volume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testVolumeName,
+			Namespace: testNamespace,
+		},
+		Spec: longhorn.VolumeSpec{
+			Size:             testVolumeSize,
+			NumberOfReplicas: 1,
+			NodeID:           nodeID,
+			EngineImage:      testEngineImage,
+		},
+		Status: longhorn.VolumeStatus{
+			State:        longhorn.VolumeStateAttached,
+			CurrentImage: testEngineImage,
+		},
+	}
+	e := &longhorn.Engine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testVolumeName + "-e-0",
+			Namespace: testNamespace,
+			Labels:    types.GetVolumeLabels(testVolumeName),
+		},
+		Spec: longhorn.EngineSpec{
+			InstanceSpec: longhorn.InstanceSpec{
+				VolumeName:  testVolumeName,
+				VolumeSize:  testVolumeSize,
+				NodeID:      nodeID,
+				EngineImage: testEngineImage,
+			},
+		},
+		Status: longhorn.EngineStatus{
+			InstanceStatus: longhorn.InstanceStatus{
+				CurrentState: longhorn.InstanceStateRunning,
+				CurrentImage: testEngineImage,
+			},
+		},
+	}
+	ei := &longhorn.EngineImage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.GetEngineImageChecksumName(testEngineImage),
+			Namespace: testNamespace,
+		},
+		Spec: longhorn.EngineImageSpec{
+			Image: testEngineImage,
+		},
+		Status: longhorn.EngineImageStatus{
+			State:             longhorn.EngineImageStateDeployed,
+			NodeDeploymentMap: map[string]bool{nodeID: true},
+		},
+	}
+	return v, e, ei
+}
+
+func TestCreateSnapshotTimeout(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	v, e, ei := newRunningVolumeObjects(testNode1)
+	timeoutSetting := &longhorn.Setting{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      string(types.SettingNameEngineOperationTimeout),
+			Namespace: testNamespace,
+		},
+		Value: "1",
+	}
+	c, err := fake.NewCluster(testNamespace, stopCh, newReadyNode(testNode1), v, e, ei, timeoutSetting)
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+
+	// The engine replies after the caller times out
+	c.Faults.Add(fake.Fault{Operation: "SnapshotCreate", Target: testVolumeName, Latency: 1500 * time.Millisecond, Times: 1})
+	labels := map[string]string{"app": "test"}
+	_, err = m.CreateSnapshot(context.Background(), "snap-1", labels, testVolumeName)
+	assert.Equal(types.ErrorReasonTimeout, types.GetReasonError(err).Reason, "unexpected error %v", err)
+
+	// The retry is rejected while the snapshot is still being created
+	_, err = m.CreateSnapshot(context.Background(), "snap-1", labels, testVolumeName)
+	assert.Equal(types.ErrorReasonConflict, types.GetReasonError(err).Reason, "unexpected error %v", err)
+	err = m.DeleteSnapshot(context.Background(), "snap-1", testVolumeName)
+	assert.Equal(types.ErrorReasonConflict, types.GetReasonError(err).Reason, "unexpected error %v", err)
+
+	// Then the retry returns the snapshot created by the first request
+	var snap *longhorn.SnapshotInfo
+	assert.Eventually(func() bool {
+		snap, err = m.CreateSnapshot(context.Background(), "snap-1", labels, testVolumeName)
+		return err == nil
+	}, 10*time.Second, 50*time.Millisecond)
+	assert.Equal("snap-1", snap.Name)
+	assert.Equal(labels, snap.Labels)
+
+	snapshots, err := m.ListSnapshotInfos(context.Background(), testVolumeName)
+	assert.NoError(err)
+	assert.Len(snapshots, 2)
+
+	// The same name with different labels isn't the same snapshot
+	_, err = m.CreateSnapshot(context.Background(), "snap-1", map[string]string{"app": "other"}, testVolumeName)
+	assert.Equal(types.ErrorReasonConflict, types.GetReasonError(err).Reason, "unexpected error %v", err)
+}
+
+func TestCompareVolumeWithBackup(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	v, e, ei := newRunningVolumeObjects(testNode1)
+	backup := &longhorn.Backup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup-1", Namespace: testNamespace},
+		Status: longhorn.BackupStatus{
+			State:        longhorn.BackupStateCompleted,
+			VolumeName:   testVolumeName,
+			SnapshotName: "snap-2",
+		},
+	}
+	purgedBackup := &longhorn.Backup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup-0", Namespace: testNamespace},
+		Status: longhorn.BackupStatus{
+			State:             longhorn.BackupStateCompleted,
+			VolumeName:        testVolumeName,
+			SnapshotName:      "snap-0",
+			SnapshotCreatedAt: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		},
+	}
+	c, err := fake.NewCluster(testNamespace, stopCh, newReadyNode(testNode1), v, e, ei, backup, purgedBackup)
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+	engine := c.Engines.GetEngine(testVolumeName, testVolumeSize)
+
+	// snap-1 <- snap-2 (backup) <- snap-3 <- volume-head
+	for i, name := range []string{"snap-1", "snap-2", "snap-3"} {
+		engine.Write(int64(i+1) * 1024)
+		_, err = m.CreateSnapshot(context.Background(), name, nil, testVolumeName)
+		assert.NoError(err)
+	}
+	engine.Write(512)
+
+	comparison, err := m.CompareVolumeWithBackup(context.Background(), testVolumeName, "backup-1")
+	assert.NoError(err)
+	assert.True(comparison.SnapshotExists)
+	assert.True(comparison.SnapshotInVolumeChain)
+	assert.False(comparison.Estimated)
+	assert.True(comparison.Diverged)
+	assert.Equal([]string{"snap-3", "volume-head"}, comparison.ChangedSnapshots)
+	assert.Equal(int64(3*1024+512), comparison.DivergedSize)
+
+	// After a revert to snap-1, the volume misses the data of snap-2, and the
+	// snapshots no longer in the volume chain don't count
+	err = m.RevertSnapshot(context.Background(), "snap-1", testVolumeName)
+	assert.NoError(err)
+	engine.Write(256)
+	comparison, err = m.CompareVolumeWithBackup(context.Background(), testVolumeName, "backup-1")
+	assert.NoError(err)
+	assert.True(comparison.SnapshotExists)
+	assert.False(comparison.SnapshotInVolumeChain)
+	assert.Equal([]string{"snap-2", "volume-head"}, comparison.ChangedSnapshots)
+	assert.Equal(int64(2*1024+256), comparison.DivergedSize)
+
+	// Without the backup snapshot, the snapshots of the volume chain created
+	// after it are an estimation
+	comparison, err = m.CompareVolumeWithBackup(context.Background(), testVolumeName, "backup-0")
+	assert.NoError(err)
+	assert.False(comparison.SnapshotExists)
+	assert.True(comparison.Estimated)
+	assert.Equal([]string{"snap-1", "volume-head"}, comparison.ChangedSnapshots)
+	assert.Equal(int64(1024+256), comparison.DivergedSize)
+
+	// The backup of another volume is rejected
+	_, err = m.CompareVolumeWithBackup(context.Background(), "other-volume", "backup-1")
+	assert.Equal(types.ErrorReasonInvalidParameter, types.GetReasonError(err).Reason, "unexpected error %v", err)
+}
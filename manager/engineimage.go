@@ -2,7 +2,6 @@ package manager
 
 import (
 	"fmt"
-	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -16,11 +15,6 @@ import (
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 )
 
-var (
-	WaitForEngineImageCount    = 20
-	WaitForEngineImageInterval = 6 * time.Second
-)
-
 func (m *VolumeManager) ListEngineImagesByName() (map[string]*longhorn.EngineImage, error) {
 	return m.ds.ListEngineImages()
 }
@@ -53,6 +47,11 @@ func (m *VolumeManager) GetEngineImageByImage(image string) (*longhorn.EngineIma
 }
 
 func (m *VolumeManager) CreateEngineImage(image string) (*longhorn.EngineImage, error) {
+	image, err := m.ds.ResolveImage(image)
+	if err != nil {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter, map[string]string{types.ErrorParameterParameter: "image"},
+			"cannot create engine image: %v", err)
+	}
 	name := types.GetEngineImageChecksumName(image)
 	ei := &longhorn.EngineImage{
 		ObjectMeta: metav1.ObjectMeta{
@@ -63,7 +62,7 @@ func (m *VolumeManager) CreateEngineImage(image string) (*longhorn.EngineImage,
 			Image: image,
 		},
 	}
-	ei, err := m.ds.CreateEngineImage(ei)
+	ei, err = m.ds.CreateEngineImage(ei)
 	if err != nil {
 		return nil, err
 	}
@@ -39,6 +39,12 @@ func (m *VolumeManager) PVCreate(name, pvName, fsType, secretNamespace, secretNa
 		return nil, fmt.Errorf("failed to get longhorn static storage class name for PV %v creation: %v", pvName, err)
 	}
 
+	if fsType == "" {
+		fsType = v.Spec.FilesystemType
+	}
+	if fsType == "" {
+		fsType = v.Spec.Filesystem
+	}
 	if fsType == "" {
 		fsType = "ext4"
 	}
@@ -1,7 +1,11 @@
 package manager
 
 import (
+	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -146,3 +150,193 @@ func (m *VolumeManager) DeleteNode(name string) error {
 	logrus.Debugf("Deleted node %v", name)
 	return nil
 }
+
+const (
+	replicaDataImageSuffix = ".img"
+	replicaDataMetaSuffix  = ".img.meta"
+	replicaVolumeMetaFile  = "volume.meta"
+)
+
+type ReplicaDataScanResult struct {
+	DiskName          string   `json:"diskName"`
+	DiskPath          string   `json:"diskPath"`
+	DataDirectoryName string   `json:"dataDirectoryName"`
+	ReplicaName       string   `json:"replicaName"`
+	Issues            []string `json:"issues"`
+	Repaired          []string `json:"repaired"`
+}
+
+type replicaDiskMeta struct {
+	Name   string
+	Parent string
+}
+
+// replicaDataHost accesses the replica data files on the host, and is
+// replaced in the unit tests.
+type replicaDataHost interface {
+	ListFiles(path string) ([]util.ReplicaDataFile, error)
+	ReadFile(path string) (string, error)
+	Chmod(path, mode string) error
+}
+
+type hostReplicaData struct{}
+
+func (hostReplicaData) ListFiles(path string) ([]util.ReplicaDataFile, error) {
+	return util.ListReplicaDataFiles(path)
+}
+
+func (hostReplicaData) ReadFile(path string) (string, error) {
+	return util.ReadHostFile(path)
+}
+
+func (hostReplicaData) Chmod(path, mode string) error {
+	return util.ChmodHostFile(path, mode)
+}
+
+// ScanNodeReplicaData checks the replica data directories in the disks of the
+// current node and repairs the issues that can be fixed without touching the
+// data, e.g. bad file permissions.
+func (m *VolumeManager) ScanNodeReplicaData(name string, repair bool) (results []*ReplicaDataScanResult, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to scan replica data on node %v", name)
+	}()
+
+	if name != m.currentNodeID {
+		return nil, fmt.Errorf("replica data can only be scanned on the node itself, current node is %v", m.currentNodeID)
+	}
+
+	node, err := m.ds.GetNode(name)
+	if err != nil {
+		return nil, err
+	}
+
+	results = []*ReplicaDataScanResult{}
+	for diskName, disk := range node.Spec.Disks {
+		diskStatus, ok := node.Status.DiskStatus[diskName]
+		if !ok || diskStatus.DiskUUID == "" {
+			continue
+		}
+
+		dirNames, err := util.GetPossibleReplicaDirectoryNames(disk.Path)
+		if err != nil {
+			results = append(results, &ReplicaDataScanResult{
+				DiskName: diskName,
+				DiskPath: disk.Path,
+				Issues:   []string{err.Error()},
+			})
+			continue
+		}
+
+		replicas, err := m.ds.ListReplicasByDiskUUID(diskStatus.DiskUUID)
+		if err != nil {
+			return nil, err
+		}
+		replicaNames := map[string]string{}
+		for _, r := range replicas {
+			if r.Spec.DiskPath == disk.Path {
+				replicaNames[r.Spec.DataDirectoryName] = r.Name
+			}
+		}
+
+		for dirName := range dirNames {
+			result := &ReplicaDataScanResult{
+				DiskName:          diskName,
+				DiskPath:          disk.Path,
+				DataDirectoryName: dirName,
+				ReplicaName:       replicaNames[dirName],
+				Issues:            []string{},
+				Repaired:          []string{},
+			}
+			scanReplicaDataDirectory(hostReplicaData{}, filepath.Join(disk.Path, "replicas", dirName), repair, result)
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].DiskName != results[j].DiskName {
+			return results[i].DiskName < results[j].DiskName
+		}
+		return results[i].DataDirectoryName < results[j].DataDirectoryName
+	})
+
+	logrus.Infof("Scanned %v replica data directories on node %v with repair %v", len(results), name, repair)
+	return results, nil
+}
+
+func scanReplicaDataDirectory(host replicaDataHost, path string, repair bool, result *ReplicaDataScanResult) {
+	files, err := host.ListFiles(path)
+	if err != nil {
+		result.Issues = append(result.Issues, err.Error())
+		return
+	}
+
+	sizes := map[string]int64{}
+	for _, file := range files {
+		sizes[file.Name] = file.Size
+
+		if file.Mode&0600 == 0600 {
+			continue
+		}
+		result.Issues = append(result.Issues, fmt.Sprintf("file %v is not readable and writable by owner, mode %o", file.Name, file.Mode))
+		if repair {
+			if err := host.Chmod(filepath.Join(path, file.Name), "u+rw"); err != nil {
+				result.Issues = append(result.Issues, fmt.Sprintf("failed to fix permission of file %v: %v", file.Name, err))
+			} else {
+				result.Repaired = append(result.Repaired, fmt.Sprintf("fixed permission of file %v", file.Name))
+			}
+		}
+	}
+
+	if _, ok := sizes[replicaVolumeMetaFile]; !ok {
+		result.Issues = append(result.Issues, fmt.Sprintf("missing %v", replicaVolumeMetaFile))
+		return
+	}
+	content, err := host.ReadFile(filepath.Join(path, replicaVolumeMetaFile))
+	if err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("failed to read %v: %v", replicaVolumeMetaFile, err))
+		return
+	}
+	volumeMeta := &util.VolumeMeta{}
+	if err := json.Unmarshal([]byte(content), volumeMeta); err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("invalid %v: %v", replicaVolumeMetaFile, err))
+		return
+	}
+	if _, ok := sizes[volumeMeta.Head]; !ok {
+		result.Issues = append(result.Issues, fmt.Sprintf("missing volume head file %v", volumeMeta.Head))
+	}
+
+	for name, size := range sizes {
+		switch {
+		case strings.HasSuffix(name, replicaDataImageSuffix):
+			// Sparse files are never shorter than the volume size unless they were truncated
+			if size < volumeMeta.Size {
+				result.Issues = append(result.Issues, fmt.Sprintf("file %v is truncated, size %v is less than volume size %v", name, size, volumeMeta.Size))
+			}
+			if _, ok := sizes[name+".meta"]; !ok {
+				result.Issues = append(result.Issues, fmt.Sprintf("missing metadata file of %v", name))
+			}
+		case strings.HasSuffix(name, replicaDataMetaSuffix):
+			imageName := strings.TrimSuffix(name, ".meta")
+			if _, ok := sizes[imageName]; !ok {
+				result.Issues = append(result.Issues, fmt.Sprintf("missing image file of metadata file %v", name))
+			}
+			content, err := host.ReadFile(filepath.Join(path, name))
+			if err != nil {
+				result.Issues = append(result.Issues, fmt.Sprintf("failed to read metadata file %v: %v", name, err))
+				continue
+			}
+			diskMeta := &replicaDiskMeta{}
+			if err := json.Unmarshal([]byte(content), diskMeta); err != nil {
+				result.Issues = append(result.Issues, fmt.Sprintf("invalid metadata file %v: %v", name, err))
+				continue
+			}
+			if diskMeta.Parent != "" {
+				if _, ok := sizes[diskMeta.Parent]; !ok {
+					result.Issues = append(result.Issues, fmt.Sprintf("missing parent %v of %v", diskMeta.Parent, imageName))
+				}
+			}
+		}
+	}
+
+	sort.Strings(result.Issues)
+}
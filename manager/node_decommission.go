@@ -0,0 +1,272 @@
+package manager
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+const (
+	DecommissionActionDisableScheduling = "disableScheduling"
+	DecommissionActionTransferOwnership = "transferOwnership"
+	DecommissionActionEvictReplica      = "evictReplica"
+	DecommissionActionDetach            = "detach"
+
+	DecommissionStatusPending    = "pending"
+	DecommissionStatusInProgress = "inProgress"
+	DecommissionStatusBlocked    = "blocked"
+)
+
+// DecommissionStep is a remediation left before the node can be removed. The
+// target is the replica to evict, or the node expected to take over the
+// ownership.
+type DecommissionStep struct {
+	Volume  string `json:"volume"`
+	Action  string `json:"action"`
+	Target  string `json:"target"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// GetNodeDecommissionPlan returns the steps left to move everything off the
+// node. The plan is derived from the current state, so the finished steps
+// drop out of it and the node can be removed once it's empty.
+func (m *VolumeManager) GetNodeDecommissionPlan(name string) (steps []*DecommissionStep, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to get decommission plan of node %v", name)
+	}()
+
+	node, err := m.ds.GetNodeRO(name)
+	if err != nil {
+		return nil, err
+	}
+
+	steps = []*DecommissionStep{}
+	if node.Spec.AllowScheduling {
+		steps = append(steps, &DecommissionStep{
+			Action: DecommissionActionDisableScheduling,
+			Target: name,
+			Status: DecommissionStatusPending,
+		})
+	}
+
+	volumes, err := m.ds.ListVolumesRO()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+	replicas, err := m.ds.ListReplicasByNodeRO(name)
+	if err != nil {
+		return nil, err
+	}
+	volumeReplicas := map[string][]*longhorn.Replica{}
+	for _, r := range replicas {
+		volumeReplicas[r.Spec.VolumeName] = append(volumeReplicas[r.Spec.VolumeName], r)
+	}
+
+	for _, v := range volumes {
+		volumeSteps, err := m.getVolumeDecommissionSteps(v, node, volumeReplicas[v.Name])
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, volumeSteps...)
+	}
+	return steps, nil
+}
+
+// getVolumeDecommissionSteps returns the steps of the volume in the order to
+// execute them. A detached volume is automatically attached to its owner for
+// the replica eviction, so the ownership is transferred first. An attached
+// volume rebuilds the replicas elsewhere before it's detached from the node.
+func (m *VolumeManager) getVolumeDecommissionSteps(v *longhorn.Volume, node *longhorn.Node, replicas []*longhorn.Replica) ([]*DecommissionStep, error) {
+	nodeName := node.Name
+	steps := []*DecommissionStep{}
+	isAttachedToNode := v.Spec.NodeID == nodeName || v.Spec.MigrationNodeID == nodeName
+	isDetached := v.Spec.NodeID == "" && v.Status.State == longhorn.VolumeStateDetached
+
+	if isDetached && v.Status.OwnerID == nodeName {
+		step := &DecommissionStep{
+			Volume: v.Name,
+			Action: DecommissionActionTransferOwnership,
+			Status: DecommissionStatusPending,
+		}
+		target, err := m.getDecommissionOwnerCandidate(v, nodeName)
+		if err != nil {
+			return nil, err
+		}
+		if target == "" {
+			step.Status = DecommissionStatusBlocked
+			step.Message = "no other ready node being kept has the engine image of the volume"
+		} else if node.Spec.EvictionRequested {
+			step.Status = DecommissionStatusInProgress
+		}
+		step.Target = target
+		steps = append(steps, step)
+	}
+
+	sort.Slice(replicas, func(i, j int) bool {
+		return replicas[i].Name < replicas[j].Name
+	})
+	for _, r := range replicas {
+		step := &DecommissionStep{
+			Volume: v.Name,
+			Action: DecommissionActionEvictReplica,
+			Target: r.Name,
+			Status: DecommissionStatusPending,
+		}
+		if r.Spec.EvictionRequested {
+			step.Status = DecommissionStatusInProgress
+		}
+		steps = append(steps, step)
+	}
+
+	if isAttachedToNode {
+		step := &DecommissionStep{
+			Volume:  v.Name,
+			Action:  DecommissionActionDetach,
+			Target:  nodeName,
+			Status:  DecommissionStatusPending,
+			Message: "the workload using the volume needs to be moved to another node",
+		}
+		steps = append(steps, step)
+	} else if v.Status.CurrentNodeID == nodeName && v.Status.State != longhorn.VolumeStateDetached {
+		// Detached by the user, or attached automatically for the eviction
+		steps = append(steps, &DecommissionStep{
+			Volume: v.Name,
+			Action: DecommissionActionDetach,
+			Target: nodeName,
+			Status: DecommissionStatusInProgress,
+		})
+	}
+	return steps, nil
+}
+
+func (m *VolumeManager) getDecommissionOwnerCandidate(v *longhorn.Volume, nodeName string) (string, error) {
+	nodes, err := m.ds.ListReadyNodesWithEngineImage(v.Spec.EngineImage)
+	if err != nil {
+		return "", err
+	}
+	candidates := []string{}
+	for name, node := range nodes {
+		if name != nodeName && !node.Spec.EvictionRequested {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	sort.Strings(candidates)
+	return candidates[0], nil
+}
+
+// ExecuteNodeDecommissionPlan starts the next pending step of each volume.
+// The steps of a volume are done one by one, so it's called repeatedly until
+// the plan is empty. Detaching a volume interrupts the workload using it,
+// which is skipped unless allowed.
+func (m *VolumeManager) ExecuteNodeDecommissionPlan(name string, allowDetach bool) (steps []*DecommissionStep, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to execute decommission plan of node %v", name)
+	}()
+
+	steps, err = m.GetNodeDecommissionPlan(name)
+	if err != nil {
+		return nil, err
+	}
+
+	busyVolumes := map[string]bool{}
+	updatedNodes := map[string]*longhorn.Node{}
+	for _, step := range steps {
+		if step.Status != DecommissionStatusPending {
+			busyVolumes[step.Volume] = true
+			continue
+		}
+		if busyVolumes[step.Volume] {
+			continue
+		}
+		if step.Action == DecommissionActionDetach && !allowDetach {
+			step.Message = "detachment is not allowed"
+			continue
+		}
+		if step.Volume != "" {
+			busyVolumes[step.Volume] = true
+		}
+
+		if err := m.executeDecommissionStep(step, updatedNodes); err != nil {
+			logrus.WithError(err).Warnf("Failed to %v for volume %v during decommission of node %v", step.Action, step.Volume, name)
+			step.Status = DecommissionStatusBlocked
+			step.Message = err.Error()
+			continue
+		}
+		logrus.Infof("Started %v %v for volume %v during decommission of node %v", step.Action, step.Target, step.Volume, name)
+		step.Status = DecommissionStatusInProgress
+	}
+	return steps, nil
+}
+
+// executeDecommissionStep starts the step. The nodes updated by the previous
+// steps are passed along, since the datastore may not have caught up with
+// them yet.
+func (m *VolumeManager) executeDecommissionStep(step *DecommissionStep, updatedNodes map[string]*longhorn.Node) error {
+	switch step.Action {
+	case DecommissionActionDisableScheduling:
+		return m.updateDecommissionNode(updatedNodes, step.Target, func(node *longhorn.Node) bool {
+			node.Spec.AllowScheduling = false
+			return true
+		})
+	case DecommissionActionTransferOwnership:
+		// The volume controllers hand the detached volumes over from the
+		// node being evicted, see VolumeController.isResponsibleFor. The
+		// eviction also evicts the replicas on the node.
+		v, err := m.ds.GetVolumeRO(step.Volume)
+		if err != nil {
+			return err
+		}
+		return m.updateDecommissionNode(updatedNodes, v.Status.OwnerID, func(node *longhorn.Node) bool {
+			if node.Spec.EvictionRequested {
+				return false
+			}
+			node.Spec.EvictionRequested = true
+			return true
+		})
+	case DecommissionActionEvictReplica:
+		// Not EvictReplica, since the detached volume is attached
+		// automatically for the eviction
+		r, err := m.ds.GetReplica(step.Target)
+		if err != nil {
+			return err
+		}
+		r.Spec.EvictionRequested = true
+		_, err = m.ds.UpdateReplica(r)
+		return err
+	case DecommissionActionDetach:
+		_, err := m.Detach(context.Background(), step.Volume, step.Target, false)
+		return err
+	}
+	return types.NewReasonError(types.ErrorReasonInvalidParameter,
+		map[string]string{types.ErrorParameterName: step.Action},
+		"unknown decommission action %v", step.Action)
+}
+
+func (m *VolumeManager) updateDecommissionNode(updatedNodes map[string]*longhorn.Node, name string, update func(node *longhorn.Node) bool) (err error) {
+	node := updatedNodes[name]
+	if node == nil {
+		if node, err = m.ds.GetNode(name); err != nil {
+			return err
+		}
+	}
+	if !update(node) {
+		return nil
+	}
+	if node, err = m.ds.UpdateNode(node); err != nil {
+		return err
+	}
+	updatedNodes[name] = node
+	return nil
+}
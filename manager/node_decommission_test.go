@@ -0,0 +1,76 @@
+package manager_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func TestExecuteNodeDecommissionPlanTransferOwnership(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// The detached volume is owned by the node being decommissioned
+	v, _, ei := newRunningVolumeObjects("")
+	v.Status.State = longhorn.VolumeStateDetached
+	v.Status.OwnerID = testNode1
+	ei.Status.NodeDeploymentMap = map[string]bool{testNode1: true, testNode2: true}
+	r := &longhorn.Replica{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testVolumeName + "-r-0",
+			Namespace: testNamespace,
+			Labels: map[string]string{
+				types.LonghornLabelVolume: testVolumeName,
+				types.LonghornNodeKey:     testNode1,
+			},
+		},
+		Spec: longhorn.ReplicaSpec{
+			InstanceSpec: longhorn.InstanceSpec{
+				VolumeName: testVolumeName,
+				NodeID:     testNode1,
+			},
+		},
+	}
+	node := newReadyNode(testNode1)
+	node.Spec.AllowScheduling = true
+	c, err := fake.NewCluster(testNamespace, stopCh, v, r, ei, node, newReadyNode(testNode2))
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode2)
+
+	steps, err := m.ExecuteNodeDecommissionPlan(testNode1, false)
+	assert.NoError(err)
+	assert.Len(steps, 3)
+	assert.Equal(manager.DecommissionActionTransferOwnership, steps[1].Action)
+	assert.Equal(testNode2, steps[1].Target)
+	assert.Equal(manager.DecommissionStatusInProgress, steps[1].Status, steps[1].Message)
+	// The replica is evicted after the ownership is transferred
+	assert.Equal(manager.DecommissionActionEvictReplica, steps[2].Action)
+	assert.Equal(manager.DecommissionStatusPending, steps[2].Status)
+
+	// The ownership is moved by the volume controllers once the node is
+	// being evicted, rather than by the manager
+	assert.Eventually(func() bool {
+		node, err := c.DataStore.GetNodeRO(testNode1)
+		return err == nil && node.Spec.EvictionRequested
+	}, 5*time.Second, 10*time.Millisecond)
+	v, err = c.DataStore.GetVolumeRO(testVolumeName)
+	assert.NoError(err)
+	assert.Equal(testNode1, v.Status.OwnerID)
+
+	assert.Eventually(func() bool {
+		steps, err := m.GetNodeDecommissionPlan(testNode1)
+		return err == nil && len(steps) == 2 &&
+			steps[0].Action == manager.DecommissionActionTransferOwnership && steps[0].Status == manager.DecommissionStatusInProgress
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,152 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+type fakeReplicaDataHost struct {
+	files    map[string]util.ReplicaDataFile
+	contents map[string]string
+	chmodErr error
+}
+
+func (h *fakeReplicaDataHost) ListFiles(path string) ([]util.ReplicaDataFile, error) {
+	files := []util.ReplicaDataFile{}
+	for _, file := range h.files {
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func (h *fakeReplicaDataHost) ReadFile(path string) (string, error) {
+	content, ok := h.contents[filepath.Base(path)]
+	if !ok {
+		return "", fmt.Errorf("cannot find %v", path)
+	}
+	return content, nil
+}
+
+func (h *fakeReplicaDataHost) Chmod(path, mode string) error {
+	if h.chmodErr != nil {
+		return h.chmodErr
+	}
+	file := h.files[filepath.Base(path)]
+	file.Mode |= 0600
+	h.files[filepath.Base(path)] = file
+	return nil
+}
+
+func newFakeReplicaDataHost() *fakeReplicaDataHost {
+	h := &fakeReplicaDataHost{
+		files:    map[string]util.ReplicaDataFile{},
+		contents: map[string]string{},
+	}
+	add := func(name string, size int64, content string) {
+		h.files[name] = util.ReplicaDataFile{Name: name, Size: size, Mode: 0644}
+		if content != "" {
+			h.contents[name] = content
+		}
+	}
+	add("volume.meta", 100, `{"Size":1024,"Head":"volume-head-001.img"}`)
+	add("volume-head-001.img", 1024, "")
+	add("volume-head-001.img.meta", 100, `{"Name":"volume-head-001.img","Parent":"volume-snap-s1.img"}`)
+	add("volume-snap-s1.img", 1024, "")
+	add("volume-snap-s1.img.meta", 100, `{"Name":"volume-snap-s1.img","Parent":""}`)
+	return h
+}
+
+func TestScanReplicaDataDirectory(t *testing.T) {
+	tests := map[string]struct {
+		modify       func(h *fakeReplicaDataHost)
+		repair       bool
+		expectIssues []string
+		expectFixed  []string
+	}{
+		"healthy": {
+			modify:       func(h *fakeReplicaDataHost) {},
+			expectIssues: []string{},
+		},
+		"missing volume meta": {
+			modify:       func(h *fakeReplicaDataHost) { delete(h.files, "volume.meta") },
+			expectIssues: []string{"missing volume.meta"},
+		},
+		"invalid volume meta": {
+			modify:       func(h *fakeReplicaDataHost) { h.contents["volume.meta"] = "{" },
+			expectIssues: []string{"invalid volume.meta: unexpected end of JSON input"},
+		},
+		"missing head": {
+			modify: func(h *fakeReplicaDataHost) {
+				delete(h.files, "volume-head-001.img")
+			},
+			expectIssues: []string{
+				"missing image file of metadata file volume-head-001.img.meta",
+				"missing volume head file volume-head-001.img",
+			},
+		},
+		"truncated snapshot": {
+			modify: func(h *fakeReplicaDataHost) {
+				h.files["volume-snap-s1.img"] = util.ReplicaDataFile{Name: "volume-snap-s1.img", Size: 512, Mode: 0644}
+			},
+			expectIssues: []string{"file volume-snap-s1.img is truncated, size 512 is less than volume size 1024"},
+		},
+		"missing parent and metadata": {
+			modify: func(h *fakeReplicaDataHost) {
+				delete(h.files, "volume-snap-s1.img")
+				delete(h.files, "volume-snap-s1.img.meta")
+				h.files["volume-snap-s2.img"] = util.ReplicaDataFile{Name: "volume-snap-s2.img", Size: 1024, Mode: 0644}
+			},
+			expectIssues: []string{
+				"missing metadata file of volume-snap-s2.img",
+				"missing parent volume-snap-s1.img of volume-head-001.img",
+			},
+		},
+		"bad permission without repair": {
+			modify: func(h *fakeReplicaDataHost) {
+				h.files["volume-snap-s1.img"] = util.ReplicaDataFile{Name: "volume-snap-s1.img", Size: 1024, Mode: 0444}
+			},
+			expectIssues: []string{"file volume-snap-s1.img is not readable and writable by owner, mode 444"},
+		},
+		"bad permission repaired": {
+			modify: func(h *fakeReplicaDataHost) {
+				h.files["volume-snap-s1.img"] = util.ReplicaDataFile{Name: "volume-snap-s1.img", Size: 1024, Mode: 0444}
+			},
+			repair:       true,
+			expectIssues: []string{"file volume-snap-s1.img is not readable and writable by owner, mode 444"},
+			expectFixed:  []string{"fixed permission of file volume-snap-s1.img"},
+		},
+		"bad permission repair failed": {
+			modify: func(h *fakeReplicaDataHost) {
+				h.files["volume-snap-s1.img"] = util.ReplicaDataFile{Name: "volume-snap-s1.img", Size: 1024, Mode: 0444}
+				h.chmodErr = os.ErrPermission
+			},
+			repair: true,
+			expectIssues: []string{
+				"failed to fix permission of file volume-snap-s1.img: permission denied",
+				"file volume-snap-s1.img is not readable and writable by owner, mode 444",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := require.New(t)
+
+			h := newFakeReplicaDataHost()
+			tc.modify(h)
+			result := &ReplicaDataScanResult{Issues: []string{}, Repaired: []string{}}
+			scanReplicaDataDirectory(h, "/var/lib/longhorn/replicas/vol-abcdef12", tc.repair, result)
+			assert.Equal(tc.expectIssues, result.Issues)
+			if tc.expectFixed == nil {
+				tc.expectFixed = []string{}
+			}
+			assert.Equal(tc.expectFixed, result.Repaired)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/engineapi"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+// OperationClass groups the operations sharing the same timeout.
+type OperationClass string
+
+const (
+	OperationClassEngineQuery     = OperationClass("engine-query")
+	OperationClassEngineOperation = OperationClass("engine-operation")
+
+	volumeDeletionCheckInterval = time.Second
+)
+
+var operationClassTimeoutSettings = map[OperationClass]types.SettingName{
+	OperationClassEngineQuery:     types.SettingNameEngineQueryTimeout,
+	OperationClassEngineOperation: types.SettingNameEngineOperationTimeout,
+}
+
+func (m *VolumeManager) getOperationTimeout(class OperationClass) (time.Duration, error) {
+	seconds, err := m.ds.GetSettingAsInt(operationClassTimeoutSettings[class])
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// runEngineOperation calls fn with the running engine of the volume and its
+// client. It returns once fn returns, or fails if the context is done, the
+// timeout of the class expires, or the volume is being deleted. The client
+// stops its calls with the context as far as it can, but a call already sent
+// to the engine may still run in the background until the engine replies.
+// Until then, the other operations changing the same volume are rejected, so
+// a retry of the caller cannot repeat the change.
+func (m *VolumeManager) runEngineOperation(ctx context.Context, class OperationClass, volumeName string, fn func(e *longhorn.Engine, engineClientProxy engineapi.EngineClientProxy) error) error {
+	timeout, err := m.getOperationTimeout(class)
+	if err != nil {
+		return err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	e, err := m.GetRunningEngineByVolume(volumeName)
+	if err != nil {
+		return err
+	}
+
+	log := util.WithRequestIDField(ctx, logrus.StandardLogger()).WithField("volume", volumeName)
+	log.Debugf("Running %v on engine %v", class, e.Name)
+	engineClientProxy, err := m.engineClientFactory.NewEngineClient(ctx, e, log)
+	if err != nil {
+		return err
+	}
+
+	isMutation := class != OperationClassEngineQuery
+	if isMutation {
+		if err := m.startEngineMutation(volumeName); err != nil {
+			engineClientProxy.Close()
+			return err
+		}
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer engineClientProxy.Close()
+		err := fn(e, engineClientProxy)
+		if isMutation {
+			// The operation may change the replicas or the frontend
+			m.engineStatusCache.Invalidate(volumeName)
+			m.finishEngineMutation(volumeName)
+		}
+		errCh <- err
+	}()
+
+	ticker := time.NewTicker(volumeDeletionCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-errCh:
+			return err
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return types.NewReasonError(types.ErrorReasonTimeout,
+					map[string]string{types.ErrorParameterName: volumeName},
+					"timed out after %v waiting for %v of volume %v", timeout, class, volumeName)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+			v, err := m.ds.GetVolumeRO(volumeName)
+			if err != nil && !datastore.ErrorIsNotFound(err) {
+				continue
+			}
+			if v == nil || v.DeletionTimestamp != nil {
+				return types.NewReasonError(types.ErrorReasonInvalidState,
+					map[string]string{types.ErrorParameterName: volumeName, types.ErrorParameterState: string(longhorn.VolumeStateDeleting)},
+					"volume %v is deleted during %v", volumeName, class)
+			}
+		}
+	}
+}
+
+// startEngineMutation fails if an operation changing the volume is still
+// running, e.g. in the background after its caller timed out.
+func (m *VolumeManager) startEngineMutation(volumeName string) error {
+	m.engineOperationLock.Lock()
+	defer m.engineOperationLock.Unlock()
+
+	if m.engineOperationsRunning[volumeName] {
+		return types.NewReasonError(types.ErrorReasonConflict,
+			map[string]string{types.ErrorParameterName: volumeName},
+			"a previous engine operation of volume %v is still running", volumeName)
+	}
+	m.engineOperationsRunning[volumeName] = true
+	return nil
+}
+
+func (m *VolumeManager) finishEngineMutation(volumeName string) {
+	m.engineOperationLock.Lock()
+	defer m.engineOperationLock.Unlock()
+	delete(m.engineOperationsRunning, volumeName)
+}
@@ -1,9 +1,11 @@
 package manager
 
 import (
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
 )
 
 func (m *VolumeManager) GetOrphan(name string) (*longhorn.Orphan, error) {
@@ -21,3 +23,35 @@ func (m *VolumeManager) DeleteOrphan(name string) error {
 	logrus.Infof("Deleted orphan %v", name)
 	return nil
 }
+
+func (m *VolumeManager) RetainOrphan(name string, retained bool) (orphan *longhorn.Orphan, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to update retention of orphan %v", name)
+	}()
+
+	orphan, err = m.ds.GetOrphan(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if types.IsOrphanRetained(orphan) == retained {
+		logrus.Debugf("Orphan %v already has retained %v", name, retained)
+		return orphan, nil
+	}
+
+	if orphan.Labels == nil {
+		orphan.Labels = map[string]string{}
+	}
+	if retained {
+		orphan.Labels[types.GetLonghornLabelKey(types.LonghornLabelOrphanRetained)] = "true"
+	} else {
+		delete(orphan.Labels, types.GetLonghornLabelKey(types.LonghornLabelOrphanRetained))
+	}
+
+	orphan, err = m.ds.UpdateOrphan(orphan)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("Updated orphan %v retained to %v", name, retained)
+	return orphan, nil
+}
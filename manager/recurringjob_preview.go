@@ -0,0 +1,197 @@
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+// DefaultRecurringJobPreviewCount is the number of the runs previewed if not
+// specified.
+const DefaultRecurringJobPreviewCount = 5
+
+// RecurringJobPreview is the next runs of a recurring job applied to a
+// volume. The snapshots and the backups to be created by the previewed runs
+// are referred to by the time of the run, since they don't have a name yet.
+type RecurringJobPreview struct {
+	Name   string                    `json:"name"`
+	Task   longhorn.RecurringJobType `json:"task"`
+	Cron   string                    `json:"cron"`
+	Retain int                       `json:"retain"`
+	Runs   []*RecurringJobRunPreview `json:"runs"`
+}
+
+// RecurringJobRunPreview is what a run is projected to prune to keep the
+// retain count of the job.
+type RecurringJobRunPreview struct {
+	Time            string   `json:"time"`
+	PrunedSnapshots []string `json:"prunedSnapshots"`
+	PrunedBackups   []string `json:"prunedBackups"`
+}
+
+type retainedItem struct {
+	name    string
+	created time.Time
+}
+
+// PreviewVolumeRecurringJobs returns the next count runs of each recurring
+// job applied to the volume, directly or by a group, sorted by the job name.
+// The projection assumes every run succeeds, and nothing else creates or
+// deletes the snapshots and the backups in between.
+func (m *VolumeManager) PreviewVolumeRecurringJobs(volumeName string, count int) (previews []*RecurringJobPreview, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to preview recurring jobs of volume %v", volumeName)
+	}()
+
+	v, err := m.ds.GetVolumeRO(volumeName)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := m.ListRecurringJobsSorted()
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := m.ds.ListVolumeSnapshotsRO(volumeName)
+	if err != nil {
+		return nil, err
+	}
+	backups, err := m.ds.ListBackupsWithBackupVolumeName(volumeName)
+	if err != nil {
+		return nil, err
+	}
+
+	volumeJobs := datastore.MarshalLabelToVolumeRecurringJob(v.Labels)
+	now := m.clock.Now().UTC()
+	previews = []*RecurringJobPreview{}
+	for _, job := range jobs {
+		if !isRecurringJobAppliedToVolume(job, volumeJobs) {
+			continue
+		}
+		runTimes, err := util.GetCronNextRunTimes(job.Spec.Cron, now, count)
+		if err != nil {
+			return nil, err
+		}
+		previews = append(previews, &RecurringJobPreview{
+			Name:   job.Name,
+			Task:   job.Spec.Task,
+			Cron:   job.Spec.Cron,
+			Retain: job.Spec.Retain,
+			Runs:   previewRecurringJobRuns(job, now, runTimes, snapshots, backups),
+		})
+	}
+	return previews, nil
+}
+
+func isRecurringJobAppliedToVolume(job *longhorn.RecurringJob, volumeJobs map[string]*longhorn.VolumeRecurringJob) bool {
+	for _, volumeJob := range volumeJobs {
+		if !volumeJob.IsGroup && volumeJob.Name == job.Name {
+			return true
+		}
+		if volumeJob.IsGroup && util.Contains(job.Spec.Groups, volumeJob.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// previewRecurringJobRuns follows the cleanup of the recurring job runs: a
+// snapshot job keeps the last retain snapshots it created, a backup job keeps
+// the last retain backups it created and only the snapshot of the last
+// backup, and a snapshot-delete job keeps the last retain snapshots not
+// created by Longhorn.
+func previewRecurringJobRuns(job *longhorn.RecurringJob, now time.Time, runTimes []time.Time, snapshots map[string]*longhorn.Snapshot, backups map[string]*longhorn.Backup) []*RecurringJobRunPreview {
+	var retainedSnapshots, retainedBackups []retainedItem
+	switch job.Spec.Task {
+	case longhorn.RecurringJobTypeSnapshot, longhorn.RecurringJobTypeSnapshotForceCreate,
+		longhorn.RecurringJobTypeBackup, longhorn.RecurringJobTypeBackupForceCreate:
+		for _, snapshot := range snapshots {
+			if snapshot.Status.Labels[types.RecurringJobLabel] != job.Name ||
+				!strings.HasPrefix(snapshot.Name, types.GetRecurringJobSnapshotNamePrefix(job.Name)) {
+				continue
+			}
+			retainedSnapshots = appendRetainedItem(retainedSnapshots, snapshot.Name, snapshot.Status.CreationTime, now)
+		}
+	case longhorn.RecurringJobTypeSnapshotDelete:
+		for _, snapshot := range snapshots {
+			if types.IsLonghornCreatedSnapshot(snapshot.Name, snapshot.Status.Labels) {
+				continue
+			}
+			retainedSnapshots = appendRetainedItem(retainedSnapshots, snapshot.Name, snapshot.Status.CreationTime, now)
+		}
+	}
+	if job.Spec.Task == longhorn.RecurringJobTypeBackup || job.Spec.Task == longhorn.RecurringJobTypeBackupForceCreate {
+		for _, backup := range backups {
+			if backup.Status.Labels[types.RecurringJobLabel] != job.Name {
+				continue
+			}
+			retainedBackups = appendRetainedItem(retainedBackups, backup.Name, backup.Status.BackupCreatedAt, now)
+		}
+	}
+	sortRetainedItems(retainedSnapshots)
+	sortRetainedItems(retainedBackups)
+
+	runs := []*RecurringJobRunPreview{}
+	for _, runTime := range runTimes {
+		run := &RecurringJobRunPreview{
+			Time:            util.FormatTimeZ(runTime),
+			PrunedSnapshots: []string{},
+			PrunedBackups:   []string{},
+		}
+		created := retainedItem{
+			name:    fmt.Sprintf("created at %v", run.Time),
+			created: runTime,
+		}
+
+		switch job.Spec.Task {
+		case longhorn.RecurringJobTypeSnapshot, longhorn.RecurringJobTypeSnapshotForceCreate:
+			retainedSnapshots = append(retainedSnapshots, created)
+			retainedSnapshots, run.PrunedSnapshots = pruneRetainedItems(retainedSnapshots, job.Spec.Retain)
+		case longhorn.RecurringJobTypeSnapshotDelete:
+			retainedSnapshots, run.PrunedSnapshots = pruneRetainedItems(retainedSnapshots, job.Spec.Retain)
+		case longhorn.RecurringJobTypeBackup, longhorn.RecurringJobTypeBackupForceCreate:
+			retainedBackups = append(retainedBackups, created)
+			retainedBackups, run.PrunedBackups = pruneRetainedItems(retainedBackups, job.Spec.Retain)
+			_, run.PrunedSnapshots = pruneRetainedItems(retainedSnapshots, 0)
+			retainedSnapshots = []retainedItem{created}
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+func appendRetainedItem(items []retainedItem, name, created string, now time.Time) []retainedItem {
+	t, err := util.ParseTimeZ(created)
+	if err != nil {
+		// Not created yet, so it's the latest one
+		t = now
+	}
+	return append(items, retainedItem{name: name, created: t})
+}
+
+func sortRetainedItems(items []retainedItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].created.Equal(items[j].created) {
+			return items[i].name < items[j].name
+		}
+		return items[i].created.Before(items[j].created)
+	})
+}
+
+// pruneRetainedItems keeps the latest retain items of the sorted ones, and
+// returns the names of the others.
+func pruneRetainedItems(items []retainedItem, retain int) ([]retainedItem, []string) {
+	pruned := []string{}
+	for len(items) > retain && len(items) > 0 {
+		pruned = append(pruned, items[0].name)
+		items = items[1:]
+	}
+	return items, pruned
+}
@@ -0,0 +1,67 @@
+package manager_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func TestPreviewVolumeRecurringJobs(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	job := &longhorn.RecurringJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "snap", Namespace: testNamespace},
+		Spec: longhorn.RecurringJobSpec{
+			Name:   "snap",
+			Task:   longhorn.RecurringJobTypeSnapshot,
+			Cron:   "0 * * * *",
+			Retain: 2,
+		},
+	}
+	v, _, _ := newRunningVolumeObjects(testNode1)
+	v.Labels = map[string]string{types.GetRecurringJobLabelKey(types.LonghornLabelRecurringJob, job.Name): types.LonghornLabelValueEnabled}
+	prefix := types.GetRecurringJobSnapshotNamePrefix(job.Name)
+	newSnapshot := func(name, creationTime string) *longhorn.Snapshot {
+		return &longhorn.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      prefix + name,
+				Namespace: testNamespace,
+				Labels:    types.GetVolumeLabels(testVolumeName),
+			},
+			Status: longhorn.SnapshotStatus{
+				CreationTime: creationTime,
+				Labels:       map[string]string{types.RecurringJobLabel: job.Name},
+			},
+		}
+	}
+	c, err := fake.NewCluster(testNamespace, stopCh, v, job,
+		newSnapshot("1", "2023-01-02T12:00:00Z"),
+		newSnapshot("2", "2023-01-02T13:00:00Z"),
+		// Still being created, so it's the latest one
+		newSnapshot("3", ""))
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+	m.SetClock(&fakeClock{now: time.Date(2023, 1, 2, 15, 30, 0, 0, time.UTC)})
+
+	previews, err := m.PreviewVolumeRecurringJobs(testVolumeName, 2)
+	assert.NoError(err)
+	assert.Len(previews, 1)
+	runs := previews[0].Runs
+	assert.Len(runs, 2)
+	assert.Equal("2023-01-02T16:00:00Z", runs[0].Time)
+	assert.Equal([]string{prefix + "1", prefix + "2"}, runs[0].PrunedSnapshots)
+	assert.Equal("2023-01-02T17:00:00Z", runs[1].Time)
+	assert.Equal([]string{prefix + "3"}, runs[1].PrunedSnapshots)
+	assert.Empty(runs[1].PrunedBackups)
+}
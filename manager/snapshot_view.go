@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/engineapi"
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+// CreateSnapshotView creates a single replica volume with a read-only
+// frontend holding the content of the snapshot, e.g. for analytics or backup
+// verification, without touching the source volume. The view is a child of
+// the source volume and is garbage collected with it. It can be attached and
+// detached on its own.
+//
+// The engine cannot expose a snapshot as a device, so the view is not
+// zero-copy. The data is cloned from the snapshot when the view is attached
+// the first time, so the view is checked like any other cloned volume. The
+// view of a snapshot is named after it, so creating it again returns the
+// existing view.
+func (m *VolumeManager) CreateSnapshotView(ctx context.Context, volumeName, snapshotName string) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to create view of snapshot %v of volume %v", snapshotName, volumeName)
+	}()
+
+	if snapshotName == "" {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "name"}, "snapshot name is required")
+	}
+	volume, err := m.ds.GetVolumeRO(volumeName)
+	if err != nil {
+		return nil, err
+	}
+
+	name := util.AutoCorrectName(fmt.Sprintf("%s-view-%s", volumeName, snapshotName), datastore.NameMaximumLength)
+	if err := validateVolumeName(name); err != nil {
+		return nil, err
+	}
+	labels := map[string]string{
+		types.GetLonghornLabelKey(types.LonghornLabelSnapshotViewOf):       volumeName,
+		types.GetLonghornLabelKey(types.LonghornLabelSnapshotViewSnapshot): snapshotName,
+	}
+	if existing, err := m.ds.GetVolumeRO(name); err == nil {
+		if isSnapshotView(existing, volumeName, snapshotName) {
+			logrus.Infof("View %v of snapshot %v of volume %v already exists", name, snapshotName, volumeName)
+			return existing, nil
+		}
+		return nil, types.NewReasonError(types.ErrorReasonAlreadyExists,
+			map[string]string{types.ErrorParameterKind: "volume", types.ErrorParameterName: name},
+			"volume %v already exists", name)
+	} else if !datastore.ErrorIsNotFound(err) {
+		return nil, err
+	}
+
+	// The view gets the default engine image like any new volume, which
+	// must be able to start with a read-only frontend
+	engineImage, err := m.ds.GetSettingValueExisted(types.SettingNameDefaultEngineImage)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkEngineImageFeature(engineImage, engineapi.EngineFeatureFrontendReadOnly); err != nil {
+		return nil, err
+	}
+
+	tenant := GetVolumeTenant(volume)
+	spec, err := m.validateVolumeCreation(ctx, name, &longhorn.VolumeSpec{
+		Size:             volume.Spec.Size,
+		Frontend:         volume.Spec.Frontend,
+		Encrypted:        volume.Spec.Encrypted,
+		BackingImage:     volume.Spec.BackingImage,
+		DataSource:       types.NewVolumeDataSourceTypeSnapshot(volumeName, snapshotName),
+		NumberOfReplicas: 1,
+		DataLocality:     longhorn.DataLocalityBestEffort,
+		DiskSelector:     volume.Spec.DiskSelector,
+		NodeSelector:     volume.Spec.NodeSelector,
+		ReadOnly:         true,
+	}, nil, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if tenant != "" {
+		labels[types.GetLonghornLabelKey(types.LonghornLabelTenant)] = tenant
+	}
+
+	v = &longhorn.Volume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Labels:          labels,
+			OwnerReferences: datastore.GetOwnerReferencesForVolume(volume),
+		},
+		Spec: *spec,
+	}
+	setLastRequestID(ctx, v)
+	if v, err = m.ds.CreateVolume(v); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+		// Created by a concurrent request
+		if existing, getErr := m.ds.GetVolumeRO(name); getErr == nil && isSnapshotView(existing, volumeName, snapshotName) {
+			return existing, nil
+		}
+		return nil, err
+	}
+	logrus.Infof("Created view %v of snapshot %v of volume %v", v.Name, snapshotName, volumeName)
+	return v, nil
+}
+
+func isSnapshotView(v *longhorn.Volume, volumeName, snapshotName string) bool {
+	return v.Labels[types.GetLonghornLabelKey(types.LonghornLabelSnapshotViewOf)] == volumeName &&
+		v.Labels[types.GetLonghornLabelKey(types.LonghornLabelSnapshotViewSnapshot)] == snapshotName
+}
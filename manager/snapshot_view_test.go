@@ -0,0 +1,87 @@
+package manager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/engineapi"
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func newSnapshotViewCluster(t *testing.T, stopCh chan struct{}, cliAPIVersion int) *fake.Cluster {
+	v, e, ei := newRunningVolumeObjects(testNode1)
+	v.Labels = map[string]string{types.GetLonghornLabelKey(types.LonghornLabelTenant): "team-a"}
+	ei.Status.CLIAPIVersion = cliAPIVersion
+	c, err := fake.NewCluster(testNamespace, stopCh, newReadyNode(testNode1), v, e, ei,
+		&longhorn.Setting{
+			ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameDefaultEngineImage), Namespace: testNamespace},
+			Value:      testEngineImage,
+		})
+	require.NoError(t, err)
+	return c
+}
+
+func TestCreateSnapshotView(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	c := newSnapshotViewCluster(t, stopCh, engineapi.CLIVersionEight)
+	m := c.NewVolumeManager(testNode1)
+	_, err := m.CreateSnapshot(context.Background(), "snap-1", nil, testVolumeName)
+	assert.NoError(err)
+
+	view, err := m.CreateSnapshotView(context.Background(), testVolumeName, "snap-1")
+	assert.NoError(err)
+	assert.Equal(testVolumeName+"-view-snap-1", view.Name)
+	assert.True(view.Spec.ReadOnly)
+	assert.Equal(1, view.Spec.NumberOfReplicas)
+	assert.Equal(int64(testVolumeSize), view.Spec.Size)
+	assert.Equal(types.NewVolumeDataSourceTypeSnapshot(testVolumeName, "snap-1"), view.Spec.DataSource)
+	assert.Equal("team-a", view.Labels[types.GetLonghornLabelKey(types.LonghornLabelTenant)])
+	assert.Len(view.OwnerReferences, 1)
+	assert.Equal(testVolumeName, view.OwnerReferences[0].Name)
+
+	// Creating the view again returns the existing one
+	assert.Eventually(func() bool {
+		_, err := c.DataStore.GetVolumeRO(view.Name)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	retried, err := m.CreateSnapshotView(context.Background(), testVolumeName, "snap-1")
+	assert.NoError(err)
+	assert.Equal(view.UID, retried.UID)
+
+	// The snapshot is checked like the one of any cloned volume
+	_, err = m.CreateSnapshotView(context.Background(), testVolumeName, "snap-missing")
+	assert.Error(err)
+	_, err = m.CreateSnapshotView(context.Background(), testVolumeName, "")
+	assert.Equal(types.ErrorReasonInvalidParameter, types.GetReasonError(err).Reason, "unexpected error %v", err)
+}
+
+func TestCreateSnapshotViewUnsupported(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	c := newSnapshotViewCluster(t, stopCh, engineapi.CLIVersionSeven)
+	m := c.NewVolumeManager(testNode1)
+	_, err := m.CreateSnapshot(context.Background(), "snap-1", nil, testVolumeName)
+	assert.NoError(err)
+
+	// The view could never be attached with a read-only frontend
+	_, err = m.CreateSnapshotView(context.Background(), testVolumeName, "snap-1")
+	assert.Equal(types.ErrorReasonInvalidState, types.GetReasonError(err).Reason, "unexpected error %v", err)
+	volumes, err := c.DataStore.ListVolumesRO()
+	assert.NoError(err)
+	assert.Len(volumes, 1)
+}
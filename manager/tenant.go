@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"github.com/pkg/errors"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+type TenantUsage struct {
+	Name             string `json:"name"`
+	VolumeCount      int64  `json:"volumeCount"`
+	Size             int64  `json:"size"`
+	VolumeCountLimit int64  `json:"volumeCountLimit"`
+	SizeLimit        int64  `json:"sizeLimit"`
+}
+
+// GetVolumeTenant returns the tenant of the volume, or empty if the volume
+// doesn't belong to a tenant. A tenant groups the volumes by the tenant label.
+// Unlike a Kubernetes namespace, it doesn't scope the volume names, since the
+// name of a volume is the name of its custom resource in the Longhorn
+// namespace. The quotas of the tenants are kept in the tenant-quotas setting
+// rather than in a resource of their own.
+func GetVolumeTenant(v *longhorn.Volume) string {
+	return v.Labels[types.GetLonghornLabelKey(types.LonghornLabelTenant)]
+}
+
+func getTenantSelector(tenant string) labels.Selector {
+	return labels.SelectorFromSet(labels.Set{types.GetLonghornLabelKey(types.LonghornLabelTenant): tenant})
+}
+
+// GetTenantUsage returns the volumes of the tenant against its quota.
+func (m *VolumeManager) GetTenantUsage(tenant string) (usage *TenantUsage, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to get usage of tenant %v", tenant)
+	}()
+
+	if err := types.ValidateTenant(tenant); err != nil {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "tenant", types.ErrorParameterValue: tenant}, "%v", err)
+	}
+	quota, err := m.ds.GetTenantQuota(tenant)
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := m.ds.ListTenantVolumesRO(tenant)
+	if err != nil {
+		return nil, err
+	}
+	usage = &TenantUsage{
+		Name:             tenant,
+		VolumeCountLimit: quota.VolumeCount,
+		SizeLimit:        quota.Size,
+	}
+	for _, v := range volumes {
+		usage.VolumeCount++
+		usage.Size += v.Spec.Size
+	}
+	return usage, nil
+}
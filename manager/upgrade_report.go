@@ -0,0 +1,193 @@
+package manager
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+const (
+	VolumeUpgradePathUpToDate = "up-to-date"
+	VolumeUpgradePathLive     = "live-upgrade"
+	VolumeUpgradePathOffline  = "offline-upgrade"
+	VolumeUpgradePathDetach   = "detach-required"
+	VolumeUpgradePathBlocked  = "blocked"
+)
+
+type VolumeUpgradeReport struct {
+	Name         string   `json:"name"`
+	State        string   `json:"state"`
+	Robustness   string   `json:"robustness"`
+	CurrentImage string   `json:"currentImage"`
+	Path         string   `json:"path"`
+	Reasons      []string `json:"reasons"`
+	// The replica processes restarted on the new engine image by a live
+	// upgrade. The data is reused, so no replica is rebuilt.
+	ReplicaRestarts int  `json:"replicaRestarts"`
+	EngineRestart   bool `json:"engineRestart"`
+}
+
+type SettingUpgradeReport struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Default string `json:"default"`
+}
+
+type UpgradeReport struct {
+	EngineImage string                 `json:"engineImage"`
+	Volumes     []*VolumeUpgradeReport `json:"volumes"`
+	// The settings at the default value of this manager, which follow the
+	// default of the new version after a manager upgrade. The customized
+	// settings are kept.
+	DefaultSettings []*SettingUpgradeReport `json:"defaultSettings"`
+	// The volumes are upgraded to the default engine image automatically, at
+	// most this number on each node at a time, if it's greater than 0.
+	AutomaticUpgradePerNodeLimit int64          `json:"automaticUpgradePerNodeLimit"`
+	Summary                      map[string]int `json:"summary"`
+}
+
+// GetUpgradeReport reports how each volume would be upgraded to the engine
+// image, the default engine image if it's empty, without changing anything.
+// The checks are the same as the ones of EngineUpgrade.
+func (m *VolumeManager) GetUpgradeReport(image string) (report *UpgradeReport, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to get upgrade report for engine image %v", image)
+	}()
+
+	defaultEngineImage, err := m.ds.GetSettingValueExisted(types.SettingNameDefaultEngineImage)
+	if err != nil {
+		return nil, err
+	}
+	if image == "" {
+		image = defaultEngineImage
+	}
+	if image, err = m.ds.ResolveImage(image); err != nil {
+		return nil, err
+	}
+	automaticUpgradePerNodeLimit, err := m.ds.GetSettingAsInt(types.SettingNameConcurrentAutomaticEngineUpgradePerNodeLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	report = &UpgradeReport{
+		EngineImage:                  image,
+		Volumes:                      []*VolumeUpgradeReport{},
+		DefaultSettings:              []*SettingUpgradeReport{},
+		AutomaticUpgradePerNodeLimit: automaticUpgradePerNodeLimit,
+		Summary:                      map[string]int{},
+	}
+	// Only the upgrade to the default engine image is allowed while the
+	// automatic upgrade is enabled
+	var upgradeNotAllowed string
+	if automaticUpgradePerNodeLimit > 0 && image != defaultEngineImage {
+		upgradeNotAllowed = fmt.Sprintf("only the upgrade to the default engine image %v is allowed because the setting %v is greater than 0",
+			defaultEngineImage, types.SettingNameConcurrentAutomaticEngineUpgradePerNodeLimit)
+	}
+
+	volumes, err := m.ListSorted()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range volumes {
+		vr, err := m.getVolumeUpgradeReport(v, image, upgradeNotAllowed)
+		if err != nil {
+			return nil, err
+		}
+		report.Volumes = append(report.Volumes, vr)
+		report.Summary[vr.Path]++
+	}
+
+	settings, err := m.ListSettings()
+	if err != nil {
+		return nil, err
+	}
+	for name, setting := range settings {
+		definition, ok := types.GetSettingDefinition(name)
+		if !ok || definition.ReadOnly || setting.Value != definition.Default {
+			continue
+		}
+		report.DefaultSettings = append(report.DefaultSettings, &SettingUpgradeReport{
+			Name:    string(name),
+			Value:   setting.Value,
+			Default: definition.Default,
+		})
+	}
+	sort.Slice(report.DefaultSettings, func(i, j int) bool {
+		return report.DefaultSettings[i].Name < report.DefaultSettings[j].Name
+	})
+
+	return report, nil
+}
+
+func (m *VolumeManager) getVolumeUpgradeReport(v *longhorn.Volume, image, upgradeNotAllowed string) (*VolumeUpgradeReport, error) {
+	vr := &VolumeUpgradeReport{
+		Name:         v.Name,
+		State:        string(v.Status.State),
+		Robustness:   string(v.Status.Robustness),
+		CurrentImage: v.Status.CurrentImage,
+		Reasons:      []string{},
+	}
+
+	if v.Spec.EngineImage == image && v.Status.CurrentImage == image {
+		vr.Path = VolumeUpgradePathUpToDate
+		return vr, nil
+	}
+
+	if upgradeNotAllowed != "" {
+		vr.Reasons = append(vr.Reasons, upgradeNotAllowed)
+	}
+	if v.Spec.EngineImage != v.Status.CurrentImage && image != v.Status.CurrentImage {
+		vr.Reasons = append(vr.Reasons, fmt.Sprintf("upgrading from %v to %v is in process", v.Status.CurrentImage, v.Spec.EngineImage))
+	}
+	if v.Spec.MigrationNodeID != "" {
+		vr.Reasons = append(vr.Reasons, "volume is migrating")
+	}
+	if isReady, err := m.ds.CheckEngineImageReadyOnAllVolumeReplicas(image, v.Name, v.Status.CurrentNodeID); !isReady {
+		if err != nil {
+			vr.Reasons = append(vr.Reasons, err.Error())
+		} else {
+			vr.Reasons = append(vr.Reasons, fmt.Sprintf("engine image %v is not deployed on the replicas' nodes or the node that the volume is attached to", image))
+		}
+	}
+	if isReady, err := m.ds.CheckEngineImageReadyOnAllVolumeReplicas(v.Status.CurrentImage, v.Name, v.Status.CurrentNodeID); !isReady {
+		if err != nil {
+			vr.Reasons = append(vr.Reasons, err.Error())
+		} else {
+			vr.Reasons = append(vr.Reasons, fmt.Sprintf("current engine image %v is not deployed on the replicas' nodes or the node that the volume is attached to", v.Status.CurrentImage))
+		}
+	}
+	if len(vr.Reasons) != 0 {
+		vr.Path = VolumeUpgradePathBlocked
+		return vr, nil
+	}
+
+	if v.Status.State != longhorn.VolumeStateAttached {
+		vr.Path = VolumeUpgradePathOffline
+		return vr, nil
+	}
+
+	// Rebuilding is not supported during the live upgrade, so an unhealthy
+	// volume has to be detached first
+	if v.Status.Robustness != longhorn.VolumeRobustnessHealthy {
+		vr.Path = VolumeUpgradePathDetach
+		vr.Reasons = append(vr.Reasons, fmt.Sprintf("live upgrade is not possible for a %v volume", v.Status.Robustness))
+		return vr, nil
+	}
+
+	replicas, err := m.ds.ListVolumeReplicas(v.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range replicas {
+		if r.Spec.FailedAt == "" && r.Spec.HealthyAt != "" {
+			vr.ReplicaRestarts++
+		}
+	}
+	vr.Path = VolumeUpgradePathLive
+	vr.EngineRestart = true
+	return vr, nil
+}
@@ -0,0 +1,156 @@
+package manager_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+const testNewEngineImage = "longhornio/longhorn-engine:new"
+
+func newUpgradeReportObjects(defaultEngineImage, automaticUpgradePerNodeLimit string, deployedImages ...string) []runtime.Object {
+	v, e, _ := newRunningVolumeObjects(testNode1)
+	v.Status.CurrentNodeID = testNode1
+	v.Status.Robustness = longhorn.VolumeRobustnessHealthy
+	r := &longhorn.Replica{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testVolumeName + "-r-0",
+			Namespace: testNamespace,
+			Labels:    types.GetVolumeLabels(testVolumeName),
+		},
+		Spec: longhorn.ReplicaSpec{
+			InstanceSpec: longhorn.InstanceSpec{
+				VolumeName: testVolumeName,
+				NodeID:     testNode1,
+			},
+			HealthyAt: "2026-01-01T00:00:00Z",
+		},
+	}
+	objects := []runtime.Object{
+		v, e, r, newReadyNode(testNode1),
+		&longhorn.Setting{
+			ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameDefaultEngineImage), Namespace: testNamespace},
+			Value:      defaultEngineImage,
+		},
+		&longhorn.Setting{
+			ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameConcurrentAutomaticEngineUpgradePerNodeLimit), Namespace: testNamespace},
+			Value:      automaticUpgradePerNodeLimit,
+		},
+	}
+	for _, image := range deployedImages {
+		objects = append(objects, &longhorn.EngineImage{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      types.GetEngineImageChecksumName(image),
+				Namespace: testNamespace,
+			},
+			Spec: longhorn.EngineImageSpec{
+				Image: image,
+			},
+			Status: longhorn.EngineImageStatus{
+				State:             longhorn.EngineImageStateDeployed,
+				NodeDeploymentMap: map[string]bool{testNode1: true},
+			},
+		})
+	}
+	return objects
+}
+
+func TestGetUpgradeReport(t *testing.T) {
+	assert := require.New(t)
+
+	testCases := map[string]struct {
+		objects         []runtime.Object
+		image           string
+		expectedPath    string
+		expectedReasons int
+		expectedLimit   int64
+	}{
+		"up to date": {
+			objects:      newUpgradeReportObjects(testEngineImage, "0", testEngineImage),
+			expectedPath: manager.VolumeUpgradePathUpToDate,
+		},
+		"live upgrade to the default image": {
+			objects:      newUpgradeReportObjects(testNewEngineImage, "0", testEngineImage, testNewEngineImage),
+			expectedPath: manager.VolumeUpgradePathLive,
+		},
+		"target image not deployed": {
+			objects:         newUpgradeReportObjects(testEngineImage, "0", testEngineImage),
+			image:           testNewEngineImage,
+			expectedPath:    manager.VolumeUpgradePathBlocked,
+			expectedReasons: 1,
+		},
+		"current image not deployed": {
+			objects:         newUpgradeReportObjects(testNewEngineImage, "0", testNewEngineImage),
+			expectedPath:    manager.VolumeUpgradePathBlocked,
+			expectedReasons: 1,
+		},
+		"automatic upgrade to the default image": {
+			objects:       newUpgradeReportObjects(testNewEngineImage, "2", testEngineImage, testNewEngineImage),
+			expectedPath:  manager.VolumeUpgradePathLive,
+			expectedLimit: 2,
+		},
+		"automatic upgrade to another image": {
+			objects:         newUpgradeReportObjects(testEngineImage, "2", testEngineImage, testNewEngineImage),
+			image:           testNewEngineImage,
+			expectedPath:    manager.VolumeUpgradePathBlocked,
+			expectedReasons: 1,
+			expectedLimit:   2,
+		},
+	}
+	for name, tc := range testCases {
+		stopCh := make(chan struct{})
+		c, err := fake.NewCluster(testNamespace, stopCh, tc.objects...)
+		assert.NoError(err, name)
+
+		report, err := c.NewVolumeManager(testNode1).GetUpgradeReport(tc.image)
+		close(stopCh)
+		assert.NoError(err, name)
+		assert.Equal(tc.expectedLimit, report.AutomaticUpgradePerNodeLimit, name)
+		assert.Len(report.Volumes, 1, name)
+		vr := report.Volumes[0]
+		assert.Equal(tc.expectedPath, vr.Path, name)
+		assert.Len(vr.Reasons, tc.expectedReasons, "%v: %v", name, vr.Reasons)
+		if vr.Path == manager.VolumeUpgradePathLive {
+			assert.Equal(1, vr.ReplicaRestarts, name)
+			assert.True(vr.EngineRestart, name)
+		}
+		assert.Equal(1, report.Summary[tc.expectedPath], name)
+	}
+}
+
+func TestGetUpgradeReportAirGapped(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	resolvedImage := "registry.example.com/longhornio/longhorn-engine:new"
+	objects := newUpgradeReportObjects(testNewEngineImage, "0", testEngineImage, resolvedImage)
+	objects = append(objects,
+		&longhorn.Setting{
+			ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameAirGappedMode), Namespace: testNamespace},
+			Value:      "true",
+		},
+		&longhorn.Setting{
+			ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameAirGappedRegistry), Namespace: testNamespace},
+			Value:      "registry.example.com",
+		},
+	)
+	c, err := fake.NewCluster(testNamespace, stopCh, objects...)
+	assert.NoError(err)
+
+	// The image is checked the same way as it would be upgraded to
+	report, err := c.NewVolumeManager(testNode1).GetUpgradeReport("")
+	assert.NoError(err)
+	assert.Equal(resolvedImage, report.EngineImage)
+	assert.Equal(manager.VolumeUpgradePathLive, report.Volumes[0].Path, report.Volumes[0].Reasons)
+}
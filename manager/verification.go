@@ -0,0 +1,377 @@
+package manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	bsutil "github.com/longhorn/backupstore/util"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+const (
+	VerificationStepCreate   = "create"
+	VerificationStepAttach   = "attach"
+	VerificationStepWrite    = "write"
+	VerificationStepVerify   = "verify"
+	VerificationStepSnapshot = "snapshot"
+	VerificationStepBackup   = "backup"
+	VerificationStepRestore  = "restore"
+	VerificationStepDelete   = "delete"
+
+	VerificationStatusPassed  = "passed"
+	VerificationStatusFailed  = "failed"
+	VerificationStatusSkipped = "skipped"
+
+	verificationVolumePrefix  = "verify-"
+	verificationVolumeSize    = 16 * 1024 * 1024
+	verificationPatternSize   = 4096
+	verificationTimeout       = 5 * time.Minute
+	verificationCheckInterval = 2 * time.Second
+)
+
+type nodeVerification struct {
+	m     *VolumeManager
+	node  string
+	log   logrus.FieldLogger
+	steps []longhorn.NodeVerificationStepStatus
+
+	volumeName        string
+	restoreVolumeName string
+	snapshotName      string
+	backupName        string
+	pattern           string
+	checksum          string
+	failed            bool
+}
+
+// VerifyNode starts the verification of the node in the background. It runs
+// the volume life cycle on the node: create a test volume, attach it, write
+// and verify a data pattern, take a snapshot, back it up and restore it if a
+// backup target is available, then clean up. The result of each step is
+// recorded in the verification status of the node as soon as the step
+// finishes. The data path is accessed directly, so it must run on the node
+// itself.
+func (m *VolumeManager) VerifyNode(name string) (node *longhorn.Node, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to verify node %v", name)
+	}()
+
+	if name != m.currentNodeID {
+		return nil, fmt.Errorf("node can only be verified on the node itself, current node is %v", m.currentNodeID)
+	}
+
+	m.nodeVerificationLock.Lock()
+	defer m.nodeVerificationLock.Unlock()
+	// The state in progress left by a manager that was restarted during the
+	// verification is overwritten, since nothing is running it anymore
+	if m.nodeVerificationRunning {
+		return nil, types.NewReasonError(types.ErrorReasonConflict,
+			map[string]string{types.ErrorParameterNode: name},
+			"node %v is being verified", name)
+	}
+
+	node, err = m.ds.GetNode(name)
+	if err != nil {
+		return nil, err
+	}
+	if types.GetCondition(node.Status.Conditions, longhorn.NodeConditionTypeReady).Status != longhorn.ConditionStatusTrue {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState,
+			map[string]string{types.ErrorParameterNode: name},
+			"node %v is not ready", name)
+	}
+
+	node.Status.VerificationStatus = longhorn.NodeVerificationStatus{
+		State:          longhorn.NodeVerificationStateInProgress,
+		Steps:          []longhorn.NodeVerificationStepStatus{},
+		StartedAt:      m.now(),
+		LastVerifiedAt: node.Status.VerificationStatus.LastVerifiedAt,
+	}
+	if node, err = m.ds.UpdateNodeStatus(node); err != nil {
+		return nil, err
+	}
+
+	volumeName := verificationVolumePrefix + util.RandomID()
+	nv := &nodeVerification{
+		m:                 m,
+		node:              name,
+		log:               logrus.WithFields(logrus.Fields{"node": name, "volume": volumeName}),
+		steps:             []longhorn.NodeVerificationStepStatus{},
+		volumeName:        volumeName,
+		restoreVolumeName: volumeName + "-restore",
+		pattern:           volumeName,
+	}
+	nv.checksum = getVerificationPatternChecksum(nv.pattern)
+
+	m.nodeVerificationRunning = true
+	go func() {
+		defer func() {
+			m.nodeVerificationLock.Lock()
+			m.nodeVerificationRunning = false
+			m.nodeVerificationLock.Unlock()
+		}()
+		nv.runSteps()
+	}()
+	nv.log.Info("Started node verification")
+	return node, nil
+}
+
+func (nv *nodeVerification) runSteps() {
+	nv.run(VerificationStepCreate, nv.create)
+	nv.run(VerificationStepAttach, nv.attach)
+	nv.run(VerificationStepWrite, nv.write)
+	nv.run(VerificationStepVerify, nv.verify)
+	nv.run(VerificationStepSnapshot, nv.snapshot)
+	if reason := nv.checkBackupTarget(); reason != "" {
+		nv.skip(VerificationStepBackup, reason)
+		nv.skip(VerificationStepRestore, reason)
+	} else {
+		nv.run(VerificationStepBackup, nv.backup)
+		nv.run(VerificationStepRestore, nv.restore)
+	}
+	// Always clean up, even if the previous steps failed
+	failed := nv.failed
+	nv.failed = false
+	nv.run(VerificationStepDelete, nv.cleanup)
+	failed = failed || nv.failed
+
+	state := longhorn.NodeVerificationStatePassed
+	if failed {
+		state = longhorn.NodeVerificationStateFailed
+	}
+	nv.log.Infof("Finished node verification with state %v", state)
+	nv.updateStatus(func(status *longhorn.NodeVerificationStatus) {
+		status.State = state
+		status.LastVerifiedAt = nv.m.now()
+	})
+}
+
+func getVerificationPatternChecksum(pattern string) string {
+	line := pattern + "\n"
+	data := strings.Repeat(line, verificationPatternSize/len(line)+1)[:verificationPatternSize]
+	checksum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(checksum[:])
+}
+
+func (nv *nodeVerification) run(step string, f func() error) {
+	if nv.failed {
+		nv.skip(step, "previous step failed")
+		return
+	}
+	if err := f(); err != nil {
+		nv.log.WithError(err).Warnf("Verification step %v failed", step)
+		nv.failed = true
+		nv.record(longhorn.NodeVerificationStepStatus{
+			Step:    step,
+			Status:  VerificationStatusFailed,
+			Message: err.Error(),
+		})
+		return
+	}
+	nv.record(longhorn.NodeVerificationStepStatus{
+		Step:   step,
+		Status: VerificationStatusPassed,
+	})
+}
+
+func (nv *nodeVerification) skip(step, reason string) {
+	nv.record(longhorn.NodeVerificationStepStatus{
+		Step:    step,
+		Status:  VerificationStatusSkipped,
+		Message: reason,
+	})
+}
+
+func (nv *nodeVerification) record(result longhorn.NodeVerificationStepStatus) {
+	nv.steps = append(nv.steps, result)
+	nv.updateStatus(func(status *longhorn.NodeVerificationStatus) {})
+}
+
+// updateStatus applies the update to the verification status of the node,
+// along with the results of all the finished steps. A failed update is only
+// logged, since the verification goes on anyway.
+func (nv *nodeVerification) updateStatus(update func(status *longhorn.NodeVerificationStatus)) {
+	if _, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		node, err := nv.m.ds.GetNode(nv.node)
+		if err != nil {
+			return nil, err
+		}
+		node.Status.VerificationStatus.Steps = append([]longhorn.NodeVerificationStepStatus{}, nv.steps...)
+		update(&node.Status.VerificationStatus)
+		return nv.m.ds.UpdateNodeStatus(node)
+	}); err != nil {
+		nv.log.WithError(err).Warn("Failed to update node verification status")
+	}
+}
+
+func (nv *nodeVerification) waitForVolume(name, description string, check func(v *longhorn.Volume) bool) error {
+	deadline := time.Now().Add(verificationTimeout)
+	for time.Now().Before(deadline) {
+		v, err := nv.m.ds.GetVolumeRO(name)
+		if err != nil {
+			return err
+		}
+		if check(v) {
+			return nil
+		}
+		time.Sleep(verificationCheckInterval)
+	}
+	return fmt.Errorf("timeout waiting for volume %v to be %v", name, description)
+}
+
+func isVolumeAttachedToNode(v *longhorn.Volume, node string) bool {
+	return v.Status.State == longhorn.VolumeStateAttached && v.Status.CurrentNodeID == node &&
+		(v.Status.Robustness == longhorn.VolumeRobustnessHealthy || v.Status.Robustness == longhorn.VolumeRobustnessDegraded)
+}
+
+func (nv *nodeVerification) create() error {
+	if _, err := nv.m.Create(context.Background(), nv.volumeName, &longhorn.VolumeSpec{
+		Size:             verificationVolumeSize,
+		NumberOfReplicas: 1,
+		DataLocality:     longhorn.DataLocalityBestEffort,
+		Frontend:         longhorn.VolumeFrontendBlockDev,
+	}, nil, nil, "", ""); err != nil {
+		return err
+	}
+	return nv.waitForVolume(nv.volumeName, "detached", func(v *longhorn.Volume) bool {
+		return v.Status.State == longhorn.VolumeStateDetached
+	})
+}
+
+func (nv *nodeVerification) attach() error {
+	if _, err := nv.m.Attach(context.Background(), nv.volumeName, nv.node, false, ""); err != nil {
+		return err
+	}
+	return nv.waitForVolume(nv.volumeName, "attached", func(v *longhorn.Volume) bool {
+		return isVolumeAttachedToNode(v, nv.node)
+	})
+}
+
+func (nv *nodeVerification) write() error {
+	return util.WriteHostDevicePattern(util.RegularDeviceDirectory+nv.volumeName, nv.pattern, verificationPatternSize)
+}
+
+func (nv *nodeVerification) verify() error {
+	return nv.verifyVolumeData(nv.volumeName)
+}
+
+func (nv *nodeVerification) verifyVolumeData(volumeName string) error {
+	checksum, err := util.GetHostDeviceChecksum(util.RegularDeviceDirectory+volumeName, verificationPatternSize)
+	if err != nil {
+		return err
+	}
+	if checksum != nv.checksum {
+		return fmt.Errorf("data checksum %v of volume %v does not match the written pattern checksum %v", checksum, volumeName, nv.checksum)
+	}
+	return nil
+}
+
+func (nv *nodeVerification) snapshot() error {
+	snapshot, err := nv.m.CreateSnapshot(context.Background(), "", nil, nv.volumeName)
+	if err != nil {
+		return err
+	}
+	nv.snapshotName = snapshot.Name
+	return nil
+}
+
+// checkBackupTarget returns the reason why the backup steps cannot be run,
+// or an empty string if they can.
+func (nv *nodeVerification) checkBackupTarget() string {
+	backupTarget, err := nv.m.ds.GetDefaultBackupTargetRO()
+	if err != nil {
+		return fmt.Sprintf("failed to get the default backup target: %v", err)
+	}
+	if backupTarget.Spec.BackupTargetURL == "" {
+		return "backup target is not set"
+	}
+	if !backupTarget.Status.Available {
+		return "backup target is not available"
+	}
+	return ""
+}
+
+func (nv *nodeVerification) backup() error {
+	nv.backupName = bsutil.GenerateName("backup")
+	if err := nv.m.BackupSnapshot(nv.backupName, nv.volumeName, nv.snapshotName, nil); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(verificationTimeout)
+	for time.Now().Before(deadline) {
+		backup, err := nv.m.ds.GetBackupRO(nv.backupName)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if backup != nil {
+			switch backup.Status.State {
+			case longhorn.BackupStateCompleted:
+				return nil
+			case longhorn.BackupStateError:
+				return fmt.Errorf("backup %v failed: %v", nv.backupName, backup.Status.Error)
+			}
+		}
+		time.Sleep(verificationCheckInterval)
+	}
+	return fmt.Errorf("timeout waiting for backup %v to complete", nv.backupName)
+}
+
+func (nv *nodeVerification) restore() error {
+	backup, err := nv.m.ds.GetBackupRO(nv.backupName)
+	if err != nil {
+		return err
+	}
+	if _, err := nv.m.Create(context.Background(), nv.restoreVolumeName, &longhorn.VolumeSpec{
+		Size:             verificationVolumeSize,
+		NumberOfReplicas: 1,
+		DataLocality:     longhorn.DataLocalityBestEffort,
+		Frontend:         longhorn.VolumeFrontendBlockDev,
+		FromBackup:       backup.Status.URL,
+	}, nil, nil, "", ""); err != nil {
+		return err
+	}
+	if err := nv.waitForVolume(nv.restoreVolumeName, "restored", func(v *longhorn.Volume) bool {
+		return v.Status.State == longhorn.VolumeStateDetached && !v.Status.RestoreRequired &&
+			types.GetCondition(v.Status.Conditions, longhorn.VolumeConditionTypeRestore).Status != longhorn.ConditionStatusTrue
+	}); err != nil {
+		return err
+	}
+	if _, err := nv.m.Attach(context.Background(), nv.restoreVolumeName, nv.node, false, ""); err != nil {
+		return err
+	}
+	if err := nv.waitForVolume(nv.restoreVolumeName, "attached", func(v *longhorn.Volume) bool {
+		return isVolumeAttachedToNode(v, nv.node)
+	}); err != nil {
+		return err
+	}
+	return nv.verifyVolumeData(nv.restoreVolumeName)
+}
+
+func (nv *nodeVerification) cleanup() error {
+	var errs []string
+	for _, name := range []string{nv.volumeName, nv.restoreVolumeName} {
+		if err := nv.m.Delete(context.Background(), name); err != nil && !apierrors.IsNotFound(errors.Cause(err)) {
+			errs = append(errs, err.Error())
+		}
+	}
+	if nv.backupName != "" {
+		if err := nv.m.DeleteBackup(nv.backupName, nv.volumeName); err != nil && !apierrors.IsNotFound(errors.Cause(err)) {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to clean up verification resources: %v", strings.Join(errs, "; "))
+	}
+	return nil
+}
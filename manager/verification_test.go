@@ -0,0 +1,119 @@
+package manager_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+const (
+	testNamespace = "longhorn-system"
+	testNode1     = "test-node-name-1"
+	testNode2     = "test-node-name-2"
+)
+
+func newReadyNode(name string) *longhorn.Node {
+	return &longhorn.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+		},
+		Status: longhorn.NodeStatus{
+			Conditions: []longhorn.Condition{
+				{
+					Type:   longhorn.NodeConditionTypeReady,
+					Status: longhorn.ConditionStatusTrue,
+				},
+			},
+		},
+	}
+}
+
+func waitForNodeVerification(t *testing.T, c *fake.Cluster, name string) *longhorn.Node {
+	var node *longhorn.Node
+	require.Eventually(t, func() bool {
+		var err error
+		node, err = c.DataStore.GetNodeRO(name)
+		require.NoError(t, err)
+		return node.Status.VerificationStatus.State != longhorn.NodeVerificationStateInProgress
+	}, 10*time.Second, 10*time.Millisecond)
+	return node
+}
+
+func TestVerifyNode(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	notReadyNode := newReadyNode(testNode2)
+	notReadyNode.Status.Conditions[0].Status = longhorn.ConditionStatusFalse
+	c, err := fake.NewCluster(testNamespace, stopCh, newReadyNode(testNode1), notReadyNode)
+	assert.NoError(err)
+
+	m := c.NewVolumeManager(testNode1)
+
+	// Only the node itself runs the verification
+	_, err = m.VerifyNode(testNode2)
+	assert.Error(err)
+	_, err = c.NewVolumeManager(testNode2).VerifyNode(testNode2)
+	assert.Equal(types.ErrorReasonInvalidState, types.GetReasonError(err).Reason)
+
+	// Hold the verification in the create step, so the second request comes
+	// while it's running
+	c.Faults.Add(fake.Fault{Operation: "create", Target: "volumes", Err: fmt.Errorf("injected"), Times: 1, Latency: 200 * time.Millisecond})
+	node, err := m.VerifyNode(testNode1)
+	assert.NoError(err)
+	assert.Equal(longhorn.NodeVerificationStateInProgress, node.Status.VerificationStatus.State)
+	assert.NotEmpty(node.Status.VerificationStatus.StartedAt)
+	assert.Empty(node.Status.VerificationStatus.Steps)
+
+	_, err = m.VerifyNode(testNode1)
+	assert.Equal(types.ErrorReasonConflict, types.GetReasonError(err).Reason)
+
+	// The failed create skips the following steps, but the clean up still runs
+	node = waitForNodeVerification(t, c, testNode1)
+	status := node.Status.VerificationStatus
+	assert.Equal(longhorn.NodeVerificationStateFailed, status.State)
+	assert.NotEmpty(status.LastVerifiedAt)
+	expectedSteps := []string{
+		manager.VerificationStatusFailed,
+		manager.VerificationStatusSkipped,
+		manager.VerificationStatusSkipped,
+		manager.VerificationStatusSkipped,
+		manager.VerificationStatusSkipped,
+		manager.VerificationStatusSkipped,
+		manager.VerificationStatusSkipped,
+		manager.VerificationStatusPassed,
+	}
+	assert.Len(status.Steps, len(expectedSteps))
+	for i, expected := range expectedSteps {
+		assert.Equal(expected, status.Steps[i].Status, "step %v", status.Steps[i].Step)
+	}
+	assert.Equal(manager.VerificationStepCreate, status.Steps[0].Step)
+	assert.Contains(status.Steps[0].Message, "injected")
+	assert.Equal(manager.VerificationStepDelete, status.Steps[7].Step)
+
+	// The state left in progress by a restarted manager doesn't block a new
+	// verification
+	node, err = c.DataStore.GetNode(testNode1)
+	assert.NoError(err)
+	node.Status.VerificationStatus.State = longhorn.NodeVerificationStateInProgress
+	_, err = c.DataStore.UpdateNodeStatus(node)
+	assert.NoError(err)
+	c.Faults.Add(fake.Fault{Operation: "create", Target: "volumes", Err: fmt.Errorf("injected"), Times: 1})
+	node, err = c.NewVolumeManager(testNode1).VerifyNode(testNode1)
+	assert.NoError(err)
+	assert.Equal(status.LastVerifiedAt, node.Status.VerificationStatus.LastVerifiedAt)
+	assert.Equal(longhorn.NodeVerificationStateFailed, waitForNodeVerification(t, c, testNode1).Status.VerificationStatus.State)
+}
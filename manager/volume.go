@@ -1,8 +1,13 @@
 package manager
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -12,8 +17,11 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/engineapi"
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 	"github.com/longhorn/longhorn-manager/scheduler"
 	"github.com/longhorn/longhorn-manager/types"
@@ -27,17 +35,46 @@ type VolumeManager struct {
 	currentNodeID string
 
 	proxyConnCounter util.Counter
+
+	clock               Clock
+	engineClientFactory EngineClientFactory
+	freezer             FilesystemFreezer
+	engineStatusCache   *engineapi.StatusCache
+
+	policies []VolumePolicy
+
+	volumeOperations *volumeOperationQueue
+
+	engineOperationLock     sync.Mutex
+	engineOperationsRunning map[string]bool
+
+	nodeVerificationLock    sync.Mutex
+	nodeVerificationRunning bool
 }
 
+// NewVolumeManager creates the manager with the real clock and the engine
+// clients. It keeps no state out of itself, so multiple managers can be
+// embedded in the same process.
 func NewVolumeManager(currentNodeID string, ds *datastore.DataStore, proxyConnCounter util.Counter) *VolumeManager {
-	return &VolumeManager{
+	m := &VolumeManager{
 		ds:        ds,
 		scheduler: scheduler.NewReplicaScheduler(ds),
 
 		currentNodeID: currentNodeID,
 
 		proxyConnCounter: proxyConnCounter,
+
+		clock:   realClock{},
+		freezer: defaultFilesystemFreezer{},
+
+		engineStatusCache: engineapi.NewStatusCache(),
+
+		volumeOperations: newVolumeOperationQueue(),
+
+		engineOperationsRunning: map[string]bool{},
 	}
+	m.engineClientFactory = &defaultEngineClientFactory{m: m}
+	return m
 }
 
 func (m *VolumeManager) GetCurrentNodeID() string {
@@ -56,6 +93,21 @@ func (m *VolumeManager) Node2APIAddress(nodeID string) (string, error) {
 	return types.GetAPIServerAddressFromIP(ip), nil
 }
 
+// IsManagerIP returns if the IP belongs to a manager, e.g. to trust the
+// requests forwarded by the other managers.
+func (m *VolumeManager) IsManagerIP(ip string) (bool, error) {
+	nodeIPMap, err := m.ds.GetManagerNodeIPMap()
+	if err != nil {
+		return false, err
+	}
+	for _, managerIP := range nodeIPMap {
+		if managerIP == ip {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (m *VolumeManager) List() (map[string]*longhorn.Volume, error) {
 	return m.ds.ListVolumes()
 }
@@ -77,6 +129,81 @@ func (m *VolumeManager) ListSorted() ([]*longhorn.Volume, error) {
 	return volumes, nil
 }
 
+type VolumeListOptions struct {
+	// Limit is the maximum number of volumes in a page, 0 means no limit
+	Limit int
+	// Continue is the token returned with the previous page
+	Continue string
+
+	State         longhorn.VolumeState
+	NodeID        string
+	LabelSelector string
+	// Tenant limits the volumes to the ones of the tenant
+	Tenant string
+}
+
+// ListPage returns a page of the volumes matching the options, sorted by name,
+// and the token to get the next page, which is empty for the last page.
+func (m *VolumeManager) ListPage(opts *VolumeListOptions) (volumes []*longhorn.Volume, continueToken string, err error) {
+	selector := labels.Everything()
+	if opts.LabelSelector != "" {
+		if selector, err = labels.Parse(opts.LabelSelector); err != nil {
+			return nil, "", types.NewReasonError(types.ErrorReasonInvalidParameter,
+				map[string]string{types.ErrorParameterParameter: "labelSelector", types.ErrorParameterValue: opts.LabelSelector},
+				"invalid label selector %v: %v", opts.LabelSelector, err)
+		}
+	}
+	if opts.Tenant != "" {
+		if err := types.ValidateTenant(opts.Tenant); err != nil {
+			return nil, "", types.NewReasonError(types.ErrorReasonInvalidParameter,
+				map[string]string{types.ErrorParameterParameter: "tenant", types.ErrorParameterValue: opts.Tenant}, "%v", err)
+		}
+		requirements, _ := getTenantSelector(opts.Tenant).Requirements()
+		selector = selector.Add(requirements...)
+	}
+	if opts.Limit < 0 {
+		return nil, "", types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "limit", types.ErrorParameterValue: strconv.Itoa(opts.Limit)},
+			"invalid limit %v", opts.Limit)
+	}
+	startAfter := ""
+	if opts.Continue != "" {
+		name, err := base64.RawURLEncoding.DecodeString(opts.Continue)
+		if err != nil {
+			return nil, "", types.NewReasonError(types.ErrorReasonInvalidParameter,
+				map[string]string{types.ErrorParameterParameter: "continue", types.ErrorParameterValue: opts.Continue},
+				"invalid continue token %v", opts.Continue)
+		}
+		startAfter = string(name)
+	}
+
+	filter := func(v *longhorn.Volume) bool {
+		if opts.State != "" && v.Status.State != opts.State {
+			return false
+		}
+		if opts.NodeID != "" && v.Status.CurrentNodeID != opts.NodeID {
+			return false
+		}
+		return true
+	}
+	list, more, err := m.ds.ListVolumesSortedBySelectorRO(selector, filter, startAfter, opts.Limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	volumes = make([]*longhorn.Volume, len(list))
+	for i, v := range list {
+		// Cannot use cached object from lister
+		volumes[i] = v.DeepCopy()
+	}
+	// The token is the name of the last volume returned, so the next page
+	// stays consistent when volumes are created or deleted in between
+	if more {
+		continueToken = base64.RawURLEncoding.EncodeToString([]byte(list[len(list)-1].Name))
+	}
+	return volumes, continueToken, nil
+}
+
 func (m *VolumeManager) Get(vName string) (*longhorn.Volume, error) {
 	return m.ds.GetVolume(vName)
 }
@@ -123,7 +250,12 @@ func (m *VolumeManager) GetReplicasSorted(vName string) ([]*longhorn.Replica, er
 	return replicas, nil
 }
 
-func (m *VolumeManager) Create(name string, spec *longhorn.VolumeSpec, recurringJobSelector []longhorn.VolumeRecurringJob) (v *longhorn.Volume, err error) {
+// Create creates the volume. The name is lower cased and shortened as by the
+// volume mutator. If the idempotency key is not empty and the volume is
+// already created with the same key, e.g. by a retried request, the existing
+// volume is returned instead of an AlreadyExists error, or a Conflict error
+// if the request differs.
+func (m *VolumeManager) Create(ctx context.Context, name string, spec *longhorn.VolumeSpec, recurringJobSelector []longhorn.VolumeRecurringJob, userLabels map[string]string, tenant, idempotencyKey string) (v *longhorn.Volume, err error) {
 	defer func() {
 		err = errors.Wrapf(err, "unable to create volume %v", name)
 		if err != nil {
@@ -131,7 +263,33 @@ func (m *VolumeManager) Create(name string, spec *longhorn.VolumeSpec, recurring
 		}
 	}()
 
+	// Correct the name the same way as the volume mutator does
+	name = util.AutoCorrectName(name, datastore.NameMaximumLength)
+	if err := validateVolumeName(name); err != nil {
+		return nil, err
+	}
+	checksum, err := getVolumeCreateChecksum(spec, recurringJobSelector, userLabels, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := m.ds.GetVolume(name); err == nil {
+		return getExistingVolumeForCreation(existing, idempotencyKey, checksum)
+	} else if !datastore.ErrorIsNotFound(err) {
+		return nil, err
+	}
+
+	spec, err = m.validateVolumeCreation(ctx, name, spec, userLabels, tenant)
+	if err != nil {
+		return nil, err
+	}
+
 	labels := map[string]string{}
+	for key, value := range userLabels {
+		labels[key] = value
+	}
+	if tenant != "" {
+		labels[types.GetLonghornLabelKey(types.LonghornLabelTenant)] = tenant
+	}
 	for _, job := range recurringJobSelector {
 		labelType := types.LonghornLabelRecurringJob
 		if job.IsGroup {
@@ -141,16 +299,17 @@ func (m *VolumeManager) Create(name string, spec *longhorn.VolumeSpec, recurring
 		labels[key] = types.LonghornLabelValueEnabled
 	}
 
-	if spec.DataSource != "" {
-		if err := m.verifyDataSourceForVolumeCreation(spec.DataSource, spec.Size); err != nil {
-			return nil, err
-		}
+	annotations := map[string]string{}
+	if idempotencyKey != "" {
+		annotations[types.VolumeAnnotationIdempotencyKey] = idempotencyKey
+		annotations[types.VolumeAnnotationIdempotencyChecksum] = checksum
 	}
 
 	v = &longhorn.Volume{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   name,
-			Labels: labels,
+			Name:        name,
+			Labels:      labels,
+			Annotations: annotations,
 		},
 		Spec: longhorn.VolumeSpec{
 			Size:                      spec.Size,
@@ -174,30 +333,215 @@ func (m *VolumeManager) Create(name string, spec *longhorn.VolumeSpec, recurring
 			SnapshotDataIntegrity:     spec.SnapshotDataIntegrity,
 			BackupCompressionMethod:   spec.BackupCompressionMethod,
 			UnmapMarkSnapChainRemoved: spec.UnmapMarkSnapChainRemoved,
+			ExpireAt:                  spec.ExpireAt,
+			RebuildBandwidthLimit:     spec.RebuildBandwidthLimit,
+			FrontendIOPSLimit:         spec.FrontendIOPSLimit,
+			FrontendBandwidthLimit:    spec.FrontendBandwidthLimit,
+			FilesystemType:            spec.FilesystemType,
+			Filesystem:                spec.Filesystem,
+			MountOptions:              spec.MountOptions,
+			SnapshotMaxCount:          spec.SnapshotMaxCount,
+			SnapshotMaxAge:            spec.SnapshotMaxAge,
 		},
 	}
+	setLastRequestID(ctx, v)
 
 	v, err = m.ds.CreateVolume(v)
 	if err != nil {
-		return nil, err
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+		// Created by a concurrent request, which can be a retry with the
+		// same idempotency key
+		existing, getErr := m.getCreatedVolume(name)
+		if getErr != nil {
+			return nil, errors.Wrapf(err, "failed to get the concurrently created volume: %v", getErr)
+		}
+		return getExistingVolumeForCreation(existing, idempotencyKey, checksum)
 	}
 	logrus.Debugf("Created volume %v: %+v", v.Name, v.Spec)
 	return v, nil
 }
 
-func (m *VolumeManager) Delete(name string) error {
+// getExistingVolumeForCreation returns the existing volume if it's created
+// by the same request with the idempotency key. Otherwise, the creation is a
+// Conflict error if the key is reused by a different request, or an
+// AlreadyExists error.
+func getExistingVolumeForCreation(existing *longhorn.Volume, idempotencyKey, checksum string) (*longhorn.Volume, error) {
+	name := existing.Name
+	if idempotencyKey != "" && existing.Annotations[types.VolumeAnnotationIdempotencyKey] == idempotencyKey {
+		existingChecksum, ok := existing.Annotations[types.VolumeAnnotationIdempotencyChecksum]
+		if ok && existingChecksum != checksum {
+			return nil, types.NewReasonError(types.ErrorReasonConflict,
+				map[string]string{types.ErrorParameterKind: "volume", types.ErrorParameterName: name},
+				"volume %v is created with idempotency key %v by a different request", name, idempotencyKey)
+		}
+		logrus.Infof("Volume %v is already created with idempotency key %v", name, idempotencyKey)
+		return existing, nil
+	}
+	return nil, types.NewReasonError(types.ErrorReasonAlreadyExists,
+		map[string]string{types.ErrorParameterKind: "volume", types.ErrorParameterName: name},
+		"volume %v already exists", name)
+}
+
+// getCreatedVolume waits for the volume that is just created to show up in
+// the datastore.
+func (m *VolumeManager) getCreatedVolume(name string) (v *longhorn.Volume, err error) {
+	for i := 0; i < datastore.VerificationRetryCounts; i++ {
+		if v, err = m.ds.GetVolume(name); err == nil || !datastore.ErrorIsNotFound(err) {
+			return v, err
+		}
+		time.Sleep(datastore.VerificationRetryInterval)
+	}
+	return nil, err
+}
+
+// validateVolumeCreation checks the volume can be created, and returns the
+// spec reviewed by the volume policies.
+func (m *VolumeManager) validateVolumeCreation(ctx context.Context, name string, spec *longhorn.VolumeSpec, userLabels map[string]string, tenant string) (*longhorn.VolumeSpec, error) {
+	if err := validateVolumeUserLabels(userLabels); err != nil {
+		return nil, err
+	}
+	if tenant != "" {
+		if err := types.ValidateTenant(tenant); err != nil {
+			return nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+				map[string]string{types.ErrorParameterParameter: "tenant", types.ErrorParameterValue: tenant}, "%v", err)
+		}
+	}
+	review, err := m.reviewVolumeOperation(&VolumePolicyReview{
+		Operation:  VolumePolicyOperationCreate,
+		Volume:     name,
+		Spec:       spec,
+		Labels:     userLabels,
+		Parameters: map[string]string{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	spec = review.Spec
+	if err := m.checkVolumeSizeFitsDisks(spec.Size); err != nil {
+		return nil, err
+	}
+	if err := m.ds.CheckTenantQuota(tenant, name, spec.Size); err != nil {
+		return nil, err
+	}
+	if spec.DataSource != "" {
+		if err := m.verifyDataSourceForVolumeCreation(ctx, spec.DataSource, spec.Size); err != nil {
+			return nil, err
+		}
+	}
+	return spec, nil
+}
+
+// getVolumeCreateChecksum returns the checksum of the create request, so
+// that a retry with the same idempotency key can be told apart from another
+// request reusing the key.
+func getVolumeCreateChecksum(spec *longhorn.VolumeSpec, recurringJobSelector []longhorn.VolumeRecurringJob, userLabels map[string]string, tenant string) (string, error) {
+	data, err := json.Marshal(struct {
+		Spec                 *longhorn.VolumeSpec
+		RecurringJobSelector []longhorn.VolumeRecurringJob
+		Labels               map[string]string
+		Tenant               string
+	}{spec, recurringJobSelector, userLabels, tenant})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode the create request")
+	}
+	return util.GetChecksumSHA256(data), nil
+}
+
+func validateVolumeName(name string) error {
+	if errs := validation.IsDNS1123Label(name); len(errs) != 0 {
+		return types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "name", types.ErrorParameterValue: name},
+			"invalid volume name %v: %v", name, strings.Join(errs, ", "))
+	}
+	if len(name) > datastore.NameMaximumLength {
+		return types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "name", types.ErrorParameterValue: name},
+			"volume name %v is longer than %v characters", name, datastore.NameMaximumLength)
+	}
+	return nil
+}
+
+// setLastRequestID records the ID of the request in the context on the
+// volume, if any.
+func setLastRequestID(ctx context.Context, v *longhorn.Volume) {
+	requestID := util.GetRequestID(ctx)
+	if requestID == "" {
+		return
+	}
+	if v.Annotations == nil {
+		v.Annotations = map[string]string{}
+	}
+	v.Annotations[types.VolumeAnnotationLastRequestID] = requestID
+}
+
+// checkVolumeSizeFitsDisks rejects the volumes that no disk can hold a
+// replica of. It's skipped if there is no schedulable disk yet, since the
+// disks may be added later.
+func (m *VolumeManager) checkVolumeSizeFitsDisks(size int64) error {
+	maxSize, err := m.scheduler.GetMaxReplicaSize()
+	if err != nil {
+		return err
+	}
+	if maxSize == 0 || size <= maxSize {
+		return nil
+	}
+	return types.NewReasonError(types.ErrorReasonInsufficientStorage,
+		map[string]string{types.ErrorParameterNeeded: strconv.FormatInt(size, 10), types.ErrorParameterAvailable: strconv.FormatInt(maxSize, 10)},
+		"volume size %v is larger than the maximum replica size %v that the disks can hold", size, maxSize)
+}
+
+func (m *VolumeManager) Delete(ctx context.Context, name string) error {
+	release, err := m.volumeOperations.acquire(ctx, name, VolumeOperationDelete)
+	if err != nil {
+		return errors.Wrapf(err, "unable to delete volume %v", name)
+	}
+	defer release()
+
+	if _, err := m.reviewVolumeOperation(&VolumePolicyReview{
+		Operation:  VolumePolicyOperationDelete,
+		Volume:     name,
+		Parameters: map[string]string{},
+	}); err != nil {
+		return errors.Wrapf(err, "unable to delete volume %v", name)
+	}
 	if err := m.ds.DeleteVolume(name); err != nil {
 		return err
 	}
-	logrus.Debugf("Deleted volume %v", name)
+	util.WithRequestIDField(ctx, logrus.StandardLogger()).Debugf("Deleted volume %v", name)
 	return nil
 }
 
-func (m *VolumeManager) Attach(name, nodeID string, disableFrontend bool, attachedBy string) (v *longhorn.Volume, err error) {
+func (m *VolumeManager) Attach(ctx context.Context, name, nodeID string, disableFrontend bool, attachedBy string) (v *longhorn.Volume, err error) {
 	defer func() {
 		err = errors.Wrapf(err, "unable to attach volume %v to %v", name, nodeID)
 	}()
 
+	release, err := m.volumeOperations.acquire(ctx, name, VolumeOperationAttach)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	review, err := m.reviewVolumeOperation(&VolumePolicyReview{
+		Operation: VolumePolicyOperationAttach,
+		Volume:    name,
+		Parameters: map[string]string{
+			VolumePolicyParameterNode:            nodeID,
+			VolumePolicyParameterDisableFrontend: strconv.FormatBool(disableFrontend),
+			VolumePolicyParameterAttachedBy:      attachedBy,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	nodeID = review.Parameters[VolumePolicyParameterNode]
+	if disableFrontend, err = strconv.ParseBool(review.Parameters[VolumePolicyParameterDisableFrontend]); err != nil {
+		return nil, errors.Wrapf(err, "invalid %v parameter from volume policy", VolumePolicyParameterDisableFrontend)
+	}
+	attachedBy = review.Parameters[VolumePolicyParameterAttachedBy]
+
 	node, err := m.ds.GetNode(nodeID)
 	if err != nil {
 		return nil, err
@@ -229,6 +573,22 @@ func (m *VolumeManager) Attach(name, nodeID string, disableFrontend bool, attach
 	}
 
 	if v.Spec.NodeID == nodeID {
+		// The volume is exclusive to the requester of the attachment
+		ticket := v.Spec.AttachmentTicket
+		// The attachment without a requester, e.g. by the CSI driver before
+		// it sent one, is taken over by the requester
+		if ticket.NodeID == nodeID && ticket.AttachedBy == "" && attachedBy != "" {
+			logrus.Infof("Volume %v attachment to %v is taken over by %v", v.Name, nodeID, attachedBy)
+			v.Spec.AttachmentTicket.AttachedBy = attachedBy
+			v.Spec.LastAttachedBy = attachedBy
+			setLastRequestID(ctx, v)
+			return m.ds.UpdateVolume(v)
+		}
+		if ticket.NodeID == nodeID && ticket.AttachedBy != attachedBy {
+			return nil, types.NewReasonError(types.ErrorReasonConflict,
+				map[string]string{types.ErrorParameterNode: nodeID, types.ErrorParameterName: ticket.AttachedBy},
+				"volume %v is already attached to node %v by %v since %v", v.Name, nodeID, ticket.AttachedBy, ticket.RequestedAt)
+		}
 		logrus.Debugf("Volume %v is already attached to node %v", v.Name, v.Spec.NodeID)
 		return v, nil
 	}
@@ -244,9 +604,19 @@ func (m *VolumeManager) Attach(name, nodeID string, disableFrontend bool, attach
 
 	isVolumeShared := v.Spec.AccessMode == longhorn.AccessModeReadWriteMany && !v.Spec.Migratable
 	isVolumeDetached := v.Spec.NodeID == ""
+	if !isVolumeShared || disableFrontend {
+		if err := m.checkNodeAttachedVolumeLimit(v, nodeID); err != nil {
+			return nil, err
+		}
+	}
 	if isVolumeDetached {
 		if !isVolumeShared || disableFrontend {
 			v.Spec.NodeID = nodeID
+			v.Spec.AttachmentTicket = longhorn.AttachmentTicket{
+				NodeID:      nodeID,
+				AttachedBy:  attachedBy,
+				RequestedAt: m.now(),
+			}
 			logrus.Infof("Volume %v attachment to %v with disableFrontend %v requested", v.Name, v.Spec.NodeID, disableFrontend)
 		}
 	} else if isVolumeShared {
@@ -285,6 +655,7 @@ func (m *VolumeManager) Attach(name, nodeID string, disableFrontend bool, attach
 
 	v.Spec.DisableFrontend = disableFrontend
 	v.Spec.LastAttachedBy = attachedBy
+	setLastRequestID(ctx, v)
 	v, err = m.ds.UpdateVolume(v)
 	if err != nil {
 		return nil, err
@@ -292,13 +663,53 @@ func (m *VolumeManager) Attach(name, nodeID string, disableFrontend bool, attach
 	return v, nil
 }
 
+// checkNodeAttachedVolumeLimit checks if one more volume can be attached to
+// the node, counting the volumes migrating to the node as well.
+// The check counts the volumes in the datastore cache before the attachment
+// is written, so concurrent attachments to the same node can each pass it and
+// exceed the limit. The limit is a soft one: it stops the node from taking
+// more volumes once it's reached, but isn't enforced atomically.
+func (m *VolumeManager) checkNodeAttachedVolumeLimit(volume *longhorn.Volume, nodeID string) error {
+	limit, err := m.ds.GetNodeSettingAsInt(types.SettingNameMaxAttachedVolumesPerNode, nodeID)
+	if err != nil {
+		return err
+	}
+	if limit <= 0 {
+		return nil
+	}
+	volumes, err := m.ds.ListVolumesRO()
+	if err != nil {
+		return err
+	}
+	var count int64
+	for _, v := range volumes {
+		if v.Name != volume.Name && (v.Spec.NodeID == nodeID || v.Spec.MigrationNodeID == nodeID) {
+			count++
+		}
+	}
+	if count >= limit {
+		return types.NewReasonError(types.ErrorReasonQuotaExceeded,
+			map[string]string{types.ErrorParameterNode: nodeID, types.ErrorParameterLimit: strconv.FormatInt(limit, 10)},
+			"node %v already has %v volumes attached, reaching the limit %v", nodeID, count, limit)
+	}
+	return nil
+}
+
 // Detach will handle regular detachment as well as volume migration confirmation/rollback
-// if nodeID is not specified, the volume will be detached from all nodes
-func (m *VolumeManager) Detach(name, nodeID string) (v *longhorn.Volume, err error) {
+// if nodeID is not specified, the volume will be detached from all nodes.
+// The force detachment revokes the attachment of a volume attached to a node
+// that is down, regardless of the migration.
+func (m *VolumeManager) Detach(ctx context.Context, name, nodeID string, force bool) (v *longhorn.Volume, err error) {
 	defer func() {
 		err = errors.Wrapf(err, "unable to detach volume %v", name)
 	}()
 
+	release, err := m.volumeOperations.acquire(ctx, name, VolumeOperationDetach)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	v, err = m.ds.GetVolume(name)
 	if err != nil {
 		return nil, err
@@ -324,6 +735,10 @@ func (m *VolumeManager) Detach(name, nodeID string) (v *longhorn.Volume, err err
 		return v, nil
 	}
 
+	if force {
+		return m.forceDetach(ctx, v)
+	}
+
 	isMigratingVolume := v.Spec.Migratable && v.Spec.MigrationNodeID != "" && v.Spec.NodeID != ""
 	isMigrationConfirmation := isMigratingVolume && nodeID == v.Spec.NodeID
 	isMigrationRollback := isMigratingVolume && nodeID == v.Spec.MigrationNodeID
@@ -337,6 +752,7 @@ func (m *VolumeManager) Detach(name, nodeID string) (v *longhorn.Volume, err err
 		}
 		v.Spec.NodeID = v.Spec.MigrationNodeID
 		v.Spec.MigrationNodeID = ""
+		v.Spec.AttachmentTicket.NodeID = v.Spec.NodeID
 		logrus.Infof("Volume %v migration from %v to %v confirmed", v.Name, nodeID, v.Spec.NodeID)
 	} else if isMigrationRollback {
 		v.Spec.MigrationNodeID = ""
@@ -344,10 +760,12 @@ func (m *VolumeManager) Detach(name, nodeID string) (v *longhorn.Volume, err err
 	} else {
 		v.Spec.NodeID = ""
 		v.Spec.MigrationNodeID = ""
+		v.Spec.AttachmentTicket = longhorn.AttachmentTicket{}
 		logrus.Infof("Volume %v detachment from node %v requested", v.Name, nodeID)
 	}
 
 	v.Spec.DisableFrontend = false
+	setLastRequestID(ctx, v)
 	v, err = m.ds.UpdateVolume(v)
 	if err != nil {
 		return nil, err
@@ -355,6 +773,103 @@ func (m *VolumeManager) Detach(name, nodeID string) (v *longhorn.Volume, err err
 	return v, nil
 }
 
+func (m *VolumeManager) forceDetach(ctx context.Context, v *longhorn.Volume) (*longhorn.Volume, error) {
+	for _, attachedNodeID := range []string{v.Spec.NodeID, v.Spec.MigrationNodeID} {
+		if attachedNodeID == "" {
+			continue
+		}
+		isDown, err := m.ds.IsNodeDownOrDeleted(attachedNodeID)
+		if err != nil {
+			return nil, err
+		}
+		if !isDown {
+			return nil, types.NewReasonError(types.ErrorReasonInvalidState, map[string]string{types.ErrorParameterNode: attachedNodeID},
+				"cannot force detach volume %v since node %v is not confirmed down", v.Name, attachedNodeID)
+		}
+	}
+
+	logrus.Warnf("Volume %v force detachment from node %v requested, revoking the attachment by %v",
+		v.Name, v.Spec.NodeID, v.Spec.AttachmentTicket.AttachedBy)
+	v.Spec.NodeID = ""
+	v.Spec.MigrationNodeID = ""
+	v.Spec.AttachmentTicket = longhorn.AttachmentTicket{}
+	v.Spec.DisableFrontend = false
+	setLastRequestID(ctx, v)
+	return m.ds.UpdateVolume(v)
+}
+
+// MigrationStart starts the live migration of the attached volume to the
+// node. The engine on the node is attached while the one on the current node
+// stays active, then the migration is either confirmed or rolled back.
+func (m *VolumeManager) MigrationStart(ctx context.Context, name, nodeID string) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to start migrating volume %v to node %v", name, nodeID)
+	}()
+
+	v, err = m.ds.GetVolumeRO(name)
+	if err != nil {
+		return nil, err
+	}
+	if !v.Spec.Migratable {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, nil,
+			"volume is not migratable")
+	}
+	if v.Spec.NodeID == "" {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, map[string]string{types.ErrorParameterState: string(v.Status.State)},
+			"volume must be attached to start a migration")
+	}
+	if nodeID == "" || nodeID == v.Spec.NodeID {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter, map[string]string{types.ErrorParameterNode: nodeID},
+			"migration node must be different from the current node %v", v.Spec.NodeID)
+	}
+
+	return m.Attach(ctx, name, nodeID, v.Spec.DisableFrontend, v.Spec.LastAttachedBy)
+}
+
+// MigrationConfirm switches the migrating volume to the migration node, and
+// detaches it from the previous node.
+func (m *VolumeManager) MigrationConfirm(ctx context.Context, name string) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to confirm the migration of volume %v", name)
+	}()
+
+	v, err = m.getMigratingVolume(name)
+	if err != nil {
+		return nil, err
+	}
+	if v.Status.MigrationState != longhorn.VolumeMigrationStateReady {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, map[string]string{types.ErrorParameterState: string(v.Status.MigrationState)},
+			"migration to node %v is not ready yet", v.Spec.MigrationNodeID)
+	}
+	return m.Detach(ctx, name, v.Spec.NodeID, false)
+}
+
+// MigrationRollback keeps the migrating volume on the current node, and
+// detaches it from the migration node.
+func (m *VolumeManager) MigrationRollback(ctx context.Context, name string) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to roll back the migration of volume %v", name)
+	}()
+
+	v, err = m.getMigratingVolume(name)
+	if err != nil {
+		return nil, err
+	}
+	return m.Detach(ctx, name, v.Spec.MigrationNodeID, false)
+}
+
+func (m *VolumeManager) getMigratingVolume(name string) (*longhorn.Volume, error) {
+	v, err := m.ds.GetVolumeRO(name)
+	if err != nil {
+		return nil, err
+	}
+	if !v.Spec.Migratable || v.Spec.NodeID == "" || v.Spec.MigrationNodeID == "" {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, nil,
+			"volume is not migrating")
+	}
+	return v, nil
+}
+
 func (m *VolumeManager) isVolumeAvailableOnNode(volume, node string) bool {
 	es, _ := m.ds.ListVolumeEngines(volume)
 	for _, e := range es {
@@ -380,7 +895,7 @@ func (m *VolumeManager) isVolumeAvailableOnNode(volume, node string) bool {
 	return false
 }
 
-func (m *VolumeManager) Salvage(volumeName string, replicaNames []string) (v *longhorn.Volume, err error) {
+func (m *VolumeManager) Salvage(ctx context.Context, volumeName string, replicaNames []string) (v *longhorn.Volume, err error) {
 	defer func() {
 		err = errors.Wrapf(err, "unable to salvage volume %v", volumeName)
 	}()
@@ -390,12 +905,33 @@ func (m *VolumeManager) Salvage(volumeName string, replicaNames []string) (v *lo
 		return nil, err
 	}
 	if v.Status.State != longhorn.VolumeStateDetached {
-		return nil, fmt.Errorf("invalid volume state to salvage: %v", v.Status.State)
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, map[string]string{types.ErrorParameterState: string(v.Status.State)},
+			"invalid volume state to salvage: %v", v.Status.State)
 	}
 	if v.Status.Robustness != longhorn.VolumeRobustnessFaulted {
 		return nil, fmt.Errorf("invalid robustness state to salvage: %v", v.Status.Robustness)
 	}
+
+	// Pick the replicas with the latest data the same way as the auto salvage
+	// if none is specified
+	if len(replicaNames) == 0 {
+		rs, err := m.ds.ListVolumeReplicas(v.Name)
+		if err != nil {
+			return nil, err
+		}
+		salvageableReplicas, _ := m.ds.GetSalvageableReplicas(rs, types.AutoSalvageTimeLimit)
+		if len(salvageableReplicas) == 0 {
+			return nil, types.NewReasonError(types.ErrorReasonInvalidState, nil, "no replica of volume %v can be salvaged", v.Name)
+		}
+		for name := range salvageableReplicas {
+			replicaNames = append(replicaNames, name)
+		}
+		logrus.Infof("Picked replicas %v with the latest data to salvage volume %v", replicaNames, v.Name)
+	}
+
 	v.Spec.NodeID = ""
+	v.Spec.AttachmentTicket = longhorn.AttachmentTicket{}
+	setLastRequestID(ctx, v)
 	v, err = m.ds.UpdateVolume(v)
 	if err != nil {
 		return nil, err
@@ -446,7 +982,7 @@ func (m *VolumeManager) Salvage(volumeName string, replicaNames []string) (v *lo
 	return v, nil
 }
 
-func (m *VolumeManager) Activate(volumeName string, frontend string) (v *longhorn.Volume, err error) {
+func (m *VolumeManager) Activate(ctx context.Context, volumeName string, frontend string) (v *longhorn.Volume, err error) {
 	defer func() {
 		err = errors.Wrapf(err, "unable to activate volume %v", volumeName)
 	}()
@@ -464,7 +1000,8 @@ func (m *VolumeManager) Activate(volumeName string, frontend string) (v *longhor
 	}
 
 	if frontend != string(longhorn.VolumeFrontendBlockDev) && frontend != string(longhorn.VolumeFrontendISCSI) {
-		return nil, fmt.Errorf("invalid frontend %v", frontend)
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter, map[string]string{types.ErrorParameterParameter: "frontend", types.ErrorParameterValue: frontend},
+			"invalid frontend %v", frontend)
 	}
 
 	v, err = m.ds.GetVolume(volumeName)
@@ -480,6 +1017,7 @@ func (m *VolumeManager) Activate(volumeName string, frontend string) (v *longhor
 
 	v.Spec.Frontend = longhorn.VolumeFrontend(frontend)
 	v.Spec.Standby = false
+	setLastRequestID(ctx, v)
 	v, err = m.ds.UpdateVolume(v)
 	if err != nil {
 		return nil, err
@@ -499,7 +1037,7 @@ func (m *VolumeManager) triggerBackupVolumeToSync(volume *longhorn.Volume) error
 	if err != nil {
 		return errors.Wrapf(err, "failed to get backup volume: %v", backupVolumeName)
 	}
-	requestSyncTime := metav1.Time{Time: time.Now().UTC()}
+	requestSyncTime := metav1.Time{Time: m.clock.Now().UTC()}
 	backupVolume.Spec.SyncRequestedAt = requestSyncTime
 	if _, err = m.ds.UpdateBackupVolume(backupVolume); err != nil {
 		return errors.Wrapf(err, "failed to update backup volume: %v", backupVolumeName)
@@ -508,18 +1046,33 @@ func (m *VolumeManager) triggerBackupVolumeToSync(volume *longhorn.Volume) error
 	return nil
 }
 
-func (m *VolumeManager) Expand(volumeName string, size int64) (v *longhorn.Volume, err error) {
+func (m *VolumeManager) Expand(ctx context.Context, volumeName string, size int64) (v *longhorn.Volume, err error) {
 	defer func() {
 		err = errors.Wrapf(err, "unable to expand volume %v", volumeName)
 	}()
 
+	review, err := m.reviewVolumeOperation(&VolumePolicyReview{
+		Operation: VolumePolicyOperationExpand,
+		Volume:    volumeName,
+		Parameters: map[string]string{
+			VolumePolicyParameterSize: strconv.FormatInt(size, 10),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if size, err = strconv.ParseInt(review.Parameters[VolumePolicyParameterSize], 10, 64); err != nil {
+		return nil, errors.Wrapf(err, "invalid %v parameter from volume policy", VolumePolicyParameterSize)
+	}
+
 	v, err = m.ds.GetVolume(volumeName)
 	if err != nil {
 		return nil, err
 	}
 
 	if v.Status.State != longhorn.VolumeStateDetached && v.Status.State != longhorn.VolumeStateAttached {
-		return nil, fmt.Errorf("invalid volume state to expand: %v", v.Status.State)
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, map[string]string{types.ErrorParameterState: string(v.Status.State)},
+			"invalid volume state to expand: %v", v.Status.State)
 	}
 
 	if types.GetCondition(v.Status.Conditions, longhorn.VolumeConditionTypeScheduled).Status != longhorn.ConditionStatusTrue {
@@ -527,6 +1080,11 @@ func (m *VolumeManager) Expand(volumeName string, size int64) (v *longhorn.Volum
 	}
 
 	size = util.RoundUpSize(size)
+	if size > v.Spec.Size {
+		if err := m.ds.CheckTenantQuota(GetVolumeTenant(v), v.Name, size); err != nil {
+			return nil, err
+		}
+	}
 
 	kubernetesStatus := &v.Status.KubernetesStatus
 	if kubernetesStatus.PVCName != "" && kubernetesStatus.LastPVCRefAt == "" {
@@ -554,6 +1112,7 @@ func (m *VolumeManager) Expand(volumeName string, size int64) (v *longhorn.Volum
 
 	previousSize := v.Spec.Size
 	v.Spec.Size = size
+	setLastRequestID(ctx, v)
 
 	v, err = m.ds.UpdateVolume(v)
 	if err != nil {
@@ -683,6 +1242,133 @@ func (m *VolumeManager) TrimFilesystem(name string) (v *longhorn.Volume, err err
 	return v, util.TrimFilesystem(name, v.Spec.Encrypted)
 }
 
+const (
+	DefaultVolumeFreezeTimeout = 60 * time.Second
+	MaxVolumeFreezeTimeout     = 10 * time.Minute
+)
+
+// Freeze freezes the filesystem of the volume for an external tool to take a
+// consistent snapshot, e.g. a VM snapshot. The volume controller thaws it
+// once the timeout is reached, in case the caller never thaws it.
+func (m *VolumeManager) Freeze(name string, timeout time.Duration) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to freeze filesystem for volume %v", name)
+	}()
+
+	if timeout == 0 {
+		timeout = DefaultVolumeFreezeTimeout
+	}
+	if timeout < 0 || timeout > MaxVolumeFreezeTimeout {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "timeout", types.ErrorParameterValue: timeout.String()},
+			"freeze timeout must be between 0 and %v", MaxVolumeFreezeTimeout)
+	}
+
+	v, err = m.getVolumeToFreeze(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the deadline first, so the filesystem is thawed even if the
+	// manager crashes right after freezing it. Freezing a frozen filesystem
+	// extends the deadline, but never brings it forward, so the filesystem
+	// isn't thawed under another caller still relying on it.
+	frozenUntil := m.clock.Now().Add(timeout).UTC()
+	if v.Status.FrozenUntil != "" {
+		if current, err := util.ParseTime(v.Status.FrozenUntil); err == nil && current.After(frozenUntil) {
+			frozenUntil = current
+		}
+	}
+	v.Status.FrozenUntil = frozenUntil.Format(time.RFC3339)
+	if v, err = m.ds.UpdateVolumeStatus(v); err != nil {
+		return nil, err
+	}
+	// The deadline is kept on failure, since the filesystem may be frozen,
+	// e.g. by an earlier request. Thawing a filesystem not frozen at the
+	// deadline is harmless.
+	if err := m.freezer.Freeze(v.Name, v.Spec.Encrypted); err != nil {
+		return nil, err
+	}
+	logrus.Infof("Froze filesystem of volume %v until %v", v.Name, v.Status.FrozenUntil)
+	return v, nil
+}
+
+func (m *VolumeManager) Thaw(name string) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to thaw filesystem for volume %v", name)
+	}()
+
+	v, err = m.getVolumeToFreeze(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.freezer.Thaw(v.Name, v.Spec.Encrypted); err != nil {
+		return nil, err
+	}
+	if v.Status.FrozenUntil != "" {
+		v.Status.FrozenUntil = ""
+		if v, err = m.ds.UpdateVolumeStatus(v); err != nil {
+			return nil, err
+		}
+	}
+	logrus.Infof("Thawed filesystem of volume %v", v.Name)
+	return v, nil
+}
+
+// getVolumeToFreeze gets the volume attached to the current node, since the
+// filesystem can only be reached from there.
+func (m *VolumeManager) getVolumeToFreeze(name string) (*longhorn.Volume, error) {
+	v, err := m.ds.GetVolume(name)
+	if err != nil {
+		return nil, err
+	}
+	if v.Status.State != longhorn.VolumeStateAttached {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, map[string]string{types.ErrorParameterState: string(v.Status.State)},
+			"volume is not attached")
+	}
+	if v.Status.FrontendDisabled {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, nil, "volume frontend is disabled")
+	}
+	if v.Status.CurrentNodeID != m.currentNodeID {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, map[string]string{types.ErrorParameterNode: v.Status.CurrentNodeID},
+			"volume is attached to node %v rather than the current node %v", v.Status.CurrentNodeID, m.currentNodeID)
+	}
+	return v, nil
+}
+
+// CheckFilesystem requests a full check of the filesystem of the detached
+// volume. The volume is attached to the current node, then the volume
+// controller repairs the errors found and detaches the volume. The result is
+// recorded by the filesystemerror condition of the volume.
+func (m *VolumeManager) CheckFilesystem(ctx context.Context, name string) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to request filesystem check for volume %v", name)
+	}()
+
+	v, err = m.ds.GetVolumeRO(name)
+	if err != nil {
+		return nil, err
+	}
+	if v.Spec.FilesystemType == "" {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, nil,
+			"volume has no filesystem type recorded")
+	}
+	if v.Spec.Encrypted {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, nil,
+			"checking the filesystem of an encrypted volume is not supported")
+	}
+	if v.Spec.ReadOnly {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, nil,
+			"volume is read-only, so the filesystem errors cannot be repaired")
+	}
+	if v.Spec.NodeID != "" || v.Status.State != longhorn.VolumeStateDetached {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, map[string]string{types.ErrorParameterState: string(v.Status.State)},
+			"volume must be detached for a full filesystem check")
+	}
+
+	return m.Attach(ctx, name, m.currentNodeID, false, types.FilesystemCheckAttachedBy)
+}
+
 func (m *VolumeManager) AddVolumeRecurringJob(volumeName string, name string, isGroup bool) (volumeRecurringJob map[string]*longhorn.VolumeRecurringJob, err error) {
 	defer func() {
 		err = errors.Wrapf(err, "failed to add volume recurring jobs for %v", volumeName)
@@ -767,6 +1453,75 @@ func (m *VolumeManager) DeleteReplica(volumeName, replicaName string) error {
 	return nil
 }
 
+// EvictReplica requests to move the replica away. Unlike DeleteReplica, the
+// replica is only removed by the volume controller once another one is
+// rebuilt, so the volume keeps its redundancy.
+func (m *VolumeManager) EvictReplica(volumeName, replicaName string) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to evict replica %v of volume %v", replicaName, volumeName)
+	}()
+
+	v, err := m.ds.GetVolumeRO(volumeName)
+	if err != nil {
+		return err
+	}
+	if v.Status.State != longhorn.VolumeStateAttached {
+		return types.NewReasonError(types.ErrorReasonInvalidState,
+			map[string]string{types.ErrorParameterName: volumeName, types.ErrorParameterState: string(v.Status.State)},
+			"volume must be attached to rebuild the replica elsewhere")
+	}
+
+	r, err := m.ds.GetReplica(replicaName)
+	if err != nil {
+		return err
+	}
+	if r.Spec.VolumeName != volumeName {
+		return types.NewReasonError(types.ErrorReasonNotFound,
+			map[string]string{types.ErrorParameterName: replicaName, types.ErrorParameterKind: types.LonghornKindReplica},
+			"cannot find replica %v of volume %v", replicaName, volumeName)
+	}
+	if r.Spec.EvictionRequested {
+		return nil
+	}
+	if r.Spec.NodeID == "" {
+		return types.NewReasonError(types.ErrorReasonInvalidState,
+			map[string]string{types.ErrorParameterName: replicaName},
+			"replica is not scheduled yet")
+	}
+
+	r.Spec.EvictionRequested = true
+	if _, err := m.ds.UpdateReplica(r); err != nil {
+		return err
+	}
+	logrus.Infof("Requested eviction of replica %v of volume %v", replicaName, volumeName)
+	return nil
+}
+
+// ScheduleExplain returns the trace of the last scheduling of the replica if
+// it's scheduled by the volume controller of this node. Otherwise, e.g. the
+// manager restarted, it explains where the replica would be scheduled now.
+func (m *VolumeManager) ScheduleExplain(volumeName, replicaName string) (*scheduler.ScheduleTrace, error) {
+	v, err := m.ds.GetVolumeRO(volumeName)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := m.ds.ListVolumeReplicas(volumeName)
+	if err != nil {
+		return nil, err
+	}
+	r, exists := rs[replicaName]
+	if !exists {
+		return nil, types.NewReasonError(types.ErrorReasonNotFound,
+			map[string]string{types.ErrorParameterKind: "replica", types.ErrorParameterName: replicaName},
+			"cannot find replica %v of volume %v", replicaName, volumeName)
+	}
+
+	if trace := scheduler.GetScheduleTrace(replicaName); trace != nil {
+		return trace, nil
+	}
+	return m.scheduler.ScheduleExplain(r, rs, v)
+}
+
 func (m *VolumeManager) GetManagerNodeIPMap() (map[string]string, error) {
 	podList, err := m.ds.ListManagerPods()
 	if err != nil {
@@ -783,11 +1538,15 @@ func (m *VolumeManager) GetManagerNodeIPMap() (map[string]string, error) {
 	return nodeIPMap, nil
 }
 
-func (m *VolumeManager) EngineUpgrade(volumeName, image string) (v *longhorn.Volume, err error) {
+func (m *VolumeManager) EngineUpgrade(ctx context.Context, volumeName, image string) (v *longhorn.Volume, err error) {
 	defer func() {
 		err = errors.Wrapf(err, "cannot upgrade engine for volume %v using image %v", volumeName, image)
 	}()
 
+	if image, err = m.ds.ResolveImage(image); err != nil {
+		return nil, err
+	}
+
 	// Only allow to upgrade to the default engine image if the setting `Automatically upgrade volumes' engine to the default engine image` is enabled
 	concurrentAutomaticEngineUpgradePerNodeLimit, err := m.ds.GetSettingAsInt(types.SettingNameConcurrentAutomaticEngineUpgradePerNodeLimit)
 	if err != nil {
@@ -847,6 +1606,7 @@ func (m *VolumeManager) EngineUpgrade(volumeName, image string) (v *longhorn.Vol
 
 	oldImage := v.Spec.EngineImage
 	v.Spec.EngineImage = image
+	setLastRequestID(ctx, v)
 
 	v, err = m.ds.UpdateVolume(v)
 	if err != nil {
@@ -866,13 +1626,25 @@ func (m *VolumeManager) UpdateReplicaCount(name string, count int) (v *longhorn.
 		err = errors.Wrapf(err, "unable to update replica count for volume %v", name)
 	}()
 
+	if err := types.ValidateReplicaCount(count); err != nil {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "replicaCount", types.ErrorParameterValue: strconv.Itoa(count)},
+			"%v", err)
+	}
+
 	v, err = m.ds.GetVolume(name)
 	if err != nil {
 		return nil, err
 	}
 
 	if v.Spec.NodeID == "" || v.Status.State != longhorn.VolumeStateAttached {
-		return nil, fmt.Errorf("invalid volume state to update replica count%v", v.Status.State)
+		return nil, types.NewReasonError(types.ErrorReasonInvalidState, map[string]string{types.ErrorParameterState: string(v.Status.State)},
+			"invalid volume state to update replica count %v", v.Status.State)
+	}
+	if err := types.ValidateDataLocalityAndReplicaCount(v.Spec.DataLocality, count); err != nil {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "replicaCount", types.ErrorParameterValue: strconv.Itoa(count)},
+			"%v", err)
 	}
 	if v.Spec.EngineImage != v.Status.CurrentImage {
 		return nil, fmt.Errorf("upgrading in process, cannot update replica count")
@@ -934,6 +1706,178 @@ func (m *VolumeManager) UpdateBackupCompressionMethod(name string, value string)
 	return v, nil
 }
 
+func (m *VolumeManager) UpdateExpireAt(name string, expireAt string) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to update expiration time for volume %v", name)
+	}()
+
+	v, err = m.ds.GetVolume(name)
+	if err != nil {
+		return nil, err
+	}
+
+	oldExpireAt := v.Spec.ExpireAt
+	v.Spec.ExpireAt = expireAt
+
+	v, err = m.ds.UpdateVolume(v)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debugf("Updated volume %v expiration time from %v to %v", v.Name, oldExpireAt, v.Spec.ExpireAt)
+	return v, nil
+}
+
+// UpdateSnapshotLimits updates the max count and the max age of the snapshots
+// of the volume. The system snapshots beyond them are cleaned up by the
+// snapshot cleanup controller.
+func (m *VolumeManager) UpdateSnapshotLimits(name string, maxCount int, maxAge string) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to update snapshot limits for volume %v", name)
+	}()
+
+	v, err = m.ds.GetVolume(name)
+	if err != nil {
+		return nil, err
+	}
+
+	v.Spec.SnapshotMaxCount = maxCount
+	v.Spec.SnapshotMaxAge = maxAge
+
+	v, err = m.ds.UpdateVolume(v)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debugf("Updated volume %v snapshot max count to %v and max age to %v", v.Name, v.Spec.SnapshotMaxCount, v.Spec.SnapshotMaxAge)
+	return v, nil
+}
+
+// UpdateQoS updates the limits of the volume. They're applied when the
+// engine starts next time.
+func (m *VolumeManager) UpdateQoS(name string, rebuildBandwidthLimit, frontendIOPSLimit, frontendBandwidthLimit int64) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to update QoS for volume %v", name)
+	}()
+
+	v, err = m.ds.GetVolume(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The engine without the support would silently run without the limits
+	if rebuildBandwidthLimit > 0 || frontendIOPSLimit > 0 || frontendBandwidthLimit > 0 {
+		if err := m.checkEngineImageFeature(v.Spec.EngineImage, engineapi.EngineFeatureQoS); err != nil {
+			return nil, err
+		}
+	}
+
+	v.Spec.RebuildBandwidthLimit = rebuildBandwidthLimit
+	v.Spec.FrontendIOPSLimit = frontendIOPSLimit
+	v.Spec.FrontendBandwidthLimit = frontendBandwidthLimit
+
+	v, err = m.ds.UpdateVolume(v)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debugf("Updated volume %v QoS to rebuild bandwidth limit %v, frontend IOPS limit %v and frontend bandwidth limit %v",
+		v.Name, v.Spec.RebuildBandwidthLimit, v.Spec.FrontendIOPSLimit, v.Spec.FrontendBandwidthLimit)
+	return v, nil
+}
+
+// SetReadOnly sets the read-only mode of the volume frontend. It takes effect
+// when the engine starts, so an attached volume keeps its current mode until
+// it's attached again.
+func (m *VolumeManager) SetReadOnly(name string, readOnly bool) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to set read-only mode %v for volume %v", readOnly, name)
+	}()
+
+	v, err = m.ds.GetVolume(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.Spec.ReadOnly == readOnly {
+		logrus.Debugf("Volume %v already has read-only mode %v", v.Name, readOnly)
+		return v, nil
+	}
+	if readOnly {
+		// The share manager and the restoration write to the volume
+		if v.Spec.AccessMode == longhorn.AccessModeReadWriteMany && !v.Spec.Migratable {
+			return nil, types.NewReasonError(types.ErrorReasonInvalidState, nil,
+				"read-only mode is not supported for shared volumes")
+		}
+		if v.Spec.Standby || v.Status.RestoreRequired {
+			return nil, types.NewReasonError(types.ErrorReasonInvalidState, nil,
+				"read-only mode is not supported for restoring volumes")
+		}
+		// Otherwise the engine fails to start on the next attachment
+		if err := m.checkEngineImageFeature(v.Spec.EngineImage, engineapi.EngineFeatureFrontendReadOnly); err != nil {
+			return nil, err
+		}
+	}
+
+	v.Spec.ReadOnly = readOnly
+	v, err = m.ds.UpdateVolume(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.Spec.NodeID != "" {
+		logrus.Infof("Updated volume %v read-only mode to %v, which takes effect the next time it's attached", v.Name, readOnly)
+	} else {
+		logrus.Infof("Updated volume %v read-only mode to %v", v.Name, readOnly)
+	}
+	return v, nil
+}
+
+// checkEngineImageFeature rejects the change of the volume if the engine
+// image doesn't support the feature.
+func (m *VolumeManager) checkEngineImageFeature(engineImage string, feature engineapi.EngineFeature) error {
+	cliAPIVersion, err := m.ds.GetEngineImageCLIAPIVersion(engineImage)
+	if err != nil {
+		return err
+	}
+	if err := engineapi.CheckCLIFeatureSupport(feature, cliAPIVersion); err != nil {
+		return types.NewReasonError(types.ErrorReasonInvalidState, nil, "%v", err)
+	}
+	return nil
+}
+
+// UpdateStaleReplicaTimeout updates how long, in minutes, a failed replica of
+// the volume is kept before the volume controller cleans it up. 0 keeps the
+// failed replicas until they're removed manually.
+func (m *VolumeManager) UpdateStaleReplicaTimeout(name string, timeout int) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to update stale replica timeout for volume %v", name)
+	}()
+
+	if timeout < 0 {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "staleReplicaTimeout", types.ErrorParameterValue: strconv.Itoa(timeout)},
+			"invalid stale replica timeout %v", timeout)
+	}
+
+	v, err = m.ds.GetVolume(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.Spec.StaleReplicaTimeout == timeout {
+		logrus.Debugf("Volume %v already has stale replica timeout %v", v.Name, timeout)
+		return v, nil
+	}
+
+	oldTimeout := v.Spec.StaleReplicaTimeout
+	v.Spec.StaleReplicaTimeout = timeout
+	v, err = m.ds.UpdateVolume(v)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Updated volume %v stale replica timeout from %v to %v", v.Name, oldTimeout, v.Spec.StaleReplicaTimeout)
+	return v, nil
+}
+
 func (m *VolumeManager) UpdateReplicaAutoBalance(name string, inputSpec longhorn.ReplicaAutoBalance) (v *longhorn.Volume, err error) {
 	defer func() {
 		err = errors.Wrapf(err, "unable to update replica auto-balance for volume %v", name)
@@ -1042,7 +1986,7 @@ func (m *VolumeManager) UpdateUnmapMarkSnapChainRemoved(name string, unmapMarkSn
 	return v, nil
 }
 
-func (m *VolumeManager) verifyDataSourceForVolumeCreation(dataSource longhorn.VolumeDataSource, requestSize int64) (err error) {
+func (m *VolumeManager) verifyDataSourceForVolumeCreation(ctx context.Context, dataSource longhorn.VolumeDataSource, requestSize int64) (err error) {
 	defer func() {
 		err = errors.Wrapf(err, "failed to verify data source")
 	}()
@@ -1062,7 +2006,7 @@ func (m *VolumeManager) verifyDataSourceForVolumeCreation(dataSource longhorn.Vo
 		}
 
 		if snapName := types.GetSnapshotName(dataSource); snapName != "" {
-			if _, err := m.GetSnapshotInfo(snapName, srcVolName); err != nil {
+			if _, err := m.GetSnapshotInfo(ctx, snapName, srcVolName); err != nil {
 				return err
 			}
 		}
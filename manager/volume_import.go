@@ -0,0 +1,251 @@
+package manager
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+// Import creates a volume from the replica data already on the node disks,
+// e.g. copied from another cluster or left by a dead one. The data
+// directories are detected by the node monitor as orphans, which carry the
+// volume metadata of the data. The data must be consistent: not in the
+// middle of a rebuilding, without error, and of the same size and head
+// across the orphans. The replicas are created on the orphaned data before
+// the volume, so the volume starts with the imported replicas instead of
+// empty ones. Hence the volume is validated before the replicas adopt the
+// data, and the replicas of a failed import are deactivated before they're
+// deleted, which leaves the data on the disks. The size of the volume is
+// taken from the metadata.
+func (m *VolumeManager) Import(ctx context.Context, name string, orphanNames []string, spec *longhorn.VolumeSpec) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to import volume %v", name)
+	}()
+
+	// Correct the name the same way as the volume creation does
+	name = util.AutoCorrectName(name, datastore.NameMaximumLength)
+	if err := validateVolumeName(name); err != nil {
+		return nil, err
+	}
+	if _, err := m.ds.GetVolumeRO(name); err == nil {
+		return nil, types.NewReasonError(types.ErrorReasonAlreadyExists,
+			map[string]string{types.ErrorParameterKind: "volume", types.ErrorParameterName: name},
+			"volume %v already exists", name)
+	} else if !datastore.ErrorIsNotFound(err) {
+		return nil, err
+	}
+	if len(orphanNames) == 0 {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "orphans", types.ErrorParameterValue: ""},
+			"no orphaned replica data to import")
+	}
+
+	orphans, size, err := m.getImportOrphans(orphanNames)
+	if err != nil {
+		return nil, err
+	}
+
+	engineImage, err := m.ds.GetSettingValueExisted(types.SettingNameDefaultEngineImage)
+	if err != nil {
+		return nil, err
+	}
+
+	spec.Size = size
+	if spec.NumberOfReplicas == 0 {
+		spec.NumberOfReplicas = len(orphans)
+	}
+	spec, err = m.validateVolumeCreation(ctx, name, spec, nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := []*longhorn.Replica{}
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, r := range replicas {
+			if err := m.deleteImportedReplica(r.Name); err != nil {
+				logrus.WithError(err).Warnf("Failed to clean up replica %v of the failed import of volume %v", r.Name, name)
+			}
+		}
+	}()
+	now := m.now()
+	for _, orphan := range orphans {
+		r := &longhorn.Replica{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: types.GenerateReplicaNameForVolume(name),
+			},
+			Spec: longhorn.ReplicaSpec{
+				InstanceSpec: longhorn.InstanceSpec{
+					VolumeName:  name,
+					VolumeSize:  size,
+					NodeID:      orphan.Spec.NodeID,
+					EngineImage: engineImage,
+					DesireState: longhorn.InstanceStateStopped,
+				},
+				HealthyAt:               now,
+				DiskID:                  orphan.Spec.Parameters[longhorn.OrphanDiskUUID],
+				DiskPath:                orphan.Spec.Parameters[longhorn.OrphanDiskPath],
+				DataDirectoryName:       orphan.Spec.Parameters[longhorn.OrphanDataName],
+				Active:                  true,
+				RevisionCounterDisabled: spec.RevisionCounterDisabled,
+			},
+		}
+		r, err = m.ds.CreateReplica(r)
+		if err != nil {
+			return nil, err
+		}
+		replicas = append(replicas, r)
+	}
+
+	v, err = m.Create(ctx, name, spec, nil, nil, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	// The replicas are created before the volume, so they're owned by the
+	// volume afterwards. They're deleted with the volume regardless.
+	for _, r := range replicas {
+		if err := m.setReplicaOwnerReferences(r.Name, v); err != nil {
+			logrus.WithError(err).Warnf("Failed to set owner references of replica %v of imported volume %v", r.Name, name)
+		}
+	}
+
+	logrus.Infof("Imported volume %v from orphans %v", name, orphanNames)
+	return v, nil
+}
+
+// getImportOrphans validates the orphans to import and returns them with the
+// size of the volume.
+func (m *VolumeManager) getImportOrphans(orphanNames []string) ([]*longhorn.Orphan, int64, error) {
+	orphans := []*longhorn.Orphan{}
+	size := int64(0)
+	head := ""
+	for _, orphanName := range orphanNames {
+		orphan, err := m.ds.GetOrphanRO(orphanName)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := m.validateImportOrphan(orphan); err != nil {
+			return nil, 0, types.NewReasonError(types.ErrorReasonInvalidParameter,
+				map[string]string{types.ErrorParameterParameter: "orphans", types.ErrorParameterValue: orphanName},
+				"cannot import orphan %v: %v", orphanName, err)
+		}
+
+		orphanSize, err := strconv.ParseInt(orphan.Spec.Parameters[longhorn.OrphanVolumeSize], 10, 64)
+		if err != nil {
+			return nil, 0, err
+		}
+		orphanHead := orphan.Spec.Parameters[longhorn.OrphanVolumeHead]
+		if len(orphans) == 0 {
+			size, head = orphanSize, orphanHead
+		} else if orphanSize != size || orphanHead != head {
+			return nil, 0, types.NewReasonError(types.ErrorReasonInvalidParameter,
+				map[string]string{types.ErrorParameterParameter: "orphans", types.ErrorParameterValue: orphanName},
+				"orphan %v with size %v and head %v doesn't match orphan %v with size %v and head %v",
+				orphanName, orphanSize, orphanHead, orphans[0].Name, size, head)
+		}
+		for _, o := range orphans {
+			if o.Name == orphan.Name {
+				return nil, 0, types.NewReasonError(types.ErrorReasonInvalidParameter,
+					map[string]string{types.ErrorParameterParameter: "orphans", types.ErrorParameterValue: orphanName},
+					"orphan %v is specified more than once", orphanName)
+			}
+		}
+		orphans = append(orphans, orphan)
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].Name < orphans[j].Name
+	})
+	return orphans, size, nil
+}
+
+func (m *VolumeManager) validateImportOrphan(orphan *longhorn.Orphan) error {
+	if orphan.Spec.Type != longhorn.OrphanTypeReplica {
+		return errors.Errorf("orphan type %v is not %v", orphan.Spec.Type, longhorn.OrphanTypeReplica)
+	}
+	if orphan.DeletionTimestamp != nil {
+		return errors.New("orphan is being deleted")
+	}
+
+	params := orphan.Spec.Parameters
+	if _, ok := params[longhorn.OrphanVolumeSize]; !ok {
+		return errors.New("volume metadata of the data is unknown")
+	}
+	if rebuilding, _ := strconv.ParseBool(params[longhorn.OrphanVolumeRebuilding]); rebuilding {
+		return errors.New("data is in the middle of a rebuilding")
+	}
+	if params[longhorn.OrphanVolumeError] != "" {
+		return errors.Errorf("data has error %v", params[longhorn.OrphanVolumeError])
+	}
+
+	node, err := m.ds.GetNodeRO(orphan.Spec.NodeID)
+	if err != nil {
+		return err
+	}
+	diskName := params[longhorn.OrphanDiskName]
+	diskSpec, ok := node.Spec.Disks[diskName]
+	if !ok || diskSpec.Path != params[longhorn.OrphanDiskPath] ||
+		node.Status.DiskStatus[diskName] == nil || node.Status.DiskStatus[diskName].DiskUUID != params[longhorn.OrphanDiskUUID] {
+		return errors.Errorf("disk %v of the data is changed on node %v", diskName, node.Name)
+	}
+
+	replicas, err := m.ds.ListReplicasByDiskUUID(params[longhorn.OrphanDiskUUID])
+	if err != nil {
+		return err
+	}
+	for _, r := range replicas {
+		if r.Spec.DiskPath == params[longhorn.OrphanDiskPath] && r.Spec.DataDirectoryName == params[longhorn.OrphanDataName] {
+			return errors.Errorf("data is used by replica %v", r.Name)
+		}
+	}
+	return nil
+}
+
+// deleteImportedReplica deletes the replica of a failed import. The replica is
+// deactivated first, so the replica controller doesn't remove the adopted
+// data, and the data can be imported again.
+func (m *VolumeManager) deleteImportedReplica(replicaName string) error {
+	_, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		r, err := m.ds.GetReplica(replicaName)
+		if err != nil {
+			return nil, err
+		}
+		r.Spec.Active = false
+		return m.ds.UpdateReplica(r)
+	})
+	if err != nil {
+		if datastore.ErrorIsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if err := m.ds.DeleteReplica(replicaName); err != nil && !datastore.ErrorIsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (m *VolumeManager) setReplicaOwnerReferences(replicaName string, v *longhorn.Volume) error {
+	_, err := util.RetryOnConflictCause(func() (interface{}, error) {
+		r, err := m.ds.GetReplica(replicaName)
+		if err != nil {
+			return nil, err
+		}
+		r.OwnerReferences = datastore.GetOwnerReferencesForVolume(v)
+		return m.ds.UpdateReplica(r)
+	})
+	return err
+}
@@ -0,0 +1,193 @@
+package manager_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+const (
+	testImportDiskName = "disk-1"
+	testImportDiskUUID = "disk-uuid-1"
+	testImportDiskPath = "/var/lib/longhorn"
+)
+
+func newImportOrphan(name, dataName string, size int64, rebuilding bool) *longhorn.Orphan {
+	return &longhorn.Orphan{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec: longhorn.OrphanSpec{
+			NodeID: testNode1,
+			Type:   longhorn.OrphanTypeReplica,
+			Parameters: map[string]string{
+				longhorn.OrphanDataName:         dataName,
+				longhorn.OrphanDiskName:         testImportDiskName,
+				longhorn.OrphanDiskUUID:         testImportDiskUUID,
+				longhorn.OrphanDiskPath:         testImportDiskPath,
+				longhorn.OrphanVolumeSize:       fmt.Sprint(size),
+				longhorn.OrphanVolumeHead:       "volume-head-001.img",
+				longhorn.OrphanVolumeRebuilding: fmt.Sprint(rebuilding),
+			},
+		},
+	}
+}
+
+// newImportObjects returns the node with the disk of the orphaned data, and
+// the default engine image setting.
+func newImportObjects(diskMaximum int64, orphans ...*longhorn.Orphan) []runtime.Object {
+	node := newReadyNode(testNode1)
+	node.Spec.AllowScheduling = true
+	node.Status.Conditions = append(node.Status.Conditions, longhorn.Condition{
+		Type:   longhorn.NodeConditionTypeSchedulable,
+		Status: longhorn.ConditionStatusTrue,
+	})
+	node.Spec.Disks = map[string]longhorn.DiskSpec{
+		testImportDiskName: {Path: testImportDiskPath, AllowScheduling: true},
+	}
+	node.Status.DiskStatus = map[string]*longhorn.DiskStatus{
+		testImportDiskName: {DiskUUID: testImportDiskUUID, StorageMaximum: diskMaximum, StorageAvailable: diskMaximum},
+	}
+	objects := []runtime.Object{
+		node,
+		&longhorn.Setting{
+			ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameDefaultEngineImage), Namespace: testNamespace},
+			Value:      testEngineImage,
+		},
+	}
+	for _, orphan := range orphans {
+		objects = append(objects, orphan)
+	}
+	return objects
+}
+
+func TestImportVolume(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	c, err := fake.NewCluster(testNamespace, stopCh, newImportObjects(4*testVolumeSize,
+		newImportOrphan("orphan-1", "vol-1-abc", testVolumeSize, false),
+		newImportOrphan("orphan-2", "vol-2-def", testVolumeSize, false))...)
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+	m.SetClock(&fakeClock{now: time.Date(2023, 1, 2, 15, 0, 0, 0, time.UTC)})
+
+	v, err := m.Import(context.Background(), testVolumeName, []string{"orphan-1", "orphan-2"}, &longhorn.VolumeSpec{})
+	assert.NoError(err)
+	assert.Equal(int64(testVolumeSize), v.Spec.Size)
+	assert.Equal(2, v.Spec.NumberOfReplicas)
+
+	replicas, err := c.DataStore.ListReplicasRO()
+	assert.NoError(err)
+	assert.Len(replicas, 2)
+	dataNames := map[string]bool{}
+	for _, r := range replicas {
+		assert.Equal(testVolumeName, r.Spec.VolumeName)
+		assert.True(r.Spec.Active)
+		assert.Equal(testImportDiskPath, r.Spec.DiskPath)
+		assert.Equal("2023-01-02T15:00:00Z", r.Spec.HealthyAt)
+		dataNames[r.Spec.DataDirectoryName] = true
+	}
+	assert.Equal(map[string]bool{"vol-1-abc": true, "vol-2-def": true}, dataNames)
+
+	// The data is used by the replicas of the volume now
+	_, err = m.Import(context.Background(), "other", []string{"orphan-1"}, &longhorn.VolumeSpec{})
+	assert.Equal(types.ErrorReasonInvalidParameter, types.GetReasonError(err).Reason, "unexpected error %v", err)
+}
+
+func TestImportVolumeInvalid(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	testCases := map[string]struct {
+		diskMaximum    int64
+		orphans        []*longhorn.Orphan
+		expectedReason types.ErrorReason
+	}{
+		"rebuilding": {
+			4 * testVolumeSize,
+			[]*longhorn.Orphan{newImportOrphan("orphan-1", "vol-1-abc", testVolumeSize, true)},
+			types.ErrorReasonInvalidParameter,
+		},
+		"size mismatch": {
+			4 * testVolumeSize,
+			[]*longhorn.Orphan{
+				newImportOrphan("orphan-1", "vol-1-abc", testVolumeSize, false),
+				newImportOrphan("orphan-2", "vol-2-def", 2*testVolumeSize, false),
+			},
+			types.ErrorReasonInvalidParameter,
+		},
+		// The volume is validated before the replicas adopt the data
+		"too large for the disks": {
+			testVolumeSize / 4,
+			[]*longhorn.Orphan{newImportOrphan("orphan-1", "vol-1-abc", testVolumeSize, false)},
+			types.ErrorReasonInsufficientStorage,
+		},
+	}
+	for name, tc := range testCases {
+		c, err := fake.NewCluster(testNamespace, stopCh, newImportObjects(tc.diskMaximum, tc.orphans...)...)
+		assert.NoError(err, name)
+		m := c.NewVolumeManager(testNode1)
+
+		orphanNames := []string{}
+		for _, orphan := range tc.orphans {
+			orphanNames = append(orphanNames, orphan.Name)
+		}
+		_, err = m.Import(context.Background(), testVolumeName, orphanNames, &longhorn.VolumeSpec{})
+		assert.Error(err, name)
+		assert.Equal(tc.expectedReason, types.GetReasonError(err).Reason, "%v: unexpected error %v", name, err)
+
+		replicas, err := c.LonghornClient.LonghornV1beta2().Replicas(testNamespace).List(context.TODO(), metav1.ListOptions{})
+		assert.NoError(err, name)
+		assert.Empty(replicas.Items, name)
+	}
+}
+
+func TestImportVolumeRollback(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	c, err := fake.NewCluster(testNamespace, stopCh, newImportObjects(4*testVolumeSize,
+		newImportOrphan("orphan-1", "vol-1-abc", testVolumeSize, false))...)
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+
+	// The replicas are deleted once the volume fails to be created
+	c.Faults.Add(fake.Fault{Operation: "create", Target: "volumes", Err: fmt.Errorf("injected"), Times: 1})
+	_, err = m.Import(context.Background(), testVolumeName, []string{"orphan-1"}, &longhorn.VolumeSpec{})
+	assert.Error(err)
+	replicas, err := c.LonghornClient.LonghornV1beta2().Replicas(testNamespace).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(err)
+	assert.Empty(replicas.Items)
+	assert.Eventually(func() bool {
+		replicas, err := c.DataStore.ListReplicasRO()
+		return err == nil && len(replicas) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The replicas are deactivated before they're deleted, so the replica
+	// controller leaves the data on the disk
+	c.Faults.Add(fake.Fault{Operation: "create", Target: "volumes", Err: fmt.Errorf("injected"), Times: 1})
+	c.Faults.Add(fake.Fault{Operation: "delete", Target: "replicas", Err: fmt.Errorf("injected"), Times: 1})
+	_, err = m.Import(context.Background(), testVolumeName, []string{"orphan-1"}, &longhorn.VolumeSpec{})
+	assert.Error(err)
+	replicas, err = c.LonghornClient.LonghornV1beta2().Replicas(testNamespace).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(err)
+	assert.Len(replicas.Items, 1)
+	assert.False(replicas.Items[0].Spec.Active)
+	assert.Equal("vol-1-abc", replicas.Items[0].Spec.DataDirectoryName)
+}
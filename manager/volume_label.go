@@ -0,0 +1,184 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	bsutil "github.com/longhorn/backupstore/util"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+const (
+	VolumeBulkActionDetach   = "detach"
+	VolumeBulkActionSnapshot = "snapshot"
+	VolumeBulkActionBackup   = "backup"
+)
+
+type VolumeBulkActionResult struct {
+	Volume string `json:"volume"`
+	Error  string `json:"error"`
+}
+
+// IsVolumeUserLabel returns false for the labels managed by Longhorn, e.g.
+// the recurring job and the setting labels.
+func IsVolumeUserLabel(key string) bool {
+	i := strings.Index(key, "/")
+	if i < 0 {
+		return true
+	}
+	return !strings.HasSuffix(key[:i], types.LonghornLabelKeyPrefix)
+}
+
+// GetVolumeUserLabels returns the labels of the volume set by the user.
+func GetVolumeUserLabels(v *longhorn.Volume) map[string]string {
+	labels := map[string]string{}
+	for key, value := range v.Labels {
+		if IsVolumeUserLabel(key) {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+func validateVolumeUserLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if !IsVolumeUserLabel(key) {
+			return types.NewReasonError(types.ErrorReasonInvalidParameter,
+				map[string]string{types.ErrorParameterParameter: "labels", types.ErrorParameterValue: key},
+				"label %v is reserved for Longhorn", key)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+			return types.NewReasonError(types.ErrorReasonInvalidParameter,
+				map[string]string{types.ErrorParameterParameter: "labels", types.ErrorParameterValue: key},
+				"invalid label key %v: %v", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) != 0 {
+			return types.NewReasonError(types.ErrorReasonInvalidParameter,
+				map[string]string{types.ErrorParameterParameter: "labels", types.ErrorParameterValue: value},
+				"invalid label value %v: %v", value, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// UpdateLabels replaces the user labels of the volume. The labels managed by
+// Longhorn are kept.
+func (m *VolumeManager) UpdateLabels(name string, labels map[string]string) (v *longhorn.Volume, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to update labels for volume %v", name)
+	}()
+
+	if err := validateVolumeUserLabels(labels); err != nil {
+		return nil, err
+	}
+
+	v, err = m.ds.GetVolume(name)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := map[string]string{}
+	for key, value := range v.Labels {
+		if !IsVolumeUserLabel(key) {
+			updated[key] = value
+		}
+	}
+	for key, value := range labels {
+		updated[key] = value
+	}
+	v.Labels = updated
+
+	v, err = m.ds.UpdateVolume(v)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debugf("Updated volume %v labels to %v", v.Name, labels)
+	return v, nil
+}
+
+// BulkAction runs the action on all the volumes matching the label selector
+// and reports the result of each volume. Snapshots and backups are taken
+// asynchronously by the snapshot and backup controllers.
+func (m *VolumeManager) BulkAction(ctx context.Context, action, labelSelector string) (results []*VolumeBulkActionResult, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to %v volumes matching %v", action, labelSelector)
+	}()
+
+	var f func(v *longhorn.Volume) error
+	switch action {
+	case VolumeBulkActionDetach:
+		f = func(v *longhorn.Volume) error {
+			_, err := m.Detach(ctx, v.Name, "", false)
+			return err
+		}
+	case VolumeBulkActionSnapshot:
+		f = func(v *longhorn.Volume) error {
+			_, err := m.createSnapshotCR(v, nil)
+			return err
+		}
+	case VolumeBulkActionBackup:
+		f = m.backupVolume
+	default:
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "action", types.ErrorParameterValue: action},
+			"unknown bulk action %v", action)
+	}
+	if labelSelector == "" {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "labelSelector"},
+			"label selector is required")
+	}
+
+	volumes, _, err := m.ListPage(&VolumeListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, err
+	}
+	results = []*VolumeBulkActionResult{}
+	for _, v := range volumes {
+		result := &VolumeBulkActionResult{Volume: v.Name}
+		if err := f(v); err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (m *VolumeManager) createSnapshotCR(v *longhorn.Volume, labels map[string]string) (*longhorn.Snapshot, error) {
+	if v.Status.State != longhorn.VolumeStateAttached {
+		return nil, fmt.Errorf("volume %v is not attached", v.Name)
+	}
+	return m.ds.CreateSnapshot(&longhorn.Snapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   util.UUID(),
+			Labels: labels,
+		},
+		Spec: longhorn.SnapshotSpec{
+			Volume:         v.Name,
+			CreateSnapshot: true,
+		},
+	})
+}
+
+// backupVolume takes a snapshot of the volume labeled with the name of the
+// backup. The snapshot controller backs it up once the snapshot is ready, so
+// the backup isn't lost if the manager restarts in between.
+func (m *VolumeManager) backupVolume(v *longhorn.Volume) error {
+	if err := m.checkVolumeNotInMigration(v.Name); err != nil {
+		return err
+	}
+	_, err := m.createSnapshotCR(v, map[string]string{
+		types.GetLonghornLabelKey(types.LonghornLabelBulkBackup): bsutil.GenerateName("backup"),
+	})
+	return err
+}
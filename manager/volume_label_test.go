@@ -0,0 +1,44 @@
+package manager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+func TestBulkBackup(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	v, e, ei := newRunningVolumeObjects(testNode1)
+	v.Labels = map[string]string{"app": "test"}
+	c, err := fake.NewCluster(testNamespace, stopCh, v, e, ei, newReadyNode(testNode1))
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+
+	results, err := m.BulkAction(context.Background(), manager.VolumeBulkActionBackup, "app=test")
+	assert.NoError(err)
+	assert.Equal([]*manager.VolumeBulkActionResult{{Volume: testVolumeName}}, results)
+
+	// The backup is requested on the snapshot, to be created by the snapshot
+	// controller once it's ready
+	assert.Eventually(func() bool {
+		snapshots, err := c.DataStore.ListSnapshots()
+		return err == nil && len(snapshots) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	snapshots, err := c.DataStore.ListSnapshots()
+	assert.NoError(err)
+	for _, snapshot := range snapshots {
+		assert.Equal(testVolumeName, snapshot.Spec.Volume)
+		assert.True(snapshot.Spec.CreateSnapshot)
+		assert.NotEmpty(snapshot.Labels[types.GetLonghornLabelKey(types.LonghornLabelBulkBackup)])
+	}
+}
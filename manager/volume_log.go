@@ -0,0 +1,342 @@
+package manager
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/longhorn/longhorn-manager/engineapi"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+const (
+	VolumeLogComponentEngine  = "engine"
+	VolumeLogComponentReplica = "replica"
+)
+
+type volumeLogInstance struct {
+	name                string
+	instanceManagerName string
+}
+
+// VolumeLogs writes the logs of the engine and the replica processes of the
+// volume to w, each line prefixed by the process name. The component limits
+// the logs to the engine or to the replicas. The logs are read from the
+// instance managers, which keep the output of the processes since they
+// started. If since is not zero, the lines logged before it are skipped. If
+// tail is positive, only the last tail lines of each process are written.
+func (m *VolumeManager) VolumeLogs(ctx context.Context, w io.Writer, volumeName, component string, since time.Time, tail int) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to get logs of volume %v", volumeName)
+	}()
+
+	instances, err := m.getVolumeLogInstances(volumeName, component)
+	if err != nil {
+		return err
+	}
+	for _, instance := range instances {
+		if err := m.writeInstanceLog(ctx, w, instance, since, tail, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *VolumeManager) getVolumeLogInstances(volumeName, component string) ([]*volumeLogInstance, error) {
+	if component != "" && component != VolumeLogComponentEngine && component != VolumeLogComponentReplica {
+		return nil, types.NewReasonError(types.ErrorReasonInvalidParameter,
+			map[string]string{types.ErrorParameterParameter: "component", types.ErrorParameterValue: component},
+			"invalid log component %v", component)
+	}
+	if _, err := m.ds.GetVolumeRO(volumeName); err != nil {
+		return nil, err
+	}
+
+	instances := []*volumeLogInstance{}
+	if component != VolumeLogComponentReplica {
+		engines, err := m.ds.ListVolumeEngines(volumeName)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range engines {
+			instances = append(instances, &volumeLogInstance{name: e.Name, instanceManagerName: e.Status.InstanceManagerName})
+		}
+	}
+	if component != VolumeLogComponentEngine {
+		replicas, err := m.ds.ListVolumeReplicas(volumeName)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range replicas {
+			instances = append(instances, &volumeLogInstance{name: r.Name, instanceManagerName: r.Status.InstanceManagerName})
+		}
+	}
+	// The engine names sort before the replica names of the same volume
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].name < instances[j].name
+	})
+	return instances, nil
+}
+
+// writeInstanceLog writes the log of the process, or a line explaining why
+// it's unavailable, so one stopped or unreachable process doesn't hide the
+// logs of the others.
+func (m *VolumeManager) writeInstanceLog(ctx context.Context, w io.Writer, instance *volumeLogInstance, since time.Time, tail int, prefixed bool) error {
+	prefix := ""
+	if prefixed {
+		prefix = fmt.Sprintf("[%v] ", instance.name)
+	}
+
+	var writeErr error
+	err := m.streamInstanceLog(ctx, instance, since, tail, func(line string) error {
+		_, writeErr = fmt.Fprintf(w, "%v%v\n", prefix, line)
+		return writeErr
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	if err != nil {
+		logrus.WithError(err).Warnf("Failed to get log of %v", instance.name)
+		_, err = fmt.Fprintf(w, "%vfailed to get log: %v\n", prefix, err)
+		return err
+	}
+	return nil
+}
+
+func (m *VolumeManager) streamInstanceLog(ctx context.Context, instance *volumeLogInstance, since time.Time, tail int, write func(line string) error) error {
+	if instance.instanceManagerName == "" {
+		return fmt.Errorf("process is not running")
+	}
+	im, err := m.ds.GetInstanceManagerRO(instance.instanceManagerName)
+	if err != nil {
+		return err
+	}
+	c, err := engineapi.NewInstanceManagerClient(im)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.ProcessLog(ctx, instance.name)
+	if err != nil {
+		return err
+	}
+	return filterLog(stream.Recv, since, tail, write)
+}
+
+// filterLog passes the lines received until io.EOF to write, skipping the
+// ones logged before since if it's not zero. The lines are written as they
+// are received, unless tail is positive. Then only the last tail lines are
+// kept in a ring buffer, and written once the log ends.
+func filterLog(recv func() (string, error), since time.Time, tail int, write func(line string) error) error {
+	var ring []string
+	next := 0
+	var lineTime time.Time
+	for {
+		line, err := recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// A line without the time, e.g. of a stack trace, belongs to the
+		// last line with the time
+		if t, ok := parseLogLineTime(line); ok {
+			lineTime = t
+		}
+		if !since.IsZero() && !lineTime.IsZero() && lineTime.Before(since) {
+			continue
+		}
+		if tail <= 0 {
+			if err := write(line); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(ring) < tail {
+			ring = append(ring, line)
+			continue
+		}
+		ring[next] = line
+		next = (next + 1) % tail
+	}
+	for i := range ring {
+		if err := write(ring[(next+i)%len(ring)]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseLogLineTime returns the time of a line in the logrus text format,
+// e.g. `time="2023-01-02T15:04:05Z" level=info msg="..."`.
+func parseLogLineTime(line string) (time.Time, bool) {
+	const key = `time="`
+	start := strings.Index(line, key)
+	if start < 0 {
+		return time.Time{}, false
+	}
+	value := line[start+len(key):]
+	end := strings.Index(value, `"`)
+	if end < 0 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, value[:end])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+type volumeSupportBundleSpecs struct {
+	Volume   *longhorn.Volume    `json:"volume"`
+	Engines  []*longhorn.Engine  `json:"engines"`
+	Replicas []*longhorn.Replica `json:"replicas"`
+}
+
+// VolumeSupportBundle writes a gzipped tar archive to w with what's needed to
+// troubleshoot the volume: the volume, engine and replica objects, the
+// settings, the events of these objects, and the logs of each process.
+func (m *VolumeManager) VolumeSupportBundle(ctx context.Context, w io.Writer, volumeName string) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "unable to create support bundle of volume %v", volumeName)
+	}()
+
+	v, err := m.ds.GetVolumeRO(volumeName)
+	if err != nil {
+		return err
+	}
+	specs := &volumeSupportBundleSpecs{
+		Volume:   v,
+		Engines:  []*longhorn.Engine{},
+		Replicas: []*longhorn.Replica{},
+	}
+	engines, err := m.ds.ListVolumeEngines(volumeName)
+	if err != nil {
+		return err
+	}
+	for _, e := range engines {
+		specs.Engines = append(specs.Engines, e)
+	}
+	replicas, err := m.ds.ListVolumeReplicas(volumeName)
+	if err != nil {
+		return err
+	}
+	for _, r := range replicas {
+		specs.Replicas = append(specs.Replicas, r)
+	}
+	sort.Slice(specs.Engines, func(i, j int) bool { return specs.Engines[i].Name < specs.Engines[j].Name })
+	sort.Slice(specs.Replicas, func(i, j int) bool { return specs.Replicas[i].Name < specs.Replicas[j].Name })
+
+	settings, err := m.ListSettingsSorted()
+	if err != nil {
+		return err
+	}
+
+	objects := map[string]bool{volumeName: true}
+	for name := range engines {
+		objects[name] = true
+	}
+	for name := range replicas {
+		objects[name] = true
+	}
+	eventList, err := m.GetLonghornEventList()
+	if err != nil {
+		return err
+	}
+	events := []corev1.Event{}
+	for _, event := range eventList.Items {
+		if objects[event.InvolvedObject.Name] {
+			events = append(events, event)
+		}
+	}
+
+	instances, err := m.getVolumeLogInstances(volumeName, "")
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	now := m.clock.Now()
+	for _, file := range []struct {
+		name string
+		obj  interface{}
+	}{
+		{"volume.json", specs},
+		{"settings.json", settings},
+		{"events.json", events},
+	} {
+		data, err := json.MarshalIndent(file.obj, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, volumeName+"/"+file.name, bytes.NewReader(data), int64(len(data)), now); err != nil {
+			return err
+		}
+	}
+	for _, instance := range instances {
+		if err := m.writeInstanceLogTarFile(ctx, tw, volumeName+"/logs/"+instance.name+".log", instance, now); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// writeInstanceLogTarFile spools the log of the process to a temporary file,
+// since the size of a tar file is written before its content, so the whole
+// log isn't held in memory.
+func (m *VolumeManager) writeInstanceLogTarFile(ctx context.Context, tw *tar.Writer, name string, instance *volumeLogInstance, modTime time.Time) error {
+	f, err := os.CreateTemp("", "longhorn-volume-log-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := m.writeInstanceLog(ctx, f, instance, time.Time{}, 0, false); err != nil {
+		return err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return writeTarFile(tw, name, f, size, modTime)
+}
+
+func writeTarFile(tw *tar.Writer, name string, r io.Reader, size int64, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := io.CopyN(tw, r, size)
+	return err
+}
@@ -0,0 +1,116 @@
+package manager
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogLineTime(t *testing.T) {
+	assert := require.New(t)
+
+	testCases := map[string]struct {
+		line         string
+		expectedTime time.Time
+		expectedOK   bool
+	}{
+		"logrus": {
+			`time="2023-01-02T15:04:05Z" level=info msg="Starting"`,
+			time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC), true,
+		},
+		"nanoseconds": {
+			`time="2023-01-02T15:04:05.123456789Z" level=info msg="Starting"`,
+			time.Date(2023, 1, 2, 15, 4, 5, 123456789, time.UTC), true,
+		},
+		"prefixed": {
+			`[pvc-1-r-1] time="2023-01-02T15:04:05Z" level=info`,
+			time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC), true,
+		},
+		"without time":  {`goroutine 1 [running]:`, time.Time{}, false},
+		"unterminated":  {`time="2023-01-02T15:04:05Z`, time.Time{}, false},
+		"invalid time":  {`time="yesterday" level=info`, time.Time{}, false},
+		"empty":         {"", time.Time{}, false},
+		"without quote": {`time=2023-01-02T15:04:05Z level=info`, time.Time{}, false},
+	}
+	for name, tc := range testCases {
+		parsed, ok := parseLogLineTime(tc.line)
+		assert.Equal(tc.expectedOK, ok, name)
+		assert.True(tc.expectedTime.Equal(parsed), "%v: unexpected time %v", name, parsed)
+	}
+}
+
+func newLogReceiver(lines []string, err error) func() (string, error) {
+	return func() (string, error) {
+		if len(lines) == 0 {
+			if err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line, nil
+	}
+}
+
+func TestFilterLog(t *testing.T) {
+	assert := require.New(t)
+
+	lines := []string{
+		`time="2023-01-02T15:00:00Z" level=info msg="1"`,
+		`time="2023-01-02T15:01:00Z" level=error msg="2"`,
+		// Belongs to the line above
+		`goroutine 1 [running]:`,
+		`time="2023-01-02T15:02:00Z" level=info msg="3"`,
+		`time="2023-01-02T15:03:00Z" level=info msg="4"`,
+	}
+	testCases := map[string]struct {
+		since    time.Time
+		tail     int
+		expected []string
+	}{
+		"all":              {time.Time{}, 0, lines},
+		"since":            {time.Date(2023, 1, 2, 15, 2, 0, 0, time.UTC), 0, lines[3:]},
+		"since with trace": {time.Date(2023, 1, 2, 15, 0, 30, 0, time.UTC), 0, lines[1:]},
+		"since after all":  {time.Date(2023, 1, 2, 16, 0, 0, 0, time.UTC), 0, []string{}},
+		"tail":             {time.Time{}, 2, lines[3:]},
+		"tail of one":      {time.Time{}, 1, lines[4:]},
+		"tail over all":    {time.Time{}, 10, lines},
+		"since and tail":   {time.Date(2023, 1, 2, 15, 0, 30, 0, time.UTC), 3, lines[2:]},
+	}
+	for name, tc := range testCases {
+		written := []string{}
+		err := filterLog(newLogReceiver(lines, nil), tc.since, tc.tail, func(line string) error {
+			written = append(written, line)
+			return nil
+		})
+		assert.NoError(err, name)
+		assert.Equal(tc.expected, written, name)
+	}
+
+	// The lines before the receive error are written unless kept for the tail
+	written := []string{}
+	err := filterLog(newLogReceiver(lines[:2], fmt.Errorf("connection reset")), time.Time{}, 0, func(line string) error {
+		written = append(written, line)
+		return nil
+	})
+	assert.Error(err)
+	assert.Equal(lines[:2], written)
+
+	written = []string{}
+	err = filterLog(newLogReceiver(lines[:2], fmt.Errorf("connection reset")), time.Time{}, 1, func(line string) error {
+		written = append(written, line)
+		return nil
+	})
+	assert.Error(err)
+	assert.Empty(written)
+
+	// The write error stops the filtering
+	err = filterLog(newLogReceiver(lines, nil), time.Time{}, 0, func(line string) error {
+		return fmt.Errorf("broken pipe")
+	})
+	assert.EqualError(err, "broken pipe")
+}
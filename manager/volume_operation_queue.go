@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+const (
+	VolumeOperationAttach = "attach"
+	VolumeOperationDetach = "detach"
+	VolumeOperationDelete = "delete"
+)
+
+// contradictingVolumeOperations are the operations whose result depends on
+// the order they run, e.g. a detachment queued after an attachment.
+var contradictingVolumeOperations = map[string]map[string]bool{
+	VolumeOperationAttach: {VolumeOperationDetach: true, VolumeOperationDelete: true},
+	VolumeOperationDetach: {VolumeOperationAttach: true},
+	VolumeOperationDelete: {VolumeOperationAttach: true},
+}
+
+// volumeOperationQueue runs the mutating operations of a volume one by one,
+// in the order they're requested to this manager.
+type volumeOperationQueue struct {
+	lock    sync.Mutex
+	volumes map[string][]*volumeOperation
+}
+
+type volumeOperation struct {
+	name string
+	done chan struct{}
+}
+
+func newVolumeOperationQueue() *volumeOperationQueue {
+	return &volumeOperationQueue{
+		volumes: map[string][]*volumeOperation{},
+	}
+}
+
+// acquire waits for the operations queued before, and returns the function
+// to call once the operation is done. The operation is rejected if it
+// contradicts one still waiting in the queue. The running one is not
+// considered, since the operation is going to be applied on its result. If
+// the context is done while waiting, the operation leaves the queue without
+// running.
+func (q *volumeOperationQueue) acquire(ctx context.Context, volumeName, operation string) (release func(), err error) {
+	q.lock.Lock()
+	queued := q.volumes[volumeName]
+	for i := 1; i < len(queued); i++ {
+		if contradictingVolumeOperations[operation][queued[i].name] {
+			q.lock.Unlock()
+			return nil, types.NewReasonError(types.ErrorReasonConflict,
+				map[string]string{types.ErrorParameterName: volumeName, types.ErrorParameterValue: queued[i].name},
+				"cannot %v volume %v while %v is pending", operation, volumeName, queued[i].name)
+		}
+	}
+
+	op := &volumeOperation{
+		name: operation,
+		done: make(chan struct{}),
+	}
+	q.volumes[volumeName] = append(queued, op)
+	q.lock.Unlock()
+
+	for {
+		previous := q.getPrevious(volumeName, op)
+		if previous == nil {
+			return func() { q.release(volumeName, op) }, nil
+		}
+		select {
+		case <-previous.done:
+		case <-ctx.Done():
+			q.release(volumeName, op)
+			return nil, errors.Wrapf(ctx.Err(), "canceled waiting to %v volume %v", operation, volumeName)
+		}
+	}
+}
+
+// getPrevious returns the operation queued right before op, or nil if op is
+// the first one. The previous one may leave the queue without running, so
+// op waits for the one before it again.
+func (q *volumeOperationQueue) getPrevious(volumeName string, op *volumeOperation) *volumeOperation {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	queued := q.volumes[volumeName]
+	for i := range queued {
+		if queued[i] == op {
+			if i == 0 {
+				return nil
+			}
+			return queued[i-1]
+		}
+	}
+	return nil
+}
+
+func (q *volumeOperationQueue) release(volumeName string, op *volumeOperation) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	queued := q.volumes[volumeName]
+	for i := range queued {
+		if queued[i] == op {
+			queued = append(queued[:i], queued[i+1:]...)
+			break
+		}
+	}
+	if len(queued) == 0 {
+		delete(q.volumes, volumeName)
+	} else {
+		q.volumes[volumeName] = queued
+	}
+	close(op.done)
+}
+
+func (q *volumeOperationQueue) depths() map[string]int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	depths := map[string]int{}
+	for name, queued := range q.volumes {
+		depths[name] = len(queued)
+	}
+	return depths
+}
+
+// GetVolumeOperationQueueDepths returns the number of the running and the
+// waiting operations of each volume with any.
+func (m *VolumeManager) GetVolumeOperationQueueDepths() map[string]int {
+	return m.volumeOperations.depths()
+}
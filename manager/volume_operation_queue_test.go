@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+const testQueueVolume = "test-volume"
+
+// acquireInBackground queues the operation, and sends its release function
+// once it can run.
+func acquireInBackground(ctx context.Context, q *volumeOperationQueue, operation string) (<-chan func(), <-chan error) {
+	releaseCh := make(chan func(), 1)
+	errCh := make(chan error, 1)
+	go func() {
+		release, err := q.acquire(ctx, testQueueVolume, operation)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		releaseCh <- release
+	}()
+	return releaseCh, errCh
+}
+
+func waitForQueueDepth(t *testing.T, q *volumeOperationQueue, depth int) {
+	require.Eventually(t, func() bool {
+		return q.depths()[testQueueVolume] == depth
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestVolumeOperationQueueOrder(t *testing.T) {
+	assert := require.New(t)
+
+	q := newVolumeOperationQueue()
+	release, err := q.acquire(context.Background(), testQueueVolume, VolumeOperationAttach)
+	assert.NoError(err)
+
+	// The operations run one by one, in the order they're queued
+	lock := sync.Mutex{}
+	order := []int{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		waitForQueueDepth(t, q, i+1)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			release, err := q.acquire(context.Background(), testQueueVolume, VolumeOperationAttach)
+			assert.NoError(err)
+			lock.Lock()
+			order = append(order, i)
+			lock.Unlock()
+			release()
+		}(i)
+	}
+	waitForQueueDepth(t, q, 6)
+	lock.Lock()
+	assert.Empty(order)
+	lock.Unlock()
+
+	release()
+	wg.Wait()
+	assert.Equal([]int{0, 1, 2, 3, 4}, order)
+	assert.Empty(q.depths())
+}
+
+func TestVolumeOperationQueueConflict(t *testing.T) {
+	assert := require.New(t)
+
+	q := newVolumeOperationQueue()
+	release, err := q.acquire(context.Background(), testQueueVolume, VolumeOperationAttach)
+	assert.NoError(err)
+
+	// The running attachment doesn't block a detachment
+	detachReleaseCh, detachErrCh := acquireInBackground(context.Background(), q, VolumeOperationDetach)
+	waitForQueueDepth(t, q, 2)
+
+	// but an attachment can't be queued after the pending detachment
+	_, err = q.acquire(context.Background(), testQueueVolume, VolumeOperationAttach)
+	assert.Equal(types.ErrorReasonConflict, types.GetReasonError(err).Reason)
+
+	// while a deletion can, and a later attachment contradicts both
+	deleteReleaseCh, deleteErrCh := acquireInBackground(context.Background(), q, VolumeOperationDelete)
+	waitForQueueDepth(t, q, 3)
+	_, err = q.acquire(context.Background(), testQueueVolume, VolumeOperationAttach)
+	assert.Equal(types.ErrorReasonConflict, types.GetReasonError(err).Reason)
+
+	release()
+	for _, ch := range []struct {
+		releaseCh <-chan func()
+		errCh     <-chan error
+	}{{detachReleaseCh, detachErrCh}, {deleteReleaseCh, deleteErrCh}} {
+		select {
+		case release := <-ch.releaseCh:
+			release()
+		case err := <-ch.errCh:
+			assert.Fail("unexpected error", err)
+		case <-time.After(5 * time.Second):
+			assert.Fail("operation doesn't run after the previous one is done")
+		}
+	}
+	assert.Empty(q.depths())
+}
+
+func TestVolumeOperationQueueCancel(t *testing.T) {
+	assert := require.New(t)
+
+	q := newVolumeOperationQueue()
+	release, err := q.acquire(context.Background(), testQueueVolume, VolumeOperationAttach)
+	assert.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, canceledErrCh := acquireInBackground(ctx, q, VolumeOperationAttach)
+	waitForQueueDepth(t, q, 2)
+	lastReleaseCh, _ := acquireInBackground(context.Background(), q, VolumeOperationAttach)
+	waitForQueueDepth(t, q, 3)
+
+	// The canceled operation leaves the queue without running
+	cancel()
+	select {
+	case err := <-canceledErrCh:
+		assert.ErrorIs(err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		assert.Fail("canceled operation is still waiting")
+	}
+	waitForQueueDepth(t, q, 2)
+
+	// and the following one still waits for the running one
+	select {
+	case <-lastReleaseCh:
+		assert.Fail("operation runs before the previous one is done")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case release := <-lastReleaseCh:
+		release()
+	case <-time.After(5 * time.Second):
+		assert.Fail("operation doesn't run after the previous one is done")
+	}
+	assert.Empty(q.depths())
+}
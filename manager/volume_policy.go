@@ -0,0 +1,139 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/types"
+)
+
+type VolumePolicyOperation string
+
+const (
+	VolumePolicyOperationCreate = VolumePolicyOperation("create")
+	VolumePolicyOperationAttach = VolumePolicyOperation("attach")
+	VolumePolicyOperationDelete = VolumePolicyOperation("delete")
+	VolumePolicyOperationExpand = VolumePolicyOperation("expand")
+
+	VolumePolicyParameterNode            = "node"
+	VolumePolicyParameterDisableFrontend = "disableFrontend"
+	VolumePolicyParameterAttachedBy      = "attachedBy"
+	VolumePolicyParameterSize            = "size"
+
+	volumePolicyWebhookTimeout = 10 * time.Second
+)
+
+// VolumePolicyReview is the volume operation reviewed by the policies. Spec
+// and Labels are only set for create.
+type VolumePolicyReview struct {
+	Operation  VolumePolicyOperation `json:"operation"`
+	Volume     string                `json:"volume"`
+	Spec       *longhorn.VolumeSpec  `json:"spec,omitempty"`
+	Labels     map[string]string     `json:"labels,omitempty"`
+	Parameters map[string]string     `json:"parameters"`
+}
+
+// VolumePolicyResult denies the operation, or allows it with the changed spec
+// for create and the changed parameters for the other operations. The
+// parameters not returned are unchanged.
+type VolumePolicyResult struct {
+	Allowed    bool                 `json:"allowed"`
+	Message    string               `json:"message,omitempty"`
+	Spec       *longhorn.VolumeSpec `json:"spec,omitempty"`
+	Parameters map[string]string    `json:"parameters,omitempty"`
+}
+
+// VolumePolicy is an extension point to enforce the guardrails of an
+// organization on the volume operations of the API.
+type VolumePolicy interface {
+	Name() string
+	Review(review *VolumePolicyReview) (*VolumePolicyResult, error)
+}
+
+// RegisterVolumePolicy adds a policy evaluated before the webhooks of the
+// volume policy webhooks setting. It's not safe to call after the manager
+// starts serving.
+func (m *VolumeManager) RegisterVolumePolicy(policy VolumePolicy) {
+	m.policies = append(m.policies, policy)
+}
+
+// reviewVolumeOperation runs the policies in order, each one reviewing the
+// operation as changed by the previous ones.
+func (m *VolumeManager) reviewVolumeOperation(review *VolumePolicyReview) (*VolumePolicyReview, error) {
+	webhooksSetting, err := m.ds.GetSetting(types.SettingNameVolumePolicyWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	webhooks, err := types.UnmarshalVolumePolicyWebhooks(webhooksSetting.Value)
+	if err != nil {
+		return nil, err
+	}
+	policies := append([]VolumePolicy{}, m.policies...)
+	for _, url := range webhooks {
+		policies = append(policies, &webhookVolumePolicy{url: url})
+	}
+
+	for _, policy := range policies {
+		result, err := policy.Review(review)
+		if err != nil {
+			// The operation isn't denied by the policy, it's unknown whether
+			// it would be allowed, so it can be retried later
+			return nil, types.NewReasonError(types.ErrorReasonUnavailable,
+				map[string]string{"policy": policy.Name()},
+				"failed to review %v of volume %v by policy %v: %v", review.Operation, review.Volume, policy.Name(), err)
+		}
+		if !result.Allowed {
+			return nil, types.NewReasonError(types.ErrorReasonForbidden,
+				map[string]string{"policy": policy.Name()},
+				"%v of volume %v is denied by policy %v: %v", review.Operation, review.Volume, policy.Name(), result.Message)
+		}
+		if result.Spec != nil && review.Operation == VolumePolicyOperationCreate {
+			review.Spec = result.Spec
+		}
+		for key, value := range result.Parameters {
+			review.Parameters[key] = value
+		}
+	}
+	return review, nil
+}
+
+type webhookVolumePolicy struct {
+	url string
+}
+
+func (p *webhookVolumePolicy) Name() string {
+	return p.url
+}
+
+func (p *webhookVolumePolicy) Review(review *VolumePolicyReview) (*VolumePolicyResult, error) {
+	body, err := json.Marshal(review)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: volumePolicyWebhookTimeout}
+	resp, err := client.Post(p.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v: %s", resp.Status, respBody)
+	}
+	result := &VolumePolicyResult{}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return nil, errors.Wrap(err, "invalid review result")
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package manager_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func TestVolumePolicyWebhook(t *testing.T) {
+	assert := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		review := &manager.VolumePolicyReview{}
+		if err := json.NewDecoder(req.Body).Decode(review); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		result := &manager.VolumePolicyResult{Allowed: review.Volume != "denied"}
+		if !result.Allowed {
+			result.Message = "not allowed"
+		}
+		if err := json.NewEncoder(rw).Encode(result); err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	testCases := map[string]struct {
+		webhook        string
+		volume         string
+		expectedReason types.ErrorReason
+	}{
+		"allowed": {
+			webhook: server.URL,
+			volume:  "allowed",
+		},
+		"denied": {
+			webhook:        server.URL,
+			volume:         "denied",
+			expectedReason: types.ErrorReasonForbidden,
+		},
+		"unreachable": {
+			webhook:        unreachable.URL,
+			volume:         "allowed",
+			expectedReason: types.ErrorReasonUnavailable,
+		},
+	}
+	for name, tc := range testCases {
+		stopCh := make(chan struct{})
+		c, err := fake.NewCluster(testNamespace, stopCh, newReadyNode(testNode1), &longhorn.Setting{
+			ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameVolumePolicyWebhooks), Namespace: testNamespace},
+			Value:      tc.webhook,
+		})
+		assert.NoError(err, name)
+
+		_, err = c.NewVolumeManager(testNode1).Create(context.Background(), tc.volume, newVolumeSpec(), nil, nil, "", "")
+		close(stopCh)
+		if tc.expectedReason == "" {
+			assert.NoError(err, name)
+			continue
+		}
+		reasonErr := types.GetReasonError(err)
+		assert.NotNil(reasonErr, "%v: unexpected error %v", name, err)
+		assert.Equal(tc.expectedReason, reasonErr.Reason, name)
+	}
+}
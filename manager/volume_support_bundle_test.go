@@ -0,0 +1,55 @@
+package manager_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/test/fake"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func TestVolumeSupportBundle(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	v := &longhorn.Volume{ObjectMeta: metav1.ObjectMeta{Name: testVolumeName, Namespace: testNamespace}}
+	c, err := fake.NewCluster(testNamespace, stopCh, v)
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+	now := time.Date(2023, 1, 2, 15, 0, 0, 0, time.UTC)
+	m.SetClock(&fakeClock{now: now})
+
+	buf := &bytes.Buffer{}
+	assert.NoError(m.VolumeSupportBundle(context.Background(), buf, testVolumeName))
+
+	gr, err := gzip.NewReader(buf)
+	assert.NoError(err)
+	tr := tar.NewReader(gr)
+	names := []string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(err)
+		names = append(names, header.Name)
+		assert.True(now.Equal(header.ModTime), "%v: %v", header.Name, header.ModTime)
+	}
+	assert.Equal([]string{
+		testVolumeName + "/volume.json",
+		testVolumeName + "/settings.json",
+		testVolumeName + "/events.json",
+	}, names)
+}
@@ -0,0 +1,550 @@
+package manager_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/longhorn/longhorn-manager/engineapi"
+	"github.com/longhorn/longhorn-manager/manager"
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+	"github.com/longhorn/longhorn-manager/util"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func newVolumeSpec() *longhorn.VolumeSpec {
+	return &longhorn.VolumeSpec{
+		Size:             testVolumeSize,
+		NumberOfReplicas: 3,
+	}
+}
+
+func TestCreateVolumeName(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	c, err := fake.NewCluster(testNamespace, stopCh, newReadyNode(testNode1))
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+
+	testCases := map[string]struct {
+		name         string
+		expectedName string
+	}{
+		"lower case": {"vol-1", "vol-1"},
+		"upper case": {"Vol-2", "vol-2"},
+		"too long":   {strings.Repeat("a", 50), util.AutoCorrectName(strings.Repeat("a", 50), 40)},
+		"dotted":     {"vol.3", ""},
+		"underscore": {"vol_4", ""},
+	}
+	for name, tc := range testCases {
+		v, err := m.Create(context.Background(), tc.name, newVolumeSpec(), nil, nil, "", "")
+		if tc.expectedName == "" {
+			assert.Equal(types.ErrorReasonInvalidParameter, types.GetReasonError(err).Reason, "%v: unexpected error %v", name, err)
+			continue
+		}
+		assert.NoError(err, name)
+		assert.Equal(tc.expectedName, v.Name, name)
+	}
+}
+
+func TestCreateVolumeIdempotencyKey(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	c, err := fake.NewCluster(testNamespace, stopCh, newReadyNode(testNode1))
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+
+	created, err := m.Create(context.Background(), testVolumeName, newVolumeSpec(), nil, nil, "", "key-1")
+	assert.NoError(err)
+
+	// The retry of the same request returns the volume
+	v, err := m.Create(context.Background(), testVolumeName, newVolumeSpec(), nil, nil, "", "key-1")
+	assert.NoError(err)
+	assert.Equal(created.UID, v.UID)
+
+	// The key reused by a different request is a conflict
+	spec := newVolumeSpec()
+	spec.NumberOfReplicas = 2
+	_, err = m.Create(context.Background(), testVolumeName, spec, nil, nil, "", "key-1")
+	assert.Equal(types.ErrorReasonConflict, types.GetReasonError(err).Reason, "unexpected error %v", err)
+	_, err = m.Create(context.Background(), testVolumeName, newVolumeSpec(), nil, map[string]string{"app": "test"}, "", "key-1")
+	assert.Equal(types.ErrorReasonConflict, types.GetReasonError(err).Reason, "unexpected error %v", err)
+
+	// Without the key or with another key, the volume already exists
+	_, err = m.Create(context.Background(), testVolumeName, newVolumeSpec(), nil, nil, "", "")
+	assert.Equal(types.ErrorReasonAlreadyExists, types.GetReasonError(err).Reason, "unexpected error %v", err)
+	_, err = m.Create(context.Background(), testVolumeName, newVolumeSpec(), nil, nil, "", "key-2")
+	assert.Equal(types.ErrorReasonAlreadyExists, types.GetReasonError(err).Reason, "unexpected error %v", err)
+}
+
+func TestCreateVolumeIdempotencyKeyConcurrently(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	c, err := fake.NewCluster(testNamespace, stopCh, newReadyNode(testNode1))
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+
+	// The concurrent request creates the volume once this one has checked
+	// that the volume doesn't exist
+	var concurrent *longhorn.Volume
+	c.LonghornClient.PrependReactor("create", "volumes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if concurrent == nil {
+			return false, nil, nil
+		}
+		v := action.(k8stesting.CreateAction).GetObject().(*longhorn.Volume)
+		if err := c.LonghornClient.Tracker().Add(concurrent); err != nil {
+			return true, nil, err
+		}
+		return true, nil, apierrors.NewAlreadyExists(longhorn.Resource("volumes"), v.Name)
+	})
+
+	created, err := m.Create(context.Background(), testVolumeName, newVolumeSpec(), nil, nil, "", "key-1")
+	assert.NoError(err)
+	assert.NoError(c.LonghornClient.Tracker().Delete(longhorn.SchemeGroupVersion.WithResource("volumes"), testNamespace, testVolumeName))
+	assert.Eventually(func() bool {
+		_, err := c.DataStore.GetVolumeRO(testVolumeName)
+		return apierrors.IsNotFound(err)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The retry racing with the original request returns the volume
+	concurrent = created.DeepCopy()
+	concurrent.ResourceVersion = ""
+	v, err := m.Create(context.Background(), testVolumeName, newVolumeSpec(), nil, nil, "", "key-1")
+	assert.NoError(err)
+	assert.Equal(created.UID, v.UID)
+}
+
+// newMigratingVolumeObjects returns the volume migrating from the node to the
+// migration node, and its engines running on both nodes.
+func newMigratingVolumeObjects(nodeID, migrationNodeID string, state longhorn.VolumeMigrationState) []runtime.Object {
+	v, e, ei := newRunningVolumeObjects(nodeID)
+	v.Spec.Migratable = true
+	v.Spec.AccessMode = longhorn.AccessModeReadWriteMany
+	v.Spec.MigrationNodeID = migrationNodeID
+	v.Status.MigrationState = state
+
+	e.Spec.DesireState = longhorn.InstanceStateRunning
+	e.Status.ReplicaModeMap = map[string]longhorn.ReplicaMode{testVolumeName + "-r-0": longhorn.ReplicaModeRW}
+	migrationEngine := e.DeepCopy()
+	migrationEngine.Name = testVolumeName + "-e-1"
+	migrationEngine.Spec.NodeID = migrationNodeID
+	migrationEngine.Status.ReplicaModeMap = map[string]longhorn.ReplicaMode{testVolumeName + "-r-1": longhorn.ReplicaModeRW}
+
+	return []runtime.Object{v, e, migrationEngine, ei, newReadyNode(nodeID), newReadyNode(migrationNodeID)}
+}
+
+func TestMigrationStartInvalid(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	v, _, _ := newRunningVolumeObjects(testNode1)
+	c, err := fake.NewCluster(testNamespace, stopCh, v, newReadyNode(testNode1), newReadyNode(testNode2))
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+
+	_, err = m.MigrationStart(context.Background(), testVolumeName, testNode2)
+	assert.Equal(types.ErrorReasonInvalidState, types.GetReasonError(err).Reason, "unexpected error %v", err)
+
+	v.Spec.Migratable = true
+	v.Spec.AccessMode = longhorn.AccessModeReadWriteMany
+	_, err = c.LonghornClient.LonghornV1beta2().Volumes(testNamespace).Update(context.TODO(), v, metav1.UpdateOptions{})
+	assert.NoError(err)
+	assert.Eventually(func() bool {
+		v, err := c.DataStore.GetVolumeRO(testVolumeName)
+		return err == nil && v.Spec.Migratable
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = m.MigrationStart(context.Background(), testVolumeName, testNode1)
+	assert.Equal(types.ErrorReasonInvalidParameter, types.GetReasonError(err).Reason, "unexpected error %v", err)
+	_, err = m.MigrationStart(context.Background(), testVolumeName, "")
+	assert.Equal(types.ErrorReasonInvalidParameter, types.GetReasonError(err).Reason, "unexpected error %v", err)
+
+	// Only the migrating volume is confirmed or rolled back
+	_, err = m.MigrationConfirm(context.Background(), testVolumeName)
+	assert.Equal(types.ErrorReasonInvalidState, types.GetReasonError(err).Reason, "unexpected error %v", err)
+	_, err = m.MigrationRollback(context.Background(), testVolumeName)
+	assert.Equal(types.ErrorReasonInvalidState, types.GetReasonError(err).Reason, "unexpected error %v", err)
+}
+
+func TestMigrationConfirm(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// The migration is confirmed once it's ready
+	c, err := fake.NewCluster(testNamespace, stopCh, newMigratingVolumeObjects(testNode1, testNode2, longhorn.VolumeMigrationStatePreparing)...)
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+	_, err = m.MigrationConfirm(context.Background(), testVolumeName)
+	assert.Equal(types.ErrorReasonInvalidState, types.GetReasonError(err).Reason, "unexpected error %v", err)
+
+	c, err = fake.NewCluster(testNamespace, stopCh, newMigratingVolumeObjects(testNode1, testNode2, longhorn.VolumeMigrationStateReady)...)
+	assert.NoError(err)
+	m = c.NewVolumeManager(testNode1)
+	v, err := m.MigrationConfirm(context.Background(), testVolumeName)
+	assert.NoError(err)
+	assert.Equal(testNode2, v.Spec.NodeID)
+	assert.Equal("", v.Spec.MigrationNodeID)
+}
+
+func TestMigrationRollback(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// The migration is rolled back whether it's ready or not
+	for _, state := range []longhorn.VolumeMigrationState{longhorn.VolumeMigrationStatePreparing, longhorn.VolumeMigrationStateReady} {
+		c, err := fake.NewCluster(testNamespace, stopCh, newMigratingVolumeObjects(testNode1, testNode2, state)...)
+		assert.NoError(err)
+		m := c.NewVolumeManager(testNode1)
+		v, err := m.MigrationRollback(context.Background(), testVolumeName)
+		assert.NoError(err, state)
+		assert.Equal(testNode1, v.Spec.NodeID, state)
+		assert.Equal("", v.Spec.MigrationNodeID, state)
+	}
+}
+
+func TestSetReadOnly(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	testCases := map[string]struct {
+		cliAPIVersion int
+		expectedErr   bool
+	}{
+		"unsupported engine image": {engineapi.CLIVersionSeven, true},
+		"supported engine image":   {engineapi.CLIVersionEight, false},
+	}
+	for name, tc := range testCases {
+		v, e, ei := newRunningVolumeObjects(testNode1)
+		ei.Status.CLIAPIVersion = tc.cliAPIVersion
+		c, err := fake.NewCluster(testNamespace, stopCh, newReadyNode(testNode1), v, e, ei)
+		assert.NoError(err, name)
+		m := c.NewVolumeManager(testNode1)
+
+		v, err = m.SetReadOnly(testVolumeName, true)
+		if tc.expectedErr {
+			// Otherwise the engine fails to start on the next attachment
+			assert.Equal(types.ErrorReasonInvalidState, types.GetReasonError(err).Reason, "%v: unexpected error %v", name, err)
+			v, err = c.DataStore.GetVolumeRO(testVolumeName)
+			assert.NoError(err, name)
+			assert.False(v.Spec.ReadOnly, name)
+			continue
+		}
+		assert.NoError(err, name)
+		assert.True(v.Spec.ReadOnly, name)
+		assert.Eventually(func() bool {
+			v, err := c.DataStore.GetVolumeRO(testVolumeName)
+			return err == nil && v.Spec.ReadOnly
+		}, 5*time.Second, 10*time.Millisecond, name)
+
+		v, err = m.SetReadOnly(testVolumeName, false)
+		assert.NoError(err, name)
+		assert.False(v.Spec.ReadOnly, name)
+	}
+}
+
+func TestUpdateQoS(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	v, e, ei := newRunningVolumeObjects(testNode1)
+	ei.Status.CLIAPIVersion = engineapi.CLIVersionSeven
+	c, err := fake.NewCluster(testNamespace, stopCh, newReadyNode(testNode1), v, e, ei)
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+
+	// The engine image without the support would drop the limits
+	_, err = m.UpdateQoS(testVolumeName, 100, 0, 0)
+	assert.Equal(types.ErrorReasonInvalidState, types.GetReasonError(err).Reason, "unexpected error %v", err)
+
+	// Disabling the limits needs no support
+	v, err = m.UpdateQoS(testVolumeName, -1, -1, 0)
+	assert.NoError(err)
+	assert.Equal(int64(-1), v.Spec.RebuildBandwidthLimit)
+	assert.Equal(int64(-1), v.Spec.FrontendIOPSLimit)
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+type fakeFreezer struct {
+	frozen    bool
+	freezeErr error
+}
+
+func (f *fakeFreezer) Freeze(volumeName string, isEncryptedDevice bool) error {
+	if f.freezeErr != nil {
+		return f.freezeErr
+	}
+	f.frozen = true
+	return nil
+}
+
+func (f *fakeFreezer) Thaw(volumeName string, isEncryptedDevice bool) error {
+	f.frozen = false
+	return nil
+}
+
+func TestFreeze(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	v, e, ei := newRunningVolumeObjects(testNode1)
+	v.Status.CurrentNodeID = testNode1
+	c, err := fake.NewCluster(testNamespace, stopCh, newReadyNode(testNode1), v, e, ei)
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+	clock := &fakeClock{now: time.Date(2023, 1, 2, 15, 0, 0, 0, time.UTC)}
+	m.SetClock(clock)
+	freezer := &fakeFreezer{}
+	m.SetFilesystemFreezer(freezer)
+
+	waitForFrozenUntil := func(expected string) {
+		assert.Eventually(func() bool {
+			v, err := c.DataStore.GetVolumeRO(testVolumeName)
+			return err == nil && v.Status.FrozenUntil == expected
+		}, 5*time.Second, 10*time.Millisecond, "expected freeze deadline %q", expected)
+	}
+
+	v, err = m.Freeze(testVolumeName, 10*time.Minute)
+	assert.NoError(err)
+	assert.True(freezer.frozen)
+	assert.Equal("2023-01-02T15:10:00Z", v.Status.FrozenUntil)
+	waitForFrozenUntil("2023-01-02T15:10:00Z")
+
+	// Freezing the frozen filesystem again extends the deadline
+	clock.now = clock.now.Add(5 * time.Minute)
+	v, err = m.Freeze(testVolumeName, 10*time.Minute)
+	assert.NoError(err)
+	assert.Equal("2023-01-02T15:15:00Z", v.Status.FrozenUntil)
+	waitForFrozenUntil("2023-01-02T15:15:00Z")
+
+	// but a shorter timeout doesn't bring it forward
+	v, err = m.Freeze(testVolumeName, time.Minute)
+	assert.NoError(err)
+	assert.Equal("2023-01-02T15:15:00Z", v.Status.FrozenUntil)
+
+	// The deadline is kept on failure, since the filesystem is still frozen
+	freezer.freezeErr = errors.New("failed to run fsfreeze")
+	clock.now = clock.now.Add(5 * time.Minute)
+	_, err = m.Freeze(testVolumeName, 10*time.Minute)
+	assert.Error(err)
+	assert.True(freezer.frozen)
+	waitForFrozenUntil("2023-01-02T15:20:00Z")
+
+	v, err = m.Thaw(testVolumeName)
+	assert.NoError(err)
+	assert.False(freezer.frozen)
+	assert.Equal("", v.Status.FrozenUntil)
+	waitForFrozenUntil("")
+
+	_, err = m.Freeze(testVolumeName, manager.MaxVolumeFreezeTimeout+time.Second)
+	assert.Equal(types.ErrorReasonInvalidParameter, types.GetReasonError(err).Reason, "unexpected error %v", err)
+}
+
+func TestAttachTakeOverTicket(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// The volume was attached by the CSI driver before it sent the requester
+	v, e, ei := newRunningVolumeObjects(testNode1)
+	v.Spec.AttachmentTicket = longhorn.AttachmentTicket{NodeID: testNode1, RequestedAt: "2023-01-02T15:00:00Z"}
+	r := &longhorn.Replica{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testVolumeName + "-r-0",
+			Namespace: testNamespace,
+			Labels:    types.GetVolumeLabels(testVolumeName),
+		},
+		Spec: longhorn.ReplicaSpec{
+			InstanceSpec: longhorn.InstanceSpec{
+				VolumeName: testVolumeName,
+				NodeID:     testNode1,
+			},
+		},
+	}
+	c, err := fake.NewCluster(testNamespace, stopCh, newReadyNode(testNode1), v, e, r, ei)
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+
+	v, err = m.Attach(context.Background(), testVolumeName, testNode1, false, types.CSIAttachedBy)
+	assert.NoError(err)
+	// The request time is kept so the attachment isn't checked again
+	assert.Equal(longhorn.AttachmentTicket{
+		NodeID:      testNode1,
+		AttachedBy:  types.CSIAttachedBy,
+		RequestedAt: "2023-01-02T15:00:00Z",
+	}, v.Spec.AttachmentTicket)
+	assert.Eventually(func() bool {
+		v, err := c.DataStore.GetVolumeRO(testVolumeName)
+		return err == nil && v.Spec.AttachmentTicket.AttachedBy == types.CSIAttachedBy
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = m.Attach(context.Background(), testVolumeName, testNode1, false, types.CSIAttachedBy)
+	assert.NoError(err)
+	_, err = m.Attach(context.Background(), testVolumeName, testNode1, false, types.FilesystemCheckAttachedBy)
+	assert.Equal(types.ErrorReasonConflict, types.GetReasonError(err).Reason, "unexpected error %v", err)
+}
+
+func TestAttachNodeAttachedVolumeLimit(t *testing.T) {
+	assert := require.New(t)
+
+	testCases := map[string]struct {
+		limit     string
+		overrides string
+		expectErr bool
+	}{
+		"no limit": {
+			limit: "0",
+		},
+		"limit reached": {
+			limit:     "1",
+			expectErr: true,
+		},
+		"limit overridden for the node group": {
+			limit:     "1",
+			overrides: "node-type:edge|max-attached-volumes-per-node=2",
+		},
+		"limit overridden for another node group": {
+			limit:     "1",
+			overrides: "node-type:storage|max-attached-volumes-per-node=2",
+			expectErr: true,
+		},
+	}
+	for name, tc := range testCases {
+		// Another volume is attached to the node already
+		attached, e, ei := newRunningVolumeObjects(testNode1)
+		v := attached.DeepCopy()
+		v.Name = "detached-volume"
+		v.Spec.NodeID = ""
+		v.Status.State = longhorn.VolumeStateDetached
+		r := &longhorn.Replica{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      v.Name + "-r-0",
+				Namespace: testNamespace,
+				Labels:    types.GetVolumeLabels(v.Name),
+			},
+			Spec: longhorn.ReplicaSpec{
+				InstanceSpec: longhorn.InstanceSpec{
+					VolumeName: v.Name,
+					NodeID:     testNode1,
+				},
+			},
+		}
+		kubeNode := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   testNode1,
+				Labels: map[string]string{"node-type": "edge"},
+			},
+		}
+		objects := []runtime.Object{
+			attached, e, ei, v, r, newReadyNode(testNode1), kubeNode,
+			&longhorn.Setting{
+				ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameMaxAttachedVolumesPerNode), Namespace: testNamespace},
+				Value:      tc.limit,
+			},
+			&longhorn.Setting{
+				ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameNodeGroupSettingOverrides), Namespace: testNamespace},
+				Value:      tc.overrides,
+			},
+		}
+
+		stopCh := make(chan struct{})
+		c, err := fake.NewCluster(testNamespace, stopCh, objects...)
+		assert.NoError(err, name)
+		_, err = c.NewVolumeManager(testNode1).Attach(context.Background(), v.Name, testNode1, false, "")
+		close(stopCh)
+		if tc.expectErr {
+			assert.Equal(types.ErrorReasonQuotaExceeded, types.GetReasonError(err).Reason, "%v: unexpected error %v", name, err)
+			continue
+		}
+		assert.NoError(err, name)
+	}
+}
+
+func TestEvictReplica(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	v, e, ei := newRunningVolumeObjects(testNode1)
+	detached := &longhorn.Volume{
+		ObjectMeta: metav1.ObjectMeta{Name: "detached", Namespace: testNamespace},
+		Status:     longhorn.VolumeStatus{State: longhorn.VolumeStateDetached},
+	}
+	newReplica := func(name, volumeName, nodeID string) *longhorn.Replica {
+		return &longhorn.Replica{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: testNamespace,
+				Labels:    types.GetVolumeLabels(volumeName),
+			},
+			Spec: longhorn.ReplicaSpec{
+				InstanceSpec: longhorn.InstanceSpec{
+					VolumeName: volumeName,
+					NodeID:     nodeID,
+				},
+			},
+		}
+	}
+	c, err := fake.NewCluster(testNamespace, stopCh, newReadyNode(testNode1), v, e, ei, detached,
+		newReplica(testVolumeName+"-r-0", testVolumeName, testNode1),
+		newReplica(testVolumeName+"-r-1", testVolumeName, ""),
+		newReplica("detached-r-0", "detached", testNode1))
+	assert.NoError(err)
+	m := c.NewVolumeManager(testNode1)
+
+	err = m.EvictReplica("detached", "detached-r-0")
+	assert.Equal(types.ErrorReasonInvalidState, types.GetReasonError(err).Reason, "unexpected error %v", err)
+	err = m.EvictReplica(testVolumeName, "detached-r-0")
+	assert.Equal(types.ErrorReasonNotFound, types.GetReasonError(err).Reason, "unexpected error %v", err)
+	err = m.EvictReplica(testVolumeName, testVolumeName+"-r-1")
+	assert.Equal(types.ErrorReasonInvalidState, types.GetReasonError(err).Reason, "unexpected error %v", err)
+
+	assert.NoError(m.EvictReplica(testVolumeName, testVolumeName+"-r-0"))
+	assert.Eventually(func() bool {
+		r, err := c.DataStore.GetReplica(testVolumeName + "-r-0")
+		return err == nil && r.Spec.EvictionRequested
+	}, 5*time.Second, 10*time.Millisecond)
+	// Evicting the replica again is a no-op
+	assert.NoError(m.EvictReplica(testVolumeName, testVolumeName+"-r-0"))
+}
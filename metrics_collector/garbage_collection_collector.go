@@ -0,0 +1,54 @@
+package metricscollector
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/longhorn/longhorn-manager/controller"
+	"github.com/longhorn/longhorn-manager/datastore"
+)
+
+type GarbageCollectionCollector struct {
+	*baseCollector
+
+	purgedMetric metricInfo
+}
+
+func NewGarbageCollectionCollector(
+	logger logrus.FieldLogger,
+	nodeID string,
+	ds *datastore.DataStore) *GarbageCollectionCollector {
+
+	gc := &GarbageCollectionCollector{
+		baseCollector: newBaseCollector(subsystemGarbageCollection, logger, nodeID, ds),
+	}
+
+	gc.purgedMetric = metricInfo{
+		Desc: prometheus.NewDesc(
+			prometheus.BuildFQName(longhornName, subsystemGarbageCollection, "purged_total"),
+			"The number of objects deleted by the garbage collection of this longhorn manager beyond the retention settings",
+			[]string{nodeLabel, kindLabel},
+			nil,
+		),
+		Type: prometheus.CounterValue,
+	}
+
+	return gc
+}
+
+func (gc *GarbageCollectionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- gc.purgedMetric.Desc
+}
+
+func (gc *GarbageCollectionCollector) Collect(ch chan<- prometheus.Metric) {
+	defer func() {
+		if err := recover(); err != nil {
+			gc.logger.WithField("error", err).Warn("Panic during collecting metrics")
+		}
+	}()
+
+	for _, kind := range []string{controller.GarbageCollectionKindJob, controller.GarbageCollectionKindEvent} {
+		ch <- prometheus.MustNewConstMetric(gc.purgedMetric.Desc, gc.purgedMetric.Type, float64(controller.GetPurgedCounts()[kind]), gc.currentNodeID, kind)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
 
 	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/manager"
 	_ "github.com/longhorn/longhorn-manager/metrics_collector/client_go_adaper" // load the client-go metrics
 	"github.com/longhorn/longhorn-manager/metrics_collector/registry"
 	_ "github.com/longhorn/longhorn-manager/metrics_collector/workqueue" // load the workqueue metrics
@@ -18,12 +19,15 @@ import (
 	"github.com/longhorn/longhorn-manager/util"
 )
 
-func InitMetricsCollectorSystem(logger logrus.FieldLogger, currentNodeID string, ds *datastore.DataStore, kubeconfigPath string, proxyConnCounter util.Counter) {
+func InitMetricsCollectorSystem(logger logrus.FieldLogger, currentNodeID string, ds *datastore.DataStore, m *manager.VolumeManager, kubeconfigPath string, proxyConnCounter util.Counter) {
 	logger.Info("Initializing metrics collector system")
 
 	vc := NewVolumeCollector(logger, currentNodeID, ds)
 	dc := NewDiskCollector(logger, currentNodeID, ds)
 	bc := NewBackupCollector(logger, currentNodeID, ds)
+	wc := NewWorkQueueCollector(logger, currentNodeID, ds)
+	gc := NewGarbageCollectionCollector(logger, currentNodeID, ds)
+	oc := NewVolumeOperationCollector(logger, currentNodeID, ds, m)
 
 	if err := registry.Register(vc); err != nil {
 		logger.WithField("collector", subsystemVolume).WithError(err).Warn("Failed to register collector")
@@ -37,6 +41,18 @@ func InitMetricsCollectorSystem(logger logrus.FieldLogger, currentNodeID string,
 		logger.WithField("collector", subsystemBackup).WithError(err).Warn("Failed to register collector")
 	}
 
+	if err := registry.Register(wc); err != nil {
+		logger.WithField("collector", subsystemWorkQueue).WithError(err).Warn("Failed to register collector")
+	}
+
+	if err := registry.Register(gc); err != nil {
+		logger.WithField("collector", subsystemGarbageCollection).WithError(err).Warn("Failed to register collector")
+	}
+
+	if err := registry.Register(oc); err != nil {
+		logger.WithField("collector", subsystemVolume).WithError(err).Warn("Failed to register collector")
+	}
+
 	namespace := os.Getenv(types.EnvPodNamespace)
 	if namespace == "" {
 		logger.Warnf("Cannot detect pod namespace, environment variable %v is missing, "+
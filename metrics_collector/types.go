@@ -9,12 +9,14 @@ import (
 const (
 	longhornName = "longhorn"
 
-	subsystemVolume          = "volume"
-	subsystemNode            = "node"
-	subsystemDisk            = "disk"
-	subsystemInstanceManager = "instance_manager"
-	subsystemManager         = "manager"
-	subsystemBackup          = "backup"
+	subsystemVolume            = "volume"
+	subsystemNode              = "node"
+	subsystemDisk              = "disk"
+	subsystemInstanceManager   = "instance_manager"
+	subsystemManager           = "manager"
+	subsystemBackup            = "backup"
+	subsystemWorkQueue         = "workqueue"
+	subsystemGarbageCollection = "garbage_collection"
 
 	nodeLabel            = "node"
 	diskLabel            = "disk"
@@ -25,6 +27,9 @@ const (
 	instanceManagerType  = "instance_manager_type"
 	managerLabel         = "manager"
 	backupLabel          = "backup"
+	nameLabel            = "name"
+	replicaLabel         = "replica"
+	kindLabel            = "kind"
 )
 
 type metricInfo struct {
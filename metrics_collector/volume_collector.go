@@ -23,6 +23,11 @@ type VolumeCollector struct {
 	stateMetric      metricInfo
 	robustnessMetric metricInfo
 
+	rebuildThroughputMetric metricInfo
+
+	integrityLastVerifiedMetric metricInfo
+	integrityCheckFailedMetric  metricInfo
+
 	volumePerfMetrics
 }
 
@@ -87,6 +92,36 @@ func NewVolumeCollector(
 		Type: prometheus.GaugeValue,
 	}
 
+	vc.rebuildThroughputMetric = metricInfo{
+		Desc: prometheus.NewDesc(
+			prometheus.BuildFQName(longhornName, subsystemVolume, "rebuild_throughput"),
+			"Average rebuild throughput of the replica being rebuilt for this volume, uncompressed on the wire (Bytes/s)",
+			[]string{nodeLabel, volumeLabel, replicaLabel},
+			nil,
+		),
+		Type: prometheus.GaugeValue,
+	}
+
+	vc.integrityLastVerifiedMetric = metricInfo{
+		Desc: prometheus.NewDesc(
+			prometheus.BuildFQName(longhornName, subsystemVolume, "integrity_last_verified_timestamp_seconds"),
+			"Last time a snapshot of this volume passed the integrity sweep, 0 if never",
+			[]string{nodeLabel, volumeLabel},
+			nil,
+		),
+		Type: prometheus.GaugeValue,
+	}
+
+	vc.integrityCheckFailedMetric = metricInfo{
+		Desc: prometheus.NewDesc(
+			prometheus.BuildFQName(longhornName, subsystemVolume, "integrity_check_failed"),
+			"Whether the last integrity sweep of this volume failed",
+			[]string{nodeLabel, volumeLabel},
+			nil,
+		),
+		Type: prometheus.GaugeValue,
+	}
+
 	vc.volumePerfMetrics.throughputMetrics.read = metricInfo{
 		Desc: prometheus.NewDesc(
 			prometheus.BuildFQName(longhornName, subsystemVolume, "read_throughput"),
@@ -155,6 +190,8 @@ func (vc *VolumeCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- vc.sizeMetric.Desc
 	ch <- vc.stateMetric.Desc
 	ch <- vc.robustnessMetric.Desc
+	ch <- vc.integrityLastVerifiedMetric.Desc
+	ch <- vc.integrityCheckFailedMetric.Desc
 }
 
 func (vc *VolumeCollector) Collect(ch chan<- prometheus.Metric) {
@@ -198,13 +235,38 @@ func (vc *VolumeCollector) Collect(ch chan<- prometheus.Metric) {
 			ch <- prometheus.MustNewConstMetric(vc.sizeMetric.Desc, vc.sizeMetric.Type, float64(v.Status.ActualSize), vc.currentNodeID, v.Name)
 			ch <- prometheus.MustNewConstMetric(vc.stateMetric.Desc, vc.stateMetric.Type, float64(getVolumeStateValue(v)), vc.currentNodeID, v.Name)
 			ch <- prometheus.MustNewConstMetric(vc.robustnessMetric.Desc, vc.robustnessMetric.Type, float64(getVolumeRobustnessValue(v)), vc.currentNodeID, v.Name)
+			ch <- prometheus.MustNewConstMetric(vc.integrityLastVerifiedMetric.Desc, vc.integrityLastVerifiedMetric.Type, float64(getVolumeIntegrityLastVerifiedValue(v)), vc.currentNodeID, v.Name)
+			ch <- prometheus.MustNewConstMetric(vc.integrityCheckFailedMetric.Desc, vc.integrityCheckFailedMetric.Type, float64(getVolumeIntegrityCheckFailedValue(v)), vc.currentNodeID, v.Name)
 			ch <- prometheus.MustNewConstMetric(vc.volumePerfMetrics.throughputMetrics.read.Desc, vc.volumePerfMetrics.throughputMetrics.read.Type, float64(vc.getVolumeReadThroughput(metrics)), vc.currentNodeID, v.Name)
 			ch <- prometheus.MustNewConstMetric(vc.volumePerfMetrics.throughputMetrics.write.Desc, vc.volumePerfMetrics.throughputMetrics.write.Type, float64(vc.getVolumeWriteThroughput(metrics)), vc.currentNodeID, v.Name)
 			ch <- prometheus.MustNewConstMetric(vc.volumePerfMetrics.iopsMetrics.read.Desc, vc.volumePerfMetrics.iopsMetrics.read.Type, float64(vc.getVolumeReadIOPS(metrics)), vc.currentNodeID, v.Name)
 			ch <- prometheus.MustNewConstMetric(vc.volumePerfMetrics.iopsMetrics.write.Desc, vc.volumePerfMetrics.iopsMetrics.write.Type, float64(vc.getVolumeWriteIOPS(metrics)), vc.currentNodeID, v.Name)
 			ch <- prometheus.MustNewConstMetric(vc.volumePerfMetrics.latencyMetrics.read.Desc, vc.volumePerfMetrics.latencyMetrics.read.Type, float64(vc.getVolumeReadLatency(metrics)), vc.currentNodeID, v.Name)
 			ch <- prometheus.MustNewConstMetric(vc.volumePerfMetrics.latencyMetrics.write.Desc, vc.volumePerfMetrics.latencyMetrics.write.Type, float64(vc.getVolumeWriteLatency(metrics)), vc.currentNodeID, v.Name)
+
+			if e != nil {
+				vc.collectRebuildThroughput(ch, v, e)
+			}
+		}
+	}
+}
+
+func (vc *VolumeCollector) collectRebuildThroughput(ch chan<- prometheus.Metric, v *longhorn.Volume, e *longhorn.Engine) {
+	replicaMap, err := vc.ds.ListVolumeReplicas(v.Name)
+	if err != nil {
+		vc.logger.WithError(err).Warnf("Failed to list replicas of volume %v", v.Name)
+		return
+	}
+	replicas := []*longhorn.Replica{}
+	for _, r := range replicaMap {
+		replicas = append(replicas, r)
+	}
+	for addr, status := range e.Status.RebuildStatus {
+		if status == nil || !status.IsRebuilding {
+			continue
 		}
+		replicaName := datastore.ReplicaAddressToReplicaName(addr, replicas)
+		ch <- prometheus.MustNewConstMetric(vc.rebuildThroughputMetric.Desc, vc.rebuildThroughputMetric.Type, float64(status.Throughput), vc.currentNodeID, v.Name, replicaName)
 	}
 }
 
@@ -251,6 +313,24 @@ func getVolumeRobustnessValue(v *longhorn.Volume) int {
 	return robustnessValue
 }
 
+func getVolumeIntegrityLastVerifiedValue(v *longhorn.Volume) int64 {
+	if v.Status.LastIntegrityVerifiedAt == "" {
+		return 0
+	}
+	t, err := util.ParseTime(v.Status.LastIntegrityVerifiedAt)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+func getVolumeIntegrityCheckFailedValue(v *longhorn.Volume) int {
+	if v.Status.IntegrityCheckError != "" {
+		return 1
+	}
+	return 0
+}
+
 func (vc *VolumeCollector) getVolumeReadThroughput(metrics *engineapi.Metrics) int64 {
 	if metrics == nil {
 		return 0
@@ -0,0 +1,58 @@
+package metricscollector
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/manager"
+)
+
+type VolumeOperationCollector struct {
+	*baseCollector
+
+	m *manager.VolumeManager
+
+	queueDepthMetric metricInfo
+}
+
+func NewVolumeOperationCollector(
+	logger logrus.FieldLogger,
+	nodeID string,
+	ds *datastore.DataStore,
+	m *manager.VolumeManager) *VolumeOperationCollector {
+
+	oc := &VolumeOperationCollector{
+		baseCollector: newBaseCollector(subsystemVolume, logger, nodeID, ds),
+		m:             m,
+	}
+
+	oc.queueDepthMetric = metricInfo{
+		Desc: prometheus.NewDesc(
+			prometheus.BuildFQName(longhornName, subsystemVolume, "operation_queue_depth"),
+			"The number of the running and the waiting attach, detach and delete operations of this volume in this longhorn manager",
+			[]string{nodeLabel, volumeLabel},
+			nil,
+		),
+		Type: prometheus.GaugeValue,
+	}
+
+	return oc
+}
+
+func (oc *VolumeOperationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- oc.queueDepthMetric.Desc
+}
+
+func (oc *VolumeOperationCollector) Collect(ch chan<- prometheus.Metric) {
+	defer func() {
+		if err := recover(); err != nil {
+			oc.logger.WithField("error", err).Warn("Panic during collecting metrics")
+		}
+	}()
+
+	for volumeName, depth := range oc.m.GetVolumeOperationQueueDepths() {
+		ch <- prometheus.MustNewConstMetric(oc.queueDepthMetric.Desc, oc.queueDepthMetric.Type, float64(depth), oc.currentNodeID, volumeName)
+	}
+}
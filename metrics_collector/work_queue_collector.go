@@ -0,0 +1,58 @@
+package metricscollector
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/longhorn/longhorn-manager/controller"
+	"github.com/longhorn/longhorn-manager/datastore"
+)
+
+type WorkQueueCollector struct {
+	*baseCollector
+
+	oldestPendingMetric metricInfo
+
+	// for unit test
+	getWorkQueueStatuses func() []*controller.WorkQueueStatus
+}
+
+func NewWorkQueueCollector(
+	logger logrus.FieldLogger,
+	nodeID string,
+	ds *datastore.DataStore) *WorkQueueCollector {
+
+	wc := &WorkQueueCollector{
+		baseCollector:        newBaseCollector(subsystemWorkQueue, logger, nodeID, ds),
+		getWorkQueueStatuses: controller.GetWorkQueueStatuses,
+	}
+
+	wc.oldestPendingMetric = metricInfo{
+		Desc: prometheus.NewDesc(
+			prometheus.BuildFQName(longhornName, subsystemWorkQueue, "oldest_pending_seconds"),
+			"How many seconds the oldest pending item of the workqueue has been waiting for a worker",
+			[]string{nodeLabel, nameLabel},
+			nil,
+		),
+		Type: prometheus.GaugeValue,
+	}
+
+	return wc
+}
+
+func (wc *WorkQueueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- wc.oldestPendingMetric.Desc
+}
+
+func (wc *WorkQueueCollector) Collect(ch chan<- prometheus.Metric) {
+	defer func() {
+		if err := recover(); err != nil {
+			wc.logger.WithField("error", err).Warn("Panic during collecting metrics")
+		}
+	}()
+
+	for _, status := range wc.getWorkQueueStatuses() {
+		ch <- prometheus.MustNewConstMetric(wc.oldestPendingMetric.Desc, wc.oldestPendingMetric.Type, float64(status.OldestPendingAge), wc.currentNodeID, status.Name)
+	}
+}
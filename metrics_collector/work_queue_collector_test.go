@@ -0,0 +1,43 @@
+package metricscollector
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/longhorn/longhorn-manager/controller"
+)
+
+func TestWorkQueueCollector(t *testing.T) {
+	assert := require.New(t)
+
+	wc := NewWorkQueueCollector(logrus.StandardLogger(), "node-1", nil)
+	wc.getWorkQueueStatuses = func() []*controller.WorkQueueStatus {
+		return []*controller.WorkQueueStatus{
+			{Name: "longhorn-volume", Depth: 2, OldestPendingAge: 30},
+			{Name: "longhorn-engine"},
+		}
+	}
+
+	ch := make(chan prometheus.Metric, 10)
+	wc.Collect(ch)
+	close(ch)
+
+	values := map[string]float64{}
+	for metric := range ch {
+		m := &dto.Metric{}
+		assert.NoError(metric.Write(m))
+		labels := map[string]string{}
+		for _, label := range m.Label {
+			labels[label.GetName()] = label.GetValue()
+		}
+		assert.Equal("node-1", labels[nodeLabel])
+		values[labels[nameLabel]] = m.GetGauge().GetValue()
+	}
+	assert.Equal(map[string]float64{"longhorn-volume": 30, "longhorn-engine": 0}, values)
+}
@@ -3,6 +3,7 @@ package scheduler
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
@@ -44,7 +45,8 @@ func NewReplicaScheduler(ds *datastore.DataStore) *ReplicaScheduler {
 	return rcScheduler
 }
 
-// ScheduleReplica will return (nil, nil) for unschedulable replica
+// ScheduleReplica will return (nil, nil) for unschedulable replica. The
+// trace of the scheduling is kept for GetScheduleTrace.
 func (rcs *ReplicaScheduler) ScheduleReplica(replica *longhorn.Replica, replicas map[string]*longhorn.Replica, volume *longhorn.Volume) (*longhorn.Replica, util.MultiError, error) {
 	// only called when replica is starting for the first time
 	if replica.Spec.NodeID != "" {
@@ -57,13 +59,49 @@ func (rcs *ReplicaScheduler) ScheduleReplica(replica *longhorn.Replica, replicas
 		return nil, nil, nil
 	}
 
+	trace := newScheduleTrace(replica, volume)
+	scheduledReplica, multiError, err := rcs.scheduleReplica(replica, replicas, volume, trace)
+	if err == nil {
+		trace.finish(replica, multiError)
+		recordScheduleTrace(trace)
+	}
+	return scheduledReplica, multiError, err
+}
+
+// ScheduleExplain explains where the replica would be scheduled with the
+// current nodes and settings, without changing the replica.
+func (rcs *ReplicaScheduler) ScheduleExplain(replica *longhorn.Replica, replicas map[string]*longhorn.Replica, volume *longhorn.Volume) (*ScheduleTrace, error) {
+	r := replica.DeepCopy()
+	r.Spec.NodeID = ""
+	r.Spec.DiskID = ""
+	r.Spec.DiskPath = ""
+	r.Spec.HealthyAt = ""
+	r.Spec.FailedAt = ""
+	otherReplicas := map[string]*longhorn.Replica{}
+	for name, other := range replicas {
+		if name != r.Name {
+			otherReplicas[name] = other
+		}
+	}
+
+	trace := newScheduleTrace(r, volume)
+	trace.DryRun = true
+	_, multiError, err := rcs.scheduleReplica(r, otherReplicas, volume, trace)
+	if err != nil {
+		return nil, err
+	}
+	trace.finish(r, multiError)
+	return trace, nil
+}
+
+func (rcs *ReplicaScheduler) scheduleReplica(replica *longhorn.Replica, replicas map[string]*longhorn.Replica, volume *longhorn.Volume, trace *ScheduleTrace) (*longhorn.Replica, util.MultiError, error) {
 	// get all hosts
-	nodesInfo, err := rcs.getNodeInfo()
+	nodesInfo, err := rcs.getNodeInfo(trace)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	nodeCandidates, multiError := rcs.getNodeCandidates(nodesInfo, replica)
+	nodeCandidates, multiError := rcs.getNodeCandidates(nodesInfo, replica, trace)
 	if len(nodeCandidates) == 0 {
 		logrus.Errorf("There's no available node for replica %v, size %v", replica.ObjectMeta.Name, replica.Spec.VolumeSize)
 		return nil, multiError, nil
@@ -77,10 +115,16 @@ func (rcs *ReplicaScheduler) ScheduleReplica(replica *longhorn.Replica, replicas
 			if !exists {
 				continue
 			}
-			if !diskSpec.AllowScheduling || diskSpec.EvictionRequested {
+			if !diskSpec.AllowScheduling {
+				trace.filterDisk(node, diskStatus.DiskUUID, diskSpec.Path, ScheduleFilterDiskSchedulingDisabled)
+				continue
+			}
+			if diskSpec.EvictionRequested {
+				trace.filterDisk(node, diskStatus.DiskUUID, diskSpec.Path, ScheduleFilterDiskEvictionRequested)
 				continue
 			}
 			if types.GetCondition(diskStatus.Conditions, longhorn.DiskConditionTypeSchedulable).Status != longhorn.ConditionStatusTrue {
+				trace.filterDisk(node, diskStatus.DiskUUID, diskSpec.Path, ScheduleFilterDiskNotSchedulable)
 				continue
 			}
 			disks[diskStatus.DiskUUID] = struct{}{}
@@ -88,7 +132,7 @@ func (rcs *ReplicaScheduler) ScheduleReplica(replica *longhorn.Replica, replicas
 		nodeDisksMap[node.Name] = disks
 	}
 
-	diskCandidates, multiError := rcs.getDiskCandidates(nodeCandidates, nodeDisksMap, replicas, volume, true)
+	diskCandidates, multiError := rcs.getDiskCandidates(nodeCandidates, nodeDisksMap, replicas, volume, true, trace)
 
 	// there's no disk that fit for current replica
 	if len(diskCandidates) == 0 {
@@ -96,14 +140,24 @@ func (rcs *ReplicaScheduler) ScheduleReplica(replica *longhorn.Replica, replicas
 		return nil, multiError, nil
 	}
 
+	dataDirectoryNameFormat, err := rcs.ds.GetSettingValueExisted(types.SettingNameReplicaDataDirectoryNameFormat)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// schedule replica to disk
-	rcs.scheduleReplicaToDisk(replica, diskCandidates)
+	rcs.scheduleReplicaToDisk(replica, diskCandidates, types.GenerateReplicaDataDirectoryName(dataDirectoryNameFormat, volume))
 
 	return replica, nil, nil
 }
 
-func (rcs *ReplicaScheduler) getNodeCandidates(nodesInfo map[string]*longhorn.Node, schedulingReplica *longhorn.Replica) (nodeCandidates map[string]*longhorn.Node, multiError util.MultiError) {
+func (rcs *ReplicaScheduler) getNodeCandidates(nodesInfo map[string]*longhorn.Node, schedulingReplica *longhorn.Replica, trace *ScheduleTrace) (nodeCandidates map[string]*longhorn.Node, multiError util.MultiError) {
 	if schedulingReplica.Spec.HardNodeAffinity != "" {
+		for name, node := range nodesInfo {
+			if name != schedulingReplica.Spec.HardNodeAffinity {
+				trace.filterNode(node, ScheduleFilterHardNodeAffinity)
+			}
+		}
 		node, exist := nodesInfo[schedulingReplica.Spec.HardNodeAffinity]
 		if !exist {
 			return nil, util.NewMultiError(longhorn.ErrorReplicaScheduleHardNodeAffinityNotSatisfied)
@@ -120,12 +174,15 @@ func (rcs *ReplicaScheduler) getNodeCandidates(nodesInfo map[string]*longhorn.No
 	for _, node := range nodesInfo {
 		if isReady, _ := rcs.ds.CheckEngineImageReadiness(schedulingReplica.Spec.EngineImage, node.Name); isReady {
 			nodeCandidates[node.Name] = node
+		} else {
+			trace.filterNode(node, ScheduleFilterEngineImageNotReady)
 		}
 	}
 
 	if len(nodeCandidates) == 0 {
 		return map[string]*longhorn.Node{}, util.NewMultiError(longhorn.ErrorReplicaScheduleEngineImageNotReady)
 	}
+	trace.considerNodes(nodeCandidates, "")
 
 	return nodeCandidates, nil
 }
@@ -143,8 +200,34 @@ func getNodesWithEvictingReplicas(replicas map[string]*longhorn.Replica, nodeInf
 	return nodesWithEvictingReplicas
 }
 
-func (rcs *ReplicaScheduler) getDiskCandidates(nodeInfo map[string]*longhorn.Node, nodeDisksMap map[string]map[string]struct{}, replicas map[string]*longhorn.Replica, volume *longhorn.Volume, requireSchedulingCheck bool) (map[string]*Disk, util.MultiError) {
+// filterNodesOfEvictedReplicas removes the nodes of the replicas requested to
+// be evicted on their own, so the replacement isn't put back there. Unlike the
+// replicas evicted with their disk or node, they are still on a schedulable
+// disk.
+func filterNodesOfEvictedReplicas(nodeInfo map[string]*longhorn.Node, replicas map[string]*longhorn.Replica, trace *ScheduleTrace) map[string]*longhorn.Node {
+	evictedNodes := map[string]bool{}
+	for _, r := range replicas {
+		if r.Spec.EvictionRequested && r.Spec.NodeID != "" {
+			evictedNodes[r.Spec.NodeID] = true
+		}
+	}
+	if len(evictedNodes) == 0 {
+		return nodeInfo
+	}
+	filtered := map[string]*longhorn.Node{}
+	for nodeName, node := range nodeInfo {
+		if evictedNodes[nodeName] {
+			trace.filterNode(node, ScheduleFilterReplicaEvicted)
+			continue
+		}
+		filtered[nodeName] = node
+	}
+	return filtered
+}
+
+func (rcs *ReplicaScheduler) getDiskCandidates(nodeInfo map[string]*longhorn.Node, nodeDisksMap map[string]map[string]struct{}, replicas map[string]*longhorn.Replica, volume *longhorn.Volume, requireSchedulingCheck bool, trace *ScheduleTrace) (map[string]*Disk, util.MultiError) {
 	multiError := util.NewMultiError()
+	nodeInfo = filterNodesOfEvictedReplicas(nodeInfo, replicas, trace)
 
 	nodeSoftAntiAffinity, err :=
 		rcs.ds.GetSettingAsBool(types.SettingNameReplicaSoftAntiAffinity)
@@ -158,10 +241,13 @@ func (rcs *ReplicaScheduler) getDiskCandidates(nodeInfo map[string]*longhorn.Nod
 		logrus.Errorf("Error getting replica zone soft anti-affinity setting: %v", err)
 	}
 
-	getDiskCandidatesFromNodes := func(nodes map[string]*longhorn.Node) (diskCandidates map[string]*Disk, multiError util.MultiError) {
+	zoneNetworkCost, attachedZone := rcs.getZoneNetworkCost(volume)
+
+	getDiskCandidatesFromNodes := func(nodes map[string]*longhorn.Node, tier string) (diskCandidates map[string]*Disk, multiError util.MultiError) {
 		multiError = util.NewMultiError()
-		for _, node := range nodes {
-			diskCandidates, errors := rcs.filterNodeDisksForReplica(node, nodeDisksMap[node.Name], replicas, volume, requireSchedulingCheck)
+		trace.considerNodes(nodes, tier)
+		for _, node := range sortNodesByZoneNetworkCost(nodes, zoneNetworkCost, attachedZone) {
+			diskCandidates, errors := rcs.filterNodeDisksForReplica(node, nodeDisksMap[node.Name], replicas, volume, requireSchedulingCheck, trace)
 			if len(diskCandidates) > 0 {
 				return diskCandidates, nil
 			}
@@ -204,6 +290,7 @@ func (rcs *ReplicaScheduler) getDiskCandidates(nodeInfo map[string]*longhorn.Nod
 	for nodeName, node := range nodeInfo {
 		// Filter Nodes. If the Nodes don't match the tags, don't bother marking them as candidates.
 		if !rcs.checkTagsAreFulfilled(node.Spec.Tags, volume.Spec.NodeSelector) {
+			trace.filterNode(node, ScheduleFilterNodeSelector)
 			continue
 		}
 		if _, ok := usedNodes[nodeName]; !ok {
@@ -219,60 +306,60 @@ func (rcs *ReplicaScheduler) getDiskCandidates(nodeInfo map[string]*longhorn.Nod
 
 	switch {
 	case !zoneSoftAntiAffinity && !nodeSoftAntiAffinity:
-		diskCandidates, errors := getDiskCandidatesFromNodes(unusedNodesInNewZones)
+		diskCandidates, errors := getDiskCandidatesFromNodes(unusedNodesInNewZones, "unused nodes in new zones")
 		if len(diskCandidates) > 0 {
 			return diskCandidates, nil
 		}
 		multiError.Append(errors)
-		diskCandidates, errors = getDiskCandidatesFromNodes(filterNodesWithLessThanTwoReplicas(nodesWithEvictingReplicas))
+		diskCandidates, errors = getDiskCandidatesFromNodes(filterNodesWithLessThanTwoReplicas(nodesWithEvictingReplicas), "nodes with evicting replicas")
 		if len(diskCandidates) > 0 {
 			return diskCandidates, nil
 		}
 		multiError.Append(errors)
 	case zoneSoftAntiAffinity && !nodeSoftAntiAffinity:
-		diskCandidates, errors := getDiskCandidatesFromNodes(unusedNodesInNewZones)
+		diskCandidates, errors := getDiskCandidatesFromNodes(unusedNodesInNewZones, "unused nodes in new zones")
 		if len(diskCandidates) > 0 {
 			return diskCandidates, nil
 		}
 		multiError.Append(errors)
-		diskCandidates, errors = getDiskCandidatesFromNodes(unusedNodes)
+		diskCandidates, errors = getDiskCandidatesFromNodes(unusedNodes, "unused nodes")
 		if len(diskCandidates) > 0 {
 			return diskCandidates, nil
 		}
 		multiError.Append(errors)
-		diskCandidates, errors = getDiskCandidatesFromNodes(filterNodesWithLessThanTwoReplicas(nodesWithEvictingReplicas))
+		diskCandidates, errors = getDiskCandidatesFromNodes(filterNodesWithLessThanTwoReplicas(nodesWithEvictingReplicas), "nodes with evicting replicas")
 		if len(diskCandidates) > 0 {
 			return diskCandidates, nil
 		}
 		multiError.Append(errors)
 	case !zoneSoftAntiAffinity && nodeSoftAntiAffinity:
-		diskCandidates, errors := getDiskCandidatesFromNodes(unusedNodesInNewZones)
+		diskCandidates, errors := getDiskCandidatesFromNodes(unusedNodesInNewZones, "unused nodes in new zones")
 		if len(diskCandidates) > 0 {
 			return diskCandidates, nil
 		}
 		multiError.Append(errors)
-		diskCandidates, errors = getDiskCandidatesFromNodes(nodesInUnusedZones)
+		diskCandidates, errors = getDiskCandidatesFromNodes(nodesInUnusedZones, "nodes in unused zones")
 		if len(diskCandidates) > 0 {
 			return diskCandidates, nil
 		}
 		multiError.Append(errors)
-		diskCandidates, errors = getDiskCandidatesFromNodes(nodesWithEvictingReplicas)
+		diskCandidates, errors = getDiskCandidatesFromNodes(nodesWithEvictingReplicas, "nodes with evicting replicas")
 		if len(diskCandidates) > 0 {
 			return diskCandidates, nil
 		}
 		multiError.Append(errors)
 	case zoneSoftAntiAffinity && nodeSoftAntiAffinity:
-		diskCandidates, errors := getDiskCandidatesFromNodes(unusedNodesInNewZones)
+		diskCandidates, errors := getDiskCandidatesFromNodes(unusedNodesInNewZones, "unused nodes in new zones")
 		if len(diskCandidates) > 0 {
 			return diskCandidates, nil
 		}
 		multiError.Append(errors)
-		diskCandidates, errors = getDiskCandidatesFromNodes(unusedNodes)
+		diskCandidates, errors = getDiskCandidatesFromNodes(unusedNodes, "unused nodes")
 		if len(diskCandidates) > 0 {
 			return diskCandidates, nil
 		}
 		multiError.Append(errors)
-		diskCandidates, errors = getDiskCandidatesFromNodes(usedNodes)
+		diskCandidates, errors = getDiskCandidatesFromNodes(usedNodes, "used nodes")
 		if len(diskCandidates) > 0 {
 			return diskCandidates, nil
 		}
@@ -281,11 +368,61 @@ func (rcs *ReplicaScheduler) getDiskCandidates(nodeInfo map[string]*longhorn.Nod
 	return map[string]*Disk{}, multiError
 }
 
-func (rcs *ReplicaScheduler) filterNodeDisksForReplica(node *longhorn.Node, disks map[string]struct{}, replicas map[string]*longhorn.Replica, volume *longhorn.Volume, requireSchedulingCheck bool) (preferredDisks map[string]*Disk, multiError util.MultiError) {
+// getZoneNetworkCost returns the configured zone network cost and the zone of
+// the node the volume is attached to. The cost is nil if it is not configured
+// or the volume is not attached.
+func (rcs *ReplicaScheduler) getZoneNetworkCost(volume *longhorn.Volume) (types.ZoneNetworkCost, string) {
+	setting, err := rcs.ds.GetSetting(types.SettingNameReplicaZoneNetworkCost)
+	if err != nil {
+		logrus.Errorf("Error getting replica zone network cost setting: %v", err)
+		return nil, ""
+	}
+	if setting.Value == "" {
+		return nil, ""
+	}
+	zoneNetworkCost, err := types.UnmarshalZoneNetworkCost(setting.Value)
+	if err != nil {
+		logrus.Errorf("Error parsing replica zone network cost setting: %v", err)
+		return nil, ""
+	}
+
+	attachedNodeID := volume.Status.CurrentNodeID
+	if attachedNodeID == "" {
+		attachedNodeID = volume.Spec.NodeID
+	}
+	if attachedNodeID == "" {
+		return nil, ""
+	}
+	attachedNode, err := rcs.ds.GetNodeRO(attachedNodeID)
+	if err != nil {
+		logrus.Errorf("Error getting node %v of volume %v: %v", attachedNodeID, volume.Name, err)
+		return nil, ""
+	}
+	return zoneNetworkCost, attachedNode.Status.Zone
+}
+
+// sortNodesByZoneNetworkCost orders the nodes by the network cost from the
+// given zone. The order of the nodes with the same cost stays random.
+func sortNodesByZoneNetworkCost(nodes map[string]*longhorn.Node, zoneNetworkCost types.ZoneNetworkCost, zone string) []*longhorn.Node {
+	sortedNodes := []*longhorn.Node{}
+	for _, node := range nodes {
+		sortedNodes = append(sortedNodes, node)
+	}
+	if zoneNetworkCost == nil {
+		return sortedNodes
+	}
+	sort.SliceStable(sortedNodes, func(i, j int) bool {
+		return zoneNetworkCost.Get(zone, sortedNodes[i].Status.Zone) < zoneNetworkCost.Get(zone, sortedNodes[j].Status.Zone)
+	})
+	return sortedNodes
+}
+
+func (rcs *ReplicaScheduler) filterNodeDisksForReplica(node *longhorn.Node, disks map[string]struct{}, replicas map[string]*longhorn.Replica, volume *longhorn.Volume, requireSchedulingCheck bool, trace *ScheduleTrace) (preferredDisks map[string]*Disk, multiError util.MultiError) {
 	multiError = util.NewMultiError()
 	preferredDisks = map[string]*Disk{}
 
 	if len(disks) == 0 {
+		trace.filterNode(node, ScheduleFilterNodeNoDisk)
 		multiError.Append(util.NewMultiError(longhorn.ErrorReplicaScheduleDiskUnavailable))
 		return preferredDisks, multiError
 	}
@@ -308,6 +445,7 @@ func (rcs *ReplicaScheduler) filterNodeDisksForReplica(node *longhorn.Node, disk
 		}
 		if !diskFound {
 			logrus.Errorf("Cannot find the spec or the status for disk %v when scheduling replica", diskUUID)
+			trace.filterDisk(node, diskUUID, "", ScheduleFilterDiskNotFound)
 			multiError.Append(util.NewMultiError(longhorn.ErrorReplicaScheduleDiskNotFound))
 			continue
 		}
@@ -315,6 +453,7 @@ func (rcs *ReplicaScheduler) filterNodeDisksForReplica(node *longhorn.Node, disk
 			info, err := rcs.GetDiskSchedulingInfo(diskSpec, diskStatus)
 			if err != nil {
 				logrus.Errorf("Failed to get settings when scheduling replica: %v", err)
+				trace.filterDisk(node, diskUUID, diskSpec.Path, ScheduleFilterDiskSchedulingSettingError)
 				multiError.Append(util.NewMultiError(longhorn.ErrorReplicaScheduleSchedulingSettingsRetrieveFailed))
 				return preferredDisks, multiError
 			}
@@ -330,6 +469,7 @@ func (rcs *ReplicaScheduler) filterNodeDisksForReplica(node *longhorn.Node, disk
 				info.StorageScheduled += storageScheduled
 			}
 			if !rcs.IsSchedulableToDisk(volume.Spec.Size, volume.Status.ActualSize, info) {
+				trace.filterDisk(node, diskUUID, diskSpec.Path, ScheduleFilterDiskInsufficientStorage)
 				multiError.Append(util.NewMultiError(longhorn.ErrorReplicaScheduleInsufficientStorage))
 				continue
 			}
@@ -337,6 +477,7 @@ func (rcs *ReplicaScheduler) filterNodeDisksForReplica(node *longhorn.Node, disk
 
 		// Check if the Disk's Tags are valid.
 		if !rcs.checkTagsAreFulfilled(diskSpec.Tags, volume.Spec.DiskSelector) {
+			trace.filterDisk(node, diskUUID, diskSpec.Path, ScheduleFilterDiskSelector)
 			multiError.Append(util.NewMultiError(longhorn.ErrorReplicaScheduleTagsNotFulfilled))
 			continue
 		}
@@ -347,6 +488,7 @@ func (rcs *ReplicaScheduler) filterNodeDisksForReplica(node *longhorn.Node, disk
 			NodeID:     node.Name,
 		}
 		preferredDisks[diskUUID] = suggestDisk
+		trace.scoreDisk(node, suggestDisk)
 	}
 
 	return preferredDisks, multiError
@@ -367,7 +509,7 @@ func (rcs *ReplicaScheduler) checkTagsAreFulfilled(itemTags, volumeTags []string
 	return true
 }
 
-func (rcs *ReplicaScheduler) getNodeInfo() (map[string]*longhorn.Node, error) {
+func (rcs *ReplicaScheduler) getNodeInfo(trace *ScheduleTrace) (map[string]*longhorn.Node, error) {
 	nodeInfo, err := rcs.ds.ListNodes()
 	if err != nil {
 		return nil, err
@@ -386,17 +528,29 @@ func (rcs *ReplicaScheduler) getNodeInfo() (map[string]*longhorn.Node, error) {
 			nodeSchedulableCondition.Status == longhorn.ConditionStatusTrue &&
 			node.Spec.AllowScheduling {
 			scheduledNode[node.Name] = node
+			continue
+		}
+		switch {
+		case node == nil:
+		case node.DeletionTimestamp != nil:
+			trace.filterNode(node, ScheduleFilterNodeDeleting)
+		case nodeReadyCondition.Status != longhorn.ConditionStatusTrue:
+			trace.filterNode(node, ScheduleFilterNodeNotReady)
+		case !node.Spec.AllowScheduling:
+			trace.filterNode(node, ScheduleFilterNodeSchedulingDisabled)
+		default:
+			trace.filterNode(node, ScheduleFilterNodeNotSchedulable)
 		}
 	}
 	return scheduledNode, nil
 }
 
-func (rcs *ReplicaScheduler) scheduleReplicaToDisk(replica *longhorn.Replica, diskCandidates map[string]*Disk) {
+func (rcs *ReplicaScheduler) scheduleReplicaToDisk(replica *longhorn.Replica, diskCandidates map[string]*Disk, dataDirectoryName string) {
 	disk := rcs.getDiskWithMostUsableStorage(diskCandidates)
 	replica.Spec.NodeID = disk.NodeID
 	replica.Spec.DiskID = disk.DiskUUID
 	replica.Spec.DiskPath = disk.Path
-	replica.Spec.DataDirectoryName = replica.Spec.VolumeName + "-" + util.RandomID()
+	replica.Spec.DataDirectoryName = dataDirectoryName
 
 	logrus.WithFields(logrus.Fields{
 		"replica":           replica.Name,
@@ -427,7 +581,7 @@ func (rcs *ReplicaScheduler) getDiskWithMostUsableStorage(disks map[string]*Disk
 }
 
 func (rcs *ReplicaScheduler) CheckAndReuseFailedReplica(replicas map[string]*longhorn.Replica, volume *longhorn.Volume, hardNodeAffinity string) (*longhorn.Replica, error) {
-	allNodesInfo, err := rcs.getNodeInfo()
+	allNodesInfo, err := rcs.getNodeInfo(nil)
 	if err != nil {
 		return nil, err
 	}
@@ -456,7 +610,7 @@ func (rcs *ReplicaScheduler) CheckAndReuseFailedReplica(replicas map[string]*lon
 		}
 	}
 
-	diskCandidates, _ := rcs.getDiskCandidates(availableNodesInfo, availableNodeDisksMap, replicas, volume, false)
+	diskCandidates, _ := rcs.getDiskCandidates(availableNodesInfo, availableNodeDisksMap, replicas, volume, false, nil)
 
 	var reusedReplica *longhorn.Replica
 	for _, suggestDisk := range diskCandidates {
@@ -488,6 +642,10 @@ func (rcs *ReplicaScheduler) CheckAndReuseFailedReplica(replicas map[string]*lon
 //  3. replica eviction happens (volume.Status.Robustness is Healthy)
 //  4. there is no potential reusable replica
 //  5. there is potential reusable replica but the replica replenishment wait interval is passed.
+//
+// The wait interval starts from the latest failure of the potential reusable replicas, or when the volume became
+// degraded if it's later. Hence a replica failed when the volume is already degraded still gets the whole interval
+// to come back.
 func (rcs *ReplicaScheduler) RequireNewReplica(replicas map[string]*longhorn.Replica, volume *longhorn.Volume, hardNodeAffinity string) time.Duration {
 	if volume.Status.Robustness != longhorn.VolumeRobustnessDegraded {
 		return 0
@@ -497,10 +655,14 @@ func (rcs *ReplicaScheduler) RequireNewReplica(replicas map[string]*longhorn.Rep
 	}
 
 	hasPotentiallyReusableReplica := false
+	var lastFailedAt time.Time
 	for _, r := range replicas {
-		if IsPotentiallyReusableReplica(r, hardNodeAffinity) {
-			hasPotentiallyReusableReplica = true
-			break
+		if !IsPotentiallyReusableReplica(r, hardNodeAffinity) {
+			continue
+		}
+		hasPotentiallyReusableReplica = true
+		if failedAt, err := util.ParseTime(r.Spec.FailedAt); err == nil && failedAt.After(lastFailedAt) {
+			lastFailedAt = failedAt
 		}
 	}
 	if !hasPotentiallyReusableReplica {
@@ -520,14 +682,18 @@ func (rcs *ReplicaScheduler) RequireNewReplica(replicas map[string]*longhorn.Rep
 		logrus.Errorf("Failed to get parse volume last degraded timestamp %v, will directly replenish a new replica: %v", volume.Status.LastDegradedAt, err)
 		return 0
 	}
+	waitStartedAt := lastDegradedAt
+	if lastFailedAt.After(waitStartedAt) {
+		waitStartedAt = lastFailedAt
+	}
 	now := time.Now()
-	if now.After(lastDegradedAt.Add(waitInterval)) {
+	if now.After(waitStartedAt.Add(waitInterval)) {
 		return 0
 	}
 
-	logrus.Debugf("Replica replenishment is delayed until %v", lastDegradedAt.Add(waitInterval))
+	logrus.Debugf("Replica replenishment is delayed until %v", waitStartedAt.Add(waitInterval))
 	// Adding 1 more second to the check back interval to avoid clock skew
-	return lastDegradedAt.Add(waitInterval).Sub(now) + time.Second
+	return waitStartedAt.Add(waitInterval).Sub(now) + time.Second
 }
 
 func (rcs *ReplicaScheduler) isFailedReplicaReusable(r *longhorn.Replica, v *longhorn.Volume, nodeInfo map[string]*longhorn.Node, hardNodeAffinity string) bool {
@@ -657,6 +823,20 @@ func (rcs *ReplicaScheduler) IsSchedulableToDisk(size int64, requiredStorage int
 		(size+info.StorageScheduled) <= int64(float64(info.StorageMaximum-info.StorageReserved)*float64(info.OverProvisioningPercentage)/100)
 }
 
+// GetSchedulableStorage returns the largest size of a new replica that
+// IsSchedulableToDisk accepts on the disk. It's 0 if the disk is full.
+func GetSchedulableStorage(info *DiskSchedulingInfo) int64 {
+	if info.StorageMaximum <= 0 || info.StorageAvailable <= 0 ||
+		info.StorageAvailable <= int64(float64(info.StorageMaximum)*float64(info.MinimalAvailablePercentage)/100) {
+		return 0
+	}
+	schedulable := int64(float64(info.StorageMaximum-info.StorageReserved)*float64(info.OverProvisioningPercentage)/100) - info.StorageScheduled
+	if schedulable < 0 {
+		return 0
+	}
+	return schedulable
+}
+
 func (rcs *ReplicaScheduler) isDiskNotFull(info *DiskSchedulingInfo) bool {
 	// StorageAvailable = the space can be used by 3rd party or Longhorn system.
 	return info.StorageMaximum > 0 && info.StorageAvailable > 0 &&
@@ -684,6 +864,86 @@ func (rcs *ReplicaScheduler) GetDiskSchedulingInfo(disk longhorn.DiskSpec, diskS
 	return info, nil
 }
 
+// GetMaxReplicaSize returns the largest replica size that a schedulable disk
+// can hold when it's empty, considering the over provisioning. It's 0 if
+// there is no schedulable disk.
+func (rcs *ReplicaScheduler) GetMaxReplicaSize() (int64, error) {
+	nodes, err := rcs.getNodeInfo(nil)
+	if err != nil {
+		return 0, err
+	}
+	overProvisioningPercentage, err := rcs.ds.GetSettingAsInt(types.SettingNameStorageOverProvisioningPercentage)
+	if err != nil {
+		return 0, err
+	}
+
+	var maxSize int64
+	for _, node := range nodes {
+		for fsid, diskStatus := range node.Status.DiskStatus {
+			diskSpec, exists := node.Spec.Disks[fsid]
+			if !exists || !diskSpec.AllowScheduling || diskSpec.EvictionRequested {
+				continue
+			}
+			size := int64(float64(diskStatus.StorageMaximum-diskSpec.StorageReserved) * float64(overProvisioningPercentage) / 100)
+			if size > maxSize {
+				maxSize = size
+			}
+		}
+	}
+	return maxSize, nil
+}
+
+// GetMaxReplicaCount returns how many replicas of the volume the available
+// nodes can hold under the hard replica anti-affinity, regardless of the
+// storage. It's the requested replica count if both the node and the zone
+// anti-affinity are soft.
+func (rcs *ReplicaScheduler) GetMaxReplicaCount(volume *longhorn.Volume) (int, error) {
+	nodeSoftAntiAffinity, err := rcs.ds.GetSettingAsBool(types.SettingNameReplicaSoftAntiAffinity)
+	if err != nil {
+		return 0, err
+	}
+	zoneSoftAntiAffinity, err := rcs.ds.GetSettingAsBool(types.SettingNameReplicaZoneSoftAntiAffinity)
+	if err != nil {
+		return 0, err
+	}
+	if nodeSoftAntiAffinity && zoneSoftAntiAffinity {
+		return volume.Spec.NumberOfReplicas, nil
+	}
+
+	nodeInfo, err := rcs.getNodeInfo(nil)
+	if err != nil {
+		return 0, err
+	}
+	nodes := 0
+	zones := map[string]struct{}{}
+	for _, node := range nodeInfo {
+		if !rcs.checkTagsAreFulfilled(node.Spec.Tags, volume.Spec.NodeSelector) {
+			continue
+		}
+		hasDisk := false
+		for fsid, diskStatus := range node.Status.DiskStatus {
+			diskSpec, exists := node.Spec.Disks[fsid]
+			if !exists || !diskSpec.AllowScheduling || diskSpec.EvictionRequested ||
+				types.GetCondition(diskStatus.Conditions, longhorn.DiskConditionTypeSchedulable).Status != longhorn.ConditionStatusTrue ||
+				!rcs.checkTagsAreFulfilled(diskSpec.Tags, volume.Spec.DiskSelector) {
+				continue
+			}
+			hasDisk = true
+			break
+		}
+		if !hasDisk {
+			continue
+		}
+		nodes++
+		zones[node.Status.Zone] = struct{}{}
+	}
+
+	if !zoneSoftAntiAffinity {
+		return len(zones), nil
+	}
+	return nodes, nil
+}
+
 func (rcs *ReplicaScheduler) CheckReplicasSizeExpansion(v *longhorn.Volume, oldSize, newSize int64) (diskScheduleMultiError util.MultiError, err error) {
 	defer func() {
 		err = errors.Wrapf(err, "error while CheckReplicasSizeExpansion for volume %v", v.Name)
@@ -695,6 +955,7 @@ func (rcs *ReplicaScheduler) CheckReplicasSizeExpansion(v *longhorn.Volume, oldS
 	}
 	diskIDToReplicaCount := map[string]int64{}
 	diskIDToDiskInfo := map[string]*DiskSchedulingInfo{}
+	diskIDToNodeID := map[string]string{}
 	for _, r := range replicas {
 		if r.Spec.NodeID == "" {
 			continue
@@ -714,6 +975,7 @@ func (rcs *ReplicaScheduler) CheckReplicasSizeExpansion(v *longhorn.Volume, oldS
 				fmt.Errorf("failed to GetDiskSchedulingInfo %v", err)
 		}
 		diskIDToDiskInfo[r.Spec.DiskID] = diskInfo
+		diskIDToNodeID[r.Spec.DiskID] = node.Name
 		diskIDToReplicaCount[r.Spec.DiskID] = diskIDToReplicaCount[r.Spec.DiskID] + 1
 	}
 
@@ -722,8 +984,14 @@ func (rcs *ReplicaScheduler) CheckReplicasSizeExpansion(v *longhorn.Volume, oldS
 		requestingSizeExpansionOnDisk := expandingSize * diskIDToReplicaCount[diskID]
 		if !rcs.IsSchedulableToDisk(requestingSizeExpansionOnDisk, 0, diskInfo) {
 			logrus.Errorf("Cannot schedule %v more bytes to disk %v with %+v", requestingSizeExpansionOnDisk, diskID, diskInfo)
+			available := int64(float64(diskInfo.StorageMaximum-diskInfo.StorageReserved)*float64(diskInfo.OverProvisioningPercentage)/100) - diskInfo.StorageScheduled
 			return util.NewMultiError(longhorn.ErrorReplicaScheduleInsufficientStorage),
-				fmt.Errorf("cannot schedule %v more bytes to disk %v with %+v", requestingSizeExpansionOnDisk, diskID, diskInfo)
+				types.NewReasonError(types.ErrorReasonInsufficientStorage, map[string]string{
+					types.ErrorParameterNeeded:    strconv.FormatInt(requestingSizeExpansionOnDisk, 10),
+					types.ErrorParameterAvailable: strconv.FormatInt(available, 10),
+					types.ErrorParameterNode:      diskIDToNodeID[diskID],
+					types.ErrorParameterDisk:      diskID,
+				}, "cannot schedule %v more bytes to disk %v with %+v", requestingSizeExpansionOnDisk, diskID, diskInfo)
 		}
 	}
 	return nil, nil
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -810,6 +811,12 @@ func (s *TestSuite) TestReplicaScheduler(c *C) {
 					c.Assert(sr.Spec.DiskID, Not(Equals), "")
 					c.Assert(sr.Spec.DiskPath, Not(Equals), "")
 					c.Assert(sr.Spec.DataDirectoryName, Not(Equals), "")
+					trace := GetScheduleTrace(sr.Name)
+					c.Assert(trace, NotNil)
+					c.Assert(trace.NodeID, Equals, sr.Spec.NodeID)
+					c.Assert(trace.DiskID, Equals, sr.Spec.DiskID)
+					c.Assert(trace.Nodes[0].Name, Equals, sr.Spec.NodeID)
+					c.Assert(trace.Nodes[0].Filter, Equals, "")
 					tc.replicas[sr.Name] = sr
 					// check expected node
 					for nname, node := range tc.expectedNodes {
@@ -824,3 +831,283 @@ func (s *TestSuite) TestReplicaScheduler(c *C) {
 		c.Assert(len(tc.expectedNodes), Equals, 0)
 	}
 }
+
+func (s *TestSuite) TestSortNodesByZoneNetworkCost(c *C) {
+	nodes := map[string]*longhorn.Node{}
+	for name, zone := range map[string]string{
+		TestNode1: "zone-a",
+		TestNode2: "zone-b",
+		TestNode3: "zone-c",
+	} {
+		node := newNode(name, TestNamespace, true, longhorn.ConditionStatusTrue)
+		node.Status.Zone = zone
+		nodes[name] = node
+	}
+
+	sortedNodes := sortNodesByZoneNetworkCost(nodes, nil, "zone-a")
+	c.Assert(sortedNodes, HasLen, 3)
+
+	zoneNetworkCost, err := types.UnmarshalZoneNetworkCost("zone-a,zone-b=20; zone-a,zone-c=5")
+	c.Assert(err, IsNil)
+	sortedNodes = sortNodesByZoneNetworkCost(nodes, zoneNetworkCost, "zone-a")
+	c.Assert(sortedNodes, HasLen, 3)
+	c.Assert(sortedNodes[0].Name, Equals, TestNode1)
+	c.Assert(sortedNodes[1].Name, Equals, TestNode3)
+	c.Assert(sortedNodes[2].Name, Equals, TestNode2)
+
+	sortedNodes = sortNodesByZoneNetworkCost(nodes, zoneNetworkCost, "zone-b")
+	c.Assert(sortedNodes[0].Name, Equals, TestNode2)
+	c.Assert(sortedNodes[2].Name, Equals, TestNode1)
+}
+
+func (s *TestSuite) TestGetMaxReplicaCount(c *C) {
+	kubeClient := fake.NewSimpleClientset()
+	kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+	lhClient := lhfake.NewSimpleClientset()
+	lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+	extensionsClient := apiextensionsfake.NewSimpleClientset()
+
+	nIndexer := lhInformerFactory.Longhorn().V1beta2().Nodes().Informer().GetIndexer()
+	sIndexer := lhInformerFactory.Longhorn().V1beta2().Settings().Informer().GetIndexer()
+	rs := newReplicaScheduler(lhInformerFactory, kubeInformerFactory, lhClient, kubeClient, extensionsClient)
+
+	for name, zone := range map[string]string{
+		TestNode1: "zone-a",
+		TestNode2: "zone-a",
+		TestNode3: "zone-b",
+		"node-4":  "zone-c",
+	} {
+		node := newNode(name, TestNamespace, true, longhorn.ConditionStatusTrue)
+		node.Status.Zone = zone
+		node.Spec.Disks = map[string]longhorn.DiskSpec{
+			getDiskID(name, "1"): newDisk(TestDefaultDataPath, true, 0),
+		}
+		node.Status.DiskStatus = map[string]*longhorn.DiskStatus{
+			getDiskID(name, "1"): {
+				StorageAvailable: TestDiskAvailableSize,
+				StorageMaximum:   TestDiskSize,
+				Conditions: []longhorn.Condition{
+					newCondition(longhorn.DiskConditionTypeSchedulable, longhorn.ConditionStatusTrue),
+				},
+				DiskUUID: getDiskID(name, "1"),
+			},
+		}
+		// The node without a schedulable disk doesn't count
+		if name == "node-4" {
+			node.Spec.Disks[getDiskID(name, "1")] = newDisk(TestDefaultDataPath, false, 0)
+		}
+		c.Assert(nIndexer.Add(node), IsNil)
+	}
+	setSetting := func(name types.SettingName, value string) {
+		setting := initSettings(string(name), value)
+		setting.Namespace = TestNamespace
+		c.Assert(sIndexer.Add(setting), IsNil)
+	}
+
+	v := newVolume(TestVolumeName, 5)
+	setSetting(types.SettingNameReplicaSoftAntiAffinity, "false")
+	setSetting(types.SettingNameReplicaZoneSoftAntiAffinity, "true")
+	count, err := rs.GetMaxReplicaCount(v)
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 3)
+
+	setSetting(types.SettingNameReplicaZoneSoftAntiAffinity, "false")
+	count, err = rs.GetMaxReplicaCount(v)
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 2)
+
+	setSetting(types.SettingNameReplicaSoftAntiAffinity, "true")
+	count, err = rs.GetMaxReplicaCount(v)
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 2)
+
+	setSetting(types.SettingNameReplicaZoneSoftAntiAffinity, "true")
+	count, err = rs.GetMaxReplicaCount(v)
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 5)
+}
+
+func (s *TestSuite) TestRequireNewReplica(c *C) {
+	kubeClient := fake.NewSimpleClientset()
+	kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+	lhClient := lhfake.NewSimpleClientset()
+	lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+	extensionsClient := apiextensionsfake.NewSimpleClientset()
+
+	sIndexer := lhInformerFactory.Longhorn().V1beta2().Settings().Informer().GetIndexer()
+	rs := newReplicaScheduler(lhInformerFactory, kubeInformerFactory, lhClient, kubeClient, extensionsClient)
+
+	setting := initSettings(string(types.SettingNameReplicaReplenishmentWaitInterval), "600")
+	setting.Namespace = TestNamespace
+	c.Assert(sIndexer.Add(setting), IsNil)
+
+	now := time.Now()
+	timestamp := func(d time.Duration) string {
+		return now.Add(d).UTC().Format(time.RFC3339)
+	}
+	v := newVolume(TestVolumeName, 2)
+	v.Status.Robustness = longhorn.VolumeRobustnessDegraded
+	v.Status.LastDegradedAt = timestamp(-time.Hour)
+	healthy := newReplicaForVolume(v)
+	healthy.Spec.NodeID = TestNode1
+	healthy.Spec.DiskID = getDiskID(TestNode1, "1")
+	failed := newReplicaForVolume(v)
+	failed.Spec.NodeID = TestNode2
+	failed.Spec.DiskID = getDiskID(TestNode2, "1")
+	replicas := map[string]*longhorn.Replica{
+		healthy.Name: healthy,
+		failed.Name:  failed,
+	}
+
+	// The interval is passed since the failed replica and the volume are
+	// down for long
+	failed.Spec.FailedAt = timestamp(-time.Hour)
+	c.Assert(rs.RequireNewReplica(replicas, v, ""), Equals, time.Duration(0))
+
+	// The replica just failed in a volume degraded for long, so it's waited
+	// for
+	failed.Spec.FailedAt = timestamp(-time.Minute)
+	wait := rs.RequireNewReplica(replicas, v, "")
+	c.Assert(wait > 8*time.Minute && wait <= 9*time.Minute+2*time.Second, Equals, true, Commentf("wait %v", wait))
+
+	// A new replica is required right away if there is no reusable replica
+	failed.Spec.RebuildRetryCount = FailedReplicaMaxRetryCount
+	c.Assert(rs.RequireNewReplica(replicas, v, ""), Equals, time.Duration(0))
+	failed.Spec.RebuildRetryCount = 0
+
+	// or the volume is not degraded
+	v.Status.Robustness = longhorn.VolumeRobustnessHealthy
+	c.Assert(rs.RequireNewReplica(replicas, v, ""), Equals, time.Duration(0))
+}
+
+func (s *TestSuite) TestScheduleReplicaAwayFromEvictedReplica(c *C) {
+	newSchedulableNode := func(name string) *longhorn.Node {
+		node := newNode(name, TestNamespace, true, longhorn.ConditionStatusTrue)
+		node.Spec.Disks = map[string]longhorn.DiskSpec{
+			getDiskID(name, "1"): newDisk(TestDefaultDataPath, true, 0),
+		}
+		node.Status.DiskStatus = map[string]*longhorn.DiskStatus{
+			getDiskID(name, "1"): {
+				StorageAvailable: TestDiskAvailableSize,
+				StorageMaximum:   TestDiskSize,
+				Conditions: []longhorn.Condition{
+					newCondition(longhorn.DiskConditionTypeSchedulable, longhorn.ConditionStatusTrue),
+				},
+				DiskUUID: getDiskID(name, "1"),
+			},
+		}
+		return node
+	}
+
+	testCases := map[string]struct {
+		nodes          []string
+		expectedNodeID string
+	}{
+		"another node": {
+			nodes:          []string{TestNode1, TestNode2},
+			expectedNodeID: TestNode2,
+		},
+		"no other node": {
+			nodes: []string{TestNode1},
+		},
+	}
+
+	for name, tc := range testCases {
+		fmt.Printf("testing %v\n", name)
+
+		kubeClient := fake.NewSimpleClientset()
+		kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, controller.NoResyncPeriodFunc())
+		lhClient := lhfake.NewSimpleClientset()
+		lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(lhClient, controller.NoResyncPeriodFunc())
+		extensionsClient := apiextensionsfake.NewSimpleClientset()
+		nIndexer := lhInformerFactory.Longhorn().V1beta2().Nodes().Informer().GetIndexer()
+		eiIndexer := lhInformerFactory.Longhorn().V1beta2().EngineImages().Informer().GetIndexer()
+		sIndexer := lhInformerFactory.Longhorn().V1beta2().Settings().Informer().GetIndexer()
+		pIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+		rs := newReplicaScheduler(lhInformerFactory, kubeInformerFactory, lhClient, kubeClient, extensionsClient)
+
+		// The soft anti-affinity would allow the node of the evicted replica
+		c.Assert(sIndexer.Add(initSettings(string(types.SettingNameReplicaSoftAntiAffinity), "true")), IsNil)
+		ei := newEngineImage(TestEngineImage, longhorn.EngineImageStateDeployed)
+		daemons := map[string]*v1.Pod{
+			TestNode1: newDaemonPod(v1.PodRunning, TestDaemon1, TestNamespace, TestNode1, TestIP1),
+			TestNode2: newDaemonPod(v1.PodRunning, TestDaemon2, TestNamespace, TestNode2, TestIP2),
+		}
+		for _, nodeName := range tc.nodes {
+			c.Assert(nIndexer.Add(newSchedulableNode(nodeName)), IsNil)
+			c.Assert(pIndexer.Add(daemons[nodeName]), IsNil)
+			ei.Status.NodeDeploymentMap[nodeName] = true
+		}
+		c.Assert(eiIndexer.Add(ei), IsNil)
+
+		v := newVolume(TestVolumeName, 1)
+		evicted := newReplicaForVolume(v)
+		evicted.Spec.NodeID = TestNode1
+		evicted.Spec.DiskID = getDiskID(TestNode1, "1")
+		evicted.Spec.EvictionRequested = true
+		r := newReplicaForVolume(v)
+		replicas := map[string]*longhorn.Replica{
+			evicted.Name: evicted,
+			r.Name:       r,
+		}
+
+		sr, _, err := rs.ScheduleReplica(r, replicas, v)
+		c.Assert(err, IsNil)
+		if tc.expectedNodeID == "" {
+			c.Assert(sr, IsNil)
+			trace := GetScheduleTrace(r.Name)
+			c.Assert(trace, NotNil)
+			c.Assert(trace.Nodes[0].Filter, Equals, ScheduleFilterReplicaEvicted)
+			continue
+		}
+		c.Assert(sr, NotNil)
+		c.Assert(sr.Spec.NodeID, Equals, tc.expectedNodeID)
+	}
+}
+
+func (s *TestSuite) TestGetSchedulableStorage(c *C) {
+	rs := &ReplicaScheduler{}
+
+	testCases := map[string]struct {
+		info     DiskSchedulingInfo
+		expected int64
+	}{
+		"empty disk": {
+			info: DiskSchedulingInfo{
+				StorageAvailable: 100, StorageMaximum: 100, StorageReserved: 20,
+				OverProvisioningPercentage: 200, MinimalAvailablePercentage: 25,
+			},
+			expected: 160,
+		},
+		"scheduled disk": {
+			info: DiskSchedulingInfo{
+				StorageAvailable: 60, StorageMaximum: 100, StorageReserved: 20, StorageScheduled: 100,
+				OverProvisioningPercentage: 200, MinimalAvailablePercentage: 25,
+			},
+			expected: 60,
+		},
+		"over scheduled disk": {
+			info: DiskSchedulingInfo{
+				StorageAvailable: 60, StorageMaximum: 100, StorageReserved: 20, StorageScheduled: 200,
+				OverProvisioningPercentage: 200, MinimalAvailablePercentage: 25,
+			},
+		},
+		"full disk": {
+			info: DiskSchedulingInfo{
+				StorageAvailable: 25, StorageMaximum: 100, StorageReserved: 20,
+				OverProvisioningPercentage: 200, MinimalAvailablePercentage: 25,
+			},
+		},
+	}
+	for name, tc := range testCases {
+		fmt.Printf("testing %v\n", name)
+
+		size := GetSchedulableStorage(&tc.info)
+		c.Assert(size, Equals, tc.expected)
+		// It's the largest new replica the disk accepts
+		if size > 0 {
+			c.Assert(rs.IsSchedulableToDisk(size, 0, &tc.info), Equals, true)
+		}
+		c.Assert(rs.IsSchedulableToDisk(size+1, 0, &tc.info), Equals, false)
+	}
+}
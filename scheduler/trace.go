@@ -0,0 +1,212 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+const (
+	maxScheduleTraces = 1000
+
+	ScheduleFilterNodeNotReady               = "node is not ready"
+	ScheduleFilterNodeNotSchedulable         = "node is not schedulable"
+	ScheduleFilterNodeSchedulingDisabled     = "node scheduling is disabled"
+	ScheduleFilterNodeDeleting               = "node is being deleted"
+	ScheduleFilterHardNodeAffinity           = "replica is bound to another node"
+	ScheduleFilterEngineImageNotReady        = "engine image is not deployed on node"
+	ScheduleFilterNodeSelector               = "node tags don't match the node selector"
+	ScheduleFilterReplicaEvicted             = "a replica of the volume is being evicted from node"
+	ScheduleFilterAntiAffinity               = "excluded by the replica anti-affinity"
+	ScheduleFilterNodeNoDisk                 = "node has no schedulable disk"
+	ScheduleFilterNodeNoDiskFits             = "no disk on node fits"
+	ScheduleFilterNotEvaluated               = "not evaluated since a node with less cost or in a preferred tier fits"
+	ScheduleFilterDiskSchedulingDisabled     = "disk scheduling is disabled"
+	ScheduleFilterDiskEvictionRequested      = "disk eviction is requested"
+	ScheduleFilterDiskNotSchedulable         = "disk is not schedulable"
+	ScheduleFilterDiskNotFound               = "disk is not found"
+	ScheduleFilterDiskInsufficientStorage    = "disk storage is insufficient"
+	ScheduleFilterDiskSelector               = "disk tags don't match the disk selector"
+	ScheduleFilterDiskSchedulingSettingError = "failed to get the disk scheduling settings"
+)
+
+// ScheduleTrace explains a replica scheduling. A node or a disk has a filter
+// if it's ruled out, otherwise it's a candidate. The nodes are tried tier by
+// tier of the replica anti-affinity, by the zone network cost within a tier,
+// and the disk candidate of the first fitting node with the highest score,
+// the usable storage, is picked.
+type ScheduleTrace struct {
+	Volume    string               `json:"volume"`
+	Replica   string               `json:"replica"`
+	Timestamp string               `json:"timestamp"`
+	DryRun    bool                 `json:"dryRun"`
+	NodeID    string               `json:"nodeID"`
+	DiskID    string               `json:"diskID"`
+	Error     string               `json:"error"`
+	Nodes     []*NodeScheduleTrace `json:"nodes"`
+
+	nodes map[string]*NodeScheduleTrace
+}
+
+type NodeScheduleTrace struct {
+	Name   string               `json:"name"`
+	Zone   string               `json:"zone"`
+	Filter string               `json:"filter"`
+	Tier   string               `json:"tier"`
+	Disks  []*DiskScheduleTrace `json:"disks"`
+}
+
+type DiskScheduleTrace struct {
+	UUID   string `json:"uuid"`
+	Path   string `json:"path"`
+	Filter string `json:"filter"`
+	Score  int64  `json:"score"`
+}
+
+func newScheduleTrace(replica *longhorn.Replica, volume *longhorn.Volume) *ScheduleTrace {
+	return &ScheduleTrace{
+		Volume:    volume.Name,
+		Replica:   replica.Name,
+		Timestamp: util.Now(),
+		Nodes:     []*NodeScheduleTrace{},
+		nodes:     map[string]*NodeScheduleTrace{},
+	}
+}
+
+// The recording methods are no-op on nil, so the callers not explaining the
+// scheduling can pass a nil trace.
+
+func (t *ScheduleTrace) node(node *longhorn.Node) *NodeScheduleTrace {
+	n, ok := t.nodes[node.Name]
+	if !ok {
+		n = &NodeScheduleTrace{
+			Name:  node.Name,
+			Zone:  node.Status.Zone,
+			Disks: []*DiskScheduleTrace{},
+		}
+		t.nodes[node.Name] = n
+		t.Nodes = append(t.Nodes, n)
+	}
+	return n
+}
+
+func (n *NodeScheduleTrace) hasDiskCandidate() bool {
+	for _, d := range n.Disks {
+		if d.Filter == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *ScheduleTrace) filterNode(node *longhorn.Node, filter string) {
+	if t == nil {
+		return
+	}
+	if n := t.node(node); n.Filter == "" {
+		n.Filter = filter
+	}
+}
+
+func (t *ScheduleTrace) considerNodes(nodes map[string]*longhorn.Node, tier string) {
+	if t == nil {
+		return
+	}
+	for _, node := range nodes {
+		if n := t.node(node); n.Tier == "" {
+			n.Tier = tier
+		}
+	}
+}
+
+func (t *ScheduleTrace) disk(node *longhorn.Node, diskUUID, path string) *DiskScheduleTrace {
+	n := t.node(node)
+	for _, d := range n.Disks {
+		if d.UUID == diskUUID {
+			return d
+		}
+	}
+	d := &DiskScheduleTrace{
+		UUID: diskUUID,
+		Path: path,
+	}
+	n.Disks = append(n.Disks, d)
+	return d
+}
+
+func (t *ScheduleTrace) filterDisk(node *longhorn.Node, diskUUID, path, filter string) {
+	if t == nil {
+		return
+	}
+	t.disk(node, diskUUID, path).Filter = filter
+}
+
+func (t *ScheduleTrace) scoreDisk(node *longhorn.Node, disk *Disk) {
+	if t == nil {
+		return
+	}
+	t.disk(node, disk.DiskUUID, disk.Path).Score = disk.StorageAvailable - disk.StorageReserved
+}
+
+// finish fills the nodes never reached, and sorts the nodes so that the
+// candidates come first.
+func (t *ScheduleTrace) finish(replica *longhorn.Replica, multiError util.MultiError) {
+	if t == nil {
+		return
+	}
+	t.NodeID = replica.Spec.NodeID
+	t.DiskID = replica.Spec.DiskID
+	if len(multiError) > 0 {
+		t.Error = multiError.Join()
+	}
+	for _, n := range t.Nodes {
+		if n.Filter != "" {
+			continue
+		}
+		switch {
+		case n.Tier == "":
+			n.Filter = ScheduleFilterAntiAffinity
+		case len(n.Disks) == 0:
+			n.Filter = ScheduleFilterNotEvaluated
+		case !n.hasDiskCandidate():
+			n.Filter = ScheduleFilterNodeNoDiskFits
+		}
+	}
+	sort.SliceStable(t.Nodes, func(i, j int) bool {
+		if (t.Nodes[i].Filter == "") != (t.Nodes[j].Filter == "") {
+			return t.Nodes[i].Filter == ""
+		}
+		return t.Nodes[i].Name < t.Nodes[j].Name
+	})
+}
+
+var (
+	scheduleTracesLock sync.RWMutex
+	scheduleTraces     = map[string]*ScheduleTrace{}
+)
+
+func recordScheduleTrace(t *ScheduleTrace) {
+	scheduleTracesLock.Lock()
+	defer scheduleTracesLock.Unlock()
+
+	if _, ok := scheduleTraces[t.Replica]; !ok && len(scheduleTraces) >= maxScheduleTraces {
+		oldest := ""
+		for name, trace := range scheduleTraces {
+			if oldest == "" || trace.Timestamp < scheduleTraces[oldest].Timestamp {
+				oldest = name
+			}
+		}
+		delete(scheduleTraces, oldest)
+	}
+	scheduleTraces[t.Replica] = t
+}
+
+// GetScheduleTrace returns the trace of the last scheduling of the replica
+// on this node, or nil if it's not scheduled here since the manager started.
+func GetScheduleTrace(replicaName string) *ScheduleTrace {
+	scheduleTracesLock.RLock()
+	defer scheduleTracesLock.RUnlock()
+	return scheduleTraces[replicaName]
+}
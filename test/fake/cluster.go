@@ -0,0 +1,84 @@
+// Package fake provides an in-memory Longhorn cluster for the integration
+// tests of the code embedding the manager, without Kubernetes, real nodes or
+// engine binaries. The datastore is backed by fake clientsets, the engines
+// are simulated in memory, and faults can be injected into both.
+package fake
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+
+	"github.com/longhorn/longhorn-manager/datastore"
+	"github.com/longhorn/longhorn-manager/manager"
+
+	lhfake "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned/fake"
+	lhscheme "github.com/longhorn/longhorn-manager/k8s/pkg/client/clientset/versioned/scheme"
+	lhinformerfactory "github.com/longhorn/longhorn-manager/k8s/pkg/client/informers/externalversions"
+)
+
+// Cluster is an in-memory Longhorn cluster. The writes through the datastore
+// or the clientsets are seen by the datastore once the informers catch up,
+// as in a real cluster.
+type Cluster struct {
+	Namespace string
+
+	KubeClient       *fake.Clientset
+	LonghornClient   *lhfake.Clientset
+	ExtensionsClient *apiextensionsfake.Clientset
+	DataStore        *datastore.DataStore
+
+	Engines *EngineClientFactory
+	Faults  *FaultInjector
+}
+
+// NewCluster creates the cluster with the objects, the Longhorn custom
+// resources and the Kubernetes ones, e.g. the nodes and the pods. The
+// informers run until stopCh is closed.
+func NewCluster(namespace string, stopCh <-chan struct{}, objects ...runtime.Object) (*Cluster, error) {
+	lhObjects := []runtime.Object{}
+	kubeObjects := []runtime.Object{}
+	for _, obj := range objects {
+		if _, _, err := lhscheme.Scheme.ObjectKinds(obj); err == nil {
+			lhObjects = append(lhObjects, obj)
+		} else {
+			kubeObjects = append(kubeObjects, obj)
+		}
+	}
+
+	faults := NewFaultInjector()
+	c := &Cluster{
+		Namespace:        namespace,
+		KubeClient:       fake.NewSimpleClientset(kubeObjects...),
+		LonghornClient:   lhfake.NewSimpleClientset(lhObjects...),
+		ExtensionsClient: apiextensionsfake.NewSimpleClientset(),
+		Engines:          NewEngineClientFactory(faults),
+		Faults:           faults,
+	}
+	c.KubeClient.PrependReactor(Any, Any, faults.react)
+	c.LonghornClient.PrependReactor(Any, Any, faults.react)
+
+	kubeInformerFactory := informers.NewSharedInformerFactory(c.KubeClient, 0)
+	lhInformerFactory := lhinformerfactory.NewSharedInformerFactory(c.LonghornClient, 0)
+	c.DataStore = datastore.NewDataStore(lhInformerFactory, c.LonghornClient,
+		kubeInformerFactory, c.KubeClient, c.ExtensionsClient, namespace)
+
+	kubeInformerFactory.Start(stopCh)
+	lhInformerFactory.Start(stopCh)
+	if !c.DataStore.Sync(stopCh) {
+		return nil, fmt.Errorf("failed to sync the informers of the fake cluster")
+	}
+	return c, nil
+}
+
+// NewVolumeManager creates the volume manager of the node on the cluster,
+// reaching the fake engines.
+func (c *Cluster) NewVolumeManager(nodeID string) *manager.VolumeManager {
+	m := manager.NewVolumeManager(nodeID, c.DataStore, nil)
+	m.SetEngineClientFactory(c.Engines)
+	return m
+}
@@ -0,0 +1,577 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/longhorn-manager/engineapi"
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	"github.com/longhorn/longhorn-manager/util"
+)
+
+const volumeHeadName = "volume-head"
+
+// EngineClientFactory creates the clients of the in-memory engines, one per
+// volume, for manager.VolumeManager.SetEngineClientFactory. An engine keeps
+// its replicas, snapshots and backups across the clients, until it's
+// removed. The long running operations, e.g. the backups and the purges,
+// complete right away.
+type EngineClientFactory struct {
+	lock    sync.Mutex
+	engines map[string]*Engine
+	faults  *FaultInjector
+}
+
+func NewEngineClientFactory(faults *FaultInjector) *EngineClientFactory {
+	return &EngineClientFactory{
+		engines: map[string]*Engine{},
+		faults:  faults,
+	}
+}
+
+func (f *EngineClientFactory) NewEngineClient(ctx context.Context, e *longhorn.Engine, log logrus.FieldLogger) (engineapi.EngineClientProxy, error) {
+	if err := f.faults.Inject("NewEngineClient", e.Spec.VolumeName); err != nil {
+		return nil, err
+	}
+	return f.GetEngine(e.Spec.VolumeName, e.Spec.VolumeSize), nil
+}
+
+// Collection returns the engineapi.EngineClientCollection of the engines, for
+// the controllers creating the clients from the engine client requests.
+func (f *EngineClientFactory) Collection() *EngineClientCollection {
+	return &EngineClientCollection{factory: f}
+}
+
+// GetEngine returns the engine of the volume, created on the first call.
+func (f *EngineClientFactory) GetEngine(volumeName string, size int64) *Engine {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	engine, ok := f.engines[volumeName]
+	if !ok {
+		engine = &Engine{
+			volumeName: volumeName,
+			size:       size,
+			replicas:   map[string]*engineapi.Replica{},
+			snapshots: map[string]*longhorn.SnapshotInfo{
+				volumeHeadName: {Name: volumeHeadName, Children: map[string]bool{}},
+			},
+			backups: map[string]*longhorn.EngineBackupStatus{},
+			faults:  f.faults,
+		}
+		f.engines[volumeName] = engine
+	}
+	return engine
+}
+
+// RemoveEngine drops the state of the engine of the volume.
+func (f *EngineClientFactory) RemoveEngine(volumeName string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.engines, volumeName)
+}
+
+// EngineClientCollection implements engineapi.EngineClientCollection with the
+// engines of the factory. The faults of the operation "NewEngineClient" fail
+// the client creation.
+type EngineClientCollection struct {
+	factory *EngineClientFactory
+}
+
+func (c *EngineClientCollection) NewEngineClient(request *engineapi.EngineClientRequest) (engineapi.EngineClientProxy, error) {
+	if err := c.factory.faults.Inject("NewEngineClient", request.VolumeName); err != nil {
+		return nil, err
+	}
+	return c.factory.GetEngine(request.VolumeName, 0), nil
+}
+
+// Engine is the in-memory engine of a volume. It implements
+// engineapi.EngineClientProxy.
+type Engine struct {
+	lock sync.RWMutex
+
+	volumeName                string
+	size                      int64
+	frontendStarted           bool
+	unmapMarkSnapChainRemoved bool
+
+	replicas  map[string]*engineapi.Replica
+	snapshots map[string]*longhorn.SnapshotInfo
+	backups   map[string]*longhorn.EngineBackupStatus
+	restore   *longhorn.RestoreStatus
+
+	faults *FaultInjector
+}
+
+func (e *Engine) Close() {}
+
+func (e *Engine) VersionGet(engine *longhorn.Engine, clientOnly bool) (*engineapi.EngineVersion, error) {
+	if err := e.faults.Inject("VersionGet", e.volumeName); err != nil {
+		return nil, err
+	}
+	details := &longhorn.EngineVersionDetails{
+		Version:          "fake",
+		CLIAPIVersion:    engineapi.CurrentCLIVersion,
+		CLIAPIMinVersion: engineapi.MinCLIVersion,
+	}
+	version := &engineapi.EngineVersion{ClientVersion: details}
+	if !clientOnly {
+		version.ServerVersion = details
+	}
+	return version, nil
+}
+
+func (e *Engine) VolumeGet(*longhorn.Engine) (*engineapi.Volume, error) {
+	if err := e.faults.Inject("VolumeGet", e.volumeName); err != nil {
+		return nil, err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	volume := &engineapi.Volume{
+		Name:                      e.volumeName,
+		Size:                      e.size,
+		ReplicaCount:              len(e.replicas),
+		UnmapMarkSnapChainRemoved: e.unmapMarkSnapChainRemoved,
+	}
+	if e.frontendStarted {
+		volume.Frontend = string(longhorn.VolumeFrontendBlockDev)
+		volume.Endpoint = util.RegularDeviceDirectory + e.volumeName
+	}
+	return volume, nil
+}
+
+func (e *Engine) VolumeExpand(engine *longhorn.Engine) error {
+	if err := e.faults.Inject("VolumeExpand", e.volumeName); err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if engine.Spec.VolumeSize < e.size {
+		return fmt.Errorf("cannot shrink volume %v from %v to %v", e.volumeName, e.size, engine.Spec.VolumeSize)
+	}
+	e.size = engine.Spec.VolumeSize
+	return nil
+}
+
+func (e *Engine) VolumeFrontendStart(*longhorn.Engine) error {
+	return e.setFrontendStarted("VolumeFrontendStart", true)
+}
+
+func (e *Engine) VolumeFrontendShutdown(*longhorn.Engine) error {
+	return e.setFrontendStarted("VolumeFrontendShutdown", false)
+}
+
+func (e *Engine) setFrontendStarted(operation string, started bool) error {
+	if err := e.faults.Inject(operation, e.volumeName); err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.frontendStarted = started
+	return nil
+}
+
+func (e *Engine) VolumeUnmapMarkSnapChainRemovedSet(engine *longhorn.Engine) error {
+	if err := e.faults.Inject("VolumeUnmapMarkSnapChainRemovedSet", e.volumeName); err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.unmapMarkSnapChainRemoved = engine.Spec.UnmapMarkSnapChainRemovedEnabled
+	return nil
+}
+
+func (e *Engine) ReplicaList(*longhorn.Engine) (map[string]*engineapi.Replica, error) {
+	if err := e.faults.Inject("ReplicaList", e.volumeName); err != nil {
+		return nil, err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	replicas := map[string]*engineapi.Replica{}
+	for url, replica := range e.replicas {
+		r := *replica
+		replicas[url] = &r
+	}
+	return replicas, nil
+}
+
+func (e *Engine) ReplicaAdd(engine *longhorn.Engine, url string, isRestoreVolume, fastSync bool, replicaFileSyncHTTPClientTimeout int64) error {
+	if err := e.faults.Inject("ReplicaAdd", e.volumeName); err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if _, ok := e.replicas[url]; ok {
+		return fmt.Errorf("duplicate replica %v already exists", url)
+	}
+	e.replicas[url] = &engineapi.Replica{URL: url, Mode: longhorn.ReplicaModeRW}
+	return nil
+}
+
+func (e *Engine) ReplicaRemove(engine *longhorn.Engine, url string) error {
+	if err := e.faults.Inject("ReplicaRemove", e.volumeName); err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if _, ok := e.replicas[url]; !ok {
+		return fmt.Errorf("unable to find replica %v", url)
+	}
+	delete(e.replicas, url)
+	return nil
+}
+
+func (e *Engine) ReplicaRebuildStatus(*longhorn.Engine) (map[string]*longhorn.RebuildStatus, error) {
+	if err := e.faults.Inject("ReplicaRebuildStatus", e.volumeName); err != nil {
+		return nil, err
+	}
+	return map[string]*longhorn.RebuildStatus{}, nil
+}
+
+func (e *Engine) ReplicaRebuildVerify(engine *longhorn.Engine, url string) error {
+	return e.ReplicaModeUpdate(engine, url, string(longhorn.ReplicaModeRW))
+}
+
+func (e *Engine) ReplicaModeUpdate(engine *longhorn.Engine, url, mode string) error {
+	if err := e.faults.Inject("ReplicaModeUpdate", e.volumeName); err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	replica, ok := e.replicas[url]
+	if !ok {
+		return fmt.Errorf("unable to find replica %v", url)
+	}
+	replica.Mode = longhorn.ReplicaMode(mode)
+	return nil
+}
+
+// Write adds the size of the data written to the volume head, which moves to
+// the snapshot created next.
+func (e *Engine) Write(size int64) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	head := e.snapshots[volumeHeadName]
+	written, _ := util.ConvertSize(head.Size)
+	head.Size = strconv.FormatInt(written+size, 10)
+}
+
+// SetReplicaMode changes the mode of the replica without a client, e.g. to
+// simulate a failed replica with longhorn.ReplicaModeERR.
+func (e *Engine) SetReplicaMode(url string, mode longhorn.ReplicaMode) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	replica, ok := e.replicas[url]
+	if !ok {
+		return fmt.Errorf("unable to find replica %v", url)
+	}
+	replica.Mode = mode
+	return nil
+}
+
+func (e *Engine) SnapshotCreate(engine *longhorn.Engine, name string, labels map[string]string) (string, error) {
+	if err := e.faults.Inject("SnapshotCreate", e.volumeName); err != nil {
+		return "", err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if name == "" {
+		name = util.UUID()
+	}
+	if _, ok := e.snapshots[name]; ok {
+		return "", fmt.Errorf("snapshot %v already exists", name)
+	}
+
+	head := e.snapshots[volumeHeadName]
+	snapshot := &longhorn.SnapshotInfo{
+		Name:        name,
+		Parent:      head.Parent,
+		Children:    map[string]bool{volumeHeadName: true},
+		UserCreated: true,
+		Created:     util.Now(),
+		Size:        "0",
+		Labels:      labels,
+	}
+	// The data written to the volume head moves to the new snapshot
+	if head.Size != "" {
+		snapshot.Size = head.Size
+	}
+	head.Size = ""
+	if parent, ok := e.snapshots[head.Parent]; ok {
+		delete(parent.Children, volumeHeadName)
+		parent.Children[name] = true
+	}
+	head.Parent = name
+	e.snapshots[name] = snapshot
+	return name, nil
+}
+
+func (e *Engine) SnapshotList(*longhorn.Engine) (map[string]*longhorn.SnapshotInfo, error) {
+	if err := e.faults.Inject("SnapshotList", e.volumeName); err != nil {
+		return nil, err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	snapshots := map[string]*longhorn.SnapshotInfo{}
+	for name, snapshot := range e.snapshots {
+		snapshots[name] = copySnapshotInfo(snapshot)
+	}
+	return snapshots, nil
+}
+
+func (e *Engine) SnapshotGet(engine *longhorn.Engine, name string) (*longhorn.SnapshotInfo, error) {
+	if err := e.faults.Inject("SnapshotGet", e.volumeName); err != nil {
+		return nil, err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	snapshot, ok := e.snapshots[name]
+	if !ok {
+		return nil, nil
+	}
+	return copySnapshotInfo(snapshot), nil
+}
+
+func copySnapshotInfo(snapshot *longhorn.SnapshotInfo) *longhorn.SnapshotInfo {
+	s := *snapshot
+	s.Children = map[string]bool{}
+	for child := range snapshot.Children {
+		s.Children[child] = true
+	}
+	s.Labels = map[string]string{}
+	for k, v := range snapshot.Labels {
+		s.Labels[k] = v
+	}
+	return &s
+}
+
+func (e *Engine) SnapshotDelete(engine *longhorn.Engine, name string) error {
+	if err := e.faults.Inject("SnapshotDelete", e.volumeName); err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	snapshot, ok := e.snapshots[name]
+	if !ok || name == volumeHeadName {
+		return fmt.Errorf("unable to find snapshot %v", name)
+	}
+	snapshot.Removed = true
+	return nil
+}
+
+func (e *Engine) SnapshotRevert(engine *longhorn.Engine, name string) error {
+	if err := e.faults.Inject("SnapshotRevert", e.volumeName); err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	snapshot, ok := e.snapshots[name]
+	if !ok || snapshot.Removed || name == volumeHeadName {
+		return fmt.Errorf("unable to find snapshot %v", name)
+	}
+	head := e.snapshots[volumeHeadName]
+	if parent, ok := e.snapshots[head.Parent]; ok {
+		delete(parent.Children, volumeHeadName)
+	}
+	snapshot.Children[volumeHeadName] = true
+	head.Parent = name
+	head.Size = ""
+	return nil
+}
+
+// SnapshotPurge removes the snapshots marked as removed, and links their
+// children to their parents.
+func (e *Engine) SnapshotPurge(*longhorn.Engine) error {
+	if err := e.faults.Inject("SnapshotPurge", e.volumeName); err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for name, snapshot := range e.snapshots {
+		if !snapshot.Removed {
+			continue
+		}
+		parent := e.snapshots[snapshot.Parent]
+		if parent != nil {
+			delete(parent.Children, name)
+		}
+		for child := range snapshot.Children {
+			e.snapshots[child].Parent = snapshot.Parent
+			if parent != nil {
+				parent.Children[child] = true
+			}
+		}
+		delete(e.snapshots, name)
+	}
+	return nil
+}
+
+func (e *Engine) SnapshotPurgeStatus(*longhorn.Engine) (map[string]*longhorn.PurgeStatus, error) {
+	if err := e.faults.Inject("SnapshotPurgeStatus", e.volumeName); err != nil {
+		return nil, err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	statuses := map[string]*longhorn.PurgeStatus{}
+	for url := range e.replicas {
+		statuses[url] = &longhorn.PurgeStatus{Progress: 100, State: engineapi.ProcessStateComplete}
+	}
+	return statuses, nil
+}
+
+func (e *Engine) SnapshotBackup(engine *longhorn.Engine, backupName, snapName, backupTarget,
+	backingImageName, backingImageChecksum, compressionMethod string, concurrentLimit int,
+	labels, credential map[string]string) (string, string, error) {
+	if err := e.faults.Inject("SnapshotBackup", e.volumeName); err != nil {
+		return "", "", err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if snapshot, ok := e.snapshots[snapName]; !ok || snapshot.Removed {
+		return "", "", fmt.Errorf("unable to find snapshot %v", snapName)
+	}
+	replicaAddress := ""
+	for url := range e.replicas {
+		replicaAddress = url
+		break
+	}
+	e.backups[backupName] = &longhorn.EngineBackupStatus{
+		Progress:       100,
+		BackupURL:      fmt.Sprintf("%v?backup=%v&volume=%v", backupTarget, backupName, e.volumeName),
+		SnapshotName:   snapName,
+		State:          engineapi.ProcessStateComplete,
+		ReplicaAddress: replicaAddress,
+	}
+	return backupName, replicaAddress, nil
+}
+
+func (e *Engine) SnapshotBackupStatus(engine *longhorn.Engine, backupName, replicaAddress string) (*longhorn.EngineBackupStatus, error) {
+	if err := e.faults.Inject("SnapshotBackupStatus", e.volumeName); err != nil {
+		return nil, err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	status, ok := e.backups[backupName]
+	if !ok {
+		return nil, nil
+	}
+	s := *status
+	return &s, nil
+}
+
+func (e *Engine) SnapshotCloneStatus(*longhorn.Engine) (map[string]*longhorn.SnapshotCloneStatus, error) {
+	if err := e.faults.Inject("SnapshotCloneStatus", e.volumeName); err != nil {
+		return nil, err
+	}
+	return map[string]*longhorn.SnapshotCloneStatus{}, nil
+}
+
+func (e *Engine) SnapshotClone(engine *longhorn.Engine, snapshotName, fromControllerAddress string, fileSyncHTTPClientTimeout int64) error {
+	if err := e.faults.Inject("SnapshotClone", e.volumeName); err != nil {
+		return err
+	}
+	_, err := e.SnapshotCreate(engine, snapshotName, nil)
+	return err
+}
+
+func (e *Engine) SnapshotHash(engine *longhorn.Engine, snapshotName string, rehash bool) error {
+	if err := e.faults.Inject("SnapshotHash", e.volumeName); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (e *Engine) SnapshotHashStatus(engine *longhorn.Engine, snapshotName string) (map[string]*longhorn.HashStatus, error) {
+	if err := e.faults.Inject("SnapshotHashStatus", e.volumeName); err != nil {
+		return nil, err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	statuses := map[string]*longhorn.HashStatus{}
+	for url := range e.replicas {
+		statuses[url] = &longhorn.HashStatus{
+			State:    engineapi.ProcessStateComplete,
+			Checksum: util.GetStringChecksum(e.volumeName + "/" + snapshotName),
+		}
+	}
+	return statuses, nil
+}
+
+// BackupRestore restores the backup right away. The restoration only
+// records the last restored backup.
+func (e *Engine) BackupRestore(engine *longhorn.Engine, backupTarget, backupName, backupVolume, lastRestored string, credential map[string]string, concurrentLimit int) error {
+	if err := e.faults.Inject("BackupRestore", e.volumeName); err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.restore = &longhorn.RestoreStatus{
+		LastRestored: backupName,
+		Progress:     100,
+		State:        engineapi.ProcessStateComplete,
+	}
+	return nil
+}
+
+func (e *Engine) BackupRestoreStatus(*longhorn.Engine) (map[string]*longhorn.RestoreStatus, error) {
+	if err := e.faults.Inject("BackupRestoreStatus", e.volumeName); err != nil {
+		return nil, err
+	}
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	statuses := map[string]*longhorn.RestoreStatus{}
+	if e.restore == nil {
+		return statuses, nil
+	}
+	for url := range e.replicas {
+		s := *e.restore
+		statuses[url] = &s
+	}
+	return statuses, nil
+}
+
+func (e *Engine) MetricsGet(*longhorn.Engine) (*engineapi.Metrics, error) {
+	if err := e.faults.Inject("MetricsGet", e.volumeName); err != nil {
+		return nil, err
+	}
+	return &engineapi.Metrics{}, nil
+}
+
+// snapshotCount returns the number of the snapshots not removed, for the
+// tests.
+func (e *Engine) snapshotCount() int {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	count := 0
+	for name, snapshot := range e.snapshots {
+		if name != volumeHeadName && !snapshot.Removed {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,134 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/engineapi"
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+const testNamespace = "longhorn-system"
+
+func TestClusterFaults(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	c, err := NewCluster(testNamespace, stopCh)
+	assert.Nil(err)
+
+	injected := fmt.Errorf("injected")
+	c.Faults.Add(Fault{Operation: "create", Target: "volumes", Err: injected, Times: 1})
+
+	volume := &longhorn.Volume{
+		ObjectMeta: metav1.ObjectMeta{Name: "vol", Namespace: testNamespace},
+	}
+	_, err = c.LonghornClient.LonghornV1beta2().Volumes(testNamespace).Create(context.TODO(), volume, metav1.CreateOptions{})
+	assert.Equal(injected, err)
+	// The fault is removed once injected
+	_, err = c.LonghornClient.LonghornV1beta2().Volumes(testNamespace).Create(context.TODO(), volume, metav1.CreateOptions{})
+	assert.Nil(err)
+}
+
+func TestEngineSnapshots(t *testing.T) {
+	assert := require.New(t)
+
+	faults := NewFaultInjector()
+	engines := NewEngineClientFactory(faults)
+	e := &longhorn.Engine{}
+	e.Spec.VolumeName = "vol"
+	e.Spec.VolumeSize = 1024
+
+	client, err := engines.NewEngineClient(context.Background(), e, nil)
+	assert.Nil(err)
+	assert.Nil(client.ReplicaAdd(e, "tcp://10.0.0.1:10000", false, false, 0))
+
+	_, err = client.SnapshotCreate(e, "snap1", nil)
+	assert.Nil(err)
+	_, err = client.SnapshotCreate(e, "snap2", nil)
+	assert.Nil(err)
+
+	faults.Add(Fault{Operation: "SnapshotCreate", Target: Any, Err: fmt.Errorf("injected")})
+	_, err = client.SnapshotCreate(e, "snap3", nil)
+	assert.NotNil(err)
+	faults.Reset()
+
+	assert.Nil(client.SnapshotDelete(e, "snap1"))
+	assert.Nil(client.SnapshotPurge(e))
+	snapshots, err := client.SnapshotList(e)
+	assert.Nil(err)
+	assert.Len(snapshots, 2)
+	assert.Equal("", snapshots["snap2"].Parent)
+	assert.Equal("snap2", snapshots[volumeHeadName].Parent)
+	assert.Equal(1, engines.GetEngine("vol", 0).snapshotCount())
+
+	// The state is kept across the clients
+	client, err = engines.NewEngineClient(context.Background(), e, nil)
+	assert.Nil(err)
+	replicas, err := client.ReplicaList(e)
+	assert.Nil(err)
+	assert.Len(replicas, 1)
+}
+
+func TestFaultProbability(t *testing.T) {
+	assert := require.New(t)
+
+	faults := NewFaultInjector()
+	faults.Add(Fault{Operation: Any, Target: "vol", Err: fmt.Errorf("injected"), Probability: 0.5})
+
+	failed := 0
+	for i := 0; i < 1000; i++ {
+		if faults.Inject("VolumeGet", "vol") != nil {
+			failed++
+		}
+		assert.Nil(faults.Inject("VolumeGet", "other"))
+	}
+	assert.True(failed > 0 && failed < 1000)
+}
+
+func TestFaultSeed(t *testing.T) {
+	assert := require.New(t)
+
+	inject := func() []bool {
+		faults := NewFaultInjector()
+		faults.SetSeed(42)
+		faults.Add(Fault{Operation: Any, Target: Any, Err: fmt.Errorf("injected"), Probability: 0.5})
+		failed := []bool{}
+		for i := 0; i < 100; i++ {
+			failed = append(failed, faults.Inject("VolumeGet", "vol") != nil)
+		}
+		return failed
+	}
+	assert.Equal(inject(), inject())
+}
+
+func TestEngineClientCollection(t *testing.T) {
+	assert := require.New(t)
+
+	faults := NewFaultInjector()
+	engines := NewEngineClientFactory(faults)
+	var collection engineapi.EngineClientCollection = engines.Collection()
+	e := &longhorn.Engine{}
+	e.Spec.VolumeName = "vol"
+	request := &engineapi.EngineClientRequest{VolumeName: "vol", EngineImage: "image"}
+
+	faults.Add(Fault{Operation: "NewEngineClient", Target: "vol", Err: fmt.Errorf("injected"), Times: 1})
+	_, err := collection.NewEngineClient(request)
+	assert.NotNil(err)
+
+	client, err := collection.NewEngineClient(request)
+	assert.Nil(err)
+	defer client.Close()
+	_, err = client.SnapshotCreate(e, "snap1", nil)
+	assert.Nil(err)
+	// The clients of the collection share the engines of the factory
+	_, err = engines.GetEngine("vol", 0).SnapshotGet(e, "snap1")
+	assert.Nil(err)
+}
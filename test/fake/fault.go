@@ -0,0 +1,116 @@
+package fake
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// Any matches any operation or any target of a fault.
+const Any = "*"
+
+// Fault is injected into the matching operations. For the clientsets, the
+// operation is the verb, e.g. "update", and the target is the resource,
+// e.g. "volumes". For the fake engines, the operation is the method name,
+// e.g. "SnapshotCreate", and the target is the volume name.
+type Fault struct {
+	Operation string
+	Target    string
+
+	// Latency delays the operation before it's done or failed.
+	Latency time.Duration
+	// Err fails the operation without doing it, if not nil.
+	Err error
+	// Probability of the fault per matching operation, 0 means always,
+	// which makes partial failures among a batch of operations.
+	Probability float64
+	// Times the fault is injected before it's removed, 0 means forever.
+	Times int
+}
+
+// FaultInjector holds the faults injected into the fake clientsets and
+// engines of a cluster.
+type FaultInjector struct {
+	lock   sync.Mutex
+	faults []*Fault
+	random *rand.Rand
+}
+
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetSeed reseeds the random source of the fault probabilities, so that a
+// test injecting the probable faults is repeatable.
+func (fi *FaultInjector) SetSeed(seed int64) {
+	fi.lock.Lock()
+	defer fi.lock.Unlock()
+	fi.random = rand.New(rand.NewSource(seed))
+}
+
+// Add injects the fault. The faults are checked in the order they're added,
+// and the first matching one applies.
+func (fi *FaultInjector) Add(fault Fault) {
+	fi.lock.Lock()
+	defer fi.lock.Unlock()
+	fi.faults = append(fi.faults, &fault)
+}
+
+// Reset removes all the faults.
+func (fi *FaultInjector) Reset() {
+	fi.lock.Lock()
+	defer fi.lock.Unlock()
+	fi.faults = nil
+}
+
+// Inject applies the first fault matching the operation on the target, and
+// returns the error of the fault if any.
+func (fi *FaultInjector) Inject(operation, target string) error {
+	fault := fi.match(operation, target)
+	if fault == nil {
+		return nil
+	}
+	if fault.Latency > 0 {
+		time.Sleep(fault.Latency)
+	}
+	return fault.Err
+}
+
+func (fi *FaultInjector) match(operation, target string) *Fault {
+	fi.lock.Lock()
+	defer fi.lock.Unlock()
+
+	for i, fault := range fi.faults {
+		if (fault.Operation != Any && fault.Operation != operation) ||
+			(fault.Target != Any && fault.Target != target) {
+			continue
+		}
+		if fault.Probability > 0 && fi.random.Float64() >= fault.Probability {
+			return nil
+		}
+		matched := *fault
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				fi.faults = append(fi.faults[:i], fi.faults[i+1:]...)
+			}
+		}
+		return &matched
+	}
+	return nil
+}
+
+// react is the clientset reactor. The action is passed on to the object
+// tracker unless the fault fails it.
+func (fi *FaultInjector) react(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+	if err := fi.Inject(action.GetVerb(), action.GetResource().Resource); err != nil {
+		return true, nil, err
+	}
+	return false, nil, nil
+}
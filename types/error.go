@@ -0,0 +1,71 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorReason is a stable, machine-readable cause attached to an error so
+// that API clients can branch on it and localize the message.
+type ErrorReason string
+
+const (
+	ErrorReasonNotFound            = ErrorReason("NotFound")
+	ErrorReasonAlreadyExists       = ErrorReason("AlreadyExists")
+	ErrorReasonConflict            = ErrorReason("Conflict")
+	ErrorReasonInvalidParameter    = ErrorReason("InvalidParameter")
+	ErrorReasonInvalidState        = ErrorReason("InvalidState")
+	ErrorReasonInsufficientStorage = ErrorReason("InsufficientStorage")
+	ErrorReasonUnauthorized        = ErrorReason("Unauthorized")
+	ErrorReasonForbidden           = ErrorReason("Forbidden")
+	ErrorReasonForwardFailed       = ErrorReason("ForwardFailed")
+	ErrorReasonUnavailable         = ErrorReason("Unavailable")
+	ErrorReasonTimeout             = ErrorReason("Timeout")
+	ErrorReasonQuotaExceeded       = ErrorReason("QuotaExceeded")
+	ErrorReasonEngineUnavailable   = ErrorReason("EngineUnavailable")
+
+	ErrorParameterName      = "name"
+	ErrorParameterKind      = "kind"
+	ErrorParameterParameter = "parameter"
+	ErrorParameterValue     = "value"
+	ErrorParameterState     = "state"
+	ErrorParameterNode      = "node"
+	ErrorParameterDisk      = "disk"
+	ErrorParameterNeeded    = "needed"
+	ErrorParameterAvailable = "available"
+	ErrorParameterLimit     = "limit"
+	ErrorParameterEngine    = "engine"
+	ErrorParameterTenant    = "tenant"
+)
+
+type ReasonError struct {
+	Reason     ErrorReason
+	Parameters map[string]string
+
+	message string
+}
+
+func NewReasonError(reason ErrorReason, parameters map[string]string, format string, args ...interface{}) *ReasonError {
+	if parameters == nil {
+		parameters = map[string]string{}
+	}
+	return &ReasonError{
+		Reason:     reason,
+		Parameters: parameters,
+		message:    fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *ReasonError) Error() string {
+	return e.message
+}
+
+// GetReasonError returns the first ReasonError in the chain of err, or nil if
+// there is none.
+func GetReasonError(err error) *ReasonError {
+	var reasonErr *ReasonError
+	if errors.As(err, &reasonErr) {
+		return reasonErr
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package types
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetReasonError(t *testing.T) {
+	reasonErr := NewReasonError(ErrorReasonNotFound, nil, "volume %v not found", "vol")
+	require.Equal(t, "volume vol not found", reasonErr.Error())
+	require.NotNil(t, reasonErr.Parameters)
+
+	tests := map[string]struct {
+		err    error
+		expect *ReasonError
+	}{
+		"reason error": {
+			err:    reasonErr,
+			expect: reasonErr,
+		},
+		"wrapped": {
+			err:    errors.Wrapf(errors.Wrap(reasonErr, "unable to get volume"), "unable to attach volume"),
+			expect: reasonErr,
+		},
+		"wrapped by fmt": {
+			err:    fmt.Errorf("unable to get volume: %w", reasonErr),
+			expect: reasonErr,
+		},
+		"no reason": {
+			err: errors.Wrap(fmt.Errorf("failed"), "unable to get volume"),
+		},
+		"nil": {},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expect, GetReasonError(tc.err))
+		})
+	}
+}
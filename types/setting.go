@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -9,11 +10,11 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/robfig/cron"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
 	"github.com/longhorn/longhorn-manager/meta"
@@ -31,6 +32,9 @@ const (
 	SettingTypeInt        = SettingType("int")
 	SettingTypeBool       = SettingType("bool")
 	SettingTypeDeprecated = SettingType("deprecated")
+
+	ValueIntRangeMinimum = "minimum"
+	ValueIntRangeMaximum = "maximum"
 )
 
 type SettingName string
@@ -105,6 +109,35 @@ const (
 	SettingNameBackupCompressionMethod                                  = SettingName("backup-compression-method")
 	SettingNameBackupConcurrentLimit                                    = SettingName("backup-concurrent-limit")
 	SettingNameRestoreConcurrentLimit                                   = SettingName("restore-concurrent-limit")
+	SettingNameReplicaDataDirectoryNameFormat                           = SettingName("replica-data-directory-name-format")
+	SettingNameFailedReplicaDataCleanupGracePeriod                      = SettingName("failed-replica-data-cleanup-grace-period")
+	SettingNameReplicaZoneNetworkCost                                   = SettingName("replica-zone-network-cost")
+	SettingNameAPIAuthentication                                        = SettingName("api-authentication")
+	SettingNameVolumePolicyWebhooks                                     = SettingName("volume-policy-webhooks")
+	SettingNameCloudTagSyncProvider                                     = SettingName("cloud-tag-sync-provider")
+	SettingNameSnapshotIntegritySweepWeeklyBudget                       = SettingName("snapshot-integrity-sweep-weekly-budget")
+	SettingNameEngineQueryTimeout                                       = SettingName("engine-query-timeout")
+	SettingNameEngineOperationTimeout                                   = SettingName("engine-operation-timeout")
+	SettingNameEngineStatusCacheTTL                                     = SettingName("engine-status-cache-ttl")
+	SettingNameEngineStatusPollConcurrency                              = SettingName("engine-status-poll-concurrency")
+	SettingNameReplicaCountAutoScaling                                  = SettingName("replica-count-auto-scaling")
+	SettingNameMaxAttachedVolumesPerNode                                = SettingName("max-attached-volumes-per-node")
+	SettingNameNodeGroupSettingOverrides                                = SettingName("node-group-setting-overrides")
+	SettingNameAirGappedMode                                            = SettingName("air-gapped-mode")
+	SettingNameAirGappedRegistry                                        = SettingName("air-gapped-registry")
+	SettingNameCompletedJobRetentionPeriod                              = SettingName("completed-job-retention-period")
+	SettingNameEventRetentionCount                                      = SettingName("event-retention-count")
+	SettingNameEventRetentionPeriod                                     = SettingName("event-retention-period")
+	SettingNameReplicaRebuildOffPeakHours                               = SettingName("replica-rebuild-off-peak-hours")
+	SettingNameReplicaRebuildBandwidthLimit                             = SettingName("replica-rebuild-bandwidth-limit")
+	SettingNameVolumeFrontendIOPSLimit                                  = SettingName("volume-frontend-iops-limit")
+	SettingNameVolumeFrontendBandwidthLimit                             = SettingName("volume-frontend-bandwidth-limit")
+	SettingNameFilesystemCheckAfterAttach                               = SettingName("filesystem-check-after-attach")
+	SettingNameInstanceProcessRestartLimit                              = SettingName("instance-process-restart-limit")
+	SettingNameNotificationWebhookSecret                                = SettingName("notification-webhook-secret")
+	SettingNameNotificationWebhookFormat                                = SettingName("notification-webhook-format")
+	SettingNameNotificationDegradedVolumeThreshold                      = SettingName("notification-degraded-volume-threshold")
+	SettingNameTenantQuotas                                             = SettingName("tenant-quotas")
 )
 
 var (
@@ -178,6 +211,35 @@ var (
 		SettingNameBackupCompressionMethod,
 		SettingNameBackupConcurrentLimit,
 		SettingNameRestoreConcurrentLimit,
+		SettingNameReplicaDataDirectoryNameFormat,
+		SettingNameFailedReplicaDataCleanupGracePeriod,
+		SettingNameReplicaZoneNetworkCost,
+		SettingNameAPIAuthentication,
+		SettingNameVolumePolicyWebhooks,
+		SettingNameCloudTagSyncProvider,
+		SettingNameSnapshotIntegritySweepWeeklyBudget,
+		SettingNameEngineQueryTimeout,
+		SettingNameEngineOperationTimeout,
+		SettingNameEngineStatusCacheTTL,
+		SettingNameEngineStatusPollConcurrency,
+		SettingNameReplicaCountAutoScaling,
+		SettingNameMaxAttachedVolumesPerNode,
+		SettingNameNodeGroupSettingOverrides,
+		SettingNameAirGappedMode,
+		SettingNameAirGappedRegistry,
+		SettingNameCompletedJobRetentionPeriod,
+		SettingNameEventRetentionCount,
+		SettingNameEventRetentionPeriod,
+		SettingNameReplicaRebuildOffPeakHours,
+		SettingNameReplicaRebuildBandwidthLimit,
+		SettingNameVolumeFrontendIOPSLimit,
+		SettingNameVolumeFrontendBandwidthLimit,
+		SettingNameFilesystemCheckAfterAttach,
+		SettingNameInstanceProcessRestartLimit,
+		SettingNameNotificationWebhookSecret,
+		SettingNameNotificationWebhookFormat,
+		SettingNameNotificationDegradedVolumeThreshold,
+		SettingNameTenantQuotas,
 	}
 )
 
@@ -201,6 +263,12 @@ type SettingDefinition struct {
 	ReadOnly    bool            `json:"readOnly"`
 	Default     string          `json:"default"`
 	Choices     []string        `json:"options,omitempty"` // +optional
+	// The allowed range of an int setting, keyed by ValueIntRangeMinimum and
+	// ValueIntRangeMaximum. A missing key means no limit.
+	ValueIntRange map[string]int `json:"range,omitempty"` // +optional
+	// The setting restarts the system managed pods when it's changed. The
+	// others take effect without restarting anything.
+	RequiresRestart bool `json:"requiresRestart"`
 }
 
 var settingDefinitionsLock sync.RWMutex
@@ -276,6 +344,35 @@ var (
 		SettingNameBackupCompressionMethod:                                  SettingDefinitionBackupCompressionMethod,
 		SettingNameBackupConcurrentLimit:                                    SettingDefinitionBackupConcurrentLimit,
 		SettingNameRestoreConcurrentLimit:                                   SettingDefinitionRestoreConcurrentLimit,
+		SettingNameReplicaDataDirectoryNameFormat:                           SettingDefinitionReplicaDataDirectoryNameFormat,
+		SettingNameFailedReplicaDataCleanupGracePeriod:                      SettingDefinitionFailedReplicaDataCleanupGracePeriod,
+		SettingNameReplicaZoneNetworkCost:                                   SettingDefinitionReplicaZoneNetworkCost,
+		SettingNameAPIAuthentication:                                        SettingDefinitionAPIAuthentication,
+		SettingNameVolumePolicyWebhooks:                                     SettingDefinitionVolumePolicyWebhooks,
+		SettingNameCloudTagSyncProvider:                                     SettingDefinitionCloudTagSyncProvider,
+		SettingNameSnapshotIntegritySweepWeeklyBudget:                       SettingDefinitionSnapshotIntegritySweepWeeklyBudget,
+		SettingNameEngineQueryTimeout:                                       SettingDefinitionEngineQueryTimeout,
+		SettingNameEngineOperationTimeout:                                   SettingDefinitionEngineOperationTimeout,
+		SettingNameEngineStatusCacheTTL:                                     SettingDefinitionEngineStatusCacheTTL,
+		SettingNameEngineStatusPollConcurrency:                              SettingDefinitionEngineStatusPollConcurrency,
+		SettingNameReplicaCountAutoScaling:                                  SettingDefinitionReplicaCountAutoScaling,
+		SettingNameMaxAttachedVolumesPerNode:                                SettingDefinitionMaxAttachedVolumesPerNode,
+		SettingNameNodeGroupSettingOverrides:                                SettingDefinitionNodeGroupSettingOverrides,
+		SettingNameAirGappedMode:                                            SettingDefinitionAirGappedMode,
+		SettingNameAirGappedRegistry:                                        SettingDefinitionAirGappedRegistry,
+		SettingNameCompletedJobRetentionPeriod:                              SettingDefinitionCompletedJobRetentionPeriod,
+		SettingNameEventRetentionCount:                                      SettingDefinitionEventRetentionCount,
+		SettingNameEventRetentionPeriod:                                     SettingDefinitionEventRetentionPeriod,
+		SettingNameReplicaRebuildOffPeakHours:                               SettingDefinitionReplicaRebuildOffPeakHours,
+		SettingNameReplicaRebuildBandwidthLimit:                             SettingDefinitionReplicaRebuildBandwidthLimit,
+		SettingNameVolumeFrontendIOPSLimit:                                  SettingDefinitionVolumeFrontendIOPSLimit,
+		SettingNameVolumeFrontendBandwidthLimit:                             SettingDefinitionVolumeFrontendBandwidthLimit,
+		SettingNameFilesystemCheckAfterAttach:                               SettingDefinitionFilesystemCheckAfterAttach,
+		SettingNameInstanceProcessRestartLimit:                              SettingDefinitionInstanceProcessRestartLimit,
+		SettingNameNotificationWebhookSecret:                                SettingDefinitionNotificationWebhookSecret,
+		SettingNameNotificationWebhookFormat:                                SettingDefinitionNotificationWebhookFormat,
+		SettingNameNotificationDegradedVolumeThreshold:                      SettingDefinitionNotificationDegradedVolumeThreshold,
+		SettingNameTenantQuotas:                                             SettingDefinitionTenantQuotas,
 	}
 
 	SettingDefinitionBackupTarget = SettingDefinition{
@@ -309,13 +406,14 @@ var (
 	}
 
 	SettingDefinitionBackupstorePollInterval = SettingDefinition{
-		DisplayName: "Backupstore Poll Interval",
-		Description: "In seconds. The backupstore poll interval determines how often Longhorn checks the backupstore for new backups. Set to 0 to disable the polling.",
-		Category:    SettingCategoryBackup,
-		Type:        SettingTypeInt,
-		Required:    true,
-		ReadOnly:    false,
-		Default:     "300",
+		DisplayName:   "Backupstore Poll Interval",
+		Description:   "In seconds. The backupstore poll interval determines how often Longhorn checks the backupstore for new backups. Set to 0 to disable the polling.",
+		Category:      SettingCategoryBackup,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "300",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
 	}
 
 	SettingDefinitionFailedBackupTTL = SettingDefinition{
@@ -324,11 +422,12 @@ var (
 			"Failed backups will be checked and cleaned up during backupstore polling which is controlled by **Backupstore Poll Interval** setting.\n" +
 			"Hence this value determines the minimal wait interval of the cleanup. And the actual cleanup interval is multiple of **Backupstore Poll Interval**.\n" +
 			"Disabling **Backupstore Poll Interval** also means to disable failed backup auto-deletion.\n\n",
-		Category: SettingCategoryBackup,
-		Type:     SettingTypeInt,
-		Required: true,
-		ReadOnly: false,
-		Default:  "1440",
+		Category:      SettingCategoryBackup,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "1440",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
 	}
 
 	SettingDefinitionRestoreVolumeRecurringJobs = SettingDefinition{
@@ -449,33 +548,36 @@ var (
 	}
 
 	SettingDefinitionStorageOverProvisioningPercentage = SettingDefinition{
-		DisplayName: "Storage Over Provisioning Percentage",
-		Description: "The over-provisioning percentage defines how much storage can be allocated relative to the hard drive's capacity",
-		Category:    SettingCategoryScheduling,
-		Type:        SettingTypeInt,
-		Required:    true,
-		ReadOnly:    false,
-		Default:     "200",
+		DisplayName:   "Storage Over Provisioning Percentage",
+		Description:   "The over-provisioning percentage defines how much storage can be allocated relative to the hard drive's capacity",
+		Category:      SettingCategoryScheduling,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "200",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
 	}
 
 	SettingDefinitionStorageMinimalAvailablePercentage = SettingDefinition{
-		DisplayName: "Storage Minimal Available Percentage",
-		Description: "If the minimum available disk capacity exceeds the actual percentage of available disk capacity, the disk becomes unschedulable until more space is freed up.",
-		Category:    SettingCategoryScheduling,
-		Type:        SettingTypeInt,
-		Required:    true,
-		ReadOnly:    false,
-		Default:     "25",
+		DisplayName:   "Storage Minimal Available Percentage",
+		Description:   "If the minimum available disk capacity exceeds the actual percentage of available disk capacity, the disk becomes unschedulable until more space is freed up.",
+		Category:      SettingCategoryScheduling,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "25",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0, ValueIntRangeMaximum: 100},
 	}
 
 	SettingDefinitionStorageReservedPercentageForDefaultDisk = SettingDefinition{
-		DisplayName: "Storage Reserved Percentage For Default Disk",
-		Description: "The reserved percentage specifies the percentage of disk space that will not be allocated to the default disk on each new Longhorn node",
-		Category:    SettingCategoryScheduling,
-		Type:        SettingTypeInt,
-		Required:    true,
-		ReadOnly:    false,
-		Default:     "30",
+		DisplayName:   "Storage Reserved Percentage For Default Disk",
+		Description:   "The reserved percentage specifies the percentage of disk space that will not be allocated to the default disk on each new Longhorn node",
+		Category:      SettingCategoryScheduling,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "30",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0, ValueIntRangeMaximum: 100},
 	}
 
 	SettingDefinitionUpgradeChecker = SettingDefinition{
@@ -517,13 +619,14 @@ var (
 	}
 
 	SettingDefinitionDefaultReplicaCount = SettingDefinition{
-		DisplayName: "Default Replica Count",
-		Description: "The default number of replicas when a volume is created from the Longhorn UI. For Kubernetes configuration, update the `numberOfReplicas` in the StorageClass",
-		Category:    SettingCategoryGeneral,
-		Type:        SettingTypeInt,
-		Required:    true,
-		ReadOnly:    false,
-		Default:     "3",
+		DisplayName:   "Default Replica Count",
+		Description:   "The default number of replicas when a volume is created from the Longhorn UI. For Kubernetes configuration, update the `numberOfReplicas` in the StorageClass",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "3",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 1, ValueIntRangeMaximum: 20},
 	}
 
 	SettingDefinitionDefaultDataLocality = SettingDefinition{
@@ -581,10 +684,11 @@ var (
 			"* `key1=value1:`  this toleration has empty effect. It matches all effects with key `key1` \n\n" +
 			"Because `kubernetes.io` is used as the key of all Kubernetes default tolerations, it should not be used in the toleration settings.\n\n " +
 			"WARNING: DO NOT CHANGE THIS SETTING WITH ATTACHED VOLUMES! ",
-		Category: SettingCategoryDangerZone,
-		Type:     SettingTypeString,
-		Required: false,
-		ReadOnly: false,
+		Category:        SettingCategoryDangerZone,
+		Type:            SettingTypeString,
+		Required:        false,
+		ReadOnly:        false,
+		RequiresRestart: true,
 	}
 
 	SettingDefinitionSystemManagedComponentsNodeSelector = SettingDefinition{
@@ -600,10 +704,11 @@ var (
 			"* `label-key1=label-value1; label-key2=label-value2` \n\n" +
 			"WARNING: DO NOT CHANGE THIS SETTING WITH ATTACHED VOLUMES! \n\n" +
 			"Please see the documentation at https://longhorn.io for more detailed instructions about changing node selector",
-		Category: SettingCategoryDangerZone,
-		Type:     SettingTypeString,
-		Required: false,
-		ReadOnly: false,
+		Category:        SettingCategoryDangerZone,
+		Type:            SettingTypeString,
+		Required:        false,
+		ReadOnly:        false,
+		RequiresRestart: true,
 	}
 
 	SettingDefinitionCRDAPIVersion = SettingDefinition{
@@ -729,9 +834,10 @@ var (
 			"Note that this setting only sets Priority Class for system managed components. " +
 			"Depending on how you deployed Longhorn, you need to set Priority Class for user deployed components in Helm chart or deployment YAML file. \n" +
 			"WARNING: DO NOT CHANGE THIS SETTING WITH ATTACHED VOLUMES.",
-		Category: SettingCategoryDangerZone,
-		Required: false,
-		ReadOnly: false,
+		Category:        SettingCategoryDangerZone,
+		Required:        false,
+		ReadOnly:        false,
+		RequiresRestart: true,
 	}
 	SettingDefinitionDisableRevisionCounter = SettingDefinition{
 		DisplayName: "Disable Revision Counter",
@@ -754,13 +860,15 @@ var (
 
 	SettingDefinitionReplicaReplenishmentWaitInterval = SettingDefinition{
 		DisplayName: "Replica Replenishment Wait Interval",
-		Description: "In seconds. The interval determines how long Longhorn will wait at least in order to reuse the existing data on a failed replica rather than directly creating a new replica for a degraded volume.\n" +
+		Description: "In seconds. The interval determines how long Longhorn will wait at least in order to reuse the existing data on a failed replica rather than directly creating a new replica for a degraded volume. " +
+			"The interval starts when the replica fails, so a replica on a node that is offline briefly is reused once the node is back rather than fully rebuilt. Set it to 0 to create new replicas immediately.\n" +
 			"Warning: This option works only when there is a failed replica in the volume. And this option may block the rebuilding for a while in the case.",
-		Category: SettingCategoryGeneral,
-		Type:     SettingTypeInt,
-		Required: true,
-		ReadOnly: false,
-		Default:  "600",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "600",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
 	}
 
 	SettingDefinitionConcurrentReplicaRebuildPerNodeLimit = SettingDefinition{
@@ -771,11 +879,12 @@ var (
 			"  - The old setting \"Disable Replica Rebuild\" is replaced by this setting. \n\n" +
 			"  - Different from relying on replica starting delay to limit the concurrent rebuilding, if the rebuilding is disabled, replica object replenishment will be directly skipped. \n\n" +
 			"  - When the value is 0, the eviction and data locality feature won't work. But this shouldn't have any impact to any current replica rebuild and backup restore.",
-		Category: SettingCategoryDangerZone,
-		Type:     SettingTypeInt,
-		Required: true,
-		ReadOnly: false,
-		Default:  "5",
+		Category:      SettingCategoryDangerZone,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "5",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
 	}
 
 	SettingDefinitionConcurrentVolumeBackupRestorePerNodeLimit = SettingDefinition{
@@ -783,11 +892,12 @@ var (
 		Description: "This setting controls how many volumes on a node can restore the backup concurrently.\n\n" +
 			"Longhorn blocks the backup restore once the restoring volume count exceeds the limit.\n\n" +
 			"Set the value to **0** to disable backup restore.\n\n",
-		Category: SettingCategoryGeneral,
-		Type:     SettingTypeInt,
-		Required: true,
-		ReadOnly: false,
-		Default:  "5",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "5",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
 	}
 
 	SettingDefinitionSystemManagedPodsImagePullPolicy = SettingDefinition{
@@ -804,6 +914,7 @@ var (
 			string(SystemManagedPodsImagePullPolicyNever),
 			string(SystemManagedPodsImagePullPolicyAlways),
 		},
+		RequiresRestart: true,
 	}
 
 	SettingDefinitionAllowVolumeCreationWithDegradedAvailability = SettingDefinition{
@@ -831,21 +942,23 @@ var (
 		Description: "This setting controls how Longhorn automatically upgrades volumes' engines after upgrading Longhorn manager. " +
 			"The value of this setting specifies the maximum number of engines per node that are allowed to upgrade to the default engine image at the same time. " +
 			"If the value is 0, Longhorn will not automatically upgrade volumes' engines to default version.",
-		Category: SettingCategoryGeneral,
-		Type:     SettingTypeInt,
-		Required: true,
-		ReadOnly: false,
-		Default:  "0",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "0",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
 	}
 
 	SettingDefinitionBackingImageCleanupWaitInterval = SettingDefinition{
-		DisplayName: "Backing Image Cleanup Wait Interval",
-		Description: "In minutes. The interval determines how long Longhorn will wait before cleaning up the backing image file when there is no replica in the disk using it.",
-		Category:    SettingCategoryGeneral,
-		Type:        SettingTypeInt,
-		Required:    true,
-		ReadOnly:    false,
-		Default:     "60",
+		DisplayName:   "Backing Image Cleanup Wait Interval",
+		Description:   "In minutes. The interval determines how long Longhorn will wait before cleaning up the backing image file when there is no replica in the disk using it.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "60",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
 	}
 
 	SettingDefinitionBackingImageRecoveryWaitInterval = SettingDefinition{
@@ -854,11 +967,12 @@ var (
 			"WARNING: \n\n" +
 			"  - This recovery only works for the backing image of which the creation type is \"download\". \n\n" +
 			"  - File state \"unknown\" means the related manager pods on the pod is not running or the node itself is down/disconnected.",
-		Category: SettingCategoryGeneral,
-		Type:     SettingTypeInt,
-		Required: true,
-		ReadOnly: false,
-		Default:  "300",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "300",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
 	}
 
 	SettingDefinitionGuaranteedEngineManagerCPU = SettingDefinition{
@@ -874,11 +988,12 @@ var (
 			"  - One more set of instance manager pods may need to be deployed when the Longhorn system is upgraded. If current available CPUs of the nodes are not enough for the new instance manager pods, you need to detach the volumes using the oldest instance manager pods so that Longhorn can clean up the old pods automatically and release the CPU resources. And the new pods with the latest instance manager image will be launched then. \n\n" +
 			"  - This global setting will be ignored for a node if the field \"EngineManagerCPURequest\" on the node is set. \n\n" +
 			"  - After this setting is changed, all engine manager pods using this global setting on all the nodes will be automatically restarted. In other words, DO NOT CHANGE THIS SETTING WITH ATTACHED VOLUMES. \n\n",
-		Category: SettingCategoryDangerZone,
-		Type:     SettingTypeString,
-		Required: true,
-		ReadOnly: false,
-		Default:  "12",
+		Category:        SettingCategoryDangerZone,
+		Type:            SettingTypeString,
+		Required:        true,
+		ReadOnly:        false,
+		Default:         "12",
+		RequiresRestart: true,
 	}
 
 	SettingDefinitionGuaranteedReplicaManagerCPU = SettingDefinition{
@@ -894,11 +1009,12 @@ var (
 			"  - One more set of instance manager pods may need to be deployed when the Longhorn system is upgraded. If current available CPUs of the nodes are not enough for the new instance manager pods, you need to detach the volumes using the oldest instance manager pods so that Longhorn can clean up the old pods automatically and release the CPU resources. And the new pods with the latest instance manager image will be launched then. \n\n" +
 			"  - This global setting will be ignored for a node if the field \"ReplicaManagerCPURequest\" on the node is set. \n\n" +
 			"  - After this setting is changed, all replica manager pods using this global setting on all the nodes will be automatically restarted. In other words, DO NOT CHANGE THIS SETTING WITH ATTACHED VOLUMES. \n\n",
-		Category: SettingCategoryDangerZone,
-		Type:     SettingTypeString,
-		Required: true,
-		ReadOnly: false,
-		Default:  "12",
+		Category:        SettingCategoryDangerZone,
+		Type:            SettingTypeString,
+		Required:        true,
+		ReadOnly:        false,
+		Default:         "12",
+		RequiresRestart: true,
 	}
 
 	SettingDefinitionKubernetesClusterAutoscalerEnabled = SettingDefinition{
@@ -935,33 +1051,36 @@ var (
 			"  - The cluster must have pre-existing Multus installed, and NetworkAttachmentDefinition IPs are reachable between nodes. \n\n" +
 			"  - DO NOT CHANGE THIS SETTING WITH ATTACHED VOLUMES. Longhorn will try to block this setting update when there are attached volumes. \n\n" +
 			"  - When applying the setting, Longhorn will restart all instance-manager, and backing-image-manager pods. \n\n",
-		Category: SettingCategoryDangerZone,
-		Type:     SettingTypeString,
-		Required: false,
-		ReadOnly: false,
-		Default:  CniNetworkNone,
+		Category:        SettingCategoryDangerZone,
+		Type:            SettingTypeString,
+		Required:        false,
+		ReadOnly:        false,
+		Default:         CniNetworkNone,
+		RequiresRestart: true,
 	}
 
 	SettingDefinitionRecurringSuccessfulJobsHistoryLimit = SettingDefinition{
 		DisplayName: "Cronjob Successful Jobs History Limit",
 		Description: "This setting specifies how many successful backup or snapshot job histories should be retained. \n\n" +
 			"History will not be retained if the value is 0.",
-		Category: SettingCategoryBackup,
-		Type:     SettingTypeInt,
-		Required: false,
-		ReadOnly: false,
-		Default:  "1",
+		Category:      SettingCategoryBackup,
+		Type:          SettingTypeInt,
+		Required:      false,
+		ReadOnly:      false,
+		Default:       "1",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
 	}
 
 	SettingDefinitionRecurringFailedJobsHistoryLimit = SettingDefinition{
 		DisplayName: "Cronjob Failed Jobs History Limit",
 		Description: "This setting specifies how many failed backup or snapshot job histories should be retained.\n\n" +
 			"History will not be retained if the value is 0.",
-		Category: SettingCategoryBackup,
-		Type:     SettingTypeInt,
-		Required: false,
-		ReadOnly: false,
-		Default:  "1",
+		Category:      SettingCategoryBackup,
+		Type:          SettingTypeInt,
+		Required:      false,
+		ReadOnly:      false,
+		Default:       "1",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
 	}
 
 	SettingDefinitionSupportBundleFailedHistoryLimit = SettingDefinition{
@@ -969,11 +1088,12 @@ var (
 		Description: "This setting specifies how many failed support bundles can exist in the cluster.\n\n" +
 			"The retained failed support bundle is for analysis purposes and needs to clean up manually.\n\n" +
 			"Set this value to **0** to have Longhorn automatically purge all failed support bundles.\n\n",
-		Category: SettingCategoryGeneral,
-		Type:     SettingTypeInt,
-		Required: false,
-		ReadOnly: false,
-		Default:  "1",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      false,
+		ReadOnly:      false,
+		Default:       "1",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
 	}
 
 	SettingDefinitionDeletingConfirmationFlag = SettingDefinition{
@@ -989,13 +1109,14 @@ var (
 	}
 
 	SettingDefinitionEngineReplicaTimeout = SettingDefinition{
-		DisplayName: "Timeout between Engine and Replica",
-		Description: "In seconds. The setting specifies the timeout between the engine and replica(s), and the value should be between 8 to 30 seconds. The default value is 8 seconds.",
-		Category:    SettingCategoryGeneral,
-		Type:        SettingTypeInt,
-		Required:    true,
-		ReadOnly:    false,
-		Default:     "8",
+		DisplayName:   "Timeout between Engine and Replica",
+		Description:   "In seconds. The setting specifies the timeout between the engine and replica(s), and the value should be between 8 to 30 seconds. The default value is 8 seconds.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "8",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 8, ValueIntRangeMaximum: 30},
 	}
 
 	SettingDefinitionSnapshotDataIntegrity = SettingDefinition{
@@ -1061,13 +1182,14 @@ var (
 	}
 
 	SettingDefinitionReplicaFileSyncHTTPClientTimeout = SettingDefinition{
-		DisplayName: "Timeout of HTTP Client to Replica File Sync Server",
-		Description: "In seconds. The setting specifies the HTTP client timeout to the file sync server.",
-		Category:    SettingCategoryGeneral,
-		Type:        SettingTypeInt,
-		Required:    true,
-		ReadOnly:    false,
-		Default:     "30",
+		DisplayName:   "Timeout of HTTP Client to Replica File Sync Server",
+		Description:   "In seconds. The setting specifies the HTTP client timeout to the file sync server.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "30",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 5, ValueIntRangeMaximum: 120},
 	}
 
 	SettingDefinitionBackupCompressionMethod = SettingDefinition{
@@ -1091,25 +1213,409 @@ var (
 
 	SettingDefinitionBackupConcurrentLimit = SettingDefinition{
 		DisplayName: "Backup Concurrent Limit Per Backup",
-		Description: "This setting controls how many worker threads per backup concurrently.",
-		Category:    SettingCategoryBackup,
-		Type:        SettingTypeInt,
-		Required:    true,
-		ReadOnly:    false,
-		Default:     "5",
+		Description: "This setting controls how many worker threads per backup concurrently. " +
+			"For an S3 backup target, it's the number of blocks uploaded to the bucket concurrently. " +
+			"Each block of at most 2 MiB is uploaded as a single object, so there is no multipart upload part size to tune.",
+		Category:      SettingCategoryBackup,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "5",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 1},
 	}
 
 	SettingDefinitionRestoreConcurrentLimit = SettingDefinition{
-		DisplayName: "Restore Concurrent Limit Per Backup",
-		Description: "This setting controls how many worker threads per restore concurrently.",
-		Category:    SettingCategoryBackup,
-		Type:        SettingTypeInt,
-		Required:    true,
-		ReadOnly:    false,
-		Default:     "5",
+		DisplayName:   "Restore Concurrent Limit Per Backup",
+		Description:   "This setting controls how many worker threads per restore concurrently.",
+		Category:      SettingCategoryBackup,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "5",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 1},
+	}
+
+	SettingDefinitionReplicaDataDirectoryNameFormat = SettingDefinition{
+		DisplayName: "Replica Data Directory Name Format",
+		Description: "The format of the replica data directory created under the `replicas` directory of a disk. \n\n" +
+			"Available placeholders are: \n\n" +
+			"- **{volume}**: The name of the volume. \n\n" +
+			"- **{volume-uid}**: The UID of the volume object. \n\n" +
+			"- **{random}**: A random 8-character ID. \n\n" +
+			"The format must end with `-{random}` so that Longhorn can recognize the directory as replica data. " +
+			"A static prefix can be added, and a single `/` can be used to group the replicas of a volume in a per-volume subdirectory, e.g. `{volume}/{volume}-{random}`. \n\n" +
+			"Changing the format only affects newly scheduled replicas. Existing replicas keep using the data directory recorded in their spec, so no data is moved, and the flat and the per-volume layouts can coexist in the same disk. " +
+			"A per-volume subdirectory is removed once the last replica in it is cleaned up.",
+		Category: SettingCategoryGeneral,
+		Type:     SettingTypeString,
+		Required: true,
+		ReadOnly: false,
+		Default:  ReplicaDataDirectoryNameFormatDefault,
+	}
+
+	SettingDefinitionFailedReplicaDataCleanupGracePeriod = SettingDefinition{
+		DisplayName: "Failed Replica Data Cleanup Grace Period",
+		Description: "In minutes. The period Longhorn keeps the on-disk data of a failed replica after the replica is removed, for investigation or salvage. \n\n" +
+			"During the period the data is tracked by an orphan resource. The orphan can be deleted immediately, or marked to be retained so that it is not cleaned up automatically. \n\n" +
+			"When the period is 0, the data of a failed replica is deleted along with the replica.",
+		Category:      SettingCategoryOrphan,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "0",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
+	}
+
+	SettingDefinitionReplicaZoneNetworkCost = SettingDefinition{
+		DisplayName: "Replica Zone Network Cost",
+		Description: "The relative network cost between zones. If it is set, the Nodes to schedule new Replicas, including the Replicas for rebuilding, are preferred by the lowest network cost from the zone of the Node the Volume is attached to, after the anti-affinity rules are fulfilled. " +
+			"The cost within a zone is 0, and the cost of zone pairs that are not listed is 1. " +
+			"Multiple zone pairs are separated by semicolon. For example: \n\n" +
+			"* `zone-a,zone-b=5; zone-a,zone-c=20` \n\n" +
+			"Leave it empty to schedule Replicas regardless of the network cost.",
+		Category: SettingCategoryScheduling,
+		Type:     SettingTypeString,
+		Required: false,
+		ReadOnly: false,
+		Default:  "",
+	}
+
+	SettingDefinitionAPIAuthentication = SettingDefinition{
+		DisplayName: "API Authentication",
+		Description: "Require the clients of the Longhorn API to authenticate, with a bearer token, the token as the basic auth password, or a client certificate. " +
+			"The tokens are listed one per line in the keys `admin` and `read-only` of the Secret `longhorn-api-auth` in the Longhorn namespace. " +
+			"A client certificate grants the role of its organization, `longhorn:admin` or `longhorn:read-only`. " +
+			"The read-only role can only get and list, the admin role is required for any change. " +
+			"The CSI plugin and the recurring jobs use the first admin token. The UI and the other clients have to be configured with a token before enabling it.",
+		Category: SettingCategoryGeneral,
+		Type:     SettingTypeBool,
+		Required: true,
+		ReadOnly: false,
+		Default:  "false",
+	}
+
+	SettingDefinitionVolumePolicyWebhooks = SettingDefinition{
+		DisplayName: "Volume Policy Webhooks",
+		Description: "The URLs of the policy webhooks reviewing the volume create, attach, delete and expand requests of the Longhorn API, in order. " +
+			"Longhorn POSTs the review of the request to each webhook, which can deny it, or change the volume spec of a create request and the parameters of the other requests. " +
+			"The request is denied if a webhook is unavailable. Multiple URLs are separated by semicolon. For example: \n\n" +
+			"* `http://opa.policy-system:8181/v1/data/longhorn/review` \n\n" +
+			"Leave it empty to allow the requests without a review.",
+		Category: SettingCategoryGeneral,
+		Type:     SettingTypeString,
+		Required: false,
+		ReadOnly: false,
+		Default:  "",
+	}
+
+	SettingDefinitionCloudTagSyncProvider = SettingDefinition{
+		DisplayName: "Cloud Tag Sync Provider",
+		Description: "Sync the tags of the cloud instance of each node to the labels of the Longhorn node, for cost attribution and inventory systems. " +
+			"The tags are read from the instance metadata service of the provider, and set as the labels with the prefix `cloud-tag.longhorn.io/`. " +
+			"The invalid characters of the tags are replaced by `-`, and the tags are truncated to the label length limit.\n" +
+			"- **disabled** doesn't sync the tags, and removes the synced labels.\n" +
+			"- **aws** requires the instance tags in the EC2 instance metadata to be allowed.\n" +
+			"- **gcp** syncs the custom metadata of the GCE instance with the prefix `longhorn-tag-`, since the GCE labels are not in the instance metadata.\n" +
+			"- **azure** syncs the tags of the Azure VM.\n",
+		Category: SettingCategoryGeneral,
+		Type:     SettingTypeString,
+		Required: true,
+		ReadOnly: false,
+		Default:  string(CloudTagSyncProviderDisabled),
+		Choices: []string{
+			string(CloudTagSyncProviderDisabled),
+			string(CloudTagSyncProviderAWS),
+			string(CloudTagSyncProviderGCP),
+			string(CloudTagSyncProviderAzure),
+		},
+	}
+
+	SettingDefinitionSnapshotIntegritySweepWeeklyBudget = SettingDefinition{
+		DisplayName: "Snapshot Integrity Sweep Weekly Budget",
+		Description: "The GiB of snapshot data each node reads per week to verify the snapshot checksums in the background, spread evenly over the week. " +
+			"The sweep rotates through the volumes attached to the node, the ones never or least recently checked first, and verifies one randomly sampled snapshot of each volume on all the replicas. " +
+			"The results are recorded in the volume status and exported as metrics, to alert on the volumes never verified or failing the verification. " +
+			"It works regardless of the Snapshot Data Integrity setting. Set it to 0 to disable the sweep.",
+		Category:      SettingCategorySnapshot,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "0",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
+	}
+
+	SettingDefinitionEngineQueryTimeout = SettingDefinition{
+		DisplayName:   "Timeout of Engine Queries",
+		Description:   "In seconds. The setting specifies how long an API request waits for the engine to list or get the snapshots of a volume before failing. 0 means no timeout.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "30",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
+	}
+
+	SettingDefinitionEngineOperationTimeout = SettingDefinition{
+		DisplayName:   "Timeout of Engine Operations",
+		Description:   "In seconds. The setting specifies how long an API request waits for the engine to create, delete, revert or purge the snapshots of a volume before failing. The operation may still complete in the engine after the timeout. 0 means no timeout.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "120",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
+	}
+
+	SettingDefinitionEngineStatusCacheTTL = SettingDefinition{
+		DisplayName:   "Engine Status Cache TTL",
+		Description:   "In seconds. The setting specifies how long the replica list and the controller info of an engine are cached for the API requests, so the repeated requests don't query the engine each time. 0 disables the cache.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "5",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
+	}
+
+	SettingDefinitionEngineStatusPollConcurrency = SettingDefinition{
+		DisplayName:   "Engine Status Poll Concurrency",
+		Description:   "The max number of engines a Longhorn manager polls the status of at the same time. The polls beyond it wait for the ongoing ones, so the instance managers aren't flooded when a node has many volumes.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "20",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 1},
+	}
+
+	SettingDefinitionReplicaCountAutoScaling = SettingDefinition{
+		DisplayName: "Replica Count Auto Scaling",
+		Description: "Scale down the effective replica count of a volume temporarily when there are fewer nodes or zones available than the requested replica count, instead of retrying the replica scheduling forever. " +
+			"The replicas are scaled back up automatically when the nodes return. A volume with reduced redundancy has the condition `reducedredundancy`. " +
+			"This setting has no effect if both the replica node level and zone level soft anti-affinity are enabled.",
+		Category: SettingCategoryScheduling,
+		Type:     SettingTypeBool,
+		Required: true,
+		ReadOnly: false,
+		Default:  "false",
+	}
+
+	SettingDefinitionMaxAttachedVolumesPerNode = SettingDefinition{
+		DisplayName: "Max Attached Volumes Per Node",
+		Description: "The maximum number of volumes attached to a node, including the volumes migrating to the node. A volume can't be attached to a node once the limit is reached. " +
+			"The limit isn't enforced atomically, so concurrent attachments to the same node can exceed it by a few volumes. " +
+			"Set the value to 0 for no limit.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "0",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
+	}
+
+	SettingDefinitionNodeGroupSettingOverrides = SettingDefinition{
+		DisplayName: "Node Group Setting Overrides",
+		Description: "Override some settings for the groups of nodes with a Kubernetes node label, so that different kinds of nodes can be tuned separately. " +
+			"A group is a label followed by the overridden settings, and multiple groups are separated by semicolon. The first group matching a node applies. For example: \n\n" +
+			"* `node-type:edge|concurrent-replica-rebuild-per-node-limit=1,max-attached-volumes-per-node=10; node-type:storage|storage-reserved-percentage-for-default-disk=10` \n\n" +
+			"The settings that can be overridden are `storage-reserved-percentage-for-default-disk`, `max-attached-volumes-per-node` and `concurrent-replica-rebuild-per-node-limit`.",
+		Category: SettingCategoryGeneral,
+		Type:     SettingTypeString,
+		Required: false,
+		ReadOnly: false,
+		Default:  "",
+	}
+
+	SettingDefinitionAirGappedMode = SettingDefinition{
+		DisplayName: "Air-Gapped Mode",
+		Description: "Run Longhorn without access to the internet. In the air-gapped mode: \n\n" +
+			"- The upgrade checker doesn't contact the external version service, regardless of the Upgrade Checker setting. \n" +
+			"- The engine images are resolved from the Air-Gapped Registry only. \n" +
+			"- An S3 backup target requires the `AWS_ENDPOINTS` of a local object store in the credential secret. Use an NFS or CIFS backup target for a cluster without an object store.",
+		Category: SettingCategoryGeneral,
+		Type:     SettingTypeBool,
+		Required: true,
+		ReadOnly: false,
+		Default:  "false",
+	}
+
+	SettingDefinitionAirGappedRegistry = SettingDefinition{
+		DisplayName: "Air-Gapped Registry",
+		Description: "The private registry to resolve the engine images from in the air-gapped mode, with an optional path. For example, `registry.example.local:5000/longhorn`. " +
+			"An engine image without a registry is resolved to the image in this registry, and an engine image in another registry is rejected.",
+		Category: SettingCategoryGeneral,
+		Type:     SettingTypeString,
+		Required: false,
+		ReadOnly: false,
+		Default:  "",
+	}
+
+	SettingDefinitionCompletedJobRetentionPeriod = SettingDefinition{
+		DisplayName: "Completed Job Retention Period",
+		Description: "In hours. The completed jobs of the recurring jobs older than this are deleted, in addition to the ones beyond the **Recurring Successful Jobs History Limit** and **Recurring Failed Jobs History Limit**.\n\n" +
+			"Set this value to **0** to keep the completed jobs within the history limits regardless of the age.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "0",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
+	}
+
+	SettingDefinitionEventRetentionCount = SettingDefinition{
+		DisplayName: "Event Retention Count",
+		Description: "The maximum number of the events of the Longhorn resources kept in the Longhorn namespace. The oldest events beyond it are deleted.\n\n" +
+			"Set this value to **0** to keep the events until they expire in Kubernetes.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "1000",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
+	}
+
+	SettingDefinitionEventRetentionPeriod = SettingDefinition{
+		DisplayName: "Event Retention Period",
+		Description: "In hours. The events of the Longhorn resources not seen for longer than this are deleted.\n\n" +
+			"Set this value to **0** to keep the events until they expire in Kubernetes.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "0",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
+	}
+
+	SettingDefinitionReplicaRebuildOffPeakHours = SettingDefinition{
+		DisplayName: "Replica Rebuild Off-Peak Hours",
+		Description: "The daily hours in UTC to rebuild the replicas of the volumes that still have more than one healthy replica, in the format of `<start>-<end>`, e.g. `22-6` for 10 PM to 6 AM. " +
+			"The replica of a volume with only one healthy replica left is rebuilt immediately. Empty means rebuilding at any time.",
+		Category: SettingCategoryScheduling,
+		Type:     SettingTypeString,
+		Required: false,
+		ReadOnly: false,
+		Default:  "",
+	}
+
+	SettingDefinitionReplicaRebuildBandwidthLimit = SettingDefinition{
+		DisplayName:   "Replica Rebuild Bandwidth Limit",
+		Description:   "In MiB/s. The maximum bandwidth of rebuilding a replica of a volume, so a rebuild storm can't starve the I/O of the workloads. The volume can override it with its own limit. 0 means no limit. The limits take effect when the engine of the volume starts, and require an engine image with CLI API version 8 or later. Otherwise the engine runs without the limits, and the volume gets a FailedApplyingQoS event.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "0",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
+	}
+
+	SettingDefinitionVolumeFrontendIOPSLimit = SettingDefinition{
+		DisplayName:   "Volume Frontend IOPS Limit",
+		Description:   "The maximum IOPS of the frontend of a volume. The volume can override it with its own limit. 0 means no limit. The limits take effect when the engine of the volume starts, and require an engine image with CLI API version 8 or later. Otherwise the engine runs without the limits, and the volume gets a FailedApplyingQoS event.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "0",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
+	}
+
+	SettingDefinitionVolumeFrontendBandwidthLimit = SettingDefinition{
+		DisplayName:   "Volume Frontend Bandwidth Limit",
+		Description:   "In MiB/s. The maximum bandwidth of the frontend of a volume. The volume can override it with its own limit. 0 means no limit. The limits take effect when the engine of the volume starts, and require an engine image with CLI API version 8 or later. Otherwise the engine runs without the limits, and the volume gets a FailedApplyingQoS event.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "0",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
+	}
+
+	SettingDefinitionFilesystemCheckAfterAttach = SettingDefinition{
+		DisplayName: "Filesystem Check After Attach",
+		Description: "Check the filesystem of a volume read-only after the volume is attached. " +
+			"A volume used by a workload pod is not checked, since the workload mounts it meanwhile. Only the volumes with the filesystem type recorded in the spec are checked. Errors found are reported by the volume condition `filesystemerror`, and can be repaired by a full filesystem check requested while the volume is detached.",
+		Category: SettingCategoryGeneral,
+		Type:     SettingTypeBool,
+		Required: true,
+		ReadOnly: false,
+		Default:  "false",
+	}
+
+	SettingDefinitionInstanceProcessRestartLimit = SettingDefinition{
+		DisplayName: "Instance Process Restart Limit",
+		Description: "The number of times Longhorn restarts a replica process in place after it exits unexpectedly, before leaving the failure to the replica rebuilding. " +
+			"The count is reset once the process keeps running for 10 minutes after the last restart. A crashed engine is never restarted in place, the volume is reattached with the healthy replicas instead. 0 means disabled.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "3",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0, ValueIntRangeMaximum: 10},
+	}
+
+	SettingDefinitionNotificationWebhookSecret = SettingDefinition{
+		DisplayName: "Notification Webhook Secret",
+		Description: "The name of the Kubernetes secret in the Longhorn namespace holding the webhook URL in the `url` key. " +
+			"Longhorn POSTs the notifications of the critical events to the URL: a volume becomes faulted, a volume stays degraded beyond the threshold, a backup fails, or a node goes down. " +
+			"A failed notification is retried a few times, and the same notification is sent at most once an hour. " +
+			"The URL is kept in a secret since it usually embeds the token of the webhook, e.g. a Slack incoming webhook. \n\n" +
+			"Leave it empty to disable the notifications.",
+		Category: SettingCategoryGeneral,
+		Type:     SettingTypeString,
+		Required: false,
+		ReadOnly: false,
+		Default:  "",
+	}
+
+	SettingDefinitionNotificationWebhookFormat = SettingDefinition{
+		DisplayName: "Notification Webhook Format",
+		Description: "The payload format of the notifications.\n\n" +
+			"Available options are: \n\n" +
+			"- **generic**: A JSON object with the type, resource, name, message and time of the event. \n\n" +
+			"- **slack**: A JSON object with the text of the event, which is accepted by Slack compatible incoming webhooks.",
+		Category: SettingCategoryGeneral,
+		Type:     SettingTypeString,
+		Required: true,
+		ReadOnly: false,
+		Default:  NotificationWebhookFormatGeneric,
+		Choices: []string{
+			NotificationWebhookFormatGeneric,
+			NotificationWebhookFormatSlack,
+		},
+	}
+
+	SettingDefinitionNotificationDegradedVolumeThreshold = SettingDefinition{
+		DisplayName:   "Notification Degraded Volume Threshold",
+		Description:   "In minutes. A notification is sent once a volume stays degraded for longer than the threshold. 0 means no notification for the degraded volumes.",
+		Category:      SettingCategoryGeneral,
+		Type:          SettingTypeInt,
+		Required:      true,
+		ReadOnly:      false,
+		Default:       "30",
+		ValueIntRange: map[string]int{ValueIntRangeMinimum: 0},
+	}
+
+	SettingDefinitionTenantQuotas = SettingDefinition{
+		DisplayName: "Tenant Quotas",
+		Description: "The quotas of the tenants, limiting the number of volumes and the total provisioned size of the volumes of a tenant. " +
+			"A quota is a tenant followed by the limits, and multiple quotas are separated by semicolon. For example: \n\n" +
+			"* `team-a:volumes=20,size=2Ti; team-b:size=500Gi` \n\n" +
+			"A missing limit or a tenant without a quota is unlimited. The quotas are checked when a volume of the tenant is created or expanded.",
+		Category: SettingCategoryGeneral,
+		Type:     SettingTypeString,
+		Required: false,
+		ReadOnly: false,
+		Default:  "",
 	}
 )
 
+const (
+	NotificationWebhookFormatGeneric = "generic"
+	NotificationWebhookFormatSlack   = "slack"
+
+	NotificationWebhookSecretURLKey = "url"
+)
+
 type NodeDownPodDeletionPolicy string
 
 const (
@@ -1119,6 +1625,15 @@ const (
 	NodeDownPodDeletionPolicyDeleteBothStatefulsetAndDeploymentPod = NodeDownPodDeletionPolicy("delete-both-statefulset-and-deployment-pod")
 )
 
+type CloudTagSyncProvider string
+
+const (
+	CloudTagSyncProviderDisabled = CloudTagSyncProvider("disabled")
+	CloudTagSyncProviderAWS      = CloudTagSyncProvider("aws")
+	CloudTagSyncProviderGCP      = CloudTagSyncProvider("gcp")
+	CloudTagSyncProviderAzure    = CloudTagSyncProvider("azure")
+)
+
 type NodeWithLastHealthyReplicaDrainPolicy string
 
 const (
@@ -1155,6 +1670,11 @@ func ValidateSetting(name, value string) (err error) {
 	if definition.Required && value == "" {
 		return fmt.Errorf("required setting %v shouldn't be empty", sName)
 	}
+	if value != "" {
+		if err := validateSettingValueType(definition, value); err != nil {
+			return err
+		}
+	}
 
 	switch sName {
 	case SettingNameBackupTarget:
@@ -1165,6 +1685,9 @@ func ValidateSetting(name, value string) (err error) {
 		if len(findStr) != 0 {
 			return fmt.Errorf("value %s, contains %v", value, strings.Join(findStr, " or "))
 		}
+		if err := ValidateBackupTargetURL(value); err != nil {
+			return err
+		}
 
 	// boolean
 	case SettingNameCreateDefaultDiskLabeledNodes:
@@ -1197,37 +1720,19 @@ func ValidateSetting(name, value string) (err error) {
 		fallthrough
 	case SettingNameFastReplicaRebuildEnabled:
 		fallthrough
+	case SettingNameAPIAuthentication:
+		fallthrough
+	case SettingNameReplicaCountAutoScaling:
+		fallthrough
+	case SettingNameAirGappedMode:
+		fallthrough
+	case SettingNameFilesystemCheckAfterAttach:
+		fallthrough
 	case SettingNameUpgradeChecker:
 		if value != "true" && value != "false" {
 			return fmt.Errorf("value %v of setting %v should be true or false", value, sName)
 		}
 
-	case SettingNameStorageOverProvisioningPercentage:
-		if _, err := strconv.Atoi(value); err != nil {
-			return errors.Wrapf(err, "value %v is not a number", value)
-		}
-		// additional check whether over provisioning percentage is positive
-		value, err := util.ConvertSize(value)
-		if err != nil {
-			return errors.Wrapf(err, "failed to parse %v as size", value)
-		}
-		if value < 0 {
-			return fmt.Errorf("value %v should be positive", value)
-		}
-	case SettingNameStorageReservedPercentageForDefaultDisk:
-		fallthrough
-	case SettingNameStorageMinimalAvailablePercentage:
-		if _, err := strconv.Atoi(value); err != nil {
-			return errors.Wrapf(err, "value %v is not a number", value)
-		}
-		// additional check whether minimal available percentage is between 0 to 100
-		value, err := util.ConvertSize(value)
-		if err != nil {
-			return errors.Wrapf(err, "failed to parse %v as size", value)
-		}
-		if value < 0 || value > 100 {
-			return fmt.Errorf("value %v should between 0 to 100", value)
-		}
 	case SettingNameDefaultReplicaCount:
 		c, err := strconv.Atoi(value)
 		if err != nil {
@@ -1244,34 +1749,6 @@ func ValidateSetting(name, value string) (err error) {
 		if value != "" {
 			return fmt.Errorf("cannot set a value %v for the deprecated setting %v", value, sName)
 		}
-	case SettingNameBackingImageCleanupWaitInterval:
-		fallthrough
-	case SettingNameBackingImageRecoveryWaitInterval:
-		fallthrough
-	case SettingNameReplicaReplenishmentWaitInterval:
-		fallthrough
-	case SettingNameConcurrentReplicaRebuildPerNodeLimit:
-		fallthrough
-	case SettingNameConcurrentBackupRestorePerNodeLimit:
-		fallthrough
-	case SettingNameConcurrentAutomaticEngineUpgradePerNodeLimit:
-		fallthrough
-	case SettingNameSupportBundleFailedHistoryLimit:
-		fallthrough
-	case SettingNameBackupstorePollInterval:
-		fallthrough
-	case SettingNameRecurringSuccessfulJobsHistoryLimit:
-		fallthrough
-	case SettingNameRecurringFailedJobsHistoryLimit:
-		fallthrough
-	case SettingNameFailedBackupTTL:
-		value, err := strconv.Atoi(value)
-		if err != nil {
-			errors.Wrapf(err, "value %v is not a number", value)
-		}
-		if value < 0 {
-			return fmt.Errorf("the value %v shouldn't be less than 0", value)
-		}
 	case SettingNameTaintToleration:
 		if _, err = UnmarshalTolerations(value); err != nil {
 			return errors.Wrapf(err, "the value of %v is invalid", sName)
@@ -1284,53 +1761,59 @@ func ValidateSetting(name, value string) (err error) {
 		if err = ValidateStorageNetwork(value); err != nil {
 			return errors.Wrapf(err, "the value of %v is invalid", sName)
 		}
-	case SettingNameReplicaFileSyncHTTPClientTimeout:
-		timeout, err := strconv.Atoi(value)
-		if err != nil {
-			return errors.Wrapf(err, "value %v is not a number", value)
+	case SettingNameSnapshotDataIntegrity:
+		if err = ValidateSnapshotDataIntegrity(value); err != nil {
+			return errors.Wrapf(err, "the value of %v is invalid", sName)
 		}
-
-		if timeout < 5 || timeout > 120 {
-			return fmt.Errorf("the value %v should be between 5 and 120", value)
+	case SettingNameBackupCompressionMethod:
+		if err = ValidateBackupCompressionMethod(value); err != nil {
+			return errors.Wrapf(err, "the value of %v is invalid", sName)
 		}
-	case SettingNameEngineReplicaTimeout:
-		timeout, err := strconv.Atoi(value)
-		if err != nil {
-			return errors.Wrapf(err, "value %v is not a number", value)
+	case SettingNameReplicaZoneNetworkCost:
+		if _, err = UnmarshalZoneNetworkCost(value); err != nil {
+			return errors.Wrapf(err, "the value of %v is invalid", sName)
 		}
-
-		if timeout < 8 || timeout > 30 {
-			return fmt.Errorf("the value %v should be between 8 and 30", value)
+	case SettingNameVolumePolicyWebhooks:
+		if _, err = UnmarshalVolumePolicyWebhooks(value); err != nil {
+			return errors.Wrapf(err, "the value of %v is invalid", sName)
 		}
-	case SettingNameBackupConcurrentLimit:
-		fallthrough
-	case SettingNameRestoreConcurrentLimit:
-		val, err := strconv.Atoi(value)
-		if err != nil {
-			return errors.Wrapf(err, "value %v is not a number", value)
+	case SettingNameTenantQuotas:
+		if _, err = UnmarshalTenantQuotas(value); err != nil {
+			return errors.Wrapf(err, "the value of %v is invalid", sName)
 		}
-
-		if val < 1 {
-			return fmt.Errorf("the value %v shouldn't be less than 1", value)
+	case SettingNameNotificationWebhookFormat:
+		definition, _ := GetSettingDefinition(sName)
+		if !isValidChoice(definition.Choices, value) {
+			return fmt.Errorf("value %v is not a valid choice, available choices %v", value, definition.Choices)
 		}
-	case SettingNameSnapshotDataIntegrity:
-		if err = ValidateSnapshotDataIntegrity(value); err != nil {
+	case SettingNameCloudTagSyncProvider:
+		definition, _ := GetSettingDefinition(sName)
+		if !isValidChoice(definition.Choices, value) {
+			return fmt.Errorf("value %v is not a valid choice, available choices %v", value, definition.Choices)
+		}
+	case SettingNameNodeGroupSettingOverrides:
+		if _, err = UnmarshalNodeGroupSettingOverrides(value); err != nil {
 			return errors.Wrapf(err, "the value of %v is invalid", sName)
 		}
-	case SettingNameBackupCompressionMethod:
-		if err = ValidateBackupCompressionMethod(value); err != nil {
+	case SettingNameAirGappedRegistry:
+		if err = ValidateImageRegistry(value); err != nil {
+			return errors.Wrapf(err, "the value of %v is invalid", sName)
+		}
+	case SettingNameReplicaRebuildOffPeakHours:
+		if _, err = UnmarshalRebuildOffPeakHours(value); err != nil {
+			return errors.Wrapf(err, "the value of %v is invalid", sName)
+		}
+	case SettingNameReplicaDataDirectoryNameFormat:
+		if err = ValidateReplicaDataDirectoryNameFormat(value); err != nil {
 			return errors.Wrapf(err, "the value of %v is invalid", sName)
 		}
 	case SettingNameSnapshotDataIntegrityCronJob:
-		schedule, err := cron.ParseStandard(value)
+		interval, err := util.GetCronInterval(value)
 		if err != nil {
 			return errors.Wrapf(err, "invalid cron job format: %v", value)
 		}
 
-		runAt := schedule.Next(time.Unix(0, 0))
-		nextRunAt := schedule.Next(runAt)
-
-		logrus.Debugf("The interval between two data integrity checks is %v seconds", nextRunAt.Sub(runAt).Seconds())
+		logrus.Debugf("The interval between two data integrity checks is %v seconds", interval.Seconds())
 
 	// multi-choices
 	case SettingNameNodeDownPodDeletionPolicy:
@@ -1487,6 +1970,27 @@ func UnmarshalTolerations(tolerationSetting string) ([]v1.Toleration, error) {
 	return res, nil
 }
 
+func validateSettingValueType(definition SettingDefinition, value string) error {
+	switch definition.Type {
+	case SettingTypeBool:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("value %v should be true or false", value)
+		}
+	case SettingTypeInt:
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.Wrapf(err, "value %v is not a number", value)
+		}
+		if minimum, ok := definition.ValueIntRange[ValueIntRangeMinimum]; ok && intValue < minimum {
+			return fmt.Errorf("value %v shouldn't be less than %v", value, minimum)
+		}
+		if maximum, ok := definition.ValueIntRange[ValueIntRangeMaximum]; ok && intValue > maximum {
+			return fmt.Errorf("value %v shouldn't be greater than %v", value, maximum)
+		}
+	}
+	return nil
+}
+
 func validateAndUnmarshalLabel(label string) (key, value string, err error) {
 	label = strings.Trim(label, " ")
 	parts := strings.Split(label, ":")
@@ -1513,6 +2017,286 @@ func UnmarshalNodeSelector(nodeSelectorSetting string) (map[string]string, error
 	return nodeSelector, nil
 }
 
+// ZoneNetworkCost is the relative network cost between zone pairs.
+type ZoneNetworkCost map[string]map[string]int
+
+// Get returns the cost between the zones, which is 0 within a zone and 1 for
+// the zone pairs that are not configured.
+func (c ZoneNetworkCost) Get(zone1, zone2 string) int {
+	if zone1 == zone2 {
+		return 0
+	}
+	if cost, ok := c[zone1][zone2]; ok {
+		return cost
+	}
+	return 1
+}
+
+func UnmarshalZoneNetworkCost(zoneNetworkCostSetting string) (ZoneNetworkCost, error) {
+	zoneNetworkCost := ZoneNetworkCost{}
+
+	zoneNetworkCostSetting = strings.Trim(zoneNetworkCostSetting, " ")
+	if zoneNetworkCostSetting == "" {
+		return zoneNetworkCost, nil
+	}
+	for _, item := range strings.Split(zoneNetworkCostSetting, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, "=")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid zone network cost %v, the format should be <zone>,<zone>=<cost>", item)
+		}
+		zones := strings.Split(parts[0], ",")
+		if len(zones) != 2 {
+			return nil, fmt.Errorf("invalid zone pair %v in zone network cost %v", parts[0], item)
+		}
+		zone1, zone2 := strings.TrimSpace(zones[0]), strings.TrimSpace(zones[1])
+		if zone1 == "" || zone2 == "" || zone1 == zone2 {
+			return nil, fmt.Errorf("invalid zone pair %v in zone network cost %v", parts[0], item)
+		}
+		cost, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || cost < 0 {
+			return nil, fmt.Errorf("invalid cost %v in zone network cost %v, it should be a non-negative integer", parts[1], item)
+		}
+		for _, zones := range [][2]string{{zone1, zone2}, {zone2, zone1}} {
+			if zoneNetworkCost[zones[0]] == nil {
+				zoneNetworkCost[zones[0]] = map[string]int{}
+			}
+			zoneNetworkCost[zones[0]][zones[1]] = cost
+		}
+	}
+	return zoneNetworkCost, nil
+}
+
+func UnmarshalVolumePolicyWebhooks(volumePolicyWebhooksSetting string) ([]string, error) {
+	webhooks := []string{}
+	for _, item := range strings.Split(volumePolicyWebhooksSetting, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		u, err := url.Parse(item)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid volume policy webhook %v", item)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid volume policy webhook %v, it should be an http or https URL", item)
+		}
+		webhooks = append(webhooks, item)
+	}
+	return webhooks, nil
+}
+
+// ValidateNotificationWebhookURL validates the webhook URL of the
+// notifications. The URL is left out of the error, since it usually embeds
+// the token of the webhook.
+func ValidateNotificationWebhookURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return errors.Wrap(err, "invalid notification webhook URL")
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid notification webhook URL, it should be an http or https URL")
+	}
+	return nil
+}
+
+// TenantQuota limits the volumes of a tenant. 0 means unlimited.
+type TenantQuota struct {
+	VolumeCount int64
+	Size        int64
+}
+
+func UnmarshalTenantQuotas(tenantQuotasSetting string) (map[string]TenantQuota, error) {
+	quotas := map[string]TenantQuota{}
+	for _, item := range strings.Split(tenantQuotasSetting, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid tenant quota %v, the format should be <tenant>:volumes=<count>,size=<size>", item)
+		}
+		tenant := strings.TrimSpace(parts[0])
+		if err := ValidateTenant(tenant); err != nil {
+			return nil, errors.Wrapf(err, "invalid tenant quota %v", item)
+		}
+		if _, exists := quotas[tenant]; exists {
+			return nil, fmt.Errorf("duplicate quota of tenant %v", tenant)
+		}
+		quota := TenantQuota{}
+		for _, limit := range strings.Split(parts[1], ",") {
+			nameValue := strings.Split(limit, "=")
+			if len(nameValue) != 2 {
+				return nil, fmt.Errorf("invalid limit %v of tenant %v", limit, tenant)
+			}
+			value := strings.TrimSpace(nameValue[1])
+			switch strings.TrimSpace(nameValue[0]) {
+			case "volumes":
+				count, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || count < 0 {
+					return nil, fmt.Errorf("invalid volume count %v of tenant %v", value, tenant)
+				}
+				quota.VolumeCount = count
+			case "size":
+				size, err := util.ConvertSize(value)
+				if err != nil || size < 0 {
+					return nil, fmt.Errorf("invalid size %v of tenant %v", value, tenant)
+				}
+				quota.Size = size
+			default:
+				return nil, fmt.Errorf("unknown limit %v of tenant %v, it should be volumes or size", nameValue[0], tenant)
+			}
+		}
+		quotas[tenant] = quota
+	}
+	return quotas, nil
+}
+
+// ValidateTenant checks the tenant is a DNS-1123 label like a namespace, so
+// it can be used as a label value.
+func ValidateTenant(tenant string) error {
+	if errs := validation.IsDNS1123Label(tenant); len(errs) != 0 {
+		return fmt.Errorf("invalid tenant %v: %v", tenant, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// RebuildOffPeakHours is a daily window in UTC from the start hour to the end
+// hour, which wraps around midnight if the end is not after the start.
+type RebuildOffPeakHours struct {
+	Start int
+	End   int
+}
+
+func UnmarshalRebuildOffPeakHours(rebuildOffPeakHoursSetting string) (*RebuildOffPeakHours, error) {
+	rebuildOffPeakHoursSetting = strings.TrimSpace(rebuildOffPeakHoursSetting)
+	if rebuildOffPeakHoursSetting == "" {
+		return nil, nil
+	}
+	parts := strings.Split(rebuildOffPeakHoursSetting, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid off-peak hours %v, the format should be <start>-<end>", rebuildOffPeakHoursSetting)
+	}
+	hours := [2]int{}
+	for i, part := range parts {
+		hour, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || hour < 0 || hour > 23 {
+			return nil, fmt.Errorf("invalid hour %v in off-peak hours %v, it should be an integer from 0 to 23", part, rebuildOffPeakHoursSetting)
+		}
+		hours[i] = hour
+	}
+	if hours[0] == hours[1] {
+		return nil, fmt.Errorf("invalid off-peak hours %v, the start and the end are the same", rebuildOffPeakHoursSetting)
+	}
+	return &RebuildOffPeakHours{Start: hours[0], End: hours[1]}, nil
+}
+
+func (h *RebuildOffPeakHours) Contains(t time.Time) bool {
+	hour := t.UTC().Hour()
+	if h.Start < h.End {
+		return hour >= h.Start && hour < h.End
+	}
+	return hour >= h.Start || hour < h.End
+}
+
+// Until returns the duration from the time to the next start of the window.
+func (h *RebuildOffPeakHours) Until(t time.Time) time.Duration {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), t.Day(), h.Start, 0, 0, 0, time.UTC)
+	if !start.After(t) {
+		start = start.AddDate(0, 0, 1)
+	}
+	return start.Sub(t)
+}
+
+// NodeGroupSettingOverridableSettings are the settings that can be
+// overridden for a node group.
+var NodeGroupSettingOverridableSettings = []SettingName{
+	SettingNameStorageReservedPercentageForDefaultDisk,
+	SettingNameMaxAttachedVolumesPerNode,
+	SettingNameConcurrentReplicaRebuildPerNodeLimit,
+}
+
+// NodeGroupSettingOverride is the settings overridden for the nodes with the
+// label.
+type NodeGroupSettingOverride struct {
+	LabelKey   string
+	LabelValue string
+	Settings   map[SettingName]string
+}
+
+func UnmarshalNodeGroupSettingOverrides(nodeGroupSettingOverridesSetting string) ([]NodeGroupSettingOverride, error) {
+	overrides := []NodeGroupSettingOverride{}
+	for _, item := range strings.Split(nodeGroupSettingOverridesSetting, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, "|")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid node group %v, the format should be <label-key>:<label-value>|<setting>=<value>,<setting>=<value>", item)
+		}
+		key, value, err := validateAndUnmarshalLabel(parts[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid node group %v", item)
+		}
+		if key == "" {
+			return nil, fmt.Errorf("invalid node group %v, the label key is empty", item)
+		}
+		override := NodeGroupSettingOverride{
+			LabelKey:   key,
+			LabelValue: value,
+			Settings:   map[SettingName]string{},
+		}
+		for _, setting := range strings.Split(parts[1], ",") {
+			nameValue := strings.Split(setting, "=")
+			if len(nameValue) != 2 {
+				return nil, fmt.Errorf("invalid setting %v of node group %v", setting, item)
+			}
+			name := SettingName(strings.TrimSpace(nameValue[0]))
+			if !isNodeGroupSettingOverridable(name) {
+				return nil, fmt.Errorf("setting %v of node group %v can't be overridden", name, item)
+			}
+			settingValue := strings.TrimSpace(nameValue[1])
+			if err := ValidateSetting(string(name), settingValue); err != nil {
+				return nil, errors.Wrapf(err, "invalid setting %v of node group %v", name, item)
+			}
+			override.Settings[name] = settingValue
+		}
+		overrides = append(overrides, override)
+	}
+	return overrides, nil
+}
+
+func isNodeGroupSettingOverridable(name SettingName) bool {
+	for _, overridable := range NodeGroupSettingOverridableSettings {
+		if name == overridable {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNodeGroupSettingOverride returns the value of the setting overridden for
+// the node with the labels by the first matching node group.
+func GetNodeGroupSettingOverride(overrides []NodeGroupSettingOverride, nodeLabels map[string]string, name SettingName) (string, bool) {
+	for _, override := range overrides {
+		if labelValue, ok := nodeLabels[override.LabelKey]; !ok || labelValue != override.LabelValue {
+			continue
+		}
+		value, ok := override.Settings[name]
+		return value, ok
+	}
+	return "", false
+}
+
 func GetSettingDefinition(name SettingName) (SettingDefinition, bool) {
 	settingDefinitionsLock.RLock()
 	defer settingDefinitionsLock.RUnlock()
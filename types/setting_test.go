@@ -0,0 +1,163 @@
+package types
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSettingIntRange(t *testing.T) {
+	assert := require.New(t)
+
+	noMaximum := -1
+	tests := map[SettingName]struct {
+		minimum int
+		maximum int
+	}{
+		SettingNameStorageOverProvisioningPercentage:       {0, noMaximum},
+		SettingNameStorageMinimalAvailablePercentage:       {0, 100},
+		SettingNameStorageReservedPercentageForDefaultDisk: {0, 100},
+		SettingNameDefaultReplicaCount:                     {1, 20},
+		SettingNameReplicaFileSyncHTTPClientTimeout:        {5, 120},
+		SettingNameEngineReplicaTimeout:                    {8, 30},
+		SettingNameBackupConcurrentLimit:                   {1, noMaximum},
+		SettingNameRestoreConcurrentLimit:                  {1, noMaximum},
+
+		SettingNameBackingImageCleanupWaitInterval:              {0, noMaximum},
+		SettingNameBackingImageRecoveryWaitInterval:             {0, noMaximum},
+		SettingNameReplicaReplenishmentWaitInterval:             {0, noMaximum},
+		SettingNameConcurrentReplicaRebuildPerNodeLimit:         {0, noMaximum},
+		SettingNameConcurrentBackupRestorePerNodeLimit:          {0, noMaximum},
+		SettingNameConcurrentAutomaticEngineUpgradePerNodeLimit: {0, noMaximum},
+		SettingNameSupportBundleFailedHistoryLimit:              {0, noMaximum},
+		SettingNameBackupstorePollInterval:                      {0, noMaximum},
+		SettingNameRecurringSuccessfulJobsHistoryLimit:          {0, noMaximum},
+		SettingNameRecurringFailedJobsHistoryLimit:              {0, noMaximum},
+		SettingNameFailedReplicaDataCleanupGracePeriod:          {0, noMaximum},
+		SettingNameSnapshotIntegritySweepWeeklyBudget:           {0, noMaximum},
+		SettingNameEngineQueryTimeout:                           {0, noMaximum},
+		SettingNameEngineOperationTimeout:                       {0, noMaximum},
+		SettingNameMaxAttachedVolumesPerNode:                    {0, noMaximum},
+		SettingNameCompletedJobRetentionPeriod:                  {0, noMaximum},
+		SettingNameEventRetentionCount:                          {0, noMaximum},
+		SettingNameEventRetentionPeriod:                         {0, noMaximum},
+		SettingNameReplicaRebuildBandwidthLimit:                 {0, noMaximum},
+		SettingNameVolumeFrontendIOPSLimit:                      {0, noMaximum},
+		SettingNameVolumeFrontendBandwidthLimit:                 {0, noMaximum},
+		SettingNameFailedBackupTTL:                              {0, noMaximum},
+	}
+	for name, test := range tests {
+		definition, ok := GetSettingDefinition(name)
+		assert.True(ok, name)
+		assert.Equal(SettingTypeInt, definition.Type, name)
+		assert.NoError(ValidateSetting(string(name), definition.Default), "default of %v", name)
+
+		assert.NoError(ValidateSetting(string(name), strconv.Itoa(test.minimum)), name)
+		assert.Error(ValidateSetting(string(name), strconv.Itoa(test.minimum-1)), name)
+		if test.maximum == noMaximum {
+			_, ok := definition.ValueIntRange[ValueIntRangeMaximum]
+			assert.False(ok, name)
+		} else {
+			assert.NoError(ValidateSetting(string(name), strconv.Itoa(test.maximum)), name)
+			assert.Error(ValidateSetting(string(name), strconv.Itoa(test.maximum+1)), name)
+		}
+		assert.Error(ValidateSetting(string(name), "ten"), name)
+		assert.Error(ValidateSetting(string(name), "1.5"), name)
+	}
+}
+
+func TestValidateSettingType(t *testing.T) {
+	assert := require.New(t)
+
+	// Every int and bool setting is checked by its definition, whether it
+	// has its own validation or not
+	for _, name := range SettingNameList {
+		definition, ok := GetSettingDefinition(name)
+		assert.True(ok, name)
+		switch definition.Type {
+		case SettingTypeInt:
+			assert.Error(ValidateSetting(string(name), "ten"), name)
+		case SettingTypeBool:
+			assert.Error(ValidateSetting(string(name), "yes"), name)
+			assert.NoError(ValidateSetting(string(name), "true"), name)
+		default:
+			continue
+		}
+		if definition.Default != "" {
+			assert.NoError(ValidateSetting(string(name), definition.Default), "default of %v", name)
+		}
+	}
+
+	assert.Error(ValidateSetting("unknown-setting", "1"))
+}
+
+func TestRebuildOffPeakHours(t *testing.T) {
+	assert := require.New(t)
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 1, 2, hour, minute, 0, 0, time.UTC)
+	}
+
+	hours, err := UnmarshalRebuildOffPeakHours("")
+	assert.NoError(err)
+	assert.Nil(hours)
+	for _, value := range []string{"1", "1-1", "1-24", "a-2", "1-2-3"} {
+		_, err := UnmarshalRebuildOffPeakHours(value)
+		assert.Error(err, value)
+	}
+
+	hours, err = UnmarshalRebuildOffPeakHours(" 1 - 5 ")
+	assert.NoError(err)
+	assert.Equal(&RebuildOffPeakHours{Start: 1, End: 5}, hours)
+	assert.False(hours.Contains(at(0, 59)))
+	assert.True(hours.Contains(at(1, 0)))
+	assert.True(hours.Contains(at(4, 59)))
+	assert.False(hours.Contains(at(5, 0)))
+	assert.Equal(30*time.Minute, hours.Until(at(0, 30)))
+	assert.Equal(23*time.Hour+30*time.Minute, hours.Until(at(1, 30)))
+
+	// The window wraps around midnight
+	hours, err = UnmarshalRebuildOffPeakHours("22-2")
+	assert.NoError(err)
+	assert.True(hours.Contains(at(23, 0)))
+	assert.True(hours.Contains(at(1, 0)))
+	assert.False(hours.Contains(at(2, 0)))
+	assert.False(hours.Contains(at(21, 59)))
+	assert.Equal(time.Hour, hours.Until(at(21, 0)))
+	assert.Equal(21*time.Hour, hours.Until(at(1, 0)))
+	// The time is compared in UTC
+	assert.True(hours.Contains(time.Date(2026, 1, 2, 8, 0, 0, 0, time.FixedZone("UTC+9", 9*60*60))))
+}
+
+func TestNodeGroupSettingOverrides(t *testing.T) {
+	assert := require.New(t)
+
+	overrides, err := UnmarshalNodeGroupSettingOverrides(" node-type:edge|max-attached-volumes-per-node=10, concurrent-replica-rebuild-per-node-limit=1; node-type:storage|max-attached-volumes-per-node=20;")
+	assert.NoError(err)
+	assert.Len(overrides, 2)
+
+	value, ok := GetNodeGroupSettingOverride(overrides, map[string]string{"node-type": "edge"}, SettingNameMaxAttachedVolumesPerNode)
+	assert.True(ok)
+	assert.Equal("10", value)
+	value, ok = GetNodeGroupSettingOverride(overrides, map[string]string{"node-type": "storage"}, SettingNameMaxAttachedVolumesPerNode)
+	assert.True(ok)
+	assert.Equal("20", value)
+	// The first matching node group applies even if it doesn't override the setting
+	_, ok = GetNodeGroupSettingOverride(overrides, map[string]string{"node-type": "storage"}, SettingNameConcurrentReplicaRebuildPerNodeLimit)
+	assert.False(ok)
+	_, ok = GetNodeGroupSettingOverride(overrides, map[string]string{"node-type": "compute"}, SettingNameMaxAttachedVolumesPerNode)
+	assert.False(ok)
+
+	for _, invalid := range []string{
+		"node-type:edge",
+		"|max-attached-volumes-per-node=10",
+		"node-type:edge|max-attached-volumes-per-node",
+		"node-type:edge|max-attached-volumes-per-node=-1",
+		"node-type:edge|backup-target=s3://backup@us-east-1/",
+	} {
+		_, err := UnmarshalNodeGroupSettingOverrides(invalid)
+		assert.Error(err, invalid)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -43,6 +44,7 @@ const (
 	KubernetesKindClusterRole           = "ClusterRole"
 	KubernetesKindClusterRoleBinding    = "ClusterRoleBinding"
 	KubernetesKindConfigMap             = "ConfigMap"
+	KubernetesKindCronJob               = "CronJob"
 	KubernetesKindDaemonSet             = "DaemonSet"
 	KubernetesKindDeployment            = "Deployment"
 	KubernetesKindJob                   = "Job"
@@ -118,6 +120,7 @@ const (
 	NodeCreateDefaultDiskLabelValueConfig     = "config"
 	KubeNodeDefaultDiskConfigAnnotationKey    = "node.longhorn.io/default-disks-config"
 	KubeNodeDefaultNodeTagConfigAnnotationKey = "node.longhorn.io/default-node-tags"
+	KubeNodeDefaultSchedulingAnnotationKey    = "node.longhorn.io/default-node-scheduling"
 
 	LastAppliedTolerationAnnotationKeySuffix = "last-applied-tolerations"
 
@@ -133,6 +136,10 @@ const (
 
 	LonghornLabelKeyPrefix = "longhorn.io"
 
+	// CloudTagLabelKeyPrefix is the prefix of the node labels synced from
+	// the cloud instance tags
+	CloudTagLabelKeyPrefix = "cloud-tag.longhorn.io/"
+
 	LonghornLabelRecurringJobKeyPrefixFmt = "recurring-%s.longhorn.io"
 	LonghornLabelVolumeSettingKeyPrefix   = "setting.longhorn.io"
 
@@ -156,6 +163,7 @@ const (
 	LonghornLabelRecurringJobGroup          = "job-group"
 	LonghornLabelOrphan                     = "orphan"
 	LonghornLabelOrphanType                 = "orphan-type"
+	LonghornLabelOrphanRetained             = "orphan-retained"
 	LonghornLabelRecoveryBackend            = "recovery-backend"
 	LonghornLabelCRDAPIVersion              = "crd-api-version"
 	LonghornLabelVolumeAccessMode           = "volume-access-mode"
@@ -167,6 +175,15 @@ const (
 	LonghornLabelLastSystemRestoreAt        = "last-system-restored-at"
 	LonghornLabelLastSystemRestoreBackup    = "last-system-restored-backup"
 	LonghornLabelVersion                    = "version"
+	LonghornLabelSnapshotViewOf             = "snapshot-view-of"
+	LonghornLabelSnapshotViewSnapshot       = "snapshot-view-snapshot"
+	LonghornLabelBackupVerification         = "backup-verification"
+	LonghornLabelBackupBrowser              = "backup-browser"
+	LonghornLabelSystemSnapshotPurpose      = "system-snapshot-purpose"
+	LonghornLabelSystemSnapshotOwner        = "system-snapshot-owner"
+	LonghornLabelTenant                     = "tenant"
+	LonghornLabelBulkBackup                 = "bulk-backup"
+	LonghornLabelDataCorruption             = "data-corruption"
 
 	LonghornLabelValueEnabled = "enabled"
 	LonghornLabelValueIgnored = "ignored"
@@ -183,6 +200,15 @@ const (
 
 	LonghornDriverName = "driver.longhorn.io"
 
+	// FilesystemCheckAttachedBy requests the attachment of a detached volume
+	// for a full filesystem check. The volume is detached once it's done.
+	FilesystemCheckAttachedBy = "filesystem-check"
+	// CSIAttachedBy and ShareManagerAttachedBy request the attachment of the
+	// volume for the workloads, by the CSI driver or the share manager of a
+	// shared volume.
+	CSIAttachedBy          = "csi"
+	ShareManagerAttachedBy = "share-manager"
+
 	DefaultDiskPrefix = "default-disk-"
 
 	DeprecatedProvisionerName          = "rancher.io/longhorn"
@@ -196,6 +222,18 @@ const (
 
 	PVAnnotationLonghornVolumeSchedulingError = "longhorn.io/volume-scheduling-error"
 
+	// VolumeAnnotationIdempotencyKey is the key of the request creating the
+	// volume, so that a retried request returns the volume
+	VolumeAnnotationIdempotencyKey = "longhorn.io/idempotency-key"
+	// VolumeAnnotationIdempotencyChecksum is the checksum of the request
+	// creating the volume, so that the key reused by a different request is
+	// rejected
+	VolumeAnnotationIdempotencyChecksum = "longhorn.io/idempotency-checksum"
+	// VolumeAnnotationLastRequestID is the ID of the user request last
+	// changing the volume spec, so the work of the controllers can be traced
+	// back to it
+	VolumeAnnotationLastRequestID = "longhorn.io/last-request-id"
+
 	CniNetworkNone          = ""
 	StorageNetworkInterface = "lhnet1"
 )
@@ -213,10 +251,40 @@ const (
 	SupportBundleDownloadTimeout = 5 * time.Minute
 )
 
+const (
+	// AutoSalvageTimeLimit is how long before the last failure a replica can
+	// fail to be salvaged, as they're considered failing at the same time.
+	AutoSalvageTimeLimit = 1 * time.Minute
+)
+
 const (
 	KubernetesMinVersion = "v1.18.0"
 )
 
+const (
+	APIAuthSecretName = "longhorn-api-auth"
+
+	APIRoleAdmin    = "admin"
+	APIRoleReadOnly = "read-only"
+
+	// APIRoleOrganizationPrefix is prefixed to the role in the organization
+	// of a client certificate, e.g. longhorn:admin
+	APIRoleOrganizationPrefix = "longhorn:"
+
+	// EnvAPITokens holds the admin tokens of the API auth Secret for the
+	// internal clients.
+	EnvAPITokens = "LONGHORN_API_TOKENS"
+
+	// APITLSSecretName is the Secret of the API certificate. Its CA is used
+	// by the internal clients to verify the API served over TLS.
+	APITLSSecretName  = "longhorn-api-tls"
+	APITLSSecretCAKey = "ca.crt"
+
+	// EnvAPICACerts holds the CA certificates of the API TLS Secret for the
+	// internal clients.
+	EnvAPICACerts = "LONGHORN_API_CA_CERTS"
+)
+
 const (
 	EnvNodeName       = "NODE_NAME"
 	EnvPodNamespace   = "POD_NAMESPACE"
@@ -305,6 +373,17 @@ func GetCronJobNameForRecurringJob(name string) string {
 	return name + recurringSuffix
 }
 
+// GetRecurringJobSnapshotNamePrefix returns the name prefix of the snapshots
+// created by the recurring job, which tells them apart from a user created
+// snapshot with the job label copied.
+func GetRecurringJobSnapshotNamePrefix(jobName string) string {
+	prefix := GetCronJobNameForRecurringJob(jobName)
+	if len(prefix) > 8 {
+		prefix = prefix[:8]
+	}
+	return prefix + "-"
+}
+
 func GetCronJobNameForVolumeAndJob(vName, job string) string {
 	return vName + "-" + job + recurringSuffix
 }
@@ -314,7 +393,44 @@ func GetAPIServerAddressFromIP(ip string) string {
 }
 
 func GetDefaultManagerURL() string {
-	return "http://longhorn-backend:" + strconv.Itoa(DefaultAPIPort) + "/v1"
+	return GetManagerURL(false)
+}
+
+// GetManagerURL returns the URL of the API for the internal clients, over
+// https if the API is served over TLS.
+func GetManagerURL(tlsEnabled bool) string {
+	scheme := "http"
+	if tlsEnabled {
+		scheme = "https"
+	}
+	return scheme + "://longhorn-backend:" + strconv.Itoa(DefaultAPIPort) + "/v1"
+}
+
+// ParseAPITokens returns the tokens listed one per line.
+func ParseAPITokens(data string) []string {
+	tokens := []string{}
+	for _, line := range strings.Split(data, "\n") {
+		if token := strings.TrimSpace(line); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// GetAPICACerts returns the CA certificates for the internal clients to verify
+// the API served over TLS, or empty to use the system CAs.
+func GetAPICACerts() string {
+	return os.Getenv(EnvAPICACerts)
+}
+
+// GetAPIToken returns the token for the internal clients of the API, or empty
+// if there is none.
+func GetAPIToken() string {
+	tokens := ParseAPITokens(os.Getenv(EnvAPITokens))
+	if len(tokens) == 0 {
+		return ""
+	}
+	return tokens[0]
 }
 
 func GetImageCanonicalName(image string) string {
@@ -526,6 +642,12 @@ func GetOrphanLabelsForOrphanedDirectory(nodeID, diskUUID string) map[string]str
 	return labels
 }
 
+// IsOrphanRetained returns true if the orphaned data is marked to be kept, so
+// it won't be cleaned up automatically.
+func IsOrphanRetained(orphan *longhorn.Orphan) bool {
+	return orphan.Labels[GetLonghornLabelKey(LonghornLabelOrphanRetained)] == "true"
+}
+
 func GetRecoveryBackendConfigMapLabels() map[string]string {
 	labels := GetBaseLabelsForSystemManagedComponent()
 	labels[GetLonghornLabelComponentKey()] = LonghornLabelRecoveryBackend
@@ -640,6 +762,131 @@ func GetBackingImageDataSourcePodName(bidsName string) string {
 	return fmt.Sprintf("%s%s", BackingImageDataSourcePodNamePrefix, bidsName)
 }
 
+const (
+	ReplicaDataDirectoryNamePlaceholderVolume    = "{volume}"
+	ReplicaDataDirectoryNamePlaceholderVolumeUID = "{volume-uid}"
+	ReplicaDataDirectoryNamePlaceholderRandom    = "{random}"
+
+	ReplicaDataDirectoryNameFormatDefault = ReplicaDataDirectoryNamePlaceholderVolume + "-" + ReplicaDataDirectoryNamePlaceholderRandom
+)
+
+// ValidateReplicaDataDirectoryNameFormat makes sure the format always
+// generates a relative path with at most one level of subdirectory and that
+// the last path element ends with a random ID, which is what the orphan
+// detection and the replica data cleanup rely on.
+func ValidateReplicaDataDirectoryNameFormat(format string) error {
+	if !strings.HasSuffix(format, "-"+ReplicaDataDirectoryNamePlaceholderRandom) {
+		return fmt.Errorf("format %v should end with -%v", format, ReplicaDataDirectoryNamePlaceholderRandom)
+	}
+	if strings.Count(format, ReplicaDataDirectoryNamePlaceholderRandom) != 1 {
+		return fmt.Errorf("format %v should contain exactly one %v", format, ReplicaDataDirectoryNamePlaceholderRandom)
+	}
+
+	elements := strings.Split(format, "/")
+	if len(elements) > 2 {
+		return fmt.Errorf("format %v should contain at most one subdirectory", format)
+	}
+	for _, element := range elements {
+		if element == "" || element == "." || element == ".." {
+			return fmt.Errorf("format %v contains invalid path element %q", format, element)
+		}
+	}
+
+	sample := strings.NewReplacer(
+		ReplicaDataDirectoryNamePlaceholderVolume, "volume",
+		ReplicaDataDirectoryNamePlaceholderVolumeUID, "uid",
+		ReplicaDataDirectoryNamePlaceholderRandom, "random",
+	).Replace(format)
+	if !regexp.MustCompile(`^[a-zA-Z0-9_.\-/]+$`).MatchString(sample) {
+		return fmt.Errorf("format %v contains unsupported characters or placeholders", format)
+	}
+	return nil
+}
+
+// GenerateReplicaDataDirectoryName renders the replica data directory name
+// based on the format. The default format is used if the format is invalid.
+func GenerateReplicaDataDirectoryName(format string, volume *longhorn.Volume) string {
+	if err := ValidateReplicaDataDirectoryNameFormat(format); err != nil {
+		format = ReplicaDataDirectoryNameFormatDefault
+	}
+	return strings.NewReplacer(
+		ReplicaDataDirectoryNamePlaceholderVolume, volume.Name,
+		ReplicaDataDirectoryNamePlaceholderVolumeUID, string(volume.UID),
+		ReplicaDataDirectoryNamePlaceholderRandom, util.RandomID(),
+	).Replace(format)
+}
+
+// ValidateBackupTargetURL checks the format of the backup target URL. An S3
+// backup target should be in the format of s3://<bucket>@<region>/<path>.
+func ValidateBackupTargetURL(backupTarget string) error {
+	if backupTarget == "" {
+		return nil
+	}
+
+	u, err := url.Parse(backupTarget)
+	if err != nil {
+		return errors.Wrapf(err, "invalid backup target URL %v", backupTarget)
+	}
+
+	switch u.Scheme {
+	case "":
+		return fmt.Errorf("missing scheme in backup target URL %v", backupTarget)
+	case BackupStoreTypeS3:
+		if u.User == nil || u.User.Username() == "" {
+			return fmt.Errorf("missing bucket in S3 backup target URL %v, the format should be s3://<bucket>@<region>/<path>", backupTarget)
+		}
+		if u.Hostname() == "" {
+			return fmt.Errorf("missing region in S3 backup target URL %v, the format should be s3://<bucket>@<region>/<path>", backupTarget)
+		}
+	}
+
+	return nil
+}
+
+// ValidateImageRegistry checks the registry is a host with an optional port
+// and path, e.g. registry.example.local:5000/longhorn.
+func ValidateImageRegistry(registry string) error {
+	if registry == "" {
+		return nil
+	}
+	if strings.Contains(registry, "://") || strings.ContainsAny(registry, " @") {
+		return fmt.Errorf("invalid registry %v, it should be in the format of <host>[:<port>][/<path>]", registry)
+	}
+	host := strings.SplitN(strings.TrimSuffix(registry, "/"), "/", 2)[0]
+	if !isImageRegistryHost(host) {
+		return fmt.Errorf("invalid registry host %v, it should contain a dot or a port, or be localhost", host)
+	}
+	return nil
+}
+
+func isImageRegistryHost(host string) bool {
+	return strings.ContainsAny(host, ".:") || host == "localhost"
+}
+
+// ResolveImageWithRegistry resolves the image without a registry host to the
+// image in the registry. The image in another registry is rejected.
+func ResolveImageWithRegistry(image, registry string) (string, error) {
+	registry = strings.TrimSuffix(registry, "/")
+	if registry == "" || strings.HasPrefix(image, registry+"/") {
+		return image, nil
+	}
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && isImageRegistryHost(parts[0]) {
+		return "", fmt.Errorf("image %v is not in the registry %v", image, registry)
+	}
+	return registry + "/" + image, nil
+}
+
+// ValidateAirGappedBackupTarget checks the backup target can be reached
+// without the internet. An S3 backup target needs the endpoint of a local
+// object store, otherwise the public AWS endpoint is used.
+func ValidateAirGappedBackupTarget(backupType string, credential map[string]string) error {
+	if backupType == BackupStoreTypeS3 && credential[AWSEndPoint] == "" {
+		return fmt.Errorf("S3 backup target requires %v of a local object store in the air-gapped mode", AWSEndPoint)
+	}
+	return nil
+}
+
 func GetReplicaDataPath(diskPath, dataDirectoryName string) string {
 	return filepath.Join(diskPath, "replicas", dataDirectoryName)
 }
@@ -715,6 +962,75 @@ func ValidateStorageNetwork(value string) (err error) {
 	return nil
 }
 
+type SystemSnapshotPurpose string
+
+const (
+	SystemSnapshotPurposeCloneVolume        = SystemSnapshotPurpose("clone")
+	SystemSnapshotPurposeExportBackingImage = SystemSnapshotPurpose("bi-export")
+
+	// SystemSnapshotNamePrefix is reserved for the snapshots created by
+	// Longhorn, so they never conflict with the user created ones.
+	SystemSnapshotNamePrefix = "system-"
+
+	systemSnapshotOwnerMaxLength = 24
+)
+
+// GenerateSystemSnapshotName returns the name of a snapshot created by
+// Longhorn in the format system-<purpose>-<owner>-<random ID>.
+func GenerateSystemSnapshotName(purpose SystemSnapshotPurpose, owner string) string {
+	if len(owner) > systemSnapshotOwnerMaxLength {
+		owner = owner[:systemSnapshotOwnerMaxLength]
+	}
+	return fmt.Sprintf("%s%s-%s-%s", SystemSnapshotNamePrefix, purpose, strings.Trim(owner, "-"), util.RandomID())
+}
+
+// GetSystemSnapshotLabels returns the ownership labels of a snapshot created
+// by Longhorn.
+func GetSystemSnapshotLabels(purpose SystemSnapshotPurpose, owner string) map[string]string {
+	return map[string]string{
+		GetLonghornLabelKey(LonghornLabelSystemSnapshotPurpose): string(purpose),
+		GetLonghornLabelKey(LonghornLabelSystemSnapshotOwner):   owner,
+	}
+}
+
+// IsSystemSnapshot tells if the snapshot is created by the engine, e.g. for
+// rebuilding or upgrading, or by Longhorn for a clone or an export.
+func IsSystemSnapshot(name string, userCreated bool, labels map[string]string) bool {
+	return !userCreated || IsLonghornCreatedSnapshot(name, labels)
+}
+
+// IsLonghornCreatedSnapshot tells if the snapshot is created by Longhorn
+// rather than the engine, and it's still used by its owner.
+func IsLonghornCreatedSnapshot(name string, labels map[string]string) bool {
+	if strings.HasPrefix(name, SystemSnapshotNamePrefix) && !isRecurringJobSnapshot(name, labels) {
+		return true
+	}
+	_, ok := labels[GetLonghornLabelKey(LonghornLabelSystemSnapshotPurpose)]
+	return ok
+}
+
+// isRecurringJobSnapshot tells if the snapshot is named and labeled by a
+// recurring job, whose name may start with the system snapshot prefix too,
+// e.g. system-backup.
+func isRecurringJobSnapshot(name string, labels map[string]string) bool {
+	jobName, ok := labels[RecurringJobLabel]
+	return ok && jobName != "" && strings.HasPrefix(name, GetRecurringJobSnapshotNamePrefix(jobName))
+}
+
+// ValidateUserSnapshot makes sure the user created snapshot doesn't look like
+// a system one, so the system cleanup can't take it.
+func ValidateUserSnapshot(name string, labels map[string]string) error {
+	if strings.HasPrefix(name, SystemSnapshotNamePrefix) && !isRecurringJobSnapshot(name, labels) {
+		return fmt.Errorf("snapshot name prefix %v is reserved for system snapshots", SystemSnapshotNamePrefix)
+	}
+	for _, key := range []string{LonghornLabelSystemSnapshotPurpose, LonghornLabelSystemSnapshotOwner} {
+		if _, ok := labels[GetLonghornLabelKey(key)]; ok {
+			return fmt.Errorf("snapshot label %v is reserved for system snapshots", GetLonghornLabelKey(key))
+		}
+	}
+	return nil
+}
+
 func ValidateSnapshotDataIntegrity(mode string) error {
 	if mode != string(longhorn.SnapshotDataIntegrityDisabled) &&
 		mode != string(longhorn.SnapshotDataIntegrityEnabled) &&
@@ -733,6 +1049,69 @@ func ValidateBackupCompressionMethod(method string) error {
 	return nil
 }
 
+func ValidateVolumeExpireAt(expireAt string) error {
+	if expireAt == "" {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339, expireAt); err != nil {
+		return errors.Wrapf(err, "invalid volume expiration time %v", expireAt)
+	}
+	return nil
+}
+
+func ValidateVolumeSnapshotLimits(maxCount int, maxAge string) error {
+	if maxCount < 0 {
+		return fmt.Errorf("invalid snapshot max count %v", maxCount)
+	}
+	if maxAge == "" {
+		return nil
+	}
+	age, err := time.ParseDuration(maxAge)
+	if err != nil {
+		return errors.Wrapf(err, "invalid snapshot max age %v", maxAge)
+	}
+	if age <= 0 {
+		return fmt.Errorf("invalid snapshot max age %v", maxAge)
+	}
+	return nil
+}
+
+func ValidateVolumeQoS(rebuildBandwidthLimit, frontendIOPSLimit, frontendBandwidthLimit int64) error {
+	for name, limit := range map[string]int64{
+		"rebuild bandwidth limit":  rebuildBandwidthLimit,
+		"frontend IOPS limit":      frontendIOPSLimit,
+		"frontend bandwidth limit": frontendBandwidthLimit,
+	} {
+		if limit < -1 {
+			return fmt.Errorf("invalid %v %v, must be -1 for no limit, 0 to follow the setting, or a positive value", name, limit)
+		}
+	}
+	return nil
+}
+
+// ValidateVolumeFilesystem validates the filesystem the volume is formatted
+// with, which needs the block device frontend and the device unencrypted.
+func ValidateVolumeFilesystem(filesystem string, mountOptions []string, frontend longhorn.VolumeFrontend, encrypted bool) error {
+	for _, option := range mountOptions {
+		if option == "" || strings.ContainsAny(option, ", ") {
+			return fmt.Errorf("invalid mount option %q", option)
+		}
+	}
+	if filesystem == "" {
+		return nil
+	}
+	if filesystem != util.FilesystemTypeExt4 && filesystem != util.FilesystemTypeXFS {
+		return fmt.Errorf("unsupported filesystem %v", filesystem)
+	}
+	if frontend != longhorn.VolumeFrontendBlockDev {
+		return fmt.Errorf("formatting volume with frontend %v is not supported", frontend)
+	}
+	if encrypted {
+		return fmt.Errorf("formatting encrypted volume is not supported")
+	}
+	return nil
+}
+
 func ValidateUnmapMarkSnapChainRemoved(unmapValue longhorn.UnmapMarkSnapChainRemoved) error {
 	if unmapValue != longhorn.UnmapMarkSnapChainRemovedIgnored && unmapValue != longhorn.UnmapMarkSnapChainRemovedEnabled && unmapValue != longhorn.UnmapMarkSnapChainRemovedDisabled {
 		return fmt.Errorf("invalid UnmapMarkSnapChainRemoved setting: %v", unmapValue)
@@ -829,6 +1208,15 @@ func GetNodeTagsFromAnnotation(annotation string) ([]string, error) {
 	return validNodeTags, nil
 }
 
+// GetNodeSchedulingFromAnnotation input format should be `true` or `false`
+func GetNodeSchedulingFromAnnotation(annotation string) (bool, error) {
+	allowScheduling, err := strconv.ParseBool(strings.TrimSpace(annotation))
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid node scheduling annotation %v", annotation)
+	}
+	return allowScheduling, nil
+}
+
 type DiskSpecWithName struct {
 	longhorn.DiskSpec
 	Name string `json:"name"`
@@ -0,0 +1,141 @@
+package types
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+)
+
+func TestValidateReplicaDataDirectoryNameFormat(t *testing.T) {
+	tests := map[string]bool{
+		ReplicaDataDirectoryNameFormatDefault: true,
+		"{volume}/{volume}-{random}":          true,
+		"{volume-uid}/data-{random}":          true,
+		"prefix-{volume}-{random}":            true,
+		"{volume}":                            false,
+		"{volume}-{random}-{random}":          false,
+		"{volume}-{random}/x":                 false,
+		"a/b/{volume}-{random}":               false,
+		"/{volume}-{random}":                  false,
+		"../{volume}-{random}":                false,
+		"{volume}/{unknown}-{random}":         false,
+		"{volume} {random}-{random}":          false,
+	}
+	for format, valid := range tests {
+		err := ValidateReplicaDataDirectoryNameFormat(format)
+		require.Equal(t, valid, err == nil, "format %v: %v", format, err)
+	}
+}
+
+func TestGenerateReplicaDataDirectoryName(t *testing.T) {
+	assert := require.New(t)
+
+	v := &longhorn.Volume{ObjectMeta: metav1.ObjectMeta{Name: "vol", UID: "1234"}}
+	random := "[a-z0-9]{8}"
+
+	name := GenerateReplicaDataDirectoryName(ReplicaDataDirectoryNameFormatDefault, v)
+	assert.Regexp(regexp.MustCompile("^vol-"+random+"$"), name)
+
+	name = GenerateReplicaDataDirectoryName("{volume}/{volume-uid}-{random}", v)
+	assert.Regexp(regexp.MustCompile("^vol/1234-"+random+"$"), name)
+	assert.Equal("/longhorn/replicas/"+name, GetReplicaDataPath("/longhorn", name))
+
+	// The default format is used for an invalid one
+	name = GenerateReplicaDataDirectoryName("../{volume}", v)
+	assert.Regexp(regexp.MustCompile("^vol-"+random+"$"), name)
+	assert.False(strings.Contains(name, ".."))
+
+	// Each replica gets its own directory
+	assert.NotEqual(GenerateReplicaDataDirectoryName(ReplicaDataDirectoryNameFormatDefault, v),
+		GenerateReplicaDataDirectoryName(ReplicaDataDirectoryNameFormatDefault, v))
+}
+
+func TestValidateBackupTargetURL(t *testing.T) {
+	tests := map[string]bool{
+		"":                             true,
+		"s3://backupbucket@us-east-1/": true,
+		"s3://backupbucket@us-east-1/backupstore":              true,
+		"nfs://longhorn-test-nfs-svc.default:/opt/backupstore": true,
+		"cifs://longhorn-test-cifs-svc.default/backupstore":    true,
+		"backupbucket@us-east-1/":                              false,
+		"s3://us-east-1/":                                      false,
+		"s3://backupbucket@/":                                  false,
+	}
+	for url, valid := range tests {
+		err := ValidateBackupTargetURL(url)
+		require.Equal(t, valid, err == nil, "url %v: %v", url, err)
+	}
+}
+
+func TestParseAPITokens(t *testing.T) {
+	require.Equal(t, []string{}, ParseAPITokens(""))
+	require.Equal(t, []string{"token1", "token2"}, ParseAPITokens(" token1 \n\n\ttoken2\r\n"))
+}
+
+func TestGetAPIToken(t *testing.T) {
+	t.Setenv(EnvAPITokens, "")
+	require.Equal(t, "", GetAPIToken())
+
+	t.Setenv(EnvAPITokens, "\ntoken1\ntoken2\n")
+	require.Equal(t, "token1", GetAPIToken())
+}
+
+func TestGetManagerURL(t *testing.T) {
+	require.Equal(t, "http://longhorn-backend:9500/v1", GetDefaultManagerURL())
+	require.Equal(t, "http://longhorn-backend:9500/v1", GetManagerURL(false))
+	require.Equal(t, "https://longhorn-backend:9500/v1", GetManagerURL(true))
+}
+
+func TestValidateUserSnapshot(t *testing.T) {
+	assert := require.New(t)
+
+	systemJobLabels := map[string]string{RecurringJobLabel: "system-backup"}
+	systemJobSnapshot := GetRecurringJobSnapshotNamePrefix("system-backup") + "c3b0f1a2"
+	assert.True(strings.HasPrefix(systemJobSnapshot, SystemSnapshotNamePrefix))
+
+	tests := map[string]struct {
+		name      string
+		labels    map[string]string
+		expectErr bool
+	}{
+		"user snapshot":                      {"snap-1", nil, false},
+		"generated name":                     {"", nil, false},
+		"system prefix":                      {"system-snap", nil, true},
+		"system snapshot name":               {GenerateSystemSnapshotName(SystemSnapshotPurposeCloneVolume, "vol"), nil, true},
+		"recurring job named system-":        {systemJobSnapshot, systemJobLabels, false},
+		"recurring job label of another job": {systemJobSnapshot, map[string]string{RecurringJobLabel: "daily"}, true},
+		"system purpose label": {"snap-1", map[string]string{
+			GetLonghornLabelKey(LonghornLabelSystemSnapshotPurpose): string(SystemSnapshotPurposeCloneVolume)}, true},
+		"system owner label": {"snap-1", map[string]string{
+			GetLonghornLabelKey(LonghornLabelSystemSnapshotOwner): "vol"}, true},
+	}
+	for name, test := range tests {
+		err := ValidateUserSnapshot(test.name, test.labels)
+		if test.expectErr {
+			assert.Error(err, name)
+		} else {
+			assert.NoError(err, name)
+		}
+	}
+}
+
+func TestIsLonghornCreatedSnapshot(t *testing.T) {
+	assert := require.New(t)
+
+	// The snapshots of a recurring job named system-* are still cleaned up
+	// by the job rather than left as owned by Longhorn
+	systemJobSnapshot := GetRecurringJobSnapshotNamePrefix("system-backup") + "c3b0f1a2"
+	assert.False(IsLonghornCreatedSnapshot(systemJobSnapshot, map[string]string{RecurringJobLabel: "system-backup"}))
+	assert.True(IsLonghornCreatedSnapshot(systemJobSnapshot, nil))
+
+	name := GenerateSystemSnapshotName(SystemSnapshotPurposeExportBackingImage, "bi")
+	assert.True(IsLonghornCreatedSnapshot(name, GetSystemSnapshotLabels(SystemSnapshotPurposeExportBackingImage, "bi")))
+	assert.True(IsLonghornCreatedSnapshot("snap-1", GetSystemSnapshotLabels(SystemSnapshotPurposeCloneVolume, "vol")))
+	assert.False(IsLonghornCreatedSnapshot("snap-1", map[string]string{RecurringJobLabel: "daily"}))
+}
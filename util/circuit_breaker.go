@@ -0,0 +1,86 @@
+package util
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrCircuitBreakerOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker fails the calls fast once the calls failed in a row reach the
+// threshold, so a service that's down isn't hammered by the callers. After
+// the cool down, a single call is let through to probe the service. The
+// breaker is closed again if the probe succeeds, or stays open for another
+// cool down otherwise.
+type CircuitBreaker struct {
+	lock      sync.Mutex
+	threshold int
+	coolDown  time.Duration
+
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	// for unit test
+	now func() time.Time
+}
+
+func NewCircuitBreaker(threshold int, coolDown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		coolDown:  coolDown,
+		now:       time.Now,
+	}
+}
+
+// Allow returns ErrCircuitBreakerOpen if the call should fail fast. Otherwise
+// the caller must report the result of the call with Record.
+func (b *CircuitBreaker) Allow() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.coolDown {
+		return ErrCircuitBreakerOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// Record records the result of an allowed call. It returns true if the call
+// opened or closed the breaker.
+func (b *CircuitBreaker) Record(success bool) (changed bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	wasOpen := b.failures >= b.threshold
+	b.probing = false
+	if success {
+		b.failures = 0
+		return wasOpen
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+	return !wasOpen && b.failures >= b.threshold
+}
+
+// Ignore reports an allowed call whose result tells nothing about the
+// service, e.g. canceled by the caller. It's neither a success nor a failure,
+// but it lets the next probe through.
+func (b *CircuitBreaker) Ignore() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.probing = false
+}
+
+func (b *CircuitBreaker) IsOpen() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.failures >= b.threshold
+}
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	assert := require.New(t)
+
+	now := time.Now()
+	b := NewCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	assert.Nil(b.Allow())
+	assert.False(b.Record(false))
+	assert.Nil(b.Allow())
+	assert.True(b.Record(false))
+	assert.True(b.IsOpen())
+	assert.Equal(ErrCircuitBreakerOpen, b.Allow())
+
+	// A single probe is let through after the cool down
+	now = now.Add(time.Minute)
+	assert.Nil(b.Allow())
+	assert.Equal(ErrCircuitBreakerOpen, b.Allow())
+	assert.False(b.Record(false))
+	assert.Equal(ErrCircuitBreakerOpen, b.Allow())
+
+	// An ignored probe lets the next one through
+	now = now.Add(time.Minute)
+	assert.Nil(b.Allow())
+	b.Ignore()
+	assert.True(b.IsOpen())
+	assert.Nil(b.Allow())
+	assert.True(b.Record(true))
+	assert.False(b.IsOpen())
+	assert.Nil(b.Allow())
+}
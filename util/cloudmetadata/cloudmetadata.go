@@ -0,0 +1,168 @@
+package cloudmetadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	requestTimeout = 5 * time.Second
+
+	awsMetadataURL   = "http://169.254.169.254/latest"
+	gcpMetadataURL   = "http://metadata.google.internal/computeMetadata/v1"
+	azureMetadataURL = "http://169.254.169.254/metadata"
+
+	// GCPTagAttributePrefix is the prefix of the GCE instance custom metadata
+	// synced as tags. GCE labels are not in the metadata server, and the
+	// other attributes may hold credentials, e.g. kube-env.
+	GCPTagAttributePrefix = "longhorn-tag-"
+)
+
+// Provider reads the tags of the cloud instance running the caller.
+type Provider interface {
+	GetInstanceTags() (map[string]string, error)
+}
+
+type ProviderFactory func() Provider
+
+var (
+	providersLock sync.RWMutex
+	providers     = map[string]ProviderFactory{
+		"aws":   func() Provider { return &awsProvider{url: awsMetadataURL} },
+		"gcp":   func() Provider { return &gcpProvider{url: gcpMetadataURL} },
+		"azure": func() Provider { return &azureProvider{url: azureMetadataURL} },
+	}
+)
+
+// RegisterProvider adds or replaces the provider of the name, e.g. for a
+// private cloud.
+func RegisterProvider(name string, factory ProviderFactory) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	providers[name] = factory
+}
+
+func GetProvider(name string) (Provider, error) {
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+	factory, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown cloud metadata provider %v", name)
+	}
+	return factory(), nil
+}
+
+func doRequest(method, reqURL string, header map[string]string) ([]byte, error) {
+	req, err := http.NewRequest(method, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	client := &http.Client{Timeout: requestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v from %v: %s", resp.Status, reqURL, body)
+	}
+	return body, nil
+}
+
+type awsProvider struct {
+	url string
+}
+
+// GetInstanceTags uses IMDSv2. The tags are only in the metadata if they are
+// allowed by the instance metadata options.
+func (p *awsProvider) GetInstanceTags() (map[string]string, error) {
+	token, err := doRequest(http.MethodPut, p.url+"/api/token", map[string]string{
+		"X-aws-ec2-metadata-token-ttl-seconds": "60",
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get EC2 metadata token")
+	}
+	header := map[string]string{"X-aws-ec2-metadata-token": string(token)}
+
+	keys, err := doRequest(http.MethodGet, p.url+"/meta-data/tags/instance", header)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list EC2 instance tags")
+	}
+	tags := map[string]string{}
+	for _, key := range strings.Split(string(keys), "\n") {
+		if key = strings.TrimSpace(key); key == "" {
+			continue
+		}
+		value, err := doRequest(http.MethodGet, p.url+"/meta-data/tags/instance/"+url.PathEscape(key), header)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get EC2 instance tag %v", key)
+		}
+		tags[key] = string(value)
+	}
+	return tags, nil
+}
+
+type gcpProvider struct {
+	url string
+}
+
+func (p *gcpProvider) GetInstanceTags() (map[string]string, error) {
+	body, err := doRequest(http.MethodGet, p.url+"/instance/attributes/?recursive=true", map[string]string{
+		"Metadata-Flavor": "Google",
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get GCE instance attributes")
+	}
+	attributes := map[string]string{}
+	if err := json.Unmarshal(body, &attributes); err != nil {
+		return nil, errors.Wrap(err, "invalid GCE instance attributes")
+	}
+	tags := map[string]string{}
+	for key, value := range attributes {
+		if strings.HasPrefix(key, GCPTagAttributePrefix) {
+			tags[strings.TrimPrefix(key, GCPTagAttributePrefix)] = value
+		}
+	}
+	return tags, nil
+}
+
+type azureProvider struct {
+	url string
+}
+
+func (p *azureProvider) GetInstanceTags() (map[string]string, error) {
+	body, err := doRequest(http.MethodGet, p.url+"/instance/compute/tagsList?api-version=2021-02-01", map[string]string{
+		"Metadata": "true",
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get Azure VM tags")
+	}
+	tagList := []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}{}
+	if err := json.Unmarshal(body, &tagList); err != nil {
+		return nil, errors.Wrap(err, "invalid Azure VM tags")
+	}
+	tags := map[string]string{}
+	for _, tag := range tagList {
+		tags[tag.Name] = tag.Value
+	}
+	return tags, nil
+}
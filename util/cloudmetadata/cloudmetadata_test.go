@@ -0,0 +1,82 @@
+package cloudmetadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSGetInstanceTags(t *testing.T) {
+	assert := require.New(t)
+
+	tags := map[string]string{
+		"team":         "storage",
+		"cost center":  "1234",
+		"k8s.io/role":  "worker",
+		"a+b=c@d:e,f_": "g",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/api/token" {
+			assert.Equal(http.MethodPut, req.Method)
+			_, _ = rw.Write([]byte("token"))
+			return
+		}
+		assert.Equal("token", req.Header.Get("X-aws-ec2-metadata-token"))
+		if req.URL.Path == "/meta-data/tags/instance" {
+			keys := []string{}
+			for key := range tags {
+				keys = append(keys, key)
+			}
+			_, _ = rw.Write([]byte(strings.Join(keys, "\n")))
+			return
+		}
+		// The key is a single path segment
+		escapedKey := strings.TrimPrefix(req.URL.EscapedPath(), "/meta-data/tags/instance/")
+		assert.NotContains(escapedKey, "/")
+		value, ok := tags[strings.TrimPrefix(req.URL.Path, "/meta-data/tags/instance/")]
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = rw.Write([]byte(value))
+	}))
+	defer server.Close()
+
+	p := &awsProvider{url: server.URL}
+	result, err := p.GetInstanceTags()
+	assert.NoError(err)
+	assert.Equal(tags, result)
+}
+
+func TestGCPGetInstanceTags(t *testing.T) {
+	assert := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal("Google", req.Header.Get("Metadata-Flavor"))
+		_, _ = rw.Write([]byte(`{"longhorn-tag-team":"storage","kube-env":"secret"}`))
+	}))
+	defer server.Close()
+
+	p := &gcpProvider{url: server.URL}
+	result, err := p.GetInstanceTags()
+	assert.NoError(err)
+	assert.Equal(map[string]string{"team": "storage"}, result)
+}
+
+func TestAzureGetInstanceTags(t *testing.T) {
+	assert := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal("true", req.Header.Get("Metadata"))
+		_, _ = rw.Write([]byte(`[{"name":"team","value":"storage"}]`))
+	}))
+	defer server.Close()
+
+	p := &azureProvider{url: server.URL}
+	result, err := p.GetInstanceTags()
+	assert.NoError(err)
+	assert.Equal(map[string]string{"team": "storage"}, result)
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// MaxCronRunTimes caps the number of the run times computed at once, since
+// a schedule like "* * * * *" has one every minute.
+const MaxCronRunTimes = 1000
+
+// ParseCronSchedule parses the standard 5-field cron expression, or one of
+// the descriptors like "@daily", the same way the recurring job cron jobs
+// and the data integrity check are scheduled.
+func ParseCronSchedule(spec string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron format(%v): %v", spec, err)
+	}
+	return schedule, nil
+}
+
+// GetCronNextRunTimes returns the next count run times of the schedule after
+// the given time. The times are in the location of the given time.
+func GetCronNextRunTimes(spec string, after time.Time, count int) ([]time.Time, error) {
+	if count < 0 || count > MaxCronRunTimes {
+		return nil, fmt.Errorf("invalid count %v of the cron run times, should be between 0 and %v", count, MaxCronRunTimes)
+	}
+	schedule, err := ParseCronSchedule(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	runTimes := []time.Time{}
+	next := after
+	for i := 0; i < count; i++ {
+		next = schedule.Next(next)
+		// A schedule never fires, e.g. "0 0 30 2 *"
+		if next.IsZero() {
+			break
+		}
+		runTimes = append(runTimes, next)
+	}
+	return runTimes, nil
+}
+
+// GetCronInterval returns the interval between the first two runs of the
+// schedule after the Unix epoch.
+func GetCronInterval(spec string) (time.Duration, error) {
+	runTimes, err := GetCronNextRunTimes(spec, time.Unix(0, 0), 2)
+	if err != nil {
+		return 0, err
+	}
+	if len(runTimes) < 2 {
+		return 0, fmt.Errorf("cron schedule %v doesn't run repeatedly", spec)
+	}
+	return runTimes[1].Sub(runTimes[0]), nil
+}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCronNextRunTimes(t *testing.T) {
+	assert := require.New(t)
+
+	after := time.Date(2024, 2, 28, 23, 30, 0, 0, time.UTC)
+	runTimes, err := GetCronNextRunTimes("0 0 * * *", after, 3)
+	assert.Nil(err)
+	assert.Equal([]time.Time{
+		time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+	}, runTimes)
+
+	runTimes, err = GetCronNextRunTimes("*/20 * * * *", after, 2)
+	assert.Nil(err)
+	assert.Equal("2024-02-28T23:40:00Z", FormatTimeZ(runTimes[0]))
+	assert.Equal("2024-02-29T00:00:00Z", FormatTimeZ(runTimes[1]))
+
+	runTimes, err = GetCronNextRunTimes("0 0 30 2 *", after, 2)
+	assert.Nil(err)
+	assert.Len(runTimes, 0)
+
+	_, err = GetCronNextRunTimes("0 0 * *", after, 1)
+	assert.NotNil(err)
+	_, err = GetCronNextRunTimes("0 0 * * *", after, MaxCronRunTimes+1)
+	assert.NotNil(err)
+
+	interval, err := GetCronInterval("@hourly")
+	assert.Nil(err)
+	assert.Equal(time.Hour, interval)
+}
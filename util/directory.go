@@ -0,0 +1,163 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	iscsiutil "github.com/longhorn/go-iscsi-helper/util"
+)
+
+const (
+	DirectoryEntryTypeFile      = "file"
+	DirectoryEntryTypeDirectory = "directory"
+	DirectoryEntryTypeSymlink   = "symlink"
+	DirectoryEntryTypeOther     = "other"
+
+	hostReadOnlyMountDirectoryPrefix = "/tmp/longhorn-ro-mount-"
+)
+
+// DirectoryEntry is an entry of a directory browsed from outside, e.g. a
+// filesystem restored from a backup. The symlinks are listed but never
+// followed, since they may point out of the directory.
+type DirectoryEntry struct {
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	FileType   string `json:"fileType"`
+	Size       int64  `json:"size"`
+	ModTime    string `json:"modTime"`
+	LinkTarget string `json:"linkTarget"`
+}
+
+// ResolvePathInDirectory returns the path of the relative path p in the
+// directory root. It fails if any component of p is a symlink, so the path
+// never leaves the root.
+func ResolvePathInDirectory(root, p string) (string, error) {
+	cleaned := filepath.Clean("/" + p)
+	resolved := root
+	for _, component := range strings.Split(cleaned, "/") {
+		if component == "" {
+			continue
+		}
+		resolved = filepath.Join(resolved, component)
+		info, err := os.Lstat(resolved)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("path %v contains symlink %v", cleaned, component)
+		}
+	}
+	return resolved, nil
+}
+
+// ListDirectoryEntries lists the entries of the relative path p in the
+// directory root, sorted by the name.
+func ListDirectoryEntries(root, p string) (entries []*DirectoryEntry, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to list directory %v", p)
+	}()
+
+	dir, err := ResolvePathInDirectory(root, p)
+	if err != nil {
+		return nil, err
+	}
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries = []*DirectoryEntry{}
+	for _, dirEntry := range dirEntries {
+		info, err := dirEntry.Info()
+		if err != nil {
+			// Removed in between
+			continue
+		}
+		entry := &DirectoryEntry{
+			Name:     dirEntry.Name(),
+			Path:     filepath.Join(filepath.Clean("/"+p), dirEntry.Name()),
+			FileType: DirectoryEntryTypeOther,
+			ModTime:  FormatTimeZ(info.ModTime()),
+		}
+		switch {
+		case info.Mode().IsRegular():
+			entry.FileType = DirectoryEntryTypeFile
+			entry.Size = info.Size()
+		case info.IsDir():
+			entry.FileType = DirectoryEntryTypeDirectory
+		case info.Mode()&os.ModeSymlink != 0:
+			entry.FileType = DirectoryEntryTypeSymlink
+			if target, err := os.Readlink(filepath.Join(dir, dirEntry.Name())); err == nil {
+				entry.LinkTarget = target
+			}
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// OpenFileInDirectory opens the regular file of the relative path p in the
+// directory root. The caller closes the file.
+func OpenFileInDirectory(root, p string) (*os.File, os.FileInfo, error) {
+	path, err := ResolvePathInDirectory(root, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil, fmt.Errorf("%v is not a regular file", p)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+// MountDeviceReadOnly mounts the filesystem of the device read-only on the
+// host, and returns the mount directory as seen from the manager container.
+// The caller unmounts it once done.
+func MountDeviceReadOnly(device string) (root string, unmount func() error, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to mount device %v read-only", device)
+	}()
+
+	nsPath := iscsiutil.GetHostNamespacePath(HostProcPath)
+	nsExec, err := iscsiutil.NewNamespaceExecutor(nsPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	hostDir := hostReadOnlyMountDirectoryPrefix + RandomID()
+	if _, err := nsExec.Execute("mkdir", []string{"-p", hostDir}); err != nil {
+		return "", nil, err
+	}
+	if _, err := nsExec.Execute("mount", []string{"-o", "ro", device, hostDir}); err != nil {
+		if _, rmErr := nsExec.Execute("rmdir", []string{hostDir}); rmErr != nil {
+			err = errors.Wrapf(err, "failed to remove mount directory %v: %v", hostDir, rmErr)
+		}
+		return "", nil, err
+	}
+
+	unmount = func() error {
+		if _, err := nsExec.Execute("umount", []string{hostDir}); err != nil {
+			return errors.Wrapf(err, "failed to unmount %v", hostDir)
+		}
+		_, err := nsExec.Execute("rmdir", []string{hostDir})
+		return err
+	}
+	// The host mount namespace is seen through the root of the host init
+	// process
+	return filepath.Join(HostProcPath, "1", "root", hostDir), unmount, nil
+}
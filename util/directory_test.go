@@ -0,0 +1,52 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListDirectoryEntries(t *testing.T) {
+	assert := require.New(t)
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	assert.Nil(os.MkdirAll(filepath.Join(root, "etc", "conf.d"), 0755))
+	assert.Nil(os.WriteFile(filepath.Join(root, "etc", "hosts"), []byte("127.0.0.1 localhost\n"), 0644))
+	assert.Nil(os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644))
+	assert.Nil(os.Symlink(outside, filepath.Join(root, "escape")))
+	assert.Nil(os.Symlink("/etc/hosts", filepath.Join(root, "etc", "hosts.link")))
+
+	entries, err := ListDirectoryEntries(root, "/etc/../etc")
+	assert.Nil(err)
+	assert.Len(entries, 3)
+	assert.Equal("conf.d", entries[0].Name)
+	assert.Equal(DirectoryEntryTypeDirectory, entries[0].FileType)
+	assert.Equal("/etc/hosts", entries[1].Path)
+	assert.Equal(DirectoryEntryTypeFile, entries[1].FileType)
+	assert.Equal(int64(20), entries[1].Size)
+	assert.Equal(DirectoryEntryTypeSymlink, entries[2].FileType)
+	assert.Equal("/etc/hosts", entries[2].LinkTarget)
+
+	// The parent of the root is the root
+	entries, err = ListDirectoryEntries(root, "../..")
+	assert.Nil(err)
+	assert.Len(entries, 2)
+
+	// The symlinks are never followed
+	_, err = ListDirectoryEntries(root, "escape")
+	assert.NotNil(err)
+	_, _, err = OpenFileInDirectory(root, "escape/secret")
+	assert.NotNil(err)
+	_, _, err = OpenFileInDirectory(root, "etc/hosts.link")
+	assert.NotNil(err)
+	_, _, err = OpenFileInDirectory(root, "etc/conf.d")
+	assert.NotNil(err)
+
+	f, info, err := OpenFileInDirectory(root, "etc/hosts")
+	assert.Nil(err)
+	defer f.Close()
+	assert.Equal(int64(20), info.Size())
+}
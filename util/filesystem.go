@@ -0,0 +1,197 @@
+package util
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	iscsiutil "github.com/longhorn/go-iscsi-helper/util"
+)
+
+const (
+	FilesystemTypeExt4 = "ext4"
+	FilesystemTypeXFS  = "xfs"
+
+	filesystemCheckOutputMaxLines = 10
+
+	formatDeviceTimeout = 5 * time.Minute
+)
+
+// FilesystemCheckResult is the result of a filesystem check that ran to the
+// end, whether errors are found or not.
+type FilesystemCheckResult struct {
+	// Clean means no error is left in the filesystem, after the repair if
+	// it's requested.
+	Clean bool
+	// Output is the tail of the check output, which explains the errors.
+	Output string
+}
+
+// CheckFilesystem checks the filesystem on the device in the host mount
+// namespace. Without repair, the check never modifies the filesystem, and it
+// fails if the device is mounted, since a filesystem in use looks
+// inconsistent to the check. The repair fixes the errors found, and the check
+// tools refuse to repair a mounted filesystem.
+func CheckFilesystem(device, fsType string, repair bool) (result *FilesystemCheckResult, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to check %v filesystem on device %v", fsType, device)
+	}()
+
+	command, args, err := getFilesystemCheckCommand(fsType, repair)
+	if err != nil {
+		return nil, err
+	}
+
+	nsPath := iscsiutil.GetHostNamespacePath(HostProcPath)
+	nsExec, err := iscsiutil.NewNamespaceExecutor(nsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// findmnt fails if the device is not mounted
+	if output, err := nsExec.Execute("findmnt", []string{"--noheadings", "--source", device}); err == nil && strings.TrimSpace(output) != "" {
+		return nil, fmt.Errorf("device is mounted on %v", strings.TrimSpace(output))
+	}
+
+	exitCode := 0
+	output, err := nsExec.ExecuteWithoutTimeout(command, append(args, device))
+	if err != nil {
+		exitErr, ok := errors.Cause(err).(*exec.ExitError)
+		if !ok {
+			return nil, err
+		}
+		exitCode = exitErr.ExitCode()
+		// The error holds both the output and the error output
+		output = err.Error()
+	}
+	output = tailLines(output, filesystemCheckOutputMaxLines)
+
+	clean, err := parseFilesystemCheckExitCode(fsType, repair, exitCode)
+	if err != nil {
+		return nil, errors.Wrapf(err, "check output: %v", output)
+	}
+	return &FilesystemCheckResult{Clean: clean, Output: output}, nil
+}
+
+func getFilesystemCheckCommand(fsType string, repair bool) (string, []string, error) {
+	switch fsType {
+	case FilesystemTypeExt4:
+		if repair {
+			return "e2fsck", []string{"-f", "-y"}, nil
+		}
+		return "e2fsck", []string{"-f", "-n"}, nil
+	case FilesystemTypeXFS:
+		if repair {
+			return "xfs_repair", []string{}, nil
+		}
+		return "xfs_repair", []string{"-n"}, nil
+	}
+	return "", nil, fmt.Errorf("unsupported filesystem type %v", fsType)
+}
+
+// parseFilesystemCheckExitCode tells whether the filesystem is clean from the
+// exit code of the check, or fails if the check couldn't run to the end.
+func parseFilesystemCheckExitCode(fsType string, repair bool, exitCode int) (bool, error) {
+	switch fsType {
+	case FilesystemTypeExt4:
+		// The exit code of e2fsck is the sum of the conditions: 1 for the
+		// errors corrected, 2 for the reboot needed, 4 for the errors left
+		// uncorrected, and higher ones for the operational errors
+		if exitCode >= 8 {
+			return false, fmt.Errorf("e2fsck exited with code %v", exitCode)
+		}
+		return exitCode&4 == 0, nil
+	case FilesystemTypeXFS:
+		// xfs_repair -n exits with 1 if it finds corruption. Otherwise
+		// anything but 0 means the check or the repair failed, e.g. 2 for a
+		// dirty log to replay by mounting the filesystem first.
+		if exitCode == 0 {
+			return true, nil
+		}
+		if exitCode == 1 && !repair {
+			return false, nil
+		}
+		return false, fmt.Errorf("xfs_repair exited with code %v", exitCode)
+	}
+	return false, fmt.Errorf("unsupported filesystem type %v", fsType)
+}
+
+// FormatDeviceIfBlank formats the device with the filesystem in the host
+// mount namespace, unless the device already has a filesystem or a
+// partition table. It returns the existing format of the device, or the
+// filesystem if it's formatted.
+func FormatDeviceIfBlank(device, fsType string) (format string, formatted bool, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to format device %v with %v filesystem", device, fsType)
+	}()
+
+	var args []string
+	switch fsType {
+	case FilesystemTypeExt4:
+		// A blank volume reads zeros, so discarding the blocks is a waste
+		args = []string{"-E", "nodiscard"}
+	case FilesystemTypeXFS:
+		args = []string{"-K"}
+	default:
+		return "", false, fmt.Errorf("unsupported filesystem type %v", fsType)
+	}
+
+	nsPath := iscsiutil.GetHostNamespacePath(HostProcPath)
+	nsExec, err := iscsiutil.NewNamespaceExecutor(nsPath)
+	if err != nil {
+		return "", false, err
+	}
+
+	if format, err = getDeviceFormat(nsExec, device); err != nil || format != "" {
+		return format, false, err
+	}
+	if _, err := nsExec.ExecuteWithTimeout(formatDeviceTimeout, "mkfs."+fsType, append(args, device)); err != nil {
+		return "", false, err
+	}
+	return fsType, true, nil
+}
+
+// getDeviceFormat returns the filesystem type or the partition table type on
+// the device, or empty if the device is blank.
+func getDeviceFormat(nsExec *iscsiutil.NamespaceExecutor, device string) (string, error) {
+	output, err := nsExec.Execute("blkid", []string{"-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", device})
+	if err != nil {
+		// blkid exits with 2 if no signature is found on the device
+		if exitErr, ok := errors.Cause(err).(*exec.ExitError); ok && exitErr.ExitCode() == 2 {
+			return "", nil
+		}
+		return "", err
+	}
+	return parseBlkidFormat(output), nil
+}
+
+func parseBlkidFormat(output string) string {
+	fsType, ptType := "", ""
+	for _, line := range strings.Split(output, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "TYPE":
+			fsType = kv[1]
+		case "PTTYPE":
+			ptType = kv[1]
+		}
+	}
+	if fsType != "" {
+		return fsType
+	}
+	return ptType
+}
+
+func tailLines(s string, count int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > count {
+		lines = lines[len(lines)-count:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilesystemCheckExitCode(t *testing.T) {
+	assert := require.New(t)
+
+	for _, tc := range []struct {
+		fsType    string
+		repair    bool
+		exitCode  int
+		clean     bool
+		expectErr bool
+	}{
+		{FilesystemTypeExt4, false, 0, true, false},
+		{FilesystemTypeExt4, false, 4, false, false},
+		{FilesystemTypeExt4, true, 1, true, false},
+		{FilesystemTypeExt4, true, 3, true, false},
+		{FilesystemTypeExt4, true, 5, false, false},
+		{FilesystemTypeExt4, false, 8, false, true},
+		{FilesystemTypeXFS, false, 0, true, false},
+		{FilesystemTypeXFS, false, 1, false, false},
+		{FilesystemTypeXFS, true, 1, false, true},
+		{FilesystemTypeXFS, true, 2, false, true},
+		{"btrfs", false, 0, false, true},
+	} {
+		clean, err := parseFilesystemCheckExitCode(tc.fsType, tc.repair, tc.exitCode)
+		if tc.expectErr {
+			assert.NotNil(err, "%+v", tc)
+			continue
+		}
+		assert.Nil(err, "%+v", tc)
+		assert.Equal(tc.clean, clean, "%+v", tc)
+	}
+
+	assert.Equal("c\nd", tailLines("a\nb\nc\nd\n", 2))
+}
+
+func TestParseBlkidFormat(t *testing.T) {
+	assert := require.New(t)
+
+	assert.Equal(FilesystemTypeXFS, parseBlkidFormat("DEVNAME=/dev/longhorn/vol\nTYPE=xfs\n"))
+	assert.Equal("gpt", parseBlkidFormat("DEVNAME=/dev/longhorn/vol\nPTTYPE=gpt\n"))
+	assert.Equal("", parseBlkidFormat(""))
+}
@@ -0,0 +1,47 @@
+package util
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// RequestIDHeader carries the ID of a user request, so the request can be
+	// traced across the managers it's forwarded to.
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDMetadataKey carries the request ID in the gRPC metadata of
+	// the calls to the instance managers.
+	RequestIDMetadataKey = "x-request-id"
+
+	requestIDLogField = "requestID"
+)
+
+type requestIDContextKey struct{}
+
+func NewRequestID() string {
+	return UUID()
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// GetRequestID returns the request ID in the context, or empty if there is
+// none, e.g. for the operations not started by a user request.
+func GetRequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// WithRequestIDField adds the request ID in the context to the logger, if
+// any.
+func WithRequestIDField(ctx context.Context, logger logrus.FieldLogger) logrus.FieldLogger {
+	if requestID := GetRequestID(ctx); requestID != "" {
+		return logger.WithField(requestIDLogField, requestID)
+	}
+	return logger
+}
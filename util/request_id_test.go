@@ -0,0 +1,27 @@
+package util
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	assert := require.New(t)
+
+	var nilCtx context.Context
+	assert.Empty(GetRequestID(nilCtx))
+	assert.Empty(GetRequestID(context.Background()))
+	ctx := WithRequestID(context.Background(), "request-1")
+	assert.Equal("request-1", GetRequestID(ctx))
+	assert.NotEqual(NewRequestID(), NewRequestID())
+
+	logger := logrus.New()
+	entry, ok := WithRequestIDField(ctx, logger).(*logrus.Entry)
+	assert.True(ok)
+	assert.Equal("request-1", entry.Data[requestIDLogField])
+	// The logger is kept as is without a request ID
+	assert.Equal(logger, WithRequestIDField(context.Background(), logger))
+}
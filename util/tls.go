@@ -0,0 +1,106 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// CertificateReloader serves a certificate key pair from files, and reloads
+// them once modified, e.g. renewed by cert-manager, without restarting.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	lock    sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	r := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if _, err := r.getCertificate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertificateReloader) getCertificate() (*tls.Certificate, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	modTime := time.Time{}
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			if r.cert != nil {
+				// The files may be in the middle of an update
+				logrus.WithError(err).Warnf("Failed to check certificate file %v, keep using the loaded one", file)
+				return r.cert, nil
+			}
+			return nil, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	if r.cert != nil && !modTime.After(r.modTime) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			logrus.WithError(err).Warnf("Failed to reload certificate %v, keep using the loaded one", r.certFile)
+			return r.cert, nil
+		}
+		return nil, errors.Wrapf(err, "failed to load certificate %v and key %v", r.certFile, r.keyFile)
+	}
+	if r.cert != nil {
+		logrus.Infof("Reloaded certificate %v", r.certFile)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return r.cert, nil
+}
+
+// GetCertificate is for tls.Config of the servers.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.getCertificate()
+}
+
+// GetClientCertificate is for tls.Config of the clients.
+func (r *CertificateReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.getCertificate()
+}
+
+// LoadCertPool returns the pool of the PEM encoded certificates in the file.
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := ParseCertPool(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid CA file %v", caFile)
+	}
+	return pool, nil
+}
+
+// ParseCertPool returns the pool of the PEM encoded certificates.
+func ParseCertPool(data []byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no valid certificate found")
+	}
+	return pool, nil
+}
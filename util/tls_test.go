@@ -0,0 +1,75 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCertificate(t *testing.T, certFile, keyFile, commonName string, modTime time.Time) {
+	assert := require.New(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(err)
+
+	assert.Nil(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	assert.Nil(os.Chtimes(certFile, modTime, modTime))
+	assert.Nil(os.Chtimes(keyFile, modTime, modTime))
+}
+
+func TestCertificateReloader(t *testing.T) {
+	assert := require.New(t)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	_, err := NewCertificateReloader(certFile, keyFile)
+	assert.NotNil(err)
+
+	now := time.Now()
+	writeTestCertificate(t, certFile, keyFile, "old", now.Add(-time.Minute))
+	r, err := NewCertificateReloader(certFile, keyFile)
+	assert.Nil(err)
+	cert, err := r.GetCertificate(nil)
+	assert.Nil(err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(err)
+	assert.Equal("old", leaf.Subject.CommonName)
+
+	writeTestCertificate(t, certFile, keyFile, "new", now)
+	cert, err = r.GetClientCertificate(nil)
+	assert.Nil(err)
+	leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(err)
+	assert.Equal("new", leaf.Subject.CommonName)
+
+	// Keep using the loaded certificate if the new one is invalid
+	assert.Nil(os.WriteFile(keyFile, []byte("invalid"), 0600))
+	assert.Nil(os.Chtimes(keyFile, now.Add(time.Minute), now.Add(time.Minute)))
+	cert, err = r.GetCertificate(nil)
+	assert.Nil(err)
+	leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(err)
+	assert.Equal("new", leaf.Subject.CommonName)
+}
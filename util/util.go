@@ -2,6 +2,7 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
@@ -219,10 +220,20 @@ func Execute(envs []string, binary string, args ...string) (string, error) {
 }
 
 func ExecuteWithTimeout(timeout time.Duration, envs []string, binary string, args ...string) (string, error) {
+	return executeWithContext(context.Background(), timeout, envs, binary, args...)
+}
+
+// ExecuteWithContext is Execute, but the process is also killed once the
+// context is done.
+func ExecuteWithContext(ctx context.Context, envs []string, binary string, args ...string) (string, error) {
+	return executeWithContext(ctx, cmdTimeout, envs, binary, args...)
+}
+
+func executeWithContext(ctx context.Context, timeout time.Duration, envs []string, binary string, args ...string) (string, error) {
 	var err error
 	cmd := exec.Command(binary, args...)
 	cmd.Env = append(os.Environ(), envs...)
-	done := make(chan struct{})
+	done := make(chan struct{}, 1)
 
 	var output, stderr bytes.Buffer
 	cmd.Stdout = &output
@@ -233,17 +244,25 @@ func ExecuteWithTimeout(timeout time.Duration, envs []string, binary string, arg
 		done <- struct{}{}
 	}()
 
-	select {
-	case <-done:
-	case <-time.After(timeout):
+	kill := func() {
 		if cmd.Process != nil {
 			if err := cmd.Process.Kill(); err != nil {
 				logrus.Warnf("Problem killing process pid=%v: %s", cmd.Process.Pid, err)
 			}
 
 		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		kill()
 		return "", fmt.Errorf("timeout executing: %v %v, output %s, stderr, %s, error %v",
 			binary, args, output.String(), stderr.String(), err)
+	case <-ctx.Done():
+		kill()
+		return "", fmt.Errorf("canceled executing: %v %v, output %s, stderr, %s, error %v",
+			binary, args, output.String(), stderr.String(), ctx.Err())
 	}
 
 	if err != nil {
@@ -831,20 +850,97 @@ func GetPossibleReplicaDirectoryNames(diskPath string) (replicaDirectoryNames ma
 
 	initiatorNSPath := iscsiutil.GetHostNamespacePath(HostProcPath)
 	mountPath := fmt.Sprintf("--mount=%s/mnt", initiatorNSPath)
-	command := fmt.Sprintf("find %s -type d -maxdepth 1 -mindepth 1 -regextype posix-extended -regex \".*-[a-zA-Z0-9]{8}$\" -exec basename {} \\;", directory)
+	// Replica directories can be nested one level deeper when the replica data
+	// directory name format contains a per-volume subdirectory.
+	command := fmt.Sprintf("find %s -type d -maxdepth 2 -mindepth 1 -regextype posix-extended -regex \".*-[a-zA-Z0-9]{8}$\" -printf \"%%P\\n\"", directory)
 	output, err := Execute([]string{}, "nsenter", mountPath, "sh", "-c", command)
 	if err != nil {
 		return replicaDirectoryNames, err
 	}
 
-	names := strings.Split(output, "\n")
+	return filterReplicaDirectoryNames(strings.Split(output, "\n")), nil
+}
+
+// filterReplicaDirectoryNames drops the per-volume parent directories from
+// the directory names found in the replicas directory. A parent directory
+// may look like a replica directory as well, e.g. the one of a volume named
+// with a random suffix, but it must never be taken as replica data, otherwise
+// removing it as an orphan removes all the replicas in it. The flat and the
+// nested layouts can coexist in the same disk.
+func filterReplicaDirectoryNames(names []string) map[string]string {
+	parents := map[string]bool{}
+	for _, name := range names {
+		if parent := filepath.Dir(name); name != "" && parent != "." {
+			parents[parent] = true
+		}
+	}
+
+	replicaDirectoryNames := map[string]string{}
 	for _, name := range names {
-		if name != "" {
+		if name != "" && !parents[name] {
 			replicaDirectoryNames[name] = ""
 		}
 	}
+	return replicaDirectoryNames
+}
 
-	return replicaDirectoryNames, nil
+// GetReplicaDirectoryActualSizes returns the space allocated on the disk by
+// each replica directory, which is less than the volume size if the replica
+// is sparse. The directories not found are skipped.
+func GetReplicaDirectoryActualSizes(diskPath string, replicaDirectoryNames []string) (map[string]int64, error) {
+	initiatorNSPath := iscsiutil.GetHostNamespacePath(HostProcPath)
+	mountPath := fmt.Sprintf("--mount=%s/mnt", initiatorNSPath)
+	return getReplicaDirectoryActualSizes([]string{"nsenter", mountPath}, diskPath, replicaDirectoryNames)
+}
+
+// getReplicaDirectoryActualSizes runs du after the command prefix, e.g. to
+// enter the host mount namespace. The directories are passed as arguments
+// without a shell, and a name that is not a plain directory name is skipped,
+// so a name can never escape the replicas directory.
+func getReplicaDirectoryActualSizes(commandPrefix []string, diskPath string, replicaDirectoryNames []string) (actualSizes map[string]int64, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "cannot get replica directory sizes in the disk %v", diskPath)
+	}()
+
+	actualSizes = map[string]int64{}
+
+	directory := filepath.Join(diskPath, "replicas")
+	command := append([]string{}, commandPrefix...)
+	command = append(command, "du", "-s", "-B1", "--")
+	paths := 0
+	for _, name := range replicaDirectoryNames {
+		if name == "" || name == "." || name == ".." || strings.ContainsRune(name, filepath.Separator) {
+			continue
+		}
+		command = append(command, filepath.Join(directory, name))
+		paths++
+	}
+	if paths == 0 {
+		return actualSizes, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cmdTimeout)
+	defer cancel()
+	// du fails if any directory is not found, but still prints the others
+	output, err := exec.CommandContext(ctx, command[0], command[1:]...).Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok || ctx.Err() != nil {
+			return actualSizes, err
+		}
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		actualSizes[filepath.Base(fields[1])] = size
+	}
+	return actualSizes, nil
 }
 
 func DeleteReplicaDirectoryName(diskPath, replicaDirectoryName string) (err error) {
@@ -861,7 +957,27 @@ func DeleteReplicaDirectoryName(diskPath, replicaDirectoryName string) (err erro
 		return err
 	}
 
-	return nil
+	return RemoveEmptyReplicaParentDirectory(diskPath, replicaDirectoryName)
+}
+
+// RemoveEmptyReplicaParentDirectory removes the per-volume parent directory
+// of the replica directory once the last replica in it is removed. Nothing is
+// done for the replica directories directly in the replicas directory.
+func RemoveEmptyReplicaParentDirectory(diskPath, replicaDirectoryName string) (err error) {
+	parent := filepath.Dir(filepath.Clean(replicaDirectoryName))
+	if parent == "." || parent == "/" || strings.Contains(parent, "..") {
+		return nil
+	}
+	defer func() {
+		err = errors.Wrapf(err, "cannot remove replica parent directory %v in disk %v", parent, diskPath)
+	}()
+
+	path := filepath.Join(diskPath, "replicas", parent)
+
+	initiatorNSPath := iscsiutil.GetHostNamespacePath(HostProcPath)
+	mountPath := fmt.Sprintf("--mount=%s/mnt", initiatorNSPath)
+	_, err = Execute([]string{}, "nsenter", mountPath, "rmdir", "--ignore-fail-on-non-empty", path)
+	return err
 }
 
 type VolumeMeta struct {
@@ -895,6 +1011,106 @@ func GetVolumeMeta(path string) (*VolumeMeta, error) {
 	return meta, nil
 }
 
+type ReplicaDataFile struct {
+	Name string
+	Size int64
+	Mode os.FileMode
+}
+
+// ListReplicaDataFiles lists the regular files in the replica data directory on the host
+func ListReplicaDataFiles(path string) ([]ReplicaDataFile, error) {
+	nsPath := iscsiutil.GetHostNamespacePath(HostProcPath)
+	nsExec, err := iscsiutil.NewNamespaceExecutor(nsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := nsExec.Execute("find", []string{path, "-maxdepth", "1", "-type", "f", "-printf", "%f %s %m\n"})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list replica data files in %v on host: %v", path, err)
+	}
+
+	files := []ReplicaDataFile{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid size of replica data file %v", fields[0])
+		}
+		mode, err := strconv.ParseUint(fields[2], 8, 32)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid mode of replica data file %v", fields[0])
+		}
+		files = append(files, ReplicaDataFile{
+			Name: fields[0],
+			Size: size,
+			Mode: os.FileMode(mode),
+		})
+	}
+	return files, nil
+}
+
+func ReadHostFile(path string) (string, error) {
+	nsPath := iscsiutil.GetHostNamespacePath(HostProcPath)
+	nsExec, err := iscsiutil.NewNamespaceExecutor(nsPath)
+	if err != nil {
+		return "", err
+	}
+
+	return nsExec.Execute("cat", []string{path})
+}
+
+func ChmodHostFile(path, mode string) error {
+	nsPath := iscsiutil.GetHostNamespacePath(HostProcPath)
+	nsExec, err := iscsiutil.NewNamespaceExecutor(nsPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = nsExec.Execute("chmod", []string{mode, path})
+	return err
+}
+
+// WriteHostDevicePattern fills the first size bytes of the block device with
+// the repeated pattern line, bypassing the page cache.
+func WriteHostDevicePattern(devicePath, pattern string, size int64) error {
+	nsPath := iscsiutil.GetHostNamespacePath(HostProcPath)
+	nsExec, err := iscsiutil.NewNamespaceExecutor(nsPath)
+	if err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("yes %s | head -c %d | dd of=%s bs=%d count=1 oflag=direct conv=fsync", pattern, size, devicePath, size)
+	if _, err := nsExec.Execute("bash", []string{"-c", cmd}); err != nil {
+		return fmt.Errorf("cannot write pattern to device %v on host: %v", devicePath, err)
+	}
+	return nil
+}
+
+// GetHostDeviceChecksum returns the SHA256 checksum of the first size bytes
+// of the block device.
+func GetHostDeviceChecksum(devicePath string, size int64) (string, error) {
+	nsPath := iscsiutil.GetHostNamespacePath(HostProcPath)
+	nsExec, err := iscsiutil.NewNamespaceExecutor(nsPath)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := fmt.Sprintf("dd if=%s bs=%d count=1 iflag=direct 2>/dev/null | sha256sum", devicePath, size)
+	output, err := nsExec.Execute("bash", []string{"-c", cmd})
+	if err != nil {
+		return "", fmt.Errorf("cannot read device %v on host: %v", devicePath, err)
+	}
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum output of device %v", devicePath)
+	}
+	return fields[0], nil
+}
+
 func CapitalizeFirstLetter(input string) string {
 	return strings.ToUpper(input[:1]) + input[1:]
 }
@@ -913,6 +1129,62 @@ func TrimFilesystem(volumeName string, isEncryptedDevice bool) error {
 		return err
 	}
 
+	mountpoint, err := getVolumeMountpoint(nsExec, volumeName, isEncryptedDevice)
+	if err != nil {
+		return err
+	}
+
+	_, err = nsExec.Execute("fstrim", []string{mountpoint})
+	if err != nil {
+		return fmt.Errorf("cannot find volume %v mount info on host: %v", volumeName, err)
+	}
+
+	return nil
+}
+
+// FreezeFilesystem suspends the writes to the filesystem of the volume, so
+// it's consistent for an external snapshot. Freezing one mountpoint freezes
+// the filesystem for all the mountpoints. Freezing a frozen filesystem
+// succeeds.
+func FreezeFilesystem(volumeName string, isEncryptedDevice bool) error {
+	err := runFsfreeze(volumeName, isEncryptedDevice, "-f")
+	// fsfreeze fails with EBUSY if the filesystem is already frozen
+	if err != nil && strings.Contains(err.Error(), "Device or resource busy") {
+		return nil
+	}
+	return err
+}
+
+// UnfreezeFilesystem resumes the writes to the filesystem of the volume. It's
+// a no-op if the filesystem is not frozen.
+func UnfreezeFilesystem(volumeName string, isEncryptedDevice bool) error {
+	err := runFsfreeze(volumeName, isEncryptedDevice, "-u")
+	// fsfreeze fails with EINVAL if the filesystem is not frozen
+	if err != nil && strings.Contains(err.Error(), "Invalid argument") {
+		return nil
+	}
+	return err
+}
+
+func runFsfreeze(volumeName string, isEncryptedDevice bool, flag string) error {
+	nsPath := iscsiutil.GetHostNamespacePath(HostProcPath)
+	nsExec, err := iscsiutil.NewNamespaceExecutor(nsPath)
+	if err != nil {
+		return err
+	}
+
+	mountpoint, err := getVolumeMountpoint(nsExec, volumeName, isEncryptedDevice)
+	if err != nil {
+		return err
+	}
+
+	if _, err := nsExec.Execute("fsfreeze", []string{flag, mountpoint}); err != nil {
+		return errors.Wrapf(err, "failed to run fsfreeze %v on volume %v mountpoint %v", flag, volumeName, mountpoint)
+	}
+	return nil
+}
+
+func getVolumeMountpoint(nsExec *iscsiutil.NamespaceExecutor, volumeName string, isEncryptedDevice bool) (string, error) {
 	deviceDir := RegularDeviceDirectory
 	if isEncryptedDevice {
 		deviceDir = EncryptedDeviceDirectory
@@ -920,31 +1192,20 @@ func TrimFilesystem(volumeName string, isEncryptedDevice bool) error {
 
 	mountOutput, err := nsExec.Execute("bash", []string{"-c", fmt.Sprintf("cat /proc/mounts | grep %s%s | awk '{print $2}'", deviceDir, volumeName)})
 	if err != nil {
-		return fmt.Errorf("cannot find volume %v mount info on host: %v", volumeName, err)
+		return "", fmt.Errorf("cannot find volume %v mount info on host: %v", volumeName, err)
 	}
 
 	mountList := strings.Split(strings.TrimSpace(mountOutput), "\n")
 
-	var mountpoint string
 	for _, m := range mountList {
 		_, err = nsExec.Execute("stat", []string{m})
 		if err == nil {
-			mountpoint = m
-			break
+			return m, nil
 		}
 
 		logrus.WithError(err).Warnf("failed to get volume %v mountpoint %v info", volumeName, m)
 	}
-	if mountpoint == "" {
-		return fmt.Errorf("cannot find a valid mountpoint for volume %v", volumeName)
-	}
-
-	_, err = nsExec.Execute("fstrim", []string{mountpoint})
-	if err != nil {
-		return fmt.Errorf("cannot find volume %v mount info on host: %v", volumeName, err)
-	}
-
-	return nil
+	return "", fmt.Errorf("cannot find a valid mountpoint for volume %v", volumeName)
 }
 
 // SortKeys accepts a map with string keys and returns a sorted slice of keys
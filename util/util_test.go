@@ -1,8 +1,13 @@
 package util
 
 import (
-	"github.com/stretchr/testify/require"
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestConvertSize(t *testing.T) {
@@ -43,3 +48,78 @@ func TestRoundUpSize(t *testing.T) {
 	assert.Equal(int64(SizeAlignment), RoundUpSize(0))
 	assert.Equal(int64(2*SizeAlignment), RoundUpSize(SizeAlignment+1))
 }
+
+func TestFilterReplicaDirectoryNames(t *testing.T) {
+	assert := require.New(t)
+
+	names := filterReplicaDirectoryNames([]string{
+		// flat layout
+		"vol1-abcdef12",
+		// per-volume layout, with the parent looking like a replica directory
+		"pvc-1234abcd",
+		"pvc-1234abcd/pvc-1234abcd-0123abcd",
+		"pvc-1234abcd/pvc-1234abcd-4567abcd",
+		"",
+	})
+	assert.Equal(map[string]string{
+		"vol1-abcdef12":                      "",
+		"pvc-1234abcd/pvc-1234abcd-0123abcd": "",
+		"pvc-1234abcd/pvc-1234abcd-4567abcd": "",
+	}, names)
+
+	// A parent without replicas left looks like a replica directory again,
+	// since it cannot be told apart from one
+	names = filterReplicaDirectoryNames([]string{"pvc-1234abcd"})
+	assert.Equal(map[string]string{"pvc-1234abcd": ""}, names)
+}
+
+func TestGetReplicaDirectoryActualSizes(t *testing.T) {
+	assert := require.New(t)
+
+	diskPath := t.TempDir()
+	replicasPath := filepath.Join(diskPath, "replicas")
+	assert.Nil(os.MkdirAll(filepath.Join(replicasPath, "pvc-1-abcdef01"), 0755))
+	assert.Nil(os.WriteFile(filepath.Join(replicasPath, "pvc-1-abcdef01", "volume-head-000.img"), make([]byte, 64*1024), 0644))
+	assert.Nil(os.MkdirAll(filepath.Join(replicasPath, "pvc-2-abcdef01"), 0755))
+	marker := filepath.Join(diskPath, "injected")
+
+	actualSizes, err := getReplicaDirectoryActualSizes(nil, diskPath, []string{
+		"pvc-1-abcdef01",
+		"pvc-2-abcdef01",
+		// Skipped since it's not found
+		"pvc-3-abcdef01",
+		// Never run by a shell
+		"pvc-4$(touch " + marker + ")",
+		"; touch " + marker,
+		// Never escapes the replicas directory
+		"..",
+		"../replicas",
+		"-a",
+	})
+	assert.Nil(err)
+	assert.Len(actualSizes, 2)
+	assert.True(actualSizes["pvc-1-abcdef01"] >= 64*1024)
+	assert.Contains(actualSizes, "pvc-2-abcdef01")
+	_, err = os.Stat(marker)
+	assert.True(os.IsNotExist(err))
+
+	actualSizes, err = getReplicaDirectoryActualSizes(nil, diskPath, nil)
+	assert.Nil(err)
+	assert.Len(actualSizes, 0)
+}
+
+func TestExecuteWithContext(t *testing.T) {
+	assert := require.New(t)
+
+	output, err := ExecuteWithContext(context.Background(), []string{}, "echo", "hello")
+	assert.NoError(err)
+	assert.Equal("hello\n", output)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = ExecuteWithContext(ctx, []string{}, "sleep", "10")
+	assert.Error(err)
+	assert.Contains(err.Error(), "canceled executing")
+	assert.Less(time.Since(start), 5*time.Second)
+}
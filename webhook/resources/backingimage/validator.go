@@ -76,8 +76,8 @@ func (b *backingImageValidator) Create(request *admission.Request, newObj runtim
 		if err != nil {
 			return werror.NewInvalidError(fmt.Sprintf("failed to get then check engine image %v for volume %v before exporting backing image", eiName, volumeName), "")
 		}
-		if ei.Status.CLIAPIVersion < engineapi.CLIVersionFive {
-			return werror.NewInvalidError(fmt.Sprintf("engine image %v CLI version %v doesn't support this feature, please upgrade engine for volume %v before exporting backing image from the volume", eiName, ei.Status.CLIAPIVersion, volumeName), "")
+		if err := engineapi.CheckCLIFeatureSupport(engineapi.EngineFeatureBackingImageExport, ei.Status.CLIAPIVersion); err != nil {
+			return werror.NewInvalidError(fmt.Sprintf("engine image %v cannot be used for volume %v before exporting backing image from the volume: %v", eiName, volumeName, err), "")
 		}
 
 		if backingImage.Spec.SourceParameters[manager.DataSourceTypeExportFromVolumeParameterExportType] != manager.DataSourceTypeExportFromVolumeParameterExportTypeRAW &&
@@ -71,6 +71,22 @@ func (v *volumeValidator) Create(request *admission.Request, newObj runtime.Obje
 		return werror.NewInvalidError(err.Error(), "")
 	}
 
+	if err := types.ValidateVolumeExpireAt(volume.Spec.ExpireAt); err != nil {
+		return werror.NewInvalidError(err.Error(), "")
+	}
+
+	if err := types.ValidateVolumeSnapshotLimits(volume.Spec.SnapshotMaxCount, volume.Spec.SnapshotMaxAge); err != nil {
+		return werror.NewInvalidError(err.Error(), "")
+	}
+
+	if err := types.ValidateVolumeQoS(volume.Spec.RebuildBandwidthLimit, volume.Spec.FrontendIOPSLimit, volume.Spec.FrontendBandwidthLimit); err != nil {
+		return werror.NewInvalidError(err.Error(), "")
+	}
+
+	if err := types.ValidateVolumeFilesystem(volume.Spec.Filesystem, volume.Spec.MountOptions, volume.Spec.Frontend, volume.Spec.Encrypted); err != nil {
+		return werror.NewInvalidError(err.Error(), "")
+	}
+
 	if volume.Spec.BackingImage != "" {
 		if _, err := v.ds.GetBackingImage(volume.Spec.BackingImage); err != nil {
 			return werror.NewInvalidError(err.Error(), "")
@@ -99,10 +115,31 @@ func (v *volumeValidator) Create(request *admission.Request, newObj runtime.Obje
 		}
 	}
 
+	if volume.Spec.ReadOnly {
+		if err := v.checkEngineImageFeature(volume.Spec.EngineImage, engineapi.EngineFeatureFrontendReadOnly); err != nil {
+			return err
+		}
+	}
+
+	if hasQoSLimits(volume) {
+		if err := v.checkEngineImageFeature(volume.Spec.EngineImage, engineapi.EngineFeatureQoS); err != nil {
+			return err
+		}
+	}
+
 	if err := datastore.CheckVolume(volume); err != nil {
 		return werror.NewInvalidError(err.Error(), "")
 	}
 
+	if tenant := getVolumeTenant(volume); tenant != "" {
+		if err := types.ValidateTenant(tenant); err != nil {
+			return werror.NewInvalidError(err.Error(), "")
+		}
+		if err := v.checkTenantQuota(tenant, volume); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -130,6 +167,18 @@ func (v *volumeValidator) Update(request *admission.Request, oldObj runtime.Obje
 		return werror.NewInvalidError(err.Error(), "")
 	}
 
+	if err := types.ValidateVolumeExpireAt(newVolume.Spec.ExpireAt); err != nil {
+		return werror.NewInvalidError(err.Error(), "")
+	}
+
+	if err := types.ValidateVolumeSnapshotLimits(newVolume.Spec.SnapshotMaxCount, newVolume.Spec.SnapshotMaxAge); err != nil {
+		return werror.NewInvalidError(err.Error(), "")
+	}
+
+	if err := types.ValidateVolumeQoS(newVolume.Spec.RebuildBandwidthLimit, newVolume.Spec.FrontendIOPSLimit, newVolume.Spec.FrontendBandwidthLimit); err != nil {
+		return werror.NewInvalidError(err.Error(), "")
+	}
+
 	if newVolume.Spec.DataLocality == longhorn.DataLocalityStrictLocal {
 		// Check if the strict-local volume can attach to newVolume.Spec.NodeID
 		if oldVolume.Spec.NodeID != newVolume.Spec.NodeID && newVolume.Spec.NodeID != "" {
@@ -142,6 +191,24 @@ func (v *volumeValidator) Update(request *admission.Request, oldObj runtime.Obje
 		}
 	}
 
+	// The engine of a read-only volume fails to start without the support,
+	// so the volume would become unattachable
+	if newVolume.Spec.ReadOnly && (!oldVolume.Spec.ReadOnly || oldVolume.Spec.EngineImage != newVolume.Spec.EngineImage) {
+		if err := v.checkEngineImageFeature(newVolume.Spec.EngineImage, engineapi.EngineFeatureFrontendReadOnly); err != nil {
+			return err
+		}
+	}
+
+	// Otherwise the limits would be silently dropped by the engine
+	if hasQoSLimits(newVolume) && (oldVolume.Spec.EngineImage != newVolume.Spec.EngineImage ||
+		oldVolume.Spec.RebuildBandwidthLimit != newVolume.Spec.RebuildBandwidthLimit ||
+		oldVolume.Spec.FrontendIOPSLimit != newVolume.Spec.FrontendIOPSLimit ||
+		oldVolume.Spec.FrontendBandwidthLimit != newVolume.Spec.FrontendBandwidthLimit) {
+		if err := v.checkEngineImageFeature(newVolume.Spec.EngineImage, engineapi.EngineFeatureQoS); err != nil {
+			return err
+		}
+	}
+
 	if err := datastore.CheckVolume(newVolume); err != nil {
 		return werror.NewInvalidError(err.Error(), "")
 	}
@@ -153,6 +220,35 @@ func (v *volumeValidator) Update(request *admission.Request, oldObj runtime.Obje
 		}
 	}
 
+	// The tenant can't be changed, so a volume can't leave the quota of its
+	// tenant, or join another tenant over its quota
+	tenant := getVolumeTenant(newVolume)
+	if oldTenant := getVolumeTenant(oldVolume); oldTenant != tenant {
+		err := fmt.Errorf("changing tenant of volume %v from %q to %q is not supported", oldVolume.Name, oldTenant, tenant)
+		return werror.NewInvalidError(err.Error(), "")
+	}
+	if tenant != "" && newVolume.Spec.Size > oldVolume.Spec.Size {
+		if err := v.checkTenantQuota(tenant, newVolume); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getVolumeTenant(volume *longhorn.Volume) string {
+	return volume.Labels[types.GetLonghornLabelKey(types.LonghornLabelTenant)]
+}
+
+// checkTenantQuota enforces the tenant quota for the volumes created or
+// expanded without the manager API, e.g. by kubectl.
+func (v *volumeValidator) checkTenantQuota(tenant string, volume *longhorn.Volume) error {
+	if err := v.ds.CheckTenantQuota(tenant, volume.Name, volume.Spec.Size); err != nil {
+		if reasonErr := types.GetReasonError(err); reasonErr != nil && reasonErr.Reason == types.ErrorReasonQuotaExceeded {
+			return werror.NewForbiddenError(err.Error())
+		}
+		return werror.NewInternalError(err.Error())
+	}
 	return nil
 }
 
@@ -213,6 +309,21 @@ func validateReplicaCount(dataLocality longhorn.DataLocality, replicaCount int)
 	return nil
 }
 
+func hasQoSLimits(volume *longhorn.Volume) bool {
+	return volume.Spec.RebuildBandwidthLimit > 0 || volume.Spec.FrontendIOPSLimit > 0 || volume.Spec.FrontendBandwidthLimit > 0
+}
+
+func (v *volumeValidator) checkEngineImageFeature(engineImage string, feature engineapi.EngineFeature) error {
+	cliAPIVersion, err := v.ds.GetEngineImageCLIAPIVersion(engineImage)
+	if err != nil {
+		return werror.NewInvalidError(err.Error(), "")
+	}
+	if err := engineapi.CheckCLIFeatureSupport(feature, cliAPIVersion); err != nil {
+		return werror.NewInvalidError(err.Error(), "")
+	}
+	return nil
+}
+
 func (v *volumeValidator) canDisableRevisionCounter(engineImage string) (bool, error) {
 	cliAPIVersion, err := v.ds.GetEngineImageCLIAPIVersion(engineImage)
 	if err != nil {
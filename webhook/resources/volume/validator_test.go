@@ -0,0 +1,162 @@
+package volume
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/longhorn/longhorn-manager/engineapi"
+	"github.com/longhorn/longhorn-manager/test/fake"
+	"github.com/longhorn/longhorn-manager/types"
+
+	longhorn "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta2"
+	werror "github.com/longhorn/longhorn-manager/webhook/error"
+)
+
+const (
+	testNamespace = "longhorn-system"
+	testNode      = "node-1"
+	testSize      = 1 << 30
+
+	testEngineImage = "longhornio/longhorn-engine:latest"
+)
+
+func newTestVolume(name, tenant string, size int64) *longhorn.Volume {
+	v := &longhorn.Volume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+		},
+		Spec: longhorn.VolumeSpec{
+			Size:                      size,
+			NumberOfReplicas:          3,
+			EngineImage:               testEngineImage,
+			Frontend:                  longhorn.VolumeFrontendBlockDev,
+			AccessMode:                longhorn.AccessModeReadWriteOnce,
+			DataLocality:              longhorn.DataLocalityDisabled,
+			ReplicaAutoBalance:        longhorn.ReplicaAutoBalanceIgnored,
+			UnmapMarkSnapChainRemoved: longhorn.UnmapMarkSnapChainRemovedIgnored,
+		},
+	}
+	if tenant != "" {
+		v.Labels = map[string]string{types.GetLonghornLabelKey(types.LonghornLabelTenant): tenant}
+	}
+	return v
+}
+
+func TestValidateTenantQuota(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	existing := newTestVolume("vol-1", "team-a", testSize)
+	c, err := fake.NewCluster(testNamespace, stopCh,
+		&longhorn.Setting{
+			ObjectMeta: metav1.ObjectMeta{Name: string(types.SettingNameTenantQuotas), Namespace: testNamespace},
+			Value:      "team-a:volumes=2,size=2Gi",
+		},
+		existing,
+	)
+	assert.NoError(err)
+	v := NewValidator(c.DataStore, testNode)
+
+	assertAdmitError := func(err error, status int32) {
+		assert.Error(err)
+		admitErr, ok := err.(werror.AdmitError)
+		assert.True(ok, "unexpected error %v", err)
+		assert.Equal(status, admitErr.AsResult().Code, "unexpected error %v", err)
+	}
+
+	// Creation
+	assert.NoError(v.Create(nil, newTestVolume("vol-2", "team-a", testSize)))
+	assert.NoError(v.Create(nil, newTestVolume("vol-2", "", 10*testSize)))
+	assertAdmitError(v.Create(nil, newTestVolume("vol-2", "team-a", testSize+1)), http.StatusForbidden)
+	assertAdmitError(v.Create(nil, newTestVolume("vol-2", "Team_A", testSize)), http.StatusUnprocessableEntity)
+
+	// Expansion
+	expanded := existing.DeepCopy()
+	expanded.Spec.Size = 2 * testSize
+	assert.NoError(v.Update(nil, existing, expanded))
+	expanded.Spec.Size = 2*testSize + 1
+	assertAdmitError(v.Update(nil, existing, expanded), http.StatusForbidden)
+
+	// The tenant can be neither changed nor removed
+	moved := existing.DeepCopy()
+	moved.Labels[types.GetLonghornLabelKey(types.LonghornLabelTenant)] = "team-b"
+	assertAdmitError(v.Update(nil, existing, moved), http.StatusUnprocessableEntity)
+	removed := existing.DeepCopy()
+	removed.Labels = nil
+	assertAdmitError(v.Update(nil, existing, removed), http.StatusUnprocessableEntity)
+	added := newTestVolume("vol-untenanted", "", testSize)
+	assertAdmitError(v.Update(nil, added, newTestVolume("vol-untenanted", "team-a", testSize)), http.StatusUnprocessableEntity)
+}
+
+func newTestEngineImage(cliAPIVersion int) *longhorn.EngineImage {
+	return &longhorn.EngineImage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      types.GetEngineImageChecksumName(testEngineImage),
+			Namespace: testNamespace,
+		},
+		Spec: longhorn.EngineImageSpec{Image: testEngineImage},
+		Status: longhorn.EngineImageStatus{
+			State:                longhorn.EngineImageStateDeployed,
+			EngineVersionDetails: longhorn.EngineVersionDetails{CLIAPIVersion: cliAPIVersion},
+		},
+	}
+}
+
+func TestValidateReadOnly(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	for _, cliAPIVersion := range []int{engineapi.CLIVersionSeven, engineapi.CLIVersionEight} {
+		c, err := fake.NewCluster(testNamespace, stopCh, newTestEngineImage(cliAPIVersion))
+		assert.NoError(err)
+		v := NewValidator(c.DataStore, testNode)
+
+		readWrite := newTestVolume("vol-1", "", testSize)
+		readOnly := newTestVolume("vol-1", "", testSize)
+		readOnly.Spec.ReadOnly = true
+		if cliAPIVersion < engineapi.CLIVersionEight {
+			assert.Error(v.Create(nil, readOnly))
+			assert.Error(v.Update(nil, readWrite, readOnly))
+			continue
+		}
+		assert.NoError(v.Create(nil, readOnly))
+		assert.NoError(v.Update(nil, readWrite, readOnly))
+		assert.NoError(v.Update(nil, readOnly, readWrite))
+	}
+}
+
+func TestValidateQoS(t *testing.T) {
+	assert := require.New(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	for _, cliAPIVersion := range []int{engineapi.CLIVersionSeven, engineapi.CLIVersionEight} {
+		c, err := fake.NewCluster(testNamespace, stopCh, newTestEngineImage(cliAPIVersion))
+		assert.NoError(err)
+		v := NewValidator(c.DataStore, testNode)
+
+		unlimited := newTestVolume("vol-1", "", testSize)
+		unlimited.Spec.FrontendIOPSLimit = -1
+		limited := newTestVolume("vol-1", "", testSize)
+		limited.Spec.FrontendIOPSLimit = 1000
+		assert.NoError(v.Create(nil, unlimited))
+		assert.NoError(v.Update(nil, limited, unlimited))
+		if cliAPIVersion < engineapi.CLIVersionEight {
+			assert.Error(v.Create(nil, limited))
+			assert.Error(v.Update(nil, unlimited, limited))
+			continue
+		}
+		assert.NoError(v.Create(nil, limited))
+		assert.NoError(v.Update(nil, unlimited, limited))
+	}
+}